@@ -352,6 +352,25 @@ func test(pkgs ...string) {
 	}
 
 	runPrint(goCmd, append(args, pkgs...)...)
+
+	if runtime.GOARCH == "amd64" {
+		build32(pkgs...)
+	}
+}
+
+// build32 builds the packages for 386. Constants that overflow an int, such
+// as large bit masks, only fail to compile on 32 bit platforms.
+func build32(pkgs ...string) {
+	prev, ok := os.LookupEnv("GOARCH")
+	os.Setenv("GOARCH", "386")
+	defer func() {
+		if ok {
+			os.Setenv("GOARCH", prev)
+		} else {
+			os.Unsetenv("GOARCH")
+		}
+	}()
+	runPrint(goCmd, append([]string{"build"}, pkgs...)...)
 }
 
 func bench(pkgs ...string) {
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sessiontoken"
 )
 
 // announcement is the format received from and sent to clients
@@ -39,6 +40,8 @@ type apiSrv struct {
 	listener net.Listener
 	repl     replicator // optional
 	useHTTP  bool
+	allowed  *sessiontoken.AllowList // optional; makes the server private
+	tokens   *sessiontoken.Issuer    // set when allowed is
 
 	mapsMut sync.Mutex
 	misses  map[string]int32
@@ -54,13 +57,15 @@ type contextKey int
 
 const idKey contextKey = iota
 
-func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, useHTTP bool) *apiSrv {
+func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, useHTTP bool, allowed *sessiontoken.AllowList, tokens *sessiontoken.Issuer) *apiSrv {
 	return &apiSrv{
 		addr:    addr,
 		cert:    cert,
 		db:      db,
 		repl:    repl,
 		useHTTP: useHTTP,
+		allowed: allowed,
+		tokens:  tokens,
 		misses:  make(map[string]int32),
 	}
 }
@@ -146,10 +151,12 @@ func (s *apiSrv) handler(w http.ResponseWriter, req *http.Request) {
 		remoteIP = addr.IP
 	}
 
-	switch req.Method {
-	case "GET":
+	switch {
+	case req.Method == "GET" && s.tokens != nil && strings.HasSuffix(req.URL.Path, "/token"):
+		s.handleToken(ctx, lw, req)
+	case req.Method == "GET":
 		s.handleGET(ctx, lw, req)
-	case "POST":
+	case req.Method == "POST":
 		s.handlePOST(ctx, remoteIP, lw, req)
 	default:
 		http.Error(lw, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -159,6 +166,19 @@ func (s *apiSrv) handler(w http.ResponseWriter, req *http.Request) {
 func (s *apiSrv) handleGET(ctx context.Context, w http.ResponseWriter, req *http.Request) {
 	reqID := ctx.Value(idKey).(requestID)
 
+	if s.tokens != nil {
+		// A private server only answers devices that have a valid token.
+		if _, err := s.tokens.Verify(bearerToken(req)); err != nil {
+			if debug {
+				log.Println(reqID, "lookup token:", err)
+			}
+			lookupRequestsTotal.WithLabelValues("unauthorized").Inc()
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	deviceID, err := protocol.DeviceIDFromString(req.URL.Query().Get("device"))
 	if err != nil {
 		if debug {
@@ -242,6 +262,15 @@ func (s *apiSrv) handlePOST(ctx context.Context, remoteIP net.IP, w http.Respons
 	}
 
 	deviceID := protocol.NewDeviceID(rawCert)
+	if s.allowed != nil && !s.allowed.Allowed(deviceID) {
+		if debug {
+			log.Println(reqID, deviceID, "not allowed")
+		}
+		announceRequestsTotal.WithLabelValues("not_allowed").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
 	addresses := fixupAddresses(remoteIP, ann.Addresses)
 	if len(addresses) == 0 {
@@ -260,10 +289,54 @@ func (s *apiSrv) handlePOST(ctx context.Context, remoteIP net.IP, w http.Respons
 
 	announceRequestsTotal.WithLabelValues("success").Inc()
 
+	if s.tokens != nil {
+		s.setSessionToken(w, deviceID)
+	}
 	w.Header().Set("Reannounce-After", reannounceAfterString())
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleToken issues a session token to an allowed device, proven by its
+// certificate, for use with lookups on a private server.
+func (s *apiSrv) handleToken(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	reqID := ctx.Value(idKey).(requestID)
+
+	rawCert := certificateBytes(req)
+	if rawCert == nil {
+		if debug {
+			log.Println(reqID, "no certificates")
+		}
+		tokenRequestsTotal.WithLabelValues("no_certificate").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	deviceID := protocol.NewDeviceID(rawCert)
+	if !s.allowed.Allowed(deviceID) {
+		if debug {
+			log.Println(reqID, deviceID, "not allowed")
+		}
+		tokenRequestsTotal.WithLabelValues("not_allowed").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	tokenRequestsTotal.WithLabelValues("success").Inc()
+
+	s.setSessionToken(w, deviceID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setSessionToken sets the Session-Token header, and Session-Token-Expires
+// to the number of seconds the token is valid for.
+func (s *apiSrv) setSessionToken(w http.ResponseWriter, deviceID protocol.DeviceID) {
+	token, _ := s.tokens.Issue(deviceID)
+	w.Header().Set("Session-Token", token)
+	w.Header().Set("Session-Token-Expires", strconv.Itoa(int(s.tokens.Lifetime().Seconds())))
+}
+
 func (s *apiSrv) Stop() {
 	s.listener.Close()
 }
@@ -290,6 +363,14 @@ func handlePing(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 
+func bearerToken(req *http.Request) string {
+	const prefix = "Bearer "
+	if hdr := req.Header.Get("Authorization"); strings.HasPrefix(hdr, prefix) {
+		return strings.TrimSpace(hdr[len(prefix):])
+	}
+	return ""
+}
+
 func certificateBytes(req *http.Request) []byte {
 	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
 		return req.TLS.PeerCertificates[0].Raw
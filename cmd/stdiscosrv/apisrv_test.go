@@ -7,9 +7,20 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sessiontoken"
 )
 
 func TestFixupAddresses(t *testing.T) {
@@ -63,3 +74,98 @@ func TestFixupAddresses(t *testing.T) {
 		}
 	}
 }
+
+func TestPrivateServer(t *testing.T) {
+	allowedCert := &x509.Certificate{Raw: []byte("allowed")}
+	otherCert := &x509.Certificate{Raw: []byte("other")}
+
+	fd, err := ioutil.TempFile("", "allowed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fd.Name())
+	fd.WriteString(protocol.NewDeviceID(allowedCert.Raw).String() + "\n")
+	fd.Close()
+
+	tokens := sessiontoken.NewIssuer(time.Hour)
+	allowed, err := sessiontoken.NewAllowList(fd.Name(), tokens.Revoke)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newAPISrv("", tls.Certificate{}, newFakeDB(), nil, false, allowed, tokens)
+
+	do := func(method, path, token string, cert *x509.Certificate) *httptest.ResponseRecorder {
+		var body string
+		if method == "POST" {
+			body = `{"addresses":["tcp://192.0.2.42:22000"]}`
+		}
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		rec := httptest.NewRecorder()
+		srv.handler(rec, req)
+		return rec
+	}
+	lookup := "/?device=" + protocol.NewDeviceID(allowedCert.Raw).String()
+
+	if rec := do("POST", "/", "", otherCert); rec.Code != http.StatusForbidden {
+		t.Error("Expected announce from other device to be forbidden, got", rec.Code)
+	}
+	if rec := do("GET", "/token", "", otherCert); rec.Code != http.StatusForbidden {
+		t.Error("Expected token for other device to be forbidden, got", rec.Code)
+	}
+	if rec := do("GET", lookup, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Error("Expected lookup without token to be unauthorized, got", rec.Code)
+	}
+
+	rec := do("POST", "/", "", allowedCert)
+	token := rec.Header().Get("Session-Token")
+	if rec.Code != http.StatusNoContent || token == "" {
+		t.Fatal("Expected announce to succeed with a token, got", rec.Code)
+	}
+	if rec := do("GET", lookup, token, nil); rec.Code != http.StatusOK {
+		t.Error("Expected lookup with token to succeed, got", rec.Code)
+	}
+	rec = do("GET", "/token", "", allowedCert)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Session-Token") == "" || rec.Header().Get("Session-Token-Expires") != "3600" {
+		t.Error("Expected a token, got", rec.Code, rec.Header())
+	}
+
+	// Removing the device from the list revokes its tokens
+	ioutil.WriteFile(fd.Name(), nil, 0644)
+	if err := allowed.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do("GET", lookup, token, nil); rec.Code != http.StatusUnauthorized {
+		t.Error("Expected lookup with revoked token to be unauthorized, got", rec.Code)
+	}
+}
+
+type fakeDB struct {
+	recs map[string]DatabaseRecord
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{recs: make(map[string]DatabaseRecord)}
+}
+
+func (db *fakeDB) put(key string, rec DatabaseRecord) error {
+	db.recs[key] = rec
+	return nil
+}
+
+func (db *fakeDB) merge(key string, addrs []DatabaseAddress, seen int64) error {
+	rec := db.recs[key]
+	rec.Addresses = append(rec.Addresses, addrs...)
+	rec.Seen = seen
+	db.recs[key] = rec
+	return nil
+}
+
+func (db *fakeDB) get(key string) (DatabaseRecord, error) {
+	return db.recs[key], nil
+}
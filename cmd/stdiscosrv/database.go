@@ -154,6 +154,29 @@ func (s *levelDBStore) get(key string) (DatabaseRecord, error) {
 	return rec, nil
 }
 
+// snapshot calls the function for each record with addresses that haven't
+// expired, until it returns false. It sees the database as it was when
+// called, and doesn't hold up other operations.
+func (s *levelDBStore) snapshot(fn func(key string, addrs []DatabaseAddress, seen int64) bool) error {
+	now := s.clock.Now().UnixNano()
+	iter := s.db.NewIterator(&util.Range{}, nil)
+	defer iter.Release()
+	for iter.Next() {
+		var rec DatabaseRecord
+		if err := rec.Unmarshal(iter.Value()); err != nil {
+			continue
+		}
+		addrs := expire(rec.Addresses, now)
+		if len(addrs) == 0 {
+			continue
+		}
+		if !fn(string(iter.Key()), addrs, rec.Seen) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
 func (s *levelDBStore) Serve() {
 	t := time.NewTimer(0)
 	defer t.Stop()
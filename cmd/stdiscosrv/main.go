@@ -21,6 +21,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sessiontoken"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/thejerf/suture"
@@ -96,10 +97,13 @@ func main() {
 	var certFile string
 	var keyFile string
 	var useHTTP bool
+	var allowedDevices string
+	var tokenLifetime time.Duration
 
 	log.SetOutput(os.Stdout)
 	log.SetFlags(0)
 
+	flag.StringVar(&allowedDevices, "allowed-devices", "", "File of device IDs allowed to announce and look up, making the server private")
 	flag.StringVar(&certFile, "cert", "./cert.pem", "Certificate file")
 	flag.StringVar(&dir, "db-dir", "./discovery.db", "Database directory")
 	flag.BoolVar(&debug, "debug", false, "Print debug output")
@@ -109,6 +113,7 @@ func main() {
 	flag.StringVar(&metricsListen, "metrics-listen", "", "Metrics listen address")
 	flag.StringVar(&replicationPeers, "replicate", "", "Replication peers, id@address, comma separated")
 	flag.StringVar(&replicationListen, "replication-listen", ":19200", "Replication listen address")
+	flag.DurationVar(&tokenLifetime, "token-lifetime", time.Hour, "Lifetime of session tokens for lookups on a private server")
 	flag.Parse()
 
 	log.Println(LongVersion)
@@ -173,7 +178,7 @@ func main() {
 	// Start any replication senders.
 	var repl replicationMultiplexer
 	for _, dst := range replicationDestinations {
-		rs := newReplicationSender(dst, cert, allowedReplicationPeers)
+		rs := newReplicationSender(dst, cert, allowedReplicationPeers, db)
 		main.Add(rs)
 		repl = append(repl, rs)
 	}
@@ -184,8 +189,25 @@ func main() {
 		main.Add(rl)
 	}
 
+	// If we are private, devices get session tokens for lookups. Tokens of
+	// devices removed from the allowed list are revoked.
+	var allowed *sessiontoken.AllowList
+	var tokens *sessiontoken.Issuer
+	if allowedDevices != "" {
+		tokens = sessiontoken.NewIssuer(tokenLifetime)
+		allowed, err = sessiontoken.NewAllowList(allowedDevices, func(id protocol.DeviceID) {
+			log.Println("Revoking session tokens of", id)
+			tokens.Revoke(id)
+		})
+		if err != nil {
+			log.Fatalln("Loading allowed devices:", err)
+		}
+		main.Add(tokens)
+		main.Add(allowed)
+	}
+
 	// Start the main API server.
-	qs := newAPISrv(listen, cert, db, repl, useHTTP)
+	qs := newAPISrv(listen, cert, db, repl, useHTTP, allowed, tokens)
 	main.Add(qs)
 
 	// If we have a metrics port configured, start a metrics handler.
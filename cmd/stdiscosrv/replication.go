@@ -25,21 +25,31 @@ type replicator interface {
 	send(key string, addrs []DatabaseAddress, seen int64)
 }
 
+// a snapshotter walks the current records of the database, calling the
+// function for each until it returns false.
+type snapshotter interface {
+	snapshot(fn func(key string, addrs []DatabaseAddress, seen int64) bool) error
+}
+
 // a replicationSender tries to connect to the remote address and provide
-// them with a feed of replication updates.
+// them with a feed of replication updates. Each time it connects it first
+// sends the current records of the database, so that a peer which was down
+// or is new catches up on what it missed.
 type replicationSender struct {
 	dst        string
 	cert       tls.Certificate // our certificate
 	allowedIDs []protocol.DeviceID
+	db         snapshotter
 	outbox     chan ReplicationRecord
 	stop       chan struct{}
 }
 
-func newReplicationSender(dst string, cert tls.Certificate, allowedIDs []protocol.DeviceID) *replicationSender {
+func newReplicationSender(dst string, cert tls.Certificate, allowedIDs []protocol.DeviceID, db snapshotter) *replicationSender {
 	return &replicationSender{
 		dst:        dst,
 		cert:       cert,
 		allowedIDs: allowedIDs,
+		db:         db,
 		outbox:     make(chan ReplicationRecord, replicationOutboxSize),
 		stop:       make(chan struct{}),
 	}
@@ -82,11 +92,40 @@ func (s *replicationSender) Serve() {
 		return
 	}
 
+	// Bring the other side up to date. Updates arriving meanwhile wait in
+	// the outbox.
+	buf := make([]byte, 1024)
+	sent := 0
+	var writeErr error
+	err = s.db.snapshot(func(key string, addrs []DatabaseAddress, seen int64) bool {
+		rec := ReplicationRecord{
+			Key:       key,
+			Addresses: addrs,
+			Seen:      seen,
+		}
+		buf, writeErr = s.write(conn, buf, rec)
+		if writeErr != nil {
+			return false
+		}
+		sent++
+		return true
+	})
+	if writeErr != nil {
+		log.Println("Replication write:", writeErr)
+		return
+	}
+	if err != nil {
+		log.Println("Replication snapshot:", err)
+		return
+	}
+	if debug {
+		log.Println("Replication: sent", sent, "records to", s.dst)
+	}
+
 	heartBeatTicker := time.NewTicker(replicationHeartbeatInterval)
 	defer heartBeatTicker.Stop()
 
 	// Send records.
-	buf := make([]byte, 1024)
 	for {
 		select {
 		case <-heartBeatTicker.C:
@@ -99,32 +138,12 @@ func (s *replicationSender) Serve() {
 			s.outbox <- ReplicationRecord{}
 
 		case rec := <-s.outbox:
-			// Buffer must hold record plus four bytes for size
-			size := rec.Size()
-			if len(buf) < size+4 {
-				buf = make([]byte, size+4)
-			}
-
-			// Record comes after the four bytes size
-			n, err := rec.MarshalTo(buf[4:])
+			buf, err = s.write(conn, buf, rec)
 			if err != nil {
-				// odd to get an error here, but we haven't sent anything
-				// yet so it's not fatal
-				replicationSendsTotal.WithLabelValues("error").Inc()
-				log.Println("Replication marshal:", err)
-				continue
-			}
-			binary.BigEndian.PutUint32(buf, uint32(n))
-
-			// Send
-			conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-			if _, err := conn.Write(buf[:4+n]); err != nil {
-				replicationSendsTotal.WithLabelValues("error").Inc()
 				log.Println("Replication write:", err)
 				// Yes, we are loosing the replication event here.
 				return
 			}
-			replicationSendsTotal.WithLabelValues("success").Inc()
 
 		case <-s.stop:
 			return
@@ -132,6 +151,37 @@ func (s *replicationSender) Serve() {
 	}
 }
 
+// write sends the record, returning the buffer used, which grows for large
+// records. Records that fail to marshal are skipped; write errors are fatal
+// for the connection.
+func (s *replicationSender) write(conn net.Conn, buf []byte, rec ReplicationRecord) ([]byte, error) {
+	// Buffer must hold record plus four bytes for size
+	size := rec.Size()
+	if len(buf) < size+4 {
+		buf = make([]byte, size+4)
+	}
+
+	// Record comes after the four bytes size
+	n, err := rec.MarshalTo(buf[4:])
+	if err != nil {
+		// odd to get an error here, but we haven't sent anything
+		// yet so it's not fatal
+		replicationSendsTotal.WithLabelValues("error").Inc()
+		log.Println("Replication marshal:", err)
+		return buf, nil
+	}
+	binary.BigEndian.PutUint32(buf, uint32(n))
+
+	// Send
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(buf[:4+n]); err != nil {
+		replicationSendsTotal.WithLabelValues("error").Inc()
+		return buf, err
+	}
+	replicationSendsTotal.WithLabelValues("success").Inc()
+	return buf, nil
+}
+
 func (s *replicationSender) Stop() {
 	close(s.stop)
 }
@@ -144,6 +194,7 @@ func (s *replicationSender) send(key string, ps []DatabaseAddress, seen int64) {
 	item := ReplicationRecord{
 		Key:       key,
 		Addresses: ps,
+		Seen:      seen,
 	}
 
 	// The send should never block. The inbox is suitably buffered for at
@@ -0,0 +1,94 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestReplicationCatchUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stdiscosrv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, err := tlsutil.NewCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "stdiscosrv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []protocol.DeviceID{protocol.NewDeviceID(cert.Certificate[0])}
+
+	src, err := newLevelDBStore(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go src.Serve()
+	defer src.Stop()
+	dst, err := newLevelDBStore(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go dst.Serve()
+	defer dst.Stop()
+
+	// Records announced before the peer is there; one has expired.
+	expires := time.Now().Add(time.Hour).UnixNano()
+	seen := time.Now().UnixNano()
+	if err := src.put("abcd", DatabaseRecord{Addresses: []DatabaseAddress{{Address: "tcp://192.0.2.1:22000", Expires: expires}}, Seen: seen}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.put("efgh", DatabaseRecord{Addresses: []DatabaseAddress{{Address: "tcp://192.0.2.2:22000", Expires: 1}}, Seen: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lst.Addr().String()
+	lst.Close()
+
+	rl := newReplicationListener(addr, cert, ids, dst)
+	go rl.Serve()
+	defer rl.Stop()
+	rs := newReplicationSender(addr, cert, ids, src)
+	go rs.Serve()
+	defer rs.Stop()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec, err := dst.get("abcd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.Addresses) == 1 {
+			if rec.Addresses[0].Address != "tcp://192.0.2.1:22000" || rec.Seen != seen {
+				t.Errorf("Unexpected record %v", rec)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Record not replicated")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if rec, err := dst.get("efgh"); err != nil {
+		t.Fatal(err)
+	} else if rec.Seen != 0 {
+		t.Errorf("Expired record was replicated: %v", rec)
+	}
+}
@@ -43,6 +43,13 @@ var (
 			Name:      "announcement_requests_total",
 			Help:      "Number of announcement requests.",
 		}, []string{"result"})
+	tokenRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "syncthing",
+			Subsystem: "discovery",
+			Name:      "token_requests_total",
+			Help:      "Number of session token requests.",
+		}, []string{"result"})
 
 	replicationSendsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -104,7 +111,7 @@ const (
 
 func init() {
 	prometheus.MustRegister(apiRequestsTotal, apiRequestsSeconds,
-		lookupRequestsTotal, announceRequestsTotal,
+		lookupRequestsTotal, announceRequestsTotal, tokenRequestsTotal,
 		replicationSendsTotal, replicationRecvsTotal,
 		databaseKeys, databaseStatisticsSeconds,
 		databaseOperations, databaseOperationSeconds)
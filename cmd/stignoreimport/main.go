@@ -0,0 +1,53 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Command stignoreimport converts the ignore rules of other sync tools to
+// .stignore patterns.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/ignore"
+)
+
+func main() {
+	format := flag.String("format", ignore.FormatRsync, "Format of the input: resilio, unison, rsync or selective")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-format <format>] [file]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Prints the .stignore patterns equivalent to the file, or standard input.")
+		fmt.Fprintln(os.Stderr, "The selective format lists the paths synced with selective sync, one per line.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if path := flag.Arg(0); path != "" {
+		fd, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
+			os.Exit(1)
+		}
+		defer fd.Close()
+		input = fd
+	}
+
+	patterns, warnings, err := ignore.ImportPatterns(*format, input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", warning)
+	}
+	for _, pattern := range patterns {
+		fmt.Println(pattern)
+	}
+}
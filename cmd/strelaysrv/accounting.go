@@ -0,0 +1,115 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	syncthingprotocol "github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How often the bytes relayed in the sessions are added to the usage of
+// the devices, and thus how long a device can go over its cap.
+const accountingInterval = 10 * time.Second
+
+var (
+	deviceCapBytes  int64
+	deviceCapPeriod = 30 * 24 * time.Hour
+
+	usageMut         sync.Mutex
+	usagePeriodStart = time.Now()
+	deviceUsage      = make(map[syncthingprotocol.DeviceID]int64)
+)
+
+// accountingEnabled returns whether the usage of the devices is counted,
+// which is only needed for a cap or to show a private relay's users.
+func accountingEnabled() bool {
+	return deviceCapBytes > 0 || allowed != nil
+}
+
+// accountingService adds the bytes relayed in the active sessions to the
+// usage of the devices every accountingInterval, ending the sessions of
+// devices over their cap.
+func accountingService() {
+	for range time.NewTicker(accountingInterval).C {
+		sessionMut.RLock()
+		for _, ses := range activeSessions {
+			if !ses.flushUsage() {
+				if debug {
+					log.Println("Session", ses, "ending as a device is over its transfer cap")
+				}
+				ses.CloseConns()
+			}
+		}
+		sessionMut.RUnlock()
+	}
+}
+
+// flushUsage adds the bytes relayed in the session since the last flush to
+// the usage of both devices taking part in it, and returns false if either
+// of them is now over the cap.
+func (s *session) flushUsage() bool {
+	n := atomic.SwapInt64(&s.unaccounted, 0)
+	if n == 0 {
+		return !overCap(s.serverid) && !overCap(s.clientid)
+	}
+	return accountBytes(n, s.serverid, s.clientid)
+}
+
+// accountBytes adds the bytes to the usage of the devices, and returns
+// false if any of them is now over the cap.
+func accountBytes(n int64, ids ...syncthingprotocol.DeviceID) bool {
+	usageMut.Lock()
+	defer usageMut.Unlock()
+	rolloverUsageLocked()
+	ok := true
+	for _, id := range ids {
+		deviceUsage[id] += n
+		if deviceCapBytes > 0 && deviceUsage[id] > deviceCapBytes {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// overCap returns whether the device has used up its cap for the current
+// period.
+func overCap(id syncthingprotocol.DeviceID) bool {
+	if deviceCapBytes <= 0 {
+		return false
+	}
+	usageMut.Lock()
+	defer usageMut.Unlock()
+	rolloverUsageLocked()
+	return deviceUsage[id] >= deviceCapBytes
+}
+
+// usageSnapshot returns the bytes each device has relayed in the current
+// period, and when the period started.
+func usageSnapshot() (map[string]int64, time.Time) {
+	usageMut.Lock()
+	defer usageMut.Unlock()
+	rolloverUsageLocked()
+	res := make(map[string]int64, len(deviceUsage))
+	for id, bytes := range deviceUsage {
+		res[id.String()] = bytes
+	}
+	return res, usagePeriodStart
+}
+
+// rolloverUsageLocked starts a new accounting period once the current one
+// is over, forgetting the usage so far.
+func rolloverUsageLocked() {
+	if time.Since(usagePeriodStart) < deviceCapPeriod {
+		return
+	}
+	usagePeriodStart = time.Now()
+	deviceUsage = make(map[syncthingprotocol.DeviceID]int64)
+}
@@ -63,6 +63,7 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 		if debug {
 			log.Println("Weird error setting deadline:", err, "on", conn.RemoteAddr())
 		}
+		handshakeFailuresTotal.WithLabelValues("deadline").Inc()
 		conn.Close()
 		return
 	}
@@ -71,6 +72,7 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 		if debug {
 			log.Println("Protocol connection TLS handshake:", conn.RemoteAddr(), err)
 		}
+		handshakeFailuresTotal.WithLabelValues("tls").Inc()
 		conn.Close()
 		return
 	}
@@ -85,6 +87,7 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 		if debug {
 			log.Println("Certificate list error")
 		}
+		handshakeFailuresTotal.WithLabelValues("certificate").Inc()
 		conn.Close()
 		return
 	}
@@ -92,6 +95,10 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 
 	id := syncthingprotocol.NewDeviceID(certs[0].Raw)
 
+	defer func(started time.Time) {
+		connectionSeconds.Observe(time.Since(started).Seconds())
+	}(time.Now())
+
 	messages := make(chan interface{})
 	errors := make(chan error, 1)
 	outbox := make(chan interface{})
@@ -119,6 +126,24 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 
 			switch msg := message.(type) {
 			case protocol.JoinRelayRequest:
+				if !isAllowed(id) {
+					protocol.WriteMessage(conn, protocol.ResponseNotAllowed)
+					if debug {
+						log.Println("Refusing join request from", id, "as it is not allowed")
+					}
+					conn.Close()
+					continue
+				}
+
+				if overCap(id) {
+					protocol.WriteMessage(conn, protocol.ResponseNotAllowed)
+					if debug {
+						log.Println("Refusing join request from", id, "as it is over its transfer cap")
+					}
+					conn.Close()
+					continue
+				}
+
 				if atomic.LoadInt32(&overLimit) > 0 {
 					protocol.WriteMessage(conn, protocol.RelayFull{})
 					if debug {
@@ -149,7 +174,25 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 				protocol.WriteMessage(conn, protocol.ResponseSuccess)
 
 			case protocol.ConnectRequest:
+				if !isAllowed(id) {
+					protocol.WriteMessage(conn, protocol.ResponseNotAllowed)
+					if debug {
+						log.Println("Refusing connect request from", id, "as it is not allowed")
+					}
+					conn.Close()
+					continue
+				}
+
 				requestedPeer := syncthingprotocol.DeviceIDFromBytes(msg.ID)
+				if overCap(id) || overCap(requestedPeer) {
+					protocol.WriteMessage(conn, protocol.ResponseNotAllowed)
+					if debug {
+						log.Println("Refusing connect request from", id, "to", requestedPeer, "as one of them is over its transfer cap")
+					}
+					conn.Close()
+					continue
+				}
+
 				outboxesMut.RLock()
 				peerOutbox, ok := outboxes[requestedPeer]
 				outboxesMut.RUnlock()
@@ -278,8 +321,33 @@ func protocolConnectionHandler(tcpConn net.Conn, config *tls.Config) {
 				}
 				conn.Close()
 			}
+			if msg == protocol.ResponseNotAllowed {
+				// We've been told to drop the device.
+				conn.Close()
+			}
+		}
+	}
+}
+
+func isAllowed(id syncthingprotocol.DeviceID) bool {
+	return allowed == nil || allowed.Allowed(id)
+}
+
+// dropDevice disconnects a device that has been removed from the allowed
+// devices, along with any sessions it takes part in.
+func dropDevice(id syncthingprotocol.DeviceID) {
+	log.Println("Dropping", id, "as it is no longer allowed")
+
+	outboxesMut.RLock()
+	outbox, ok := outboxes[id]
+	outboxesMut.RUnlock()
+	if ok {
+		select {
+		case outbox <- protocol.ResponseNotAllowed:
+		case <-time.After(time.Second):
 		}
 	}
+	dropSessions(id)
 }
 
 func sessionConnectionHandler(conn net.Conn) {
@@ -22,6 +22,7 @@ import (
 
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/relay/protocol"
+	"github.com/syncthing/syncthing/lib/sessiontoken"
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"golang.org/x/time/rate"
 
@@ -84,6 +85,9 @@ var (
 	natTimeout int
 
 	pprofEnabled bool
+
+	allowedDevices string
+	allowed        *sessiontoken.AllowList
 )
 
 // httpClient is the HTTP client we use for outbound requests. It has a
@@ -105,7 +109,7 @@ func main() {
 	flag.IntVar(&sessionLimitBps, "per-session-rate", sessionLimitBps, "Per session rate limit, in bytes/s")
 	flag.IntVar(&globalLimitBps, "global-rate", globalLimitBps, "Global rate limit, in bytes/s")
 	flag.BoolVar(&debug, "debug", debug, "Enable debug output")
-	flag.StringVar(&statusAddr, "status-srv", ":22070", "Listen address for status service (blank to disable)")
+	flag.StringVar(&statusAddr, "status-srv", ":22070", "Listen address for status and metrics service (blank to disable)")
 	flag.StringVar(&poolAddrs, "pools", defaultPoolAddrs, "Comma separated list of relay pool addresses to join")
 	flag.StringVar(&providedBy, "provided-by", "", "An optional description about who provides the relay")
 	flag.StringVar(&extAddress, "ext-address", "", "An optional address to advertise as being available on.\n\tAllows listening on an unprivileged port with port forwarding from e.g. 443, and be connected to on port 443.")
@@ -116,6 +120,9 @@ func main() {
 	flag.IntVar(&natTimeout, "nat-timeout", 10, "NAT discovery timeout in seconds")
 	flag.BoolVar(&pprofEnabled, "pprof", false, "Enable the built in profiling on the status server")
 	flag.IntVar(&networkBufferSize, "network-buffer", 2048, "Network buffer size (two of these per proxied connection)")
+	flag.StringVar(&allowedDevices, "allowed-devices", "", "File of device IDs allowed to use the relay, making it private.\n\tThe file is reloaded every minute; removed devices are disconnected.")
+	flag.Int64Var(&deviceCapBytes, "per-device-cap", deviceCapBytes, "Bytes each device may relay per cap period, sent and received together (0 for no cap).\n\tDevices over the cap are disconnected and refused until the period ends.")
+	flag.DurationVar(&deviceCapPeriod, "per-device-cap-period", deviceCapPeriod, "Period after which the transfer accounting of the devices starts over")
 	flag.Parse()
 
 	if extAddress == "" {
@@ -243,6 +250,20 @@ func main() {
 
 	log.Println("URI:", uri.String())
 
+	if allowedDevices != "" {
+		allowed, err = sessiontoken.NewAllowList(allowedDevices, dropDevice)
+		if err != nil {
+			log.Fatalln("Loading allowed devices:", err)
+		}
+		go allowed.Serve()
+
+		if poolAddrs == defaultPoolAddrs {
+			// A private relay is of no use to the public.
+			log.Println("Private relay, not joining the default relay pools")
+			poolAddrs = ""
+		}
+	}
+
 	if poolAddrs == defaultPoolAddrs {
 		log.Println("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!")
 		log.Println("!!  Joining default relay pools, this relay will be available for public use. !!")
@@ -258,6 +279,10 @@ func main() {
 		}
 	}
 
+	if accountingEnabled() {
+		go accountingService()
+	}
+
 	go listener(proto, listen, tlsCfg)
 
 	sigs := make(chan os.Signal, 1)
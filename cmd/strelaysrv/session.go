@@ -49,6 +49,7 @@ func newSession(serverid, clientid syncthingprotocol.DeviceID, sessionRateLimit,
 		connsChan: make(chan net.Conn),
 		conns:     make([]net.Conn, 0, 2),
 	}
+	ses.accounting = accountingEnabled()
 
 	if debug {
 		log.Println("New session", ses)
@@ -101,6 +102,11 @@ func hasSessions(id syncthingprotocol.DeviceID) bool {
 }
 
 type session struct {
+	// Bytes relayed but not yet added to the usage of the devices, first
+	// in the struct for alignment on 32 bit platforms.
+	unaccounted int64
+	accounting  bool
+
 	mut sync.Mutex
 
 	serverkey []byte
@@ -171,7 +177,9 @@ func (s *session) Serve() {
 			activeSessions = append(activeSessions, s)
 			sessionMut.Unlock()
 
+			started := time.Now()
 			wg.Wait()
+			sessionSeconds.Observe(time.Since(started).Seconds())
 
 			if debug {
 				log.Println("Session", s, "ended, outcomes:", err0, "and", err1)
@@ -209,6 +217,10 @@ done:
 	// all connections a second time.
 	s.CloseConns()
 
+	if s.accounting {
+		s.flushUsage()
+	}
+
 	if debug {
 		log.Println("Session", s, "stopping")
 	}
@@ -263,6 +275,9 @@ func (s *session) proxy(c1, c2 net.Conn) error {
 		}
 
 		atomic.AddInt64(&bytesProxied, int64(n))
+		if s.accounting {
+			atomic.AddInt64(&s.unaccounted, int64(n))
+		}
 
 		if debug {
 			log.Printf("%d bytes from %s to %s", n, c1.RemoteAddr(), c2.RemoteAddr())
@@ -0,0 +1,126 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	handshakeFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "handshake_failures_total",
+			Help:      "Number of protocol connections that failed before the device was known.",
+		}, []string{"reason"})
+	connectionSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "connection_seconds",
+			Help:      "Duration of protocol connections.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		})
+	sessionSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "session_seconds",
+			Help:      "Duration of sessions, from both parties joining to either leaving.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		})
+)
+
+// The intervals over which the rate of bytes proxied is given, as periods
+// of the rate calculator.
+var rateIntervals = []struct {
+	name    string
+	periods int
+}{
+	{"10s", 1},
+	{"1m", 60 / 10},
+	{"5m", 5 * 60 / 10},
+	{"15m", 15 * 60 / 10},
+	{"30m", 30 * 60 / 10},
+	{"60m", 60 * 60 / 10},
+}
+
+func init() {
+	prometheus.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{
+			Namespace: "syncthing_relaysrv",
+			PidFn: func() (int, error) {
+				return os.Getpid(), nil
+			},
+		}),
+		handshakeFailuresTotal, connectionSeconds, sessionSeconds,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "pending_session_keys",
+			Help:      "Number of pending session keys, two per session.",
+		}, func() float64 {
+			sessionMut.RLock()
+			defer sessionMut.RUnlock()
+			return float64(len(pendingSessions))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "active_sessions",
+			Help:      "Number of sessions both parties have joined.",
+		}, func() float64 {
+			sessionMut.RLock()
+			defer sessionMut.RUnlock()
+			return float64(len(activeSessions))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "connections",
+			Help:      "Number of protocol connections.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(&numConnections))
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "proxies",
+			Help:      "Number of proxy routines, two per session.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(&numProxies))
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "syncthing",
+			Subsystem: "relaysrv",
+			Name:      "proxied_bytes_total",
+			Help:      "Number of bytes proxied.",
+		}, func() float64 {
+			return float64(atomic.LoadInt64(&bytesProxied))
+		}),
+	)
+
+	for _, interval := range rateIntervals {
+		periods := interval.periods
+		prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   "syncthing",
+			Subsystem:   "relaysrv",
+			Name:        "proxied_bytes_per_second",
+			Help:        "Rate of bytes proxied, averaged over the interval.",
+			ConstLabels: prometheus.Labels{"interval": interval.name},
+		}, func() float64 {
+			if rc == nil {
+				return 0
+			}
+			return float64(rc.rate(periods))
+		}))
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"runtime"
 	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var rc *rateCalculator
@@ -19,6 +21,7 @@ func statusService(addr string) {
 
 	handler := http.NewServeMux()
 	handler.HandleFunc("/status", getStatus)
+	handler.Handle("/metrics", promhttp.Handler())
 	if pprofEnabled {
 		handler.HandleFunc("/debug/pprof/", pprof.Index)
 	}
@@ -66,13 +69,21 @@ func getStatus(w http.ResponseWriter, r *http.Request) {
 		rc.rate(60*60/10) * 8 / 1000,
 	}
 	status["options"] = map[string]interface{}{
-		"network-timeout":  networkTimeout / time.Second,
-		"ping-interval":    pingInterval / time.Second,
-		"message-timeout":  messageTimeout / time.Second,
-		"per-session-rate": sessionLimitBps,
-		"global-rate":      globalLimitBps,
-		"pools":            pools,
-		"provided-by":      providedBy,
+		"network-timeout":       networkTimeout / time.Second,
+		"ping-interval":         pingInterval / time.Second,
+		"message-timeout":       messageTimeout / time.Second,
+		"per-session-rate":      sessionLimitBps,
+		"global-rate":           globalLimitBps,
+		"pools":                 pools,
+		"provided-by":           providedBy,
+		"per-device-cap":        deviceCapBytes,
+		"per-device-cap-period": deviceCapPeriod / time.Second,
+	}
+	if allowed != nil {
+		// Only a private relay tells which devices use it.
+		usage, since := usageSnapshot()
+		status["deviceBytesProxied"] = usage
+		status["deviceAccountingSince"] = since
 	}
 
 	bs, err := json.MarshalIndent(status, "", "    ")
@@ -16,15 +16,17 @@ import (
 // The auditService subscribes to events and writes these in JSON format, one
 // event per line, to the specified writer.
 type auditService struct {
-	w       io.Writer     // audit destination
-	stop    chan struct{} // signals time to stop
-	started chan struct{} // signals startup complete
-	stopped chan struct{} // signals stop complete
+	w       io.Writer        // audit destination
+	mask    events.EventType // events to write
+	stop    chan struct{}    // signals time to stop
+	started chan struct{}    // signals startup complete
+	stopped chan struct{}    // signals stop complete
 }
 
-func newAuditService(w io.Writer) *auditService {
+func newAuditService(w io.Writer, mask events.EventType) *auditService {
 	return &auditService{
 		w:       w,
+		mask:    mask,
 		stop:    make(chan struct{}),
 		started: make(chan struct{}),
 		stopped: make(chan struct{}),
@@ -34,7 +36,7 @@ func newAuditService(w io.Writer) *auditService {
 // Serve runs the audit service.
 func (s *auditService) Serve() {
 	defer close(s.stopped)
-	sub := events.Default.Subscribe(events.AllEvents)
+	sub := events.Default.Subscribe(s.mask)
 	defer events.Default.Unsubscribe(sub)
 	enc := json.NewEncoder(s.w)
 
@@ -17,7 +17,7 @@ import (
 
 func TestAuditService(t *testing.T) {
 	buf := new(bytes.Buffer)
-	service := newAuditService(buf)
+	service := newAuditService(buf, events.AllEvents)
 
 	// Event sent before start, will not be logged
 	events.Default.Log(events.ConfigSaved, "the first event")
@@ -0,0 +1,168 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/urfave/cli"
+)
+
+var devicesCommand = cli.Command{
+	Name:     "devices",
+	HideHelp: true,
+	Usage:    "Device command group",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the devices, and whether they are connected",
+			Action: expects(0, devicesList),
+		},
+		{
+			Name:      "pause",
+			Usage:     "Pause the device",
+			ArgsUsage: "[device id]",
+			Action:    expects(1, devicesSetPaused(true)),
+		},
+		{
+			Name:      "resume",
+			Usage:     "Resume the device",
+			ArgsUsage: "[device id]",
+			Action:    expects(1, devicesSetPaused(false)),
+		},
+		{
+			Name:      "completion",
+			Usage:     "Show how far the device is in syncing each folder shared with it",
+			ArgsUsage: "[device id]",
+			Action:    expects(1, devicesCompletion),
+		},
+	},
+}
+
+func devicesList(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*APIClient)
+	cfg := c.App.Metadata["config"].(*config.Configuration)
+	response, err := client.Get("system/connections")
+	if err != nil {
+		return err
+	}
+	bytes, err := responseToBArray(response)
+	if err != nil {
+		return err
+	}
+	var conns struct {
+		Connections map[string]struct {
+			Connected bool `json:"connected"`
+		} `json:"connections"`
+	}
+	if err := json.Unmarshal(bytes, &conns); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		type device struct {
+			ID        protocol.DeviceID `json:"id"`
+			Name      string            `json:"name"`
+			Paused    bool              `json:"paused"`
+			Connected bool              `json:"connected"`
+		}
+		devices := make([]device, 0, len(cfg.Devices))
+		for _, dcfg := range cfg.Devices {
+			connected := conns.Connections[dcfg.DeviceID.String()].Connected
+			devices = append(devices, device{dcfg.DeviceID, dcfg.Name, dcfg.Paused, connected})
+		}
+		return prettyPrintJSON(devices)
+	}
+	writer := newTableWriter()
+	fmt.Fprintln(writer, "ID\tName\tPaused\tConnected\t")
+	for _, dcfg := range cfg.Devices {
+		connected := conns.Connections[dcfg.DeviceID.String()].Connected
+		fmt.Fprintf(writer, "%s\t%s\t%t\t%t\t\n", dcfg.DeviceID, dcfg.Name, dcfg.Paused, connected)
+	}
+	return writer.Flush()
+}
+
+// devicesSetPaused changes the device in the config, which is then posted
+// once all commands have run.
+func devicesSetPaused(paused bool) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		cfg := c.App.Metadata["config"].(*config.Configuration)
+		id, err := protocol.DeviceIDFromString(c.Args()[0])
+		if err != nil {
+			return err
+		}
+		for i := range cfg.Devices {
+			if cfg.Devices[i].DeviceID == id {
+				cfg.Devices[i].Paused = paused
+				return nil
+			}
+		}
+		return fmt.Errorf("Device %s not found", id)
+	}
+}
+
+func devicesCompletion(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*APIClient)
+	cfg := c.App.Metadata["config"].(*config.Configuration)
+	id, err := protocol.DeviceIDFromString(c.Args()[0])
+	if err != nil {
+		return err
+	}
+
+	comps := make(map[string]completion)
+	for _, fcfg := range cfg.Folders {
+		if !fcfg.SharedWith(id) {
+			continue
+		}
+		comp, err := getCompletion(client, fcfg.ID, id.String())
+		if err != nil {
+			return err
+		}
+		comps[fcfg.ID] = comp
+	}
+	if jsonOutput {
+		return prettyPrintJSON(comps)
+	}
+	writer := newTableWriter()
+	fmt.Fprintln(writer, "Folder\tCompletion\tNeed Items\tNeed Bytes\t")
+	for _, fcfg := range cfg.Folders {
+		if comp, ok := comps[fcfg.ID]; ok {
+			fmt.Fprintf(writer, "%s\t%.0f%%\t%d\t%d\t\n", fcfg.ID, comp.Completion, comp.NeedItems, comp.NeedBytes)
+		}
+	}
+	return writer.Flush()
+}
+
+// completion is the response of db/completion.
+type completion struct {
+	Completion  float64 `json:"completion"`
+	NeedBytes   int64   `json:"needBytes"`
+	NeedItems   int64   `json:"needItems"`
+	GlobalBytes int64   `json:"globalBytes"`
+	NeedDeletes int64   `json:"needDeletes"`
+}
+
+func getCompletion(client *APIClient, folder, device string) (completion, error) {
+	var comp completion
+	response, err := client.Get("db/completion?folder=" + url.QueryEscape(folder) + "&device=" + url.QueryEscape(device))
+	if err != nil {
+		return comp, err
+	}
+	bytes, err := responseToBArray(response)
+	if err != nil {
+		return comp, err
+	}
+	if response.StatusCode != 200 {
+		return comp, fmt.Errorf("getting completion: %s", bytes)
+	}
+	err = json.Unmarshal(bytes, &comp)
+	return comp, err
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/urfave/cli"
+)
+
+var foldersCommand = cli.Command{
+	Name:     "folders",
+	HideHelp: true,
+	Usage:    "Folder command group",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the folders",
+			Action: expects(0, foldersList),
+		},
+		{
+			Name:      "pause",
+			Usage:     "Pause the folder",
+			ArgsUsage: "[folder id]",
+			Action:    expects(1, foldersSetPaused(true)),
+		},
+		{
+			Name:      "resume",
+			Usage:     "Resume the folder",
+			ArgsUsage: "[folder id]",
+			Action:    expects(1, foldersSetPaused(false)),
+		},
+		{
+			Name:      "scan",
+			Usage:     "Scan the folder, or the given subdirectories of it",
+			ArgsUsage: "[folder id] [subdirectory...]",
+			Action:    foldersScan,
+		},
+		{
+			Name:      "completion",
+			Usage:     "Show how far the device is in syncing the folder",
+			ArgsUsage: "[folder id] [device id]",
+			Action:    expects(2, foldersCompletion),
+		},
+		{
+			Name:      "file-status",
+			Usage:     "Show whether the file is in sync, pending download, locally changed, ignored or in conflict, and the version each device has",
+			ArgsUsage: "[folder id] [path]",
+			Action:    expects(2, foldersFileStatus),
+		},
+	},
+}
+
+func foldersList(c *cli.Context) error {
+	cfg := c.App.Metadata["config"].(*config.Configuration)
+	if jsonOutput {
+		type folder struct {
+			ID     string `json:"id"`
+			Label  string `json:"label"`
+			Path   string `json:"path"`
+			Paused bool   `json:"paused"`
+		}
+		folders := make([]folder, 0, len(cfg.Folders))
+		for _, fcfg := range cfg.Folders {
+			folders = append(folders, folder{fcfg.ID, fcfg.Label, fcfg.Path, fcfg.Paused})
+		}
+		return prettyPrintJSON(folders)
+	}
+	writer := newTableWriter()
+	fmt.Fprintln(writer, "ID\tLabel\tPath\tPaused\t")
+	for _, fcfg := range cfg.Folders {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%t\t\n", fcfg.ID, fcfg.Label, fcfg.Path, fcfg.Paused)
+	}
+	return writer.Flush()
+}
+
+// foldersSetPaused changes the folder in the config, which is then posted
+// once all commands have run.
+func foldersSetPaused(paused bool) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		cfg := c.App.Metadata["config"].(*config.Configuration)
+		id := c.Args()[0]
+		for i := range cfg.Folders {
+			if cfg.Folders[i].ID == id {
+				cfg.Folders[i].Paused = paused
+				return nil
+			}
+		}
+		return fmt.Errorf("Folder %s not found", id)
+	}
+}
+
+func foldersScan(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("expected at least 1 argument, got %d", c.NArg())
+	}
+	qs := url.Values{"folder": {c.Args()[0]}, "sub": c.Args()[1:]}
+	return emptyPost("db/scan?" + qs.Encode())(c)
+}
+
+func foldersCompletion(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*APIClient)
+	comp, err := getCompletion(client, c.Args()[0], c.Args()[1])
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return prettyPrintJSON(comp)
+	}
+	fmt.Printf("%.0f%% complete, %d items and %d bytes needed\n", comp.Completion, comp.NeedItems, comp.NeedBytes)
+	return nil
+}
+
+func foldersFileStatus(c *cli.Context) error {
+	client := c.App.Metadata["client"].(*APIClient)
+	qs := url.Values{"folder": {c.Args()[0]}, "file": {c.Args()[1]}}
+	response, err := client.Get("db/file/status?" + qs.Encode())
+	if err != nil {
+		return err
+	}
+	bytes, err := responseToBArray(response)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != 200 {
+		return fmt.Errorf("getting file status: %s", bytes)
+	}
+	if jsonOutput {
+		return prettyPrintJSON(json.RawMessage(bytes))
+	}
+	var status struct {
+		State    string `json:"state"`
+		Versions []struct {
+			Device string `json:"device"`
+			Vector []struct {
+				ID    string `json:"id"`
+				Value uint64 `json:"value"`
+			} `json:"vector"`
+			Deleted  bool   `json:"deleted"`
+			Ordering string `json:"ordering"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(bytes, &status); err != nil {
+		return err
+	}
+	fmt.Println("State:", status.State)
+	writer := newTableWriter()
+	fmt.Fprintln(writer, "Device\tVersion\tDeleted\tCompared to global\t")
+	for _, v := range status.Versions {
+		counters := make([]string, len(v.Vector))
+		for i, c := range v.Vector {
+			counters[i] = fmt.Sprintf("%s:%d", c.ID, c.Value)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%t\t%s\t\n", v.Device, strings.Join(counters, ","), v.Deleted, v.Ordering)
+	}
+	return writer.Flush()
+}
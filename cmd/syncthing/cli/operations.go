@@ -4,10 +4,12 @@
 // License, v. 2.0. If a copy of the MPL was not distributed with this file,
 // You can obtain one at https://mozilla.org/MPL/2.0/.
 
-package main
+package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/urfave/cli"
 )
@@ -43,6 +45,12 @@ var operationCommand = cli.Command{
 			ArgsUsage: "[folder id]",
 			Action:    expects(1, foldersOverride),
 		},
+		{
+			Name:      "folder-rehash",
+			Usage:     "Hash all files in folder, or in the given subdirectories of it, even those which seem unchanged",
+			ArgsUsage: "[folder id] [subdirectory...]",
+			Action:    foldersRehash,
+		},
 	},
 }
 
@@ -76,3 +84,35 @@ func foldersOverride(c *cli.Context) error {
 	}
 	return fmt.Errorf("Folder " + rid + " not found")
 }
+
+func foldersRehash(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return fmt.Errorf("expected at least 1 argument, got %d", c.NArg())
+	}
+	client := c.App.Metadata["client"].(*APIClient)
+	qs := url.Values{"folder": {c.Args()[0]}, "sub": c.Args()[1:]}
+	response, err := client.Post("db/rehash?"+qs.Encode(), "")
+	if err != nil {
+		return err
+	}
+	bytes, err := responseToBArray(response)
+	if err != nil {
+		return err
+	}
+	var report struct {
+		Verified     int      `json:"verified"`
+		Changed      []string `json:"changed"`
+		ChangedTotal int      `json:"changedTotal"`
+	}
+	if err := json.Unmarshal(bytes, &report); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return prettyPrintJSON(json.RawMessage(bytes))
+	}
+	fmt.Printf("Verified %d files, %d changed without their modification time changing\n", report.Verified, report.ChangedTotal)
+	for _, name := range report.Changed {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -4,14 +4,16 @@
 // License, v. 2.0. If a copy of the MPL was not distributed with this file,
 // You can obtain one at https://mozilla.org/MPL/2.0/.
 
-package main
+package cli
 
 import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -26,11 +28,40 @@ func responseToBArray(response *http.Response) ([]byte, error) {
 	return bytes, response.Body.Close()
 }
 
+// jsonOutput is set by the -json flag, and makes commands print JSON
+// even where they are silent otherwise.
+var jsonOutput bool
+
+// fatal prints the error, as an object with an "error" field with -json,
+// and exits with a non-zero status.
+func fatal(vals ...interface{}) {
+	if jsonOutput {
+		_ = prettyPrintJSON(map[string]string{"error": strings.TrimSpace(fmt.Sprintln(vals...))})
+		os.Exit(1)
+	}
+	log.Fatalln(vals...)
+}
+
 func emptyPost(url string) cli.ActionFunc {
 	return func(c *cli.Context) error {
 		client := c.App.Metadata["client"].(*APIClient)
-		_, err := client.Post(url, "")
-		return err
+		response, err := client.Post(url, "")
+		if err != nil {
+			return err
+		}
+		bytes, err := responseToBArray(response)
+		if err != nil {
+			return err
+		}
+		if !jsonOutput {
+			return nil
+		}
+		var data interface{}
+		if err := json.Unmarshal(bytes, &data); err != nil {
+			// Not all endpoints respond with JSON
+			data = map[string]bool{"ok": true}
+		}
+		return prettyPrintJSON(data)
 	}
 }
 
@@ -8,6 +8,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
@@ -29,6 +30,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/syncthing/syncthing/cmd/syncthing/cli"
 	"github.com/syncthing/syncthing/lib/api"
 	"github.com/syncthing/syncthing/lib/build"
 	"github.com/syncthing/syncthing/lib/config"
@@ -41,13 +43,16 @@ import (
 	"github.com/syncthing/syncthing/lib/locations"
 	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/mqtt"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/sha256"
 	"github.com/syncthing/syncthing/lib/tlsutil"
+	"github.com/syncthing/syncthing/lib/tracing"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/ur"
+	"github.com/syncthing/syncthing/lib/webhook"
 
 	"github.com/pkg/errors"
 	"github.com/thejerf/suture"
@@ -86,6 +91,14 @@ I.e. to prefix each log line with date and time, set -logflags=3 (1 + 2 from
 above). The value 0 is used to disable all of the above. The default is to
 show time only (2).
 
+Running "syncthing cli" instead manages the running Syncthing, listing,
+pausing and resuming folders and devices, triggering scans, showing
+completion and editing the config. See "syncthing cli --help".
+
+With -logformat=json each log line is instead a JSON object with the time,
+prefix, level, facility and message, and the folder and device the message
+is about, if any.
+
 
 Development Settings
 --------------------
@@ -117,6 +130,10 @@ are mostly useful for developers. Use with care.
  STPERFSTATS       Write running performance statistics to perf-$pid.csv. Not
                    supported on Windows.
 
+ STTRACING         Set to "log" to log timing spans of scans, hashing, block
+                   requests and database writes. Set the tracingEndpoint
+                   option to export them over OTLP instead.
+
  STDEADLOCKTIMEOUT Used for debugging internal deadlocks; sets debug
                    sensitivity. Use only under direction of a developer.
 
@@ -167,6 +184,12 @@ type RuntimeOptions struct {
 	confDir          string
 	resetDatabase    bool
 	resetDeltaIdxs   bool
+	dbVerify         bool
+	dbRepair         bool
+	exportIndex      string
+	importIndex      string
+	indexFile        string
+	generateIndex    string
 	showVersion      bool
 	showPaths        bool
 	showDeviceId     bool
@@ -178,6 +201,7 @@ type RuntimeOptions struct {
 	hideConsole      bool
 	logFile          string
 	auditEnabled     bool
+	auditAdmin       bool
 	auditFile        string
 	verbose          bool
 	paused           bool
@@ -191,8 +215,10 @@ type RuntimeOptions struct {
 	cpuProfile       bool
 	stRestarting     bool
 	logFlags         int
+	logFormat        string
 	showHelp         bool
 	allowNewerConfig bool
+	jsonOutput       bool
 }
 
 func defaultRuntimeOptions() RuntimeOptions {
@@ -203,6 +229,7 @@ func defaultRuntimeOptions() RuntimeOptions {
 		cpuProfile:   os.Getenv("STCPUPROFILE") != "",
 		stRestarting: os.Getenv("STRESTART") != "",
 		logFlags:     log.Ltime,
+		logFormat:    logger.FormatText,
 	}
 
 	if os.Getenv("STTRACE") != "" {
@@ -228,11 +255,18 @@ func parseCommandLineOptions() RuntimeOptions {
 	flag.StringVar(&options.guiAPIKey, "gui-apikey", options.guiAPIKey, "Override GUI API key")
 	flag.StringVar(&options.confDir, "home", "", "Set configuration directory")
 	flag.IntVar(&options.logFlags, "logflags", options.logFlags, "Select information in log line prefix (see below)")
+	flag.StringVar(&options.logFormat, "logformat", options.logFormat, "Log format, \"text\" or \"json\" for a JSON object per line")
 	flag.BoolVar(&options.noBrowser, "no-browser", false, "Do not start browser")
 	flag.BoolVar(&options.browserOnly, "browser-only", false, "Open GUI in browser")
 	flag.BoolVar(&options.noRestart, "no-restart", options.noRestart, "Disable monitor process, managed restarts and log file writing")
 	flag.BoolVar(&options.resetDatabase, "reset-database", false, "Reset the database, forcing a full rescan and resync")
 	flag.BoolVar(&options.resetDeltaIdxs, "reset-deltas", false, "Reset delta index IDs, forcing a full index exchange")
+	flag.BoolVar(&options.dbVerify, "db-verify", false, "Check the database for consistency problems")
+	flag.BoolVar(&options.dbRepair, "db-repair", false, "Check the database and repair any consistency problems found")
+	flag.StringVar(&options.exportIndex, "export-index", "", "Export the index of the given folder to the file given by -index-file")
+	flag.StringVar(&options.importIndex, "import-index", "", "Import the index of the given folder from the file given by -index-file")
+	flag.StringVar(&options.indexFile, "index-file", "", "Index file to use with -export-index, -import-index or -generate-index")
+	flag.StringVar(&options.generateIndex, "generate-index", "", "Scan the given directory, without using the database, and write its index to the file given by -index-file")
 	flag.BoolVar(&options.doUpgrade, "upgrade", false, "Perform upgrade")
 	flag.BoolVar(&options.doUpgradeCheck, "upgrade-check", false, "Check for available upgrade")
 	flag.BoolVar(&options.showVersion, "version", false, "Show version")
@@ -241,12 +275,14 @@ func parseCommandLineOptions() RuntimeOptions {
 	flag.BoolVar(&options.showDeviceId, "device-id", false, "Show the device ID")
 	flag.StringVar(&options.upgradeTo, "upgrade-to", options.upgradeTo, "Force upgrade directly from specified URL")
 	flag.BoolVar(&options.auditEnabled, "audit", false, "Write events to audit file")
+	flag.BoolVar(&options.auditAdmin, "auditadmin", false, "Write only config changes, logins and changing API requests to audit file")
 	flag.BoolVar(&options.verbose, "verbose", false, "Print verbose log output")
 	flag.BoolVar(&options.paused, "paused", false, "Start with all devices and folders paused")
 	flag.BoolVar(&options.unpaused, "unpaused", false, "Start with all devices and folders unpaused")
 	flag.StringVar(&options.logFile, "logfile", options.logFile, "Log file name (still always logs to stdout). Cannot be used together with -no-restart/STNORESTART environment variable.")
 	flag.StringVar(&options.auditFile, "auditfile", options.auditFile, "Specify audit file (use \"-\" for stdout, \"--\" for stderr)")
 	flag.BoolVar(&options.allowNewerConfig, "allow-newer-config", false, "Allow loading newer than current config version")
+	flag.BoolVar(&options.jsonOutput, "json", false, "Print the result of commands that exit, like -device-id or -paths, as JSON")
 	if runtime.GOOS == "windows" {
 		// Allow user to hide the console window
 		flag.BoolVar(&options.hideConsole, "no-console", false, "Hide console window")
@@ -292,8 +328,18 @@ func (e *exiter) waitForExit() int {
 var exit = &exiter{make(chan int)}
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cli" {
+		cli.Run("syncthing cli", os.Args[1:])
+		return
+	}
+
 	options := parseCommandLineOptions()
 	l.SetFlags(options.logFlags)
+	if err := l.SetFormat(options.logFormat); err != nil {
+		l.Warnln("Log format:", err)
+		os.Exit(exitError)
+	}
+	out := newCLIOutput(options.jsonOutput)
 
 	if options.guiAddress != "" {
 		// The config picks this up from the environment.
@@ -346,7 +392,9 @@ func main() {
 	}
 
 	if options.showVersion {
-		fmt.Println(build.LongVersion)
+		out.result(currentVersion(), func(w io.Writer) {
+			fmt.Fprintln(w, build.LongVersion)
+		})
 		return
 	}
 
@@ -356,7 +404,7 @@ func main() {
 	}
 
 	if options.showPaths {
-		showPaths(options)
+		showPaths(options, out)
 		return
 	}
 
@@ -366,12 +414,13 @@ func main() {
 			locations.Get(locations.KeyFile),
 		)
 		if err != nil {
-			l.Warnln("Error reading device ID:", err)
-			os.Exit(exitError)
+			out.fail("Error reading device ID", err, exitError)
 		}
 
 		myID = protocol.NewDeviceID(cert.Certificate[0])
-		fmt.Println(myID)
+		out.result(deviceIDOutput{DeviceID: myID.String()}, func(w io.Writer) {
+			fmt.Fprintln(w, myID)
+		})
 		return
 	}
 
@@ -384,10 +433,11 @@ func main() {
 	}
 
 	if options.generateDir != "" {
-		if err := generate(options.generateDir); err != nil {
-			l.Warnln("Failed to generate config and keys:", err)
-			os.Exit(exitError)
+		res, err := generate(options.generateDir)
+		if err != nil {
+			out.fail("Failed to generate config and keys", err, exitError)
 		}
+		out.result(res, nil)
 		return
 	}
 
@@ -408,7 +458,20 @@ func main() {
 	}
 
 	if options.doUpgradeCheck {
-		checkUpgrade()
+		release, newer, err := latestRelease()
+		if err != nil {
+			out.fail("Upgrade", err, exitError)
+		}
+		out.result(upgradeCheckOutput{Running: build.Version, Latest: release.Tag, UpgradeAvailable: newer}, func(io.Writer) {
+			if newer {
+				l.Infof("Upgrade available (current %q < latest %q)", build.Version, release.Tag)
+			} else {
+				l.Infof("No upgrade available (current %q >= latest %q).", build.Version, release.Tag)
+			}
+		})
+		if !newer {
+			os.Exit(exitNoUpgradeAvailable)
+		}
 		return
 	}
 
@@ -420,12 +483,33 @@ func main() {
 
 	if options.resetDatabase {
 		if err := resetDB(); err != nil {
-			l.Warnln("Resetting database:", err)
+			out.fail("Resetting database", err, exitError)
+		}
+		out.result(resetOutput{Database: locations.Get(locations.Database)}, nil)
+		return
+	}
+
+	if options.dbVerify || options.dbRepair {
+		res, err := verifyDB(options.dbRepair)
+		if err != nil {
+			out.fail("Opening database", err, exitError)
+		}
+		out.result(res, res.print)
+		if !res.OK {
 			os.Exit(exitError)
 		}
 		return
 	}
 
+	if options.exportIndex != "" || options.importIndex != "" || options.generateIndex != "" {
+		res, err := exportImportIndex(options)
+		if err != nil {
+			out.fail("Index export/import", err, exitError)
+		}
+		out.result(res, res.print)
+		return
+	}
+
 	if innerProcess || options.noRestart {
 		syncthingMain(options)
 	} else {
@@ -448,17 +532,22 @@ func openGUI() error {
 	return nil
 }
 
-func generate(generateDir string) error {
+func generate(generateDir string) (generateOutput, error) {
 	dir, err := fs.ExpandTilde(generateDir)
 	if err != nil {
-		return err
+		return generateOutput{}, err
 	}
 
 	if err := ensureDir(dir, 0700); err != nil {
-		return err
+		return generateOutput{}, err
 	}
 
 	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	res := generateOutput{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ConfigFile: filepath.Join(dir, "config.xml"),
+	}
 	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err == nil {
 		l.Warnln("Key exists; will not overwrite.")
@@ -466,31 +555,28 @@ func generate(generateDir string) error {
 	} else {
 		cert, err = tlsutil.NewCertificate(certFile, keyFile, tlsDefaultCommonName)
 		if err != nil {
-			return errors.Wrap(err, "create certificate")
+			return res, errors.Wrap(err, "create certificate")
 		}
 		myID = protocol.NewDeviceID(cert.Certificate[0])
-		if err != nil {
-			return errors.Wrap(err, "load certificate")
-		}
-		if err == nil {
-			l.Infoln("Device ID:", protocol.NewDeviceID(cert.Certificate[0]))
-		}
+		l.Infoln("Device ID:", myID)
+		res.CertCreated = true
 	}
+	res.DeviceID = protocol.NewDeviceID(cert.Certificate[0]).String()
 
-	cfgFile := filepath.Join(dir, "config.xml")
-	if _, err := os.Stat(cfgFile); err == nil {
+	if _, err := os.Stat(res.ConfigFile); err == nil {
 		l.Warnln("Config exists; will not overwrite.")
-		return nil
+		return res, nil
 	}
-	cfg, err := defaultConfig(cfgFile)
+	cfg, err := defaultConfig(res.ConfigFile)
 	if err != nil {
-		return err
+		return res, err
 	}
 	err = cfg.Save()
 	if err != nil {
-		return errors.Wrap(err, "save config")
+		return res, errors.Wrap(err, "save config")
 	}
-	return nil
+	res.ConfigCreated = true
+	return res, nil
 }
 
 func debugFacilities() string {
@@ -515,16 +601,26 @@ func debugFacilities() string {
 	return b.String()
 }
 
-func checkUpgrade() upgrade.Release {
+// latestRelease returns the latest release, and whether it is newer than
+// the running version.
+func latestRelease() (upgrade.Release, bool, error) {
 	cfg, _ := loadOrDefaultConfig()
 	opts := cfg.Options()
 	release, err := upgrade.LatestRelease(opts.ReleasesURL, build.Version, opts.UpgradeToPreReleases)
+	if err != nil {
+		return upgrade.Release{}, false, err
+	}
+	return release, upgrade.CompareVersions(release.Tag, build.Version) > 0, nil
+}
+
+func checkUpgrade() upgrade.Release {
+	release, newer, err := latestRelease()
 	if err != nil {
 		l.Warnln("Upgrade:", err)
 		os.Exit(exitError)
 	}
 
-	if upgrade.CompareVersions(release.Tag, build.Version) <= 0 {
+	if !newer {
 		noUpgradeMessage := "No upgrade available (current %q >= latest %q)."
 		l.Infof(noUpgradeMessage, build.Version, release.Tag)
 		os.Exit(exitNoUpgradeAvailable)
@@ -609,8 +705,12 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	// lines look ugly.
 	l.SetPrefix("[start] ")
 
-	if runtimeOptions.auditEnabled {
-		startAuditing(mainService, runtimeOptions.auditFile)
+	if runtimeOptions.auditEnabled || runtimeOptions.auditAdmin {
+		mask := events.AllEvents
+		if runtimeOptions.auditAdmin {
+			mask = events.AuditEvents
+		}
+		startAuditing(mainService, runtimeOptions.auditFile, mask)
 	}
 
 	if runtimeOptions.verbose {
@@ -682,6 +782,23 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		os.Exit(exitError)
 	}
 
+	// Log to the system as well, now that we know where to.
+	setupLogSinks(cfg.Options())
+
+	// The event journal should also start early, but needs the config.
+	var journal *events.Journal
+	if size := cfg.Options().EventJournalMiB; size > 0 {
+		journal, err = events.NewJournal(events.Default, locations.Get(locations.EventJournal), api.DefaultEventMask, int64(size)<<20)
+		if err != nil {
+			l.Warnln("Event journal:", err)
+		} else {
+			mainService.Add(journal)
+		}
+	}
+
+	warnings := api.NewWarningCenter(locations.Get(locations.Warnings), events.Default, l)
+	mainService.Add(warnings)
+
 	if len(runtimeOptions.profiler) > 0 {
 		go func() {
 			l.Debugln("Starting profiler on", runtimeOptions.profiler)
@@ -817,9 +934,25 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 		}
 	}
 
+	for _, domain := range cfg.Options().DNSDiscoveryDomains {
+		l.Infoln("Using DNS discovery in", domain)
+		// Same caching as for the global discovery servers.
+		cachedDiscovery.Add(discover.NewDNS(domain), 5*time.Minute, time.Minute)
+	}
+
+	if cfg.Options().DHTEnabled {
+		l.Infoln("Using DHT discovery on", cfg.Options().DHTListenAddr)
+		dht, err := discover.NewDHT(cert, cfg.Options().DHTListenAddr, cfg.Options().DHTBootstrapNodes, connectionsService)
+		if err != nil {
+			l.Warnln("DHT discovery:", err)
+		} else {
+			cachedDiscovery.Add(dht, 5*time.Minute, time.Minute)
+		}
+	}
+
 	if cfg.Options().LocalAnnEnabled {
 		// v4 broadcasts
-		bcd, err := discover.NewLocal(myID, fmt.Sprintf(":%d", cfg.Options().LocalAnnPort), connectionsService)
+		bcd, err := discover.NewLocalUnicast(myID, fmt.Sprintf(":%d", cfg.Options().LocalAnnPort), connectionsService, cfg.Options().LocalAnnUnicast)
 		if err != nil {
 			l.Warnln("IPv4 local discovery:", err)
 		} else {
@@ -868,9 +1001,21 @@ func syncthingMain(runtimeOptions RuntimeOptions) {
 	usageReportingSvc := ur.New(cfg, m, connectionsService, noUpgradeFromEnv)
 	mainService.Add(usageReportingSvc)
 
+	// Webhooks
+
+	mainService.Add(webhook.New(cfg))
+
+	// MQTT
+
+	mainService.Add(mqtt.New(cfg, myID))
+
+	// Tracing
+
+	mainService.Add(tracing.New(cfg))
+
 	// GUI
 
-	setupGUI(mainService, cfg, m, defaultSub, diskSub, cachedDiscovery, connectionsService, usageReportingSvc, errors, systemLog, runtimeOptions)
+	setupGUI(mainService, cfg, m, defaultSub, diskSub, journal, warnings, cachedDiscovery, connectionsService, usageReportingSvc, errors, systemLog, runtimeOptions)
 
 	myDev, _ := cfg.Device(myID)
 	l.Infof(`My name is "%v"`, myDev.Name)
@@ -1033,7 +1178,26 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
-func startAuditing(mainService *suture.Supervisor, auditFile string) {
+// setupLogSinks sends the log, from INFO up, to syslog and the Windows Event
+// Log, as set in the options. Failing to doesn't keep us from starting.
+func setupLogSinks(opts config.OptionsConfiguration) {
+	if opts.SyslogURL != "" {
+		if err := logger.AddSyslogSink(logger.DefaultLogger, opts.SyslogURL, logger.LevelInfo); err != nil {
+			l.Warnln("Syslog:", err)
+		} else {
+			l.Infoln("Logging to syslog at", opts.SyslogURL)
+		}
+	}
+	if opts.WindowsEventLog {
+		if err := logger.AddEventLogSink(logger.DefaultLogger, logger.LevelInfo); err != nil {
+			l.Warnln("Windows Event Log:", err)
+		} else {
+			l.Infoln("Logging to the Windows Event Log")
+		}
+	}
+}
+
+func startAuditing(mainService *suture.Supervisor, auditFile string, mask events.EventType) {
 
 	var fd io.Writer
 	var err error
@@ -1061,7 +1225,7 @@ func startAuditing(mainService *suture.Supervisor, auditFile string) {
 		auditDest = auditFile
 	}
 
-	auditService := newAuditService(fd)
+	auditService := newAuditService(fd, mask)
 	mainService.Add(auditService)
 
 	// We wait for the audit service to fully start before we return, to
@@ -1071,7 +1235,7 @@ func startAuditing(mainService *suture.Supervisor, auditFile string) {
 	l.Infoln("Audit log in", auditDest)
 }
 
-func setupGUI(mainService *suture.Supervisor, cfg config.Wrapper, m model.Model, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, errors, systemLog logger.Recorder, runtimeOptions RuntimeOptions) {
+func setupGUI(mainService *suture.Supervisor, cfg config.Wrapper, m model.Model, defaultSub, diskSub events.BufferedSubscription, journal *events.Journal, warnings *api.WarningCenter, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, errors, systemLog logger.Recorder, runtimeOptions RuntimeOptions) {
 	guiCfg := cfg.GUI()
 
 	if !guiCfg.Enabled {
@@ -1088,7 +1252,7 @@ func setupGUI(mainService *suture.Supervisor, cfg config.Wrapper, m model.Model,
 	summaryService := model.NewFolderSummaryService(cfg, m, myID)
 	mainService.Add(summaryService)
 
-	apiSvc := api.New(myID, cfg, runtimeOptions.assetDir, tlsDefaultCommonName, m, defaultSub, diskSub, discoverer, connectionsService, urService, summaryService, errors, systemLog, cpu, exit, noUpgradeFromEnv)
+	apiSvc := api.New(myID, cfg, runtimeOptions.assetDir, tlsDefaultCommonName, m, defaultSub, diskSub, journal, warnings, discoverer, connectionsService, urService, summaryService, errors, systemLog, cpu, exit, noUpgradeFromEnv)
 	mainService.Add(apiSvc)
 
 	if err := apiSvc.WaitForStart(); err != nil {
@@ -1123,6 +1287,163 @@ func resetDB() error {
 	return os.RemoveAll(locations.Get(locations.Database))
 }
 
+// verifyDB checks all folders in the database for consistency problems,
+// optionally repairing them. The result is OK when the database is (or has
+// been made) consistent.
+func verifyDB(repair bool) (dbVerifyOutput, error) {
+	ldb, err := db.Open(locations.Get(locations.Database))
+	if err != nil {
+		return dbVerifyOutput{}, err
+	}
+	defer ldb.Close()
+
+	res := dbVerifyOutput{
+		OK:      true,
+		Folders: make(map[string]folderVerify),
+	}
+	for _, folder := range ldb.ListFolders() {
+		fv := folderVerify{Problems: []string{}, Remaining: []string{}}
+		for _, p := range db.Verify(ldb, folder) {
+			fv.Problems = append(fv.Problems, p.String())
+		}
+		if len(fv.Problems) > 0 {
+			if repair {
+				db.Repair(ldb, folder)
+				for _, p := range db.Verify(ldb, folder) {
+					fv.Remaining = append(fv.Remaining, p.String())
+				}
+				fv.Repaired = len(fv.Problems) - len(fv.Remaining)
+				if len(fv.Remaining) > 0 {
+					res.OK = false
+				}
+			} else {
+				res.OK = false
+			}
+		}
+		res.Folders[folder] = fv
+	}
+	return res, nil
+}
+
+func (r dbVerifyOutput) print(w io.Writer) {
+	folders := make([]string, 0, len(r.Folders))
+	for folder := range r.Folders {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	for _, folder := range folders {
+		fv := r.Folders[folder]
+		for _, p := range fv.Problems {
+			fmt.Fprintln(w, p)
+		}
+		switch {
+		case len(fv.Problems) == 0:
+			fmt.Fprintf(w, "Folder %q: no problems found\n", folder)
+		case len(fv.Remaining) > 0:
+			for _, p := range fv.Remaining {
+				fmt.Fprintln(w, "After repair:", p)
+			}
+		case fv.Repaired > 0:
+			fmt.Fprintf(w, "Folder %q: %d problems repaired\n", folder, fv.Repaired)
+		}
+	}
+}
+
+// exportImportIndex exports, imports or generates the index of a single
+// folder, according to the given options.
+func exportImportIndex(options RuntimeOptions) (indexOutput, error) {
+	if options.exportIndex != "" && options.importIndex != "" {
+		return indexOutput{}, errors.New("cannot both export and import")
+	}
+	if options.generateIndex != "" && (options.exportIndex != "" || options.importIndex != "") {
+		return indexOutput{}, errors.New("cannot generate together with export or import")
+	}
+	if options.indexFile == "" {
+		return indexOutput{}, errors.New("no index file given")
+	}
+	res := indexOutput{IndexFile: options.indexFile}
+
+	if options.generateIndex != "" {
+		res.Operation = "generate"
+		res.Folder = options.generateIndex
+		fcfg := config.NewFolderConfiguration(protocol.EmptyDeviceID, "", "", fs.FilesystemTypeBasic, options.generateIndex)
+		fd, err := os.Create(options.indexFile)
+		if err != nil {
+			return res, err
+		}
+		res.Files, err = model.GenerateIndex(context.Background(), fcfg, fd)
+		if err != nil {
+			fd.Close()
+			return res, err
+		}
+		return res, fd.Close()
+	}
+	res.Folder = options.exportIndex
+	res.Operation = "export"
+	if res.Folder == "" {
+		res.Folder = options.importIndex
+		res.Operation = "import"
+	}
+
+	cfg, err := loadOrDefaultConfig()
+	if err != nil {
+		return res, err
+	}
+	fcfg, ok := cfg.Folder(res.Folder)
+	if !ok {
+		return res, fmt.Errorf("no such folder %q", res.Folder)
+	}
+
+	ldb, err := db.Open(locations.Get(locations.Database))
+	if err != nil {
+		return res, err
+	}
+	defer ldb.Close()
+	fset := db.NewFileSet(res.Folder, fcfg.Filesystem(), ldb)
+
+	if options.exportIndex != "" {
+		fd, err := os.Create(options.indexFile)
+		if err != nil {
+			return res, err
+		}
+		res.Files, err = model.ExportIndex(fset, fd)
+		if err != nil {
+			fd.Close()
+			return res, err
+		}
+		return res, fd.Close()
+	}
+
+	cert, err := tls.LoadX509KeyPair(
+		locations.Get(locations.CertFile),
+		locations.Get(locations.KeyFile),
+	)
+	if err != nil {
+		return res, errors.Wrap(err, "reading device ID")
+	}
+	myID = protocol.NewDeviceID(cert.Certificate[0])
+
+	fd, err := os.Open(options.indexFile)
+	if err != nil {
+		return res, err
+	}
+	defer fd.Close()
+	res.Files, err = model.ImportIndex(fset, fcfg, myID.Short(), fd)
+	return res, err
+}
+
+func (r indexOutput) print(w io.Writer) {
+	switch r.Operation {
+	case "generate":
+		fmt.Fprintf(w, "Wrote the index of %d files in %s\n", r.Files, r.Folder)
+	case "export":
+		fmt.Fprintf(w, "Exported the index of %d files of folder %s\n", r.Files, r.Folder)
+	case "import":
+		fmt.Fprintf(w, "Imported %d files into the index of folder %s\n", r.Files, r.Folder)
+	}
+}
+
 func ensureDir(dir string, mode fs.FileMode) error {
 	fs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
 	err := fs.MkdirAll(".", mode)
@@ -1280,14 +1601,27 @@ func checkShortIDs(cfg config.Wrapper) error {
 	return nil
 }
 
-func showPaths(options RuntimeOptions) {
-	fmt.Printf("Configuration file:\n\t%s\n\n", locations.Get(locations.ConfigFile))
-	fmt.Printf("Database directory:\n\t%s\n\n", locations.Get(locations.Database))
-	fmt.Printf("Device private key & certificate files:\n\t%s\n\t%s\n\n", locations.Get(locations.KeyFile), locations.Get(locations.CertFile))
-	fmt.Printf("HTTPS private key & certificate files:\n\t%s\n\t%s\n\n", locations.Get(locations.HTTPSKeyFile), locations.Get(locations.HTTPSCertFile))
-	fmt.Printf("Log file:\n\t%s\n\n", options.logFile)
-	fmt.Printf("GUI override directory:\n\t%s\n\n", options.assetDir)
-	fmt.Printf("Default sync folder directory:\n\t%s\n\n", locations.Get(locations.DefFolder))
+func showPaths(options RuntimeOptions, out cliOutput) {
+	paths := pathsOutput{
+		ConfigFile:    locations.Get(locations.ConfigFile),
+		Database:      locations.Get(locations.Database),
+		KeyFile:       locations.Get(locations.KeyFile),
+		CertFile:      locations.Get(locations.CertFile),
+		HTTPSKeyFile:  locations.Get(locations.HTTPSKeyFile),
+		HTTPSCertFile: locations.Get(locations.HTTPSCertFile),
+		LogFile:       options.logFile,
+		GUIAssets:     options.assetDir,
+		DefaultFolder: locations.Get(locations.DefFolder),
+	}
+	out.result(paths, func(w io.Writer) {
+		fmt.Fprintf(w, "Configuration file:\n\t%s\n\n", paths.ConfigFile)
+		fmt.Fprintf(w, "Database directory:\n\t%s\n\n", paths.Database)
+		fmt.Fprintf(w, "Device private key & certificate files:\n\t%s\n\t%s\n\n", paths.KeyFile, paths.CertFile)
+		fmt.Fprintf(w, "HTTPS private key & certificate files:\n\t%s\n\t%s\n\n", paths.HTTPSKeyFile, paths.HTTPSCertFile)
+		fmt.Fprintf(w, "Log file:\n\t%s\n\n", paths.LogFile)
+		fmt.Fprintf(w, "GUI override directory:\n\t%s\n\n", paths.GUIAssets)
+		fmt.Fprintf(w, "Default sync folder directory:\n\t%s\n\n", paths.DefaultFolder)
+	})
 }
 
 func setPauseState(cfg config.Wrapper, paused bool) {
@@ -7,6 +7,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -36,3 +41,39 @@ func TestShortIDCheck(t *testing.T) {
 		t.Error("Should have gotten an error")
 	}
 }
+
+func TestGenerateOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-generate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first, err := generate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.DeviceID == "" || !first.CertCreated || !first.ConfigCreated {
+		t.Errorf("Unexpected result %+v", first)
+	}
+
+	// Nothing is overwritten the second time
+	second, err := generate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.DeviceID != first.DeviceID || second.CertCreated || second.ConfigCreated {
+		t.Errorf("Unexpected result %+v", second)
+	}
+
+	buf := new(bytes.Buffer)
+	out := cliOutput{json: true, w: buf}
+	out.result(second, func(w io.Writer) { t.Error("Human output in JSON mode") })
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["deviceID"] != first.DeviceID || decoded["configCreated"] != false {
+		t.Errorf("Unexpected JSON %s", buf.Bytes())
+	}
+}
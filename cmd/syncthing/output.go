@@ -0,0 +1,151 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/syncthing/syncthing/lib/build"
+)
+
+// cliOutput prints the result of a command that runs and exits instead of
+// starting Syncthing. With -json the result is a single JSON object on
+// standard output, and log output goes to standard error, so that scripts
+// don't need to parse human readable text.
+type cliOutput struct {
+	json bool
+	w    io.Writer
+}
+
+// cliError is printed instead of the result when a command fails.
+type cliError struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exitCode"`
+}
+
+type versionOutput struct {
+	Version     string   `json:"version"`
+	Codename    string   `json:"codename"`
+	LongVersion string   `json:"longVersion"`
+	OS          string   `json:"os"`
+	Arch        string   `json:"arch"`
+	IsBeta      bool     `json:"isBeta"`
+	IsCandidate bool     `json:"isCandidate"`
+	IsRelease   bool     `json:"isRelease"`
+	Tags        []string `json:"tags"`
+}
+
+type pathsOutput struct {
+	ConfigFile    string `json:"configFile"`
+	Database      string `json:"database"`
+	KeyFile       string `json:"keyFile"`
+	CertFile      string `json:"certFile"`
+	HTTPSKeyFile  string `json:"httpsKeyFile"`
+	HTTPSCertFile string `json:"httpsCertFile"`
+	LogFile       string `json:"logFile"`
+	GUIAssets     string `json:"guiAssets"`
+	DefaultFolder string `json:"defaultFolder"`
+}
+
+type deviceIDOutput struct {
+	DeviceID string `json:"deviceID"`
+}
+
+type generateOutput struct {
+	DeviceID      string `json:"deviceID"`
+	CertFile      string `json:"certFile"`
+	KeyFile       string `json:"keyFile"`
+	ConfigFile    string `json:"configFile"`
+	CertCreated   bool   `json:"certCreated"`
+	ConfigCreated bool   `json:"configCreated"`
+}
+
+type upgradeCheckOutput struct {
+	Running          string `json:"running"`
+	Latest           string `json:"latest"`
+	UpgradeAvailable bool   `json:"upgradeAvailable"`
+}
+
+type resetOutput struct {
+	Database string `json:"database"`
+}
+
+type dbVerifyOutput struct {
+	OK      bool                    `json:"ok"`
+	Folders map[string]folderVerify `json:"folders"`
+}
+
+type folderVerify struct {
+	Problems []string `json:"problems"`
+	// Repaired is the number of problems repaired, and Remaining those
+	// still found after the repair.
+	Repaired  int      `json:"repaired"`
+	Remaining []string `json:"remaining"`
+}
+
+type indexOutput struct {
+	Operation string `json:"operation"` // "export", "import" or "generate"
+	Folder    string `json:"folder"`
+	IndexFile string `json:"indexFile"`
+	Files     int    `json:"files"`
+}
+
+func newCLIOutput(asJSON bool) cliOutput {
+	if asJSON {
+		l.SetOutput(os.Stderr)
+	}
+	return cliOutput{json: asJSON, w: os.Stdout}
+}
+
+// result prints v as JSON, or calls human, if not nil, to print it as
+// text.
+func (o cliOutput) result(v interface{}, human func(w io.Writer)) {
+	if !o.json {
+		if human != nil {
+			human(o.w)
+		}
+		return
+	}
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Encoding result:", err)
+		os.Exit(exitError)
+	}
+}
+
+// fail reports the error and exits with the given code.
+func (o cliOutput) fail(what string, err error, code int) {
+	if o.json {
+		o.result(cliError{Error: fmt.Sprintf("%s: %v", what, err), ExitCode: code}, nil)
+	} else {
+		l.Warnln(what+":", err)
+	}
+	os.Exit(code)
+}
+
+func currentVersion() versionOutput {
+	tags := build.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return versionOutput{
+		Version:     build.Version,
+		Codename:    build.Codename,
+		LongVersion: build.LongVersion,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		IsBeta:      build.IsBeta,
+		IsCandidate: build.IsCandidate,
+		IsRelease:   build.IsRelease,
+		Tags:        tags,
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"os"
+
+	"github.com/syncthing/syncthing/lib/tracing"
+)
+
+func init() {
+	if innerProcess && os.Getenv("STTRACING") != "" {
+		setupTracing(os.Getenv("STTRACING"))
+	}
+}
+
+func setupTracing(dest string) {
+	if dest != "log" {
+		l.Warnln("Starting tracing: unsupported STTRACING, use the tracingEndpoint option to export spans")
+		return
+	}
+	l.Debugln("Starting tracing to the log")
+	tracing.SetExporter(tracing.NewLogExporter())
+}
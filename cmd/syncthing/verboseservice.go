@@ -9,7 +9,10 @@ package main
 import (
 	"fmt"
 
+	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/scanner"
 )
 
 // The verbose logging service subscribes to events and prints these in
@@ -126,6 +129,10 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 		}
 		return fmt.Sprintf("Finished syncing %q / %q (%v %v): Success", data["folder"], data["item"], data["action"], data["type"])
 
+	case events.ItemMigrated:
+		data := ev.Data.(map[string]string)
+		return fmt.Sprintf("Migrated %q / %q from %q / %q without transfer", data["folder"], data["item"], data["fromFolder"], data["fromItem"])
+
 	case events.ConfigSaved:
 		return "Configuration was saved"
 
@@ -150,11 +157,14 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 		current := data["current"].(int64)
 		total := data["total"].(int64)
 		rate := data["rate"].(float64) / 1024 / 1024
+		if data["phase"] == scanner.ScanPhaseWalking {
+			return fmt.Sprintf("Scanning folder %q, walked %d items, at %q", folder, data["filesWalked"], data["currentPath"])
+		}
 		var pct int64
 		if total > 0 {
 			pct = 100 * current / total
 		}
-		return fmt.Sprintf("Scanning folder %q, %d%% done (%.01f MiB/s)", folder, pct, rate)
+		return fmt.Sprintf("Scanning folder %q, %d%% done (%.01f MiB/s), hashed %d of %d files, at %q", folder, pct, rate, data["filesHashed"], data["filesTotal"], data["currentPath"])
 
 	case events.DevicePaused:
 		data := ev.Data.(map[string]string)
@@ -185,6 +195,14 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 		wan := data["wan"]
 		return fmt.Sprintf("Listen address %s resolution has changed: lan addresses: %s wan addresses: %s", address, lan, wan)
 
+	case events.InterfaceAddressesChanged:
+		data := ev.Data.(map[string][]string)
+		return fmt.Sprintf("Network interface addresses have changed: added %v, removed %v", data["added"], data["removed"])
+
+	case events.FolderDecommissioned:
+		report := ev.Data.(model.DecommissionReport)
+		return fmt.Sprintf("Folder %v (%v) was decommissioned, in sync on %d devices", report.Folder, report.Label, len(report.InSync))
+
 	case events.LoginAttempt:
 		data := ev.Data.(map[string]interface{})
 		username := data["username"].(string)
@@ -195,6 +213,18 @@ func (s *verboseService) formatEvent(ev events.Event) string {
 			success = "failed"
 		}
 		return fmt.Sprintf("Login %s for username %s.", success, username)
+
+	case events.ConfigChanged:
+		data := ev.Data.(map[string]interface{})
+		return fmt.Sprintf("Configuration changed to version %v: %d changes", data["version"], len(data["changes"].([]config.ConfigChange)))
+
+	case events.APIRequest:
+		data := ev.Data.(map[string]interface{})
+		return fmt.Sprintf("API request %v %v from %v: status %v", data["method"], data["path"], data["remoteAddress"], data["status"])
+
+	case events.FolderQuotaExceeded:
+		data := ev.Data.(map[string]interface{})
+		return fmt.Sprintf("Folder %v stopped pulling at its quota of %v bytes, needing %v bytes", data["folder"], data["quota"], data["needed"])
 	}
 
 	return fmt.Sprintf("%s %#v", ev.Type, ev)
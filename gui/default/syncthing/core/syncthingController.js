@@ -69,6 +69,7 @@ angular.module('syncthing.core')
             maxConflicts: 10,
             fsync: true,
             order: "random",
+            conflictPolicy: "copy",
             fileVersioningSelector: "none",
             trashcanClean: 0,
             simpleKeep: 5,
@@ -334,6 +335,10 @@ angular.module('syncthing.core')
 
         $scope.$on(Events.FOLDER_SCAN_PROGRESS, function (event, arg) {
             var data = arg.data;
+            if (data.phase === 'walking') {
+                // Nothing is being hashed yet, so there's no percentage.
+                return;
+            }
             $scope.scanProgress[data.folder] = {
                 current: data.current,
                 total: data.total,
@@ -748,6 +753,9 @@ angular.module('syncthing.core')
             if (state === 'error') {
                 return 'stopped'; // legacy, the state is called "stopped" in the GUI
             }
+            if (state === 'overlap') {
+                return state;
+            }
             if (state === 'idle' && $scope.model[folderCfg.id].needTotalItems > 0) {
                 return 'outofsync';
             }
@@ -780,7 +788,7 @@ angular.module('syncthing.core')
             if (status === 'unknown') {
                 return 'info';
             }
-            if (status === 'stopped' || status === 'outofsync' || status === 'error' || status === 'faileditems') {
+            if (status === 'stopped' || status === 'overlap' || status === 'outofsync' || status === 'error' || status === 'faileditems') {
                 return 'danger';
             }
             if (status === 'unshared' || status === 'scan-waiting') {
@@ -953,6 +961,7 @@ angular.module('syncthing.core')
                         syncCount++;
                         break;
                     case 'stopped':
+                    case 'overlap':
                     case 'unknown':
                     case 'outofsync':
                     case 'error':
@@ -1670,6 +1679,9 @@ angular.module('syncthing.core')
                 $scope.currentFolder.simpleFileVersioning = true;
                 $scope.currentFolder.fileVersioningSelector = "simple";
                 $scope.currentFolder.simpleKeep = +$scope.currentFolder.versioning.params.keep;
+            } else if ($scope.currentFolder.versioning && $scope.currentFolder.versioning.type === "dedup") {
+                $scope.currentFolder.fileVersioningSelector = "dedup";
+                $scope.currentFolder.simpleKeep = +$scope.currentFolder.versioning.params.keep;
             } else if ($scope.currentFolder.versioning && $scope.currentFolder.versioning.type === "staggered") {
                 $scope.currentFolder.staggeredFileVersioning = true;
                 $scope.currentFolder.fileVersioningSelector = "staggered";
@@ -1791,6 +1803,14 @@ angular.module('syncthing.core')
                 };
                 delete folderCfg.simpleFileVersioning;
                 delete folderCfg.simpleKeep;
+            } else if (folderCfg.fileVersioningSelector === "dedup") {
+                folderCfg.versioning = {
+                    'Type': 'dedup',
+                    'Params': {
+                        'keep': '' + folderCfg.simpleKeep
+                    }
+                };
+                delete folderCfg.simpleKeep;
             } else if (folderCfg.fileVersioningSelector === "staggered") {
                 folderCfg.versioning = {
                     'type': 'staggered',
@@ -10,9 +10,11 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
@@ -35,6 +37,7 @@ import (
 	"github.com/syncthing/syncthing/lib/discover"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/locations"
 	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/model"
@@ -57,6 +60,9 @@ const (
 	DiskEventMask       = events.LocalChangeDetected | events.RemoteChangeDetected
 	EventSubBufferSize  = 1000
 	defaultEventTimeout = time.Minute
+
+	// Filtered event subscriptions not polled for this long are dropped.
+	filteredEventSubIdle = 10 * time.Minute
 )
 
 type service struct {
@@ -65,10 +71,15 @@ type service struct {
 	statics              *staticsServer
 	model                model.Model
 	eventSubs            map[events.EventType]events.BufferedSubscription
-	eventSubsMut         sync.Mutex
+	filteredEventSubs    map[string]*filteredEventSub
+	eventSubsMut         sync.Mutex // protects eventSubs and filteredEventSubs
+	journal              *events.Journal
+	warnings             *WarningCenter
+	totp                 *totpAuthenticator
 	discoverer           discover.CachingMux
 	connectionsService   connections.Service
 	fss                  model.FolderSummaryService
+	readViews            *readViews
 	urService            *ur.Service
 	systemConfigMut      sync.Mutex // serializes posts to /rest/system/config
 	cpu                  Rater
@@ -81,6 +92,9 @@ type service struct {
 	startedOnce          chan struct{} // the service has started successfully at least once
 	startupErr           error
 
+	readyzMut    sync.Mutex
+	readyzErrors map[string]string // the last error of each readiness check
+
 	guiErrors logger.Recorder
 	systemLog logger.Recorder
 }
@@ -101,7 +115,7 @@ type Service interface {
 	WaitForStart() error
 }
 
-func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonName string, m model.Model, defaultSub, diskSub events.BufferedSubscription, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, fss model.FolderSummaryService, errors, systemLog logger.Recorder, cpu Rater, contr Controller, noUpgrade bool) Service {
+func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonName string, m model.Model, defaultSub, diskSub events.BufferedSubscription, journal *events.Journal, warnings *WarningCenter, discoverer discover.CachingMux, connectionsService connections.Service, urService *ur.Service, fss model.FolderSummaryService, errors, systemLog logger.Recorder, cpu Rater, contr Controller, noUpgrade bool) Service {
 	return &service{
 		id:      id,
 		cfg:     cfg,
@@ -111,12 +125,19 @@ func New(id protocol.DeviceID, cfg config.Wrapper, assetDir, tlsDefaultCommonNam
 			DefaultEventMask: defaultSub,
 			DiskEventMask:    diskSub,
 		},
+		filteredEventSubs:    make(map[string]*filteredEventSub),
 		eventSubsMut:         sync.NewMutex(),
+		journal:              journal,
+		warnings:             warnings,
+		totp:                 newTOTPAuthenticator(cfg),
 		discoverer:           discoverer,
 		connectionsService:   connectionsService,
 		fss:                  fss,
+		readViews:            newReadViews(),
 		urService:            urService,
 		systemConfigMut:      sync.NewMutex(),
+		readyzMut:            sync.NewMutex(),
+		readyzErrors:         make(map[string]string),
 		guiErrors:            errors,
 		systemLog:            systemLog,
 		cpu:                  cpu,
@@ -157,6 +178,10 @@ func (s *service) getListener(guiCfg config.GUIConfiguration) (net.Listener, err
 	}
 	tlsCfg := tlsutil.SecureDefault()
 	tlsCfg.Certificates = []tls.Certificate{cert}
+	if err := setupClientCertAuth(tlsCfg, guiCfg); err != nil {
+		// Don't lock out users logging in some other way.
+		l.Warnln("Client certificate authentication disabled:", err)
+	}
 
 	if guiCfg.Network() == "unix" {
 		// When listening on a UNIX socket we should unlink before bind,
@@ -222,58 +247,119 @@ func (s *service) Serve() {
 
 	// The GET handlers
 	getRestMux := http.NewServeMux()
-	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)              // device folder
+	getRestMux.HandleFunc("/rest/db/completion", s.getDBCompletion)              // device folder [view]
 	getRestMux.HandleFunc("/rest/db/file", s.getDBFile)                          // folder file
+	getRestMux.HandleFunc("/rest/db/file/versions", s.getDBFileVersions)         // folder file
+	getRestMux.HandleFunc("/rest/db/file/status", s.getDBFileStatus)             // folder file
 	getRestMux.HandleFunc("/rest/db/ignores", s.getDBIgnores)                    // folder
-	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                          // folder [perpage] [page]
+	getRestMux.HandleFunc("/rest/db/need", s.getDBNeed)                          // folder [perpage] [page] [view]
 	getRestMux.HandleFunc("/rest/db/remoteneed", s.getDBRemoteNeed)              // device folder [perpage] [page]
+	getRestMux.HandleFunc("/rest/db/remotestatus", s.getDBRemoteStatus)          // device
 	getRestMux.HandleFunc("/rest/db/localchanged", s.getDBLocalChanged)          // folder
-	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                      // folder
-	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                      // folder [prefix] [dirsonly] [levels]
+	getRestMux.HandleFunc("/rest/db/status", s.getDBStatus)                      // folder [view]
+	getRestMux.HandleFunc("/rest/db/browse", s.getDBBrowse)                      // folder [prefix] [dirsonly] [levels] [sort] [desc] [perpage] [page]
+	getRestMux.HandleFunc("/rest/db/snapshots", s.getDBSnapshots)                // folder
+	getRestMux.HandleFunc("/rest/db/snapshotdiff", s.getDBSnapshotDiff)          // folder from [to]
+	getRestMux.HandleFunc("/rest/db/export", s.getDBExport)                      // folder
 	getRestMux.HandleFunc("/rest/folder/versions", s.getFolderVersions)          // folder
+	getRestMux.HandleFunc("/rest/folder/versions/file", s.getFolderVersionFile)  // folder file [time]
+	getRestMux.HandleFunc("/rest/folder/trash", s.getFolderTrash)                // folder [since] [search]
+	getRestMux.HandleFunc("/rest/folder/attention", s.getFolderAttention)        // folder
+	getRestMux.HandleFunc("/rest/folder/heatmap", s.getFolderHeatmap)            // folder [levels] [limit]
+	getRestMux.HandleFunc("/rest/folder/templates", s.getFolderTemplates)        // -
+	getRestMux.HandleFunc("/rest/folder/templates/new", s.getFolderTemplateNew)  // template id [label] path
+	getRestMux.HandleFunc("/rest/folder/ignores/test", s.getFolderIgnoresTest)   // folder file...
 	getRestMux.HandleFunc("/rest/folder/errors", s.getFolderErrors)              // folder
+	getRestMux.HandleFunc("/rest/folder/jobs", s.getFolderJobs)                  // [folder]
+	getRestMux.HandleFunc("/rest/folder/partials", s.getFolderPartials)          // folder
+	getRestMux.HandleFunc("/rest/folder/scanprogress", s.getFolderScanProgress)  // [folder]
 	getRestMux.HandleFunc("/rest/folder/pullerrors", s.getFolderErrors)          // folder (deprecated)
-	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                      // [since] [limit] [timeout] [events]
+	getRestMux.HandleFunc("/rest/events", s.getIndexEvents)                      // [since] [limit] [timeout] [events] [folder] [device]
 	getRestMux.HandleFunc("/rest/events/disk", s.getDiskEvents)                  // [since] [limit] [timeout]
+	getRestMux.HandleFunc("/rest/events/journal", s.getJournalEvents)            // [since] [limit]
 	getRestMux.HandleFunc("/rest/stats/device", s.getDeviceStats)                // -
 	getRestMux.HandleFunc("/rest/stats/folder", s.getFolderStats)                // -
 	getRestMux.HandleFunc("/rest/svc/deviceid", s.getDeviceID)                   // id
 	getRestMux.HandleFunc("/rest/svc/lang", s.getLang)                           // -
 	getRestMux.HandleFunc("/rest/svc/report", s.getReport)                       // -
 	getRestMux.HandleFunc("/rest/svc/random/string", s.getRandomString)          // [length]
+	getRestMux.HandleFunc("/rest/svc/version/compare", s.getVersionCompare)      // a b
 	getRestMux.HandleFunc("/rest/system/browse", s.getSystemBrowse)              // current
+	getRestMux.HandleFunc("/rest/system/preflight", s.getSystemPreflight)        // path [filesystem]
 	getRestMux.HandleFunc("/rest/system/config", s.getSystemConfig)              // -
 	getRestMux.HandleFunc("/rest/system/config/insync", s.getSystemConfigInsync) // -
+	getRestMux.HandleFunc("/rest/system/config/history", s.getConfigHistory)     // -
+	getRestMux.HandleFunc("/rest/pending/devices", s.getPendingDevices)          // -
+	getRestMux.HandleFunc("/rest/pending/folders", s.getPendingFolders)          // [device]
+	getRestMux.HandleFunc("/rest/device/groups", s.getDeviceGroups)              // -
 	getRestMux.HandleFunc("/rest/system/connections", s.getSystemConnections)    // -
+	getRestMux.HandleFunc("/rest/system/connections/history", s.getConnHistory)  // device
+	getRestMux.HandleFunc("/rest/system/attempts", s.getSystemAttempts)          // -
 	getRestMux.HandleFunc("/rest/system/discovery", s.getSystemDiscovery)        // -
 	getRestMux.HandleFunc("/rest/system/error", s.getSystemError)                // -
+	getRestMux.HandleFunc("/rest/system/warnings", s.getWarnings)                // [all]
+	getRestMux.HandleFunc("/rest/system/totp", s.getTOTP)                        // -
 	getRestMux.HandleFunc("/rest/system/ping", s.restPing)                       // -
 	getRestMux.HandleFunc("/rest/system/status", s.getSystemStatus)              // -
 	getRestMux.HandleFunc("/rest/system/upgrade", s.getSystemUpgrade)            // -
 	getRestMux.HandleFunc("/rest/system/version", s.getSystemVersion)            // -
 	getRestMux.HandleFunc("/rest/system/debug", s.getSystemDebug)                // -
 	getRestMux.HandleFunc("/rest/system/log", s.getSystemLog)                    // [since]
+	getRestMux.HandleFunc("/rest/system/nat", s.getSystemNAT)                    // -
 	getRestMux.HandleFunc("/rest/system/log.txt", s.getSystemLogTxt)             // [since]
+	getRestMux.HandleFunc("/rest/system/trace", s.getSystemTrace)                // device
 
 	// The POST handlers
 	postRestMux := http.NewServeMux()
-	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                          // folder file [perpage] [page]
-	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                    // folder
-	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                  // folder
-	postRestMux.HandleFunc("/rest/db/revert", s.postDBRevert)                      // folder
-	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
-	postRestMux.HandleFunc("/rest/folder/versions", s.postFolderVersionsRestore)   // folder <body>
-	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)              // <body>
-	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                // <body>
-	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)     // -
-	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                        // -
-	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                // [folder]
-	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)            // -
-	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)          // -
-	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)            // -
-	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))   // [device]
-	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false)) // [device]
-	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                // [enable] [disable]
+	postRestMux.HandleFunc("/rest/db/prio", s.postDBPrio)                               // folder file [perpage] [page]
+	postRestMux.HandleFunc("/rest/db/ignores", s.postDBIgnores)                         // folder
+	postRestMux.HandleFunc("/rest/db/ignores/import", s.postDBIgnoresImport)            // format [folder] [apply] <body>
+	postRestMux.HandleFunc("/rest/db/import", s.postDBImport)                           // folder <body>
+	postRestMux.HandleFunc("/rest/db/seed", s.postDBSeed)                               // folder source
+	postRestMux.HandleFunc("/rest/db/override", s.postDBOverride)                       // folder
+	postRestMux.HandleFunc("/rest/db/revert", s.postDBRevert)                           // folder
+	postRestMux.HandleFunc("/rest/db/scan", s.postDBScan)                               // folder [sub...] [delay]
+	postRestMux.HandleFunc("/rest/db/rehash", s.postDBRehash)                           // folder [sub...]
+	postRestMux.HandleFunc("/rest/db/view", s.postDBView)                               // [folder...]
+	postRestMux.HandleFunc("/rest/db/view/release", s.postDBViewRelease)                // view
+	postRestMux.HandleFunc("/rest/folder/decommission", s.postFolderDecommission)       // folder [minpeers] [audit] [deletedata]
+	postRestMux.HandleFunc("/rest/folder/move", s.postFolderMove)                       // folder path
+	postRestMux.HandleFunc("/rest/folder/audit", s.postFolderAudit)                     // folder device [samples]
+	postRestMux.HandleFunc("/rest/folder/jobs/cancel", s.postFolderJobsCancel)          // id
+	postRestMux.HandleFunc("/rest/folder/partials/resume", s.postFolderPartialResume)   // folder file
+	postRestMux.HandleFunc("/rest/folder/partials/discard", s.postFolderPartialDiscard) // folder file
+	postRestMux.HandleFunc("/rest/folder/templates", s.postFolderTemplate)              // <body>
+	postRestMux.HandleFunc("/rest/folder/templates/remove", s.postFolderTemplateRemove) // name
+	postRestMux.HandleFunc("/rest/folder/versions", s.postFolderVersionsRestore)        // folder <body>
+	postRestMux.HandleFunc("/rest/folder/versions/restore", s.postFolderVersionRestore) // folder file [time]
+	postRestMux.HandleFunc("/rest/system/config", s.postSystemConfig)                   // <body>
+	postRestMux.HandleFunc("/rest/system/config/rollback", s.postConfigRollback)        // version
+	postRestMux.HandleFunc("/rest/system/config/push", s.postConfigPush)                // device <body>
+	postRestMux.HandleFunc("/rest/pending/devices/accept", s.postPendingDeviceAccept)   // device [name]
+	postRestMux.HandleFunc("/rest/pending/devices/dismiss", s.postPendingDeviceDismiss) // device
+	postRestMux.HandleFunc("/rest/pending/folders/accept", s.postPendingFolderAccept)   // device folder [path] [type] [label]
+	postRestMux.HandleFunc("/rest/pending/folders/dismiss", s.postPendingFolderDismiss) // device folder
+	postRestMux.HandleFunc("/rest/device/groups", s.postDeviceGroup)                    // <body>
+	postRestMux.HandleFunc("/rest/device/groups/remove", s.postDeviceGroupRemove)       // name
+	postRestMux.HandleFunc("/rest/system/error", s.postSystemError)                     // <body>
+	postRestMux.HandleFunc("/rest/system/error/clear", s.postSystemErrorClear)          // -
+	postRestMux.HandleFunc("/rest/system/warnings/ack", s.postWarningsAck)              // id
+	postRestMux.HandleFunc("/rest/system/warnings/snooze", s.postWarningsSnooze)        // id duration
+	postRestMux.HandleFunc("/rest/system/totp/setup", s.postTOTPSetup)                  // -
+	postRestMux.HandleFunc("/rest/system/totp/enable", s.postTOTPEnable)                // code
+	postRestMux.HandleFunc("/rest/system/totp/disable", s.postTOTPDisable)              // code
+	postRestMux.HandleFunc("/rest/system/totp/recovery", s.postTOTPRecovery)            // code
+	postRestMux.HandleFunc("/rest/system/ping", s.restPing)                             // -
+	postRestMux.HandleFunc("/rest/system/reset", s.postSystemReset)                     // [folder]
+	postRestMux.HandleFunc("/rest/system/restart", s.postSystemRestart)                 // -
+	postRestMux.HandleFunc("/rest/system/shutdown", s.postSystemShutdown)               // -
+	postRestMux.HandleFunc("/rest/system/upgrade", s.postSystemUpgrade)                 // -
+	postRestMux.HandleFunc("/rest/system/pause", s.makeDevicePauseHandler(true))        // [device]
+	postRestMux.HandleFunc("/rest/system/resume", s.makeDevicePauseHandler(false))      // [device]
+	postRestMux.HandleFunc("/rest/system/reconnect", s.postSystemReconnect)             // [device]
+	postRestMux.HandleFunc("/rest/system/trace/start", s.makeDeviceTraceHandler(true))  // device
+	postRestMux.HandleFunc("/rest/system/trace/stop", s.makeDeviceTraceHandler(false))  // device
+	postRestMux.HandleFunc("/rest/system/debug", s.postSystemDebug)                     // [enable] [disable]
 
 	// Debug endpoints, not for general use
 	debugMux := http.NewServeMux()
@@ -281,7 +367,7 @@ func (s *service) Serve() {
 	debugMux.HandleFunc("/rest/debug/httpmetrics", s.getSystemHTTPMetrics)
 	debugMux.HandleFunc("/rest/debug/cpuprof", s.getCPUProf) // duration
 	debugMux.HandleFunc("/rest/debug/heapprof", s.getHeapProf)
-	debugMux.HandleFunc("/rest/debug/support", s.getSupportBundle)
+	debugMux.HandleFunc("/rest/debug/support", s.getSupportBundle) // [anonymize]
 	getRestMux.Handle("/rest/debug/", s.whenDebugging(debugMux))
 
 	// A handler that splits requests between the two above and disables
@@ -308,9 +394,18 @@ func (s *service) Serve() {
 	// Add our version and ID as a header to responses
 	handler = withDetailsMiddleware(s.id, handler)
 
-	// Wrap everything in basic auth, if user/password is set.
-	if guiCfg.IsAuthEnabled() {
-		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, s.cfg.LDAP(), handler)
+	// Let users do only what their role allows
+	handler = roleMiddleware(handler)
+
+	// Log who changes things, once they are authenticated
+	handler = auditMiddleware("sessionid-"+s.id.String()[:5], guiCfg, handler)
+
+	// Wrap everything in basic auth, if user/password is set, or log in
+	// with the OpenID Connect provider.
+	if guiCfg.AuthMode == config.AuthModeOIDC {
+		handler = oidcAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, newOIDCAuthenticator(s.cfg.OIDC()), handler)
+	} else if guiCfg.IsAuthEnabled() {
+		handler = basicAuthAndSessionMiddleware("sessionid-"+s.id.String()[:5], guiCfg, s.cfg.LDAP(), s.totp, handler)
 	}
 
 	// Redirect to HTTPS if we are supposed to
@@ -326,6 +421,12 @@ func (s *service) Serve() {
 		handler = localhostMiddleware(handler)
 	}
 
+	// Answer the probes of load balancers and the like without
+	// authentication, when enabled
+	if guiCfg.HealthEndpoints {
+		handler = s.healthMiddleware(handler)
+	}
+
 	handler = debugMiddleware(handler)
 
 	srv := http.Server{
@@ -404,8 +505,18 @@ func (s *service) VerifyConfiguration(from, to config.Configuration) error {
 func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 	// No action required when this changes, so mask the fact that it changed at all.
 	from.GUI.Debugging = to.GUI.Debugging
+	if len(from.GUI.ClientCertFingerprints) == 0 && len(to.GUI.ClientCertFingerprints) == 0 {
+		// Nil and empty lists are the same thing.
+		from.GUI.ClientCertFingerprints = to.GUI.ClientCertFingerprints
+	}
+	if len(from.GUI.Users) == 0 && len(to.GUI.Users) == 0 {
+		from.GUI.Users = to.GUI.Users
+	}
+	if len(from.LDAP.GroupRoles) == 0 && len(to.LDAP.GroupRoles) == 0 {
+		from.LDAP.GroupRoles = to.LDAP.GroupRoles
+	}
 
-	if to.GUI == from.GUI {
+	if reflect.DeepEqual(to.GUI, from.GUI) && reflect.DeepEqual(to.OIDC, from.OIDC) && reflect.DeepEqual(to.LDAP, from.LDAP) {
 		return true
 	}
 
@@ -637,7 +748,25 @@ func (s *service) getDBBrowse(w http.ResponseWriter, r *http.Request) {
 		levels = -1
 	}
 
-	sendJSON(w, s.model.GlobalDirectoryTree(folder, prefix, levels, dirsonly))
+	// Without any of the listing parameters the result is the nested tree,
+	// as it always was.
+	if qs.Get("sort") == "" && qs.Get("desc") == "" && qs.Get("page") == "" && qs.Get("perpage") == "" {
+		sendJSON(w, s.model.GlobalDirectoryTree(folder, prefix, levels, dirsonly))
+		return
+	}
+
+	page, perpage := getPagingParams(qs)
+
+	files, err := s.model.GlobalDirectoryList(folder, prefix, levels, dirsonly, qs.Get("sort"), qs.Get("desc") != "", page, perpage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"files":   toJsonFileInfoSlice(files),
+		"page":    page,
+		"perpage": perpage,
+	})
 }
 
 func (s *service) getDBCompletion(w http.ResponseWriter, r *http.Request) {
@@ -651,19 +780,65 @@ func (s *service) getDBCompletion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendJSON(w, s.model.Completion(device, folder).Map())
+	view, done, err := s.readView(qs, folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer done()
+
+	sendJSON(w, view.Completion(device, folder).Map())
 }
 
 func (s *service) getDBStatus(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
-	if sum, err := s.fss.Summary(folder); err != nil {
+
+	view, done, err := s.readView(qs, folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer done()
+
+	if sum, err := s.fss.ViewSummary(view, folder); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 	} else {
 		sendJSON(w, sum)
 	}
 }
 
+// readView returns the read view given by the view parameter, or else a new
+// one of the folder, and a function to call when done reading it.
+func (s *service) readView(qs url.Values, folder string) (*model.ReadView, func(), error) {
+	if id := qs.Get("view"); id != "" {
+		return s.readViews.acquire(id)
+	}
+	view := s.model.ReadView(folder)
+	return view, view.Release, nil
+}
+
+func (s *service) postDBView(w http.ResponseWriter, r *http.Request) {
+	folders := r.URL.Query()["folder"]
+	if len(folders) == 0 {
+		for id := range s.cfg.Folders() {
+			folders = append(folders, id)
+		}
+	}
+
+	id, expires := s.readViews.add(s.model.ReadView(folders...))
+	sendJSON(w, map[string]interface{}{
+		"view":    id,
+		"expires": expires,
+	})
+}
+
+func (s *service) postDBViewRelease(w http.ResponseWriter, r *http.Request) {
+	if err := s.readViews.release(r.URL.Query().Get("view")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
 func (s *service) postDBOverride(w http.ResponseWriter, r *http.Request) {
 	var qs = r.URL.Query()
 	var folder = qs.Get("folder")
@@ -695,7 +870,14 @@ func (s *service) getDBNeed(w http.ResponseWriter, r *http.Request) {
 
 	page, perpage := getPagingParams(qs)
 
-	progress, queued, rest := s.model.NeedFolderFiles(folder, page, perpage)
+	view, done, err := s.readView(qs, folder)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer done()
+
+	progress, queued, rest := view.NeedFolderFiles(folder, page, perpage)
 
 	// Convert the struct to a more loose structure, and inject the size.
 	sendJSON(w, map[string]interface{}{
@@ -731,6 +913,18 @@ func (s *service) getDBRemoteNeed(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *service) getDBRemoteStatus(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	deviceID, err := protocol.DeviceIDFromString(qs.Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, s.model.RemoteFolderStatus(deviceID))
+}
+
 func (s *service) getDBLocalChanged(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 
@@ -747,10 +941,127 @@ func (s *service) getDBLocalChanged(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *service) getDBSnapshots(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	snaps, err := s.model.IndexSnapshots(qs.Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, snaps)
+}
+
+func (s *service) getDBSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	from, err := time.Parse(time.RFC3339, qs.Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var to time.Time
+	if toStr := qs.Get("to"); toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, err := s.model.DiffIndexSnapshots(qs.Get("folder"), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	res := make([]map[string]interface{}, len(changes))
+	for i, c := range changes {
+		entry := map[string]interface{}{
+			"name":   c.Name(),
+			"change": c.Type.String(),
+		}
+		if c.Old.Name != "" {
+			entry["old"] = jsonDBFileInfo(c.Old)
+		}
+		if c.New.Name != "" {
+			entry["new"] = jsonDBFileInfo(c.New)
+			entry["modifiedBy"] = c.New.ModifiedBy.String()
+		}
+		res[i] = entry
+	}
+	sendJSON(w, res)
+}
+
+func (s *service) getDBExport(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	folder := qs.Get("folder")
+	if _, ok := s.cfg.Folder(folder); !ok {
+		http.Error(w, "No such folder", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": folder + ".stindex"}))
+	if err := s.model.ExportIndex(folder, w); err != nil {
+		l.Warnf("Exporting index of folder %q: %v", folder, err)
+	}
+}
+
+func (s *service) postDBImport(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	n, err := s.model.ImportIndex(qs.Get("folder"), r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, map[string]int{
+		"imported": n,
+	})
+}
+
+func (s *service) postDBSeed(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	source := qs.Get("source")
+	if source == "" {
+		http.Error(w, "Missing source", http.StatusBadRequest)
+		return
+	}
+	res, err := s.model.SeedFolder(qs.Get("folder"), source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, res)
+}
+
 func (s *service) getSystemConnections(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, s.model.ConnectionStats())
 }
 
+func (s *service) getConnHistory(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, s.model.ConnectionHistory(deviceID))
+}
+
+func (s *service) getSystemAttempts(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string]interface{}{
+		"attempts": s.connectionsService.ConnectionAttempts(),
+		"bans":     s.connectionsService.Bans(),
+	})
+}
+
+func (s *service) getSystemNAT(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string]interface{}{
+		"type":     s.connectionsService.NATType(),
+		"mappings": s.connectionsService.NATMappings(),
+	})
+}
+
 func (s *service) getDeviceStats(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, s.model.DeviceStatistics())
 }
@@ -776,12 +1087,88 @@ func (s *service) getDBFile(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, map[string]interface{}{
 		"global":       jsonFileInfo(gf),
 		"local":        jsonFileInfo(lf),
-		"availability": av,
+		"availability": s.model.RankSources(av),
+	})
+}
+
+// getDBFileVersions returns the version of the file on each device that has
+// it, and how it compares to the global version.
+func (s *service) getDBFileVersions(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	versions, err := s.model.FileVersions(qs.Get("folder"), qs.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if versions == nil {
+		http.Error(w, "No such object in the index", http.StatusNotFound)
+		return
+	}
+
+	res := make([]map[string]interface{}, len(versions))
+	for i, v := range versions {
+		res[i] = map[string]interface{}{
+			"device":   v.Device.String(),
+			"vector":   jsonVector(v.File.Version),
+			"sequence": v.File.Sequence,
+			"deleted":  v.File.Deleted,
+			"invalid":  v.File.IsInvalid(),
+			"ordering": v.Ordering.String(),
+		}
+	}
+	sendJSON(w, res)
+}
+
+// getDBFileStatus returns the sync state of the file, and the version of
+// it on each device that has it.
+func (s *service) getDBFileStatus(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	status, err := s.model.FileStatus(qs.Get("folder"), qs.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	versions := make([]map[string]interface{}, len(status.Versions))
+	for i, v := range status.Versions {
+		versions[i] = map[string]interface{}{
+			"device":   v.Device.String(),
+			"vector":   jsonVector(v.File.Version),
+			"deleted":  v.File.Deleted,
+			"ordering": v.Ordering.String(),
+		}
+	}
+	sendJSON(w, map[string]interface{}{
+		"state":    status.State.String(),
+		"versions": versions,
+	})
+}
+
+// getVersionCompare returns how version a relates to version b: equal,
+// greater (a is newer), lesser (b is newer) or concurrent (conflicting).
+func (s *service) getVersionCompare(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	a, err := parseVector(qs.Get("a"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := parseVector(qs.Get("b"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, map[string]string{
+		"ordering": a.Compare(b).String(),
 	})
 }
 
 func (s *service) getSystemConfig(w http.ResponseWriter, r *http.Request) {
-	sendJSON(w, s.cfg.RawCopy())
+	cfg := s.cfg.RawCopy()
+	if requestRole(r) != config.GUIRoleAdmin {
+		cfg = redactConfig(cfg)
+	}
+	sendJSON(w, cfg)
 }
 
 func (s *service) postSystemConfig(w http.ResponseWriter, r *http.Request) {
@@ -796,6 +1183,10 @@ func (s *service) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The second factor isn't part of the JSON config and is managed
+	// through its own endpoints only.
+	to.TOTP = s.cfg.TOTP()
+
 	if to.GUI.Password != s.cfg.GUI().Password {
 		if to.GUI.Password != "" && !bcryptExpr.MatchString(to.GUI.Password) {
 			hash, err := bcrypt.GenerateFromPassword([]byte(to.GUI.Password), 0)
@@ -808,6 +1199,18 @@ func (s *service) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 			to.GUI.Password = string(hash)
 		}
 	}
+	for i, user := range to.GUI.Users {
+		if user.Password != "" && !bcryptExpr.MatchString(user.Password) {
+			hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 0)
+			if err != nil {
+				l.Warnln("bcrypting password:", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			to.GUI.Users[i].Password = string(hash)
+		}
+	}
 
 	// Activate and save. Wait for the configuration to become active before
 	// completing the request.
@@ -827,55 +1230,443 @@ func (s *service) postSystemConfig(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *service) getSystemConfigInsync(w http.ResponseWriter, r *http.Request) {
-	sendJSON(w, map[string]bool{"configInSync": !s.cfg.RequiresRestart()})
-}
-
-func (s *service) postSystemRestart(w http.ResponseWriter, r *http.Request) {
-	s.flushResponse(`{"ok": "restarting"}`, w)
-	go s.contr.Restart()
+func (s *service) getConfigHistory(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.cfg.History())
 }
 
-func (s *service) postSystemReset(w http.ResponseWriter, r *http.Request) {
-	var qs = r.URL.Query()
-	folder := qs.Get("folder")
+func (s *service) postConfigRollback(w http.ResponseWriter, r *http.Request) {
+	s.systemConfigMut.Lock()
+	defer s.systemConfigMut.Unlock()
 
-	if len(folder) > 0 {
-		if _, ok := s.cfg.Folders()[folder]; !ok {
-			http.Error(w, "Invalid folder ID", 500)
-			return
-		}
+	version, err := strconv.Atoi(r.URL.Query().Get("version"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if len(folder) == 0 {
-		// Reset all folders.
-		for folder := range s.cfg.Folders() {
-			s.model.ResetFolder(folder)
-		}
-		s.flushResponse(`{"ok": "resetting database"}`, w)
-	} else {
-		// Reset a specific folder, assuming it's supposed to exist.
-		s.model.ResetFolder(folder)
-		s.flushResponse(`{"ok": "resetting folder `+folder+`"}`, w)
+	wg, err := s.cfg.Rollback(version)
+	if err != nil {
+		l.Warnln("Rolling back config:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	wg.Wait()
 
-	go s.contr.Restart()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
 
-func (s *service) postSystemShutdown(w http.ResponseWriter, r *http.Request) {
-	s.flushResponse(`{"ok": "shutting down"}`, w)
-	go s.contr.Shutdown()
+// postConfigPush pushes the folders, devices and options in the body to a
+// device that accepts us as its config manager, returning once the device
+// has applied them.
+func (s *service) postConfigPush(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	var cfg config.PushedConfig
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.model.PushConfig(deviceID, cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 }
 
-func (s *service) flushResponse(resp string, w http.ResponseWriter) {
-	w.Write([]byte(resp + "\n"))
-	f := w.(http.Flusher)
-	f.Flush()
+func (s *service) getFolderTemplates(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.cfg.FolderTemplates())
 }
 
-func (s *service) getSystemStatus(w http.ResponseWriter, r *http.Request) {
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
+// getFolderTemplateNew returns the configuration of a new folder created
+// from the template, to be added with the folder config.
+func (s *service) getFolderTemplateNew(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	tmpl, ok := s.cfg.FolderTemplate(qs.Get("template"))
+	if !ok {
+		http.Error(w, "no such folder template", http.StatusNotFound)
+		return
+	}
+	id, path := qs.Get("id"), qs.Get("path")
+	if id == "" || path == "" {
+		http.Error(w, "folder id and path are required", http.StatusBadRequest)
+		return
+	}
+
+	fcfg := config.NewFolderConfiguration(s.id, id, qs.Get("label"), fs.FilesystemTypeBasic, path)
+	tmpl.Apply(&fcfg)
+	sendJSON(w, fcfg)
+}
+
+func (s *service) postFolderTemplate(w http.ResponseWriter, r *http.Request) {
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	tmpl := config.NewFolderTemplate("")
+	if err := json.Unmarshal(bs, &tmpl); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tmpl.Name == "" {
+		http.Error(w, "folder template name is required", http.StatusBadRequest)
+		return
+	}
+
+	wg, err := s.cfg.SetFolderTemplate(tmpl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wg.Wait()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *service) postFolderTemplateRemove(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if _, ok := s.cfg.FolderTemplate(name); !ok {
+		http.Error(w, "no such folder template", http.StatusNotFound)
+		return
+	}
+
+	wg, err := s.cfg.RemoveFolderTemplate(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wg.Wait()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *service) getDeviceGroups(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.cfg.DeviceGroups())
+}
+
+func (s *service) postDeviceGroup(w http.ResponseWriter, r *http.Request) {
+	bs, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	var group config.DeviceGroup
+	if err := json.Unmarshal(bs, &group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if group.Name == "" {
+		http.Error(w, "device group name is required", http.StatusBadRequest)
+		return
+	}
+
+	wg, err := s.cfg.SetDeviceGroup(group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wg.Wait()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *service) postDeviceGroupRemove(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if _, ok := s.cfg.DeviceGroup(name); !ok {
+		http.Error(w, "no such device group", http.StatusNotFound)
+		return
+	}
+
+	wg, err := s.cfg.RemoveDeviceGroup(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	wg.Wait()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *service) getPendingDevices(w http.ResponseWriter, r *http.Request) {
+	devices := s.cfg.PendingDevices()
+	if devices == nil {
+		devices = []config.ObservedDevice{}
+	}
+	sendJSON(w, devices)
+}
+
+// getPendingFolders returns the folders offered by devices, or the given
+// one, that aren't shared with them, and whether there already is a folder
+// with the ID to share with the device when accepted.
+func (s *service) getPendingFolders(w http.ResponseWriter, r *http.Request) {
+	var device protocol.DeviceID
+	if deviceStr := r.URL.Query().Get("device"); deviceStr != "" {
+		var err error
+		device, err = protocol.DeviceIDFromString(deviceStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	devices := s.cfg.Devices()
+	folders := s.cfg.Folders()
+	res := []map[string]interface{}{}
+	for _, pending := range s.cfg.PendingFolders() {
+		if device != protocol.EmptyDeviceID && pending.DeviceID != device {
+			continue
+		}
+		_, existing := folders[pending.ID]
+		res = append(res, map[string]interface{}{
+			"time":       pending.Time,
+			"id":         pending.ID,
+			"label":      pending.Label,
+			"deviceID":   pending.DeviceID,
+			"deviceName": devices[pending.DeviceID].Name,
+			"existing":   existing,
+		})
+	}
+	sendJSON(w, res)
+}
+
+func (s *service) postPendingDeviceAccept(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	device, pending, ok := s.pendingDevice(w, qs.Get("device"))
+	if !ok {
+		return
+	}
+	name := qs.Get("name")
+	if name == "" {
+		name = pending.Name
+	}
+
+	s.commitConfigChange(w, func() (config.Waiter, error) {
+		return s.cfg.SetDevice(config.NewDeviceConfiguration(device, name))
+	})
+}
+
+func (s *service) postPendingDeviceDismiss(w http.ResponseWriter, r *http.Request) {
+	device, _, ok := s.pendingDevice(w, r.URL.Query().Get("device"))
+	if !ok {
+		return
+	}
+
+	s.commitConfigChange(w, func() (config.Waiter, error) {
+		return s.cfg.DismissPendingDevice(device)
+	})
+}
+
+// pendingDevice returns the pending device of the ID, or responds with the
+// error and returns false.
+func (s *service) pendingDevice(w http.ResponseWriter, deviceStr string) (protocol.DeviceID, config.ObservedDevice, bool) {
+	device, err := protocol.DeviceIDFromString(deviceStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return device, config.ObservedDevice{}, false
+	}
+	for _, pending := range s.cfg.PendingDevices() {
+		if pending.ID == device {
+			return device, pending, true
+		}
+	}
+	http.Error(w, "no such pending device", http.StatusNotFound)
+	return device, config.ObservedDevice{}, false
+}
+
+// postPendingFolderAccept shares the folder with the device that offered
+// it, creating it at the path and of the type, by default send-receive in
+// the default folder path, unless there already is a folder with the ID.
+func (s *service) postPendingFolderAccept(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	device, pending, ok := s.pendingFolder(w, qs.Get("device"), qs.Get("folder"))
+	if !ok {
+		return
+	}
+
+	fcfg, ok := s.cfg.Folder(pending.ID)
+	if !ok {
+		label := qs.Get("label")
+		if label == "" {
+			label = pending.Label
+		}
+		var paths []string
+		if path := qs.Get("path"); path != "" {
+			if expanded, err := fs.ExpandTilde(path); err == nil {
+				path = expanded
+			}
+			paths = []string{path}
+		} else {
+			// The label comes from the remote device, so it's made safe
+			// to use as a path just like when auto-accepting.
+			deviceCfg, _ := s.cfg.Device(device)
+			paths = model.NewFolderPaths("", s.cfg.Options().DefaultFolderPath, deviceCfg, protocol.Folder{ID: pending.ID, Label: label})
+		}
+		path, err := unusedFolderPath(paths)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		fcfg = config.NewFolderConfiguration(s.id, pending.ID, label, fs.FilesystemTypeBasic, path)
+		if typ := qs.Get("type"); typ != "" {
+			if err := fcfg.Type.UnmarshalText([]byte(typ)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{DeviceID: device})
+
+	s.commitConfigChange(w, func() (config.Waiter, error) {
+		return s.cfg.SetFolder(fcfg)
+	})
+}
+
+// unusedFolderPath returns the first of the paths which doesn't exist or is
+// an empty directory, so that accepting a folder doesn't mix its files with
+// others.
+func unusedFolderPath(paths []string) (string, error) {
+	for _, path := range paths {
+		parentFs := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Dir(path))
+		info, err := parentFs.Lstat(filepath.Base(path))
+		if fs.IsNotExist(err) {
+			return path, nil
+		}
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if names, err := parentFs.DirNames(filepath.Base(path)); err == nil && len(names) == 0 {
+			return path, nil
+		}
+	}
+	if len(paths) == 0 {
+		return "", errors.New("no usable folder path")
+	}
+	return "", fmt.Errorf("folder path %s exists and is not empty", paths[0])
+}
+
+func (s *service) postPendingFolderDismiss(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	device, pending, ok := s.pendingFolder(w, qs.Get("device"), qs.Get("folder"))
+	if !ok {
+		return
+	}
+
+	s.commitConfigChange(w, func() (config.Waiter, error) {
+		return s.cfg.DismissPendingFolder(device, pending.ID)
+	})
+}
+
+// pendingFolder returns the folder pending from the device, or responds
+// with the error and returns false.
+func (s *service) pendingFolder(w http.ResponseWriter, deviceStr, folder string) (protocol.DeviceID, config.PendingFolder, bool) {
+	device, err := protocol.DeviceIDFromString(deviceStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return device, config.PendingFolder{}, false
+	}
+	for _, pending := range s.cfg.PendingFolders() {
+		if pending.DeviceID == device && pending.ID == folder {
+			return device, pending, true
+		}
+	}
+	http.Error(w, "no such pending folder", http.StatusNotFound)
+	return device, config.PendingFolder{}, false
+}
+
+// commitConfigChange makes the change, waits for it to take effect and
+// saves the config, responding with the error if any of that fails.
+func (s *service) commitConfigChange(w http.ResponseWriter, change func() (config.Waiter, error)) {
+	wg, err := change()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wg.Wait()
+	if err := s.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *service) getSystemConfigInsync(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, map[string]bool{"configInSync": !s.cfg.RequiresRestart()})
+}
+
+func (s *service) postSystemRestart(w http.ResponseWriter, r *http.Request) {
+	s.flushResponse(`{"ok": "restarting"}`, w)
+	go s.contr.Restart()
+}
+
+func (s *service) postSystemReset(w http.ResponseWriter, r *http.Request) {
+	var qs = r.URL.Query()
+	folder := qs.Get("folder")
+
+	if len(folder) > 0 {
+		if _, ok := s.cfg.Folders()[folder]; !ok {
+			http.Error(w, "Invalid folder ID", 500)
+			return
+		}
+	}
+
+	if len(folder) == 0 {
+		// Reset all folders.
+		for folder := range s.cfg.Folders() {
+			s.model.ResetFolder(folder)
+		}
+		s.flushResponse(`{"ok": "resetting database"}`, w)
+	} else {
+		// Reset a specific folder, assuming it's supposed to exist.
+		s.model.ResetFolder(folder)
+		s.flushResponse(`{"ok": "resetting folder `+folder+`"}`, w)
+	}
+
+	go s.contr.Restart()
+}
+
+func (s *service) postSystemShutdown(w http.ResponseWriter, r *http.Request) {
+	s.flushResponse(`{"ok": "shutting down"}`, w)
+	go s.contr.Shutdown()
+}
+
+func (s *service) flushResponse(resp string, w http.ResponseWriter) {
+	w.Write([]byte(resp + "\n"))
+	f := w.(http.Flusher)
+	f.Flush()
+}
+
+func (s *service) getSystemStatus(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
 
 	tilde, _ := fs.ExpandTilde("~")
 	res := make(map[string]interface{})
@@ -927,6 +1718,106 @@ func (s *service) postSystemErrorClear(w http.ResponseWriter, r *http.Request) {
 	s.guiErrors.Clear()
 }
 
+func (s *service) getWarnings(w http.ResponseWriter, r *http.Request) {
+	if s.warnings == nil {
+		http.Error(w, "warnings are not available", http.StatusNotFound)
+		return
+	}
+	sendJSON(w, map[string][]Warning{
+		"warnings": s.warnings.Warnings(r.URL.Query().Get("all") != ""),
+	})
+}
+
+func (s *service) postWarningsAck(w http.ResponseWriter, r *http.Request) {
+	if s.warnings == nil {
+		http.Error(w, "warnings are not available", http.StatusNotFound)
+		return
+	}
+	if !s.warnings.Acknowledge(r.URL.Query().Get("id")) {
+		http.Error(w, "no such warning", http.StatusNotFound)
+	}
+}
+
+func (s *service) postWarningsSnooze(w http.ResponseWriter, r *http.Request) {
+	if s.warnings == nil {
+		http.Error(w, "warnings are not available", http.StatusNotFound)
+		return
+	}
+	qs := r.URL.Query()
+	d, err := time.ParseDuration(qs.Get("duration"))
+	if err != nil || d <= 0 {
+		http.Error(w, "invalid duration", http.StatusBadRequest)
+		return
+	}
+	if !s.warnings.Snooze(qs.Get("id"), d) {
+		http.Error(w, "no such warning", http.StatusNotFound)
+	}
+}
+
+func (s *service) getTOTP(w http.ResponseWriter, r *http.Request) {
+	totp := s.cfg.TOTP()
+	sendJSON(w, map[string]interface{}{
+		"enabled":       totp.Enabled(),
+		"recoveryCodes": len(totp.RecoveryCodes),
+	})
+}
+
+func (s *service) postTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	guiCfg := s.cfg.GUI()
+	if !guiCfg.IsAuthEnabled() || guiCfg.AuthMode == config.AuthModeOIDC {
+		http.Error(w, "a second factor requires password authentication", http.StatusBadRequest)
+		return
+	}
+	secret, uri, err := s.totp.setup(guiCfg.User)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, map[string]string{
+		"secret": secret,
+		"uri":    uri,
+	})
+}
+
+func (s *service) postTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.totp.enable(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), totpErrorStatus(err))
+		return
+	}
+	sendJSON(w, map[string][]string{
+		"recoveryCodes": codes,
+	})
+}
+
+func (s *service) postTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	if err := s.totp.disable(r.URL.Query().Get("code")); err != nil {
+		http.Error(w, err.Error(), totpErrorStatus(err))
+	}
+}
+
+func (s *service) postTOTPRecovery(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.totp.regenerateRecoveryCodes(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), totpErrorStatus(err))
+		return
+	}
+	sendJSON(w, map[string][]string{
+		"recoveryCodes": codes,
+	})
+}
+
+func totpErrorStatus(err error) int {
+	switch err {
+	case errTOTPInvalidCode:
+		return http.StatusForbidden
+	case errTOTPNotEnabled, errTOTPNoSetup:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (s *service) getSystemLog(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	since, err := time.Parse(time.RFC3339, q.Get("since"))
@@ -975,7 +1866,7 @@ func (s *service) getSupportBundle(w http.ResponseWriter, r *http.Request) {
 
 	// Errors as a JSON
 	if errs := s.guiErrors.Since(time.Time{}); len(errs) > 0 {
-		if jsonError, err := json.MarshalIndent(errs, "", "  "); err != nil {
+		if jsonError, err := json.MarshalIndent(errs, "", "  "); err == nil {
 			files = append(files, fileEntry{name: "errors.json.txt", data: jsonError})
 		} else {
 			l.Warnln("Support bundle: failed to create errors.json:", err)
@@ -1019,6 +1910,27 @@ func (s *service) getSupportBundle(w http.ResponseWriter, r *http.Request) {
 		files = append(files, fileEntry{name: "usage-reporting.json.txt", data: usageReportingData})
 	}
 
+	// Database statistics as a JSON
+	if jsonDBStats, err := json.MarshalIndent(dbStats(s), "", "  "); err == nil {
+		files = append(files, fileEntry{name: "db-stats.json.txt", data: jsonDBStats})
+	} else {
+		l.Warnln("Support bundle: failed to create db-stats.json:", err)
+	}
+
+	// Pseudonyms instead of device IDs, names and paths, as the same in
+	// all files, for bundles to be shared publicly. The profiles hold
+	// nothing identifying.
+	anonymize, _ := strconv.ParseBool(r.URL.Query().Get("anonymize"))
+	if anonymize {
+		anon := newAnonymizer(s.cfg.RawCopy(), s.id)
+		for _, file := range files {
+			anon.collect(file.data)
+		}
+		for i := range files {
+			files[i].data = anon.anonymize(files[i].data)
+		}
+	}
+
 	// Heap and CPU Proofs as a pprof extension
 	var heapBuffer, cpuBuffer bytes.Buffer
 	filename := fmt.Sprintf("syncthing-heap-%s-%s-%s-%s.pprof", runtime.GOOS, runtime.GOARCH, build.Version, time.Now().Format("150405")) // hhmmss
@@ -1045,6 +1957,9 @@ func (s *service) getSupportBundle(w http.ResponseWriter, r *http.Request) {
 
 	// Set zip file name and path
 	zipFileName := fmt.Sprintf("support-bundle-%s-%s.zip", s.id.Short().String(), time.Now().Format("2006-01-02T150405"))
+	if anonymize {
+		zipFileName = fmt.Sprintf("support-bundle-anonymized-%s.zip", time.Now().Format("2006-01-02T150405"))
+	}
 	zipFilePath := filepath.Join(locations.GetBaseDir(locations.ConfigBaseDir), zipFileName)
 
 	// Write buffer zip to local zip file (back up)
@@ -1116,16 +2031,33 @@ func (s *service) getDBIgnores(w http.ResponseWriter, r *http.Request) {
 
 	folder := qs.Get("folder")
 
-	ignores, patterns, err := s.model.GetIgnores(folder)
+	ignores, patterns, err := s.model.GetIgnores(folder)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	sendJSON(w, map[string][]string{
+		"ignore":   ignores,
+		"expanded": patterns,
+	})
+}
+
+func (s *service) getFolderIgnoresTest(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	files := qs["file"]
+	if len(files) == 0 {
+		http.Error(w, "no file given", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.model.TestIgnores(qs.Get("folder"), files)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	sendJSON(w, map[string][]string{
-		"ignore":   ignores,
-		"expanded": patterns,
-	})
+	sendJSON(w, res)
 }
 
 func (s *service) postDBIgnores(w http.ResponseWriter, r *http.Request) {
@@ -1154,10 +2086,38 @@ func (s *service) postDBIgnores(w http.ResponseWriter, r *http.Request) {
 	s.getDBIgnores(w, r)
 }
 
+// postDBIgnoresImport converts the posted ignore rules of another sync
+// tool, and replaces the ignores of the folder with the result if apply is
+// set.
+func (s *service) postDBIgnoresImport(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	patterns, warnings, err := ignore.ImportPatterns(qs.Get("format"), r.Body)
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if qs.Get("apply") == "true" {
+		if err := s.model.SetIgnores(qs.Get("folder"), patterns); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+
+	sendJSON(w, map[string][]string{
+		"ignore":   patterns,
+		"warnings": warnings,
+	})
+}
+
 func (s *service) getIndexEvents(w http.ResponseWriter, r *http.Request) {
 	s.fss.OnEventRequest()
-	mask := s.getEventMask(r.URL.Query().Get("events"))
-	sub := s.getEventSub(mask)
+	qs := r.URL.Query()
+	mask := s.getEventMask(qs.Get("events"))
+	sub, done := s.getFilteredEventSub(mask, getEventFilter(qs))
+	defer done()
 	s.getEvents(w, r, sub)
 }
 
@@ -1192,6 +2152,24 @@ func (s *service) getEvents(w http.ResponseWriter, r *http.Request, eventSub eve
 		evs = evs[len(evs)-limit:]
 	}
 
+	sendJSON(w, redactEvents(evs, requestRole(r)))
+}
+
+func (s *service) getJournalEvents(w http.ResponseWriter, r *http.Request) {
+	if s.journal == nil {
+		http.Error(w, "event journal is not enabled", http.StatusNotFound)
+		return
+	}
+
+	qs := r.URL.Query()
+	since, _ := strconv.Atoi(qs.Get("since"))
+	limit, _ := strconv.Atoi(qs.Get("limit"))
+
+	evs, err := s.journal.Since(since, limit)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
 	sendJSON(w, evs)
 }
 
@@ -1220,6 +2198,78 @@ func (s *service) getEventSub(mask events.EventType) events.BufferedSubscription
 	return bufsub
 }
 
+// getEventFilter returns the filter given by the comma separated folder and
+// device query parameters.
+func getEventFilter(qs url.Values) events.Filter {
+	var filter events.Filter
+	for _, folder := range strings.Split(qs.Get("folder"), ",") {
+		if folder = strings.TrimSpace(folder); folder != "" {
+			filter.Folders = append(filter.Folders, folder)
+		}
+	}
+	for _, device := range strings.Split(qs.Get("device"), ",") {
+		device = strings.TrimSpace(device)
+		if device == "" {
+			continue
+		}
+		// Events carry the canonical form of the device ID
+		if id, err := protocol.DeviceIDFromString(device); err == nil {
+			device = id.String()
+		}
+		filter.Devices = append(filter.Devices, device)
+	}
+	return filter
+}
+
+type filteredEventSub struct {
+	sub      *events.Subscription
+	buf      events.BufferedSubscription
+	polling  int // requests waiting for events
+	lastUsed time.Time
+}
+
+// getFilteredEventSub returns a buffered subscription receiving only the
+// events matching the mask and filter, so that clients interested in a
+// single folder or device don't need to wade through everything else. The
+// returned function must be called when done polling it.
+func (s *service) getFilteredEventSub(mask events.EventType, filter events.Filter) (events.BufferedSubscription, func()) {
+	if filter.IsEmpty() {
+		return s.getEventSub(mask), func() {}
+	}
+
+	key := fmt.Sprintf("%d %v", mask, filter)
+	s.eventSubsMut.Lock()
+	defer s.eventSubsMut.Unlock()
+	s.dropIdleFilteredEventSubsLocked()
+	fsub, ok := s.filteredEventSubs[key]
+	if !ok {
+		evsub := events.Default.SubscribeFiltered(mask, filter)
+		fsub = &filteredEventSub{
+			sub: evsub,
+			buf: events.NewBufferedSubscription(evsub, EventSubBufferSize),
+		}
+		s.filteredEventSubs[key] = fsub
+	}
+	fsub.polling++
+	return fsub.buf, func() {
+		s.eventSubsMut.Lock()
+		fsub.polling--
+		fsub.lastUsed = time.Now()
+		s.eventSubsMut.Unlock()
+	}
+}
+
+// dropIdleFilteredEventSubsLocked drops the filtered event subscriptions
+// that nobody polled in a while.
+func (s *service) dropIdleFilteredEventSubsLocked() {
+	for key, fsub := range s.filteredEventSubs {
+		if fsub.polling == 0 && time.Since(fsub.lastUsed) > filteredEventSubIdle {
+			events.Default.Unsubscribe(fsub.sub)
+			delete(s.filteredEventSubs, key)
+		}
+	}
+}
+
 func (s *service) getSystemUpgrade(w http.ResponseWriter, r *http.Request) {
 	if s.noUpgrade {
 		http.Error(w, upgrade.ErrUpgradeUnsupported.Error(), 500)
@@ -1323,6 +2373,66 @@ func (s *service) makeDevicePauseHandler(paused bool) http.HandlerFunc {
 	}
 }
 
+// postSystemReconnect dials the given device, or all devices, right away
+// instead of waiting for the reconnect interval.
+func (s *service) postSystemReconnect(w http.ResponseWriter, r *http.Request) {
+	deviceStr := r.URL.Query().Get("device")
+	if deviceStr == "" {
+		for device := range s.cfg.Devices() {
+			s.connectionsService.ReconnectNow(device)
+		}
+		return
+	}
+
+	device, err := protocol.DeviceIDFromString(deviceStr)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if _, ok := s.cfg.Devices()[device]; !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.connectionsService.ReconnectNow(device)
+}
+
+func (s *service) makeDeviceTraceHandler(start bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if start {
+			err = s.model.StartProtocolTrace(device)
+		} else {
+			err = s.model.StopProtocolTrace(device)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		}
+	}
+}
+
+func (s *service) getSystemTrace(w http.ResponseWriter, r *http.Request) {
+	device, err := protocol.DeviceIDFromString(r.URL.Query().Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := s.model.ProtocolTrace(device)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filename := fmt.Sprintf("syncthing-trace-%s-%s.json", device.Short(), time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	sendJSON(w, entries)
+}
+
 func (s *service) postDBScan(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -1348,6 +2458,16 @@ func (s *service) postDBScan(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *service) postDBRehash(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	report, err := s.model.RehashFolderSubdirs(qs.Get("folder"), qs["sub"])
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	sendJSON(w, report)
+}
+
 func (s *service) postDBPrio(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -1403,6 +2523,72 @@ func (s *service) getFolderVersions(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, versions)
 }
 
+func (s *service) getFolderVersionFile(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	when := time.Now()
+	if t := qs.Get("time"); t != "" {
+		var err error
+		if when, err = time.Parse(time.RFC3339, t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	file := qs.Get("file")
+	version, fd, err := s.model.GetFolderVersionAt(qs.Get("folder"), file, when)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer fd.Close()
+
+	w.Header().Set("X-Syncthing-Version-Time", version.VersionTime.Format(time.RFC3339))
+	http.ServeContent(w, r, filepath.Base(file), version.VersionTime, fd)
+}
+
+func (s *service) getFolderTrash(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var since time.Time
+	if t := qs.Get("since"); t != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	files, err := s.model.DeletedFiles(qs.Get("folder"), since, qs.Get("search"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, files)
+}
+
+func (s *service) getFolderAttention(w http.ResponseWriter, r *http.Request) {
+	files, err := s.model.AttentionFiles(r.URL.Query().Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, files)
+}
+
+func (s *service) getFolderHeatmap(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	levels, _ := strconv.Atoi(qs.Get("levels"))
+	limit, _ := strconv.Atoi(qs.Get("limit"))
+
+	changes, err := s.model.ChangeHeatmap(qs.Get("folder"), levels, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, changes)
+}
+
 func (s *service) postFolderVersionsRestore(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 
@@ -1428,6 +2614,125 @@ func (s *service) postFolderVersionsRestore(w http.ResponseWriter, r *http.Reque
 	sendJSON(w, ferr)
 }
 
+func (s *service) postFolderVersionRestore(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var versionTime time.Time
+	if t := qs.Get("time"); t != "" {
+		var err error
+		if versionTime, err = time.Parse(time.RFC3339, t); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	version, err := s.model.RestoreFolderVersion(qs.Get("folder"), qs.Get("file"), versionTime)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	sendJSON(w, version)
+}
+
+func (s *service) postFolderAudit(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	deviceID, err := protocol.DeviceIDFromString(qs.Get("device"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	samples, _ := strconv.Atoi(qs.Get("samples"))
+
+	report, err := s.model.AuditRemote(deviceID, qs.Get("folder"), samples)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, report)
+}
+
+func (s *service) getFolderJobs(w http.ResponseWriter, r *http.Request) {
+	sendJSON(w, s.model.FolderJobs(r.URL.Query().Get("folder")))
+}
+
+func (s *service) getFolderScanProgress(w http.ResponseWriter, r *http.Request) {
+	progress := s.model.ScanProgress()
+	if folder := r.URL.Query().Get("folder"); folder != "" {
+		p, ok := progress[folder]
+		if !ok {
+			http.Error(w, "folder is not scanning", http.StatusNotFound)
+			return
+		}
+		sendJSON(w, p)
+		return
+	}
+	sendJSON(w, progress)
+}
+
+func (s *service) postFolderJobsCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.model.CancelFolderJob(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+}
+
+func (s *service) getFolderPartials(w http.ResponseWriter, r *http.Request) {
+	partials, err := s.model.KeptPartials(r.URL.Query().Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	sendJSON(w, partials)
+}
+
+func (s *service) postFolderPartialResume(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if err := s.model.ResumePartial(qs.Get("folder"), qs.Get("file")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *service) postFolderPartialDiscard(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if err := s.model.DiscardPartial(qs.Get("folder"), qs.Get("file")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *service) postFolderDecommission(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var opts model.DecommissionOptions
+	opts.MinPeers, _ = strconv.Atoi(qs.Get("minpeers"))
+	opts.AuditSamples, _ = strconv.Atoi(qs.Get("audit"))
+	opts.DeleteData = qs.Get("deletedata") == "true"
+
+	report, err := s.model.DecommissionFolder(qs.Get("folder"), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, report)
+}
+
+func (s *service) postFolderMove(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	report, err := s.model.MoveFolder(qs.Get("folder"), qs.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sendJSON(w, report)
+}
+
 func (s *service) getFolderErrors(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -1469,6 +2774,26 @@ func (s *service) getSystemBrowse(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, browseFiles(current, fsType))
 }
 
+func (s *service) getSystemPreflight(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	path, err := fs.ExpandTilde(qs.Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if path == "" {
+		http.Error(w, "no path given", http.StatusBadRequest)
+		return
+	}
+
+	// Default value or in case of error unmarshalling ends up being basic fs.
+	var fsType fs.FilesystemType
+	fsType.UnmarshalText([]byte(qs.Get("filesystem")))
+
+	sendJSON(w, fs.Preflight(fsType, path))
+}
+
 const (
 	matchExact int = iota
 	matchCaseIns
@@ -1592,6 +2917,7 @@ func (f jsonFileInfo) MarshalJSON() ([]byte, error) {
 		"sequence":      f.Sequence,
 		"numBlocks":     len(f.Blocks),
 		"version":       jsonVersionVector(f.Version),
+		"vector":        jsonVector(f.Version),
 		"localFlags":    f.LocalFlags,
 	})
 }
@@ -1614,6 +2940,7 @@ func (f jsonDBFileInfo) MarshalJSON() ([]byte, error) {
 		"sequence":      f.Sequence,
 		"numBlocks":     nil, // explicitly unknown
 		"version":       jsonVersionVector(f.Version),
+		"vector":        jsonVector(f.Version),
 		"localFlags":    f.LocalFlags,
 	})
 }
@@ -1628,6 +2955,51 @@ func (v jsonVersionVector) MarshalJSON() ([]byte, error) {
 	return json.Marshal(res)
 }
 
+// jsonCounter is a counter of a version vector in the documented form of
+// the REST API, which unlike jsonVersionVector keeps the whole ID: the
+// first 64 bits of the device ID, as 16 hex digits.
+type jsonCounter struct {
+	ID    string `json:"id"`
+	Value uint64 `json:"value"`
+}
+
+func jsonVector(v protocol.Vector) []jsonCounter {
+	res := make([]jsonCounter, len(v.Counters))
+	for i, c := range v.Counters {
+		res[i] = jsonCounter{ID: fmt.Sprintf("%016x", uint64(c.ID)), Value: c.Value}
+	}
+	return res
+}
+
+// parseVector parses a vector given as comma separated id:value counters,
+// with the IDs as in jsonCounter.
+func parseVector(s string) (protocol.Vector, error) {
+	var v protocol.Vector
+	if s == "" {
+		return v, nil
+	}
+	for _, counter := range strings.Split(s, ",") {
+		parts := strings.Split(counter, ":")
+		if len(parts) != 2 {
+			return protocol.Vector{}, fmt.Errorf("invalid counter %q", counter)
+		}
+		id, err := strconv.ParseUint(parts[0], 16, 64)
+		if err != nil {
+			return protocol.Vector{}, fmt.Errorf("invalid counter %q: %v", counter, err)
+		}
+		value, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return protocol.Vector{}, fmt.Errorf("invalid counter %q: %v", counter, err)
+		}
+		v.Counters = append(v.Counters, protocol.Counter{ID: protocol.ShortID(id), Value: value})
+	}
+	// Vectors are kept sorted by ID, which Compare relies on.
+	sort.Slice(v.Counters, func(a, b int) bool {
+		return v.Counters[a].ID < v.Counters[b].ID
+	})
+	return v, nil
+}
+
 func dirNames(dir string) []string {
 	fd, err := os.Open(dir)
 	if err != nil {
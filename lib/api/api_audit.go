@@ -0,0 +1,56 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+// auditMiddleware logs an APIRequest event for each REST request that may
+// change something, that is anything but a GET, with how it was
+// authenticated and where it came from. Together with the ConfigChanged
+// events that's who changed what.
+func auditMiddleware(cookieName string, guiCfg config.GUIConfiguration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodOptions || !strings.HasPrefix(r.URL.Path, "/rest/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		data := map[string]interface{}{
+			"method":        r.Method,
+			"path":          r.URL.Path,
+			"remoteAddress": r.RemoteAddr,
+			"status":        sw.status,
+			"apiKey":        guiCfg.IsValidAPIKey(r.Header.Get("X-API-Key")),
+		}
+		if username, ok := sessionUser(cookieName, r); ok {
+			data["username"] = username
+		} else if username, _, ok := r.BasicAuth(); ok {
+			data["username"] = username
+		}
+		events.Default.Log(events.APIRequest, data)
+	})
+}
+
+// statusResponseWriter records the status of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
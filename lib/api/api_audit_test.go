@@ -0,0 +1,65 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	sub := events.Default.Subscribe(events.APIRequest)
+	defer events.Default.Unsubscribe(sub)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	handler := auditMiddleware("sessionid-test", config.GUIConfiguration{APIKey: "abc123"}, next)
+
+	// Reads aren't logged
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/rest/system/status", nil))
+	if _, err := sub.Poll(10 * time.Millisecond); err != events.ErrTimeout {
+		t.Error("Expected no event for a GET, got", err)
+	}
+
+	req := httptest.NewRequest("POST", "/rest/system/config", nil)
+	req.Header.Set("X-API-Key", "abc123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	ev, err := sub.Poll(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := ev.Data.(map[string]interface{})
+	if data["method"] != "POST" || data["path"] != "/rest/system/config" || data["apiKey"] != true || data["status"] != http.StatusBadRequest || data["remoteAddress"] != req.RemoteAddr {
+		t.Error("Unexpected event data", data)
+	}
+	if _, ok := data["username"]; ok {
+		t.Error("Unexpected username", data["username"])
+	}
+
+	// The user of a session is recorded
+	rec := httptest.NewRecorder()
+	createSession("sessionid-test", "admin", config.GUIRoleAdmin, rec)
+	req = httptest.NewRequest("POST", "/rest/system/restart", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	ev, err = sub.Poll(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = ev.Data.(map[string]interface{})
+	if data["username"] != "admin" || data["apiKey"] != false {
+		t.Error("Unexpected event data", data)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -24,33 +25,33 @@ import (
 )
 
 var (
-	sessions    = make(map[string]bool)
+	sessions    = make(map[string]session) // session ID to the user logged in with it
 	sessionsMut = sync.NewMutex()
 )
 
-func emitLoginAttempt(success bool, username string) {
+type session struct {
+	username string
+	role     config.GUIRole
+}
+
+func emitLoginAttempt(success bool, username string, r *http.Request) {
 	events.Default.Log(events.LoginAttempt, map[string]interface{}{
-		"success":  success,
-		"username": username,
+		"success":       success,
+		"username":      username,
+		"remoteAddress": r.RemoteAddr,
 	})
 }
 
-func basicAuthAndSessionMiddleware(cookieName string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, next http.Handler) http.Handler {
+func basicAuthAndSessionMiddleware(cookieName string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, totp *totpAuthenticator, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if guiCfg.IsValidAPIKey(r.Header.Get("X-API-Key")) {
+		if hasValidAPICredentials(guiCfg, r) {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		cookie, err := r.Cookie(cookieName)
-		if err == nil && cookie != nil {
-			sessionsMut.Lock()
-			_, ok := sessions[cookie.Value]
-			sessionsMut.Unlock()
-			if ok {
-				next.ServeHTTP(w, r)
-				return
-			}
+		if sess, ok := lookupSession(cookieName, r); ok {
+			next.ServeHTTP(w, withRole(r, sess.role))
+			return
 		}
 
 		l.Debugln("Sessionless HTTP request with authentication; this is expensive.")
@@ -83,43 +84,91 @@ func basicAuthAndSessionMiddleware(cookieName string, guiCfg config.GUIConfigura
 		username := string(fields[0])
 		password := string(fields[1])
 
-		authOk := auth(username, password, guiCfg, ldapCfg)
+		// With a second factor, its code is given in a header or after the
+		// password.
+		var code string
+		secondFactor := totp != nil && totp.enabled()
+		if secondFactor {
+			password, code = splitSecondFactor(password, r.Header.Get("X-TOTP-Code"))
+		}
+
+		role, authOk := auth(username, password, guiCfg, ldapCfg)
 		if !authOk {
 			usernameIso := string(iso88591ToUTF8([]byte(username)))
 			passwordIso := string(iso88591ToUTF8([]byte(password)))
-			authOk = auth(usernameIso, passwordIso, guiCfg, ldapCfg)
+			role, authOk = auth(usernameIso, passwordIso, guiCfg, ldapCfg)
 			if authOk {
 				username = usernameIso
 			}
 		}
 
 		if !authOk {
-			emitLoginAttempt(false, username)
+			emitLoginAttempt(false, username, r)
 			error()
 			return
 		}
 
-		sessionid := rand.String(32)
-		sessionsMut.Lock()
-		sessions[sessionid] = true
-		sessionsMut.Unlock()
-		http.SetCookie(w, &http.Cookie{
-			Name:   cookieName,
-			Value:  sessionid,
-			MaxAge: 0,
-		})
-
-		emitLoginAttempt(true, username)
-		next.ServeHTTP(w, r)
+		if secondFactor {
+			if err := totp.verify(username, code); err != nil {
+				l.Debugln("Second factor:", err)
+				emitLoginAttempt(false, username, r)
+				error()
+				return
+			}
+		}
+
+		createSession(cookieName, username, role, w)
+		emitLoginAttempt(true, username, r)
+		next.ServeHTTP(w, withRole(r, role))
+	})
+}
+
+// lookupSession returns the session of the request, and whether there is
+// one.
+func lookupSession(cookieName string, r *http.Request) (session, bool) {
+	cookie, err := r.Cookie(cookieName)
+	if err != nil || cookie == nil {
+		return session{}, false
+	}
+	sessionsMut.Lock()
+	sess, ok := sessions[cookie.Value]
+	sessionsMut.Unlock()
+	return sess, ok
+}
+
+// sessionUser returns the user logged in with the session of the request,
+// and whether there is a session.
+func sessionUser(cookieName string, r *http.Request) (string, bool) {
+	sess, ok := lookupSession(cookieName, r)
+	return sess.username, ok
+}
+
+func createSession(cookieName, username string, role config.GUIRole, w http.ResponseWriter) {
+	sessionid := rand.String(32)
+	sessionsMut.Lock()
+	sessions[sessionid] = session{username: username, role: role}
+	sessionsMut.Unlock()
+	http.SetCookie(w, &http.Cookie{
+		Name:   cookieName,
+		Value:  sessionid,
+		MaxAge: 0,
 	})
 }
 
-func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) bool {
+// auth returns the role of the user, and whether the password is theirs.
+func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) (config.GUIRole, bool) {
 	if guiCfg.AuthMode == config.AuthModeLDAP {
 		return authLDAP(username, password, ldapCfg)
-	} else {
-		return authStatic(username, password, guiCfg.User, guiCfg.Password)
 	}
+	if authStatic(username, password, guiCfg.User, guiCfg.Password) {
+		return config.GUIRoleAdmin, true
+	}
+	for _, user := range guiCfg.Users {
+		if authStatic(username, password, user.Name, user.Password) {
+			return user.Role, true
+		}
+	}
+	return 0, false
 }
 
 func authStatic(username string, password string, configUser string, configPassword string) bool {
@@ -128,7 +177,13 @@ func authStatic(username string, password string, configUser string, configPassw
 	return bcrypt.CompareHashAndPassword(configPasswordBytes, passwordBytes) == nil && username == configUser
 }
 
-func authLDAP(username string, password string, cfg config.LDAPConfiguration) bool {
+// The search filter for the user, when none is configured, for both
+// OpenLDAP and Active Directory.
+const defaultLDAPSearchFilter = "(|(uid=%s)(sAMAccountName=%s))"
+
+// authLDAP returns the role of the user, by the groups they are a member
+// of, and whether they could bind with the password.
+func authLDAP(username string, password string, cfg config.LDAPConfiguration) (config.GUIRole, bool) {
 	address := cfg.Address
 	var connection *ldap.Conn
 	var err error
@@ -140,14 +195,14 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 
 	if err != nil {
 		l.Warnln("LDAP Dial:", err)
-		return false
+		return 0, false
 	}
 
 	if cfg.Transport == config.LDAPTransportStartTLS {
 		err = connection.StartTLS(&tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify})
 		if err != nil {
 			l.Warnln("LDAP Start TLS:", err)
-			return false
+			return 0, false
 		}
 	}
 
@@ -156,10 +211,56 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 	err = connection.Bind(fmt.Sprintf(cfg.BindDN, username), password)
 	if err != nil {
 		l.Warnln("LDAP Bind:", err)
-		return false
+		return 0, false
+	}
+
+	if len(cfg.GroupRoles) == 0 {
+		return config.GUIRoleAdmin, true
+	}
+	groups, err := ldapGroups(connection, username, cfg)
+	if err != nil {
+		l.Warnln("LDAP Search:", err)
+		return 0, false
+	}
+	return ldapRole(groups, cfg.GroupRoles)
+}
+
+// ldapGroups returns the DNs of the groups the user is a member of, by the
+// memberOf attribute of their entry.
+func ldapGroups(connection *ldap.Conn, username string, cfg config.LDAPConfiguration) ([]string, error) {
+	if cfg.SearchBaseDN == "" {
+		return nil, errors.New("no search base DN to look up the groups of users")
+	}
+	filter := cfg.SearchFilter
+	if filter == "" {
+		filter = defaultLDAPSearchFilter
+	}
+	filter = strings.Replace(filter, "%s", ldap.EscapeFilter(username), -1)
+
+	req := ldap.NewSearchRequest(cfg.SearchBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, filter, []string{"memberOf"}, nil)
+	res, err := connection.Search(req)
+	if err != nil {
+		return nil, err
 	}
+	if len(res.Entries) != 1 {
+		return nil, fmt.Errorf("%d entries found for user %q, expected one", len(res.Entries), username)
+	}
+	return res.Entries[0].GetAttributeValues("memberOf"), nil
+}
 
-	return true
+// ldapRole returns the most privileged role any of the groups has, and
+// whether any of them has one.
+func ldapRole(groups []string, groupRoles []config.LDAPGroupRole) (config.GUIRole, bool) {
+	role, ok := config.GUIRoleViewer, false
+	for _, group := range groups {
+		for _, gr := range groupRoles {
+			// DNs are case insensitive
+			if strings.EqualFold(group, gr.Group) && (!ok || gr.Role > role) {
+				role, ok = gr.Role, true
+			}
+		}
+	}
+	return role, ok
 }
 
 // Convert an ISO-8859-1 encoded byte string to UTF-8. Works by the
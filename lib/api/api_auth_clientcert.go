@@ -0,0 +1,108 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+var errClientCertNotAllowed = errors.New("client certificate not allowed")
+
+// setupClientCertAuth makes the TLS listener ask for client certificates
+// when client certificate authentication is configured. Certificates that
+// match neither a configured fingerprint nor the configured CA fail the
+// handshake, so any client certificate on a request is a valid one.
+func setupClientCertAuth(tlsCfg *tls.Config, guiCfg config.GUIConfiguration) error {
+	if !guiCfg.IsClientCertAuthEnabled() {
+		return nil
+	}
+
+	fingerprints := make(map[string]struct{}, len(guiCfg.ClientCertFingerprints))
+	for _, fp := range guiCfg.ClientCertFingerprints {
+		fingerprints[normalizeFingerprint(fp)] = struct{}{}
+	}
+
+	var roots *x509.CertPool
+	if guiCfg.ClientCAFile != "" {
+		bs, err := ioutil.ReadFile(guiCfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CA: %v", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(bs) {
+			return fmt.Errorf("loading client CA: no certificates in %s", guiCfg.ClientCAFile)
+		}
+	}
+
+	tlsCfg.ClientAuth = tls.RequestClientCert
+	tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			// No certificate; the client authenticates some other way.
+			return nil
+		}
+		return verifyClientCert(rawCerts, fingerprints, roots)
+	}
+	return nil
+}
+
+func verifyClientCert(rawCerts [][]byte, fingerprints map[string]struct{}, roots *x509.CertPool) error {
+	sum := sha256.Sum256(rawCerts[0])
+	if _, ok := fingerprints[hex.EncodeToString(sum[:])]; ok {
+		return nil
+	}
+	if roots == nil {
+		return errClientCertNotAllowed
+	}
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		l.Debugln("client certificate:", err)
+		return errClientCertNotAllowed
+	}
+	return nil
+}
+
+// normalizeFingerprint accepts SHA-256 fingerprints in either case and with
+// or without colons, as printed by "openssl x509 -fingerprint -sha256".
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+}
+
+// hasValidAPICredentials returns true for requests carrying a valid API key
+// or client certificate, which bypass login and CSRF checks.
+func hasValidAPICredentials(guiCfg config.GUIConfiguration, r *http.Request) bool {
+	if guiCfg.IsValidAPIKey(r.Header.Get("X-API-Key")) {
+		return true
+	}
+	return guiCfg.IsClientCertAuthEnabled() && r.TLS != nil && len(r.TLS.PeerCertificates) > 0
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+// newTestCert returns a certificate signed by the parent, or self signed if
+// the parent is nil.
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestClientCertAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-clientcert-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newTestCert(t, "ca", true, nil, nil)
+	signed, _ := newTestCert(t, "signed", false, ca, caKey)
+	pinned, _ := newTestCert(t, "pinned", false, nil, nil)
+	other, _ := newTestCert(t, "other", false, nil, nil)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fingerprint as printed by openssl
+	sum := sha256.Sum256(pinned.Raw)
+	var parts []string
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+
+	guiCfg := config.GUIConfiguration{
+		ClientCertFingerprints: []string{strings.Join(parts, ":")},
+		ClientCAFile:           caFile,
+	}
+	tlsCfg := &tls.Config{}
+	if err := setupClientCertAuth(tlsCfg, guiCfg); err != nil {
+		t.Fatal(err)
+	}
+	if tlsCfg.ClientAuth != tls.RequestClientCert {
+		t.Fatal("Expected client certificates to be requested")
+	}
+
+	cases := []struct {
+		name  string
+		certs [][]byte
+		ok    bool
+	}{
+		{"no certificate", nil, true},
+		{"pinned", [][]byte{pinned.Raw}, true},
+		{"signed by CA", [][]byte{signed.Raw}, true},
+		{"other", [][]byte{other.Raw}, false},
+	}
+	for _, tc := range cases {
+		if err := tlsCfg.VerifyPeerCertificate(tc.certs, nil); (err == nil) != tc.ok {
+			t.Errorf("%s: unexpected result %v", tc.name, err)
+		}
+	}
+
+	if err := setupClientCertAuth(&tls.Config{}, config.GUIConfiguration{ClientCAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+
+	// Requests with a certificate get past the login
+	guiCfg.User = "user"
+	guiCfg.Password = string(passwordHashBytes)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := basicAuthAndSessionMiddleware("sessionid-test", guiCfg, config.LDAPConfiguration{}, nil, next)
+
+	req := httptest.NewRequest("GET", "/rest/system/status", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Error("Expected request without certificate to be unauthorized, got", rec.Code)
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{pinned}}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Error("Expected request with certificate to be let through, got", rec.Code)
+	}
+}
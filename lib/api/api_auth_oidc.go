@@ -0,0 +1,647 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // for crypto.SHA256
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	oidcCallbackPath    = "/oidc/callback"
+	oidcLoginTimeout    = 10 * time.Minute // to complete a login at the provider
+	oidcMaxPendingLogin = 1000
+	oidcClockSkew       = time.Minute
+	oidcKeysMinAge      = time.Minute // before fetching the keys again for an unknown key ID
+	oidcRequestTimeout  = 30 * time.Second
+)
+
+var (
+	errOIDCInvalidToken  = errors.New("malformed ID token")
+	errOIDCUnknownKey    = errors.New("ID token signed with unknown key")
+	errOIDCBadSignature  = errors.New("invalid ID token signature")
+	errOIDCGroupMismatch = errors.New("user is not a member of an allowed group")
+)
+
+// oidcAuthenticator logs users in using the OpenID Connect authorization
+// code flow, and verifies the ID tokens the provider issues.
+type oidcAuthenticator struct {
+	cfg    config.OIDCConfiguration
+	client *http.Client
+
+	mut         sync.Mutex
+	provider    *oidcProviderMetadata
+	keys        map[string]crypto.PublicKey // key ID -> key
+	keysFetched time.Time
+	pending     map[string]oidcLogin // state -> login in progress
+}
+
+// oidcProviderMetadata is the part of the provider's discovery document we
+// need.
+type oidcProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcLogin struct {
+	nonce       string
+	redirectURL string // our callback, which must be repeated when exchanging the code
+	returnTo    string // where the user was going when the login started
+	started     time.Time
+}
+
+type oidcClaims map[string]interface{}
+
+func newOIDCAuthenticator(cfg config.OIDCConfiguration) *oidcAuthenticator {
+	return &oidcAuthenticator{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: oidcRequestTimeout},
+		mut:     sync.NewMutex(),
+		keys:    make(map[string]crypto.PublicKey),
+		pending: make(map[string]oidcLogin),
+	}
+}
+
+// oidcAndSessionMiddleware lets through requests with a valid API key or
+// client certificate, session cookie or ID token in a bearer authorization header. Other
+// requests for the GUI are sent to the provider to log in, and REST
+// requests are rejected.
+func oidcAndSessionMiddleware(cookieName string, guiCfg config.GUIConfiguration, auth *oidcAuthenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasValidAPICredentials(guiCfg, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if sess, ok := lookupSession(cookieName, r); ok {
+			next.ServeHTTP(w, withRole(r, sess.role))
+			return
+		}
+
+		if r.URL.Path == oidcCallbackPath {
+			auth.callback(cookieName, w, r)
+			return
+		}
+
+		if hdr := r.Header.Get("Authorization"); strings.HasPrefix(hdr, "Bearer ") {
+			claims, err := auth.verify(hdr[7:], "")
+			if err == nil {
+				err = auth.checkGroups(claims)
+			}
+			if err != nil {
+				l.Debugln("OIDC bearer token:", err)
+				emitLoginAttempt(false, claims.username(), r)
+				http.Error(w, "Not Authorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, withRole(r, auth.role(claims)))
+			return
+		}
+
+		if r.Method != "GET" || strings.HasPrefix(r.URL.Path, "/rest/") {
+			http.Error(w, "Not Authorized", http.StatusUnauthorized)
+			return
+		}
+
+		auth.login(w, r)
+	})
+}
+
+// login redirects the user to the provider's authorization endpoint.
+func (a *oidcAuthenticator) login(w http.ResponseWriter, r *http.Request) {
+	provider, err := a.metadata()
+	if err != nil {
+		l.Warnln("OIDC provider discovery:", err)
+		http.Error(w, "Login provider unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	state := rand.String(32)
+	login := oidcLogin{
+		nonce:       rand.String(32),
+		redirectURL: a.redirectURL(r),
+		returnTo:    localReturnTo(r.URL.RequestURI()),
+		started:     time.Now(),
+	}
+
+	a.mut.Lock()
+	for s, pending := range a.pending {
+		if time.Since(pending.started) > oidcLoginTimeout {
+			delete(a.pending, s)
+		}
+	}
+	if len(a.pending) >= oidcMaxPendingLogin {
+		a.mut.Unlock()
+		http.Error(w, "Too many logins in progress", http.StatusServiceUnavailable)
+		return
+	}
+	a.pending[state] = login
+	a.mut.Unlock()
+
+	scopes := append([]string{"openid", "profile", "email"}, a.cfg.Scopes...)
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {a.cfg.ClientID},
+		"redirect_uri":  {login.redirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+		"nonce":         {login.nonce},
+	}
+	target := provider.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + params.Encode()
+	} else {
+		target += "?" + params.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// callback completes a login when the provider redirects the user back to
+// us, and starts a session if the user is allowed in.
+func (a *oidcAuthenticator) callback(cookieName string, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		l.Infof("OIDC login failed: %s: %s", e, q.Get("error_description"))
+		emitLoginAttempt(false, "", r)
+		http.Error(w, "Login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	a.mut.Lock()
+	login, ok := a.pending[q.Get("state")]
+	delete(a.pending, q.Get("state"))
+	a.mut.Unlock()
+	if !ok || time.Since(login.started) > oidcLoginTimeout {
+		http.Error(w, "Unknown or expired login", http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := a.exchange(q.Get("code"), login.redirectURL)
+	if err != nil {
+		l.Infoln("OIDC token exchange:", err)
+		emitLoginAttempt(false, "", r)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := a.verify(idToken, login.nonce)
+	if err != nil {
+		l.Infoln("OIDC ID token:", err)
+		emitLoginAttempt(false, "", r)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	username := claims.username()
+	if err := a.checkGroups(claims); err != nil {
+		l.Infof("OIDC login for %s: %v", username, err)
+		emitLoginAttempt(false, username, r)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	createSession(cookieName, username, a.role(claims), w)
+	emitLoginAttempt(true, username, r)
+	http.Redirect(w, r, localReturnTo(login.returnTo), http.StatusFound)
+}
+
+// localReturnTo returns the path to send the user to after login, if it's
+// one of ours, or else the root. Browsers take paths starting with // or
+// /\ to be on another host.
+func localReturnTo(uri string) string {
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") || strings.HasPrefix(uri, "/\\") {
+		return "/"
+	}
+	return uri
+}
+
+func (a *oidcAuthenticator) redirectURL(r *http.Request) string {
+	if a.cfg.RedirectURL != "" {
+		return a.cfg.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+// exchange redeems the authorization code for an ID token at the
+// provider's token endpoint.
+func (a *oidcAuthenticator) exchange(code, redirectURL string) (string, error) {
+	provider, err := a.metadata()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURL},
+		"client_id":    {a.cfg.ClientID},
+	}
+	req, err := http.NewRequest("POST", provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+
+	var res struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := a.doJSON(req, &res); err != nil {
+		return "", err
+	}
+	if res.IDToken == "" {
+		return "", errors.New("no ID token in response")
+	}
+	return res.IDToken, nil
+}
+
+// verify checks the signature, issuer, audience and expiry of the ID token
+// and returns its claims. The nonce is checked unless empty.
+func (a *oidcAuthenticator) verify(token, nonce string) (oidcClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errOIDCInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	var claims oidcClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errOIDCInvalidToken
+	}
+
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	provider, err := a.metadata()
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != provider.Issuer {
+		return nil, fmt.Errorf("ID token issued by %q, not %q", iss, provider.Issuer)
+	}
+	if !claims.hasAudience(a.cfg.ClientID) {
+		return nil, errors.New("ID token not issued for us")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Now().Add(-oidcClockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("ID token expired")
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// checkGroups returns nil if the claims show membership of one of the
+// allowed groups, or no groups are configured.
+func (a *oidcAuthenticator) checkGroups(claims oidcClaims) error {
+	if len(a.cfg.AllowedGroups) == 0 {
+		return nil
+	}
+	for _, g := range claims.groups(a.cfg.GroupsClaim) {
+		for _, allowed := range a.cfg.AllowedGroups {
+			if g == allowed {
+				return nil
+			}
+		}
+	}
+	return errOIDCGroupMismatch
+}
+
+// role returns the most privileged role any of the user's groups has, the
+// viewer role if none has one, or the admin role if no group roles are
+// configured, the way LDAP group roles work.
+func (a *oidcAuthenticator) role(claims oidcClaims) config.GUIRole {
+	if len(a.cfg.GroupRoles) == 0 {
+		return config.GUIRoleAdmin
+	}
+	role := config.GUIRoleViewer
+	for _, g := range claims.groups(a.cfg.GroupsClaim) {
+		for _, gr := range a.cfg.GroupRoles {
+			if g == gr.Group && gr.Role > role {
+				role = gr.Role
+			}
+		}
+	}
+	return role
+}
+
+// metadata returns the provider's discovery document, fetching it the
+// first time.
+func (a *oidcAuthenticator) metadata() (*oidcProviderMetadata, error) {
+	a.mut.Lock()
+	provider := a.provider
+	a.mut.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	issuer := strings.TrimSuffix(a.cfg.Issuer, "/")
+	req, err := http.NewRequest("GET", issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	provider = new(oidcProviderMetadata)
+	if err := a.doJSON(req, provider); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("provider claims to be %q, not %q", provider.Issuer, a.cfg.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("incomplete provider configuration")
+	}
+
+	a.mut.Lock()
+	a.provider = provider
+	a.mut.Unlock()
+	return provider, nil
+}
+
+// key returns the provider's public key with the given ID. The keys are
+// fetched again when an unknown one is asked for, as the provider may have
+// rotated them.
+func (a *oidcAuthenticator) key(kid string) (crypto.PublicKey, error) {
+	a.mut.Lock()
+	key, ok := a.lookupKeyLocked(kid)
+	stale := time.Since(a.keysFetched) > oidcKeysMinAge
+	a.mut.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, errOIDCUnknownKey
+	}
+
+	provider, err := a.metadata()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", provider.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := a.doJSON(req, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			l.Debugf("OIDC key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.keys = keys
+	a.keysFetched = time.Now()
+	if key, ok := a.lookupKeyLocked(kid); ok {
+		return key, nil
+	}
+	return nil, errOIDCUnknownKey
+}
+
+// lookupKeyLocked finds the key with the given ID. Tokens without a key ID
+// are accepted when the provider has only one key.
+func (a *oidcAuthenticator) lookupKeyLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+func (a *oidcAuthenticator) doJSON(req *http.Request, into interface{}) error {
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	return json.Unmarshal(bs, into)
+}
+
+func (c oidcClaims) hasAudience(clientID string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groups returns the groups listed in the claim, "groups" if empty.
+func (c oidcClaims) groups(claim string) []string {
+	if claim == "" {
+		claim = "groups"
+	}
+	var groups []string
+	switch v := c[claim].(type) {
+	case string:
+		groups = []string{v}
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	return groups
+}
+
+// username returns the most readable name for the user available.
+func (c oidcClaims) username() string {
+	for _, claim := range []string{"preferred_username", "email", "sub"} {
+		if s, ok := c[claim].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// A jsonWebKey is a public key as published in the provider's JWKS
+// document, see RFC 7517.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(bs) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(bs), nil
+}
+
+func decodeJWTPart(s string, into interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return errOIDCInvalidToken
+	}
+	if err := json.Unmarshal(bs, into); err != nil {
+		return errOIDCInvalidToken
+	}
+	return nil
+}
+
+// verifyJWTSignature checks a JWS signature made with one of the RSA or
+// ECDSA algorithms of RFC 7518. Symmetric and unsigned tokens are rejected.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' || rsa.VerifyPKCS1v15(key, hash, digest, sig) != nil {
+			return errOIDCBadSignature
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(sig) != 2*size {
+			return errOIDCBadSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errOIDCBadSignature
+		}
+		return nil
+
+	default:
+		return errOIDCBadSignature
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+// fakeOIDCProvider issues ID tokens for a user in the given groups for any
+// authorization code.
+type fakeOIDCProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	groups []string
+	nonce  string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/auth",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "key1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "syncthing" || pass != "secret" || r.FormValue("code") != "thecode" {
+			http.Error(w, "invalid_client", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token": p.token(t, "syncthing", time.Hour),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *fakeOIDCProvider) token(t *testing.T, aud string, valid time.Duration) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key1"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":                p.URL,
+		"aud":                aud,
+		"sub":                "1234",
+		"preferred_username": "jb",
+		"exp":                time.Now().Add(valid).Unix(),
+		"nonce":              p.nonce,
+		"groups":             p.groups,
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	defer provider.Close()
+	provider.groups = []string{"staff", "syncthing-admins"}
+
+	auth := newOIDCAuthenticator(config.OIDCConfiguration{
+		Issuer:        provider.URL,
+		ClientID:      "syncthing",
+		ClientSecret:  "secret",
+		AllowedGroups: []string{"syncthing-admins"},
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	handler := oidcAndSessionMiddleware("sessionid-test", config.GUIConfiguration{}, auth, next)
+	do := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// REST requests are rejected outright
+	if rec := do(httptest.NewRequest("GET", "/rest/system/status", nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatal("Expected unauthorized REST request, got", rec.Code)
+	}
+
+	// GUI requests are sent to the provider
+	rec := do(httptest.NewRequest("GET", "http://syncthing.local/", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatal("Expected redirect to provider, got", rec.Code)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := loc.Query()
+	if !strings.HasPrefix(loc.String(), provider.URL+"/auth?") || q.Get("client_id") != "syncthing" || q.Get("redirect_uri") != "http://syncthing.local"+oidcCallbackPath {
+		t.Fatal("Unexpected redirect:", loc)
+	}
+
+	// An unknown state is rejected
+	if rec := do(httptest.NewRequest("GET", oidcCallbackPath+"?code=thecode&state=wrong", nil)); rec.Code != http.StatusBadRequest {
+		t.Error("Expected bad request for unknown state, got", rec.Code)
+	}
+
+	// The provider redirects back with a code, which gets us a session
+	provider.nonce = q.Get("nonce")
+	rec = do(httptest.NewRequest("GET", oidcCallbackPath+"?code=thecode&state="+q.Get("state"), nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+		t.Fatal("Expected redirect back to the GUI, got", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sessionid-test" {
+		t.Fatal("Expected session cookie, got", cookies)
+	}
+	req := httptest.NewRequest("GET", "/rest/system/status", nil)
+	req.AddCookie(cookies[0])
+	if rec := do(req); rec.Code != http.StatusOK {
+		t.Error("Expected session to be accepted, got", rec.Code)
+	}
+
+	// The state can't be reused
+	if rec := do(httptest.NewRequest("GET", oidcCallbackPath+"?code=thecode&state="+q.Get("state"), nil)); rec.Code != http.StatusBadRequest {
+		t.Error("Expected bad request for reused state, got", rec.Code)
+	}
+
+	// ID tokens are accepted as bearer tokens for the REST API
+	req = httptest.NewRequest("GET", "/rest/system/status", nil)
+	req.Header.Set("Authorization", "Bearer "+provider.token(t, "syncthing", time.Hour))
+	if rec := do(req); rec.Code != http.StatusOK {
+		t.Error("Expected bearer token to be accepted, got", rec.Code)
+	}
+
+	// Users not in an allowed group are rejected
+	provider.groups = []string{"staff"}
+	rec = do(httptest.NewRequest("GET", "http://syncthing.local/", nil))
+	loc, _ = url.Parse(rec.Header().Get("Location"))
+	provider.nonce = loc.Query().Get("nonce")
+	rec = do(httptest.NewRequest("GET", oidcCallbackPath+"?code=thecode&state="+loc.Query().Get("state"), nil))
+	if rec.Code != http.StatusForbidden || len(rec.Result().Cookies()) != 0 {
+		t.Error("Expected forbidden login, got", rec.Code)
+	}
+}
+
+func TestOIDCVerify(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	defer provider.Close()
+	provider.nonce = "thenonce"
+
+	auth := newOIDCAuthenticator(config.OIDCConfiguration{
+		Issuer:   provider.URL,
+		ClientID: "syncthing",
+	})
+
+	good := provider.token(t, "syncthing", time.Hour)
+	if claims, err := auth.verify(good, "thenonce"); err != nil || claims.username() != "jb" {
+		t.Fatal("Expected valid token, got", claims, err)
+	}
+
+	parts := strings.Split(good, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+provider.URL+`","aud":"syncthing","exp":9999999999}`)) + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	cases := []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"wrong nonce", good, "othernonce"},
+		{"wrong audience", provider.token(t, "someone-else", time.Hour), ""},
+		{"expired", provider.token(t, "syncthing", -time.Hour), ""},
+		{"tampered", tampered, ""},
+		{"unsigned", unsigned, ""},
+		{"garbage", "foo.bar", ""},
+	}
+	for _, tc := range cases {
+		if _, err := auth.verify(tc.token, tc.nonce); err == nil {
+			t.Errorf("%s: expected verification to fail", tc.name)
+		}
+	}
+}
+
+func TestOIDCRoles(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	defer provider.Close()
+
+	auth := newOIDCAuthenticator(config.OIDCConfiguration{
+		Issuer:     provider.URL,
+		ClientID:   "syncthing",
+		GroupRoles: []config.OIDCGroupRole{{Group: "syncthing-admins", Role: config.GUIRoleAdmin}},
+	})
+	var role config.GUIRole
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role = requestRole(r)
+	})
+	handler := oidcAndSessionMiddleware("sessionid-test", config.GUIConfiguration{}, auth, next)
+
+	for _, tc := range []struct {
+		groups []string
+		role   config.GUIRole
+	}{
+		{[]string{"staff", "syncthing-admins"}, config.GUIRoleAdmin},
+		{[]string{"staff"}, config.GUIRoleViewer},
+		{nil, config.GUIRoleViewer},
+	} {
+		provider.groups = tc.groups
+		req := httptest.NewRequest("GET", "/rest/system/status", nil)
+		req.Header.Set("Authorization", "Bearer "+provider.token(t, "syncthing", time.Hour))
+		role = -1
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || role != tc.role {
+			t.Errorf("Groups %v: got %d as %v, expected %v", tc.groups, rec.Code, role, tc.role)
+		}
+	}
+}
+
+func TestOIDCReturnTo(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"/":                    "/",
+		"/#settings":           "/#settings",
+		"/rest/x?a=b":          "/rest/x?a=b",
+		"//evil.example":       "/",
+		"/\\evil.example":      "/",
+		"http://evil.example/": "/",
+		"":                     "/",
+	} {
+		if got := localReturnTo(uri); got != expected {
+			t.Errorf("localReturnTo(%q) = %q, expected %q", uri, got, expected)
+		}
+	}
+
+	// A login started at a path that looks like another host returns to
+	// the root.
+	provider := newFakeOIDCProvider(t)
+	defer provider.Close()
+	auth := newOIDCAuthenticator(config.OIDCConfiguration{
+		Issuer:       provider.URL,
+		ClientID:     "syncthing",
+		ClientSecret: "secret",
+	})
+	handler := oidcAndSessionMiddleware("sessionid-test", config.GUIConfiguration{}, auth, http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "http://syncthing.local//evil.example", nil))
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.nonce = loc.Query().Get("nonce")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", oidcCallbackPath+"?code=thecode&state="+loc.Query().Get("state"), nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+		t.Error("Expected redirect to the root, got", rec.Code, rec.Header().Get("Location"))
+	}
+}
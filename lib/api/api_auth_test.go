@@ -9,6 +9,7 @@ package api
 import (
 	"testing"
 
+	"github.com/syncthing/syncthing/lib/config"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -38,3 +39,31 @@ func TestStaticAuthPasswordFail(t *testing.T) {
 		t.Fatalf("should fail auth")
 	}
 }
+
+func TestLDAPRole(t *testing.T) {
+	groupRoles := []config.LDAPGroupRole{
+		{Group: "cn=staff,dc=example,dc=com", Role: config.GUIRoleViewer},
+		{Group: "cn=ops,dc=example,dc=com", Role: config.GUIRoleOperator},
+		{Group: "cn=admins,dc=example,dc=com", Role: config.GUIRoleAdmin},
+	}
+
+	cases := []struct {
+		groups []string
+		role   config.GUIRole
+		ok     bool
+	}{
+		{nil, 0, false},
+		{[]string{"cn=other,dc=example,dc=com"}, 0, false},
+		{[]string{"cn=staff,dc=example,dc=com"}, config.GUIRoleViewer, true},
+		{[]string{"CN=Ops,DC=example,DC=com"}, config.GUIRoleOperator, true},
+		// The most privileged role wins
+		{[]string{"cn=admins,dc=example,dc=com", "cn=staff,dc=example,dc=com"}, config.GUIRoleAdmin, true},
+		{[]string{"cn=staff,dc=example,dc=com", "cn=ops,dc=example,dc=com"}, config.GUIRoleOperator, true},
+	}
+	for _, tc := range cases {
+		role, ok := ldapRole(tc.groups, groupRoles)
+		if ok != tc.ok || (ok && role != tc.role) {
+			t.Errorf("%v: expected %v %v, got %v %v", tc.groups, tc.role, tc.ok, role, ok)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto/hmac"
+	cryptoRand "crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TOTP parameters, as per RFC 6238 and what authenticator apps expect.
+const (
+	totpDigits        = 6
+	totpPeriod        = 30 // seconds
+	totpSkew          = 1  // periods of clock difference accepted either way
+	totpSecretLen     = 20 // bytes
+	totpRecoveryCodes = 10
+	totpReuseWindow   = 10 * time.Second // how long an accepted code may be reused by the same user
+)
+
+var (
+	// Recovery codes look like "7g3kq-xm2vd", so they can be told apart
+	// from a TOTP code when appended to the password.
+	recoveryCodeExp = regexp.MustCompile(`[` + randomCharsetExp + `]{5}-[` + randomCharsetExp + `]{5}$`)
+	totpCodeExp     = regexp.MustCompile(`[0-9]{6}$`)
+	totpEncoding    = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	errTOTPNotEnabled  = errors.New("second factor is not enabled")
+	errTOTPNoSetup     = errors.New("no second factor setup in progress")
+	errTOTPInvalidCode = errors.New("invalid code")
+)
+
+// The characters rand.String uses.
+const randomCharsetExp = "2-9a-zA-Z"
+
+// totpAuthenticator checks the TOTP code or recovery code that is required
+// in addition to the password when the second factor is enabled. The code
+// can be given in the X-TOTP-Code header or appended to the password, as
+// the browser's login dialog has no field for it.
+type totpAuthenticator struct {
+	cfg config.Wrapper
+
+	mut       sync.Mutex
+	pending   string                // secret being set up, until confirmed by a code
+	lastSteps map[string]int64      // user -> the last time step accepted for them, so codes can't be replayed
+	accepted  map[totpUse]time.Time // recently accepted codes, until when they may be reused
+}
+
+// A totpUse is a time step code accepted for a user.
+type totpUse struct {
+	user string
+	step int64
+}
+
+func newTOTPAuthenticator(cfg config.Wrapper) *totpAuthenticator {
+	return &totpAuthenticator{
+		cfg:       cfg,
+		mut:       sync.NewMutex(),
+		lastSteps: make(map[string]int64),
+		accepted:  make(map[totpUse]time.Time),
+	}
+}
+
+func (t *totpAuthenticator) enabled() bool {
+	return t.cfg.TOTP().Enabled()
+}
+
+// splitSecondFactor separates the second factor code from the password,
+// unless it was given in the header.
+func splitSecondFactor(password, header string) (string, string) {
+	if header != "" {
+		return password, header
+	}
+	if code := recoveryCodeExp.FindString(password); code != "" {
+		return strings.TrimSuffix(password, code), code
+	}
+	if code := totpCodeExp.FindString(password); code != "" {
+		return strings.TrimSuffix(password, code), code
+	}
+	return password, ""
+}
+
+// verify returns nil if the code is currently valid for the configured
+// secret, or is an unused recovery code. Recovery codes are used up. A time
+// step code may only be used once by each user, except that they may use it
+// again for a little while, as the browser sends it with all the requests
+// it makes while logging in. Without a user it must be unused.
+func (t *totpAuthenticator) verify(user, code string) error {
+	totp := t.cfg.TOTP()
+	if !totp.Enabled() {
+		return errTOTPNotEnabled
+	}
+
+	if recoveryCodeExp.MatchString(code) && len(code) == 11 {
+		for _, hash := range totp.RecoveryCodes {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+				return t.useRecoveryCode(hash)
+			}
+		}
+		return errTOTPInvalidCode
+	}
+
+	step, ok := checkTOTPCode(totp.Secret, code, time.Now())
+	if !ok {
+		return errTOTPInvalidCode
+	}
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	now := time.Now()
+	for use, until := range t.accepted {
+		if now.After(until) {
+			delete(t.accepted, use)
+		}
+	}
+	use := totpUse{user, step}
+	if _, ok := t.accepted[use]; ok && user != "" {
+		return nil
+	}
+	if step <= t.lastSteps[user] {
+		return errTOTPInvalidCode
+	}
+	for u, last := range t.lastSteps {
+		if last < step-2*totpSkew {
+			// Too old to be accepted again anyway
+			delete(t.lastSteps, u)
+		}
+	}
+	t.lastSteps[user] = step
+	if user != "" {
+		t.accepted[use] = now.Add(totpReuseWindow)
+	}
+	return nil
+}
+
+// useRecoveryCode removes the recovery code, failing if it's already gone
+// because a parallel request used it.
+func (t *totpAuthenticator) useRecoveryCode(hash string) error {
+	wg, ok, err := t.cfg.RemoveTOTPRecoveryCode(hash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errTOTPInvalidCode
+	}
+	wg.Wait()
+	return t.cfg.Save()
+}
+
+// setup generates a new secret, which becomes active once confirmed with a
+// code from it, and returns it together with an otpauth URI for QR codes.
+func (t *totpAuthenticator) setup(account string) (string, string, error) {
+	bs := make([]byte, totpSecretLen)
+	if _, err := cryptoRand.Read(bs); err != nil {
+		return "", "", err
+	}
+	secret := totpEncoding.EncodeToString(bs)
+
+	t.mut.Lock()
+	t.pending = secret
+	t.mut.Unlock()
+
+	if account == "" {
+		account = "syncthing"
+	}
+	params := url.Values{
+		"secret": {secret},
+		"issuer": {"Syncthing"},
+		"digits": {fmt.Sprint(totpDigits)},
+		"period": {fmt.Sprint(totpPeriod)},
+	}
+	uri := fmt.Sprintf("otpauth://totp/%s?%s", url.PathEscape("Syncthing:"+account), params.Encode())
+	return secret, uri, nil
+}
+
+// enable activates the secret being set up if the code is valid for it, and
+// returns a fresh set of recovery codes.
+func (t *totpAuthenticator) enable(code string) ([]string, error) {
+	t.mut.Lock()
+	secret := t.pending
+	t.mut.Unlock()
+	if secret == "" {
+		return nil, errTOTPNoSetup
+	}
+	step, ok := checkTOTPCode(secret, code, time.Now())
+	if !ok {
+		return nil, errTOTPInvalidCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.save(config.TOTPConfiguration{Secret: secret, RecoveryCodes: hashes}); err != nil {
+		return nil, err
+	}
+
+	t.mut.Lock()
+	t.pending = ""
+	t.lastSteps[""] = step
+	t.mut.Unlock()
+	return codes, nil
+}
+
+// disable turns the second factor off, given a valid code.
+func (t *totpAuthenticator) disable(code string) error {
+	if err := t.verify("", code); err != nil {
+		return err
+	}
+	return t.save(config.TOTPConfiguration{})
+}
+
+// regenerateRecoveryCodes replaces the recovery codes, given a valid code.
+func (t *totpAuthenticator) regenerateRecoveryCodes(code string) ([]string, error) {
+	if err := t.verify("", code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	totp := t.cfg.TOTP()
+	totp.RecoveryCodes = hashes
+	if err := t.save(totp); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (t *totpAuthenticator) save(totp config.TOTPConfiguration) error {
+	wg, err := t.cfg.SetTOTP(totp)
+	if err != nil {
+		return err
+	}
+	wg.Wait()
+	return t.cfg.Save()
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, totpRecoveryCodes)
+	hashes := make([]string, totpRecoveryCodes)
+	for i := range codes {
+		codes[i] = strings.ToLower(rand.String(5) + "-" + rand.String(5))
+		hash, err := bcrypt.GenerateFromPassword([]byte(codes[i]), 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes[i] = string(hash)
+	}
+	return codes, hashes, nil
+}
+
+// checkTOTPCode returns the time step the code is valid for, allowing for
+// some clock difference.
+func checkTOTPCode(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	step := now.Unix() / totpPeriod
+	for s := step - totpSkew; s <= step+totpSkew; s++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// hotp returns the HOTP value of RFC 4226 for the counter.
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
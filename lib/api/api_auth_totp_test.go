@@ -0,0 +1,183 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestHOTP(t *testing.T) {
+	// Test vectors from RFC 4226 appendix D
+	key := []byte("12345678901234567890")
+	expected := []string{"755224", "287082", "359152", "969429", "338314"}
+	for i, exp := range expected {
+		if code := hotp(key, int64(i)); code != exp {
+			t.Errorf("counter %d: got %s, expected %s", i, code, exp)
+		}
+	}
+
+	// RFC 6238 appendix B, truncated to six digits
+	secret := totpEncoding.EncodeToString(key)
+	if step, ok := checkTOTPCode(secret, "287082", time.Unix(59, 0)); !ok || step != 1 {
+		t.Error("Expected code to be valid at T=59")
+	}
+	if _, ok := checkTOTPCode(secret, "287082", time.Unix(1111111109, 0)); ok {
+		t.Error("Expected code to be invalid much later")
+	}
+}
+
+func TestSplitSecondFactor(t *testing.T) {
+	cases := []struct {
+		password, header string
+		expPassword      string
+		expCode          string
+	}{
+		{"secret123456", "", "secret", "123456"},
+		{"secret7g3kq-xm2vd", "", "secret", "7g3kq-xm2vd"},
+		{"secret123456", "654321", "secret123456", "654321"},
+		{"secret", "", "secret", ""},
+		{"secret12345", "", "secret12345", ""},
+	}
+	for _, tc := range cases {
+		password, code := splitSecondFactor(tc.password, tc.header)
+		if password != tc.expPassword || code != tc.expCode {
+			t.Errorf("%q, %q: got %q, %q", tc.password, tc.header, password, code)
+		}
+	}
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-totp-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.New(protocol.LocalDeviceID)
+	cfg.GUI.User = "user"
+	cfg.GUI.Password = string(passwordHashBytes)
+	w := config.Wrap(filepath.Join(dir, "config.xml"), cfg)
+	totp := newTOTPAuthenticator(w)
+
+	if _, err := totp.enable("123456"); err != errTOTPNoSetup {
+		t.Fatal("Expected enabling without setup to fail, got", err)
+	}
+
+	secret, uri, err := totp.setup("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri == "" || totp.enabled() {
+		t.Fatal("Expected setup not to enable the second factor yet")
+	}
+	if _, err := totp.enable("000000x"); err != errTOTPInvalidCode {
+		t.Fatal("Expected invalid code to be rejected, got", err)
+	}
+
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now().Unix() / totpPeriod
+	codes, err := totp.enable(hotp(key, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != totpRecoveryCodes || !totp.enabled() || len(w.TOTP().RecoveryCodes) != totpRecoveryCodes {
+		t.Fatal("Expected second factor to be enabled with recovery codes")
+	}
+
+	// Codes can't be replayed
+	if err := totp.verify("", hotp(key, now)); err != errTOTPInvalidCode {
+		t.Error("Expected replayed code to be rejected, got", err)
+	}
+	if err := totp.verify("user", hotp(key, now+1)); err != nil {
+		t.Error("Expected next code to be accepted, got", err)
+	}
+
+	// Except by the same user, shortly after, as in parallel requests
+	if err := totp.verify("user", hotp(key, now+1)); err != nil {
+		t.Error("Expected code to be accepted again for the same user, got", err)
+	}
+	totp.mut.Lock()
+	totp.accepted = make(map[totpUse]time.Time)
+	totp.mut.Unlock()
+	if err := totp.verify("user", hotp(key, now+1)); err != errTOTPInvalidCode {
+		t.Error("Expected code to be rejected again for the same user later, got", err)
+	}
+
+	// Other users logging in at the same time have the same code, which
+	// they may use once too
+	if err := totp.verify("other", hotp(key, now+1)); err != nil {
+		t.Error("Expected code to be accepted for another user, got", err)
+	}
+	if err := totp.verify("", hotp(key, now+1)); err != nil {
+		t.Error("Expected code to be accepted without a user, got", err)
+	}
+	if err := totp.verify("", hotp(key, now+1)); err != errTOTPInvalidCode {
+		t.Error("Expected code to be rejected again without a user, got", err)
+	}
+
+	// Recovery codes are used up
+	if err := totp.verify("user", codes[0]); err != nil {
+		t.Error("Expected recovery code to be accepted, got", err)
+	}
+	if err := totp.verify("user", codes[0]); err != errTOTPInvalidCode {
+		t.Error("Expected used recovery code to be rejected, got", err)
+	}
+	if n := len(w.TOTP().RecoveryCodes); n != totpRecoveryCodes-1 {
+		t.Error("Expected one less recovery code, got", n)
+	}
+
+	// Also when used in parallel
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- totp.verify("user", codes[4]) }()
+	}
+	if err1, err2 := <-errs, <-errs; (err1 == nil) == (err2 == nil) {
+		t.Error("Expected the recovery code to be accepted once, got", err1, err2)
+	}
+
+	// Logging in requires the code
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := basicAuthAndSessionMiddleware("sessionid-test", w.GUI(), config.LDAPConfiguration{}, totp, next)
+	login := func(password, header string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetBasicAuth("user", password)
+		if header != "" {
+			req.Header.Set("X-TOTP-Code", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := login("pass", ""); code != http.StatusUnauthorized {
+		t.Error("Expected login without code to fail, got", code)
+	}
+	if code := login("pass"+codes[1], ""); code != http.StatusOK {
+		t.Error("Expected login with recovery code to succeed, got", code)
+	}
+	if code := login("pass", codes[2]); code != http.StatusOK {
+		t.Error("Expected login with code in header to succeed, got", code)
+	}
+	if code := login("wrong"+codes[3], ""); code != http.StatusUnauthorized {
+		t.Error("Expected login with wrong password to fail, got", code)
+	}
+
+	if err := totp.disable(codes[3]); err != nil {
+		t.Fatal(err)
+	}
+	if totp.enabled() || len(w.TOTP().RecoveryCodes) != 0 {
+		t.Error("Expected second factor to be disabled")
+	}
+}
@@ -36,10 +36,10 @@ const maxCsrfTokens = 25
 func csrfMiddleware(unique string, prefix string, cfg config.GUIConfiguration, next http.Handler) http.Handler {
 	loadCsrfTokens()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow requests carrying a valid API key
-		if cfg.IsValidAPIKey(r.Header.Get("X-API-Key")) {
+		// Allow requests carrying a valid API key or client certificate
+		if hasValidAPICredentials(cfg, r) {
 			// Set the access-control-allow-origin header for CORS requests
-			// since a valid API key has been provided
+			// since valid credentials have been provided
 			w.Header().Add("Access-Control-Allow-Origin", "*")
 			next.ServeHTTP(w, r)
 			return
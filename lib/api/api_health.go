@@ -0,0 +1,113 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// healthMiddleware serves /healthz and /readyz ahead of authentication,
+// for load balancers and Kubernetes probes. /healthz answers as long as
+// the process does, /readyz only when the database, the folders and the
+// listeners are operational.
+func (s *service) healthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			sendJSON(w, map[string]string{"status": "ok"})
+		case "/readyz":
+			s.getReadyz(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *service) getReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]error{
+		"database":  s.model.DatabaseError(),
+		"folders":   s.foldersReady(),
+		"listeners": s.listenersReady(),
+	}
+
+	// The endpoint is unauthenticated, so the details of why a check
+	// fails, such as folder names, go to the log only.
+	res := map[string]interface{}{"status": "ok"}
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+	for name, err := range checks {
+		s.logReadyz(name, err)
+		if err != nil {
+			results[name] = "unavailable"
+			res["status"] = "unavailable"
+			status = http.StatusServiceUnavailable
+		} else {
+			results[name] = "ok"
+		}
+	}
+	res["checks"] = results
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// logReadyz logs the result of the readiness check when it changes, so
+// that frequent probes don't flood the log.
+func (s *service) logReadyz(name string, err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	s.readyzMut.Lock()
+	defer s.readyzMut.Unlock()
+	if s.readyzErrors[name] == msg {
+		return
+	}
+	s.readyzErrors[name] = msg
+	if err != nil {
+		l.Infof("Readiness check %s failed: %v", name, err)
+	} else {
+		l.Infof("Readiness check %s passed", name)
+	}
+}
+
+// foldersReady returns an error for the first folder, that isn't paused,
+// that isn't running or is in error.
+func (s *service) foldersReady() error {
+	for _, folder := range s.cfg.FolderList() {
+		if folder.Paused {
+			continue
+		}
+		state, _, err := s.model.State(folder.ID)
+		if err != nil {
+			return fmt.Errorf("folder %s: %v", folder.Description(), err)
+		}
+		if state == "" {
+			return fmt.Errorf("folder %s: not running", folder.Description())
+		}
+	}
+	return nil
+}
+
+// listenersReady returns an error when there are listeners and none of
+// them is working.
+func (s *service) listenersReady() error {
+	listeners := s.connectionsService.Status()
+	for _, status := range listeners {
+		if _, ok := status.(map[string]interface{})["error"]; !ok {
+			return nil
+		}
+	}
+	if len(listeners) == 0 {
+		return nil
+	}
+	return errors.New("no working listener")
+}
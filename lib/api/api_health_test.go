@@ -0,0 +1,76 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := new(mockedConfig)
+	cfg.gui.User = "user"
+	cfg.gui.Password = "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq"
+	cfg.gui.HealthEndpoints = true
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The probes need no authentication
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || res.Status != "ok" {
+			t.Errorf("Unexpected %d %q for %s", resp.StatusCode, res.Status, path)
+		}
+		if path == "/readyz" && (res.Checks["database"] != "ok" || res.Checks["folders"] != "ok" || res.Checks["listeners"] != "ok") {
+			t.Error("Unexpected checks", res.Checks)
+		}
+	}
+
+	// Everything else still does
+	resp, err := http.Get(baseURL + "/rest/system/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error("Unexpected status for unauthenticated request", resp.StatusCode)
+	}
+}
+
+func TestHealthEndpointsDisabled(t *testing.T) {
+	cfg := new(mockedConfig)
+	cfg.gui.User = "user"
+	cfg.gui.Password = "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq"
+	baseURL, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(baseURL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error("Unexpected status for disabled health endpoint", resp.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	// A read view not released is released automatically after this
+	// long, so that a client going away doesn't pin database snapshots.
+	readViewTimeout = 30 * time.Second
+	// Creating views beyond this many releases the oldest ones first.
+	maxReadViews = 16
+)
+
+var errNoSuchReadView = errors.New("no such read view, or it expired")
+
+// readViews keeps the read views created through the REST interface, so
+// that several requests can read the same point in time.
+type readViews struct {
+	mut    sync.Mutex
+	views  map[string]*readView
+	serial int // of the last view added
+}
+
+type readView struct {
+	view    *model.ReadView
+	serial  int
+	expires time.Time
+	users   int  // requests currently reading the view
+	expired bool // to be released when the last user is done
+}
+
+func newReadViews() *readViews {
+	return &readViews{
+		mut:   sync.NewMutex(),
+		views: make(map[string]*readView),
+	}
+}
+
+// add keeps the view and returns its ID and expiry.
+func (r *readViews) add(view *model.ReadView) (string, time.Time) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	for len(r.views) >= maxReadViews {
+		var oldest string
+		for id, v := range r.views {
+			if oldest == "" || v.serial < r.views[oldest].serial {
+				oldest = id
+			}
+		}
+		r.removeLocked(oldest)
+	}
+
+	id := rand.String(16)
+	r.serial++
+	rv := &readView{
+		view:    view,
+		serial:  r.serial,
+		expires: time.Now().Add(readViewTimeout),
+	}
+	r.views[id] = rv
+	time.AfterFunc(readViewTimeout, func() {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		if r.views[id] == rv {
+			r.removeLocked(id)
+		}
+	})
+	return id, rv.expires
+}
+
+// acquire returns the view with the given ID, and a function to call when
+// done reading it.
+func (r *readViews) acquire(id string) (*model.ReadView, func(), error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	rv, ok := r.views[id]
+	if !ok {
+		return nil, nil, errNoSuchReadView
+	}
+	rv.users++
+	return rv.view, func() {
+		r.mut.Lock()
+		defer r.mut.Unlock()
+		rv.users--
+		if rv.expired && rv.users == 0 {
+			rv.view.Release()
+		}
+	}, nil
+}
+
+// release releases the view with the given ID, once it's no longer read.
+func (r *readViews) release(id string) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if _, ok := r.views[id]; !ok {
+		return errNoSuchReadView
+	}
+	r.removeLocked(id)
+	return nil
+}
+
+func (r *readViews) removeLocked(id string) {
+	rv := r.views[id]
+	delete(r.views, id)
+	rv.expired = true
+	if rv.users == 0 {
+		rv.view.Release()
+	}
+}
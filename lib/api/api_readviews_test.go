@@ -0,0 +1,53 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+func TestReadViews(t *testing.T) {
+	r := newReadViews()
+
+	view := &model.ReadView{}
+	id, expires := r.add(view)
+	if expires.Before(time.Now().Add(readViewTimeout - time.Second)) {
+		t.Error("Unexpected expiry", expires)
+	}
+
+	got, done, err := r.acquire(id)
+	if err != nil || got != view {
+		t.Fatal("Failed to acquire view:", err)
+	}
+
+	// Released while in use, the view stays readable until done.
+	if err := r.release(id); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	if _, _, err := r.acquire(id); err != errNoSuchReadView {
+		t.Error("Expected errNoSuchReadView, got", err)
+	}
+	if err := r.release(id); err != errNoSuchReadView {
+		t.Error("Expected errNoSuchReadView, got", err)
+	}
+
+	// The oldest views make way for new ones.
+	first, _ := r.add(&model.ReadView{})
+	for i := 0; i < maxReadViews; i++ {
+		r.add(&model.ReadView{})
+	}
+	if len(r.views) != maxReadViews {
+		t.Errorf("Kept %d views, expected %d", len(r.views), maxReadViews)
+	}
+	if _, _, err := r.acquire(first); err != errNoSuchReadView {
+		t.Error("Oldest view should have been released")
+	}
+}
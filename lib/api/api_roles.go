@@ -0,0 +1,161 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+type roleKey struct{}
+
+// withRole returns the request with the role of the user who made it.
+func withRole(r *http.Request, role config.GUIRole) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), roleKey{}, role))
+}
+
+// requestRole returns the role of the user who made the request. Requests
+// without one, made with the API key, a client certificate or without
+// authentication being enabled, are an admin's.
+func requestRole(r *http.Request) config.GUIRole {
+	if role, ok := r.Context().Value(roleKey{}).(config.GUIRole); ok {
+		return role
+	}
+	return config.GUIRoleAdmin
+}
+
+// The REST paths, by prefix, only admins may get. They reveal secrets or
+// more of the system than a dashboard needs.
+var adminGetPaths = []string{
+	"/rest/debug/",
+	"/rest/db/export",
+	"/rest/events/journal",
+	"/rest/system/browse",
+	"/rest/system/config/history",
+	"/rest/system/totp",
+	"/rest/system/trace",
+}
+
+// The REST paths operators may post to, operations that don't change the
+// config or remove data.
+var operatorPostPaths = map[string]bool{
+	"/rest/db/prio":                true,
+	"/rest/db/rehash":              true,
+	"/rest/db/scan":                true,
+	"/rest/db/view":                true,
+	"/rest/db/view/release":        true,
+	"/rest/folder/audit":           true,
+	"/rest/folder/jobs/cancel":     true,
+	"/rest/folder/partials/resume": true,
+	"/rest/system/error/clear":     true,
+	"/rest/system/pause":           true,
+	"/rest/system/ping":            true,
+	"/rest/system/reconnect":       true,
+	"/rest/system/restart":         true,
+	"/rest/system/resume":          true,
+	"/rest/system/warnings/ack":    true,
+	"/rest/system/warnings/snooze": true,
+}
+
+// The REST paths viewers may post to, which don't change anything.
+var viewerPostPaths = map[string]bool{
+	"/rest/db/view":         true,
+	"/rest/db/view/release": true,
+	"/rest/system/ping":     true,
+}
+
+// roleMiddleware rejects the REST requests the role of the user doesn't
+// allow.
+func roleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !roleAllows(requestRole(r), r.Method, r.URL.Path) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func roleAllows(role config.GUIRole, method, path string) bool {
+	if role == config.GUIRoleAdmin || !strings.HasPrefix(path, "/rest/") {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		for _, prefix := range adminGetPaths {
+			if strings.HasPrefix(path, prefix) {
+				return false
+			}
+		}
+		return true
+	}
+	switch role {
+	case config.GUIRoleOperator:
+		return operatorPostPaths[path]
+	case config.GUIRoleViewer:
+		return viewerPostPaths[path]
+	default:
+		return false
+	}
+}
+
+// redactConfig returns the config without the passwords, keys and
+// secrets in it, for users who aren't admins.
+func redactConfig(cfg config.Configuration) config.Configuration {
+	cfg = cfg.Copy()
+	cfg.GUI.Password = ""
+	cfg.GUI.APIKey = ""
+	for i := range cfg.GUI.Users {
+		cfg.GUI.Users[i].Password = ""
+	}
+	cfg.OIDC.ClientSecret = ""
+	cfg.MQTT.Password = ""
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].Secret = ""
+	}
+	for i := range cfg.Devices {
+		cfg.Devices[i].ConfigPushKey = ""
+	}
+	for i := range cfg.Folders {
+		for j := range cfg.Folders[i].Devices {
+			cfg.Folders[i].Devices[j].EncryptionPassword = ""
+		}
+	}
+	return cfg
+}
+
+// redactEvents returns the events, with the configs of ConfigSaved
+// redacted and the changed values of ConfigChanged left out, for users who
+// aren't admins.
+func redactEvents(evs []events.Event, role config.GUIRole) []events.Event {
+	if role == config.GUIRoleAdmin {
+		return evs
+	}
+	res := make([]events.Event, len(evs))
+	for i, ev := range evs {
+		switch data := ev.Data.(type) {
+		case config.Configuration:
+			ev.Data = redactConfig(data)
+		case map[string]interface{}:
+			if ev.Type == events.ConfigChanged {
+				redacted := make(map[string]interface{}, len(data))
+				for k, v := range data {
+					if k != "changes" {
+						redacted[k] = v
+					}
+				}
+				ev.Data = redacted
+			}
+		}
+		res[i] = ev
+	}
+	return res
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestRoleMiddleware(t *testing.T) {
+	// All passwords are bcrypt of "räksmörgås" in UTF-8
+	hash := "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq"
+	guiCfg := config.GUIConfiguration{
+		User:     "admin",
+		Password: hash,
+		APIKey:   "abc123",
+		Users: []config.GUIUserConfiguration{
+			{Name: "operator", Password: hash, Role: config.GUIRoleOperator},
+			{Name: "viewer", Password: hash, Role: config.GUIRoleViewer},
+			{Name: "norole", Password: hash},
+		},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := basicAuthAndSessionMiddleware("sessionid-roles", guiCfg, config.LDAPConfiguration{}, nil, roleMiddleware(next))
+
+	cases := []struct {
+		user   string
+		method string
+		path   string
+		status int
+	}{
+		{"admin", "POST", "/rest/system/config", http.StatusOK},
+		{"admin", "GET", "/rest/debug/support", http.StatusOK},
+		{"operator", "GET", "/rest/system/status", http.StatusOK},
+		{"operator", "POST", "/rest/db/scan", http.StatusOK},
+		{"operator", "POST", "/rest/system/restart", http.StatusOK},
+		{"operator", "POST", "/rest/system/config", http.StatusForbidden},
+		{"operator", "POST", "/rest/system/reset", http.StatusForbidden},
+		{"operator", "POST", "/rest/db/revert", http.StatusForbidden},
+		{"operator", "POST", "/rest/db/override", http.StatusForbidden},
+		{"viewer", "GET", "/", http.StatusOK},
+		{"viewer", "GET", "/rest/db/status", http.StatusOK},
+		{"viewer", "POST", "/rest/db/view", http.StatusOK},
+		{"viewer", "POST", "/rest/db/scan", http.StatusForbidden},
+		{"viewer", "POST", "/rest/folder/decommission", http.StatusForbidden},
+		{"viewer", "GET", "/rest/system/config/history", http.StatusForbidden},
+		{"viewer", "GET", "/rest/debug/support", http.StatusForbidden},
+		{"norole", "GET", "/rest/db/status", http.StatusOK},
+		{"norole", "POST", "/rest/db/scan", http.StatusForbidden},
+		{"norole", "POST", "/rest/system/config", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.SetBasicAuth(tc.user, "räksmörgås")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s %s: expected %d, got %d", tc.user, tc.method, tc.path, tc.status, rec.Code)
+		}
+
+		// The role sticks with the session
+		if tc.status == http.StatusForbidden {
+			cookies := rec.Result().Cookies()
+			req = httptest.NewRequest(tc.method, tc.path, nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("%s %s %s with session: expected %d, got %d", tc.user, tc.method, tc.path, tc.status, rec.Code)
+			}
+		}
+	}
+
+	// The API key is as good as an admin
+	req := httptest.NewRequest("POST", "/rest/system/config", nil)
+	req.Header.Set("X-API-Key", "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Error("Unexpected status with API key", rec.Code)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	cfg := config.Configuration{
+		GUI: config.GUIConfiguration{
+			Password: "hash",
+			APIKey:   "key",
+			Users:    []config.GUIUserConfiguration{{Name: "viewer", Password: "hash"}},
+		},
+		Devices: []config.DeviceConfiguration{{ConfigPushKey: "key"}},
+		Folders: []config.FolderConfiguration{{ID: "folder", Devices: []config.FolderDeviceConfiguration{{EncryptionPassword: "secret"}}}},
+	}
+
+	red := redactConfig(cfg)
+	if red.GUI.Password != "" || red.GUI.APIKey != "" || red.GUI.Users[0].Password != "" || red.Devices[0].ConfigPushKey != "" || red.Folders[0].Devices[0].EncryptionPassword != "" {
+		t.Errorf("Config not redacted: %+v", red)
+	}
+	if cfg.GUI.Users[0].Password != "hash" || cfg.Folders[0].Devices[0].EncryptionPassword != "secret" {
+		t.Error("The original config was changed")
+	}
+
+	evs := []events.Event{{Type: events.ConfigSaved, Data: cfg}}
+	if redactEvents(evs, config.GUIRoleAdmin)[0].Data.(config.Configuration).GUI.APIKey != "key" {
+		t.Error("Config redacted for an admin")
+	}
+	if redactEvents(evs, config.GUIRoleViewer)[0].Data.(config.Configuration).GUI.APIKey != "" {
+		t.Error("Config not redacted for a viewer")
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -88,7 +89,7 @@ func TestStopAfterBrokenConfig(t *testing.T) {
 	}
 	w := config.Wrap("/dev/null", cfg)
 
-	srv := New(protocol.LocalDeviceID, w, "", "syncthing", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
+	srv := New(protocol.LocalDeviceID, w, "", "syncthing", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
 	srv.started = make(chan string)
 
 	sup := suture.New("test", suture.Spec{
@@ -200,6 +201,26 @@ func TestDirNames(t *testing.T) {
 	}
 }
 
+func TestParseVector(t *testing.T) {
+	v, err := parseVector("000000000000000b:2,000000000000000a:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := protocol.Vector{Counters: []protocol.Counter{{ID: 10, Value: 1}, {ID: 11, Value: 2}}}
+	if !v.Equal(expected) {
+		t.Errorf("Unexpected vector %v", v)
+	}
+	if diff, equal := messagediff.PrettyDiff([]jsonCounter{{"000000000000000a", 1}, {"000000000000000b", 2}}, jsonVector(v)); !equal {
+		t.Errorf("Unexpected JSON vector:\n%s", diff)
+	}
+
+	for _, s := range []string{"a", "a:b", "x:1", "a:1:2"} {
+		if _, err := parseVector(s); err == nil {
+			t.Errorf("Expected %q to fail", s)
+		}
+	}
+}
+
 type httpTestCase struct {
 	URL     string        // URL to check
 	Code    int           // Expected result code
@@ -498,7 +519,7 @@ func startHTTP(cfg *mockedConfig) (string, error) {
 	// Instantiate the API service
 	urService := ur.New(cfg, m, connections, false)
 	summaryService := model.NewFolderSummaryService(cfg, m, protocol.LocalDeviceID)
-	svc := New(protocol.LocalDeviceID, cfg, assetDir, "syncthing", m, eventSub, diskEventSub, discoverer, connections, urService, summaryService, errorLog, systemLog, cpu, nil, false).(*service)
+	svc := New(protocol.LocalDeviceID, cfg, assetDir, "syncthing", m, eventSub, diskEventSub, nil, nil, discoverer, connections, urService, summaryService, errorLog, systemLog, cpu, nil, false).(*service)
 	svc.started = addrChan
 
 	// Actually start the API service
@@ -959,7 +980,7 @@ func TestEventMasks(t *testing.T) {
 	cfg := new(mockedConfig)
 	defSub := new(mockedEventSub)
 	diskSub := new(mockedEventSub)
-	svc := New(protocol.LocalDeviceID, cfg, "", "syncthing", nil, defSub, diskSub, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
+	svc := New(protocol.LocalDeviceID, cfg, "", "syncthing", nil, defSub, diskSub, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
 
 	if mask := svc.getEventMask(""); mask != DefaultEventMask {
 		t.Errorf("incorrect default mask %x != %x", int64(mask), int64(DefaultEventMask))
@@ -986,6 +1007,73 @@ func TestEventMasks(t *testing.T) {
 	}
 }
 
+func TestFilteredEventSubs(t *testing.T) {
+	cfg := new(mockedConfig)
+	defSub := new(mockedEventSub)
+	diskSub := new(mockedEventSub)
+	svc := New(protocol.LocalDeviceID, cfg, "", "syncthing", nil, defSub, diskSub, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false).(*service)
+
+	qs := url.Values{}
+	qs.Set("folder", "default, other")
+	qs.Set("device", strings.ToLower(protocol.LocalDeviceID.String()))
+	filter := getEventFilter(qs)
+	if len(filter.Folders) != 2 || filter.Folders[0] != "default" || filter.Folders[1] != "other" {
+		t.Error("Incorrect folders in filter:", filter.Folders)
+	}
+	if len(filter.Devices) != 1 || filter.Devices[0] != protocol.LocalDeviceID.String() {
+		t.Error("Incorrect devices in filter:", filter.Devices)
+	}
+
+	if res, done := svc.getFilteredEventSub(DefaultEventMask, events.Filter{}); res != defSub {
+		t.Error("should have returned the default event sub for an empty filter")
+	} else {
+		done()
+	}
+
+	res, done := svc.getFilteredEventSub(DefaultEventMask, filter)
+	if again, done := svc.getFilteredEventSub(DefaultEventMask, filter); res == defSub || res != again {
+		t.Error("should have returned the same filtered event sub")
+	} else {
+		done()
+	}
+
+	// Any number of subscriptions are kept while in use
+	other := events.Filter{Folders: []string{"other"}}
+	otherRes, otherDone := svc.getFilteredEventSub(DefaultEventMask, other)
+	otherDone()
+	for i := 0; i < 32; i++ {
+		_, done := svc.getFilteredEventSub(DefaultEventMask, events.Filter{Folders: []string{fmt.Sprint(i)}})
+		done()
+	}
+	if again, done := svc.getFilteredEventSub(DefaultEventMask, filter); again != res {
+		t.Error("the filtered event sub in use should have been kept")
+	} else {
+		done()
+	}
+
+	// Those not polled in a while are dropped, even if one is still being
+	// polled after as long
+	svc.eventSubsMut.Lock()
+	for _, fsub := range svc.filteredEventSubs {
+		fsub.lastUsed = time.Now().Add(-2 * filteredEventSubIdle)
+	}
+	svc.eventSubsMut.Unlock()
+	if again, done := svc.getFilteredEventSub(DefaultEventMask, other); again == otherRes {
+		t.Error("the idle filtered event sub should have been dropped")
+	} else {
+		done()
+	}
+	if again, done := svc.getFilteredEventSub(DefaultEventMask, filter); again != res {
+		t.Error("the filtered event sub being polled should have been kept")
+	} else {
+		done()
+	}
+	done()
+	if len(svc.filteredEventSubs) != 2 {
+		t.Error("Unexpected number of filtered event subs:", len(svc.filteredEventSubs))
+	}
+}
+
 func TestBrowse(t *testing.T) {
 	pathSep := string(os.PathSeparator)
 
@@ -1069,3 +1157,104 @@ func equalStrings(a, b []string) bool {
 	}
 	return true
 }
+
+func TestSupportBundleAnonymizer(t *testing.T) {
+	id1, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	id2, _ := protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+	stranger, _ := protocol.DeviceIDFromString("LGFPDIT-7SKNNJL-VJZA4FC-7QNCRKA-CE753K7-2BW5QDK-2FOZ7FR-FEP57QJ")
+
+	cfg := config.New(id1)
+	cfg.Devices = append(cfg.Devices, config.NewDeviceConfiguration(id2, "secret-laptop"))
+	cfg.Devices[1].Addresses = []string{"tcp://laptop.example.com:22000"}
+	fcfg := config.NewFolderConfiguration(id1, "abcd-efgh", "Tax Returns", fs.FilesystemTypeBasic, "/data/tax")
+	fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{DeviceID: id2})
+	cfg.Folders = append(cfg.Folders, fcfg)
+
+	anon := newAnonymizer(cfg, id1)
+
+	log := []byte(fmt.Sprintf(`Ready to synchronize "Tax Returns" (abcd-efgh) (sendreceive)
+Established secure connection to %s at 192.168.1.20:22000-192.168.1.30:22000/tcp-client
+Puller (folder "Tax Returns" (abcd-efgh), item "2019/return.pdf"): open /data/tax/2019/return.pdf: permission denied
+Rejected connection from %s at [2001:db8::1]:22000
+Device %s (secret-laptop) is abcd-efghij
+`, id2, stranger, id2.Short()))
+	jsonCfg, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anon.collect(log)
+	anon.collect(jsonCfg)
+	log = anon.anonymize(log)
+	jsonCfg = anon.anonymize(jsonCfg)
+
+	for _, s := range []string{id1.String(), id2.String(), id2.Short().String(), stranger.String(), "secret-laptop", "laptop.example.com", "Tax Returns", "abcd-efgh)", "/data/tax", "return.pdf", "192.168.1.20", "192.168.1.30", "2001:db8::1"} {
+		if bytes.Contains(log, []byte(s)) || bytes.Contains(jsonCfg, []byte(s)) {
+			t.Errorf("%q not anonymized:\n%s\n%s", s, log, jsonCfg)
+		}
+	}
+
+	// The same string gets the same pseudonym everywhere.
+	for _, s := range []string{anon.pseudonyms[id2.String()], anon.pseudonyms["abcd-efgh"], anon.pseudonyms["Tax Returns"]} {
+		if !bytes.Contains(log, []byte(s)) || !bytes.Contains(jsonCfg, []byte(s)) {
+			t.Errorf("pseudonym %q missing:\n%s\n%s", s, log, jsonCfg)
+		}
+	}
+	if p := anon.pseudonyms[id2.Short().String()]; p != anon.pseudonyms[id2.String()] {
+		t.Errorf("short device ID pseudonym %q differs from %q", p, anon.pseudonyms[id2.String()])
+	}
+
+	if !bytes.Contains(log, []byte(`item "file-1"): open path-1/file-1:`)) {
+		t.Errorf("file name below folder path anonymized differently:\n%s", log)
+	}
+
+	// Strings are replaced as a whole only, and the JSON stays valid.
+	if !bytes.Contains(log, []byte("abcd-efghij")) {
+		t.Errorf("part of a longer word anonymized:\n%s", log)
+	}
+	var res struct {
+		Folders []struct {
+			ID, Label, Path string
+		}
+	}
+	if err := json.Unmarshal(jsonCfg, &res); err != nil {
+		t.Fatal(err)
+	}
+	if f := res.Folders[0]; f.ID != "folder-1" || f.Label != "label-1" || f.Path != "path-1" {
+		t.Errorf("unexpected anonymized folder %q, %q, %q", f.ID, f.Label, f.Path)
+	}
+}
+
+func TestUnusedFolderPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	full := filepath.Join(dir, "full")
+	empty := filepath.Join(dir, "empty")
+	missing := filepath.Join(dir, "missing")
+	if err := os.MkdirAll(full, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(full, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, err := unusedFolderPath([]string{full, empty}); err != nil || path != empty {
+		t.Errorf("Got %q, %v, expected the empty directory", path, err)
+	}
+	if path, err := unusedFolderPath([]string{full, missing}); err != nil || path != missing {
+		t.Errorf("Got %q, %v, expected the missing directory", path, err)
+	}
+	if _, err := unusedFolderPath([]string{full}); err == nil {
+		t.Error("Expected an error for a directory with files in it")
+	}
+	if _, err := unusedFolderPath(nil); err == nil {
+		t.Error("Expected an error without paths")
+	}
+}
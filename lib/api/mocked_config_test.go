@@ -28,6 +28,22 @@ func (c *mockedConfig) LDAP() config.LDAPConfiguration {
 	return config.LDAPConfiguration{}
 }
 
+func (c *mockedConfig) OIDC() config.OIDCConfiguration {
+	return config.OIDCConfiguration{}
+}
+
+func (c *mockedConfig) TOTP() config.TOTPConfiguration {
+	return config.TOTPConfiguration{}
+}
+
+func (c *mockedConfig) SetTOTP(totp config.TOTPConfiguration) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
+func (c *mockedConfig) RemoveTOTPRecoveryCode(hash string) (config.Waiter, bool, error) {
+	return noopWaiter{}, false, nil
+}
+
 func (c *mockedConfig) RawCopy() config.Configuration {
 	cfg := config.Configuration{}
 	util.SetDefaults(&cfg.Options)
@@ -42,6 +58,14 @@ func (c *mockedConfig) Replace(cfg config.Configuration) (config.Waiter, error)
 	return noopWaiter{}, nil
 }
 
+func (c *mockedConfig) History() []config.ConfigVersion {
+	return nil
+}
+
+func (c *mockedConfig) Rollback(version int) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
 func (c *mockedConfig) Subscribe(cm config.Committer) {}
 
 func (c *mockedConfig) Unsubscribe(cm config.Committer) {}
@@ -94,6 +118,46 @@ func (m *mockedConfig) Folder(id string) (config.FolderConfiguration, bool) {
 	return config.FolderConfiguration{}, false
 }
 
+func (m *mockedConfig) IgnorePatternSet(name string) ([]string, bool) {
+	return nil, false
+}
+
+func (m *mockedConfig) FolderTemplate(name string) (config.FolderTemplate, bool) {
+	return config.FolderTemplate{}, false
+}
+
+func (m *mockedConfig) FolderTemplates() []config.FolderTemplate {
+	return nil
+}
+
+func (m *mockedConfig) SetFolderTemplate(tmpl config.FolderTemplate) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
+func (m *mockedConfig) RemoveFolderTemplate(name string) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
+func (m *mockedConfig) FolderPasswords(device protocol.DeviceID) map[string]string {
+	return nil
+}
+
+func (m *mockedConfig) DeviceGroup(name string) (config.DeviceGroup, bool) {
+	return config.DeviceGroup{}, false
+}
+
+func (m *mockedConfig) DeviceGroups() []config.DeviceGroup {
+	return nil
+}
+
+func (m *mockedConfig) SetDeviceGroup(group config.DeviceGroup) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
+func (m *mockedConfig) RemoveDeviceGroup(name string) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
 func (m *mockedConfig) FolderList() []config.FolderConfiguration {
 	return nil
 }
@@ -118,6 +182,22 @@ func (m *mockedConfig) IgnoredFolder(device protocol.DeviceID, folder string) bo
 	return false
 }
 
+func (m *mockedConfig) PendingDevices() []config.ObservedDevice {
+	return nil
+}
+
+func (m *mockedConfig) PendingFolders() []config.PendingFolder {
+	return nil
+}
+
+func (m *mockedConfig) DismissPendingDevice(device protocol.DeviceID) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
+func (m *mockedConfig) DismissPendingFolder(device protocol.DeviceID, folder string) (config.Waiter, error) {
+	return noopWaiter{}, nil
+}
+
 func (m *mockedConfig) GlobalDiscoveryServers() []string {
 	return nil
 }
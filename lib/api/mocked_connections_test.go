@@ -6,6 +6,14 @@
 
 package api
 
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/connections"
+	"github.com/syncthing/syncthing/lib/nat"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
 type mockedConnections struct{}
 
 func (m *mockedConnections) Status() map[string]interface{} {
@@ -16,6 +24,20 @@ func (m *mockedConnections) NATType() string {
 	return ""
 }
 
+func (m *mockedConnections) ConnectionAttempts() []connections.ConnectionAttempt {
+	return nil
+}
+
+func (m *mockedConnections) Bans() map[string]time.Time {
+	return nil
+}
+
+func (m *mockedConnections) NATMappings() []nat.MappingStatus {
+	return nil
+}
+
+func (m *mockedConnections) ReconnectNow(deviceID protocol.DeviceID) {}
+
 func (m *mockedConnections) Serve() {}
 
 func (m *mockedConnections) Stop() {}
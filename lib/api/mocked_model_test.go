@@ -7,14 +7,17 @@
 package api
 
 import (
+	"io"
 	"net"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/connections"
 	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/versioner"
 )
@@ -25,6 +28,10 @@ func (m *mockedModel) GlobalDirectoryTree(folder, prefix string, levels int, dir
 	return nil
 }
 
+func (m *mockedModel) GlobalDirectoryList(folder, prefix string, levels int, dirsonly bool, sortBy string, desc bool, page, perpage int) ([]db.FileInfoTruncated, error) {
+	return nil, nil
+}
+
 func (m *mockedModel) Completion(device protocol.DeviceID, folder string) model.FolderCompletion {
 	return model.FolderCompletion{}
 }
@@ -33,6 +40,10 @@ func (m *mockedModel) Override(folder string) {}
 
 func (m *mockedModel) Revert(folder string) {}
 
+func (m *mockedModel) ReadView(folders ...string) *model.ReadView {
+	return &model.ReadView{}
+}
+
 func (m *mockedModel) NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated) {
 	return nil, nil, nil
 }
@@ -49,6 +60,10 @@ func (m *mockedModel) ConnectionStats() map[string]interface{} {
 	return nil
 }
 
+func (m *mockedModel) ConnectionHistory(device protocol.DeviceID) []model.ConnectionRecord {
+	return nil
+}
+
 func (m *mockedModel) DeviceStatistics() map[string]stats.DeviceStatistics {
 	return nil
 }
@@ -61,6 +76,14 @@ func (m *mockedModel) CurrentFolderFile(folder string, file string) (protocol.Fi
 	return protocol.FileInfo{}, false
 }
 
+func (m *mockedModel) FileVersions(folder, file string) ([]model.FileVersion, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) FileStatus(folder, file string) (model.FileStatus, error) {
+	return model.FileStatus{}, nil
+}
+
 func (m *mockedModel) CurrentGlobalFile(folder string, file string) (protocol.FileInfo, bool) {
 	return protocol.FileInfo{}, false
 }
@@ -72,6 +95,26 @@ func (m *mockedModel) Availability(folder string, file protocol.FileInfo, block
 	return nil
 }
 
+func (m *mockedModel) RankSources(availability []model.Availability) []model.RankedAvailability {
+	return nil
+}
+
+func (m *mockedModel) StartProtocolTrace(device protocol.DeviceID) error {
+	return nil
+}
+
+func (m *mockedModel) StopProtocolTrace(device protocol.DeviceID) error {
+	return nil
+}
+
+func (m *mockedModel) ProtocolTrace(device protocol.DeviceID) ([]protocol.TraceEntry, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) PushConfig(device protocol.DeviceID, cfg config.PushedConfig) error {
+	return nil
+}
+
 func (m *mockedModel) GetIgnores(folder string) ([]string, []string, error) {
 	return nil, nil, nil
 }
@@ -88,6 +131,86 @@ func (m *mockedModel) RestoreFolderVersions(folder string, versions map[string]t
 	return nil, nil
 }
 
+func (m *mockedModel) RestoreFolderVersion(folder, file string, versionTime time.Time) (versioner.FileVersion, error) {
+	return versioner.FileVersion{}, nil
+}
+
+func (m *mockedModel) GetFolderVersionAt(folder, file string, when time.Time) (versioner.FileVersion, fs.File, error) {
+	return versioner.FileVersion{}, nil, nil
+}
+
+func (m *mockedModel) ChangeHeatmap(folder string, levels, limit int) ([]model.DirChanges, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) IndexSnapshots(folder string) ([]time.Time, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) DiffIndexSnapshots(folder string, from, to time.Time) ([]db.SnapshotChange, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) ExportIndex(folder string, w io.Writer) error {
+	return nil
+}
+
+func (m *mockedModel) ImportIndex(folder string, r io.Reader) (int, error) {
+	return 0, nil
+}
+
+func (m *mockedModel) DeletedFiles(folder string, since time.Time, search string) ([]model.DeletedFile, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) TestIgnores(folder string, files []string) ([]model.IgnoreTestResult, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) AttentionFiles(folder string) ([]model.AttentionFile, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) SeedFolder(folder, source string) (model.SeedResult, error) {
+	return model.SeedResult{}, nil
+}
+
+func (m *mockedModel) AuditRemote(device protocol.DeviceID, folder string, samples int) (model.AuditReport, error) {
+	return model.AuditReport{}, nil
+}
+
+func (m *mockedModel) FolderJobs(folder string) []model.FolderJob {
+	return nil
+}
+
+func (m *mockedModel) ScanProgress() map[string]scanner.Progress {
+	return nil
+}
+
+func (m *mockedModel) CancelFolderJob(id int) error {
+	return nil
+}
+
+func (m *mockedModel) DecommissionFolder(folder string, opts model.DecommissionOptions) (model.DecommissionReport, error) {
+	return model.DecommissionReport{}, nil
+}
+
+func (m *mockedModel) MoveFolder(folder, path string) (model.MoveReport, error) {
+	return model.MoveReport{}, nil
+}
+
+func (m *mockedModel) KeptPartials(folder string) ([]model.KeptPartial, error) {
+	return nil, nil
+}
+
+func (m *mockedModel) ResumePartial(folder, file string) error {
+	return nil
+}
+
+func (m *mockedModel) DiscardPartial(folder, file string) error {
+	return nil
+}
+
 func (m *mockedModel) PauseDevice(device protocol.DeviceID) {
 }
 
@@ -107,6 +230,10 @@ func (m *mockedModel) ScanFolderSubdirs(folder string, subs []string) error {
 	return nil
 }
 
+func (m *mockedModel) RehashFolderSubdirs(folder string, subs []string) (model.RehashReport, error) {
+	return model.RehashReport{}, nil
+}
+
 func (m *mockedModel) BringToFront(folder, file string) {}
 
 func (m *mockedModel) Connection(deviceID protocol.DeviceID) (connections.Connection, bool) {
@@ -133,10 +260,18 @@ func (m *mockedModel) RemoteSequence(folder string) (int64, bool) {
 	return 0, false
 }
 
+func (m *mockedModel) RemoteFolderStatus(device protocol.DeviceID) map[string]model.RemoteFolderStatus {
+	return nil
+}
+
 func (m *mockedModel) State(folder string) (string, time.Time, error) {
 	return "", time.Time{}, nil
 }
 
+func (m *mockedModel) DatabaseError() error {
+	return nil
+}
+
 func (m *mockedModel) UsageReportingStats(version int, preview bool) map[string]interface{} {
 	return nil
 }
@@ -170,6 +305,17 @@ func (m *mockedModel) Closed(conn protocol.Connection, err error) {}
 func (m *mockedModel) DownloadProgress(deviceID protocol.DeviceID, folder string, updates []protocol.FileDownloadProgressUpdate) {
 }
 
+func (m *mockedModel) FolderActivity(deviceID protocol.DeviceID, activity protocol.FolderActivity) {}
+
+func (m *mockedModel) RescanHint(deviceID protocol.DeviceID, hint protocol.RescanHint) {}
+
+func (m *mockedModel) BlockMismatch(deviceID protocol.DeviceID, mismatch protocol.BlockMismatch) {}
+
+func (m *mockedModel) ConfigPush(deviceID protocol.DeviceID, push protocol.ConfigPush) {}
+
+func (m *mockedModel) ConfigPushResponse(deviceID protocol.DeviceID, resp protocol.ConfigPushResponse) {
+}
+
 func (m *mockedModel) AddConnection(conn connections.Connection, hello protocol.HelloResult) {}
 
 func (m *mockedModel) OnHello(protocol.DeviceID, net.Addr, protocol.HelloResult) error {
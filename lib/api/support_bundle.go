@@ -8,18 +8,22 @@ package api
 
 import (
 	"archive/zip"
+	"bytes"
+	"fmt"
 	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 // getRedactedConfig redacting some parts of config
 func getRedactedConfig(s *service) config.Configuration {
-	rawConf := s.cfg.RawCopy()
-	rawConf.GUI.APIKey = "REDACTED"
-	if rawConf.GUI.Password != "" {
-		rawConf.GUI.Password = "REDACTED"
-	}
+	rawConf := redactConfig(s.cfg.RawCopy())
 	if rawConf.GUI.User != "" {
 		rawConf.GUI.User = "REDACTED"
 	}
@@ -45,3 +49,248 @@ func writeZip(writer io.Writer, files []fileEntry) error {
 
 	return zipWriter.Close()
 }
+
+// dbStats returns the index counts and sequences of all folders, read at
+// one point in time.
+func dbStats(s *service) map[string]interface{} {
+	var folders []string
+	for id := range s.cfg.Folders() {
+		folders = append(folders, id)
+	}
+	view := s.model.ReadView(folders...)
+	defer view.Release()
+
+	stats := make(map[string]interface{}, len(folders))
+	for _, folder := range folders {
+		sequence, _ := view.CurrentSequence(folder)
+		remoteSequence, _ := view.RemoteSequence(folder)
+		stats[folder] = map[string]interface{}{
+			"global":         view.GlobalSize(folder),
+			"local":          view.LocalSize(folder),
+			"need":           view.NeedSize(folder),
+			"sequence":       sequence,
+			"remoteSequence": remoteSequence,
+		}
+	}
+	return stats
+}
+
+var (
+	// Device IDs not in the config, e.g. of devices that tried to connect.
+	anonDeviceIDExp = regexp.MustCompile(`\b[A-Z2-7]{7}(-[A-Z2-7]{7}){7}\b`)
+	// File names, the way they are quoted in log messages.
+	anonFileExp = regexp.MustCompile(`\b(?:file|item|path|name) "((?:[^"\\]|\\.)*)"`)
+	anonIPv4Exp = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+	anonIPv6Exp = regexp.MustCompile(`\[[0-9a-fA-F:]*:[0-9a-fA-F:]*\]`)
+)
+
+// An anonymizer replaces device IDs, device names, folder IDs, labels,
+// paths, file names and addresses with pseudonyms such as "device-2". The
+// same string gets the same pseudonym in all files of a support bundle, so
+// that they can still be read together.
+type anonymizer struct {
+	pseudonyms map[string]string
+	paths      map[string]bool   // originals that are directories
+	files      map[string]string // file names, unquoted, to pseudonyms
+	counts     map[string]int
+	byFirst    map[byte][]string // originals by first byte, longest first
+}
+
+// newAnonymizer returns an anonymizer knowing the identifying strings of
+// the given configuration and local device.
+func newAnonymizer(cfg config.Configuration, myID protocol.DeviceID) *anonymizer {
+	a := &anonymizer{
+		pseudonyms: make(map[string]string),
+		paths:      make(map[string]bool),
+		files:      make(map[string]string),
+		counts:     make(map[string]int),
+	}
+
+	a.addDevice(myID)
+	for _, dev := range cfg.Devices {
+		a.addDevice(dev.DeviceID)
+		a.add("name", dev.Name)
+		for _, addr := range dev.Addresses {
+			if addr != "dynamic" {
+				a.add("address", addr)
+			}
+		}
+	}
+	for _, folder := range cfg.Folders {
+		a.add("folder", folder.ID)
+		a.add("label", folder.Label)
+		a.addPath("path", folder.Path)
+		for _, dev := range folder.Devices {
+			a.addDevice(dev.DeviceID)
+		}
+	}
+	if home, err := fs.ExpandTilde("~"); err == nil && len(home) > 1 {
+		a.addPath("home", home)
+	}
+	return a
+}
+
+func (a *anonymizer) addDevice(id protocol.DeviceID) {
+	if _, ok := a.pseudonyms[id.String()]; ok {
+		return
+	}
+	a.alias(a.add("device", id.String()), id.Short().String())
+}
+
+// addPath registers a directory as written in the config and expanded.
+// Names of files below it are anonymized along with it.
+func (a *anonymizer) addPath(kind, path string) {
+	path = strings.TrimRight(path, `/\`)
+	if path == "" {
+		return
+	}
+	pseudonym := a.add(kind, path)
+	if expanded, err := fs.ExpandTilde(path); err == nil {
+		a.alias(pseudonym, expanded)
+	}
+	for s, p := range a.pseudonyms {
+		if p == pseudonym {
+			a.paths[s] = true
+		}
+	}
+}
+
+// addFile registers a file name and returns its pseudonym.
+func (a *anonymizer) addFile(name string) string {
+	if pseudonym, ok := a.files[name]; ok {
+		return pseudonym
+	}
+	a.counts["file"]++
+	pseudonym := fmt.Sprintf("file-%d", a.counts["file"])
+	a.files[name] = pseudonym
+	// Quoted names only, as short names are common words.
+	a.pseudonyms[`"`+name+`"`] = `"` + pseudonym + `"`
+	a.byFirst = nil
+	return pseudonym
+}
+
+// add registers s as being of the given kind, and returns its pseudonym.
+func (a *anonymizer) add(kind, s string) string {
+	if s == "" {
+		return ""
+	}
+	if pseudonym, ok := a.pseudonyms[s]; ok {
+		return pseudonym
+	}
+	a.counts[kind]++
+	a.alias(fmt.Sprintf("%s-%d", kind, a.counts[kind]), s)
+	return a.pseudonyms[s]
+}
+
+// alias registers s as having the given pseudonym, also where escaped as in
+// JSON.
+func (a *anonymizer) alias(pseudonym, s string) {
+	if pseudonym == "" || s == "" {
+		return
+	}
+	a.pseudonyms[s] = pseudonym
+	if escaped := strings.ReplaceAll(s, `\`, `\\`); escaped != s {
+		a.pseudonyms[escaped] = pseudonym
+	}
+	a.byFirst = nil
+}
+
+// collect registers the device IDs, file names and IP addresses found in
+// data. All files of a bundle should be collected before anonymizing any
+// of them, as a name may be recognizable in one file only.
+func (a *anonymizer) collect(data []byte) {
+	for _, m := range anonDeviceIDExp.FindAll(data, -1) {
+		if id, err := protocol.DeviceIDFromString(string(m)); err == nil {
+			a.addDevice(id)
+		}
+	}
+	for _, m := range anonFileExp.FindAllSubmatch(data, -1) {
+		if len(m[1]) > 0 {
+			a.addFile(string(m[1]))
+		}
+	}
+	for _, m := range anonIPv4Exp.FindAll(data, -1) {
+		a.addIP(string(m))
+	}
+	for _, m := range anonIPv6Exp.FindAll(data, -1) {
+		a.addIP(string(m[1 : len(m)-1]))
+	}
+}
+
+func (a *anonymizer) addIP(s string) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() {
+		return
+	}
+	a.add("ip", s)
+}
+
+// anonymize returns data with all registered strings replaced by their
+// pseudonyms. Strings are only replaced as a whole, not where they are part
+// of a longer word, and the longest string wins. What follows a directory
+// up to the next space, quote or colon is taken to be a file name below it.
+func (a *anonymizer) anonymize(data []byte) []byte {
+	if a.byFirst == nil {
+		a.byFirst = make(map[byte][]string)
+		for s := range a.pseudonyms {
+			a.byFirst[s[0]] = append(a.byFirst[s[0]], s)
+		}
+		for _, ss := range a.byFirst {
+			sort.Slice(ss, func(i, j int) bool {
+				if len(ss[i]) != len(ss[j]) {
+					return len(ss[i]) > len(ss[j])
+				}
+				return ss[i] < ss[j]
+			})
+		}
+	}
+
+	// File names below directories are registered as they are found, which
+	// doesn't change what is replaced here.
+	byFirst := a.byFirst
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+outer:
+	for i := 0; i < len(data); {
+		for _, s := range byFirst[data[i]] {
+			if !bytes.HasPrefix(data[i:], []byte(s)) {
+				continue
+			}
+			if isWordByte(s[0]) && i > 0 && isWordByte(data[i-1]) {
+				continue
+			}
+			end := i + len(s)
+			if a.paths[s] && end < len(data) && (data[end] == '/' || data[end] == '\\') {
+				name := end + 1
+				for name < len(data) && data[name] == '\\' {
+					name++ // escaped as in JSON
+				}
+				tail := name
+				for tail < len(data) && !bytes.ContainsAny(data[tail:tail+1], " \t\r\n\"':,") {
+					tail++
+				}
+				buf.WriteString(a.pseudonyms[s])
+				buf.Write(data[end:name])
+				if tail > name {
+					buf.WriteString(a.addFile(string(data[name:tail])))
+				}
+				i = tail
+				continue outer
+			}
+			if isWordByte(s[len(s)-1]) && end < len(data) && isWordByte(data[end]) {
+				continue
+			}
+			buf.WriteString(a.pseudonyms[s])
+			i = end
+			continue outer
+		}
+		buf.WriteByte(data[i])
+		i++
+	}
+	return buf.Bytes()
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
@@ -0,0 +1,328 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/model"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Kinds of warnings
+const (
+	WarningFolderError    = "folderError"    // the folder is stopped due to an error
+	WarningFolderErrors   = "folderErrors"   // items in the folder failed to sync
+	WarningDeviceRejected = "deviceRejected" // an unknown device wants to connect
+	WarningFolderRejected = "folderRejected" // a device wants to share an unknown folder
+	WarningSystem         = "system"         // a warning was logged
+)
+
+const (
+	maxWarnings          = 200
+	maxWarningDetails    = 10
+	warningsSaveInterval = 10 * time.Second
+	warningsEventMask    = events.StateChanged | events.FolderErrors | events.FolderSummary | events.DeviceRejected | events.FolderRejected
+)
+
+// A Warning is a condition the user should know about. Repeated
+// occurrences of the same condition are merged into one warning.
+type Warning struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Folder       string    `json:"folder,omitempty"`
+	Device       string    `json:"device,omitempty"`
+	Message      string    `json:"message"`
+	Details      []string  `json:"details,omitempty"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Count        int       `json:"count"`
+	Acknowledged bool      `json:"acknowledged"`
+	SnoozedUntil time.Time `json:"snoozedUntil"`
+}
+
+// The WarningCenter collects folder and device warnings from events, and
+// warnings logged, and keeps them on disk until they are resolved or
+// acknowledged. An acknowledged warning stays hidden until it is resolved
+// and happens again, or its message changes. A snoozed warning is hidden
+// until the snooze expires.
+type WarningCenter struct {
+	path     string
+	evLogger *events.Logger
+	sub      *events.Subscription
+	stop     chan struct{}
+
+	mut      sync.Mutex
+	warnings map[string]*Warning
+	dirty    bool
+}
+
+// NewWarningCenter loads the warnings persisted at path, if any, and starts
+// collecting new ones. Events are handled once the center is served.
+func NewWarningCenter(path string, evLogger *events.Logger, log logger.Logger) *WarningCenter {
+	c := &WarningCenter{
+		path:     path,
+		evLogger: evLogger,
+		stop:     make(chan struct{}),
+		mut:      sync.NewMutex(),
+		warnings: make(map[string]*Warning),
+	}
+	if err := c.load(); err != nil {
+		l.Infoln("Loading warnings:", err)
+	}
+	c.sub = evLogger.Subscribe(warningsEventMask)
+	log.AddHandler(logger.LevelWarn, c.logged)
+	return c
+}
+
+func (c *WarningCenter) Serve() {
+	t := time.NewTicker(warningsSaveInterval)
+	defer t.Stop()
+	defer c.flush()
+
+	for {
+		select {
+		case ev, ok := <-c.sub.C():
+			if !ok {
+				return
+			}
+			c.handleEvent(ev)
+		case <-t.C:
+			c.flush()
+		case <-c.stop:
+			c.evLogger.Unsubscribe(c.sub)
+			return
+		}
+	}
+}
+
+func (c *WarningCenter) Stop() {
+	close(c.stop)
+}
+
+func (c *WarningCenter) String() string {
+	return "api.WarningCenter@" + c.path
+}
+
+// Warnings returns the current warnings, oldest first. Unless all is set,
+// acknowledged and snoozed warnings are left out.
+func (c *WarningCenter) Warnings(all bool) []Warning {
+	now := time.Now()
+
+	c.mut.Lock()
+	res := make([]Warning, 0, len(c.warnings))
+	for _, w := range c.warnings {
+		if !all && (w.Acknowledged || w.SnoozedUntil.After(now)) {
+			continue
+		}
+		res = append(res, *w)
+	}
+	c.mut.Unlock()
+
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].FirstSeen.Before(res[b].FirstSeen)
+	})
+	return res
+}
+
+// Acknowledge hides the warning with the given ID until it recurs. It
+// returns false if there is no such warning.
+func (c *WarningCenter) Acknowledge(id string) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	w, ok := c.warnings[id]
+	if ok {
+		w.Acknowledged = true
+		c.dirty = true
+	}
+	return ok
+}
+
+// Snooze hides the warning with the given ID for the given duration. It
+// returns false if there is no such warning.
+func (c *WarningCenter) Snooze(id string, d time.Duration) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	w, ok := c.warnings[id]
+	if ok {
+		w.SnoozedUntil = time.Now().Add(d)
+		c.dirty = true
+	}
+	return ok
+}
+
+func (c *WarningCenter) handleEvent(ev events.Event) {
+	switch ev.Type {
+	case events.StateChanged:
+		data := ev.Data.(map[string]interface{})
+		folder := data["folder"].(string)
+		if err, ok := data["error"].(string); ok {
+			c.add(Warning{Kind: WarningFolderError, Folder: folder, Message: err}, ev.Time)
+		} else if data["to"] != model.FolderError.String() {
+			c.resolve(Warning{Kind: WarningFolderError, Folder: folder})
+		}
+
+	case events.FolderErrors:
+		data := ev.Data.(map[string]interface{})
+		errs := data["errors"].([]model.FileError)
+		w := Warning{
+			Kind:    WarningFolderErrors,
+			Folder:  data["folder"].(string),
+			Message: fmt.Sprintf("Failed to sync %d items", len(errs)),
+		}
+		for i := 0; i < len(errs) && i < maxWarningDetails; i++ {
+			w.Details = append(w.Details, fmt.Sprintf("%s: %s", errs[i].Path, errs[i].Err))
+		}
+		c.add(w, ev.Time)
+
+	case events.FolderSummary:
+		data := ev.Data.(map[string]interface{})
+		summary := data["summary"].(map[string]interface{})
+		if n, ok := summary["errors"].(int); ok && n == 0 {
+			c.resolve(Warning{Kind: WarningFolderErrors, Folder: data["folder"].(string)})
+		}
+
+	case events.DeviceRejected:
+		data := ev.Data.(map[string]string)
+		c.add(Warning{
+			Kind:    WarningDeviceRejected,
+			Device:  data["device"],
+			Message: fmt.Sprintf("Unknown device %q (%s) wants to connect", data["name"], data["device"]),
+			Details: []string{data["address"]},
+		}, ev.Time)
+
+	case events.FolderRejected:
+		data := ev.Data.(map[string]string)
+		c.add(Warning{
+			Kind:    WarningFolderRejected,
+			Folder:  data["folder"],
+			Device:  data["device"],
+			Message: fmt.Sprintf("Device %s wants to share folder %q (%s)", data["device"], data["folderLabel"], data["folder"]),
+		}, ev.Time)
+	}
+}
+
+func (c *WarningCenter) logged(_ logger.LogLevel, msg string) {
+	c.add(Warning{Kind: WarningSystem, Message: msg}, time.Now())
+}
+
+func (c *WarningCenter) add(w Warning, t time.Time) {
+	w.ID = warningID(w)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if cur, ok := c.warnings[w.ID]; ok {
+		if cur.Message != w.Message {
+			cur.Acknowledged = false
+		}
+		cur.Message = w.Message
+		cur.Details = w.Details
+		cur.LastSeen = t
+		cur.Count++
+		c.dirty = true
+		return
+	}
+
+	w.FirstSeen = t
+	w.LastSeen = t
+	w.Count = 1
+	c.warnings[w.ID] = &w
+	c.dirty = true
+
+	if len(c.warnings) > maxWarnings {
+		var oldest *Warning
+		for _, cur := range c.warnings {
+			if oldest == nil || cur.LastSeen.Before(oldest.LastSeen) {
+				oldest = cur
+			}
+		}
+		delete(c.warnings, oldest.ID)
+	}
+}
+
+func (c *WarningCenter) resolve(w Warning) {
+	id := warningID(w)
+
+	c.mut.Lock()
+	if _, ok := c.warnings[id]; ok {
+		delete(c.warnings, id)
+		c.dirty = true
+	}
+	c.mut.Unlock()
+}
+
+// warningID identifies the condition behind a warning. Folder and device
+// warnings are identified by their kind and subject; logged warnings by
+// their message.
+func warningID(w Warning) string {
+	key := w.Kind + "|" + w.Folder + "|" + w.Device
+	if w.Kind == WarningSystem {
+		key += "|" + w.Message
+	}
+	hash := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%x", hash[:8])
+}
+
+func (c *WarningCenter) load() error {
+	bs, err := ioutil.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var warnings []Warning
+	if err := json.Unmarshal(bs, &warnings); err != nil {
+		return err
+	}
+	for i := range warnings {
+		c.warnings[warnings[i].ID] = &warnings[i]
+	}
+	return nil
+}
+
+func (c *WarningCenter) flush() {
+	c.mut.Lock()
+	dirty := c.dirty
+	c.dirty = false
+	c.mut.Unlock()
+	if !dirty {
+		return
+	}
+
+	if err := c.save(); err != nil {
+		// Not a warning, as that would be recorded here in turn.
+		l.Infoln("Saving warnings:", err)
+	}
+}
+
+func (c *WarningCenter) save() error {
+	bs, err := json.MarshalIndent(c.Warnings(true), "", "  ")
+	if err != nil {
+		return err
+	}
+	fd, err := osutil.CreateAtomic(c.path)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(bs); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
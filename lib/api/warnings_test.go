@@ -0,0 +1,125 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/logger"
+	"github.com/syncthing/syncthing/lib/model"
+)
+
+func TestWarningCenter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-warnings-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "warnings.json")
+
+	evLogger := events.NewLogger()
+	go evLogger.Serve()
+	defer evLogger.Stop()
+	log := logger.New()
+	c := NewWarningCenter(path, evLogger, log)
+
+	// Repeated conditions are merged
+	errs := []model.FileError{{Path: "foo", Err: "permission denied"}}
+	for i := 0; i < 3; i++ {
+		c.handleEvent(events.Event{Type: events.FolderErrors, Time: time.Now(), Data: map[string]interface{}{
+			"folder": "default",
+			"errors": errs,
+		}})
+	}
+	log.Warnln("Something happened")
+	log.Warnln("Something happened")
+
+	ws := c.Warnings(false)
+	if len(ws) != 2 {
+		t.Fatal("Expected two warnings, got", ws)
+	}
+	if ws[0].Kind != WarningFolderErrors || ws[0].Count != 3 || ws[0].Details[0] != "foo: permission denied" {
+		t.Error("Unexpected folder warning:", ws[0])
+	}
+	if ws[1].Kind != WarningSystem || ws[1].Count != 2 || ws[1].Message != "Something happened" {
+		t.Error("Unexpected system warning:", ws[1])
+	}
+
+	// Acknowledged warnings are hidden until the message changes
+	if !c.Acknowledge(ws[0].ID) || c.Acknowledge("nonexistent") {
+		t.Fatal("Unexpected acknowledge result")
+	}
+	c.handleEvent(events.Event{Type: events.FolderErrors, Time: time.Now(), Data: map[string]interface{}{
+		"folder": "default",
+		"errors": errs,
+	}})
+	if ws := c.Warnings(false); len(ws) != 1 {
+		t.Error("Expected acknowledged warning to be hidden, got", ws)
+	}
+	c.handleEvent(events.Event{Type: events.FolderErrors, Time: time.Now(), Data: map[string]interface{}{
+		"folder": "default",
+		"errors": append(errs, model.FileError{Path: "bar", Err: "disk full"}),
+	}})
+	if ws := c.Warnings(false); len(ws) != 2 {
+		t.Error("Expected changed warning to be shown again, got", ws)
+	}
+
+	// Snoozed warnings are hidden
+	if !c.Snooze(ws[1].ID, time.Hour) {
+		t.Fatal("Unexpected snooze result")
+	}
+	if ws := c.Warnings(false); len(ws) != 1 || ws[0].Kind != WarningFolderErrors {
+		t.Error("Expected snoozed warning to be hidden, got", ws)
+	}
+	if ws := c.Warnings(true); len(ws) != 2 {
+		t.Error("Expected all warnings, got", ws)
+	}
+
+	// Resolved warnings go away
+	c.handleEvent(events.Event{Type: events.FolderSummary, Time: time.Now(), Data: map[string]interface{}{
+		"folder":  "default",
+		"summary": map[string]interface{}{"errors": 0},
+	}})
+	if ws := c.Warnings(true); len(ws) != 1 || ws[0].Kind != WarningSystem {
+		t.Error("Expected folder warning to be resolved, got", ws)
+	}
+
+	// Warnings persist
+	c.handleEvent(events.Event{Type: events.DeviceRejected, Time: time.Now(), Data: map[string]string{
+		"name":    "other",
+		"device":  "AAAAAAA",
+		"address": "127.0.0.1:22000",
+	}})
+	go c.Serve()
+	c.Stop()
+	waitForFile(t, path)
+
+	c = NewWarningCenter(path, evLogger, logger.New())
+	ws = c.Warnings(true)
+	if len(ws) != 2 || ws[0].Kind != WarningSystem || ws[1].Kind != WarningDeviceRejected || ws[1].Device != "AAAAAAA" {
+		t.Error("Unexpected warnings after reload:", ws)
+	}
+	if ws := c.Warnings(false); len(ws) != 1 {
+		t.Error("Expected snooze to persist, got", ws)
+	}
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timeout waiting for", path)
+}
@@ -77,6 +77,7 @@ func (b *Broadcast) Error() error {
 
 type broadcastWriter struct {
 	port    int
+	unicast []net.IP // sent to as well, see NewUnicast
 	inbox   chan []byte
 	conn    *net.UDPConn
 	connMut sync.Mutex
@@ -121,6 +122,10 @@ func (w *broadcastWriter) Serve() {
 		}
 
 		l.Debugln("addresses:", dsts)
+		if len(w.unicast) > 0 {
+			l.Debugln("unicast addresses:", len(w.unicast))
+			dsts = append(dsts, w.unicast...)
+		}
 
 		success := 0
 		for _, ip := range dsts {
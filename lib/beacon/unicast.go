@@ -0,0 +1,78 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package beacon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// The most addresses a unicast subnet may have, as each gets every packet.
+const maxUnicastHosts = 1024
+
+// NewUnicast returns a broadcast beacon on the port that also sends to each
+// of the destinations, IPv4 addresses or subnets, for networks filtering
+// broadcasts such as many VPNs. The packets arrive where broadcasts do, so
+// the other side hears them without being set up for it. Invalid
+// destinations are skipped with a warning.
+func NewUnicast(port int, dsts []string) *Broadcast {
+	b := NewBroadcast(port)
+	b.bw.unicast = unicastAddresses(dsts)
+	return b
+}
+
+// unicastAddresses returns the addresses of the valid destinations.
+func unicastAddresses(dsts []string) []net.IP {
+	var ips []net.IP
+	for _, dst := range dsts {
+		dstIPs, err := ParseUnicast(dst)
+		if err != nil {
+			l.Warnln("Skipping local announcement destination:", err)
+			continue
+		}
+		ips = append(ips, dstIPs...)
+	}
+	return ips
+}
+
+// ParseUnicast returns the addresses of the unicast destination, an IPv4
+// address or subnet, the hosts of a subnet without its network and
+// broadcast addresses.
+func ParseUnicast(dst string) ([]net.IP, error) {
+	if ip := net.ParseIP(dst); ip != nil {
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("unicast destination %s: only IPv4 is supported", dst)
+		}
+		return []net.IP{ip.To4()}, nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(dst)
+	if err != nil {
+		return nil, fmt.Errorf("unicast destination %q is neither an address nor a subnet", dst)
+	}
+	if ipnet.IP.To4() == nil {
+		return nil, fmt.Errorf("unicast destination %s: only IPv4 is supported", dst)
+	}
+	ones, bits := ipnet.Mask.Size()
+	if uint64(1)<<uint(bits-ones) > maxUnicastHosts {
+		return nil, fmt.Errorf("unicast destination %s has more than %d addresses", dst, maxUnicastHosts)
+	}
+	hosts := uint32(1) << uint(bits-ones)
+	first, last := uint32(0), hosts-1
+	if hosts > 2 {
+		first, last = 1, hosts-2
+	}
+	base := binary.BigEndian.Uint32(ipnet.IP.To4())
+	ips := make([]net.IP, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, base+i)
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
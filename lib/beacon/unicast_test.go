@@ -0,0 +1,68 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package beacon
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestUnicastAddresses(t *testing.T) {
+	// Invalid destinations are skipped, the others still used.
+	ips := unicastAddresses([]string{"192.0.2.7", "198.51.100.0/30", "host.example.com", "203.0.113.8/31", "::ffff:192.0.2.9"})
+	expected := "[192.0.2.7 198.51.100.1 198.51.100.2 203.0.113.8 203.0.113.9 192.0.2.9]"
+	if got := fmt.Sprint(ips); got != expected {
+		t.Errorf("Got %s, expected %s", got, expected)
+	}
+
+	if ips, err := ParseUnicast("10.8.0.0/22"); err != nil || len(ips) != 1022 {
+		t.Errorf("Got %d addresses and %v for a /22", len(ips), err)
+	}
+
+	for _, dst := range []string{"10.8.0.0/21", "2001:db8::1", "2001:db8::/120", "host.example.com", "192.0.2.300"} {
+		if _, err := ParseUnicast(dst); err == nil {
+			t.Errorf("Expected an error for %q", dst)
+		}
+	}
+}
+
+func TestUnicastSend(t *testing.T) {
+	// Whatever listens on the port, like the broadcast reader of the other
+	// side, gets the packets.
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := &broadcastWriter{
+		port:    conn.LocalAddr().(*net.UDPAddr).Port,
+		unicast: []net.IP{net.IPv4(127, 0, 0, 1)},
+		inbox:   make(chan []byte),
+		connMut: sync.NewMutex(),
+	}
+	go w.Serve()
+	defer w.Stop()
+
+	data := []byte("announcement")
+	w.inbox <- data
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], data) {
+		t.Errorf("Got %q, expected %q", buf[:n], data)
+	}
+}
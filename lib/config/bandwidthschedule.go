@@ -0,0 +1,103 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A BandwidthSchedule replaces the overall rate limits during a time range
+// on some days of the week. The range ends the next day when it ends
+// before it starts, like 22:00 to 06:00, the days being those it starts
+// on.
+type BandwidthSchedule struct {
+	Days        []string `xml:"day" json:"days"`         // mon, tue, ..., sun; empty is every day
+	Start       string   `xml:"start,attr" json:"start"` // HH:MM in local time
+	End         string   `xml:"end,attr" json:"end"`     // HH:MM in local time, exclusive
+	MaxSendKbps int      `xml:"maxSendKbps" json:"maxSendKbps"`
+	MaxRecvKbps int      `xml:"maxRecvKbps" json:"maxRecvKbps"`
+}
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func (s BandwidthSchedule) Copy() BandwidthSchedule {
+	c := s
+	c.Days = append([]string(nil), s.Days...)
+	return c
+}
+
+// Active returns whether the schedule applies at the time.
+func (s BandwidthSchedule) Active(t time.Time) bool {
+	start, err := parseClock(s.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(s.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case start <= end:
+		return now >= start && now < end && s.onDay(day)
+	case now >= start:
+		return s.onDay(day)
+	case now < end:
+		// In the part of the range after midnight, which belongs to the
+		// day before.
+		return s.onDay((day + 6) % 7)
+	default:
+		return false
+	}
+}
+
+func (s BandwidthSchedule) onDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, name := range s.Days {
+		if d, ok := scheduleDays[strings.ToLower(name)]; ok && d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (s BandwidthSchedule) check() error {
+	if _, err := parseClock(s.Start); err != nil {
+		return fmt.Errorf("bandwidth schedule start: %v", err)
+	}
+	if _, err := parseClock(s.End); err != nil {
+		return fmt.Errorf("bandwidth schedule end: %v", err)
+	}
+	for _, name := range s.Days {
+		if _, ok := scheduleDays[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("bandwidth schedule day %q is not one of mon, tue, wed, thu, fri, sat and sun", name)
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of the HH:MM time.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,28 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestBandwidthScheduleCheck(t *testing.T) {
+	for _, tc := range []struct {
+		schedule BandwidthSchedule
+		ok       bool
+	}{
+		{BandwidthSchedule{Start: "09:00", End: "17:30"}, true},
+		{BandwidthSchedule{Days: []string{"Sat", "sun"}, Start: "22:00", End: "06:00"}, true},
+		{BandwidthSchedule{Start: "9", End: "17:00"}, false},
+		{BandwidthSchedule{Start: "09:00", End: "24:00"}, false},
+		{BandwidthSchedule{Days: []string{"monday"}, Start: "09:00", End: "17:00"}, false},
+	} {
+		if err := tc.schedule.check(); (err == nil) != tc.ok {
+			t.Errorf("Checking %+v: %v", tc.schedule, err)
+		}
+	}
+}
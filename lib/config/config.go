@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/syncthing/syncthing/lib/beacon"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
@@ -75,6 +76,8 @@ func New(myID protocol.DeviceID) Configuration {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.MQTT)
+	util.SetDefaults(&cfg.OIDC)
 
 	// Can't happen.
 	if err := cfg.prepare(myID); err != nil {
@@ -115,6 +118,8 @@ func ReadXML(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.MQTT)
+	util.SetDefaults(&cfg.OIDC)
 
 	if err := xml.NewDecoder(r).Decode(&cfg); err != nil {
 		return Configuration{}, err
@@ -133,6 +138,8 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 	util.SetDefaults(&cfg)
 	util.SetDefaults(&cfg.Options)
 	util.SetDefaults(&cfg.GUI)
+	util.SetDefaults(&cfg.MQTT)
+	util.SetDefaults(&cfg.OIDC)
 
 	bs, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -151,15 +158,22 @@ func ReadJSON(r io.Reader, myID protocol.DeviceID) (Configuration, error) {
 }
 
 type Configuration struct {
-	Version        int                   `xml:"version,attr" json:"version"`
-	Folders        []FolderConfiguration `xml:"folder" json:"folders"`
-	Devices        []DeviceConfiguration `xml:"device" json:"devices"`
-	GUI            GUIConfiguration      `xml:"gui" json:"gui"`
-	LDAP           LDAPConfiguration     `xml:"ldap" json:"ldap"`
-	Options        OptionsConfiguration  `xml:"options" json:"options"`
-	IgnoredDevices []ObservedDevice      `xml:"remoteIgnoredDevice" json:"remoteIgnoredDevices"`
-	PendingDevices []ObservedDevice      `xml:"pendingDevice" json:"pendingDevices"`
-	XMLName        xml.Name              `xml:"configuration" json:"-"`
+	Version           int                    `xml:"version,attr" json:"version"`
+	Folders           []FolderConfiguration  `xml:"folder" json:"folders"`
+	Devices           []DeviceConfiguration  `xml:"device" json:"devices"`
+	GUI               GUIConfiguration       `xml:"gui" json:"gui"`
+	LDAP              LDAPConfiguration      `xml:"ldap" json:"ldap"`
+	OIDC              OIDCConfiguration      `xml:"oidc" json:"oidc"`
+	TOTP              TOTPConfiguration      `xml:"totp" json:"-"`
+	MQTT              MQTTConfiguration      `xml:"mqtt" json:"mqtt"`
+	Options           OptionsConfiguration   `xml:"options" json:"options"`
+	IgnoredDevices    []ObservedDevice       `xml:"remoteIgnoredDevice" json:"remoteIgnoredDevices"`
+	PendingDevices    []ObservedDevice       `xml:"pendingDevice" json:"pendingDevices"`
+	Webhooks          []WebhookConfiguration `xml:"webhook" json:"webhooks"`
+	IgnorePatternSets []IgnorePatternSet     `xml:"ignorePatternSet" json:"ignorePatternSets"`
+	FolderTemplates   []FolderTemplate       `xml:"folderTemplate" json:"folderTemplates"`
+	DeviceGroups      []DeviceGroup          `xml:"deviceGroup" json:"deviceGroups"`
+	XMLName           xml.Name               `xml:"configuration" json:"-"`
 
 	MyID            protocol.DeviceID `xml:"-" json:"-"` // Provided by the instantiator.
 	OriginalVersion int               `xml:"-" json:"-"` // The version we read from disk, before any conversion
@@ -182,6 +196,10 @@ func (cfg Configuration) Copy() Configuration {
 
 	newCfg.Options = cfg.Options.Copy()
 	newCfg.GUI = cfg.GUI.Copy()
+	newCfg.MQTT = cfg.MQTT.Copy()
+	newCfg.OIDC = cfg.OIDC.Copy()
+	newCfg.LDAP = cfg.LDAP.Copy()
+	newCfg.TOTP = cfg.TOTP.Copy()
 
 	// DeviceIDs are values
 	newCfg.IgnoredDevices = make([]ObservedDevice, len(cfg.IgnoredDevices))
@@ -190,6 +208,26 @@ func (cfg Configuration) Copy() Configuration {
 	newCfg.PendingDevices = make([]ObservedDevice, len(cfg.PendingDevices))
 	copy(newCfg.PendingDevices, cfg.PendingDevices)
 
+	newCfg.Webhooks = make([]WebhookConfiguration, len(cfg.Webhooks))
+	for i := range newCfg.Webhooks {
+		newCfg.Webhooks[i] = cfg.Webhooks[i].Copy()
+	}
+
+	newCfg.IgnorePatternSets = make([]IgnorePatternSet, len(cfg.IgnorePatternSets))
+	for i := range newCfg.IgnorePatternSets {
+		newCfg.IgnorePatternSets[i] = cfg.IgnorePatternSets[i].Copy()
+	}
+
+	newCfg.FolderTemplates = make([]FolderTemplate, len(cfg.FolderTemplates))
+	for i := range newCfg.FolderTemplates {
+		newCfg.FolderTemplates[i] = cfg.FolderTemplates[i].Copy()
+	}
+
+	newCfg.DeviceGroups = make([]DeviceGroup, len(cfg.DeviceGroups))
+	for i := range newCfg.DeviceGroups {
+		newCfg.DeviceGroups[i] = cfg.DeviceGroups[i].Copy()
+	}
+
 	return newCfg
 }
 
@@ -256,11 +294,71 @@ func (cfg *Configuration) clean() error {
 			return fmt.Errorf("duplicate folder ID %q in configuration", folder.ID)
 		}
 		existingFolders[folder.ID] = folder
+
+		if err := folder.checkMetadata(); err != nil {
+			return err
+		}
+		if err := folder.checkPermBits(); err != nil {
+			return err
+		}
+		if err := folder.checkEncryption(); err != nil {
+			return err
+		}
+	}
+
+	// Folders refer to pattern sets by name, so it must be unique too.
+	existingPatternSets := make(map[string]struct{}, len(cfg.IgnorePatternSets))
+	for _, set := range cfg.IgnorePatternSets {
+		if set.Name == "" {
+			return fmt.Errorf("ignore pattern set with empty name in configuration")
+		}
+		if _, ok := existingPatternSets[set.Name]; ok {
+			return fmt.Errorf("duplicate ignore pattern set %q in configuration", set.Name)
+		}
+		existingPatternSets[set.Name] = struct{}{}
+	}
+
+	existingTemplates := make(map[string]struct{}, len(cfg.FolderTemplates))
+	for _, tmpl := range cfg.FolderTemplates {
+		if tmpl.Name == "" {
+			return fmt.Errorf("folder template with empty name in configuration")
+		}
+		if _, ok := existingTemplates[tmpl.Name]; ok {
+			return fmt.Errorf("duplicate folder template %q in configuration", tmpl.Name)
+		}
+		existingTemplates[tmpl.Name] = struct{}{}
+	}
+
+	existingGroups := make(map[string]struct{}, len(cfg.DeviceGroups))
+	for _, group := range cfg.DeviceGroups {
+		if group.Name == "" {
+			return fmt.Errorf("device group with empty name in configuration")
+		}
+		if _, ok := existingGroups[group.Name]; ok {
+			return fmt.Errorf("duplicate device group %q in configuration", group.Name)
+		}
+		existingGroups[group.Name] = struct{}{}
+	}
+
+	for _, schedule := range cfg.Options.BandwidthSchedules {
+		if err := schedule.check(); err != nil {
+			return err
+		}
 	}
 
 	cfg.Options.ListenAddresses = util.UniqueStrings(cfg.Options.ListenAddresses)
 	cfg.Options.GlobalAnnServers = util.UniqueStrings(cfg.Options.GlobalAnnServers)
 
+	unicast := cfg.Options.LocalAnnUnicast[:0]
+	for _, dst := range cfg.Options.LocalAnnUnicast {
+		if _, err := beacon.ParseUnicast(dst); err != nil {
+			l.Warnln("Removing invalid local announcement destination:", err)
+			continue
+		}
+		unicast = append(unicast, dst)
+	}
+	cfg.Options.LocalAnnUnicast = unicast
+
 	if cfg.Version > 0 && cfg.Version < OldestHandledVersion {
 		l.Warnf("Configuration version %d is deprecated. Attempting best effort conversion, but please verify manually.", cfg.Version)
 	}
@@ -340,6 +438,9 @@ func (cfg *Configuration) clean() error {
 		return cfg.Folders[a].ID < cfg.Folders[b].ID
 	})
 
+	// Share the folders shared with device groups with their devices.
+	cfg.shareWithGroups(existingDevices)
+
 	// Ensure that in all folder configs
 	// - any loose devices are not present in the wrong places
 	// - there are no duplicate devices
@@ -446,6 +547,40 @@ func (cfg *Configuration) DeviceMap() map[protocol.DeviceID]DeviceConfiguration
 	return m
 }
 
+// FolderTemplate returns the named folder template and an "ok" bool.
+func (cfg *Configuration) FolderTemplate(name string) (FolderTemplate, bool) {
+	for _, tmpl := range cfg.FolderTemplates {
+		if tmpl.Name == name {
+			return tmpl.Copy(), true
+		}
+	}
+	return FolderTemplate{}, false
+}
+
+// FolderPasswords returns the encryption passwords of the folders shared
+// with the device, by folder ID, for those that have one.
+func (cfg *Configuration) FolderPasswords(device protocol.DeviceID) map[string]string {
+	res := make(map[string]string)
+	for _, folder := range cfg.Folders {
+		for _, dev := range folder.Devices {
+			if dev.DeviceID == device && dev.EncryptionPassword != "" {
+				res[folder.ID] = dev.EncryptionPassword
+			}
+		}
+	}
+	return res
+}
+
+// DeviceGroup returns the named device group and an "ok" bool.
+func (cfg *Configuration) DeviceGroup(name string) (DeviceGroup, bool) {
+	for _, group := range cfg.DeviceGroups {
+		if group.Name == name {
+			return group.Copy(), true
+		}
+	}
+	return DeviceGroup{}, false
+}
+
 func convertV27V28(cfg *Configuration) {
 	// Show a notification about enabling filesystem watching
 	cfg.Options.UnackedNotificationIDs = append(cfg.Options.UnackedNotificationIDs, "fsWatcherNotification")
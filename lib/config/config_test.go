@@ -18,8 +18,10 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
+	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -47,6 +49,8 @@ func TestDefaultValues(t *testing.T) {
 		ReconnectIntervalS:      60,
 		RelaysEnabled:           true,
 		RelayReconnectIntervalM: 10,
+		RelayConnections:        1,
+		DHTListenAddr:           ":21028",
 		StartBrowser:            true,
 		NATEnabled:              true,
 		NATLeaseM:               60,
@@ -188,6 +192,7 @@ func TestOverriddenValues(t *testing.T) {
 		ReconnectIntervalS:      6000,
 		RelaysEnabled:           false,
 		RelayReconnectIntervalM: 20,
+		RelayConnections:        3,
 		StartBrowser:            false,
 		NATEnabled:              false,
 		NATLeaseM:               90,
@@ -212,8 +217,18 @@ func TestOverriddenValues(t *testing.T) {
 			"channelNotification",   // added in 17->18 migration
 			"fsWatcherNotification", // added in 27->28 migration
 		},
-		DefaultFolderPath: "/media/syncthing",
-		SetLowPriority:    false,
+		DefaultFolderPath:     "/media/syncthing",
+		SetLowPriority:        false,
+		LocalAnnExclude:       []string{"tun*"},
+		LocalAnnUnicast:       []string{"10.8.0.0/24", "192.0.2.7"},
+		GlobalAnnExclude:      []string{"10.8.0.0/16", "tun*"},
+		MaxIncomingRequestKiB: 65536,
+		EventJournalMiB:       20,
+		DNSDiscoveryDomains:   []string{"devices.example.com"},
+		DHTEnabled:            true,
+		DHTListenAddr:         ":21029",
+		DHTBootstrapNodes:     []string{"192.0.2.1:21028"},
+		TracingEndpoint:       "http://localhost:4318/v1/traces",
 	}
 
 	os.Unsetenv("STNOUPGRADE")
@@ -389,6 +404,9 @@ func TestVersioningConfig(t *testing.T) {
 	if diff, equal := messagediff.PrettyDiff(expected, vc.Params); !equal {
 		t.Errorf("vc.Params differ. Diff:\n%s", diff)
 	}
+	if vc.FSPath != "/var/versions" || vc.FSType != fs.FilesystemTypeBasic {
+		t.Errorf("Unexpected versions location %q (%v)", vc.FSPath, vc.FSType)
+	}
 }
 
 func TestIssue1262(t *testing.T) {
@@ -708,6 +726,171 @@ func TestDuplicateFolders(t *testing.T) {
 	}
 }
 
+func TestIgnorePatternSets(t *testing.T) {
+	wrapper, err := Load("testdata/ignorepatternsets.xml", device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns, ok := wrapper.IgnorePatternSet("media-junk")
+	if !ok || !reflect.DeepEqual(patterns, []string{"Thumbs.db", "(?i)*.thm"}) {
+		t.Errorf("Incorrect pattern set media-junk: %v, %v", patterns, ok)
+	}
+	if _, ok := wrapper.IgnorePatternSet("nonexistent"); ok {
+		t.Error("Nonexistent pattern set found")
+	}
+
+	// Duplicate names are a loading error
+	_, err = Load("testdata/dupignorepatternsets.xml", device1)
+	if err == nil || !strings.HasPrefix(err.Error(), "duplicate ignore pattern set") {
+		t.Fatal(`Expected error to mention "duplicate ignore pattern set":`, err)
+	}
+}
+
+func TestFolderTemplates(t *testing.T) {
+	wrapper, err := Load("testdata/foldertemplates.xml", device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, ok := wrapper.FolderTemplate("media")
+	if !ok {
+		t.Fatal("Folder template media not found")
+	}
+
+	fcfg := NewFolderConfiguration(device1, "id", "label", fs.FilesystemTypeBasic, "path")
+	tmpl.Apply(&fcfg)
+	expected := VersioningConfiguration{Type: "trashcan", Params: map[string]string{"cleanoutDays": "7"}}
+	if fcfg.Template != "media" || fcfg.Type != FolderTypeReceiveOnly || !reflect.DeepEqual(fcfg.Versioning, expected) || fcfg.RescanIntervalS != 600 || fcfg.Order != OrderSmallestFirst {
+		t.Errorf("Template not applied: %+v", fcfg)
+	}
+	if !reflect.DeepEqual(tmpl.Ignores, []string{"#include-config:media-junk"}) {
+		t.Errorf("Incorrect ignores %v", tmpl.Ignores)
+	}
+
+	// Changing the folder doesn't change the template.
+	fcfg.Versioning.Params["cleanoutDays"] = "1"
+	if tmpl, _ := wrapper.FolderTemplate("media"); tmpl.Versioning.Params["cleanoutDays"] != "7" {
+		t.Error("Template changed along with the folder")
+	}
+
+	tmpl = NewFolderTemplate("docs")
+	tmpl.RescanIntervalS = 60
+	if _, err := wrapper.SetFolderTemplate(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, ok := wrapper.FolderTemplate("docs"); !ok || tmpl.RescanIntervalS != 60 {
+		t.Errorf("Template docs not replaced: %+v", tmpl)
+	}
+	if _, err := wrapper.RemoveFolderTemplate("media"); err != nil {
+		t.Fatal(err)
+	}
+	if tmpls := wrapper.FolderTemplates(); len(tmpls) != 1 || tmpls[0].Name != "docs" {
+		t.Errorf("Unexpected templates after removal %+v", tmpls)
+	}
+
+	// Duplicate names are a loading error
+	_, err = Load("testdata/dupfoldertemplates.xml", device1)
+	if err == nil || !strings.HasPrefix(err.Error(), "duplicate folder template") {
+		t.Fatal(`Expected error to mention "duplicate folder template":`, err)
+	}
+}
+
+func TestDeviceGroups(t *testing.T) {
+	cfg := New(device1)
+	cfg.Devices = append(cfg.Devices, NewDeviceConfiguration(device2, "d2"), NewDeviceConfiguration(device3, "d3"))
+	cfg.Folders = []FolderConfiguration{
+		{ID: "f1", Path: "testdata", Groups: []string{"team"}, Devices: []FolderDeviceConfiguration{{DeviceID: device3}}},
+		{ID: "f2", Path: "testdata"},
+	}
+	cfg.DeviceGroups = []DeviceGroup{{Name: "team", Devices: []protocol.DeviceID{device2}}}
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	w := Wrap("/tmp/cfg", cfg)
+
+	sharedWith := func(id string) []protocol.DeviceID {
+		fcfg, _ := w.Folder(id)
+		devs := fcfg.DeviceIDs()
+		sort.Slice(devs, func(a, b int) bool { return devs[a].Compare(devs[b]) == -1 })
+		return devs
+	}
+	if devs := sharedWith("f1"); !reflect.DeepEqual(devs, []protocol.DeviceID{device1, device2, device3}) {
+		t.Errorf("Unexpected devices %v", devs)
+	}
+	if devs := sharedWith("f2"); !reflect.DeepEqual(devs, []protocol.DeviceID{device1}) {
+		t.Errorf("Unexpected devices %v", devs)
+	}
+
+	// Devices leaving the group are no longer shared with, unless they
+	// were shared with directly.
+	if _, err := w.SetDeviceGroup(DeviceGroup{Name: "team", Devices: []protocol.DeviceID{device3, device4}}); err != nil {
+		t.Fatal(err)
+	}
+	if devs := sharedWith("f1"); !reflect.DeepEqual(devs, []protocol.DeviceID{device1, device3}) {
+		t.Errorf("Unexpected devices %v", devs)
+	}
+
+	// Devices added to the config later get the folders of their group.
+	if _, err := w.SetDevice(NewDeviceConfiguration(device4, "d4")); err != nil {
+		t.Fatal(err)
+	}
+	if devs := sharedWith("f1"); !reflect.DeepEqual(devs, []protocol.DeviceID{device1, device3, device4}) {
+		t.Errorf("Unexpected devices %v", devs)
+	}
+
+	if _, err := w.RemoveDeviceGroup("team"); err != nil {
+		t.Fatal(err)
+	}
+	if fcfg, _ := w.Folder("f1"); len(fcfg.Groups) != 0 {
+		t.Errorf("Group not removed from the folder: %v", fcfg.Groups)
+	}
+	if devs := sharedWith("f1"); !reflect.DeepEqual(devs, []protocol.DeviceID{device1, device3}) {
+		t.Errorf("Unexpected devices %v", devs)
+	}
+}
+
+func TestFolderMetadataChanged(t *testing.T) {
+	cfg := New(device1)
+	cfg.Folders = []FolderConfiguration{{ID: "f1", Path: "testdata", Label: "a"}}
+	w := Wrap("/tmp/cfg", cfg)
+
+	fcfg, _ := w.Folder("f1")
+	fcfg.Metadata = []FolderMetadata{{Key: "k", Value: "v"}}
+	if _, err := w.SetFolder(fcfg); err != nil {
+		t.Fatal(err)
+	}
+	fcfg, _ = w.Folder("f1")
+	if fcfg.MetadataChanged.IsZero() {
+		t.Fatal("Changed metadata not stamped")
+	}
+
+	// Changes to what's kept local aren't shared.
+	fcfg.LocalLabel = true
+	fcfg.Metadata[0].Local = true
+	w.SetFolder(fcfg)
+	fcfg, _ = w.Folder("f1")
+	stamp := fcfg.MetadataChanged
+	fcfg.Label = "b"
+	fcfg.Metadata[0].Value = "w"
+	w.SetFolder(fcfg)
+	if fcfg, _ = w.Folder("f1"); !fcfg.MetadataChanged.Equal(stamp) {
+		t.Error("Local changes stamped")
+	}
+
+	// Metadata taken from another device keeps its stamp.
+	stamp = time.Unix(1234, 0)
+	fcfg.FolderDescription = "d"
+	fcfg.MetadataChanged = stamp
+	w.SetFolder(fcfg)
+	if fcfg, _ = w.Folder("f1"); !fcfg.MetadataChanged.Equal(stamp) {
+		t.Errorf("Stamp %v replaced by %v", stamp, fcfg.MetadataChanged)
+	}
+
+	fcfg.Metadata = append(fcfg.Metadata, FolderMetadata{Key: "k"})
+	if _, err := w.SetFolder(fcfg); err == nil || !strings.Contains(err.Error(), "duplicate metadata key") {
+		t.Error(`Expected error to mention "duplicate metadata key":`, err)
+	}
+}
+
 func TestEmptyFolderPaths(t *testing.T) {
 	// Empty folder paths are allowed at the loading stage, and should not
 	// get messed up by the prepare steps (e.g., become the current dir or
@@ -1112,3 +1295,308 @@ func defaultConfigAsMap() map[string]interface{} {
 	}
 	return tmp
 }
+
+func TestRetractedFolders(t *testing.T) {
+	cfg := New(device1)
+	cfg.Devices = append(cfg.Devices, DeviceConfiguration{
+		DeviceID:         device2,
+		RetractedFolders: []string{"unshared", "shared", "unshared", "removed"},
+	})
+	cfg.Folders = []FolderConfiguration{
+		{ID: "shared", Devices: []FolderDeviceConfiguration{{DeviceID: device2}}},
+		{ID: "unshared"},
+	}
+
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	for _, dev := range cfg.Devices {
+		if dev.DeviceID != device2 {
+			continue
+		}
+		if exp := []string{"removed", "unshared"}; !reflect.DeepEqual(dev.RetractedFolders, exp) {
+			t.Errorf("Retracted folders %v, expected %v", dev.RetractedFolders, exp)
+		}
+	}
+}
+
+func TestFolderDeviceExpired(t *testing.T) {
+	now := time.Now()
+	if (FolderDeviceConfiguration{}).Expired(now) {
+		t.Error("Share without expiry expired")
+	}
+	if (FolderDeviceConfiguration{ExpiresAt: now.Add(time.Minute)}).Expired(now) {
+		t.Error("Share expired early")
+	}
+	if !(FolderDeviceConfiguration{ExpiresAt: now}).Expired(now) {
+		t.Error("Share not expired at expiry")
+	}
+}
+
+func TestFolderTempName(t *testing.T) {
+	fcfg := FolderConfiguration{ID: "a"}
+	if name := fcfg.TempName(filepath.Join("dir", "file")); name != fs.TempName(filepath.Join("dir", "file")) {
+		t.Errorf("temporary file %v not next to the file", name)
+	}
+
+	fcfg.TempDir = "staging"
+	name := fcfg.TempName(filepath.Join("dir", "file"))
+	if filepath.Dir(name) != "." || !fs.IsTemporary(name) {
+		t.Errorf("temporary file %v not at the top of the temp dir", name)
+	}
+	if name == fcfg.TempName(filepath.Join("other", "file")) {
+		t.Error("same temporary file for different files")
+	}
+	other := FolderConfiguration{ID: "b", TempDir: "staging"}
+	if name == other.TempName(filepath.Join("dir", "file")) {
+		t.Error("same temporary file for different folders")
+	}
+}
+
+func TestFolderPermBits(t *testing.T) {
+	fcfg := FolderConfiguration{ID: "perms", IgnorePermBits: "0111"}
+	if bits := fcfg.IgnoredLocalPermBits(); bits != 0111 {
+		t.Errorf("ignored local bits %o, expected 111", bits)
+	}
+	if bits := fcfg.IgnoredRemotePermBits(); bits != 0111 {
+		t.Errorf("ignored remote bits %o, expected 111", bits)
+	}
+	fcfg.IgnoreRemotePerms = true
+	if bits := fcfg.IgnoredRemotePermBits(); bits != 0777 {
+		t.Errorf("ignored remote bits %o, expected 777", bits)
+	}
+	if bits := fcfg.IgnoredLocalPermBits(); bits != 0111 {
+		t.Errorf("ignored local bits %o, expected 111", bits)
+	}
+	fcfg.IgnorePerms = true
+	if bits := fcfg.IgnoredLocalPermBits(); bits != 0777 {
+		t.Errorf("ignored local bits %o, expected 777", bits)
+	}
+
+	for _, bits := range []string{"0999", "1777", "x"} {
+		cfg := New(device1)
+		cfg.Folders = []FolderConfiguration{{ID: "perms", IgnorePermBits: bits}}
+		if err := cfg.prepare(device1); err == nil {
+			t.Errorf("invalid permission bits %q accepted", bits)
+		}
+	}
+}
+
+func TestFolderPasswords(t *testing.T) {
+	cfg := New(device1)
+	cfg.Devices = []DeviceConfiguration{{DeviceID: device2}, {DeviceID: device3}}
+	cfg.Folders = []FolderConfiguration{
+		{ID: "enc", Devices: []FolderDeviceConfiguration{{DeviceID: device2, EncryptionPassword: "secret"}, {DeviceID: device3}}},
+		{ID: "plain", Devices: []FolderDeviceConfiguration{{DeviceID: device2}}},
+	}
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	if pws := cfg.FolderPasswords(device2); len(pws) != 1 || pws["enc"] != "secret" {
+		t.Error("unexpected passwords for device2:", pws)
+	}
+	if pws := cfg.FolderPasswords(device3); len(pws) != 0 {
+		t.Error("unexpected passwords for device3:", pws)
+	}
+
+	// A folder that holds encrypted data can't encrypt it further.
+	cfg.Folders[0].Type = FolderTypeReceiveEncrypted
+	if err := cfg.prepare(device1); err == nil {
+		t.Error("receive encrypted folder with password accepted")
+	}
+}
+
+func TestInvalidLocalAnnUnicast(t *testing.T) {
+	xml := `<configuration version="28"><options>
+		<localAnnounceUnicast>10.8.0.0/24</localAnnounceUnicast>
+		<localAnnounceUnicast>2001:db8::1</localAnnounceUnicast>
+		<localAnnounceUnicast>192.0.2.7</localAnnounceUnicast>
+		<localAnnounceUnicast>vpn.example.com</localAnnounceUnicast>
+	</options></configuration>`
+	cfg, err := ReadXML(strings.NewReader(xml), device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The valid destinations are kept.
+	if expected := []string{"10.8.0.0/24", "192.0.2.7"}; !reflect.DeepEqual(cfg.Options.LocalAnnUnicast, expected) {
+		t.Errorf("Got %v, expected %v", cfg.Options.LocalAnnUnicast, expected)
+	}
+}
+
+func TestGUIUsers(t *testing.T) {
+	xml := `<configuration version="30"><gui><user>admin</user><users>
+		<user name="ops" role="operator"><password>hash1</password></user>
+		<user name="screen" role="viewer"><password>hash2</password></user>
+		<user name="other" role="superuser"><password>hash3</password></user>
+		<user name="plain"><password>hash4</password></user>
+	</users></gui></configuration>`
+	cfg, err := ReadXML(strings.NewReader(xml), device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GUI.User != "admin" || len(cfg.GUI.Users) != 4 {
+		t.Fatalf("Unexpected GUI config %+v", cfg.GUI)
+	}
+	expected := []GUIUserConfiguration{
+		{Name: "ops", Password: "hash1", Role: GUIRoleOperator},
+		{Name: "screen", Password: "hash2", Role: GUIRoleViewer},
+		{Name: "other", Password: "hash3", Role: GUIRoleViewer}, // unknown roles get the least access
+		{Name: "plain", Password: "hash4", Role: GUIRoleViewer}, // as do missing ones
+	}
+	if !reflect.DeepEqual(cfg.GUI.Users, expected) {
+		t.Errorf("Unexpected users %+v", cfg.GUI.Users)
+	}
+	if !cfg.GUI.IsAuthEnabled() {
+		t.Error("Auth not enabled with users")
+	}
+}
+
+func TestConfigChangedEvent(t *testing.T) {
+	cfg := New(device1)
+	cfg.Folders = []FolderConfiguration{NewFolderConfiguration(device1, "default", "", fs.FilesystemTypeBasic, "testdata")}
+	w := Wrap("/tmp/cfg", cfg)
+
+	sub := events.Default.Subscribe(events.ConfigChanged)
+	defer events.Default.Unsubscribe(sub)
+	// Returns the next event with device2 under the given key, skipping
+	// those of earlier tests that may still come in.
+	next := func(key string) map[string]interface{} {
+		t.Helper()
+		for {
+			ev, err := sub.Poll(time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := ev.Data.(map[string]interface{})
+			if strings.Contains(fmt.Sprint(data[key]), device2.String()) {
+				return data
+			}
+		}
+	}
+
+	if _, err := w.SetDevice(NewDeviceConfiguration(device2, "second")); err != nil {
+		t.Fatal(err)
+	}
+	data := next("devicesAdded")
+	if added := data["devicesAdded"].([]protocol.DeviceID); len(added) != 1 || added[0] != device2 {
+		t.Error("Unexpected added devices", added)
+	}
+
+	fcfg, _ := w.Folder("default")
+	fcfg.Devices = append(fcfg.Devices, FolderDeviceConfiguration{DeviceID: device2})
+	if _, err := w.SetFolder(fcfg); err != nil {
+		t.Fatal(err)
+	}
+	data = next("foldersShared")
+	if shared := data["foldersShared"].(map[string][]protocol.DeviceID); len(shared) != 1 || len(shared["default"]) != 1 || shared["default"][0] != device2 {
+		t.Error("Unexpected shared folders", shared)
+	}
+	if len(data["changes"].([]ConfigChange)) == 0 {
+		t.Error("Expected the changes")
+	}
+
+	if _, err := w.RemoveDevice(device2); err != nil {
+		t.Fatal(err)
+	}
+	data = next("devicesRemoved")
+	if removed := data["devicesRemoved"].([]protocol.DeviceID); len(removed) != 1 || removed[0] != device2 {
+		t.Error("Unexpected removed devices", removed)
+	}
+}
+
+func TestConfigHistory(t *testing.T) {
+	cfg := New(device1)
+	cfg.Folders = []FolderConfiguration{NewFolderConfiguration(device1, "default", "", fs.FilesystemTypeBasic, "testdata")}
+	w := Wrap("/tmp/cfg", cfg)
+
+	fcfg, _ := w.Folder("default")
+	fcfg.RescanIntervalS = 60
+	if _, err := w.SetFolder(fcfg); err != nil {
+		t.Fatal(err)
+	}
+	gui := w.GUI()
+	gui.Password = "secret"
+	if _, err := w.SetGUI(gui); err != nil {
+		t.Fatal(err)
+	}
+	// Not a change
+	if _, err := w.SetGUI(gui); err != nil {
+		t.Fatal(err)
+	}
+
+	history := w.History()
+	if len(history) != 3 || history[0].Version != 0 || len(history[0].Changes) != 0 {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+	expected := []ConfigChange{{Path: "folders[default].rescanIntervalS", From: 3600.0, To: 60.0}}
+	if !reflect.DeepEqual(history[1].Changes, expected) {
+		t.Errorf("Unexpected changes %+v", history[1].Changes)
+	}
+	expected = []ConfigChange{{Path: "gui.password", From: "(redacted)", To: "(redacted)"}}
+	if !reflect.DeepEqual(history[2].Changes, expected) {
+		t.Errorf("Unexpected changes %+v", history[2].Changes)
+	}
+
+	if _, err := w.Rollback(0); err != nil {
+		t.Fatal(err)
+	}
+	if fcfg, _ := w.Folder("default"); fcfg.RescanIntervalS != 3600 || w.GUI().Password != "" {
+		t.Errorf("Not rolled back, rescan interval %v, password %q", fcfg.RescanIntervalS, w.GUI().Password)
+	}
+	if history := w.History(); len(history) != 4 || len(history[3].Changes) != 2 {
+		t.Errorf("Expected the rollback as a version, got %+v", history)
+	}
+	if _, err := w.Rollback(42); err == nil {
+		t.Error("Expected error rolling back to a nonexistent version")
+	}
+
+	for i := 0; i < maxConfigHistory; i++ {
+		fcfg.RescanIntervalS = i + 1
+		if _, err := w.SetFolder(fcfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history := w.History(); len(history) != maxConfigHistory || history[0].Version != 4 {
+		t.Errorf("Unexpected bounded history of %d, from version %d", len(history), history[0].Version)
+	}
+}
+
+func TestDismissPending(t *testing.T) {
+	cfg := New(device1)
+	cfg.Devices = []DeviceConfiguration{{DeviceID: device2, PendingFolders: []ObservedFolder{{ID: "offered", Label: "Offered"}}}}
+	cfg.PendingDevices = []ObservedDevice{{ID: device3, Name: "three", Address: "tcp://192.0.2.3:22000"}}
+	w := Wrap("/tmp/cfg", cfg)
+
+	if pending := w.PendingDevices(); len(pending) != 1 || pending[0].ID != device3 {
+		t.Fatal("Unexpected pending devices", pending)
+	}
+	if pending := w.PendingFolders(); len(pending) != 1 || pending[0].ID != "offered" || pending[0].DeviceID != device2 {
+		t.Fatal("Unexpected pending folders", pending)
+	}
+
+	if _, err := w.DismissPendingDevice(device3); err != nil {
+		t.Fatal(err)
+	}
+	if pending := w.PendingDevices(); len(pending) != 0 {
+		t.Error("Dismissed device still pending", pending)
+	}
+	if !w.IgnoredDevice(device3) {
+		t.Error("Dismissed device not ignored")
+	}
+	if ignored := w.RawCopy().IgnoredDevices; len(ignored) != 1 || ignored[0].Name != "three" {
+		t.Error("Unexpected ignored devices", ignored)
+	}
+
+	if _, err := w.DismissPendingFolder(device2, "offered"); err != nil {
+		t.Fatal(err)
+	}
+	if pending := w.PendingFolders(); len(pending) != 0 {
+		t.Error("Dismissed folder still pending", pending)
+	}
+	if !w.IgnoredFolder(device2, "offered") {
+		t.Error("Dismissed folder not ignored")
+	}
+	if _, err := w.DismissPendingFolder(device4, "offered"); err != errNoSuchDevice {
+		t.Error("Expected unknown device, got", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// PushedConfig is the configuration a manager device pushes to the devices
+// it manages. The folders and devices are added, or replace those with the
+// same ID, others are left as they are. The options replace ours when set.
+type PushedConfig struct {
+	Folders []FolderConfiguration `json:"folders"`
+	Devices []DeviceConfiguration `json:"devices"`
+	Options *OptionsConfiguration `json:"options,omitempty"`
+}
+
+// ApplyTo merges the pushed configuration into cfg. The settings that are
+// ours alone to decide are kept: the paths of existing folders, which
+// devices manage us, the keys we share with them and our usage reporting
+// ID.
+func (p PushedConfig) ApplyTo(cfg *Configuration) error {
+	for _, folder := range p.Folders {
+		folder = folder.Copy()
+		i := folderIndex(cfg.Folders, folder.ID)
+		switch {
+		case i >= 0:
+			folder.Path = cfg.Folders[i].Path
+			cfg.Folders[i] = folder
+		case folder.Path == "":
+			return fmt.Errorf("new folder %q has no path", folder.ID)
+		default:
+			cfg.Folders = append(cfg.Folders, folder)
+		}
+	}
+
+	for _, device := range p.Devices {
+		device = device.Copy()
+		device.ConfigManager = false
+		device.ConfigPushKey = ""
+		if i := deviceIndex(cfg.Devices, device.DeviceID); i >= 0 {
+			device.ConfigManager = cfg.Devices[i].ConfigManager
+			device.ConfigPushKey = cfg.Devices[i].ConfigPushKey
+			cfg.Devices[i] = device
+		} else {
+			cfg.Devices = append(cfg.Devices, device)
+		}
+	}
+
+	if p.Options != nil {
+		uniqueID := cfg.Options.URUniqueID
+		cfg.Options = p.Options.Copy()
+		cfg.Options.URUniqueID = uniqueID
+	}
+	return nil
+}
+
+func folderIndex(folders []FolderConfiguration, id string) int {
+	for i, folder := range folders {
+		if folder.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func deviceIndex(devices []DeviceConfiguration, id protocol.DeviceID) int {
+	for i, device := range devices {
+		if device.DeviceID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// ConflictPolicy decides what happens when a pulled file was changed in
+// conflict with the local one.
+type ConflictPolicy int
+
+const (
+	ConflictCopy         ConflictPolicy = iota // default is to keep a conflict copy of the local file
+	ConflictNewest                             // the newest modification time wins
+	ConflictPreferDevice                       // changes by the configured device win
+	ConflictPreferLocal                        // the local file wins
+	ConflictPreferRemote                       // the pulled file wins
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case ConflictCopy:
+		return "copy"
+	case ConflictNewest:
+		return "newest"
+	case ConflictPreferDevice:
+		return "preferDevice"
+	case ConflictPreferLocal:
+		return "preferLocal"
+	case ConflictPreferRemote:
+		return "preferRemote"
+	default:
+		return "unknown"
+	}
+}
+
+func (p ConflictPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *ConflictPolicy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "copy":
+		*p = ConflictCopy
+	case "newest":
+		*p = ConflictNewest
+	case "preferDevice":
+		*p = ConflictPreferDevice
+	case "preferLocal":
+		*p = ConflictPreferLocal
+	case "preferRemote":
+		*p = ConflictPreferRemote
+	default:
+		*p = ConflictCopy
+	}
+	return nil
+}
@@ -30,6 +30,20 @@ type DeviceConfiguration struct {
 	IgnoredFolders           []ObservedFolder     `xml:"ignoredFolder" json:"ignoredFolders"`
 	PendingFolders           []ObservedFolder     `xml:"pendingFolder" json:"pendingFolders"`
 	MaxRequestKiB            int                  `xml:"maxRequestKiB" json:"maxRequestKiB"`
+	RequestWeight            int                  `xml:"requestWeight" json:"requestWeight"`                               // Share of incoming request capacity relative to other devices; 0 counts as 1
+	ReconnectMinIntervalS    int                  `xml:"reconnectMinIntervalS" json:"reconnectMinIntervalS"`               // Redial interval after the first failed attempt; 0 uses the global reconnection interval
+	ReconnectMaxIntervalS    int                  `xml:"reconnectMaxIntervalS" json:"reconnectMaxIntervalS"`               // The redial interval doubles per failed attempt up to this; 0 disables backoff
+	ReconnectJitterPct       int                  `xml:"reconnectJitterPct" json:"reconnectJitterPct"`                     // Random variation of the redial interval, in percent
+	RetractedFolders         []string             `xml:"retractedFolder" json:"retractedFolders"`                          // Folders whose expired share the device is asked to remove, along with their data
+	ConfigManager            bool                 `xml:"configManager" json:"configManager"`                               // Apply configuration the device pushes to us
+	ConfigPushKey            string               `xml:"configPushKey,omitempty" json:"configPushKey"`                     // Shared with the device, to sign and verify configuration pushes
+	AutoAcceptPath           string               `xml:"autoAcceptPath,omitempty" json:"autoAcceptPath"`                   // Where folders auto-accepted from the device go, like ~/Sync/{folderlabel}; empty is {folderlabel} in the default folder path
+	AutoAcceptTemplate       string               `xml:"autoAcceptTemplate,omitempty" json:"autoAcceptTemplate"`           // The folder template of folders auto-accepted from the device; empty uses the default folder template
+	AutoAcceptIntroduced     bool                 `xml:"autoAcceptIntroduced" json:"autoAcceptIntroduced"`                 // Auto-accept the folders of the devices the device introduces, the same way as its own
+	AutoAcceptDevices        []protocol.DeviceID  `xml:"autoAcceptDevice" json:"autoAcceptDevices"`                        // Of the device and those it introduces, auto-accept only the folders of these; empty is all
+	AutoAcceptFolderPattern  string               `xml:"autoAcceptFolderPattern,omitempty" json:"autoAcceptFolderPattern"` // Auto-accept only the folders with IDs matching the glob pattern; empty is all
+	AutoAcceptReceiveOnly    bool                 `xml:"autoAcceptReceiveOnly" json:"autoAcceptReceiveOnly"`               // Auto-accept folders as receive only, whatever the template says
+	AutoAcceptMaxSize        Size                 `xml:"autoAcceptMaxSize" json:"autoAcceptMaxSize"`                       // Auto-accept no more folders once those shared with the device hold this much; zero is no limit
 }
 
 func NewDeviceConfiguration(id protocol.DeviceID, name string) DeviceConfiguration {
@@ -54,6 +68,8 @@ func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
 	copy(c.IgnoredFolders, cfg.IgnoredFolders)
 	c.PendingFolders = make([]ObservedFolder, len(cfg.PendingFolders))
 	copy(c.PendingFolders, cfg.PendingFolders)
+	c.RetractedFolders = append([]string(nil), cfg.RetractedFolders...)
+	c.AutoAcceptDevices = append([]protocol.DeviceID(nil), cfg.AutoAcceptDevices...)
 	return c
 }
 
@@ -64,6 +80,17 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 	if len(cfg.AllowedNetworks) == 0 {
 		cfg.AllowedNetworks = []string{}
 	}
+	if cfg.ReconnectMinIntervalS < 0 {
+		cfg.ReconnectMinIntervalS = 0
+	}
+	if cfg.ReconnectMaxIntervalS < 0 {
+		cfg.ReconnectMaxIntervalS = 0
+	}
+	if cfg.ReconnectJitterPct < 0 {
+		cfg.ReconnectJitterPct = 0
+	} else if cfg.ReconnectJitterPct > 100 {
+		cfg.ReconnectJitterPct = 100
+	}
 
 	ignoredFolders := deduplicateObservedFoldersToMap(cfg.IgnoredFolders)
 	pendingFolders := deduplicateObservedFoldersToMap(cfg.PendingFolders)
@@ -79,6 +106,26 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 
 	cfg.IgnoredFolders = sortedObservedFolderSlice(ignoredFolders)
 	cfg.PendingFolders = sortedObservedFolderSlice(pendingFolders)
+	cfg.RetractedFolders = retractedFolders(cfg.RetractedFolders, sharedFolders)
+}
+
+// retractedFolders returns the sorted, deduplicated folders, except those
+// shared with the device again.
+func retractedFolders(folders, sharedFolders []string) []string {
+	shared := make(map[string]struct{}, len(sharedFolders))
+	for _, folder := range sharedFolders {
+		shared[folder] = struct{}{}
+	}
+	var retracted []string
+	for _, folder := range folders {
+		if _, ok := shared[folder]; ok {
+			continue
+		}
+		shared[folder] = struct{}{}
+		retracted = append(retracted, folder)
+	}
+	sort.Strings(retracted)
+	return retracted
 }
 
 func (cfg *DeviceConfiguration) IgnoredFolder(folder string) bool {
@@ -0,0 +1,81 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A DeviceGroup is a named set of devices that folders can be shared with.
+// A folder shared with a group is shared with each of its devices, those
+// added to the group later included, until they leave it.
+type DeviceGroup struct {
+	Name    string              `xml:"name,attr" json:"name"`
+	Devices []protocol.DeviceID `xml:"device" json:"devices"`
+}
+
+func (g DeviceGroup) Copy() DeviceGroup {
+	c := g
+	c.Devices = append([]protocol.DeviceID(nil), g.Devices...)
+	return c
+}
+
+// Has returns true if the device is a member of the group.
+func (g DeviceGroup) Has(id protocol.DeviceID) bool {
+	for _, dev := range g.Devices {
+		if dev == id {
+			return true
+		}
+	}
+	return false
+}
+
+// shareWithGroups adds the devices of the groups the folders are shared with
+// to the folders, and removes those that were added for a group they are
+// no longer part of, or that the folder is no longer shared with. Devices
+// the folder is shared with directly are left alone.
+func (cfg *Configuration) shareWithGroups(existingDevices map[protocol.DeviceID]bool) {
+	groups := make(map[string]DeviceGroup, len(cfg.DeviceGroups))
+	for _, group := range cfg.DeviceGroups {
+		groups[group.Name] = group
+	}
+
+	for i := range cfg.Folders {
+		folder := &cfg.Folders[i]
+		// The first of the folder's groups the device is a member of.
+		groupOf := func(id protocol.DeviceID) string {
+			for _, name := range folder.Groups {
+				if groups[name].Has(id) {
+					return name
+				}
+			}
+			return ""
+		}
+
+		devices := folder.Devices[:0]
+		present := make(map[protocol.DeviceID]bool, len(folder.Devices))
+		for _, dev := range folder.Devices {
+			if dev.Group != "" {
+				if dev.Group = groupOf(dev.DeviceID); dev.Group == "" {
+					continue
+				}
+			}
+			devices = append(devices, dev)
+			present[dev.DeviceID] = true
+		}
+		for _, name := range folder.Groups {
+			for _, id := range groups[name].Devices {
+				if present[id] || id == cfg.MyID || !existingDevices[id] {
+					continue
+				}
+				devices = append(devices, FolderDeviceConfiguration{DeviceID: id, Group: name})
+				present[id] = true
+			}
+		}
+		folder.Devices = devices
+	}
+}
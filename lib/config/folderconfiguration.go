@@ -10,9 +10,12 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sha256"
 	"github.com/syncthing/syncthing/lib/util"
 	"github.com/syncthing/syncthing/lib/versioner"
 )
@@ -47,13 +50,42 @@ type FolderConfiguration struct {
 	ScanProgressIntervalS   int                         `xml:"scanProgressIntervalS" json:"scanProgressIntervalS"` // Set to a negative value to disable. Value of 0 will get replaced with value of 2 (default value)
 	PullerPauseS            int                         `xml:"pullerPauseS" json:"pullerPauseS"`
 	MaxConflicts            int                         `xml:"maxConflicts" json:"maxConflicts" default:"-1"`
+	ConflictMaxAgeS         int                         `xml:"conflictMaxAgeS" json:"conflictMaxAgeS"`                                // Conflict copies older than this are removed by the periodic cleanup. Zero or less keeps them.
+	CleanConflictsIntervalS int                         `xml:"cleanConflictsIntervalS" json:"cleanConflictsIntervalS" default:"3600"` // Set to zero or less to disable the periodic conflict cleanup.
+	ConflictPolicy          ConflictPolicy              `xml:"conflictPolicy" json:"conflictPolicy"`
+	ConflictPreferDevice    protocol.DeviceID           `xml:"conflictPreferDevice" json:"conflictPreferDevice"` // The device whose changes win with the preferDevice policy.
 	DisableSparseFiles      bool                        `xml:"disableSparseFiles" json:"disableSparseFiles"`
 	DisableTempIndexes      bool                        `xml:"disableTempIndexes" json:"disableTempIndexes"`
+	DisableSymlinks         bool                        `xml:"disableSymlinks" json:"disableSymlinks"`
 	Paused                  bool                        `xml:"paused" json:"paused"`
 	WeakHashThresholdPct    int                         `xml:"weakHashThresholdPct" json:"weakHashThresholdPct"` // Use weak hash if more than X percent of the file has changed. Set to -1 to always use weak hash.
 	MarkerName              string                      `xml:"markerName" json:"markerName"`
 	UseLargeBlocks          bool                        `xml:"useLargeBlocks" json:"useLargeBlocks" default:"true"`
 	CopyOwnershipFromParent bool                        `xml:"copyOwnershipFromParent" json:"copyOwnershipFromParent"`
+	IndexSnapshotIntervalS  int                         `xml:"indexSnapshotIntervalS" json:"indexSnapshotIntervalS" default:"21600"` // Set to zero or less to disable index snapshots.
+	IndexSnapshotsKeep      int                         `xml:"indexSnapshotsKeep" json:"indexSnapshotsKeep" default:"28"`            // Set to zero or less to keep all index snapshots.
+	IdleIOPriority          bool                        `xml:"idleIOPriority" json:"idleIOPriority"`                                 // Scan and serve requests in the idle I/O scheduling class, where supported.
+	ScanMaxRateMBps         int                         `xml:"scanMaxRateMBps" json:"scanMaxRateMBps"`                               // Limits the rate at which scans read files, in MB/s. Zero or less is unlimited.
+	ScanFileSleepMs         int                         `xml:"scanFileSleepMs" json:"scanFileSleepMs"`                               // Pause after hashing each file while scanning, to let other disk access through.
+	Downgrades              []FolderDowngrade           `xml:"downgrade" json:"downgrades"`                                          // Features disabled automatically as the filesystem doesn't support them.
+	ShareIgnores            bool                        `xml:"shareIgnores" json:"shareIgnores"`                                     // Announce our ignore patterns to the devices sharing the folder.
+	IgnoresFrom             protocol.DeviceID           `xml:"ignoresFrom" json:"ignoresFrom"`                                       // Use the ignore patterns announced by this device instead of our own.
+	FolderDescription       string                      `xml:"description" json:"description" restart:"false"`                       // Describes the folder alongside the label.
+	Metadata                []FolderMetadata            `xml:"metadata" json:"metadata" restart:"false"`                             // Key/value pairs for integrations.
+	ShareMetadata           bool                        `xml:"shareMetadata" json:"shareMetadata"`                                   // Share the label, description and metadata with the devices sharing the folder that do the same, the latest change winning.
+	MetadataChanged         time.Time                   `xml:"metadataChanged" json:"metadataChanged" restart:"false"`               // When the shared label, description or metadata last changed, here or on another device.
+	LocalLabel              bool                        `xml:"localLabel" json:"localLabel" restart:"false"`                         // Keep the label as set here, rather than taking it from other devices.
+	LocalDescription        bool                        `xml:"localDescription" json:"localDescription" restart:"false"`             // Keep the description as set here, rather than taking it from other devices.
+	AllowRemotePurge        bool                        `xml:"allowRemotePurge" json:"allowRemotePurge"`                             // Remove the folder and its data when a device sharing it asks to, as its share with us expired.
+	IgnoreRemotePerms       bool                        `xml:"ignoreRemotePerms" json:"ignoreRemotePerms"`                           // Don't apply permission changes of other devices to existing files. New files get the permissions as announced.
+	IgnoreLocalPerms        bool                        `xml:"ignoreLocalPerms" json:"ignoreLocalPerms"`                             // Don't announce permissions, nor changes to them.
+	IgnorePermBits          string                      `xml:"ignorePermBits" json:"ignorePermBits"`                                 // Octal permission bits that are never synced, e.g. "0111" to keep the executable bits as set on each device.
+	ScrubBlocksPerMin       int                         `xml:"scrubBlocksPerMin" json:"scrubBlocksPerMin"`                           // Local blocks verified per minute in the background, corrupt ones being repaired from other devices. Zero or less disables scrubbing.
+	TempDir                 string                      `xml:"tempDir" json:"tempDir"`                                               // Directory where the temporary files of files being pulled are written, instead of next to the files. Empty keeps them next to the files.
+	Template                string                      `xml:"template" json:"template" restart:"false"`                             // The folder template the folder was created from, if any.
+	VanishedPolicy          VanishedPolicy              `xml:"vanishedPolicy" json:"vanishedPolicy"`                                 // What happens to the pulled data of a file deleted on all other devices before it was complete.
+	Groups                  []string                    `xml:"group" json:"groups" restart:"false"`                                  // The device groups the folder is shared with, as well as the devices it's shared with directly.
+	QuotaBytes              int64                       `xml:"quotaBytes" json:"quotaBytes"`                                         // The local data the folder may hold; pulling stops short of it. Zero or less is unlimited.
 
 	cachedFilesystem fs.Filesystem
 
@@ -63,8 +95,26 @@ type FolderConfiguration struct {
 }
 
 type FolderDeviceConfiguration struct {
-	DeviceID     protocol.DeviceID `xml:"id,attr" json:"deviceID"`
-	IntroducedBy protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	DeviceID           protocol.DeviceID `xml:"id,attr" json:"deviceID"`
+	IntroducedBy       protocol.DeviceID `xml:"introducedBy,attr" json:"introducedBy"`
+	IndexFilter        []string          `xml:"indexFilter" json:"indexFilter"`                         // Ignore patterns of files never announced to this device.
+	ExpiresAt          time.Time         `xml:"expiresAt,attr" json:"expiresAt"`                        // The folder is shared with the device until then, after which the device is removed from it. Zero never expires.
+	PurgeOnExpiry      bool              `xml:"purgeOnExpiry,attr,omitempty" json:"purgeOnExpiry"`      // Ask the device to remove the folder and its data when the share expires.
+	Group              string            `xml:"group,attr,omitempty" json:"group"`                      // The device group the folder is shared with the device through, if not directly.
+	EncryptionPassword string            `xml:"encryptionPassword,omitempty" json:"encryptionPassword"` // Encrypts all data sent to the device with this password, so that it only stores ciphertext. Empty sends it in plain.
+}
+
+// Expired returns true if the share with the device expired by then.
+func (f FolderDeviceConfiguration) Expired(now time.Time) bool {
+	return !f.ExpiresAt.IsZero() && !now.Before(f.ExpiresAt)
+}
+
+// A FolderDowngrade records a setting that was changed automatically
+// because the filesystem of the folder doesn't support the feature.
+type FolderDowngrade struct {
+	Setting string    `xml:"setting,attr" json:"setting"`
+	Reason  string    `xml:"reason,attr" json:"reason"`
+	When    time.Time `xml:"when,attr" json:"when"`
 }
 
 func NewFolderConfiguration(myID protocol.DeviceID, id, label string, fsType fs.FilesystemType, path string) FolderConfiguration {
@@ -86,7 +136,15 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	c := f
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
+	for i := range c.Devices {
+		c.Devices[i].IndexFilter = append([]string(nil), f.Devices[i].IndexFilter...)
+	}
 	c.Versioning = f.Versioning.Copy()
+	c.Downgrades = make([]FolderDowngrade, len(f.Downgrades))
+	copy(c.Downgrades, f.Downgrades)
+	c.Metadata = make([]FolderMetadata, len(f.Metadata))
+	copy(c.Metadata, f.Metadata)
+	c.Groups = append([]string(nil), f.Groups...)
 	return c
 }
 
@@ -100,6 +158,25 @@ func (f FolderConfiguration) Filesystem() fs.Filesystem {
 	return f.cachedFilesystem
 }
 
+// TempFilesystem returns the filesystem holding the temporary files of
+// files being pulled, which is the folder unless TempDir is set.
+func (f FolderConfiguration) TempFilesystem() fs.Filesystem {
+	if f.TempDir == "" {
+		return f.Filesystem()
+	}
+	return fs.NewFilesystem(f.FilesystemType, f.TempDir)
+}
+
+// TempName returns the name of the temporary file of the given file in the
+// TempFilesystem. In a TempDir, which may be shared by several folders, the
+// temporary files are all at the top, named after the folder and file.
+func (f FolderConfiguration) TempName(name string) string {
+	if f.TempDir == "" {
+		return fs.TempName(name)
+	}
+	return fs.TempName(fmt.Sprintf("%x", sha256.Sum256([]byte(f.ID+"/"+name))))
+}
+
 func (f FolderConfiguration) Versioner() versioner.Versioner {
 	if f.Versioning.Type == "" {
 		return nil
@@ -109,7 +186,13 @@ func (f FolderConfiguration) Versioner() versioner.Versioner {
 		panic(fmt.Sprintf("Requested versioning type %q that does not exist", f.Versioning.Type))
 	}
 
-	return versionerFactory(f.ID, f.Filesystem(), f.Versioning.Params)
+	params := f.Versioning.Params
+	if f.Versioning.FSPath != "" {
+		params = f.Versioning.Copy().Params
+		params["fsPath"] = f.Versioning.FSPath
+		params["fsType"] = f.Versioning.FSType.String()
+	}
+	return versionerFactory(f.ID, f.Filesystem(), params)
 }
 
 func (f *FolderConfiguration) CreateMarker() error {
@@ -257,10 +340,63 @@ func (f FolderConfiguration) RequiresRestartOnly() FolderConfiguration {
 	return copy
 }
 
+// IgnoredLocalPermBits returns the permission bits of which local changes
+// aren't announced.
+func (f FolderConfiguration) IgnoredLocalPermBits() uint32 {
+	if f.IgnorePerms || f.IgnoreLocalPerms {
+		return 0777
+	}
+	return f.IgnoredPermBits()
+}
+
+// IgnoredRemotePermBits returns the permission bits of which changes by
+// other devices aren't applied to existing files.
+func (f FolderConfiguration) IgnoredRemotePermBits() uint32 {
+	if f.IgnorePerms || f.IgnoreRemotePerms {
+		return 0777
+	}
+	return f.IgnoredPermBits()
+}
+
+// IgnoredPermBits returns the permission bits that are never synced.
+func (f FolderConfiguration) IgnoredPermBits() uint32 {
+	bits, err := strconv.ParseUint(f.IgnorePermBits, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(bits) & 0777
+}
+
+// checkPermBits returns an error if the ignored permission bits aren't
+// octal permission bits.
+func (f FolderConfiguration) checkPermBits() error {
+	if f.IgnorePermBits == "" {
+		return nil
+	}
+	if bits, err := strconv.ParseUint(f.IgnorePermBits, 8, 32); err != nil || bits&^0777 != 0 {
+		return fmt.Errorf("folder %q: invalid ignored permission bits %q in configuration", f.ID, f.IgnorePermBits)
+	}
+	return nil
+}
+
+// checkEncryption returns an error if the folder is receive encrypted, and
+// so has only ciphertext, but is to encrypt data for any device.
+func (f FolderConfiguration) checkEncryption() error {
+	if f.Type != FolderTypeReceiveEncrypted {
+		return nil
+	}
+	for _, dev := range f.Devices {
+		if dev.EncryptionPassword != "" {
+			return fmt.Errorf("folder %q: receive encrypted folder can't have an encryption password for device %s", f.ID, dev.DeviceID)
+		}
+	}
+	return nil
+}
+
 func (f *FolderConfiguration) SharedWith(device protocol.DeviceID) bool {
 	for _, dev := range f.Devices {
 		if dev.DeviceID == device {
-			return true
+			return !dev.Expired(time.Now())
 		}
 	}
 	return false
@@ -0,0 +1,91 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// FolderMetadata is a key/value pair attached to a folder, for use by
+// integrations. With ShareMetadata it's shared with the other devices,
+// unless it's kept local.
+type FolderMetadata struct {
+	Key   string `xml:"key,attr" json:"key"`
+	Value string `xml:",chardata" json:"value"`
+	Local bool   `xml:"local,attr,omitempty" json:"local"` // Keep the value as set here, rather than taking it from other devices.
+}
+
+// checkMetadata returns an error if a metadata key of the folder is empty
+// or duplicated.
+func (f FolderConfiguration) checkMetadata() error {
+	seen := make(map[string]struct{}, len(f.Metadata))
+	for _, md := range f.Metadata {
+		if md.Key == "" {
+			return fmt.Errorf("folder %q: metadata with empty key in configuration", f.ID)
+		}
+		if _, ok := seen[md.Key]; ok {
+			return fmt.Errorf("folder %q: duplicate metadata key %q in configuration", f.ID, md.Key)
+		}
+		seen[md.Key] = struct{}{}
+	}
+	return nil
+}
+
+// sharedMetadataEqual returns true if the label, description and metadata
+// shared with other devices are the same for both folders.
+func (f FolderConfiguration) sharedMetadataEqual(o FolderConfiguration) bool {
+	if f.LocalLabel != o.LocalLabel || f.LocalDescription != o.LocalDescription {
+		return false
+	}
+	if !f.LocalLabel && f.Label != o.Label {
+		return false
+	}
+	if !f.LocalDescription && f.FolderDescription != o.FolderDescription {
+		return false
+	}
+	a, b := f.sharedMetadata(), o.sharedMetadata()
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+func (f FolderConfiguration) sharedMetadata() map[string]string {
+	shared := make(map[string]string, len(f.Metadata))
+	for _, md := range f.Metadata {
+		if !md.Local {
+			shared[md.Key] = md.Value
+		}
+	}
+	return shared
+}
+
+// stampMetadataChanges sets MetadataChanged of the folders in to whose
+// shared label, description or metadata differ from those in from, unless
+// it was set along with them, i.e. they were taken from another device.
+func stampMetadataChanges(from, to []FolderConfiguration) {
+	fromFolders := make(map[string]FolderConfiguration, len(from))
+	for _, folder := range from {
+		fromFolders[folder.ID] = folder
+	}
+	now := time.Now()
+	for i := range to {
+		fromFolder, ok := fromFolders[to[i].ID]
+		if !ok || !fromFolder.MetadataChanged.Equal(to[i].MetadataChanged) {
+			continue
+		}
+		if !fromFolder.sharedMetadataEqual(to[i]) {
+			to[i].MetadataChanged = now
+		}
+	}
+}
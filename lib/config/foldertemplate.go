@@ -0,0 +1,49 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+// A FolderTemplate is a named set of folder settings that new folders take
+// on when created from it. Changing the template later doesn't change the
+// folders created from it.
+type FolderTemplate struct {
+	Name            string                  `xml:"name,attr" json:"name"`
+	Type            FolderType              `xml:"type" json:"type"`
+	Versioning      VersioningConfiguration `xml:"versioning" json:"versioning"`
+	RescanIntervalS int                     `xml:"rescanIntervalS" json:"rescanIntervalS" default:"3600"`
+	Order           PullOrder               `xml:"order" json:"order"`
+	Ignores         []string                `xml:"ignore" json:"ignores"` // The lines of the .stignore of new folders, unless they already have one.
+}
+
+func NewFolderTemplate(name string) FolderTemplate {
+	t := FolderTemplate{
+		Name: name,
+	}
+	util.SetDefaults(&t)
+	return t
+}
+
+func (t FolderTemplate) Copy() FolderTemplate {
+	c := t
+	c.Versioning = t.Versioning.Copy()
+	c.Ignores = make([]string, len(t.Ignores))
+	copy(c.Ignores, t.Ignores)
+	return c
+}
+
+// Apply sets the settings of the template on the folder, and records that
+// it was created from it.
+func (t FolderTemplate) Apply(f *FolderConfiguration) {
+	f.Type = t.Type
+	f.Versioning = t.Versioning.Copy()
+	f.RescanIntervalS = t.RescanIntervalS
+	f.Order = t.Order
+	f.Template = t.Name
+}
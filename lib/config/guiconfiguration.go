@@ -13,22 +13,34 @@ import (
 )
 
 type GUIConfiguration struct {
-	Enabled                   bool     `xml:"enabled,attr" json:"enabled" default:"true"`
-	RawAddress                string   `xml:"address" json:"address" default:"127.0.0.1:8384"`
-	User                      string   `xml:"user,omitempty" json:"user"`
-	Password                  string   `xml:"password,omitempty" json:"password"`
-	AuthMode                  AuthMode `xml:"authMode,omitempty" json:"authMode"`
-	RawUseTLS                 bool     `xml:"tls,attr" json:"useTLS"`
-	APIKey                    string   `xml:"apikey,omitempty" json:"apiKey"`
-	InsecureAdminAccess       bool     `xml:"insecureAdminAccess,omitempty" json:"insecureAdminAccess"`
-	Theme                     string   `xml:"theme" json:"theme" default:"default"`
-	Debugging                 bool     `xml:"debugging,attr" json:"debugging"`
-	InsecureSkipHostCheck     bool     `xml:"insecureSkipHostcheck,omitempty" json:"insecureSkipHostcheck"`
-	InsecureAllowFrameLoading bool     `xml:"insecureAllowFrameLoading,omitempty" json:"insecureAllowFrameLoading"`
+	Enabled                   bool                   `xml:"enabled,attr" json:"enabled" default:"true"`
+	RawAddress                string                 `xml:"address" json:"address" default:"127.0.0.1:8384"`
+	User                      string                 `xml:"user,omitempty" json:"user"`
+	Password                  string                 `xml:"password,omitempty" json:"password"`
+	AuthMode                  AuthMode               `xml:"authMode,omitempty" json:"authMode"`
+	RawUseTLS                 bool                   `xml:"tls,attr" json:"useTLS"`
+	APIKey                    string                 `xml:"apikey,omitempty" json:"apiKey"`
+	InsecureAdminAccess       bool                   `xml:"insecureAdminAccess,omitempty" json:"insecureAdminAccess"`
+	Theme                     string                 `xml:"theme" json:"theme" default:"default"`
+	Debugging                 bool                   `xml:"debugging,attr" json:"debugging"`
+	InsecureSkipHostCheck     bool                   `xml:"insecureSkipHostcheck,omitempty" json:"insecureSkipHostcheck"`
+	InsecureAllowFrameLoading bool                   `xml:"insecureAllowFrameLoading,omitempty" json:"insecureAllowFrameLoading"`
+	ClientCertFingerprints    []string               `xml:"clientCertFingerprint" json:"clientCertFingerprints"`
+	ClientCAFile              string                 `xml:"clientCAFile,omitempty" json:"clientCAFile"`
+	HealthEndpoints           bool                   `xml:"healthEndpoints,omitempty" json:"healthEndpoints"`
+	Users                     []GUIUserConfiguration `xml:"users>user" json:"users"`
+}
+
+// GUIUserConfiguration is a GUI user in addition to the one of User and
+// Password, who is always an admin.
+type GUIUserConfiguration struct {
+	Name     string  `xml:"name,attr" json:"name"`
+	Password string  `xml:"password" json:"password"` // bcrypt hash
+	Role     GUIRole `xml:"role,attr" json:"role"`
 }
 
 func (c GUIConfiguration) IsAuthEnabled() bool {
-	return c.AuthMode == AuthModeLDAP || (len(c.User) > 0 && len(c.Password) > 0)
+	return c.AuthMode == AuthModeLDAP || c.AuthMode == AuthModeOIDC || (len(c.User) > 0 && len(c.Password) > 0) || len(c.Users) > 0
 }
 
 func (c GUIConfiguration) IsOverridden() bool {
@@ -131,6 +143,14 @@ func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
 	}
 }
 
+// IsClientCertAuthEnabled returns true when client certificates, given by
+// fingerprint or issuing CA, are accepted in place of an API key.
+func (c GUIConfiguration) IsClientCertAuthEnabled() bool {
+	return len(c.ClientCertFingerprints) > 0 || c.ClientCAFile != ""
+}
+
 func (c GUIConfiguration) Copy() GUIConfiguration {
+	c.ClientCertFingerprints = append([]string(nil), c.ClientCertFingerprints...)
+	c.Users = append([]GUIUserConfiguration(nil), c.Users...)
 	return c
 }
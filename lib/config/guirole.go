@@ -0,0 +1,50 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// GUIRole is what a GUI user may do. Admins may do anything, operators may
+// trigger operations like scans and restarts but not change the config,
+// and viewers may only look. Roles are ordered by privilege.
+type GUIRole int
+
+const (
+	GUIRoleViewer GUIRole = iota // default is viewer, so a missing role gets the least access
+	GUIRoleOperator
+	GUIRoleAdmin
+)
+
+func (t GUIRole) String() string {
+	switch t {
+	case GUIRoleAdmin:
+		return "admin"
+	case GUIRoleOperator:
+		return "operator"
+	case GUIRoleViewer:
+		return "viewer"
+	default:
+		return "unknown"
+	}
+}
+
+func (t GUIRole) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *GUIRole) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "operator":
+		*t = GUIRoleOperator
+	case "viewer":
+		*t = GUIRoleViewer
+	case "admin":
+		*t = GUIRoleAdmin
+	default:
+		// Unknown roles get the least access
+		*t = GUIRoleViewer
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The most config versions kept for rolling back to, the oldest being
+// dropped first.
+const maxConfigHistory = 25
+
+var errNoConfigVersion = errors.New("no such config version")
+
+// A ConfigVersion is a configuration that was in effect, and how it differs
+// from the one before it.
+type ConfigVersion struct {
+	Version int            `json:"version"`
+	When    time.Time      `json:"when"`
+	Changes []ConfigChange `json:"changes"`
+
+	cfg Configuration
+}
+
+// A ConfigChange is a changed setting, named by its path in the JSON
+// config, like folders[default].rescanIntervalS. Secrets are redacted.
+type ConfigChange struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// configHistory keeps the config versions made by replacing the config,
+// starting with the one the wrapper was created with.
+type configHistory struct {
+	versions []ConfigVersion // oldest first
+	next     int
+}
+
+// record adds the version, if it changes anything, and returns it.
+func (h *configHistory) record(from, to Configuration) (ConfigVersion, bool) {
+	if len(h.versions) == 0 {
+		h.versions = append(h.versions, ConfigVersion{Version: h.next, When: time.Now(), cfg: from.Copy()})
+		h.next++
+	}
+	changes := diffConfigs(from, to)
+	if len(changes) == 0 {
+		return ConfigVersion{}, false
+	}
+	version := ConfigVersion{Version: h.next, When: time.Now(), Changes: changes}
+	version.cfg = to.Copy()
+	h.versions = append(h.versions, version)
+	h.next++
+	if len(h.versions) > maxConfigHistory {
+		h.versions = append(h.versions[:0], h.versions[len(h.versions)-maxConfigHistory:]...)
+	}
+	version.cfg = Configuration{}
+	return version, true
+}
+
+func (h *configHistory) list() []ConfigVersion {
+	res := make([]ConfigVersion, len(h.versions))
+	copy(res, h.versions)
+	for i := range res {
+		res[i].cfg = Configuration{}
+	}
+	return res
+}
+
+func (h *configHistory) config(version int) (Configuration, error) {
+	for _, v := range h.versions {
+		if v.Version == version {
+			return v.cfg.Copy(), nil
+		}
+	}
+	return Configuration{}, errNoConfigVersion
+}
+
+// History returns the config versions that can be rolled back to, oldest
+// first.
+func (w *wrapper) History() []ConfigVersion {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.history.list()
+}
+
+// Rollback replaces the config with the given version of it, as a single
+// change. The second factor and the pending devices stay as they are, as
+// they aren't changed by editing the config.
+func (w *wrapper) Rollback(version int) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	to, err := w.history.config(version)
+	if err != nil {
+		return noopWaiter{}, err
+	}
+	to.TOTP = w.cfg.TOTP.Copy()
+	to.PendingDevices = append([]ObservedDevice(nil), w.cfg.PendingDevices...)
+	return w.replaceLocked(to)
+}
+
+// emitConfigChanged logs the changes of the version for the audit log,
+// with the devices added and removed, and the changed shares of folders, by
+// folder ID, spelled out.
+func emitConfigChanged(version ConfigVersion, from, to Configuration) {
+	self := from.MyID
+	added, removed := diffDevices(deviceIDs(from.Devices), deviceIDs(to.Devices), self)
+	shared := make(map[string][]protocol.DeviceID)
+	unshared := make(map[string][]protocol.DeviceID)
+	fromFolders := make(map[string]FolderConfiguration, len(from.Folders))
+	for _, folder := range from.Folders {
+		fromFolders[folder.ID] = folder
+	}
+	toFolders := make(map[string]FolderConfiguration, len(to.Folders))
+	for _, folder := range to.Folders {
+		toFolders[folder.ID] = folder
+		a, r := diffDevices(folderDeviceIDs(fromFolders[folder.ID]), folderDeviceIDs(folder), self)
+		if len(a) > 0 {
+			shared[folder.ID] = a
+		}
+		if len(r) > 0 {
+			unshared[folder.ID] = r
+		}
+	}
+	for _, folder := range from.Folders {
+		if _, ok := toFolders[folder.ID]; ok {
+			continue
+		}
+		if _, r := diffDevices(folderDeviceIDs(folder), nil, self); len(r) > 0 {
+			unshared[folder.ID] = r
+		}
+	}
+
+	events.Default.Log(events.ConfigChanged, map[string]interface{}{
+		"version":         version.Version,
+		"changes":         version.Changes,
+		"devicesAdded":    added,
+		"devicesRemoved":  removed,
+		"foldersShared":   shared,
+		"foldersUnshared": unshared,
+	})
+}
+
+func deviceIDs(devices []DeviceConfiguration) []protocol.DeviceID {
+	ids := make([]protocol.DeviceID, len(devices))
+	for i, dev := range devices {
+		ids[i] = dev.DeviceID
+	}
+	return ids
+}
+
+func folderDeviceIDs(folder FolderConfiguration) []protocol.DeviceID {
+	ids := make([]protocol.DeviceID, len(folder.Devices))
+	for i, dev := range folder.Devices {
+		ids[i] = dev.DeviceID
+	}
+	return ids
+}
+
+// diffDevices returns the devices only in b, and those only in a, other
+// than the local device.
+func diffDevices(a, b []protocol.DeviceID, self protocol.DeviceID) (added, removed []protocol.DeviceID) {
+	in := func(id protocol.DeviceID, ids []protocol.DeviceID) bool {
+		for _, other := range ids {
+			if other == id {
+				return true
+			}
+		}
+		return false
+	}
+	added, removed = []protocol.DeviceID{}, []protocol.DeviceID{}
+	for _, id := range b {
+		if id != self && !in(id, a) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range a {
+		if id != self && !in(id, b) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// diffConfigs returns the settings that differ between the configs,
+// comparing their JSON, with the elements of lists of folders, devices and
+// the like identified by their ID or name rather than position.
+func diffConfigs(from, to Configuration) []ConfigChange {
+	var a, b interface{}
+	if err := jsonRoundTrip(from, &a); err != nil {
+		return []ConfigChange{{Path: "", From: err.Error()}}
+	}
+	if err := jsonRoundTrip(to, &b); err != nil {
+		return []ConfigChange{{Path: "", To: err.Error()}}
+	}
+	var changes []ConfigChange
+	diffValues("", a, b, &changes)
+	return changes
+}
+
+func jsonRoundTrip(cfg Configuration, v interface{}) error {
+	bs, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+// The keys of secrets, whose values don't show in changes.
+var secretConfigKeys = map[string]bool{
+	"password":           true,
+	"apiKey":             true,
+	"clientSecret":       true,
+	"secret":             true,
+	"configPushKey":      true,
+	"encryptionPassword": true,
+}
+
+// The keys which identify the elements of lists, in order of preference.
+var identifyingConfigKeys = []string{"id", "deviceID", "name", "url"}
+
+func diffValues(path string, a, b interface{}, changes *[]ConfigChange) {
+	if isEmptyValue(a) && isEmptyValue(b) {
+		// Copying turns nil lists into empty ones.
+		return
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			diffMaps(path, av, bv, changes)
+			return
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			diffSlices(path, av, bv, changes)
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, ConfigChange{Path: path, From: a, To: b})
+	}
+}
+
+func diffMaps(path string, a, b map[string]interface{}, changes *[]ConfigChange) {
+	keys := make(map[string]struct{}, len(a))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		sub := k
+		if path != "" {
+			sub = path + "." + k
+		}
+		if secretConfigKeys[k] {
+			if !reflect.DeepEqual(a[k], b[k]) {
+				*changes = append(*changes, ConfigChange{Path: sub, From: "(redacted)", To: "(redacted)"})
+			}
+			continue
+		}
+		diffValues(sub, a[k], b[k], changes)
+	}
+}
+
+// diffSlices compares lists element by element, where the elements have an
+// identifying key, and as a whole otherwise.
+func diffSlices(path string, a, b []interface{}, changes *[]ConfigChange) {
+	key := sliceIdentity(a, b)
+	if key == "" {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, ConfigChange{Path: path, From: a, To: b})
+		}
+		return
+	}
+
+	index := func(s []interface{}) (map[string]interface{}, []string) {
+		m := make(map[string]interface{}, len(s))
+		var order []string
+		for _, e := range s {
+			id := fmt.Sprint(e.(map[string]interface{})[key])
+			if _, ok := m[id]; !ok {
+				order = append(order, id)
+			}
+			m[id] = e
+		}
+		return m, order
+	}
+	am, aorder := index(a)
+	bm, border := index(b)
+
+	for _, id := range aorder {
+		sub := fmt.Sprintf("%s[%s]", path, id)
+		if bv, ok := bm[id]; ok {
+			diffValues(sub, am[id], bv, changes)
+		} else {
+			*changes = append(*changes, ConfigChange{Path: sub, From: redacted(am[id])})
+		}
+	}
+	for _, id := range border {
+		if _, ok := am[id]; !ok {
+			*changes = append(*changes, ConfigChange{Path: fmt.Sprintf("%s[%s]", path, id), To: redacted(bm[id])})
+		}
+	}
+}
+
+// sliceIdentity returns the key identifying the elements of both lists, or
+// the empty string if there isn't one.
+func sliceIdentity(a, b []interface{}) string {
+	if len(a) == 0 && len(b) == 0 {
+		return ""
+	}
+next:
+	for _, key := range identifyingConfigKeys {
+		for _, s := range [][]interface{}{a, b} {
+			for _, e := range s {
+				m, ok := e.(map[string]interface{})
+				if !ok {
+					return ""
+				}
+				if _, ok := m[key]; !ok {
+					continue next
+				}
+			}
+		}
+		return key
+	}
+	return ""
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// redacted returns the value, without any secrets, when it's a whole
+// element that was added or removed.
+func redacted(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	res := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		if secretConfigKeys[k] {
+			res[k] = "(redacted)"
+		} else {
+			res[k] = redacted(e)
+		}
+	}
+	return res
+}
@@ -0,0 +1,21 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// An IgnorePatternSet is a named list of ignore patterns, which the ignore
+// files of any folder can include with "#include-config:<name>".
+type IgnorePatternSet struct {
+	Name     string   `xml:"name,attr" json:"name"`
+	Patterns []string `xml:"pattern" json:"patterns"`
+}
+
+func (s IgnorePatternSet) Copy() IgnorePatternSet {
+	cp := s
+	cp.Patterns = make([]string, len(s.Patterns))
+	copy(cp.Patterns, s.Patterns)
+	return cp
+}
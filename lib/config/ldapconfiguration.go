@@ -7,12 +7,23 @@
 package config
 
 type LDAPConfiguration struct {
-	Address            string        `xml:"address,omitempty" json:"addresd"`
-	BindDN             string        `xml:"bindDN,omitempty" json:"bindDN"`
-	Transport          LDAPTransport `xml:"transport,omitempty" json:"transport"`
-	InsecureSkipVerify bool          `xml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify" default:"false"`
+	Address            string          `xml:"address,omitempty" json:"addresd"`
+	BindDN             string          `xml:"bindDN,omitempty" json:"bindDN"`
+	Transport          LDAPTransport   `xml:"transport,omitempty" json:"transport"`
+	InsecureSkipVerify bool            `xml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify" default:"false"`
+	SearchBaseDN       string          `xml:"searchBaseDN,omitempty" json:"searchBaseDN"` // Where to look up the user for their groups
+	SearchFilter       string          `xml:"searchFilter,omitempty" json:"searchFilter"` // Finds the user, with %s as the escaped username, e.g. "(sAMAccountName=%s)"
+	GroupRoles         []LDAPGroupRole `xml:"groupRole" json:"groupRoles"`                // GUI roles of the members of groups. Without any, every user who can bind is an admin.
+}
+
+// LDAPGroupRole gives the members of the group, by DN as in the memberOf
+// attribute of the user, the role.
+type LDAPGroupRole struct {
+	Group string  `xml:"group,attr" json:"group"`
+	Role  GUIRole `xml:"role,attr" json:"role"`
 }
 
 func (c LDAPConfiguration) Copy() LDAPConfiguration {
+	c.GroupRoles = append([]LDAPGroupRole(nil), c.GroupRoles...)
 	return c
 }
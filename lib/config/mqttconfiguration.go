@@ -0,0 +1,32 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// MQTTConfiguration describes an MQTT broker that events are published to.
+// Publishing is disabled while Address is empty.
+type MQTTConfiguration struct {
+	// For example tcp://broker:1883 or tls://broker:8883
+	Address  string `xml:"address,omitempty" json:"address"`
+	ClientID string `xml:"clientID,omitempty" json:"clientID"`
+	Username string `xml:"username,omitempty" json:"username"`
+	Password string `xml:"password,omitempty" json:"password"`
+	// {device} is replaced by the short device ID and {event} by the event
+	// type.
+	Topic string `xml:"topic,omitempty" json:"topic" default:"syncthing/{device}/{event}"`
+	// Names of the event types to publish. All events are published if
+	// empty.
+	Events             []string `xml:"event" json:"events"`
+	Retain             bool     `xml:"retain,omitempty" json:"retain" default:"false"`
+	InsecureSkipVerify bool     `xml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify" default:"false"`
+}
+
+func (c MQTTConfiguration) Copy() MQTTConfiguration {
+	cp := c
+	cp.Events = make([]string, len(c.Events))
+	copy(cp.Events, c.Events)
+	return cp
+}
@@ -18,6 +18,12 @@ type ObservedFolder struct {
 	Label string    `xml:"label,attr" json:"label"`
 }
 
+// PendingFolder is a folder offered by a device it isn't shared with yet.
+type PendingFolder struct {
+	ObservedFolder
+	DeviceID protocol.DeviceID `json:"deviceID"`
+}
+
 type ObservedDevice struct {
 	Time    time.Time         `xml:"time,attr" json:"time"`
 	ID      protocol.DeviceID `xml:"id,attr" json:"deviceID"`
@@ -0,0 +1,48 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// OIDCConfiguration describes the OpenID Connect provider used to log in
+// to the GUI when the GUI auth mode is "oidc".
+type OIDCConfiguration struct {
+	// For example https://accounts.example.com; the provider configuration
+	// is discovered from its /.well-known/openid-configuration.
+	Issuer       string `xml:"issuer,omitempty" json:"issuer"`
+	ClientID     string `xml:"clientID,omitempty" json:"clientID"`
+	ClientSecret string `xml:"clientSecret,omitempty" json:"clientSecret"`
+	// The URL the provider redirects to after login, ending in
+	// /oidc/callback. Derived from the request if empty.
+	RedirectURL string `xml:"redirectURL,omitempty" json:"redirectURL"`
+	// Requested in addition to "openid profile email", for example
+	// "groups" for providers that only include group membership on request.
+	Scopes []string `xml:"scope" json:"scopes"`
+	// Users must be a member of at least one of these groups, as listed in
+	// the GroupsClaim of their ID token. Any user may log in if empty.
+	AllowedGroups []string `xml:"allowedGroup" json:"allowedGroups"`
+	GroupsClaim   string   `xml:"groupsClaim,omitempty" json:"groupsClaim" default:"groups"`
+	// GUI roles of the members of groups, as listed in the GroupsClaim.
+	// Users in none of them are viewers. Without any, every user who may
+	// log in is an admin.
+	GroupRoles []OIDCGroupRole `xml:"groupRole" json:"groupRoles"`
+}
+
+// OIDCGroupRole gives the members of the group, by name as in the ID token,
+// the role.
+type OIDCGroupRole struct {
+	Group string  `xml:"group,attr" json:"group"`
+	Role  GUIRole `xml:"role,attr" json:"role"`
+}
+
+func (c OIDCConfiguration) Copy() OIDCConfiguration {
+	cp := c
+	cp.Scopes = make([]string, len(c.Scopes))
+	copy(cp.Scopes, c.Scopes)
+	cp.AllowedGroups = make([]string, len(c.AllowedGroups))
+	copy(cp.AllowedGroups, c.AllowedGroups)
+	cp.GroupRoles = append([]OIDCGroupRole(nil), c.GroupRoles...)
+	return cp
+}
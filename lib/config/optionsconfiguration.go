@@ -8,50 +8,66 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/util"
 )
 
 type OptionsConfiguration struct {
-	ListenAddresses         []string `xml:"listenAddress" json:"listenAddresses" default:"default"`
-	GlobalAnnServers        []string `xml:"globalAnnounceServer" json:"globalAnnounceServers" default:"default" restart:"true"`
-	GlobalAnnEnabled        bool     `xml:"globalAnnounceEnabled" json:"globalAnnounceEnabled" default:"true" restart:"true"`
-	LocalAnnEnabled         bool     `xml:"localAnnounceEnabled" json:"localAnnounceEnabled" default:"true" restart:"true"`
-	LocalAnnPort            int      `xml:"localAnnouncePort" json:"localAnnouncePort" default:"21027" restart:"true"`
-	LocalAnnMCAddr          string   `xml:"localAnnounceMCAddr" json:"localAnnounceMCAddr" default:"[ff12::8384]:21027" restart:"true"`
-	MaxSendKbps             int      `xml:"maxSendKbps" json:"maxSendKbps"`
-	MaxRecvKbps             int      `xml:"maxRecvKbps" json:"maxRecvKbps"`
-	ReconnectIntervalS      int      `xml:"reconnectionIntervalS" json:"reconnectionIntervalS" default:"60"`
-	RelaysEnabled           bool     `xml:"relaysEnabled" json:"relaysEnabled" default:"true"`
-	RelayReconnectIntervalM int      `xml:"relayReconnectIntervalM" json:"relayReconnectIntervalM" default:"10"`
-	StartBrowser            bool     `xml:"startBrowser" json:"startBrowser" default:"true"`
-	NATEnabled              bool     `xml:"natEnabled" json:"natEnabled" default:"true"`
-	NATLeaseM               int      `xml:"natLeaseMinutes" json:"natLeaseMinutes" default:"60"`
-	NATRenewalM             int      `xml:"natRenewalMinutes" json:"natRenewalMinutes" default:"30"`
-	NATTimeoutS             int      `xml:"natTimeoutSeconds" json:"natTimeoutSeconds" default:"10"`
-	URAccepted              int      `xml:"urAccepted" json:"urAccepted"` // Accepted usage reporting version; 0 for off (undecided), -1 for off (permanently)
-	URSeen                  int      `xml:"urSeen" json:"urSeen"`         // Report which the user has been prompted for.
-	URUniqueID              string   `xml:"urUniqueID" json:"urUniqueId"` // Unique ID for reporting purposes, regenerated when UR is turned on.
-	URURL                   string   `xml:"urURL" json:"urURL" default:"https://data.syncthing.net/newdata"`
-	URPostInsecurely        bool     `xml:"urPostInsecurely" json:"urPostInsecurely" default:"false"` // For testing
-	URInitialDelayS         int      `xml:"urInitialDelayS" json:"urInitialDelayS" default:"1800"`
-	RestartOnWakeup         bool     `xml:"restartOnWakeup" json:"restartOnWakeup" default:"true" restart:"true"`
-	AutoUpgradeIntervalH    int      `xml:"autoUpgradeIntervalH" json:"autoUpgradeIntervalH" default:"12" restart:"true"` // 0 for off
-	UpgradeToPreReleases    bool     `xml:"upgradeToPreReleases" json:"upgradeToPreReleases" restart:"true"`              // when auto upgrades are enabled
-	KeepTemporariesH        int      `xml:"keepTemporariesH" json:"keepTemporariesH" default:"24"`                        // 0 for off
-	CacheIgnoredFiles       bool     `xml:"cacheIgnoredFiles" json:"cacheIgnoredFiles" default:"false" restart:"true"`
-	ProgressUpdateIntervalS int      `xml:"progressUpdateIntervalS" json:"progressUpdateIntervalS" default:"5"`
-	LimitBandwidthInLan     bool     `xml:"limitBandwidthInLan" json:"limitBandwidthInLan" default:"false"`
-	MinHomeDiskFree         Size     `xml:"minHomeDiskFree" json:"minHomeDiskFree" default:"1 %"`
-	ReleasesURL             string   `xml:"releasesURL" json:"releasesURL" default:"https://upgrades.syncthing.net/meta.json" restart:"true"`
-	AlwaysLocalNets         []string `xml:"alwaysLocalNet" json:"alwaysLocalNets"`
-	OverwriteRemoteDevNames bool     `xml:"overwriteRemoteDeviceNamesOnConnect" json:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
-	TempIndexMinBlocks      int      `xml:"tempIndexMinBlocks" json:"tempIndexMinBlocks" default:"10"`
-	UnackedNotificationIDs  []string `xml:"unackedNotificationID" json:"unackedNotificationIDs"`
-	TrafficClass            int      `xml:"trafficClass" json:"trafficClass"`
-	DefaultFolderPath       string   `xml:"defaultFolderPath" json:"defaultFolderPath" default:"~"`
-	SetLowPriority          bool     `xml:"setLowPriority" json:"setLowPriority" default:"true"`
-	MaxConcurrentScans      int      `xml:"maxConcurrentScans" json:"maxConcurrentScans"`
+	ListenAddresses         []string            `xml:"listenAddress" json:"listenAddresses" default:"default"`
+	GlobalAnnServers        []string            `xml:"globalAnnounceServer" json:"globalAnnounceServers" default:"default" restart:"true"`
+	GlobalAnnEnabled        bool                `xml:"globalAnnounceEnabled" json:"globalAnnounceEnabled" default:"true" restart:"true"`
+	LocalAnnEnabled         bool                `xml:"localAnnounceEnabled" json:"localAnnounceEnabled" default:"true" restart:"true"`
+	LocalAnnPort            int                 `xml:"localAnnouncePort" json:"localAnnouncePort" default:"21027" restart:"true"`
+	LocalAnnMCAddr          string              `xml:"localAnnounceMCAddr" json:"localAnnounceMCAddr" default:"[ff12::8384]:21027" restart:"true"`
+	MaxSendKbps             int                 `xml:"maxSendKbps" json:"maxSendKbps"`
+	MaxRecvKbps             int                 `xml:"maxRecvKbps" json:"maxRecvKbps"`
+	ReconnectIntervalS      int                 `xml:"reconnectionIntervalS" json:"reconnectionIntervalS" default:"60"`
+	RelaysEnabled           bool                `xml:"relaysEnabled" json:"relaysEnabled" default:"true"`
+	RelayReconnectIntervalM int                 `xml:"relayReconnectIntervalM" json:"relayReconnectIntervalM" default:"10"`
+	RelayConnections        int                 `xml:"relayConnections" json:"relayConnections" default:"1"` // The relays of a pool to stay connected to at once, announced closest first
+	StartBrowser            bool                `xml:"startBrowser" json:"startBrowser" default:"true"`
+	NATEnabled              bool                `xml:"natEnabled" json:"natEnabled" default:"true"`
+	NATLeaseM               int                 `xml:"natLeaseMinutes" json:"natLeaseMinutes" default:"60"`
+	NATRenewalM             int                 `xml:"natRenewalMinutes" json:"natRenewalMinutes" default:"30"`
+	NATTimeoutS             int                 `xml:"natTimeoutSeconds" json:"natTimeoutSeconds" default:"10"`
+	URAccepted              int                 `xml:"urAccepted" json:"urAccepted"` // Accepted usage reporting version; 0 for off (undecided), -1 for off (permanently)
+	URSeen                  int                 `xml:"urSeen" json:"urSeen"`         // Report which the user has been prompted for.
+	URUniqueID              string              `xml:"urUniqueID" json:"urUniqueId"` // Unique ID for reporting purposes, regenerated when UR is turned on.
+	URURL                   string              `xml:"urURL" json:"urURL" default:"https://data.syncthing.net/newdata"`
+	URPostInsecurely        bool                `xml:"urPostInsecurely" json:"urPostInsecurely" default:"false"` // For testing
+	URInitialDelayS         int                 `xml:"urInitialDelayS" json:"urInitialDelayS" default:"1800"`
+	RestartOnWakeup         bool                `xml:"restartOnWakeup" json:"restartOnWakeup" default:"true" restart:"true"`
+	AutoUpgradeIntervalH    int                 `xml:"autoUpgradeIntervalH" json:"autoUpgradeIntervalH" default:"12" restart:"true"` // 0 for off
+	UpgradeToPreReleases    bool                `xml:"upgradeToPreReleases" json:"upgradeToPreReleases" restart:"true"`              // when auto upgrades are enabled
+	KeepTemporariesH        int                 `xml:"keepTemporariesH" json:"keepTemporariesH" default:"24"`                        // 0 for off
+	CacheIgnoredFiles       bool                `xml:"cacheIgnoredFiles" json:"cacheIgnoredFiles" default:"false" restart:"true"`
+	ProgressUpdateIntervalS int                 `xml:"progressUpdateIntervalS" json:"progressUpdateIntervalS" default:"5"`
+	LimitBandwidthInLan     bool                `xml:"limitBandwidthInLan" json:"limitBandwidthInLan" default:"false"`
+	MinHomeDiskFree         Size                `xml:"minHomeDiskFree" json:"minHomeDiskFree" default:"1 %"`
+	ReleasesURL             string              `xml:"releasesURL" json:"releasesURL" default:"https://upgrades.syncthing.net/meta.json" restart:"true"`
+	AlwaysLocalNets         []string            `xml:"alwaysLocalNet" json:"alwaysLocalNets"` // Networks (CIDR) or addresses classified as LAN, like a VPN, for dialing order and rate limits
+	OverwriteRemoteDevNames bool                `xml:"overwriteRemoteDeviceNamesOnConnect" json:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
+	TempIndexMinBlocks      int                 `xml:"tempIndexMinBlocks" json:"tempIndexMinBlocks" default:"10"`
+	UnackedNotificationIDs  []string            `xml:"unackedNotificationID" json:"unackedNotificationIDs"`
+	TrafficClass            int                 `xml:"trafficClass" json:"trafficClass"`
+	DefaultFolderPath       string              `xml:"defaultFolderPath" json:"defaultFolderPath" default:"~"`
+	DefaultFolderTemplate   string              `xml:"defaultFolderTemplate" json:"defaultFolderTemplate"` // Template of auto-accepted folders
+	SetLowPriority          bool                `xml:"setLowPriority" json:"setLowPriority" default:"true"`
+	MaxConcurrentScans      int                 `xml:"maxConcurrentScans" json:"maxConcurrentScans"`
+	LocalAnnExclude         []string            `xml:"localAnnounceExclude" json:"localAnnounceExclude"`             // Networks (CIDR) or interface names (glob) never announced locally
+	GlobalAnnExclude        []string            `xml:"globalAnnounceExclude" json:"globalAnnounceExclude"`           // Networks (CIDR) or interface names (glob) never announced globally
+	MaxIncomingRequestKiB   int                 `xml:"maxIncomingRequestKiB" json:"maxIncomingRequestKiB"`           // 0 for default, <0 for no limit
+	EventJournalMiB         int                 `xml:"eventJournalMiB" json:"eventJournalMiB" restart:"true"`        // 0 for off
+	SyslogURL               string              `xml:"syslogURL" json:"syslogURL" restart:"true"`                    // udp://host:port, tcp://host:port or unix:///path to log to, empty for off
+	WindowsEventLog         bool                `xml:"windowsEventLog" json:"windowsEventLog" restart:"true"`        // Log to the Windows Event Log
+	BandwidthSchedules      []BandwidthSchedule `xml:"bandwidthSchedule" json:"bandwidthSchedules"`                  // The first one active replaces maxSendKbps and maxRecvKbps
+	DNSDiscoveryDomains     []string            `xml:"dnsDiscoveryDomain" json:"dnsDiscoveryDomains" restart:"true"` // Domains with SRV and TXT records of devices, named by device ID
+	DHTEnabled              bool                `xml:"dhtEnabled" json:"dhtEnabled" default:"false" restart:"true"`
+	DHTListenAddr           string              `xml:"dhtListenAddress" json:"dhtListenAddress" default:":21028" restart:"true"` // UDP
+	DHTBootstrapNodes       []string            `xml:"dhtBootstrapNode" json:"dhtBootstrapNodes" restart:"true"`                 // host:port of nodes to join the DHT through
+	LocalAnnUnicast         []string            `xml:"localAnnounceUnicast" json:"localAnnounceUnicast" restart:"true"`          // IPv4 addresses or subnets (CIDR) also announced to locally, where broadcasts are filtered
+	TracingEndpoint         string              `xml:"tracingEndpoint" json:"tracingEndpoint"`                                   // OTLP/HTTP URL to export timing spans to, such as http://localhost:4318/v1/traces, empty for off
 
 	DeprecatedUPnPEnabled        bool     `xml:"upnpEnabled,omitempty" json:"-"`
 	DeprecatedUPnPLeaseM         int      `xml:"upnpLeaseMinutes,omitempty" json:"-"`
@@ -71,9 +87,34 @@ func (orig OptionsConfiguration) Copy() OptionsConfiguration {
 	copy(c.AlwaysLocalNets, orig.AlwaysLocalNets)
 	c.UnackedNotificationIDs = make([]string, len(orig.UnackedNotificationIDs))
 	copy(c.UnackedNotificationIDs, orig.UnackedNotificationIDs)
+	c.LocalAnnExclude = make([]string, len(orig.LocalAnnExclude))
+	copy(c.LocalAnnExclude, orig.LocalAnnExclude)
+	c.LocalAnnUnicast = make([]string, len(orig.LocalAnnUnicast))
+	copy(c.LocalAnnUnicast, orig.LocalAnnUnicast)
+	c.GlobalAnnExclude = make([]string, len(orig.GlobalAnnExclude))
+	copy(c.GlobalAnnExclude, orig.GlobalAnnExclude)
+	c.DNSDiscoveryDomains = make([]string, len(orig.DNSDiscoveryDomains))
+	copy(c.DNSDiscoveryDomains, orig.DNSDiscoveryDomains)
+	c.DHTBootstrapNodes = make([]string, len(orig.DHTBootstrapNodes))
+	copy(c.DHTBootstrapNodes, orig.DHTBootstrapNodes)
+	c.BandwidthSchedules = nil
+	for _, schedule := range orig.BandwidthSchedules {
+		c.BandwidthSchedules = append(c.BandwidthSchedules, schedule.Copy())
+	}
 	return c
 }
 
+// BandwidthLimits returns the overall send and receive rate limits, in
+// KiB/s, in effect at the time.
+func (orig OptionsConfiguration) BandwidthLimits(t time.Time) (sendKbps, recvKbps int) {
+	for _, schedule := range orig.BandwidthSchedules {
+		if schedule.Active(t) {
+			return schedule.MaxSendKbps, schedule.MaxRecvKbps
+		}
+	}
+	return orig.MaxSendKbps, orig.MaxRecvKbps
+}
+
 // RequiresRestartOnly returns a copy with only the attributes that require
 // restart on change.
 func (orig OptionsConfiguration) RequiresRestartOnly() OptionsConfiguration {
@@ -0,0 +1,29 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// TOTPConfiguration holds the second factor required, in addition to the
+// password, when logging in to the GUI. It is only managed through its own
+// REST endpoints and never included in the JSON config.
+type TOTPConfiguration struct {
+	// Base32 encoded shared secret. The second factor is disabled while
+	// empty.
+	Secret string `xml:"secret,omitempty" json:"-"`
+	// Bcrypt hashes of the unused recovery codes.
+	RecoveryCodes []string `xml:"recoveryCode" json:"-"`
+}
+
+func (c TOTPConfiguration) Enabled() bool {
+	return c.Secret != ""
+}
+
+func (c TOTPConfiguration) Copy() TOTPConfiguration {
+	cp := c
+	cp.RecoveryCodes = make([]string, len(c.RecoveryCodes))
+	copy(cp.RecoveryCodes, c.RecoveryCodes)
+	return cp
+}
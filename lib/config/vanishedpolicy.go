@@ -0,0 +1,48 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// VanishedPolicy decides what happens to the data pulled so far of a file
+// that was deleted on all other devices before it was complete.
+type VanishedPolicy int
+
+const (
+	VanishedDiscard VanishedPolicy = iota // default is to remove the temporary file
+	VanishedKeep                          // keep the temporary file, to resume from when the file is offered again
+	VanishedPromote                       // turn the temporary file into a local file next to where the file was
+)
+
+func (p VanishedPolicy) String() string {
+	switch p {
+	case VanishedDiscard:
+		return "discard"
+	case VanishedKeep:
+		return "keep"
+	case VanishedPromote:
+		return "promote"
+	default:
+		return "unknown"
+	}
+}
+
+func (p VanishedPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *VanishedPolicy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "discard":
+		*p = VanishedDiscard
+	case "keep":
+		*p = VanishedKeep
+	case "promote":
+		*p = VanishedPromote
+	default:
+		*p = VanishedDiscard
+	}
+	return nil
+}
@@ -6,16 +6,26 @@
 
 package config
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
 
 type VersioningConfiguration struct {
 	Type   string            `xml:"type,attr" json:"type"`
 	Params map[string]string `json:"params"`
+	// Where versions are kept, if not in the .stversions directory of the
+	// folder. A relative path is relative to the folder.
+	FSPath string            `xml:"fsPath" json:"fsPath"`
+	FSType fs.FilesystemType `xml:"fsType" json:"fsType"`
 }
 
 type InternalVersioningConfiguration struct {
-	Type   string          `xml:"type,attr,omitempty"`
-	Params []InternalParam `xml:"param"`
+	Type   string            `xml:"type,attr,omitempty"`
+	Params []InternalParam   `xml:"param"`
+	FSPath string            `xml:"fsPath,omitempty"`
+	FSType fs.FilesystemType `xml:"fsType"`
 }
 
 type InternalParam struct {
@@ -35,6 +45,8 @@ func (c VersioningConfiguration) Copy() VersioningConfiguration {
 func (c *VersioningConfiguration) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	var tmp InternalVersioningConfiguration
 	tmp.Type = c.Type
+	tmp.FSPath = c.FSPath
+	tmp.FSType = c.FSType
 	for k, v := range c.Params {
 		tmp.Params = append(tmp.Params, InternalParam{k, v})
 	}
@@ -51,6 +63,8 @@ func (c *VersioningConfiguration) UnmarshalXML(d *xml.Decoder, start xml.StartEl
 	}
 
 	c.Type = tmp.Type
+	c.FSPath = tmp.FSPath
+	c.FSType = tmp.FSType
 	c.Params = make(map[string]string, len(tmp.Params))
 	for _, p := range tmp.Params {
 		c.Params[p.Key] = p.Val
@@ -0,0 +1,25 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// A WebhookConfiguration describes a URL that events are posted to.
+type WebhookConfiguration struct {
+	URL string `xml:"url,attr" json:"url"`
+	// Names of the event types to post, such as "FolderCompletion". All
+	// events are posted if empty.
+	Events []string `xml:"event" json:"events"`
+	// If set, requests are signed with an HMAC-SHA256 of the body using
+	// this key.
+	Secret string `xml:"secret,omitempty" json:"secret"`
+}
+
+func (c WebhookConfiguration) Copy() WebhookConfiguration {
+	cp := c
+	cp.Events = make([]string, len(c.Events))
+	copy(cp.Events, c.Events)
+	return cp
+}
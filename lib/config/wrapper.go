@@ -7,6 +7,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"sync/atomic"
 	"time"
@@ -60,12 +61,18 @@ type Wrapper interface {
 
 	RawCopy() Configuration
 	Replace(cfg Configuration) (Waiter, error)
+	History() []ConfigVersion
+	Rollback(version int) (Waiter, error)
 	RequiresRestart() bool
 	Save() error
 
 	GUI() GUIConfiguration
 	SetGUI(gui GUIConfiguration) (Waiter, error)
 	LDAP() LDAPConfiguration
+	OIDC() OIDCConfiguration
+	TOTP() TOTPConfiguration
+	SetTOTP(totp TOTPConfiguration) (Waiter, error)
+	RemoveTOTPRecoveryCode(hash string) (Waiter, bool, error)
 
 	Options() OptionsConfiguration
 	SetOptions(opts OptionsConfiguration) (Waiter, error)
@@ -75,6 +82,18 @@ type Wrapper interface {
 	FolderList() []FolderConfiguration
 	SetFolder(fld FolderConfiguration) (Waiter, error)
 
+	IgnorePatternSet(name string) ([]string, bool)
+
+	FolderTemplate(name string) (FolderTemplate, bool)
+	FolderTemplates() []FolderTemplate
+	SetFolderTemplate(tmpl FolderTemplate) (Waiter, error)
+	RemoveFolderTemplate(name string) (Waiter, error)
+	FolderPasswords(device protocol.DeviceID) map[string]string
+	DeviceGroup(name string) (DeviceGroup, bool)
+	DeviceGroups() []DeviceGroup
+	SetDeviceGroup(group DeviceGroup) (Waiter, error)
+	RemoveDeviceGroup(name string) (Waiter, error)
+
 	Device(id protocol.DeviceID) (DeviceConfiguration, bool)
 	Devices() map[protocol.DeviceID]DeviceConfiguration
 	RemoveDevice(id protocol.DeviceID) (Waiter, error)
@@ -85,6 +104,10 @@ type Wrapper interface {
 	AddOrUpdatePendingFolder(id, label string, device protocol.DeviceID)
 	IgnoredDevice(id protocol.DeviceID) bool
 	IgnoredFolder(device protocol.DeviceID, folder string) bool
+	PendingDevices() []ObservedDevice
+	PendingFolders() []PendingFolder
+	DismissPendingDevice(device protocol.DeviceID) (Waiter, error)
+	DismissPendingFolder(device protocol.DeviceID, folder string) (Waiter, error)
 
 	ListenAddresses() []string
 	GlobalDiscoveryServers() []string
@@ -100,6 +123,7 @@ type wrapper struct {
 	deviceMap map[protocol.DeviceID]DeviceConfiguration
 	folderMap map[string]FolderConfiguration
 	subs      []Committer
+	history   configHistory
 	mut       sync.Mutex
 
 	requiresRestart uint32 // an atomic bool
@@ -180,6 +204,7 @@ func (w *wrapper) replaceLocked(to Configuration) (Waiter, error) {
 	if err := to.clean(); err != nil {
 		return noopWaiter{}, err
 	}
+	stampMetadataChanges(from.Folders, to.Folders)
 
 	for _, sub := range w.subs {
 		l.Debugln(sub, "verifying configuration")
@@ -192,6 +217,9 @@ func (w *wrapper) replaceLocked(to Configuration) (Waiter, error) {
 	w.cfg = to
 	w.deviceMap = nil
 	w.folderMap = nil
+	if version, ok := w.history.record(from, to); ok {
+		emitConfigChanged(version, from, to)
+	}
 
 	return w.notifyListeners(from.Copy(), to.Copy()), nil
 }
@@ -339,6 +367,44 @@ func (w *wrapper) LDAP() LDAPConfiguration {
 	return w.cfg.LDAP.Copy()
 }
 
+func (w *wrapper) OIDC() OIDCConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.OIDC.Copy()
+}
+
+func (w *wrapper) TOTP() TOTPConfiguration {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.TOTP.Copy()
+}
+
+// SetTOTP replaces the current second factor configuration object.
+func (w *wrapper) SetTOTP(totp TOTPConfiguration) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	newCfg := w.cfg.Copy()
+	newCfg.TOTP = totp.Copy()
+	return w.replaceLocked(newCfg)
+}
+
+// RemoveTOTPRecoveryCode removes the recovery code with the given hash, and
+// returns false if there is none, such as when it was just used by someone
+// else.
+func (w *wrapper) RemoveTOTPRecoveryCode(hash string) (Waiter, bool, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	for i, h := range w.cfg.TOTP.RecoveryCodes {
+		if h == hash {
+			newCfg := w.cfg.Copy()
+			newCfg.TOTP.RecoveryCodes = append(newCfg.TOTP.RecoveryCodes[:i], newCfg.TOTP.RecoveryCodes[i+1:]...)
+			wg, err := w.replaceLocked(newCfg)
+			return wg, true, err
+		}
+	}
+	return noopWaiter{}, false, nil
+}
+
 // GUI returns the current GUI configuration object.
 func (w *wrapper) GUI() GUIConfiguration {
 	w.mut.Lock()
@@ -402,6 +468,141 @@ func (w *wrapper) Folder(id string) (FolderConfiguration, bool) {
 	return FolderConfiguration{}, false
 }
 
+// IgnorePatternSet returns the patterns of the named ignore pattern set and
+// an "ok" bool.
+func (w *wrapper) IgnorePatternSet(name string) ([]string, bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	for _, set := range w.cfg.IgnorePatternSets {
+		if set.Name == name {
+			return set.Copy().Patterns, true
+		}
+	}
+	return nil, false
+}
+
+// FolderTemplate returns the named folder template and an "ok" bool.
+func (w *wrapper) FolderTemplate(name string) (FolderTemplate, bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.FolderTemplate(name)
+}
+
+// FolderTemplates returns a slice of folder templates.
+func (w *wrapper) FolderTemplates() []FolderTemplate {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.Copy().FolderTemplates
+}
+
+// SetFolderTemplate adds a new folder template to the configuration, or
+// overwrites an existing template with the same name.
+func (w *wrapper) SetFolderTemplate(tmpl FolderTemplate) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+
+	for i := range newCfg.FolderTemplates {
+		if newCfg.FolderTemplates[i].Name == tmpl.Name {
+			newCfg.FolderTemplates[i] = tmpl.Copy()
+			return w.replaceLocked(newCfg)
+		}
+	}
+
+	newCfg.FolderTemplates = append(newCfg.FolderTemplates, tmpl.Copy())
+
+	return w.replaceLocked(newCfg)
+}
+
+// RemoveFolderTemplate removes the folder template from the configuration.
+// Folders created from it keep their settings.
+func (w *wrapper) RemoveFolderTemplate(name string) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	for i := range newCfg.FolderTemplates {
+		if newCfg.FolderTemplates[i].Name == name {
+			newCfg.FolderTemplates = append(newCfg.FolderTemplates[:i], newCfg.FolderTemplates[i+1:]...)
+			return w.replaceLocked(newCfg)
+		}
+	}
+
+	return noopWaiter{}, nil
+}
+
+// FolderPasswords returns the encryption passwords of the folders shared
+// with the device, by folder ID, for those that have one.
+func (w *wrapper) FolderPasswords(device protocol.DeviceID) map[string]string {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.FolderPasswords(device)
+}
+
+// DeviceGroup returns the named device group and an "ok" bool.
+func (w *wrapper) DeviceGroup(name string) (DeviceGroup, bool) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.DeviceGroup(name)
+}
+
+// DeviceGroups returns a slice of device groups.
+func (w *wrapper) DeviceGroups() []DeviceGroup {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.cfg.Copy().DeviceGroups
+}
+
+// SetDeviceGroup adds a new device group to the configuration, or
+// overwrites an existing group with the same name. The folders shared with
+// the group are shared with the devices added to it, and no longer with
+// those removed from it.
+func (w *wrapper) SetDeviceGroup(group DeviceGroup) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+
+	for i := range newCfg.DeviceGroups {
+		if newCfg.DeviceGroups[i].Name == group.Name {
+			newCfg.DeviceGroups[i] = group.Copy()
+			return w.replaceLocked(newCfg)
+		}
+	}
+
+	newCfg.DeviceGroups = append(newCfg.DeviceGroups, group.Copy())
+
+	return w.replaceLocked(newCfg)
+}
+
+// RemoveDeviceGroup removes the device group from the configuration, and
+// stops sharing the folders shared with it with its devices.
+func (w *wrapper) RemoveDeviceGroup(name string) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	for i := range newCfg.DeviceGroups {
+		if newCfg.DeviceGroups[i].Name == name {
+			newCfg.DeviceGroups = append(newCfg.DeviceGroups[:i], newCfg.DeviceGroups[i+1:]...)
+			for j := range newCfg.Folders {
+				folder := &newCfg.Folders[j]
+				groups := folder.Groups[:0]
+				for _, group := range folder.Groups {
+					if group != name {
+						groups = append(groups, group)
+					}
+				}
+				folder.Groups = groups
+			}
+			return w.replaceLocked(newCfg)
+		}
+	}
+
+	return noopWaiter{}, nil
+}
+
 // Save writes the configuration to disk, and generates a ConfigSaved event.
 func (w *wrapper) Save() error {
 	w.mut.Lock()
@@ -523,3 +724,75 @@ func (w *wrapper) AddOrUpdatePendingFolder(id, label string, device protocol.Dev
 
 	panic("bug: adding pending folder for non-existing device")
 }
+
+var errNoSuchDevice = errors.New("no such device")
+
+// PendingDevices returns the devices that tried to connect and aren't in
+// the config, the longest waiting first.
+func (w *wrapper) PendingDevices() []ObservedDevice {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return append([]ObservedDevice(nil), w.cfg.PendingDevices...)
+}
+
+// PendingFolders returns the folders devices offered to share that aren't
+// shared with them, by device.
+func (w *wrapper) PendingFolders() []PendingFolder {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	var folders []PendingFolder
+	for _, dev := range w.cfg.Devices {
+		for _, folder := range dev.PendingFolders {
+			folders = append(folders, PendingFolder{ObservedFolder: folder, DeviceID: dev.DeviceID})
+		}
+	}
+	return folders
+}
+
+// DismissPendingDevice ignores the device from now on, instead of it being
+// pending.
+func (w *wrapper) DismissPendingDevice(device protocol.DeviceID) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	for _, ignored := range newCfg.IgnoredDevices {
+		if ignored.ID == device {
+			return noopWaiter{}, nil
+		}
+	}
+	ignored := ObservedDevice{Time: time.Now().Round(time.Second), ID: device}
+	for _, pending := range newCfg.PendingDevices {
+		if pending.ID == device {
+			ignored.Name = pending.Name
+			ignored.Address = pending.Address
+		}
+	}
+	newCfg.IgnoredDevices = append(newCfg.IgnoredDevices, ignored)
+
+	return w.replaceLocked(newCfg)
+}
+
+// DismissPendingFolder ignores the offers of the device to share the
+// folder from now on, instead of it being pending.
+func (w *wrapper) DismissPendingFolder(device protocol.DeviceID, folder string) (Waiter, error) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	newCfg := w.cfg.Copy()
+	for i := range newCfg.Devices {
+		if newCfg.Devices[i].DeviceID != device {
+			continue
+		}
+		ignored := ObservedFolder{Time: time.Now().Round(time.Second), ID: folder}
+		for _, pending := range newCfg.Devices[i].PendingFolders {
+			if pending.ID == folder {
+				ignored.Label = pending.Label
+			}
+		}
+		newCfg.Devices[i].IgnoredFolders = append(newCfg.Devices[i].IgnoredFolders, ignored)
+		return w.replaceLocked(newCfg)
+	}
+
+	return nil, errNoSuchDevice
+}
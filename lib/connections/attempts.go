@@ -0,0 +1,210 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	maxAttemptEntries   = 256 // Don't keep track of more devices or addresses than this
+	attemptBanThreshold = 10  // Rejected attempts within attemptBanWindow before a ban
+	attemptBanWindow    = time.Minute
+	attemptBanDuration  = 10 * time.Minute
+)
+
+// A ConnectionAttempt describes the rejected connection attempts made by a
+// device that is not in our configuration.
+type ConnectionAttempt struct {
+	DeviceID        protocol.DeviceID `json:"deviceID"`
+	Address         string            `json:"address"`
+	CertName        string            `json:"certName"`
+	CertFingerprint string            `json:"certFingerprint"`
+	FirstSeen       time.Time         `json:"firstSeen"`
+	LastSeen        time.Time         `json:"lastSeen"`
+	Attempts        int               `json:"attempts"`
+}
+
+// attemptCounter counts rejected attempts per device ID or IP address.
+type attemptCounter struct {
+	windowStart time.Time
+	count       int
+	bannedUntil time.Time
+}
+
+// attemptTracker records connection attempts from unknown devices and bans
+// the devices and addresses that are making too many of them.
+type attemptTracker struct {
+	mut      sync.Mutex
+	attempts map[protocol.DeviceID]*ConnectionAttempt
+	counters map[string]*attemptCounter // keyed by device ID or IP address
+}
+
+func newAttemptTracker() *attemptTracker {
+	return &attemptTracker{
+		mut:      sync.NewMutex(),
+		attempts: make(map[protocol.DeviceID]*ConnectionAttempt),
+		counters: make(map[string]*attemptCounter),
+	}
+}
+
+// record registers a rejected connection attempt from the given device. The
+// address is only taken into account for banning if direct is true, as
+// otherwise it is the address of a relay rather than of the device.
+func (t *attemptTracker) record(id protocol.DeviceID, addr net.Addr, direct bool, cert *x509.Certificate, now time.Time) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	a, ok := t.attempts[id]
+	if !ok {
+		if len(t.attempts) >= maxAttemptEntries {
+			t.evictOldestAttemptLocked()
+		}
+		fp := sha256.Sum256(cert.Raw)
+		a = &ConnectionAttempt{
+			DeviceID:        id,
+			CertName:        cert.Subject.CommonName,
+			CertFingerprint: hex.EncodeToString(fp[:]),
+			FirstSeen:       now,
+		}
+		t.attempts[id] = a
+	}
+	a.Address = addr.String()
+	a.LastSeen = now
+	a.Attempts++
+
+	t.countLocked(id.String(), now)
+	if direct {
+		if host := hostOf(addr); host != "" {
+			t.countLocked(host, now)
+		}
+	}
+}
+
+func (t *attemptTracker) countLocked(key string, now time.Time) {
+	c, ok := t.counters[key]
+	if !ok {
+		if len(t.counters) >= maxAttemptEntries {
+			t.pruneCountersLocked(now)
+		}
+		c = &attemptCounter{windowStart: now}
+		t.counters[key] = c
+	}
+	if now.Sub(c.windowStart) > attemptBanWindow {
+		c.windowStart = now
+		c.count = 0
+	}
+	c.count++
+	if c.count >= attemptBanThreshold && !now.Before(c.bannedUntil) {
+		c.bannedUntil = now.Add(attemptBanDuration)
+		l.Infof("Too many rejected connection attempts from %s; ignoring it for %v", key, attemptBanDuration)
+	}
+}
+
+func (t *attemptTracker) evictOldestAttemptLocked() {
+	var oldest *ConnectionAttempt
+	for _, a := range t.attempts {
+		if oldest == nil || a.LastSeen.Before(oldest.LastSeen) {
+			oldest = a
+		}
+	}
+	if oldest != nil {
+		delete(t.attempts, oldest.DeviceID)
+	}
+}
+
+// pruneCountersLocked removes counters that neither ban nor are about to
+// ban anything. If that doesn't free up any space, all counters not
+// currently banning are removed.
+func (t *attemptTracker) pruneCountersLocked(now time.Time) {
+	for key, c := range t.counters {
+		if now.Sub(c.windowStart) > attemptBanWindow && !now.Before(c.bannedUntil) {
+			delete(t.counters, key)
+		}
+	}
+	if len(t.counters) < maxAttemptEntries {
+		return
+	}
+	for key, c := range t.counters {
+		if !now.Before(c.bannedUntil) {
+			delete(t.counters, key)
+		}
+	}
+}
+
+// bannedDevice returns true if connections from the device should be
+// rejected out of hand.
+func (t *attemptTracker) bannedDevice(id protocol.DeviceID, now time.Time) bool {
+	return t.banned(id.String(), now)
+}
+
+// bannedAddress returns true if connections from the address should be
+// rejected out of hand.
+func (t *attemptTracker) bannedAddress(addr net.Addr, now time.Time) bool {
+	host := hostOf(addr)
+	return host != "" && t.banned(host, now)
+}
+
+func (t *attemptTracker) banned(key string, now time.Time) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	c, ok := t.counters[key]
+	return ok && now.Before(c.bannedUntil)
+}
+
+// forget removes the attempts of the device and lifts its ban, for when it
+// becomes known.
+func (t *attemptTracker) forget(id protocol.DeviceID) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	delete(t.attempts, id)
+	delete(t.counters, id.String())
+}
+
+// list returns the recorded attempts, the most recent first.
+func (t *attemptTracker) list() []ConnectionAttempt {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	res := make([]ConnectionAttempt, 0, len(t.attempts))
+	for _, a := range t.attempts {
+		res = append(res, *a)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LastSeen.After(res[j].LastSeen)
+	})
+	return res
+}
+
+// bans returns the currently banned device IDs and addresses with the time
+// their ban ends.
+func (t *attemptTracker) bans(now time.Time) map[string]time.Time {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	res := make(map[string]time.Time)
+	for key, c := range t.counters {
+		if now.Before(c.bannedUntil) {
+			res[key] = c.bannedUntil
+		}
+	}
+	return res
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAttemptTrackerBans(t *testing.T) {
+	tr := newAttemptTracker()
+	cert := &x509.Certificate{Raw: []byte("cert"), Subject: pkix.Name{CommonName: "syncthing"}}
+	addr1 := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22000}
+	addr2 := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 22000}
+	now := time.Now()
+
+	// Spread out attempts are not banned.
+	for i := 0; i < 2*attemptBanThreshold; i++ {
+		tr.record(device1, addr1, true, cert, now)
+		now = now.Add(attemptBanWindow / attemptBanThreshold * 2)
+	}
+	if tr.bannedDevice(device1, now) || tr.bannedAddress(addr1, now) {
+		t.Fatal("Unexpected ban for infrequent attempts")
+	}
+
+	// Hammering from one address via many device IDs bans the address.
+	for i := 0; i < attemptBanThreshold; i++ {
+		tr.record(device2, addr2, true, cert, now)
+		tr.record(device3, addr2, true, cert, now)
+	}
+	if !tr.bannedAddress(addr2, now) {
+		t.Error("Expected address to be banned")
+	}
+	if !tr.bannedDevice(device2, now) {
+		t.Error("Expected device to be banned")
+	}
+
+	// Relay addresses are never banned.
+	relayAddr := &net.TCPAddr{IP: net.ParseIP("192.0.2.3"), Port: 22067}
+	for i := 0; i < attemptBanThreshold; i++ {
+		tr.record(device4, relayAddr, false, cert, now)
+	}
+	if tr.bannedAddress(relayAddr, now) {
+		t.Error("Relay address should not be banned")
+	}
+	if !tr.bannedDevice(device4, now) {
+		t.Error("Expected device behind relay to be banned")
+	}
+
+	if bans := tr.bans(now); len(bans) != 4 {
+		t.Error("Expected four bans, got", bans)
+	}
+
+	// Bans expire.
+	now = now.Add(attemptBanDuration + time.Second)
+	if tr.bannedAddress(addr2, now) || tr.bannedDevice(device2, now) {
+		t.Error("Ban should have expired")
+	}
+
+	list := tr.list()
+	if len(list) != 4 {
+		t.Fatal("Expected four devices in the attempt list, got", len(list))
+	}
+	for _, a := range list {
+		if a.DeviceID == device1 {
+			if a.Attempts != 2*attemptBanThreshold || a.Address != addr1.String() || a.CertName != "syncthing" {
+				t.Error("Unexpected attempt record", a)
+			}
+		}
+	}
+}
+
+func TestAttemptTrackerForget(t *testing.T) {
+	tr := newAttemptTracker()
+	cert := &x509.Certificate{Raw: []byte("cert"), Subject: pkix.Name{CommonName: "syncthing"}}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22000}
+	now := time.Now()
+
+	for i := 0; i < attemptBanThreshold; i++ {
+		tr.record(device1, addr, true, cert, now)
+	}
+	if !tr.bannedDevice(device1, now) || !tr.bannedAddress(addr, now) {
+		t.Fatal("Expected device and address to be banned")
+	}
+
+	// Adding the device lifts its ban, not that of the address.
+	tr.forget(device1)
+	if tr.bannedDevice(device1, now) {
+		t.Error("Device still banned")
+	}
+	if !tr.bannedAddress(addr, now) {
+		t.Error("Address ban lifted")
+	}
+	if list := tr.list(); len(list) != 0 {
+		t.Error("Device still in the attempt list", list)
+	}
+}
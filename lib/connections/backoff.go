@@ -0,0 +1,69 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+// redialInterval returns how long to wait before dialing a device again
+// after the given number of consecutive failed attempts. The interval starts
+// at the device's minimum, or the dialer's redial frequency if unset, and
+// doubles per failure up to the device's maximum, if any. The result is
+// varied randomly by the device's jitter percentage.
+func redialInterval(deviceCfg config.DeviceConfiguration, dialerFreq time.Duration, failures int) time.Duration {
+	min := dialerFreq
+	if deviceCfg.ReconnectMinIntervalS > 0 {
+		min = time.Duration(deviceCfg.ReconnectMinIntervalS) * time.Second
+	}
+	max := min
+	if limit := time.Duration(deviceCfg.ReconnectMaxIntervalS) * time.Second; limit > min {
+		max = limit
+	}
+
+	interval := min
+	for i := 0; i < failures && interval < max; i++ {
+		interval *= 2
+	}
+	if interval > max {
+		interval = max
+	}
+
+	if pct := deviceCfg.ReconnectJitterPct; pct > 0 && interval > 0 {
+		spread := int64(interval) * int64(pct) / 100
+		if spread > 0 {
+			interval += time.Duration(rand.Int63()%(2*spread+1) - spread)
+		}
+	}
+	return interval
+}
+
+// ReconnectNow makes the next connection loop dial the device right away,
+// regardless of when it was last attempted, and resets its backoff.
+func (s *service) ReconnectNow(deviceID protocol.DeviceID) {
+	s.reconnectMut.Lock()
+	s.reconnectNow[deviceID] = struct{}{}
+	s.reconnectMut.Unlock()
+
+	select {
+	case s.reconnectWake <- struct{}{}:
+	default:
+	}
+}
+
+// takeReconnectNow returns and clears the devices to dial right away.
+func (s *service) takeReconnectNow() map[protocol.DeviceID]struct{} {
+	s.reconnectMut.Lock()
+	defer s.reconnectMut.Unlock()
+	devices := s.reconnectNow
+	s.reconnectNow = make(map[protocol.DeviceID]struct{})
+	return devices
+}
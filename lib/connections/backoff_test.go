@@ -0,0 +1,53 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestRedialInterval(t *testing.T) {
+	cases := []struct {
+		cfg      config.DeviceConfiguration
+		failures int
+		expected time.Duration
+	}{
+		// Without settings, the dialer's frequency is used as is
+		{config.DeviceConfiguration{}, 0, time.Minute},
+		{config.DeviceConfiguration{}, 5, time.Minute},
+		// A minimum without maximum means no backoff
+		{config.DeviceConfiguration{ReconnectMinIntervalS: 10}, 3, 10 * time.Second},
+		// Doubling per failure, up to the maximum
+		{config.DeviceConfiguration{ReconnectMinIntervalS: 10, ReconnectMaxIntervalS: 300}, 0, 10 * time.Second},
+		{config.DeviceConfiguration{ReconnectMinIntervalS: 10, ReconnectMaxIntervalS: 300}, 2, 40 * time.Second},
+		{config.DeviceConfiguration{ReconnectMinIntervalS: 10, ReconnectMaxIntervalS: 300}, 10, 300 * time.Second},
+		{config.DeviceConfiguration{ReconnectMaxIntervalS: 600}, 100, 600 * time.Second},
+	}
+	for i, tc := range cases {
+		if res := redialInterval(tc.cfg, time.Minute, tc.failures); res != tc.expected {
+			t.Errorf("%d: got %v, expected %v", i, res, tc.expected)
+		}
+	}
+
+	cfg := config.DeviceConfiguration{ReconnectMinIntervalS: 100, ReconnectJitterPct: 20}
+	varied := false
+	for i := 0; i < 100; i++ {
+		res := redialInterval(cfg, time.Minute, 0)
+		if res < 80*time.Second || res > 120*time.Second {
+			t.Fatal("Jitter out of range:", res)
+		}
+		if res != 100*time.Second {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Error("Expected jitter to vary the interval")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// A TCP listen address can be restricted to certain network interfaces with
+// the "interface" parameter, a comma separated list of interface name
+// patterns. Patterns prefixed with "!" exclude interfaces instead. For
+// example, tcp://:22000?interface=eth*,!eth1 listens on the addresses of all
+// eth interfaces except eth1, and tcp://:22000?interface=!tun* on all
+// addresses except those of tun interfaces.
+const interfaceParam = "interface"
+
+type interfaceAddrs struct {
+	name string
+	ips  []net.IP
+}
+
+func localInterfaces() ([]interfaceAddrs, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]interfaceAddrs, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			l.Debugf("Listing addresses of interface %s: %v", iface.Name, err)
+			continue
+		}
+		ia := interfaceAddrs{name: iface.Name}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				ia.ips = append(ia.ips, ipnet.IP)
+			}
+		}
+		res = append(res, ia)
+	}
+	return res, nil
+}
+
+// expandListenAddresses replaces the listen addresses restricted to
+// interfaces by one address per matching interface address.
+func expandListenAddresses(addrs []string) []string {
+	var ifaces []interfaceAddrs
+	var ifacesErr error
+	looked := false
+
+	res := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		uri, err := url.Parse(addr)
+		if err != nil || !strings.HasPrefix(uri.Scheme, "tcp") || uri.Query().Get(interfaceParam) == "" {
+			// Errors are handled by the caller.
+			res = append(res, addr)
+			continue
+		}
+		if !looked {
+			ifaces, ifacesErr = localInterfaces()
+			looked = true
+		}
+		if ifacesErr != nil {
+			l.Infof("Listener for %s: listing interfaces: %v", addr, ifacesErr)
+			continue
+		}
+		expanded := expandInterfaceURI(uri, ifaces)
+		if len(expanded) == 0 {
+			l.Debugln("No interface addresses match", addr)
+		}
+		res = append(res, expanded...)
+	}
+	return res
+}
+
+func expandInterfaceURI(uri *url.URL, ifaces []interfaceAddrs) []string {
+	query := uri.Query()
+	patterns := strings.Split(query.Get(interfaceParam), ",")
+	query.Del(interfaceParam)
+	_, port, err := net.SplitHostPort(uri.Host)
+	if err != nil {
+		port = ""
+	}
+
+	var res []string
+	for _, iface := range ifaces {
+		if !interfaceMatches(iface.name, patterns) {
+			continue
+		}
+		for _, ip := range iface.ips {
+			if ip.IsLinkLocalUnicast() {
+				// Would need a zone to bind to
+				continue
+			}
+			isV4 := ip.To4() != nil
+			if (uri.Scheme == "tcp4" && !isV4) || (uri.Scheme == "tcp6" && isV4) {
+				continue
+			}
+			expanded := *uri
+			expanded.Host = net.JoinHostPort(ip.String(), port)
+			expanded.RawQuery = query.Encode()
+			res = append(res, expanded.String())
+		}
+	}
+	return res
+}
+
+// interfaceMatches returns true if the name matches at least one of the
+// include patterns, or there are none, and none of the exclude patterns.
+func interfaceMatches(name string, patterns []string) bool {
+	included, haveIncludes := false, false
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(pattern, "!") {
+			if ok, _ := path.Match(pattern[1:], name); ok {
+				return false
+			}
+			continue
+		}
+		haveIncludes = true
+		if ok, _ := path.Match(pattern, name); ok {
+			included = true
+		}
+	}
+	return included || !haveIncludes
+}
+
+// filterAnnounced removes the addresses that must not be announced, as
+// given by a list of networks in CIDR notation and interface name patterns.
+// With any exclusions, loopback addresses are dropped too. Unspecified
+// addresses, such as those of the default tcp://0.0.0.0:22000 and
+// tcp://:22000, are kept for global announcements, where the discovery
+// server fills in whichever of our IPs it sees. For local announcements
+// they are replaced by the IPs of our interfaces that aren't excluded.
+// Addresses with a host name can't be filtered and are kept.
+func filterAnnounced(addrs []string, exclude []string, global bool) []string {
+	if len(exclude) == 0 {
+		return addrs
+	}
+	ifaces, err := localInterfaces()
+	if err != nil {
+		l.Debugln("Listing interfaces:", err)
+	}
+	return filterAddresses(addrs, exclude, global, ifaces)
+}
+
+func filterAddresses(addrs []string, exclude []string, global bool, ifaces []interfaceAddrs) []string {
+	var nets []*net.IPNet
+	var ips []net.IP
+	var patterns []string
+	for _, ex := range exclude {
+		if _, ipnet, err := net.ParseCIDR(ex); err == nil {
+			nets = append(nets, ipnet)
+		} else {
+			patterns = append(patterns, ex)
+		}
+	}
+	for _, iface := range ifaces {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, iface.name); ok {
+				ips = append(ips, iface.ips...)
+				break
+			}
+		}
+	}
+	allowed := func(ip net.IP) bool {
+		if ip.IsLoopback() {
+			return false
+		}
+		for _, ipnet := range nets {
+			if ipnet.Contains(ip) {
+				return false
+			}
+		}
+		for _, excluded := range ips {
+			if excluded.Equal(ip) {
+				return false
+			}
+		}
+		return true
+	}
+
+	res := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		uri, err := url.Parse(addr)
+		if err != nil {
+			res = append(res, addr)
+			continue
+		}
+		ip := net.ParseIP(uri.Hostname())
+		switch {
+		case uri.Hostname() == "" || ip.IsUnspecified():
+			if global {
+				res = append(res, addr)
+				continue
+			}
+			res = append(res, expandUnspecified(uri, ip, ifaces, allowed)...)
+		case ip == nil:
+			res = append(res, addr)
+		case allowed(ip):
+			res = append(res, addr)
+		}
+	}
+	return res
+}
+
+// expandUnspecified returns the address with each allowed IP of the
+// interfaces, of the family of the scheme or the unspecified IP, in place of
+// its unspecified or empty host.
+func expandUnspecified(uri *url.URL, unspecified net.IP, ifaces []interfaceAddrs, allowed func(net.IP) bool) []string {
+	onlyV4 := strings.HasSuffix(uri.Scheme, "4") || (unspecified != nil && unspecified.To4() != nil)
+	onlyV6 := strings.HasSuffix(uri.Scheme, "6")
+
+	var res []string
+	for _, iface := range ifaces {
+		for _, ip := range iface.ips {
+			if ip.IsLinkLocalUnicast() || !allowed(ip) {
+				// Link local addresses would need a zone to be of use
+				continue
+			}
+			isV4 := ip.To4() != nil
+			if (onlyV4 && !isV4) || (onlyV6 && isV4) {
+				continue
+			}
+			expanded := *uri
+			expanded.Host = net.JoinHostPort(ip.String(), uri.Port())
+			res = append(res, expanded.String())
+		}
+	}
+	return res
+}
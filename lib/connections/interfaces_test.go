@@ -0,0 +1,109 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExpandInterfaceURI(t *testing.T) {
+	ifaces := []interfaceAddrs{
+		{"eth0", []net.IP{net.ParseIP("192.168.1.2"), net.ParseIP("2001:db8::2"), net.ParseIP("fe80::1")}},
+		{"eth1", []net.IP{net.ParseIP("192.168.2.2")}},
+		{"tun0", []net.IP{net.ParseIP("10.8.0.2")}},
+	}
+
+	cases := []struct {
+		addr     string
+		expected []string
+	}{
+		{"tcp://:22000?interface=eth0", []string{"tcp://192.168.1.2:22000", "tcp://[2001:db8::2]:22000"}},
+		{"tcp4://:22000?interface=eth*", []string{"tcp4://192.168.1.2:22000", "tcp4://192.168.2.2:22000"}},
+		{"tcp6://:22000?interface=eth*", []string{"tcp6://[2001:db8::2]:22000"}},
+		{"tcp4://0.0.0.0:22000?interface=!tun*,!eth0", []string{"tcp4://192.168.2.2:22000"}},
+		{"tcp4://:22000?interface=eth*,!eth0&foo=bar", []string{"tcp4://192.168.2.2:22000?foo=bar"}},
+		{"tcp://:22000?interface=wlan0", nil},
+	}
+
+	for _, tc := range cases {
+		uri, err := url.Parse(tc.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := expandInterfaceURI(uri, ifaces); !reflect.DeepEqual(res, tc.expected) {
+			t.Errorf("expandInterfaceURI(%q) => %v, expected %v", tc.addr, res, tc.expected)
+		}
+	}
+}
+
+func TestFilterAnnounced(t *testing.T) {
+	ifaces := []interfaceAddrs{
+		{"lo", []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}},
+		{"eth0", []net.IP{net.ParseIP("192.168.1.2"), net.ParseIP("2001:db8::2"), net.ParseIP("fe80::1")}},
+		{"tun0", []net.IP{net.ParseIP("10.8.0.2")}},
+	}
+	addrs := []string{
+		"tcp://0.0.0.0:22000",
+		"quic://[::]:22000",
+		"tcp://127.0.0.1:22000",
+		"tcp://10.8.0.2:22000",
+		"tcp://192.168.1.2:22000",
+		"tcp://[2001:db8::2]:22000",
+		"relay://relay.example.com:22067/?id=abc",
+	}
+
+	// Unspecified addresses are kept for the discovery server to fill in.
+	expected := []string{
+		"tcp://0.0.0.0:22000",
+		"quic://[::]:22000",
+		"tcp://192.168.1.2:22000",
+		"relay://relay.example.com:22067/?id=abc",
+	}
+	res := filterAddresses(addrs, []string{"10.0.0.0/8", "2001:db8::/32"}, true, ifaces)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("filterAddresses => %v, expected %v", res, expected)
+	}
+
+	// Locally they are replaced by the interface addresses not excluded.
+	expected = []string{
+		"tcp://192.168.1.2:22000",
+		"quic://192.168.1.2:22000",
+		"tcp://192.168.1.2:22000",
+		"relay://relay.example.com:22067/?id=abc",
+	}
+	res = filterAddresses(addrs, []string{"10.0.0.0/8", "2001:db8::/32"}, false, ifaces)
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("filterAddresses locally => %v, expected %v", res, expected)
+	}
+
+	if res := filterAnnounced(addrs, nil, false); !reflect.DeepEqual(res, addrs) {
+		t.Errorf("filterAnnounced without exclusions => %v", res)
+	}
+}
+
+func TestFilterAnnouncedDefaultAddress(t *testing.T) {
+	ifaces := []interfaceAddrs{
+		{"eth0", []net.IP{net.ParseIP("192.168.1.2"), net.ParseIP("2001:db8::2")}},
+		{"tun0", []net.IP{net.ParseIP("10.8.0.2")}},
+	}
+
+	// The default listen address, in both its forms, with one exclusion.
+	for _, addr := range []string{"tcp://0.0.0.0:22000", "tcp://:22000"} {
+		if res := filterAddresses([]string{addr}, []string{"tun*"}, true, ifaces); !reflect.DeepEqual(res, []string{addr}) {
+			t.Errorf("Global %s => %v", addr, res)
+		}
+	}
+	if res, expected := filterAddresses([]string{"tcp://0.0.0.0:22000"}, []string{"tun*"}, false, ifaces), []string{"tcp://192.168.1.2:22000"}; !reflect.DeepEqual(res, expected) {
+		t.Errorf("Local tcp://0.0.0.0:22000 => %v, expected %v", res, expected)
+	}
+	if res, expected := filterAddresses([]string{"tcp://:22000"}, []string{"tun*"}, false, ifaces), []string{"tcp://192.168.1.2:22000", "tcp://[2001:db8::2]:22000"}; !reflect.DeepEqual(res, expected) {
+		t.Errorf("Local tcp://:22000 => %v, expected %v", res, expected)
+	}
+}
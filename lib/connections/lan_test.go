@@ -23,6 +23,9 @@ func TestIsLANHost(t *testing.T) {
 		// local nets
 		{"10.20.30.40:22000", true},
 		{"10.20.30.40", true},
+		{"10.8.0.5:22000", true},
+		{"[fd00::5]:22000", true},
+		{"10.8.0.6", false},
 		// neither
 		{"192.0.2.1:22000", false},
 		{"192.0.2.1", false},
@@ -33,7 +36,7 @@ func TestIsLANHost(t *testing.T) {
 
 	cfg := config.Wrap("/dev/null", config.Configuration{
 		Options: config.OptionsConfiguration{
-			AlwaysLocalNets: []string{"10.20.30.0/24"},
+			AlwaysLocalNets: []string{"10.20.30.0/24", "10.8.0.5", "fd00::/64"},
 		},
 	})
 	s := &service{cfg: cfg}
@@ -45,3 +48,22 @@ func TestIsLANHost(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyLocalNets(t *testing.T) {
+	s := &service{}
+	for _, tc := range []struct {
+		nets []string
+		ok   bool
+	}{
+		{[]string{"10.8.0.0/24", "fd00::/64", "192.168.1.1"}, true},
+		{[]string{"10.8.0.0/33"}, false},
+		{[]string{"wireguard"}, false},
+	} {
+		err := s.VerifyConfiguration(config.Configuration{}, config.Configuration{
+			Options: config.OptionsConfiguration{AlwaysLocalNets: tc.nets},
+		})
+		if (err == nil) != tc.ok {
+			t.Errorf("Verifying %v: %v", tc.nets, err)
+		}
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"reflect"
 	"sync/atomic"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -27,6 +29,11 @@ type limiter struct {
 	limitsLAN           atomicBool
 	deviceReadLimiters  map[protocol.DeviceID]*rate.Limiter
 	deviceWriteLimiters map[protocol.DeviceID]*rate.Limiter
+
+	opts          config.OptionsConfiguration
+	sendKbps      int // the overall limits in effect
+	recvKbps      int
+	scheduleTimer *time.Timer // re-evaluates the bandwidth schedules
 }
 
 type waiter interface {
@@ -43,6 +50,8 @@ func newLimiter(cfg config.Wrapper) *limiter {
 		mu:                  sync.NewMutex(),
 		deviceReadLimiters:  make(map[protocol.DeviceID]*rate.Limiter),
 		deviceWriteLimiters: make(map[protocol.DeviceID]*rate.Limiter),
+		sendKbps:            -1,
+		recvKbps:            -1,
 	}
 
 	cfg.Subscribe(l)
@@ -130,45 +139,76 @@ func (lim *limiter) CommitConfiguration(from, to config.Configuration) bool {
 
 	if from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
 		from.Options.MaxSendKbps == to.Options.MaxSendKbps &&
-		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan {
+		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan &&
+		reflect.DeepEqual(from.Options.BandwidthSchedules, to.Options.BandwidthSchedules) {
 		return true
 	}
 
-	limited := false
+	lim.opts = to.Options
+	lim.limitsLAN.set(to.Options.LimitBandwidthInLan)
+	lim.setOverallLimitsLocked(time.Now())
+	lim.scheduleLocked()
+
+	if lim.sendKbps > 0 || lim.recvKbps > 0 || len(to.Options.BandwidthSchedules) > 0 {
+		if to.Options.LimitBandwidthInLan {
+			l.Infoln("Rate limits apply to LAN connections")
+		} else {
+			l.Infoln("Rate limits do not apply to LAN connections")
+		}
+	}
+
+	return true
+}
+
+// setOverallLimitsLocked sets the overall rate limits in effect at the
+// time, those of the first active bandwidth schedule or else the configured
+// ones.
+func (lim *limiter) setOverallLimitsLocked(t time.Time) {
+	sendKbps, recvKbps := lim.opts.BandwidthLimits(t)
+	if sendKbps == lim.sendKbps && recvKbps == lim.recvKbps {
+		return
+	}
+	lim.sendKbps, lim.recvKbps = sendKbps, recvKbps
+
 	sendLimitStr := "is unlimited"
 	recvLimitStr := "is unlimited"
 
 	// The rate variables are in KiB/s in the config (despite the camel casing
 	// of the name). We multiply by 1024 to get bytes/s.
-	if to.Options.MaxRecvKbps <= 0 {
+	if recvKbps <= 0 {
 		lim.read.SetLimit(rate.Inf)
 	} else {
-		lim.read.SetLimit(1024 * rate.Limit(to.Options.MaxRecvKbps))
-		recvLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxRecvKbps)
-		limited = true
+		lim.read.SetLimit(1024 * rate.Limit(recvKbps))
+		recvLimitStr = fmt.Sprintf("limit is %d KiB/s", recvKbps)
 	}
 
-	if to.Options.MaxSendKbps <= 0 {
+	if sendKbps <= 0 {
 		lim.write.SetLimit(rate.Inf)
 	} else {
-		lim.write.SetLimit(1024 * rate.Limit(to.Options.MaxSendKbps))
-		sendLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxSendKbps)
-		limited = true
+		lim.write.SetLimit(1024 * rate.Limit(sendKbps))
+		sendLimitStr = fmt.Sprintf("limit is %d KiB/s", sendKbps)
 	}
 
-	lim.limitsLAN.set(to.Options.LimitBandwidthInLan)
-
 	l.Infof("Overall send rate %s, receive rate %s", sendLimitStr, recvLimitStr)
+}
 
-	if limited {
-		if to.Options.LimitBandwidthInLan {
-			l.Infoln("Rate limits apply to LAN connections")
-		} else {
-			l.Infoln("Rate limits do not apply to LAN connections")
-		}
+// scheduleLocked re-evaluates the bandwidth schedules at the start of each
+// minute, as long as there are any.
+func (lim *limiter) scheduleLocked() {
+	if lim.scheduleTimer != nil {
+		lim.scheduleTimer.Stop()
+		lim.scheduleTimer = nil
 	}
-
-	return true
+	if len(lim.opts.BandwidthSchedules) == 0 {
+		return
+	}
+	now := time.Now()
+	lim.scheduleTimer = time.AfterFunc(now.Truncate(time.Minute).Add(time.Minute).Sub(now), func() {
+		lim.mu.Lock()
+		defer lim.mu.Unlock()
+		lim.setOverallLimitsLocked(time.Now())
+		lim.scheduleLocked()
+	})
 }
 
 func (lim *limiter) String() string {
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/time/rate"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 var device1, device2, device3, device4 protocol.DeviceID
@@ -203,3 +204,42 @@ func checkActualAndExpected(t *testing.T, actualR, actualW, expectedR, expectedW
 		}
 	}
 }
+
+func TestBandwidthSchedule(t *testing.T) {
+	cfg := initConfig()
+	opts := cfg.Options()
+	opts.MaxSendKbps = 100
+	opts.MaxRecvKbps = 200
+	opts.BandwidthSchedules = []config.BandwidthSchedule{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00", MaxSendKbps: 10, MaxRecvKbps: 20},
+		{Start: "22:00", End: "06:00"},
+	}
+	waiter, _ := cfg.SetOptions(opts)
+	waiter.Wait()
+	lim := newLimiter(cfg)
+	defer func() {
+		lim.mu.Lock()
+		lim.opts.BandwidthSchedules = nil
+		lim.scheduleLocked()
+		lim.mu.Unlock()
+	}()
+
+	for _, tc := range []struct {
+		time       time.Time
+		send, recv rate.Limit
+	}{
+		{time.Date(2019, 6, 3, 10, 0, 0, 0, time.Local), 10 * 1024, 20 * 1024},   // Monday, work hours
+		{time.Date(2019, 6, 3, 17, 0, 0, 0, time.Local), 100 * 1024, 200 * 1024}, // Monday, evening
+		{time.Date(2019, 6, 8, 10, 0, 0, 0, time.Local), 100 * 1024, 200 * 1024}, // Saturday
+		{time.Date(2019, 6, 8, 23, 0, 0, 0, time.Local), rate.Inf, rate.Inf},     // Saturday night
+		{time.Date(2019, 6, 9, 5, 59, 0, 0, time.Local), rate.Inf, rate.Inf},     // Sunday early morning
+	} {
+		lim.mu.Lock()
+		lim.setOverallLimitsLocked(tc.time)
+		send, recv := lim.write.Limit(), lim.read.Limit()
+		lim.mu.Unlock()
+		if send != tc.send || recv != tc.recv {
+			t.Errorf("At %v the limits are %v and %v, expected %v and %v", tc.time, send, recv, tc.send, tc.recv)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"net"
+	"os"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const (
+	// Interface changes tend to come in bursts (link up, address
+	// assigned, routes added, ...), so wait for things to settle.
+	netChangeSettleTime = 2 * time.Second
+	// How often to check interface addresses regardless of notifications,
+	// in case we get none or miss some.
+	netPollInterval = time.Minute
+)
+
+// interfaceWatcher calls onChange when the set of addresses on the local
+// network interfaces changes. It relies on notifications from the OS where
+// available and polls otherwise.
+type interfaceWatcher struct {
+	addresses    func() ([]string, error)
+	changes      func(stop chan struct{}) (<-chan struct{}, error)
+	onChange     func(added, removed []string)
+	pollInterval time.Duration
+	stop         chan struct{}
+}
+
+func newInterfaceWatcher(onChange func(added, removed []string)) *interfaceWatcher {
+	return &interfaceWatcher{
+		addresses:    interfaceAddresses,
+		changes:      interfaceChanges,
+		onChange:     onChange,
+		pollInterval: netPollInterval,
+		stop:         make(chan struct{}),
+	}
+}
+
+func (w *interfaceWatcher) Serve() {
+	current, err := w.addresses()
+	if err != nil {
+		l.Debugln("Listing interface addresses:", err)
+	}
+
+	changes, err := w.changes(w.stop)
+	if err != nil {
+		l.Debugln("Network change notifications unavailable, polling instead:", err)
+	}
+
+	poll := time.NewTicker(w.pollInterval)
+	defer poll.Stop()
+	var settle <-chan time.Time
+
+	for {
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				l.Debugln("Network change notifications stopped, polling instead")
+				changes = nil
+				continue
+			}
+			settle = time.After(netChangeSettleTime)
+			continue
+		case <-settle:
+			settle = nil
+		case <-poll.C:
+		case <-w.stop:
+			return
+		}
+
+		addrs, err := w.addresses()
+		if err != nil {
+			l.Debugln("Listing interface addresses:", err)
+			continue
+		}
+		if added, removed := diffAddresses(current, addrs); len(added) > 0 || len(removed) > 0 {
+			l.Debugf("Interface addresses changed: added %v, removed %v", added, removed)
+			w.onChange(added, removed)
+		}
+		current = addrs
+	}
+}
+
+func (w *interfaceWatcher) Stop() {
+	close(w.stop)
+}
+
+func (w *interfaceWatcher) String() string {
+	return "interfaceWatcher"
+}
+
+// interfaceAddresses returns the sorted non-loopback addresses of the local
+// network interfaces.
+func interfaceAddresses() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() {
+			continue
+		}
+		res = append(res, ipnet.IP.String())
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// diffAddresses returns the addresses only in to and only in from, both of
+// which must be sorted.
+func diffAddresses(from, to []string) (added, removed []string) {
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case j == len(to) || (i < len(from) && from[i] < to[j]):
+			removed = append(removed, from[i])
+			i++
+		case i == len(from) || to[j] < from[i]:
+			added = append(added, to[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// notifyOnRead sends on the returned channel whenever a message can be read
+// from fd, until stop is closed. The fd must be non-blocking and is closed
+// when done.
+func notifyOnRead(fd int, name string, stop chan struct{}) <-chan struct{} {
+	f := os.NewFile(uintptr(fd), name)
+	go func() {
+		<-stop
+		f.Close()
+	}()
+
+	c := make(chan struct{}, 1)
+	go func() {
+		defer close(c)
+		buf := make([]byte, os.Getpagesize())
+		for {
+			_, err := f.Read(buf)
+			if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.ENOBUFS {
+				// We missed messages, but still know something happened.
+				err = nil
+			}
+			if err != nil {
+				return
+			}
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return c
+}
@@ -0,0 +1,26 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package connections
+
+import "syscall"
+
+// interfaceChanges listens on a routing socket, which among other things
+// reports interface and address changes.
+func interfaceChanges(stop chan struct{}) (<-chan struct{}, error) {
+	fd, err := syscall.Socket(syscall.AF_ROUTE, syscall.SOCK_RAW, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return notifyOnRead(fd, "route", stop), nil
+}
@@ -0,0 +1,15 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package connections
+
+import "errors"
+
+func interfaceChanges(stop chan struct{}) (<-chan struct{}, error) {
+	return nil, errors.New("not supported on this platform")
+}
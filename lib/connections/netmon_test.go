@@ -0,0 +1,78 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package connections
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestDiffAddresses(t *testing.T) {
+	cases := []struct {
+		from, to       []string
+		added, removed []string
+	}{
+		{nil, nil, nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}, nil, nil},
+		{nil, []string{"a"}, []string{"a"}, nil},
+		{[]string{"a"}, nil, nil, []string{"a"}},
+		{[]string{"a", "c", "e"}, []string{"b", "c", "d"}, []string{"b", "d"}, []string{"a", "e"}},
+	}
+
+	for _, tc := range cases {
+		added, removed := diffAddresses(tc.from, tc.to)
+		if !reflect.DeepEqual(added, tc.added) || !reflect.DeepEqual(removed, tc.removed) {
+			t.Errorf("diffAddresses(%v, %v) => %v, %v, expected %v, %v", tc.from, tc.to, added, removed, tc.added, tc.removed)
+		}
+	}
+}
+
+func TestInterfaceWatcher(t *testing.T) {
+	mut := sync.NewMutex()
+	addrs := []string{"192.0.2.1"}
+	notifications := make(chan struct{}, 1)
+	changed := make(chan []string, 1)
+
+	w := newInterfaceWatcher(func(added, removed []string) {
+		changed <- append(added, removed...)
+	})
+	w.addresses = func() ([]string, error) {
+		mut.Lock()
+		defer mut.Unlock()
+		return addrs, nil
+	}
+	w.changes = func(stop chan struct{}) (<-chan struct{}, error) {
+		return notifications, nil
+	}
+	go w.Serve()
+	defer w.Stop()
+
+	// A notification without an actual change does nothing.
+	notifications <- struct{}{}
+	select {
+	case c := <-changed:
+		t.Fatal("Unexpected change", c)
+	case <-time.After(netChangeSettleTime + time.Second):
+	}
+
+	// A notification with a changed address set is reported.
+	mut.Lock()
+	addrs = []string{"192.0.2.2"}
+	mut.Unlock()
+	notifications <- struct{}{}
+	select {
+	case c := <-changed:
+		if !reflect.DeepEqual(c, []string{"192.0.2.2", "192.0.2.1"}) {
+			t.Error("Unexpected change", c)
+		}
+	case <-time.After(netChangeSettleTime + 5*time.Second):
+		t.Fatal("Timed out waiting for change")
+	}
+}
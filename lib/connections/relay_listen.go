@@ -9,6 +9,7 @@ package connections
 import (
 	"crypto/tls"
 	"net/url"
+	"reflect"
 	"sync"
 	"time"
 
@@ -44,7 +45,7 @@ func (t *relayListener) Serve() {
 	t.err = nil
 	t.mut.Unlock()
 
-	clnt, err := client.NewClient(t.uri, t.tlsCfg.Certificates, nil, 10*time.Second)
+	clnt, err := client.NewMultiClient(t.uri, t.tlsCfg.Certificates, nil, 10*time.Second, t.cfg.Options().RelayConnections)
 	invitations := clnt.Invitations()
 	if err != nil {
 		t.mut.Lock()
@@ -60,7 +61,7 @@ func (t *relayListener) Serve() {
 	t.client = clnt
 	t.mut.Unlock()
 
-	oldURI := clnt.URI()
+	oldURIs := urlsToStrings(clnt.URIs())
 
 	l.Infof("Relay listener (%v) starting", t)
 	defer l.Infof("Relay listener (%v) shutting down", t)
@@ -105,13 +106,13 @@ func (t *relayListener) Serve() {
 			t.conns <- internalConn{tc, connTypeRelayServer, relayPriority}
 
 		// Poor mans notifier that informs the connection service that the
-		// relay URI has changed. This can only happen when we connect to a
-		// relay via dynamic+http(s) pool, which upon a relay failing/dropping
-		// us, would pick a different one.
+		// relay URIs have changed. This can only happen when we connect to
+		// relays via dynamic+http(s) pool, which upon a relay failing/dropping
+		// us, would pick a different one, and reorders them by latency.
 		case <-time.After(10 * time.Second):
-			currentURI := clnt.URI()
-			if currentURI != oldURI {
-				oldURI = currentURI
+			currentURIs := urlsToStrings(clnt.URIs())
+			if !reflect.DeepEqual(currentURIs, oldURIs) {
+				oldURIs = currentURIs
 				t.notifyAddressesChanged(t)
 			}
 		}
@@ -139,12 +140,7 @@ func (t *relayListener) WANAddresses() []*url.URL {
 		return nil
 	}
 
-	curi := client.URI()
-	if curi == nil {
-		return nil
-	}
-
-	return []*url.URL{curi}
+	return client.URIs()
 }
 
 func (t *relayListener) LANAddresses() []*url.URL {
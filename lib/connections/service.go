@@ -47,6 +47,7 @@ var (
 const (
 	perDeviceWarningIntv = 15 * time.Minute
 	tlsHandshakeTimeout  = 10 * time.Second
+	listenerStopTimeout  = 10 * time.Second
 )
 
 // From go/src/crypto/tls/cipher_suites.go
@@ -92,6 +93,10 @@ type Service interface {
 	suture.Service
 	Status() map[string]interface{}
 	NATType() string
+	ConnectionAttempts() []ConnectionAttempt
+	Bans() map[string]time.Time
+	NATMappings() []nat.MappingStatus
+	ReconnectNow(deviceID protocol.DeviceID)
 }
 
 type service struct {
@@ -105,9 +110,14 @@ type service struct {
 	bepProtocolName      string
 	tlsDefaultCommonName string
 	limiter              *limiter
+	attempts             *attemptTracker
 	natService           *nat.Service
 	natServiceToken      *suture.ServiceToken
 
+	reconnectMut  sync.Mutex
+	reconnectNow  map[protocol.DeviceID]struct{}
+	reconnectWake chan struct{}
+
 	listenersMut       sync.RWMutex
 	listeners          map[string]genericListener
 	listenerTokens     map[string]suture.ServiceToken
@@ -133,8 +143,13 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 		bepProtocolName:      bepProtocolName,
 		tlsDefaultCommonName: tlsDefaultCommonName,
 		limiter:              newLimiter(cfg),
+		attempts:             newAttemptTracker(),
 		natService:           nat.NewService(myID, cfg),
 
+		reconnectMut:  sync.NewMutex(),
+		reconnectNow:  make(map[protocol.DeviceID]struct{}),
+		reconnectWake: make(chan struct{}, 1),
+
 		listenersMut:   sync.NewRWMutex(),
 		listeners:      make(map[string]genericListener),
 		listenerTokens: make(map[string]suture.ServiceToken),
@@ -169,6 +184,7 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 	service.Add(serviceFunc(service.connect))
 	service.Add(serviceFunc(service.handle))
 	service.Add(service.listenerSupervisor)
+	service.Add(newInterfaceWatcher(service.interfacesChanged))
 
 	return service
 }
@@ -207,6 +223,22 @@ next:
 			continue
 		}
 
+		// Bans are for devices we don't know. Those we do are let through,
+		// even from an address banned for what other devices did there.
+		direct := c.connType.Transport() != "relay"
+		if _, known := s.cfg.Device(remoteID); !known {
+			if s.attempts.bannedDevice(remoteID, time.Now()) {
+				l.Debugf("Dropping connection from banned device %s at %s", remoteID, c)
+				c.Close()
+				continue
+			}
+			if direct && s.attempts.bannedAddress(c.RemoteAddr(), time.Now()) {
+				l.Debugf("Dropping connection from %s at banned address %s", remoteID, c.RemoteAddr())
+				c.Close()
+				continue
+			}
+		}
+
 		c.SetDeadline(time.Now().Add(20 * time.Second))
 		hello, err := protocol.ExchangeHello(c, s.model.GetHello(remoteID))
 		if err != nil {
@@ -237,6 +269,9 @@ next:
 		// have a connection with for whatever reason, for example unknown devices.
 		if err := s.model.OnHello(remoteID, c.RemoteAddr(), hello); err != nil {
 			l.Infof("Connection from %s at %s (%s) rejected: %v", remoteID, c.RemoteAddr(), c.Type(), err)
+			if _, known := s.cfg.Device(remoteID); !known {
+				s.attempts.record(remoteID, c.RemoteAddr(), direct, remoteCert, time.Now())
+			}
 			c.Close()
 			continue
 		}
@@ -289,7 +324,7 @@ next:
 		isLAN := s.isLAN(c.RemoteAddr())
 		rd, wr := s.limiter.getLimiters(remoteID, c, isLAN)
 
-		protoConn := protocol.NewConnection(remoteID, rd, wr, s.model, c.String(), deviceCfg.Compression)
+		protoConn := protocol.NewConnection(remoteID, rd, wr, s.model, c.String(), deviceCfg.Compression, s.cfg.FolderPasswords(remoteID))
 		modelConn := completeConn{c, protoConn}
 
 		l.Infof("Established secure connection to %s at %s", remoteID, c)
@@ -309,9 +344,23 @@ func (s *service) connect() {
 	// Calculated from actual dialers reconnectInterval
 	var sleep time.Duration
 
+	// Consecutive failed dial attempts per device, for backoff
+	failures := make(map[protocol.DeviceID]int)
+
 	for {
 		cfg := s.cfg.RawCopy()
 
+		for deviceID := range s.takeReconnectNow() {
+			l.Debugln("Reconnecting to", deviceID, "now")
+			prefix := deviceID.String() + "/"
+			for key := range nextDial {
+				if strings.HasPrefix(key, prefix) {
+					delete(nextDial, key)
+				}
+			}
+			delete(failures, deviceID)
+		}
+
 		bestDialerPrio := 1<<31 - 1 // worse prio won't build on 32 bit
 		for _, df := range dialers {
 			if df.Valid(cfg) != nil {
@@ -341,6 +390,7 @@ func (s *service) connect() {
 
 			if connected && ct.Priority() == bestDialerPrio {
 				// Things are already as good as they can get.
+				delete(failures, deviceID)
 				continue
 			}
 
@@ -412,7 +462,7 @@ func (s *service) connect() {
 				}
 
 				dialer := dialerFactory.New(s.cfg, s.tlsCfg)
-				nextDial[nextDialKey] = now.Add(dialer.RedialFrequency())
+				nextDial[nextDialKey] = now.Add(redialInterval(deviceCfg, dialer.RedialFrequency(), failures[deviceID]))
 
 				// For LAN addresses, increase the priority so that we
 				// try these first.
@@ -433,21 +483,45 @@ func (s *service) connect() {
 
 			conn, ok := dialParallel(deviceCfg.DeviceID, dialTargets)
 			if ok {
+				delete(failures, deviceID)
 				s.conns <- conn
+			} else if len(dialTargets) > 0 && !connected {
+				failures[deviceID]++
 			}
 		}
 
 		nextDial, sleep = filterAndFindSleepDuration(nextDial, seen, now)
 
+		wait := sleep
 		if initialRampup < sleep {
 			l.Debugln("initial rampup; sleep", initialRampup, "and update to", initialRampup*2)
-			time.Sleep(initialRampup)
+			wait = initialRampup
 			initialRampup *= 2
 		} else {
 			l.Debugln("sleep until next dial", sleep)
-			time.Sleep(sleep)
 		}
+		select {
+		case <-time.After(wait):
+		case <-s.reconnectWake:
+		}
+	}
+}
+
+// parseLocalNet parses a network of the AlwaysLocalNets, in CIDR notation
+// like 10.8.0.0/24 for a VPN, or a single address.
+func parseLocalNet(lan string) (*net.IPNet, error) {
+	if !strings.Contains(lan, "/") {
+		ip := net.ParseIP(lan)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", lan)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
 	}
+	_, ipnet, err := net.ParseCIDR(lan)
+	return ipnet, err
 }
 
 func (s *service) isLANHost(host string) bool {
@@ -485,7 +559,7 @@ func (s *service) isLAN(addr net.Addr) bool {
 	}
 
 	for _, lan := range s.cfg.Options().AlwaysLocalNets {
-		_, ipnet, err := net.ParseCIDR(lan)
+		ipnet, err := parseLocalNet(lan)
 		if err != nil {
 			l.Debugln("Network", lan, "is malformed:", err)
 			continue
@@ -517,6 +591,65 @@ func (s *service) createListener(factory listenerFactory, uri *url.URL) bool {
 	return true
 }
 
+// interfacesChanged restarts the listeners after the addresses of the local
+// network interfaces changed. This gets listeners bound to an address that
+// just came back going again, and NAT mappings and announcements updated
+// without waiting for their timers. Listeners restricted to interfaces are
+// started and stopped as their addresses come and go.
+func (s *service) interfacesChanged(added, removed []string) {
+	l.Infof("Network interface addresses changed (added %v, removed %v), restarting listeners", added, removed)
+	events.Default.Log(events.InterfaceAddressesChanged, map[string][]string{
+		"added":   added,
+		"removed": removed,
+	})
+
+	type restart struct {
+		addr    string
+		uri     *url.URL
+		factory listenerFactory
+		token   suture.ServiceToken
+	}
+	var restarts []restart
+
+	s.listenersMut.Lock()
+	existing := make(map[string]struct{}, len(s.listeners))
+	for addr := range s.listeners {
+		existing[addr] = struct{}{}
+	}
+	s.updateListenersLocked(s.cfg.RawCopy())
+	for addr, listener := range s.listeners {
+		if _, ok := existing[addr]; !ok {
+			// Just started
+			continue
+		}
+		// The key is the parsed listen address, while the listener's own
+		// URI may have been adjusted, e.g. with a default port.
+		uri, err := url.Parse(addr)
+		if err != nil {
+			continue
+		}
+		restarts = append(restarts, restart{addr, uri, listener.Factory(), s.listenerTokens[addr]})
+	}
+	s.listenersMut.Unlock()
+
+	// Stopping a listener can take a while, which mustn't hold up those
+	// wanting the status or addresses of the listeners.
+	for _, r := range restarts {
+		l.Debugln("Restarting listener", r.addr)
+		s.listenerSupervisor.RemoveAndWait(r.token, listenerStopTimeout)
+	}
+
+	s.listenersMut.Lock()
+	defer s.listenersMut.Unlock()
+	for _, r := range restarts {
+		// Unless the configuration changed in the meantime, removing or
+		// replacing the listener.
+		if token, ok := s.listenerTokens[r.addr]; ok && token == r.token {
+			s.createListener(r.factory, r.uri)
+		}
+	}
+}
+
 func (s *service) logListenAddressesChangedEvent(l genericListener) {
 	events.Default.Log(events.ListenAddressesChanged, map[string]interface{}{
 		"address": l.URI(),
@@ -526,6 +659,11 @@ func (s *service) logListenAddressesChangedEvent(l genericListener) {
 }
 
 func (s *service) VerifyConfiguration(from, to config.Configuration) error {
+	for _, lan := range to.Options.AlwaysLocalNets {
+		if _, err := parseLocalNet(lan); err != nil {
+			return fmt.Errorf("always local network: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -535,7 +673,9 @@ func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 		newDevices[dev.DeviceID] = true
 	}
 
+	oldDevices := make(map[protocol.DeviceID]bool, len(from.Devices))
 	for _, dev := range from.Devices {
+		oldDevices[dev.DeviceID] = true
 		if !newDevices[dev.DeviceID] {
 			warningLimitersMut.Lock()
 			delete(warningLimiters, dev.DeviceID)
@@ -543,9 +683,36 @@ func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 		}
 	}
 
+	// A device that was banned while unknown isn't anymore once added.
+	for _, dev := range to.Devices {
+		if !oldDevices[dev.DeviceID] {
+			s.attempts.forget(dev.DeviceID)
+		}
+	}
+
 	s.listenersMut.Lock()
+	s.updateListenersLocked(to)
+	s.listenersMut.Unlock()
+
+	if to.Options.NATEnabled && s.natServiceToken == nil {
+		l.Debugln("Starting NAT service")
+		token := s.Add(s.natService)
+		s.natServiceToken = &token
+	} else if !to.Options.NATEnabled && s.natServiceToken != nil {
+		l.Debugln("Stopping NAT service")
+		s.Remove(*s.natServiceToken)
+		s.natServiceToken = nil
+	}
+
+	return true
+}
+
+// updateListenersLocked starts and stops listeners to match the listen
+// addresses in the given configuration. It must be called with listenersMut
+// held.
+func (s *service) updateListenersLocked(to config.Configuration) {
 	seen := make(map[string]struct{})
-	for _, addr := range config.Wrap("", to).ListenAddresses() {
+	for _, addr := range expandListenAddresses(config.Wrap("", to).ListenAddresses()) {
 		if addr == "" {
 			// We can get an empty address if there is an empty listener
 			// element in the config, indicating no listeners should be
@@ -591,19 +758,6 @@ func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 			delete(s.listeners, addr)
 		}
 	}
-	s.listenersMut.Unlock()
-
-	if to.Options.NATEnabled && s.natServiceToken == nil {
-		l.Debugln("Starting NAT service")
-		token := s.Add(s.natService)
-		s.natServiceToken = &token
-	} else if !to.Options.NATEnabled && s.natServiceToken != nil {
-		l.Debugln("Stopping NAT service")
-		s.Remove(*s.natServiceToken)
-		s.natServiceToken = nil
-	}
-
-	return true
 }
 
 func (s *service) AllAddresses() []string {
@@ -618,7 +772,8 @@ func (s *service) AllAddresses() []string {
 		}
 	}
 	s.listenersMut.RUnlock()
-	return util.UniqueStrings(addrs)
+	// Expanding unspecified addresses may duplicate others.
+	return util.UniqueStrings(filterAnnounced(addrs, s.cfg.Options().LocalAnnExclude, false))
 }
 
 func (s *service) ExternalAddresses() []string {
@@ -630,7 +785,7 @@ func (s *service) ExternalAddresses() []string {
 		}
 	}
 	s.listenersMut.RUnlock()
-	return util.UniqueStrings(addrs)
+	return util.UniqueStrings(filterAnnounced(addrs, s.cfg.Options().GlobalAnnExclude, true))
 }
 
 func (s *service) Status() map[string]interface{} {
@@ -653,6 +808,24 @@ func (s *service) Status() map[string]interface{} {
 	return result
 }
 
+// ConnectionAttempts returns the recorded connection attempts from devices
+// that are not in our configuration, the most recent first.
+func (s *service) ConnectionAttempts() []ConnectionAttempt {
+	return s.attempts.list()
+}
+
+// Bans returns the device IDs and IP addresses that are temporarily banned
+// due to too many rejected connection attempts, with the time the ban ends.
+func (s *service) Bans() map[string]time.Time {
+	return s.attempts.bans(time.Now())
+}
+
+// NATMappings returns the state of the port mappings acquired through UPnP,
+// NAT-PMP or PCP.
+func (s *service) NATMappings() []nat.MappingStatus {
+	return s.natService.Status()
+}
+
 func (s *service) NATType() string {
 	s.listenersMut.RLock()
 	defer s.listenersMut.RUnlock()
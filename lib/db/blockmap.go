@@ -11,6 +11,7 @@ import (
 	"fmt"
 
 	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
 
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
@@ -41,6 +42,26 @@ func (f *BlockFinder) String() string {
 // reason. The iterator finally returns the result, whether or not a
 // satisfying block was eventually found.
 func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string, string, int32) bool) bool {
+	return f.iterate(folders, hash, func(folder, file string, index int32, _ int) bool {
+		return iterFn(folder, file, index)
+	})
+}
+
+// IterateOffsets is like Iterate, except that the iterator function is
+// given the offset of the block in the file, as the files may have
+// different block sizes.
+func (f *BlockFinder) IterateOffsets(folders []string, hash []byte, iterFn func(string, string, int64) bool) bool {
+	return f.iterate(folders, hash, func(folder, file string, index int32, blockSize int) bool {
+		if blockSize <= 0 {
+			return false
+		}
+		return iterFn(folder, file, int64(index)*int64(blockSize))
+	})
+}
+
+// iterate calls the iterator function with the block index and the block
+// size of the file, which is zero if unknown.
+func (f *BlockFinder) iterate(folders []string, hash []byte, iterFn func(string, string, int32, int) bool) bool {
 	t := f.db.newReadOnlyTransaction()
 	defer t.close()
 
@@ -50,9 +71,16 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 		iter := t.NewIterator(util.BytesPrefix(key), nil)
 
 		for iter.Next() && iter.Error() == nil {
-			file := string(f.db.keyer.NameFromBlockMapKey(iter.Key()))
-			index := int32(binary.BigEndian.Uint32(iter.Value()))
-			if iterFn(folder, osutil.NativeFilename(file), index) {
+			name := f.db.keyer.NameFromBlockMapKey(iter.Key())
+			index, blockSize, ok := decodeBlockMapValue(iter.Value())
+			if !ok {
+				// Entries written before the block size was recorded, it's
+				// that of the file.
+				if fi, exists := t.getFile([]byte(folder), protocol.LocalDeviceID[:], name); exists {
+					blockSize = fi.BlockSize()
+				}
+			}
+			if iterFn(folder, osutil.NativeFilename(string(name)), index, blockSize) {
 				iter.Release()
 				return true
 			}
@@ -61,3 +89,31 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 	}
 	return false
 }
+
+// A block map value is the index of the block in the file, followed by the
+// block size of the file.
+const blockMapValueLength = 8
+
+func encodeBlockMapValue(buf []byte, index int32, blockSize int) []byte {
+	if cap(buf) < blockMapValueLength {
+		buf = make([]byte, blockMapValueLength)
+	}
+	buf = buf[:blockMapValueLength]
+	binary.BigEndian.PutUint32(buf, uint32(index))
+	binary.BigEndian.PutUint32(buf[4:], uint32(blockSize))
+	return buf
+}
+
+// decodeBlockMapValue returns the block index and block size. Values that
+// only hold the index, as written by earlier versions, have no size and
+// ok is false.
+func decodeBlockMapValue(bs []byte) (index int32, blockSize int, ok bool) {
+	if len(bs) < 4 {
+		return 0, 0, false
+	}
+	index = int32(binary.BigEndian.Uint32(bs))
+	if len(bs) < blockMapValueLength {
+		return index, 0, false
+	}
+	return index, int(binary.BigEndian.Uint32(bs[4:])), true
+}
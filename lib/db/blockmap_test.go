@@ -230,3 +230,43 @@ func TestBlockFinderLookup(t *testing.T) {
 
 	f1.Deleted = false
 }
+
+func TestBlockFinderOffsets(t *testing.T) {
+	db, f := setup()
+
+	folder1 := []byte("folder1")
+	folder2 := []byte("folder2")
+
+	small := protocol.FileInfo{
+		Name:   "small",
+		Blocks: []protocol.BlockInfo{f1.Blocks[1], f1.Blocks[0]},
+	}
+	large := protocol.FileInfo{
+		Name:         "large",
+		RawBlockSize: 4 * protocol.MinBlockSize,
+		Blocks:       []protocol.BlockInfo{f1.Blocks[2], f1.Blocks[0]},
+	}
+	db.updateLocalFiles(folder1, []protocol.FileInfo{small}, newMetadataTracker())
+	db.updateLocalFiles(folder2, []protocol.FileInfo{large}, newMetadataTracker())
+
+	offsets := make(map[string]int64)
+	f.IterateOffsets(folders, f1.Blocks[0].Hash, func(folder, file string, offset int64) bool {
+		offsets[folder+"/"+file] = offset
+		return false
+	})
+	if len(offsets) != 2 || offsets["folder1/small"] != protocol.MinBlockSize || offsets["folder2/large"] != 4*protocol.MinBlockSize {
+		t.Errorf("Unexpected offsets %v", offsets)
+	}
+
+	// Entries without the block size take that of the file, and are
+	// skipped if there is none.
+	addToBlockMap(db, folder2, []protocol.FileInfo{large, {Name: "unknown", Blocks: f1.Blocks[:1]}})
+	offsets = make(map[string]int64)
+	f.IterateOffsets(folders[1:], f1.Blocks[0].Hash, func(folder, file string, offset int64) bool {
+		offsets[folder+"/"+file] = offset
+		return false
+	})
+	if len(offsets) != 1 || offsets["folder2/large"] != 4*protocol.MinBlockSize {
+		t.Errorf("Unexpected offsets %v", offsets)
+	}
+}
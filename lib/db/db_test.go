@@ -220,3 +220,14 @@ func TestDowngrade(t *testing.T) {
 		t.Fatalf("Error has %v as min Syncthing version, expected %v", err.minSyncthingVersion, dbMinSyncthingVersion)
 	}
 }
+
+func TestCheck(t *testing.T) {
+	db := OpenMemory()
+	if err := db.Check(); err != nil {
+		t.Fatal("Unexpected error for an open database:", err)
+	}
+	db.Close()
+	if err := db.Check(); err == nil {
+		t.Fatal("Expected an error for a closed database")
+	}
+}
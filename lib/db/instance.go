@@ -8,7 +8,6 @@ package db
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -66,8 +65,7 @@ func (db *instance) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta
 	t := db.newReadWriteTransaction()
 	defer t.close()
 
-	var dk, gk, keyBuf []byte
-	blockBuf := make([]byte, 4)
+	var dk, gk, keyBuf, blockBuf []byte
 	for _, f := range fs {
 		name := []byte(f.Name)
 		dk = db.keyer.GenerateDeviceFileKey(dk, folder, protocol.LocalDeviceID[:], name)
@@ -109,7 +107,7 @@ func (db *instance) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta
 
 		if !f.IsDirectory() && !f.IsDeleted() && !f.IsInvalid() {
 			for i, block := range f.Blocks {
-				binary.BigEndian.PutUint32(blockBuf, uint32(i))
+				blockBuf = encodeBlockMapValue(blockBuf, int32(i), f.BlockSize())
 				keyBuf = db.keyer.GenerateBlockMapKey(keyBuf, folder, block.Hash, name)
 				t.Put(keyBuf, blockBuf)
 			}
@@ -122,7 +120,10 @@ func (db *instance) updateLocalFiles(folder []byte, fs []protocol.FileInfo, meta
 func (db *instance) withHave(folder, device, prefix []byte, truncate bool, fn Iterator) {
 	t := db.newReadOnlyTransaction()
 	defer t.close()
+	t.withHave(folder, device, prefix, truncate, fn)
+}
 
+func (t readOnlyTransaction) withHave(folder, device, prefix []byte, truncate bool, fn Iterator) {
 	if len(prefix) > 0 {
 		unslashedPrefix := prefix
 		if bytes.HasSuffix(prefix, []byte{'/'}) {
@@ -131,16 +132,16 @@ func (db *instance) withHave(folder, device, prefix []byte, truncate bool, fn It
 			prefix = append(prefix, '/')
 		}
 
-		if f, ok := t.getFileTrunc(db.keyer.GenerateDeviceFileKey(nil, folder, device, unslashedPrefix), true); ok && !fn(f) {
+		if f, ok := t.getFileTrunc(t.keyer.GenerateDeviceFileKey(nil, folder, device, unslashedPrefix), true); ok && !fn(f) {
 			return
 		}
 	}
 
-	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateDeviceFileKey(nil, folder, device, prefix)), nil)
+	dbi := t.NewIterator(util.BytesPrefix(t.keyer.GenerateDeviceFileKey(nil, folder, device, prefix)), nil)
 	defer dbi.Release()
 
 	for dbi.Next() {
-		name := db.keyer.NameFromDeviceFileKey(dbi.Key())
+		name := t.keyer.NameFromDeviceFileKey(dbi.Key())
 		if len(prefix) > 0 && !bytes.HasPrefix(name, prefix) {
 			return
 		}
@@ -238,10 +239,16 @@ func (db *instance) getGlobalDirty(folder, file []byte, truncate bool) (FileIntf
 	return f, ok
 }
 
-func (db *instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator) {
+// withGlobal iterates the global files with the given prefix. If levels is
+// not negative, files nested more than that many directories below the
+// prefix are skipped over without being read.
+func (db *instance) withGlobal(folder, prefix []byte, levels int, truncate bool, fn Iterator) {
 	t := db.newReadOnlyTransaction()
 	defer t.close()
+	t.withGlobal(folder, prefix, levels, truncate, fn)
+}
 
+func (t readOnlyTransaction) withGlobal(folder, prefix []byte, levels int, truncate bool, fn Iterator) {
 	if len(prefix) > 0 {
 		unslashedPrefix := prefix
 		if bytes.HasSuffix(prefix, []byte{'/'}) {
@@ -255,22 +262,38 @@ func (db *instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator
 		}
 	}
 
-	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateGlobalVersionKey(nil, folder, prefix)), nil)
+	dbi := t.NewIterator(util.BytesPrefix(t.keyer.GenerateGlobalVersionKey(nil, folder, prefix)), nil)
 	defer dbi.Release()
 
-	var dk []byte
+	var dk, sk []byte
 	for dbi.Next() {
-		name := db.keyer.NameFromGlobalVersionKey(dbi.Key())
+		name := t.keyer.NameFromGlobalVersionKey(dbi.Key())
 		if len(prefix) > 0 && !bytes.HasPrefix(name, prefix) {
 			return
 		}
 
+		if levels >= 0 {
+			if i := nthIndexByte(name[len(prefix):], '/', levels); i >= 0 {
+				// Skip the rest of the too deeply nested directory: Seek
+				// to the first name sorting after "dir/", then step back
+				// so that Next lands on it.
+				skip := append([]byte{}, name[:len(prefix)+i]...)
+				skip = append(skip, '/'+1)
+				sk = t.keyer.GenerateGlobalVersionKey(sk, folder, skip)
+				if !dbi.Seek(sk) {
+					return
+				}
+				dbi.Prev()
+				continue
+			}
+		}
+
 		vl, ok := unmarshalVersionList(dbi.Value())
 		if !ok {
 			continue
 		}
 
-		dk = db.keyer.GenerateDeviceFileKey(dk, folder, vl.Versions[0].Device, name)
+		dk = t.keyer.GenerateDeviceFileKey(dk, folder, vl.Versions[0].Device, name)
 
 		f, ok := t.getFileTrunc(dk, truncate)
 		if !ok {
@@ -283,9 +306,32 @@ func (db *instance) withGlobal(folder, prefix []byte, truncate bool, fn Iterator
 	}
 }
 
+// nthIndexByte returns the index of the n'th (zero based) occurrence of c
+// in s, or -1.
+func nthIndexByte(s []byte, c byte, n int) int {
+	offset := 0
+	for {
+		i := bytes.IndexByte(s[offset:], c)
+		if i < 0 {
+			return -1
+		}
+		if n == 0 {
+			return offset + i
+		}
+		n--
+		offset += i + 1
+	}
+}
+
 func (db *instance) availability(folder, file []byte) []protocol.DeviceID {
-	k := db.keyer.GenerateGlobalVersionKey(nil, folder, file)
-	bs, err := db.Get(k, nil)
+	t := db.newReadOnlyTransaction()
+	defer t.close()
+	return t.availability(folder, file)
+}
+
+func (t readOnlyTransaction) availability(folder, file []byte) []protocol.DeviceID {
+	k := t.keyer.GenerateGlobalVersionKey(nil, folder, file)
+	bs, err := t.Get(k, nil)
 	if err == leveldb.ErrNotFound {
 		return nil
 	}
@@ -315,15 +361,18 @@ func (db *instance) availability(folder, file []byte) []protocol.DeviceID {
 }
 
 func (db *instance) withNeed(folder, device []byte, truncate bool, fn Iterator) {
+	t := db.newReadOnlyTransaction()
+	defer t.close()
+	t.withNeed(folder, device, truncate, fn)
+}
+
+func (t readOnlyTransaction) withNeed(folder, device []byte, truncate bool, fn Iterator) {
 	if bytes.Equal(device, protocol.LocalDeviceID[:]) {
-		db.withNeedLocal(folder, truncate, fn)
+		t.withNeedLocal(folder, truncate, fn)
 		return
 	}
 
-	t := db.newReadOnlyTransaction()
-	defer t.close()
-
-	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateGlobalVersionKey(nil, folder, nil).WithoutName()), nil)
+	dbi := t.NewIterator(util.BytesPrefix(t.keyer.GenerateGlobalVersionKey(nil, folder, nil).WithoutName()), nil)
 	defer dbi.Release()
 
 	var dk []byte
@@ -342,7 +391,7 @@ func (db *instance) withNeed(folder, device []byte, truncate bool, fn Iterator)
 			continue
 		}
 
-		name := db.keyer.NameFromGlobalVersionKey(dbi.Key())
+		name := t.keyer.NameFromGlobalVersionKey(dbi.Key())
 		needVersion := vl.Versions[0].Version
 		needDevice := protocol.DeviceIDFromBytes(vl.Versions[0].Device)
 
@@ -357,7 +406,7 @@ func (db *instance) withNeed(folder, device []byte, truncate bool, fn Iterator)
 				continue
 			}
 
-			dk = db.keyer.GenerateDeviceFileKey(dk, folder, vl.Versions[i].Device, name)
+			dk = t.keyer.GenerateDeviceFileKey(dk, folder, vl.Versions[i].Device, name)
 			gf, ok := t.getFileTrunc(dk, truncate)
 			if !ok {
 				continue
@@ -383,15 +432,18 @@ func (db *instance) withNeed(folder, device []byte, truncate bool, fn Iterator)
 func (db *instance) withNeedLocal(folder []byte, truncate bool, fn Iterator) {
 	t := db.newReadOnlyTransaction()
 	defer t.close()
+	t.withNeedLocal(folder, truncate, fn)
+}
 
-	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateNeedFileKey(nil, folder, nil).WithoutName()), nil)
+func (t readOnlyTransaction) withNeedLocal(folder []byte, truncate bool, fn Iterator) {
+	dbi := t.NewIterator(util.BytesPrefix(t.keyer.GenerateNeedFileKey(nil, folder, nil).WithoutName()), nil)
 	defer dbi.Release()
 
 	var keyBuf []byte
 	var f FileIntf
 	var ok bool
 	for dbi.Next() {
-		keyBuf, f, ok = t.getGlobal(keyBuf, folder, db.keyer.NameFromGlobalVersionKey(dbi.Key()), truncate)
+		keyBuf, f, ok = t.getGlobal(keyBuf, folder, t.keyer.NameFromGlobalVersionKey(dbi.Key()), truncate)
 		if !ok {
 			continue
 		}
@@ -416,6 +468,10 @@ func (db *instance) dropFolder(folder []byte) {
 		db.keyer.GenerateNeedFileKey(nil, folder, nil).WithoutName(),
 		// Remove the blockmap of the folder
 		db.keyer.GenerateBlockMapKey(nil, folder, nil, nil).WithoutHashAndName(),
+		// Remove all index snapshots of the folder
+		db.keyer.GenerateSnapshotKey(nil, folder, 0, nil).WithoutTimeAndName(),
+		// Remove the completed blocks of temporary files in the folder
+		db.keyer.GeneratePartialFileKey(nil, folder, nil).WithoutName(),
 	} {
 		t.deleteKeyPrefix(key)
 	}
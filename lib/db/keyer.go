@@ -15,6 +15,7 @@ const (
 	keyFolderLen   = 4 // indexed
 	keyDeviceLen   = 4 // indexed
 	keySequenceLen = 8
+	keyTimeLen     = 8
 	keyHashLen     = 32
 
 	maxInt64 int64 = 1<<63 - 1
@@ -59,6 +60,12 @@ const (
 
 	// KeyTypeNeed <int32 folder ID> <file name> = <nothing>
 	KeyTypeNeed = 12
+
+	// KeyTypeSnapshot <int32 folder ID> <int64 unix nanos> <file name> = FileInfoTruncated
+	KeyTypeSnapshot = 13
+
+	// KeyTypePartialFile <int32 folder ID> <file name> = PartialFile
+	KeyTypePartialFile = 14
 )
 
 type keyer interface {
@@ -93,6 +100,14 @@ type keyer interface {
 
 	// Folder metadata
 	GenerateFolderMetaKey(key, folder []byte) folderMetaKey
+
+	// index snapshots
+	GenerateSnapshotKey(key, folder []byte, when int64, name []byte) snapshotKey
+	TimeFromSnapshotKey(key []byte) int64
+	NameFromSnapshotKey(key []byte) []byte
+
+	// completed blocks of temporary files
+	GeneratePartialFileKey(key, folder, name []byte) partialFileKey
 }
 
 // defaultKeyer implements our key scheme. It needs folder and device
@@ -241,6 +256,51 @@ func (k defaultKeyer) GenerateFolderMetaKey(key, folder []byte) folderMetaKey {
 	return key
 }
 
+type snapshotKey []byte
+
+func (k snapshotKey) WithoutTimeAndName() []byte {
+	return k[:keyPrefixLen+keyFolderLen]
+}
+
+func (k snapshotKey) WithoutName() []byte {
+	return k[:keyPrefixLen+keyFolderLen+keyTimeLen]
+}
+
+func (k defaultKeyer) GenerateSnapshotKey(key, folder []byte, when int64, name []byte) snapshotKey {
+	key = resize(key, keyPrefixLen+keyFolderLen+keyTimeLen+len(name))
+	key[0] = KeyTypeSnapshot
+	binary.BigEndian.PutUint32(key[keyPrefixLen:], k.folderIdx.ID(folder))
+	binary.BigEndian.PutUint64(key[keyPrefixLen+keyFolderLen:], uint64(when))
+	copy(key[keyPrefixLen+keyFolderLen+keyTimeLen:], name)
+	return key
+}
+
+func (k defaultKeyer) TimeFromSnapshotKey(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[keyPrefixLen+keyFolderLen:]))
+}
+
+func (k defaultKeyer) NameFromSnapshotKey(key []byte) []byte {
+	return key[keyPrefixLen+keyFolderLen+keyTimeLen:]
+}
+
+type partialFileKey []byte
+
+func (k partialFileKey) WithoutName() []byte {
+	return k[:keyPrefixLen+keyFolderLen]
+}
+
+func (k partialFileKey) Name() []byte {
+	return k[keyPrefixLen+keyFolderLen:]
+}
+
+func (k defaultKeyer) GeneratePartialFileKey(key, folder, name []byte) partialFileKey {
+	key = resize(key, keyPrefixLen+keyFolderLen+len(name))
+	key[0] = KeyTypePartialFile
+	binary.BigEndian.PutUint32(key[keyPrefixLen:], k.folderIdx.ID(folder))
+	copy(key[keyPrefixLen+keyFolderLen:], name)
+	return key
+}
+
 // resize returns a byte slice of the specified size, reusing bs if possible
 func resize(bs []byte, size int) []byte {
 	if cap(bs) < size {
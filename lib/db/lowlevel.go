@@ -88,6 +88,17 @@ func (db *Lowlevel) ListFolders() []string {
 	return db.folderIdx.Values()
 }
 
+// Check returns an error when the database can't be read, e.g. as it has
+// been closed.
+func (db *Lowlevel) Check() error {
+	snap, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	snap.Release()
+	return nil
+}
+
 // Committed returns the number of items committed to the database since startup
 func (db *Lowlevel) Committed() int64 {
 	return atomic.LoadInt64(&db.committed)
@@ -272,6 +272,22 @@ func (m *metadataTracker) Counts(dev protocol.DeviceID, flag uint32) Counts {
 	return m.counts.Counts[idx]
 }
 
+// clone returns a copy of the metadataTracker, which isn't affected by
+// later changes to it
+func (m *metadataTracker) clone() *metadataTracker {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	c := newMetadataTracker()
+	c.counts.Created = m.counts.Created
+	c.counts.Counts = make([]Counts, len(m.counts.Counts))
+	copy(c.counts.Counts, m.counts.Counts)
+	for k, v := range m.indexes {
+		c.indexes[k] = v
+	}
+	return c
+}
+
 // nextLocalSeq allocates a new local sequence number
 func (m *metadataTracker) nextLocalSeq() int64 {
 	m.mut.Lock()
@@ -0,0 +1,116 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// A PartialFile records which blocks of the temporary file of a file being
+// pulled are complete, so that pulling can resume from them after a restart
+// rather than hashing the whole temporary file again.
+type PartialFile struct {
+	Version   protocol.Vector // The version of the file being pulled
+	BlockSize int
+	Blocks    []int32 // Indexes of the complete blocks
+}
+
+var errPartialFileTruncated = errors.New("partial file record truncated")
+
+// The encoding is the block size and the number of blocks as uint32, the
+// block indexes as uint32 each, followed by the marshalled version vector.
+func (p PartialFile) marshal() []byte {
+	vbs, _ := p.Version.Marshal() // marshalling can't fail
+	bs := make([]byte, 8+4*len(p.Blocks)+len(vbs))
+	binary.BigEndian.PutUint32(bs, uint32(p.BlockSize))
+	binary.BigEndian.PutUint32(bs[4:], uint32(len(p.Blocks)))
+	for i, index := range p.Blocks {
+		binary.BigEndian.PutUint32(bs[8+4*i:], uint32(index))
+	}
+	copy(bs[8+4*len(p.Blocks):], vbs)
+	return bs
+}
+
+func (p *PartialFile) unmarshal(bs []byte) error {
+	if len(bs) < 8 {
+		return errPartialFileTruncated
+	}
+	p.BlockSize = int(binary.BigEndian.Uint32(bs))
+	n := int(binary.BigEndian.Uint32(bs[4:]))
+	if len(bs) < 8+4*n {
+		return errPartialFileTruncated
+	}
+	p.Blocks = make([]int32, n)
+	for i := range p.Blocks {
+		p.Blocks[i] = int32(binary.BigEndian.Uint32(bs[8+4*i:]))
+	}
+	p.Version = protocol.Vector{}
+	return p.Version.Unmarshal(bs[8+4*n:])
+}
+
+// PartialFile returns the recorded complete blocks of the temporary file
+// of the given file, if any.
+func (s *FileSet) PartialFile(file string) (PartialFile, bool) {
+	key := s.db.keyer.GeneratePartialFileKey(nil, []byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+	bs, err := s.db.Get(key, nil)
+	if err != nil {
+		return PartialFile{}, false
+	}
+	var p PartialFile
+	if err := p.unmarshal(bs); err != nil {
+		l.Debugf("%s PartialFile(%v): %v", s.folder, file, err)
+		return PartialFile{}, false
+	}
+	return p, true
+}
+
+// SetPartialFile records the complete blocks of the temporary file of the
+// given file, replacing an earlier record.
+func (s *FileSet) SetPartialFile(file string, p PartialFile) {
+	l.Debugf("%s SetPartialFile(%v, %d blocks)", s.folder, file, len(p.Blocks))
+	key := s.db.keyer.GeneratePartialFileKey(nil, []byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+	if err := s.db.Put(key, p.marshal(), nil); err != nil {
+		panic("storing partial file: " + err.Error())
+	}
+}
+
+// DropPartialFile removes the record of the complete blocks of the
+// temporary file of the given file.
+func (s *FileSet) DropPartialFile(file string) {
+	l.Debugf("%s DropPartialFile(%v)", s.folder, file)
+	key := s.db.keyer.GeneratePartialFileKey(nil, []byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+	if err := s.db.Delete(key, nil); err != nil {
+		panic("removing partial file: " + err.Error())
+	}
+}
+
+// ListPartialFiles calls fn with the recorded complete blocks of the
+// temporary files of the folder, until it returns false.
+func (s *FileSet) ListPartialFiles(fn func(file string, p PartialFile) bool) {
+	t := s.db.newReadOnlyTransaction()
+	defer t.close()
+
+	dbi := t.NewIterator(util.BytesPrefix(s.db.keyer.GeneratePartialFileKey(nil, []byte(s.folder), nil).WithoutName()), nil)
+	defer dbi.Release()
+
+	for dbi.Next() {
+		var p PartialFile
+		if err := p.unmarshal(dbi.Value()); err != nil {
+			l.Debugf("%s ListPartialFiles: %v", s.folder, err)
+			continue
+		}
+		name := osutil.NativeFilename(string(partialFileKey(dbi.Key()).Name()))
+		if !fn(name, p) {
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestPartialFile(t *testing.T) {
+	ll := OpenMemory()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+
+	if _, ok := s.PartialFile("file"); ok {
+		t.Fatal("unexpected partial file")
+	}
+
+	p := PartialFile{
+		Version:   protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 2}}},
+		BlockSize: protocol.MinBlockSize,
+		Blocks:    []int32{3, 0, 7},
+	}
+	s.SetPartialFile("file", p)
+	if got, ok := s.PartialFile("file"); !ok || !reflect.DeepEqual(got, p) {
+		t.Fatalf("got %+v, %v, expected %+v", got, ok, p)
+	}
+
+	p.Blocks = append(p.Blocks, 1)
+	s.SetPartialFile("file", p)
+	if got, _ := s.PartialFile("file"); !reflect.DeepEqual(got, p) {
+		t.Fatalf("got %+v, expected %+v", got, p)
+	}
+
+	other := NewFileSet("other", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+	other.SetPartialFile("file", p)
+	s.SetPartialFile("dir/file", p)
+	var names []string
+	s.ListPartialFiles(func(name string, got PartialFile) bool {
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("listed %+v, expected %+v", got, p)
+		}
+		names = append(names, name)
+		return true
+	})
+	if expected := []string{osutil.NativeFilename("dir/file"), "file"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("listed %v, expected %v", names, expected)
+	}
+	s.DropPartialFile("dir/file")
+	other.DropPartialFile("file")
+
+	s.DropPartialFile("file")
+	if _, ok := s.PartialFile("file"); ok {
+		t.Fatal("partial file not dropped")
+	}
+
+	s.SetPartialFile("file", p)
+	DropFolder(ll, "test")
+	it := ll.NewIterator(util.BytesPrefix([]byte{KeyTypePartialFile}), nil)
+	defer it.Release()
+	if it.Next() {
+		t.Fatal("partial file not dropped with the folder")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A ReadSnapshot is a read only view of a FileSet at one point in time.
+// Files and counts read through it are consistent with each other, however
+// the FileSet changes in the meantime. It must be released when no longer
+// needed, as it holds on to database resources.
+type ReadSnapshot struct {
+	folder string
+	t      readOnlyTransaction
+	meta   *metadataTracker
+}
+
+// ReadSnapshot returns a snapshot of the current state of the FileSet.
+func (s *FileSet) ReadSnapshot() *ReadSnapshot {
+	// Holding the update mutex means no update is half way done, i.e. the
+	// database and the metadata agree.
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	return &ReadSnapshot{
+		folder: s.folder,
+		t:      s.db.newReadOnlyTransaction(),
+		meta:   s.meta.clone(),
+	}
+}
+
+// Release frees the resources held by the snapshot. It must not be used
+// afterwards.
+func (s *ReadSnapshot) Release() {
+	s.t.close()
+}
+
+func (s *ReadSnapshot) WithNeed(device protocol.DeviceID, fn Iterator) {
+	l.Debugf("%s snapshot WithNeed(%v)", s.folder, device)
+	s.t.withNeed([]byte(s.folder), device[:], false, nativeFileIterator(fn))
+}
+
+func (s *ReadSnapshot) WithNeedTruncated(device protocol.DeviceID, fn Iterator) {
+	l.Debugf("%s snapshot WithNeedTruncated(%v)", s.folder, device)
+	s.t.withNeed([]byte(s.folder), device[:], true, nativeFileIterator(fn))
+}
+
+func (s *ReadSnapshot) WithHaveTruncated(device protocol.DeviceID, fn Iterator) {
+	l.Debugf("%s snapshot WithHaveTruncated(%v)", s.folder, device)
+	s.t.withHave([]byte(s.folder), device[:], nil, true, nativeFileIterator(fn))
+}
+
+func (s *ReadSnapshot) WithGlobalTruncated(fn Iterator) {
+	l.Debugf("%s snapshot WithGlobalTruncated()", s.folder)
+	s.t.withGlobal([]byte(s.folder), nil, -1, true, nativeFileIterator(fn))
+}
+
+func (s *ReadSnapshot) Get(device protocol.DeviceID, file string) (protocol.FileInfo, bool) {
+	f, ok := s.t.getFile([]byte(s.folder), device[:], []byte(osutil.NormalizedFilename(file)))
+	f.Name = osutil.NativeFilename(f.Name)
+	return f, ok
+}
+
+func (s *ReadSnapshot) GetGlobalTruncated(file string) (FileInfoTruncated, bool) {
+	_, fi, ok := s.t.getGlobal(nil, []byte(s.folder), []byte(osutil.NormalizedFilename(file)), true)
+	if !ok {
+		return FileInfoTruncated{}, false
+	}
+	f := fi.(FileInfoTruncated)
+	f.Name = osutil.NativeFilename(f.Name)
+	return f, true
+}
+
+func (s *ReadSnapshot) Availability(file string) []protocol.DeviceID {
+	return s.t.availability([]byte(s.folder), []byte(osutil.NormalizedFilename(file)))
+}
+
+func (s *ReadSnapshot) Sequence(device protocol.DeviceID) int64 {
+	return s.meta.Sequence(device)
+}
+
+func (s *ReadSnapshot) LocalSize() Counts {
+	local := s.meta.Counts(protocol.LocalDeviceID, 0)
+	recvOnlyChanged := s.meta.Counts(protocol.LocalDeviceID, protocol.FlagLocalReceiveOnly)
+	return local.Add(recvOnlyChanged)
+}
+
+func (s *ReadSnapshot) ReceiveOnlyChangedSize() Counts {
+	return s.meta.Counts(protocol.LocalDeviceID, protocol.FlagLocalReceiveOnly)
+}
+
+func (s *ReadSnapshot) GlobalSize() Counts {
+	global := s.meta.Counts(protocol.GlobalDeviceID, 0)
+	recvOnlyChanged := s.meta.Counts(protocol.GlobalDeviceID, protocol.FlagLocalReceiveOnly)
+	return global.Add(recvOnlyChanged)
+}
@@ -197,7 +197,7 @@ func (db *schemaUpdater) updateSchema2to3() {
 	var dk []byte
 	for _, folderStr := range db.ListFolders() {
 		folder := []byte(folderStr)
-		db.withGlobal(folder, nil, true, func(f FileIntf) bool {
+		db.withGlobal(folder, nil, -1, true, func(f FileIntf) bool {
 			name := []byte(f.FileName())
 			dk = db.keyer.GenerateDeviceFileKey(dk, folder, protocol.LocalDeviceID[:], name)
 			var v protocol.Vector
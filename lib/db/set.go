@@ -185,19 +185,28 @@ func (s *FileSet) WithPrefixedHaveTruncated(device protocol.DeviceID, prefix str
 }
 func (s *FileSet) WithGlobal(fn Iterator) {
 	l.Debugf("%s WithGlobal()", s.folder)
-	s.db.withGlobal([]byte(s.folder), nil, false, nativeFileIterator(fn))
+	s.db.withGlobal([]byte(s.folder), nil, -1, false, nativeFileIterator(fn))
 }
 
 func (s *FileSet) WithGlobalTruncated(fn Iterator) {
 	l.Debugf("%s WithGlobalTruncated()", s.folder)
-	s.db.withGlobal([]byte(s.folder), nil, true, nativeFileIterator(fn))
+	s.db.withGlobal([]byte(s.folder), nil, -1, true, nativeFileIterator(fn))
 }
 
 // Except for an item with a path equal to prefix, only children of prefix are iterated.
 // E.g. for prefix "dir", "dir/file" is iterated, but "dir.file" is not.
 func (s *FileSet) WithPrefixedGlobalTruncated(prefix string, fn Iterator) {
 	l.Debugf(`%s WithPrefixedGlobalTruncated("%v")`, s.folder, prefix)
-	s.db.withGlobal([]byte(s.folder), []byte(osutil.NormalizedFilename(prefix)), true, nativeFileIterator(fn))
+	s.db.withGlobal([]byte(s.folder), []byte(osutil.NormalizedFilename(prefix)), -1, true, nativeFileIterator(fn))
+}
+
+// WithPrefixedGlobalTruncatedLevels is like WithPrefixedGlobalTruncated, but
+// items nested more than levels directories below the prefix are skipped.
+// E.g. for prefix "dir" and levels 0, "dir/sub" is iterated, but "dir/sub/file"
+// is not. A negative levels means no limit.
+func (s *FileSet) WithPrefixedGlobalTruncatedLevels(prefix string, levels int, fn Iterator) {
+	l.Debugf(`%s WithPrefixedGlobalTruncatedLevels("%v", %d)`, s.folder, prefix, levels)
+	s.db.withGlobal([]byte(s.folder), []byte(osutil.NormalizedFilename(prefix)), levels, true, nativeFileIterator(fn))
 }
 
 func (s *FileSet) Get(device protocol.DeviceID, file string) (protocol.FileInfo, bool) {
@@ -989,6 +989,48 @@ func TestIssue4925(t *testing.T) {
 	}
 }
 
+func TestWithPrefixedGlobalTruncatedLevels(t *testing.T) {
+	ldb := db.OpenMemory()
+
+	s := db.NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ldb)
+
+	replace(s, protocol.LocalDeviceID, fileList{
+		protocol.FileInfo{Name: "a", Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "a/b", Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "a/b/c", Type: protocol.FileInfoTypeDirectory},
+		protocol.FileInfo{Name: "a/b/c/file"},
+		protocol.FileInfo{Name: "a/b/file"},
+		protocol.FileInfo{Name: "a/b.file"},
+		protocol.FileInfo{Name: "a/b0"},
+		protocol.FileInfo{Name: "a/file"},
+		protocol.FileInfo{Name: "file"},
+	})
+
+	cases := []struct {
+		prefix   string
+		levels   int
+		expected []string
+	}{
+		{"", 0, []string{"a", "file"}},
+		{"", 1, []string{"a", "a/b", "a/b.file", "a/b0", "a/file", "file"}},
+		{"", -1, []string{"a", "a/b", "a/b.file", "a/b/c", "a/b/c/file", "a/b/file", "a/b0", "a/file", "file"}},
+		{"a", 0, []string{"a", "a/b", "a/b.file", "a/b0", "a/file"}},
+		{"a/b", 0, []string{"a/b", "a/b/c", "a/b/file"}},
+		{"a/b", 1, []string{"a/b", "a/b/c", "a/b/c/file", "a/b/file"}},
+	}
+
+	for _, tc := range cases {
+		var names []string
+		s.WithPrefixedGlobalTruncatedLevels(tc.prefix, tc.levels, func(fi db.FileIntf) bool {
+			names = append(names, filepath.ToSlash(fi.FileName()))
+			return true
+		})
+		if fmt.Sprint(names) != fmt.Sprint(tc.expected) {
+			t.Errorf("Prefix %q, levels %d: expected %v, got %v", tc.prefix, tc.levels, tc.expected, names)
+		}
+	}
+}
+
 func TestMoveGlobalBack(t *testing.T) {
 	ldb := db.OpenMemory()
 
@@ -1466,3 +1508,54 @@ func replace(fs *db.FileSet, device protocol.DeviceID, files []protocol.FileInfo
 	fs.Drop(device)
 	fs.Update(device, files)
 }
+
+func TestReadSnapshot(t *testing.T) {
+	ldb := db.OpenMemory()
+	s := db.NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ldb)
+
+	v1 := protocol.Vector{Counters: []protocol.Counter{{ID: myID, Value: 1}}}
+	s.Update(protocol.LocalDeviceID, fileList{{Name: "a", Version: v1, Blocks: genBlocks(1)}})
+
+	snap := s.ReadSnapshot()
+	defer snap.Release()
+
+	// Changes after taking the snapshot are invisible in it.
+	v2 := v1.Update(remoteDevice0.Short())
+	s.Update(remoteDevice0, fileList{
+		{Name: "a", Version: v2, Blocks: genBlocks(2), Sequence: 1},
+		{Name: "b", Version: v2, Blocks: genBlocks(3), Sequence: 2},
+	})
+
+	if global := snap.GlobalSize(); global.Files != 1 || global.Bytes != s.LocalSize().Bytes {
+		t.Errorf("Unexpected global size in snapshot: %v", global)
+	}
+	var need []string
+	snap.WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
+		need = append(need, f.FileName())
+		return true
+	})
+	if len(need) != 0 {
+		t.Errorf("Expected no need in snapshot, got %v", need)
+	}
+	if _, ok := snap.GetGlobalTruncated("b"); ok {
+		t.Error("File added after the snapshot is visible in it")
+	}
+	if seq := snap.Sequence(remoteDevice0); seq != 0 {
+		t.Errorf("Unexpected remote sequence %d in snapshot", seq)
+	}
+
+	newSnap := s.ReadSnapshot()
+	defer newSnap.Release()
+	if global := newSnap.GlobalSize(); global.Files != 2 {
+		t.Errorf("Unexpected global size in new snapshot: %v", global)
+	}
+	if seq := newSnap.Sequence(remoteDevice0); seq != 2 {
+		t.Errorf("Unexpected remote sequence %d in new snapshot", seq)
+	}
+	if need := needList(s, protocol.LocalDeviceID); len(need) != 2 {
+		t.Errorf("Expected two needed files, got %v", need)
+	}
+	if avail := newSnap.Availability("b"); len(avail) != 1 || avail[0] != remoteDevice0 {
+		t.Errorf("Unexpected availability %v", avail)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// ErrNoSnapshot is returned when there is no index snapshot old enough to
+// satisfy a request.
+var ErrNoSnapshot = errors.New("no index snapshot at or before the given time")
+
+// An index snapshot is a copy of the metadata (everything except the
+// blocks) of the global version of each file in a folder, as of a given
+// point in time. Deleted files are kept in the snapshot as well, so that we
+// can tell who deleted something. Each snapshot also has an entry with an
+// empty name, marking its existence even if the folder was empty at the
+// time.
+
+type SnapshotChangeType int
+
+const (
+	SnapshotAppeared SnapshotChangeType = iota
+	SnapshotChanged
+	SnapshotVanished
+)
+
+func (c SnapshotChangeType) String() string {
+	switch c {
+	case SnapshotAppeared:
+		return "appeared"
+	case SnapshotChanged:
+		return "changed"
+	case SnapshotVanished:
+		return "vanished"
+	default:
+		return "unknown"
+	}
+}
+
+// SnapshotChange describes how a file differs between two index snapshots.
+// Old is the zero value if the file appeared, New is the zero value if the
+// file vanished without leaving a deleted entry behind. Otherwise
+// New.ModifiedBy tells which device made the change.
+type SnapshotChange struct {
+	Type SnapshotChangeType
+	Old  FileInfoTruncated
+	New  FileInfoTruncated
+}
+
+func (c SnapshotChange) Name() string {
+	if c.New.Name != "" {
+		return c.New.Name
+	}
+	return c.Old.Name
+}
+
+// TakeSnapshot records the current global state of the folder as an index
+// snapshot taken at the given time.
+func (s *FileSet) TakeSnapshot(when time.Time) {
+	l.Debugf("%s TakeSnapshot(%v)", s.folder, when)
+	s.db.takeSnapshot([]byte(s.folder), when.UnixNano())
+}
+
+// Snapshots returns the times of the index snapshots of the folder, oldest
+// first.
+func (s *FileSet) Snapshots() []time.Time {
+	var times []time.Time
+	for _, when := range s.db.snapshotTimes([]byte(s.folder)) {
+		times = append(times, time.Unix(0, when))
+	}
+	return times
+}
+
+// PruneSnapshots removes all but the newest keep index snapshots of the
+// folder.
+func (s *FileSet) PruneSnapshots(keep int) {
+	folder := []byte(s.folder)
+	times := s.db.snapshotTimes(folder)
+	for len(times) > keep {
+		l.Debugf("%s PruneSnapshots: dropping %v", s.folder, time.Unix(0, times[0]))
+		s.db.dropPrefix(s.db.keyer.GenerateSnapshotKey(nil, folder, times[0], nil).WithoutName())
+		times = times[1:]
+	}
+}
+
+// DiffSnapshots returns the differences between the newest index snapshots
+// taken at or before from and to respectively, ordered by file name. A zero
+// to means comparing against the current global state of the folder.
+func (s *FileSet) DiffSnapshots(from, to time.Time) ([]SnapshotChange, error) {
+	folder := []byte(s.folder)
+	times := s.db.snapshotTimes(folder)
+
+	fromNanos, ok := snapshotAt(times, from.UnixNano())
+	if !ok {
+		return nil, ErrNoSnapshot
+	}
+	toNanos := int64(-1)
+	if !to.IsZero() {
+		if toNanos, ok = snapshotAt(times, to.UnixNano()); !ok {
+			return nil, ErrNoSnapshot
+		}
+	}
+
+	changes := s.db.diffSnapshots(folder, fromNanos, toNanos)
+	for i := range changes {
+		changes[i].Old.Name = osutil.NativeFilename(changes[i].Old.Name)
+		changes[i].New.Name = osutil.NativeFilename(changes[i].New.Name)
+	}
+	return changes, nil
+}
+
+// GetSnapshotFile returns the file as recorded in the newest index snapshot
+// taken at or before when. The returned bool is false if the snapshot
+// doesn't know the file.
+func (s *FileSet) GetSnapshotFile(when time.Time, file string) (FileInfoTruncated, bool, error) {
+	folder := []byte(s.folder)
+	nanos, ok := snapshotAt(s.db.snapshotTimes(folder), when.UnixNano())
+	if !ok {
+		return FileInfoTruncated{}, false, ErrNoSnapshot
+	}
+	f, ok := s.db.getSnapshotFile(folder, nanos, []byte(osutil.NormalizedFilename(file)))
+	f.Name = osutil.NativeFilename(f.Name)
+	return f, ok, nil
+}
+
+// snapshotAt returns the newest of the sorted times that is not after when.
+func snapshotAt(times []int64, when int64) (int64, bool) {
+	for i := len(times) - 1; i >= 0; i-- {
+		if times[i] <= when {
+			return times[i], true
+		}
+	}
+	return 0, false
+}
+
+func (db *instance) takeSnapshot(folder []byte, when int64) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	t.Put(db.keyer.GenerateSnapshotKey(nil, folder, when, nil), nil)
+
+	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateGlobalVersionKey(nil, folder, nil).WithoutName()), nil)
+	defer dbi.Release()
+
+	var dk, sk []byte
+	for dbi.Next() {
+		name := db.keyer.NameFromGlobalVersionKey(dbi.Key())
+		vl, ok := unmarshalVersionList(dbi.Value())
+		if !ok {
+			continue
+		}
+		dk = db.keyer.GenerateDeviceFileKey(dk, folder, vl.Versions[0].Device, name)
+		f, ok := t.getFileTrunc(dk, true)
+		if !ok {
+			continue
+		}
+		ft := f.(FileInfoTruncated)
+		bs, err := ft.Marshal()
+		if err != nil {
+			panic("marshalling FileInfoTruncated: " + err.Error())
+		}
+		sk = db.keyer.GenerateSnapshotKey(sk, folder, when, name)
+		t.Put(sk, bs)
+		t.checkFlush()
+	}
+}
+
+func (db *instance) getSnapshotFile(folder []byte, when int64, name []byte) (FileInfoTruncated, bool) {
+	bs, err := db.Get(db.keyer.GenerateSnapshotKey(nil, folder, when, name), nil)
+	if err != nil {
+		if err != leveldb.ErrNotFound {
+			l.Debugln("surprise error:", err)
+		}
+		return FileInfoTruncated{}, false
+	}
+	var f FileInfoTruncated
+	if err := f.Unmarshal(bs); err != nil {
+		l.Debugln("unmarshal error:", err)
+		return FileInfoTruncated{}, false
+	}
+	return f, true
+}
+
+func (db *instance) snapshotTimes(folder []byte) []int64 {
+	t := db.newReadOnlyTransaction()
+	defer t.close()
+
+	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateSnapshotKey(nil, folder, 0, nil).WithoutTimeAndName()), nil)
+	defer dbi.Release()
+
+	var times []int64
+	var key []byte
+	for ok := dbi.First(); ok; ok = dbi.Seek(key) {
+		when := db.keyer.TimeFromSnapshotKey(dbi.Key())
+		times = append(times, when)
+		if when == maxInt64 {
+			break
+		}
+		// Skip ahead to the next snapshot
+		key = db.keyer.GenerateSnapshotKey(key, folder, when+1, nil)
+	}
+	return times
+}
+
+// diffSnapshots compares the snapshots taken at from and to, or the current
+// global state if to is negative.
+func (db *instance) diffSnapshots(folder []byte, from, to int64) []SnapshotChange {
+	t := db.newReadOnlyTransaction()
+	defer t.close()
+
+	oldIt := newSnapshotIterator(t, folder, from)
+	defer oldIt.Release()
+	newIt := newSnapshotIterator(t, folder, to)
+	defer newIt.Release()
+
+	var changes []SnapshotChange
+	compare := func(o, n FileInfoTruncated) {
+		oldExists := o.Name != "" && !o.Deleted
+		newExists := n.Name != "" && !n.Deleted
+		switch {
+		case oldExists && !newExists:
+			changes = append(changes, SnapshotChange{Type: SnapshotVanished, Old: o, New: n})
+		case !oldExists && newExists:
+			changes = append(changes, SnapshotChange{Type: SnapshotAppeared, Old: o, New: n})
+		case oldExists && newExists && !o.Version.Equal(n.Version):
+			changes = append(changes, SnapshotChange{Type: SnapshotChanged, Old: o, New: n})
+		}
+	}
+
+	o, oOk := oldIt.next()
+	n, nOk := newIt.next()
+	for oOk || nOk {
+		switch {
+		case !nOk || oOk && o.Name < n.Name:
+			compare(o, FileInfoTruncated{})
+			o, oOk = oldIt.next()
+		case !oOk || n.Name < o.Name:
+			compare(FileInfoTruncated{}, n)
+			n, nOk = newIt.next()
+		default:
+			compare(o, n)
+			o, oOk = oldIt.next()
+			n, nOk = newIt.next()
+		}
+	}
+	return changes
+}
+
+// snapshotIterator yields the files of an index snapshot, or of the current
+// global state, in name order.
+type snapshotIterator struct {
+	iterator.Iterator
+	t      readOnlyTransaction
+	folder []byte
+	global bool
+	dk     []byte
+}
+
+func newSnapshotIterator(t readOnlyTransaction, folder []byte, when int64) *snapshotIterator {
+	it := &snapshotIterator{
+		t:      t,
+		folder: folder,
+		global: when < 0,
+	}
+	if it.global {
+		it.Iterator = t.NewIterator(util.BytesPrefix(t.keyer.GenerateGlobalVersionKey(nil, folder, nil).WithoutName()), nil)
+	} else {
+		it.Iterator = t.NewIterator(util.BytesPrefix(t.keyer.GenerateSnapshotKey(nil, folder, when, nil).WithoutName()), nil)
+	}
+	return it
+}
+
+func (it *snapshotIterator) next() (FileInfoTruncated, bool) {
+	for it.Next() {
+		if !it.global {
+			if len(it.t.keyer.NameFromSnapshotKey(it.Key())) == 0 {
+				// The snapshot marker
+				continue
+			}
+			var f FileInfoTruncated
+			if err := f.Unmarshal(it.Value()); err != nil {
+				l.Debugln("unmarshal error:", err)
+				continue
+			}
+			return f, true
+		}
+
+		vl, ok := unmarshalVersionList(it.Value())
+		if !ok {
+			continue
+		}
+		it.dk = it.t.keyer.GenerateDeviceFileKey(it.dk, it.folder, vl.Versions[0].Device, it.t.keyer.NameFromGlobalVersionKey(it.Key()))
+		f, ok := it.t.getFileTrunc(it.dk, true)
+		if !ok {
+			continue
+		}
+		return f.(FileInfoTruncated), true
+	}
+	return FileInfoTruncated{}, false
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	ll := OpenMemory()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+
+	remote := protocol.DeviceID{42}
+	v1 := protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}}}
+	v2 := v1.Update(remote.Short())
+
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "changed", Version: v1, ModifiedBy: 1},
+		{Name: "deleted", Version: v1, ModifiedBy: 1},
+		{Name: "same", Version: v1, ModifiedBy: 1},
+	})
+
+	t0 := time.Unix(1000, 0)
+	s.TakeSnapshot(t0)
+
+	s.Update(remote, []protocol.FileInfo{
+		{Name: "appeared", Version: v2, ModifiedBy: remote.Short()},
+		{Name: "changed", Version: v2, ModifiedBy: remote.Short()},
+		{Name: "deleted", Version: v2, ModifiedBy: remote.Short(), Deleted: true},
+		{Name: "same", Version: v1, ModifiedBy: 1},
+	})
+
+	t1 := time.Unix(2000, 0)
+	s.TakeSnapshot(t1)
+
+	if snaps := s.Snapshots(); len(snaps) != 2 || !snaps[0].Equal(t0) || !snaps[1].Equal(t1) {
+		t.Fatal("Unexpected snapshots:", snaps)
+	}
+
+	check := func(changes []SnapshotChange) {
+		t.Helper()
+		expected := []struct {
+			name string
+			typ  SnapshotChangeType
+		}{
+			{"appeared", SnapshotAppeared},
+			{"changed", SnapshotChanged},
+			{"deleted", SnapshotVanished},
+		}
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %d changes, got %d: %v", len(expected), len(changes), changes)
+		}
+		for i, c := range changes {
+			if c.Name() != expected[i].name || c.Type != expected[i].typ {
+				t.Errorf("Change %d is %v %v, expected %v %v", i, c.Type, c.Name(), expected[i].typ, expected[i].name)
+			}
+			if c.New.ModifiedBy != remote.Short() {
+				t.Errorf("Change %d not attributed to the remote device", i)
+			}
+		}
+	}
+
+	// Between two snapshots, with times not matching the snapshots exactly
+	changes, err := s.DiffSnapshots(t0.Add(time.Minute), t1.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(changes)
+
+	// Against the current state
+	changes, err = s.DiffSnapshots(t0, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(changes)
+
+	// Nothing changed since the last snapshot
+	if changes, err := s.DiffSnapshots(t1, time.Time{}); err != nil || len(changes) != 0 {
+		t.Error("Unexpected changes since last snapshot:", changes, err)
+	}
+
+	if _, err := s.DiffSnapshots(t0.Add(-time.Minute), t1); err != ErrNoSnapshot {
+		t.Error("Expected ErrNoSnapshot, got", err)
+	}
+
+	s.PruneSnapshots(1)
+	if snaps := s.Snapshots(); len(snaps) != 1 || !snaps[0].Equal(t1) {
+		t.Fatal("Unexpected snapshots after pruning:", snaps)
+	}
+
+	DropFolder(ll, "test")
+	if snaps := s.Snapshots(); len(snaps) != 0 {
+		t.Fatal("Unexpected snapshots after dropping folder:", snaps)
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	ll := OpenMemory()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+
+	v1 := protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}}}
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "file", Version: v1, ModifiedS: 100},
+	})
+
+	if _, _, err := s.GetSnapshotFile(time.Unix(1000, 0), "file"); err != ErrNoSnapshot {
+		t.Fatal("Expected no snapshot, got", err)
+	}
+
+	t0 := time.Unix(1000, 0)
+	s.TakeSnapshot(t0)
+	s.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "file", Version: v1.Update(1), ModifiedS: 200},
+	})
+
+	f, ok, err := s.GetSnapshotFile(t0.Add(time.Hour), "file")
+	if err != nil || !ok {
+		t.Fatal("Expected file in snapshot", ok, err)
+	}
+	if f.ModifiedS != 100 {
+		t.Error("Expected the snapshotted modification time, got", f.ModifiedS)
+	}
+
+	if _, ok, _ := s.GetSnapshotFile(t0, "other"); ok {
+		t.Error("Unexpected file in snapshot")
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// A Problem describes an inconsistency found in the database for a given
+// folder.
+type Problem struct {
+	Folder string
+	Name   string // file name, if the problem concerns a specific file
+	Desc   string
+}
+
+func (p Problem) String() string {
+	if p.Name == "" {
+		return fmt.Sprintf("folder %q: %s", p.Folder, p.Desc)
+	}
+	return fmt.Sprintf("folder %q, file %q: %s", p.Folder, p.Name, p.Desc)
+}
+
+// Verify checks the database contents for the given folder for
+// consistency. The canonical per device file entries are checked for sane
+// version vectors, and the derived data (global version lists, the need
+// and sequence indexes, the block map and the size accounting) is checked
+// against them. The database is not modified.
+func Verify(ll *Lowlevel, folder string) []Problem {
+	db := newInstance(ll)
+	v := &verifier{
+		db:     db,
+		folder: []byte(folder),
+		meta:   newMetadataTracker(),
+	}
+
+	t := db.newReadOnlyTransaction()
+	defer t.close()
+
+	v.checkDeviceFiles(t)
+	v.checkSequences(t)
+	v.checkGlobals(t)
+	v.checkNeeds(t)
+	v.checkMeta()
+
+	return v.problems
+}
+
+// Repair rebuilds the derived data for the given folder (global version
+// lists, the need and sequence indexes, the block map and the size
+// accounting) from the per device file entries. Invalid file entries are
+// dropped and duplicate local sequence numbers are reassigned.
+func Repair(ll *Lowlevel, folder string) {
+	db := newInstance(ll)
+	f := []byte(folder)
+
+	t := db.newReadWriteTransaction()
+	for _, key := range [][]byte{
+		db.keyer.GenerateSequenceKey(nil, f, 0).WithoutSequence(),
+		db.keyer.GenerateGlobalVersionKey(nil, f, nil).WithoutName(),
+		db.keyer.GenerateNeedFileKey(nil, f, nil).WithoutName(),
+		db.keyer.GenerateBlockMapKey(nil, f, nil, nil).WithoutHashAndName(),
+	} {
+		t.deleteKeyPrefix(key)
+	}
+	t.close()
+	db.dropFolderMeta(f)
+
+	meta := newMetadataTracker()
+	devices := db.repairDeviceFiles(f)
+
+	// The local device goes first, as sequence numbers and the block map
+	// only concern local files. Each device gets its own transaction so
+	// that the global version lists written for one device are visible
+	// when processing the next.
+	db.repairLocalFiles(f, meta)
+	for _, device := range devices {
+		if bytes.Equal(device, protocol.LocalDeviceID[:]) {
+			continue
+		}
+		db.repairRemoteFiles(f, device, meta)
+	}
+
+	meta.SetCreated()
+	meta.toDB(db, f)
+
+	l.Infof("Repaired database for folder %q", folder)
+}
+
+// repairDeviceFiles drops file entries that cannot be used at all and
+// returns the list of devices that have files in the given folder.
+func (db *instance) repairDeviceFiles(folder []byte) [][]byte {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateDeviceFileKey(nil, folder, nil, nil).WithoutNameAndDevice()), nil)
+	defer dbi.Release()
+
+	var devices [][]byte
+	for dbi.Next() {
+		device, ok := db.keyer.DeviceFromDeviceFileKey(dbi.Key())
+		if !ok {
+			t.Delete(dbi.Key())
+			t.checkFlush()
+			continue
+		}
+		var f FileInfoTruncated
+		if err := f.Unmarshal(dbi.Value()); err != nil || f.Name != string(db.keyer.NameFromDeviceFileKey(dbi.Key())) {
+			l.Infof("Dropping broken entry for %q from database", db.keyer.NameFromDeviceFileKey(dbi.Key()))
+			t.Delete(dbi.Key())
+			t.checkFlush()
+			continue
+		}
+		if len(devices) == 0 || !bytes.Equal(devices[len(devices)-1], device) {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+func (db *instance) repairLocalFiles(folder []byte, meta *metadataTracker) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	prefix := db.keyer.GenerateDeviceFileKey(nil, folder, protocol.LocalDeviceID[:], nil)
+
+	// Find the highest sequence number in use, so that we know where to
+	// start when reassigning duplicates.
+	var maxSeq int64
+	dbi := t.NewIterator(util.BytesPrefix(prefix), nil)
+	for dbi.Next() {
+		var f FileInfoTruncated
+		if err := f.Unmarshal(dbi.Value()); err == nil && f.Sequence > maxSeq {
+			maxSeq = f.Sequence
+		}
+	}
+	dbi.Release()
+
+	seen := make(map[int64]struct{})
+	var gk, keyBuf, blockBuf []byte
+	dbi = t.NewIterator(util.BytesPrefix(prefix), nil)
+	defer dbi.Release()
+	for dbi.Next() {
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			continue
+		}
+		dk := append([]byte{}, dbi.Key()...)
+		name := []byte(f.Name)
+
+		if _, ok := seen[f.Sequence]; ok || f.Sequence <= 0 {
+			maxSeq++
+			l.Debugf("reassigning sequence; folder=%q sequence=%d->%d %v", folder, f.Sequence, maxSeq, f.Name)
+			f.Sequence = maxSeq
+			t.Put(dk, mustMarshal(&f))
+		}
+		seen[f.Sequence] = struct{}{}
+
+		meta.addFile(protocol.LocalDeviceID, f)
+
+		keyBuf = db.keyer.GenerateSequenceKey(keyBuf, folder, f.Sequence)
+		t.Put(keyBuf, dk)
+
+		if !f.IsDirectory() && !f.IsDeleted() && !f.IsInvalid() {
+			for i, block := range f.Blocks {
+				blockBuf = encodeBlockMapValue(blockBuf, int32(i), f.BlockSize())
+				keyBuf = db.keyer.GenerateBlockMapKey(keyBuf, folder, block.Hash, name)
+				t.Put(keyBuf, blockBuf)
+			}
+		}
+
+		gk = db.keyer.GenerateGlobalVersionKey(gk, folder, name)
+		keyBuf, _ = t.updateGlobal(gk, keyBuf, folder, protocol.LocalDeviceID[:], f, meta)
+
+		t.checkFlush()
+	}
+}
+
+func (db *instance) repairRemoteFiles(folder, device []byte, meta *metadataTracker) {
+	t := db.newReadWriteTransaction()
+	defer t.close()
+
+	dbi := t.NewIterator(util.BytesPrefix(db.keyer.GenerateDeviceFileKey(nil, folder, device, nil)), nil)
+	defer dbi.Release()
+
+	devID := protocol.DeviceIDFromBytes(device)
+	var gk, keyBuf []byte
+	for dbi.Next() {
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			continue
+		}
+
+		meta.addFile(devID, f)
+
+		gk = db.keyer.GenerateGlobalVersionKey(gk, folder, []byte(f.Name))
+		keyBuf, _ = t.updateGlobal(gk, keyBuf, folder, device, f, meta)
+
+		t.checkFlush()
+	}
+}
+
+type verifier struct {
+	db       *instance
+	folder   []byte
+	meta     *metadataTracker
+	problems []Problem
+}
+
+func (v *verifier) problem(name []byte, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Folder: string(v.folder),
+		Name:   string(name),
+		Desc:   fmt.Sprintf(format, args...),
+	})
+}
+
+// checkDeviceFiles verifies the per device file entries and the sequence
+// and block map entries pointing to local files.
+func (v *verifier) checkDeviceFiles(t readOnlyTransaction) {
+	dbi := t.NewIterator(util.BytesPrefix(v.db.keyer.GenerateDeviceFileKey(nil, v.folder, nil, nil).WithoutNameAndDevice()), nil)
+	defer dbi.Release()
+
+	seqs := make(map[int64][]byte)
+	var gk, keyBuf []byte
+	for dbi.Next() {
+		name := v.db.keyer.NameFromDeviceFileKey(dbi.Key())
+		device, ok := v.db.keyer.DeviceFromDeviceFileKey(dbi.Key())
+		if !ok {
+			v.problem(name, "file entry for unknown device")
+			continue
+		}
+		devID := protocol.DeviceIDFromBytes(device)
+
+		var f protocol.FileInfo
+		if err := f.Unmarshal(dbi.Value()); err != nil {
+			v.problem(name, "undecodable file entry for device %v: %v", devID, err)
+			continue
+		}
+		if f.Name != string(name) {
+			v.problem(name, "file entry for device %v has mismatching name %q", devID, f.Name)
+			continue
+		}
+		if err := checkVector(f.Version); err != nil {
+			v.problem(name, "invalid version vector %v for device %v: %v", f.Version, devID, err)
+		}
+
+		v.meta.addFile(devID, f)
+
+		gk = v.db.keyer.GenerateGlobalVersionKey(gk, v.folder, name)
+		if bs, err := t.Get(gk, nil); err != nil {
+			v.problem(name, "missing global version list entry")
+		} else if vl, ok := unmarshalVersionList(bs); !ok {
+			v.problem(name, "undecodable global version list")
+		} else if fv, ok := vl.Get(device); !ok {
+			v.problem(name, "device %v missing from global version list", devID)
+		} else if !fv.Version.Equal(f.Version) || fv.Invalid != f.IsInvalid() {
+			v.problem(name, "global version list has %v for device %v, file has %v", fv.Version, devID, f.Version)
+		}
+
+		if devID != protocol.LocalDeviceID {
+			continue
+		}
+
+		if f.Sequence <= 0 {
+			v.problem(name, "local file has invalid sequence number %d", f.Sequence)
+		} else if other, ok := seqs[f.Sequence]; ok {
+			v.problem(name, "local file has same sequence number %d as %q", f.Sequence, other)
+		} else {
+			seqs[f.Sequence] = append([]byte{}, name...)
+			keyBuf = v.db.keyer.GenerateSequenceKey(keyBuf, v.folder, f.Sequence)
+			if dk, err := t.Get(keyBuf, nil); err != nil || !bytes.Equal(dk, dbi.Key()) {
+				v.problem(name, "sequence index entry %d missing or pointing elsewhere", f.Sequence)
+			}
+		}
+
+		if !f.IsDirectory() && !f.IsDeleted() && !f.IsInvalid() {
+			for i, block := range f.Blocks {
+				keyBuf = v.db.keyer.GenerateBlockMapKey(keyBuf, v.folder, block.Hash, name)
+				if bs, err := t.Get(keyBuf, nil); err != nil || len(bs) != 4 && len(bs) != blockMapValueLength {
+					v.problem(name, "missing block map entry for block %d", i)
+					break
+				}
+			}
+		}
+	}
+}
+
+// checkSequences verifies that all entries in the sequence index point to
+// existing local files with the same sequence number.
+func (v *verifier) checkSequences(t readOnlyTransaction) {
+	dbi := t.NewIterator(util.BytesPrefix(v.db.keyer.GenerateSequenceKey(nil, v.folder, 0).WithoutSequence()), nil)
+	defer dbi.Release()
+
+	for dbi.Next() {
+		seq := v.db.keyer.SequenceFromSequenceKey(dbi.Key())
+		f, ok := t.getFileTrunc(dbi.Value(), true)
+		if !ok {
+			v.problem(nil, "sequence index entry %d points to nonexistent file", seq)
+			continue
+		}
+		if f.SequenceNo() != seq {
+			v.problem([]byte(f.FileName()), "sequence index entry %d points to file with sequence number %d", seq, f.SequenceNo())
+		}
+	}
+}
+
+// checkGlobals verifies the global version lists, and accounts the global
+// files in the recalculated size data.
+func (v *verifier) checkGlobals(t readOnlyTransaction) {
+	dbi := t.NewIterator(util.BytesPrefix(v.db.keyer.GenerateGlobalVersionKey(nil, v.folder, nil).WithoutName()), nil)
+	defer dbi.Release()
+
+	var dk, nk []byte
+	for dbi.Next() {
+		name := v.db.keyer.NameFromGlobalVersionKey(dbi.Key())
+		vl, ok := unmarshalVersionList(dbi.Value())
+		if !ok {
+			v.problem(name, "empty or undecodable global version list")
+			continue
+		}
+
+		var files []protocol.FileInfo
+		for _, fv := range vl.Versions {
+			dk = v.db.keyer.GenerateDeviceFileKey(dk, v.folder, fv.Device, name)
+			f, ok := t.getFileByKey(dk)
+			if !ok {
+				v.problem(name, "global version list refers to missing file for device %v", protocol.DeviceIDFromBytes(fv.Device))
+				files = nil
+				break
+			}
+			files = append(files, f)
+		}
+		if files == nil {
+			continue
+		}
+
+		for i := 1; i < len(vl.Versions); i++ {
+			prev, cur := vl.Versions[i-1], vl.Versions[i]
+			if prev.Invalid && !cur.Invalid {
+				v.problem(name, "global version list has valid version after invalid one")
+				break
+			}
+			if prev.Invalid == cur.Invalid && prev.Version.Compare(cur.Version) == protocol.Lesser {
+				v.problem(name, "global version list is misordered")
+				break
+			}
+		}
+
+		global := files[0]
+		v.meta.addFile(protocol.GlobalDeviceID, global)
+
+		localFV, haveLocal := vl.Get(protocol.LocalDeviceID[:])
+		nk = v.db.keyer.GenerateNeedFileKey(nk, v.folder, name)
+		hasNeed, _ := t.Has(nk, nil)
+		if needed := need(global, haveLocal, localFV.Version); needed != hasNeed {
+			v.problem(name, "need index says needed=%v, should be %v", hasNeed, needed)
+		}
+	}
+}
+
+// checkNeeds verifies that all entries in the need index have a global
+// version list.
+func (v *verifier) checkNeeds(t readOnlyTransaction) {
+	dbi := t.NewIterator(util.BytesPrefix(v.db.keyer.GenerateNeedFileKey(nil, v.folder, nil).WithoutName()), nil)
+	defer dbi.Release()
+
+	var gk []byte
+	for dbi.Next() {
+		name := v.db.keyer.NameFromGlobalVersionKey(dbi.Key())
+		gk = v.db.keyer.GenerateGlobalVersionKey(gk, v.folder, name)
+		if ok, _ := t.Has(gk, nil); !ok {
+			v.problem(name, "need index entry without global version list")
+		}
+	}
+}
+
+// checkMeta compares the stored size accounting with the recalculated one.
+func (v *verifier) checkMeta() {
+	stored := newMetadataTracker()
+	if err := stored.fromDB(v.db, v.folder); err != nil {
+		v.problem(nil, "no stored metadata: %v", err)
+		return
+	}
+
+	type countsKey struct {
+		dev   protocol.DeviceID
+		flags uint32
+	}
+	keys := make(map[countsKey]struct{})
+	for _, m := range []*metadataTracker{stored, v.meta} {
+		for _, c := range m.counts.Counts {
+			keys[countsKey{protocol.DeviceIDFromBytes(c.DeviceID), c.LocalFlags}] = struct{}{}
+		}
+	}
+
+	for k := range keys {
+		exp := v.meta.Counts(k.dev, k.flags)
+		act := stored.Counts(k.dev, k.flags)
+		if exp.Files != act.Files || exp.Directories != act.Directories || exp.Symlinks != act.Symlinks || exp.Deleted != act.Deleted || exp.Bytes != act.Bytes {
+			v.problem(nil, "size accounting for %v (flags %x) is %v, should be %v", k.dev, k.flags, countsString(act), countsString(exp))
+		}
+	}
+	if exp, act := v.meta.Sequence(protocol.LocalDeviceID), stored.Sequence(protocol.LocalDeviceID); act < exp {
+		v.problem(nil, "stored local sequence %d is lower than highest used sequence %d", act, exp)
+	}
+}
+
+func countsString(c Counts) string {
+	return fmt.Sprintf("%d files, %d directories, %d symlinks, %d deleted, %d bytes", c.Files, c.Directories, c.Symlinks, c.Deleted, c.Bytes)
+}
+
+// checkVector returns an error if the version vector is not in the
+// canonical form, i.e. nonzero counters sorted by ID without duplicates.
+func checkVector(v protocol.Vector) error {
+	for i, c := range v.Counters {
+		if c.Value == 0 {
+			return fmt.Errorf("zero counter for %v", c.ID)
+		}
+		if i > 0 && v.Counters[i-1].ID >= c.ID {
+			return fmt.Errorf("counters not sorted or duplicated")
+		}
+	}
+	return nil
+}
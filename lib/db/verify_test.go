@@ -0,0 +1,73 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestVerifyRepair(t *testing.T) {
+	ll := OpenMemory()
+	s := NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+
+	remote := protocol.DeviceID{42}
+	blocks := []protocol.BlockInfo{{Size: 1, Hash: make([]byte, 32)}}
+	local := []protocol.FileInfo{
+		{Name: "a", Version: protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}}}, Blocks: blocks, Size: 1},
+		{Name: "b", Version: protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}}}, Blocks: blocks, Size: 1},
+		{Name: "d", Type: protocol.FileInfoTypeDirectory, Version: protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}}}},
+	}
+	s.Update(protocol.LocalDeviceID, local)
+	s.Update(remote, []protocol.FileInfo{
+		{Name: "b", Version: protocol.Vector{Counters: []protocol.Counter{{ID: 1, Value: 1}, {ID: 2, Value: 1}}}, Blocks: blocks, Size: 1},
+		{Name: "c", Version: protocol.Vector{Counters: []protocol.Counter{{ID: 2, Value: 1}}}, Blocks: blocks, Size: 1},
+	})
+	s.meta.toDB(s.db, []byte("test"))
+
+	if problems := Verify(ll, "test"); len(problems) != 0 {
+		t.Fatal("Unexpected problems in consistent database:", problems)
+	}
+
+	// Break things in a few different ways
+	db := newInstance(ll)
+	folder := []byte("test")
+	ll.Delete(db.keyer.GenerateSequenceKey(nil, folder, 1), nil)
+	ll.Delete(db.keyer.GenerateGlobalVersionKey(nil, folder, []byte("c")), nil)
+	ll.Delete(db.keyer.GenerateNeedFileKey(nil, folder, []byte("b")), nil)
+	ll.Delete(db.keyer.GenerateBlockMapKey(nil, folder, blocks[0].Hash, []byte("a")), nil)
+
+	problems := Verify(ll, "test")
+	if len(problems) < 4 {
+		t.Fatal("Expected at least four problems, got", problems)
+	}
+
+	Repair(ll, "test")
+
+	if problems := Verify(ll, "test"); len(problems) != 0 {
+		t.Fatal("Unexpected problems after repair:", problems)
+	}
+
+	// The repaired database should be usable as is.
+	s = NewFileSet("test", fs.NewFilesystem(fs.FilesystemTypeBasic, "."), ll)
+	if c := s.GlobalSize(); c.Files != 3 || c.Directories != 1 {
+		t.Error("Unexpected global size after repair:", c)
+	}
+	var need []string
+	s.WithNeed(protocol.LocalDeviceID, func(f FileIntf) bool {
+		need = append(need, f.FileName())
+		return true
+	})
+	if len(need) != 2 || need[0] != "b" || need[1] != "c" {
+		t.Error("Unexpected need after repair:", need)
+	}
+	if seq := s.Sequence(protocol.LocalDeviceID); seq != 3 {
+		t.Error("Unexpected local sequence after repair:", seq)
+	}
+}
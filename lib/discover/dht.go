@@ -0,0 +1,788 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package discover
+
+import (
+	"bytes"
+	"crypto"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The DHT is a Kademlia style distributed hash table between the devices
+// taking part in it, without any server. The ID of each node is its device
+// ID and its record, the addresses it announces, is stored at the nodes
+// whose IDs are closest to it in XOR distance. Records are signed with the
+// certificate of the device, so nodes can't make up addresses for others.
+// Likewise a node only enters the routing table once it has answered a ping
+// with a random nonce from us by signing it with its certificate, so nodes
+// can't claim IDs, or addresses, they don't have. Messages are JSON over
+// UDP.
+const (
+	dhtMagic           = uint32(0x7E1FD4A3)
+	dhtBucketSize      = 8 // nodes per bucket, and stores per record (k)
+	dhtParallelism     = 3 // requests in flight in a lookup (alpha)
+	dhtRequestTimeout  = 2 * time.Second
+	dhtRefreshInterval = 15 * time.Minute
+	dhtRetryInterval   = time.Minute // when no nodes are reachable
+	dhtRecordLifetime  = time.Hour
+	dhtStaleAfter      = 2 * dhtRefreshInterval
+	dhtMaxRecords      = 10000
+	dhtMaxPacketSize   = 65536
+	dhtNonceSize       = 32
+	dhtMaxVerifying    = 16 // unknown nodes being pinged at once
+)
+
+type dhtMessageType string
+
+const (
+	dhtPing      dhtMessageType = "ping"
+	dhtFindNode  dhtMessageType = "findNode"
+	dhtFindValue dhtMessageType = "findValue"
+	dhtStore     dhtMessageType = "store"
+	dhtResponse  dhtMessageType = "response"
+)
+
+type dhtMessage struct {
+	Type   dhtMessageType    `json:"type"`
+	Txn    int64             `json:"txn"`
+	From   protocol.DeviceID `json:"from"`
+	Target protocol.DeviceID `json:"target"`
+	Nodes  []dhtContact      `json:"nodes,omitempty"`
+	Record *dhtRecord        `json:"record,omitempty"`
+	// A ping has a nonce, which the response proves the sender's identity
+	// by, with its certificate and its signature of the nonce.
+	Nonce     []byte `json:"nonce,omitempty"`
+	Cert      []byte `json:"cert,omitempty"` // DER
+	Signature []byte `json:"signature,omitempty"`
+}
+
+type dhtContact struct {
+	ID   protocol.DeviceID `json:"id"`
+	Addr string            `json:"addr"` // host:port, UDP
+	seen time.Time
+}
+
+// A dhtRecord is the addresses of a device, signed by it.
+type dhtRecord struct {
+	Addresses []string `json:"addresses"`
+	Expires   int64    `json:"expires"` // Unix nanos
+	Cert      []byte   `json:"cert"`    // DER, the device ID is its hash
+	Signature []byte   `json:"signature"`
+}
+
+// A dhtPending request waits for the response from the address it was sent
+// to.
+type dhtPending struct {
+	addr string
+	resp chan dhtMessage
+}
+
+type dhtClient struct {
+	id        protocol.DeviceID
+	cert      tls.Certificate
+	addr      string
+	bootstrap []string
+	addrList  AddressLister
+	stop      chan struct{}
+
+	mut       sync.Mutex
+	conn      net.PacketConn
+	buckets   [8 * len(protocol.DeviceID{})][]dhtContact // by the length of the prefix shared with us
+	records   map[protocol.DeviceID]dhtRecord
+	pending   map[int64]dhtPending
+	verifying map[string]bool // by address
+
+	errorHolder
+}
+
+// NewDHT returns a Finder taking part in the DHT on the UDP address, joining
+// it through the bootstrap nodes, host:port each. It announces the external
+// addresses of the lister, if given.
+func NewDHT(cert tls.Certificate, addr string, bootstrap []string, addrList AddressLister) (FinderService, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("no certificate")
+	}
+	if _, ok := cert.PrivateKey.(crypto.Signer); !ok {
+		return nil, errors.New("certificate key can't sign")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, err
+	}
+	for _, node := range bootstrap {
+		if _, _, err := net.SplitHostPort(node); err != nil {
+			return nil, fmt.Errorf("bootstrap node: %v", err)
+		}
+	}
+	return &dhtClient{
+		id:        protocol.NewDeviceID(cert.Certificate[0]),
+		cert:      cert,
+		addr:      addr,
+		bootstrap: bootstrap,
+		addrList:  addrList,
+		stop:      make(chan struct{}),
+		mut:       sync.NewMutex(),
+		records:   make(map[protocol.DeviceID]dhtRecord),
+		pending:   make(map[int64]dhtPending),
+		verifying: make(map[string]bool),
+	}, nil
+}
+
+// Lookup returns the addresses in the record of the device, if the DHT has
+// one.
+func (c *dhtClient) Lookup(device protocol.DeviceID) (addresses []string, err error) {
+	c.mut.Lock()
+	running := c.conn != nil
+	rec, ok := c.records[device]
+	c.mut.Unlock()
+	if !running {
+		return nil, errors.New("DHT not running")
+	}
+	if ok && rec.verify(device, time.Now()) == nil {
+		return append([]string(nil), rec.Addresses...), nil
+	}
+	if len(c.closest(c.id, 1)) == 0 {
+		c.join()
+	}
+
+	found, _ := c.lookup(device, dhtFindValue)
+	if found == nil {
+		return nil, nil
+	}
+	return append([]string(nil), found.Addresses...), nil
+}
+
+func (c *dhtClient) String() string {
+	return "dht@" + c.addr
+}
+
+func (c *dhtClient) Cache() map[protocol.DeviceID]CacheEntry {
+	// The dhtClient doesn't do caching
+	return nil
+}
+
+func (c *dhtClient) Serve() {
+	conn, err := net.ListenPacket("udp", c.addr)
+	if err != nil {
+		l.Debugln("dht listen:", err)
+		c.setError(err)
+		// Let the supervisor try again later.
+		select {
+		case <-c.stop:
+		case <-time.After(time.Minute):
+		}
+		return
+	}
+	c.mut.Lock()
+	c.conn = conn
+	c.mut.Unlock()
+	defer func() {
+		c.mut.Lock()
+		c.conn = nil
+		c.mut.Unlock()
+		conn.Close()
+	}()
+
+	go c.readAndServe(conn)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	eventSub := events.Default.Subscribe(events.ListenAddressesChanged)
+	defer events.Default.Unsubscribe(eventSub)
+
+	for {
+		select {
+		case <-eventSub.C():
+			// Defer the refresh by 2 seconds, essentially debouncing
+			// if we have a stream of events incoming in quick succession.
+			timer.Reset(2 * time.Second)
+
+		case <-timer.C:
+			if c.refresh() {
+				timer.Reset(dhtRefreshInterval)
+			} else {
+				timer.Reset(dhtRetryInterval)
+			}
+
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *dhtClient) Stop() {
+	close(c.stop)
+}
+
+// refresh joins the DHT through the bootstrap nodes, if needed, looks up
+// the nodes closest to us and stores our record at them. It returns false
+// if no nodes could be reached.
+func (c *dhtClient) refresh() bool {
+	c.expire()
+
+	var rec *dhtRecord
+	if c.addrList != nil {
+		// There are legitimate cases for not having anything to announce,
+		// yet still using the DHT for lookups.
+		if addrs := c.addrList.ExternalAddresses(); len(addrs) > 0 {
+			signed, err := signRecord(c.cert, addrs, time.Now().Add(dhtRecordLifetime))
+			if err != nil {
+				c.setError(err)
+				return true
+			}
+			rec = &signed
+			// We answer for ourselves too.
+			c.mut.Lock()
+			c.records[c.id] = signed
+			c.mut.Unlock()
+		}
+	}
+
+	if len(c.closest(c.id, 1)) == 0 {
+		c.join()
+	}
+
+	_, closest := c.lookup(c.id, dhtFindNode)
+	if len(closest) == 0 {
+		c.setError(errors.New("no DHT nodes reachable"))
+		return false
+	}
+	c.setError(nil)
+
+	if rec == nil {
+		return true
+	}
+	for _, node := range closest {
+		go func(node dhtContact) {
+			if _, err := c.request(node.Addr, dhtMessage{Type: dhtStore, Target: c.id, Record: rec}); err != nil {
+				l.Debugln("dht store at", node.Addr, err)
+			}
+		}(node)
+	}
+	return true
+}
+
+// join pings the bootstrap nodes, their responses adding them to the
+// routing table.
+func (c *dhtClient) join() {
+	for _, addr := range c.bootstrap {
+		if _, err := c.verify(addr); err != nil {
+			l.Debugln("dht bootstrap", addr, err)
+		}
+	}
+}
+
+// verify pings the node at the address with a random nonce and, if it
+// proves its identity by signing it, adds it to the routing table.
+func (c *dhtClient) verify(addr string) (dhtContact, error) {
+	nonce := make([]byte, dhtNonceSize)
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return dhtContact{}, err
+	}
+	resp, err := c.request(addr, dhtMessage{Type: dhtPing, Nonce: nonce})
+	if err != nil {
+		return dhtContact{}, err
+	}
+	if !protocol.NewDeviceID(resp.Cert).Equals(resp.From) {
+		return dhtContact{}, errors.New("certificate is not the node's")
+	}
+	if err := checkSignature(resp.Cert, pingProof(nonce), resp.Signature); err != nil {
+		return dhtContact{}, err
+	}
+	node := dhtContact{ID: resp.From, Addr: addr}
+	c.addContact(node)
+	return node, nil
+}
+
+// verifyUnknown verifies the node which sent us a request in the
+// background, if we don't know it already and have room for it.
+func (c *dhtClient) verifyUnknown(id protocol.DeviceID, addr string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.verifying[addr] || len(c.verifying) >= dhtMaxVerifying || !c.hasRoomLocked(id) {
+		return
+	}
+	c.verifying[addr] = true
+	go func() {
+		if node, err := c.verify(addr); err != nil {
+			l.Debugln("dht verify", id, "at", addr, err)
+		} else if !node.ID.Equals(id) {
+			l.Debugln("dht verify", id, "at", addr, "answered by", node.ID)
+		}
+		c.mut.Lock()
+		delete(c.verifying, addr)
+		c.mut.Unlock()
+	}()
+}
+
+// lookup asks the nodes ever closer to the target for the closest nodes
+// they know, or its record. It returns the first valid record found, or the
+// closest nodes that responded.
+func (c *dhtClient) lookup(target protocol.DeviceID, typ dhtMessageType) (*dhtRecord, []dhtContact) {
+	type result struct {
+		node dhtContact
+		resp dhtMessage
+		err  error
+	}
+
+	shortlist := c.closest(target, dhtBucketSize)
+	queried := map[protocol.DeviceID]bool{c.id: true}
+	responded := make(map[protocol.DeviceID]bool)
+
+	for {
+		var batch []dhtContact
+		for _, node := range shortlist {
+			if len(batch) == dhtParallelism {
+				break
+			}
+			if !queried[node.ID] {
+				queried[node.ID] = true
+				batch = append(batch, node)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		results := make(chan result, len(batch))
+		for _, node := range batch {
+			go func(node dhtContact) {
+				// Nodes we only heard of from others must prove to be
+				// there before we ask them anything.
+				if !c.knows(node) {
+					if verified, err := c.verify(node.Addr); err != nil {
+						results <- result{node, dhtMessage{}, err}
+						return
+					} else if !verified.ID.Equals(node.ID) {
+						results <- result{node, dhtMessage{}, errors.New("unexpected node at address")}
+						return
+					}
+				}
+				resp, err := c.request(node.Addr, dhtMessage{Type: typ, Target: target})
+				if err == nil && !resp.From.Equals(node.ID) {
+					err = errors.New("unexpected node at address")
+				}
+				if err == nil {
+					c.addContact(node)
+				}
+				results <- result{node, resp, err}
+			}(node)
+		}
+
+		failed := make(map[protocol.DeviceID]bool)
+		for range batch {
+			res := <-results
+			if res.err != nil {
+				failed[res.node.ID] = true
+				continue
+			}
+			responded[res.node.ID] = true
+			if rec := res.resp.Record; typ == dhtFindValue && rec != nil && rec.verify(target, time.Now()) == nil {
+				return rec, nil
+			}
+			for _, node := range res.resp.Nodes {
+				if !node.ID.Equals(c.id) && !containsContact(shortlist, node.ID) {
+					shortlist = append(shortlist, node)
+				}
+			}
+		}
+
+		kept := shortlist[:0]
+		for _, node := range shortlist {
+			if !failed[node.ID] {
+				kept = append(kept, node)
+			}
+		}
+		shortlist = kept
+		sortByDistance(shortlist, target)
+		if len(shortlist) > dhtBucketSize {
+			shortlist = shortlist[:dhtBucketSize]
+		}
+	}
+
+	var closest []dhtContact
+	for _, node := range shortlist {
+		if responded[node.ID] {
+			closest = append(closest, node)
+		}
+	}
+	return nil, closest
+}
+
+// request sends the message and waits for the response.
+func (c *dhtClient) request(addr string, msg dhtMessage) (dhtMessage, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return dhtMessage{}, err
+	}
+
+	msg.Txn = rand.Int63()
+	resp := make(chan dhtMessage, 1)
+	c.mut.Lock()
+	conn := c.conn
+	c.pending[msg.Txn] = dhtPending{addr: udpAddr.String(), resp: resp}
+	c.mut.Unlock()
+	defer func() {
+		c.mut.Lock()
+		delete(c.pending, msg.Txn)
+		c.mut.Unlock()
+	}()
+	if conn == nil {
+		return dhtMessage{}, errors.New("DHT not running")
+	}
+
+	if err := c.send(conn, udpAddr, msg); err != nil {
+		return dhtMessage{}, err
+	}
+
+	select {
+	case r := <-resp:
+		return r, nil
+	case <-time.After(dhtRequestTimeout):
+		return dhtMessage{}, errors.New("timeout")
+	case <-c.stop:
+		return dhtMessage{}, errors.New("stopped")
+	}
+}
+
+func (c *dhtClient) send(conn net.PacketConn, addr net.Addr, msg dhtMessage) error {
+	msg.From = c.id
+	bs, err := json.Marshal(&msg)
+	if err != nil {
+		return err
+	}
+	pkt := make([]byte, 4, 4+len(bs))
+	binary.BigEndian.PutUint32(pkt, dhtMagic)
+	pkt = append(pkt, bs...)
+	_, err = conn.WriteTo(pkt, addr)
+	return err
+}
+
+// readAndServe handles the packets on the connection until it's closed.
+// Requests are answered, responses handed to the request waiting for them.
+func (c *dhtClient) readAndServe(conn net.PacketConn) {
+	buf := make([]byte, dhtMaxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			l.Debugln("dht read:", err)
+			return
+		}
+		if n < 4 || binary.BigEndian.Uint32(buf) != dhtMagic {
+			continue
+		}
+		var msg dhtMessage
+		if err := json.Unmarshal(buf[4:n], &msg); err != nil {
+			l.Debugln("dht unmarshal from", addr, err)
+			continue
+		}
+		if msg.From.Equals(c.id) || msg.From == protocol.EmptyDeviceID {
+			continue
+		}
+
+		if msg.Type == dhtResponse {
+			c.mut.Lock()
+			pending, ok := c.pending[msg.Txn]
+			c.mut.Unlock()
+			if ok && pending.addr == addr.String() {
+				select {
+				case pending.resp <- msg:
+				default:
+				}
+			}
+			continue
+		}
+
+		// Someone asking us may be a node to know, once it has proven so.
+		c.verifyUnknown(msg.From, addr.String())
+
+		if resp, ok := c.handle(msg); ok {
+			if err := c.send(conn, addr, resp); err != nil {
+				l.Debugln("dht respond to", addr, err)
+			}
+		}
+	}
+}
+
+// handle returns the response to the request, and false if there is none.
+func (c *dhtClient) handle(msg dhtMessage) (dhtMessage, bool) {
+	resp := dhtMessage{Type: dhtResponse, Txn: msg.Txn, Target: msg.Target}
+	switch msg.Type {
+	case dhtPing:
+		if len(msg.Nonce) == 0 || len(msg.Nonce) > dhtNonceSize {
+			return resp, false
+		}
+		sig, err := sign(c.cert, pingProof(msg.Nonce))
+		if err != nil {
+			l.Debugln("dht ping proof:", err)
+			return resp, false
+		}
+		resp.Cert = c.cert.Certificate[0]
+		resp.Signature = sig
+
+	case dhtFindNode:
+		resp.Nodes = c.closest(msg.Target, dhtBucketSize)
+
+	case dhtFindValue:
+		c.mut.Lock()
+		rec, ok := c.records[msg.Target]
+		c.mut.Unlock()
+		if ok && rec.Expires > time.Now().UnixNano() {
+			resp.Record = &rec
+		} else {
+			resp.Nodes = c.closest(msg.Target, dhtBucketSize)
+		}
+
+	case dhtStore:
+		if msg.Record == nil {
+			return resp, false
+		}
+		if err := msg.Record.verify(msg.Target, time.Now()); err != nil {
+			l.Debugln("dht store of", msg.Target, err)
+			return resp, false
+		}
+		c.mut.Lock()
+		old, ok := c.records[msg.Target]
+		switch {
+		case ok && old.Expires >= msg.Record.Expires:
+			// We have the same or a newer one.
+		case !ok && len(c.records) >= dhtMaxRecords:
+			l.Debugln("dht store of", msg.Target, "dropped, too many records")
+		default:
+			c.records[msg.Target] = *msg.Record
+		}
+		c.mut.Unlock()
+
+	default:
+		l.Debugln("dht unknown message type", msg.Type)
+		return resp, false
+	}
+	return resp, true
+}
+
+// knows returns whether the node is in the routing table at the address.
+func (c *dhtClient) knows(node dhtContact) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for _, cur := range c.buckets[commonPrefixLen(c.id, node.ID)] {
+		if cur.ID.Equals(node.ID) && cur.Addr == node.Addr {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRoomLocked returns whether the routing table would take in the node
+// if it isn't there already.
+func (c *dhtClient) hasRoomLocked(id protocol.DeviceID) bool {
+	bucket := c.buckets[commonPrefixLen(c.id, id)]
+	for _, cur := range bucket {
+		if cur.ID.Equals(id) {
+			return false
+		}
+	}
+	return len(bucket) < dhtBucketSize || time.Since(bucket[0].seen) > dhtStaleAfter
+}
+
+// addContact adds the verified node to its bucket in the routing table, or
+// marks it as seen. A full bucket only takes it in place of a stale node,
+// as nodes that have been around for a while are likely to stay.
+func (c *dhtClient) addContact(node dhtContact) {
+	node.seen = time.Now()
+	idx := commonPrefixLen(c.id, node.ID)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	bucket := c.buckets[idx]
+	for i, cur := range bucket {
+		if cur.ID.Equals(node.ID) {
+			// Move it to the end, which is the most recently seen.
+			copy(bucket[i:], bucket[i+1:])
+			bucket[len(bucket)-1] = node
+			return
+		}
+	}
+	if len(bucket) < dhtBucketSize {
+		c.buckets[idx] = append(bucket, node)
+		return
+	}
+	if time.Since(bucket[0].seen) > dhtStaleAfter {
+		copy(bucket, bucket[1:])
+		bucket[len(bucket)-1] = node
+	}
+}
+
+// closest returns up to n nodes of the routing table, those closest to the
+// target first.
+func (c *dhtClient) closest(target protocol.DeviceID, n int) []dhtContact {
+	c.mut.Lock()
+	var nodes []dhtContact
+	for _, bucket := range c.buckets {
+		nodes = append(nodes, bucket...)
+	}
+	c.mut.Unlock()
+
+	sortByDistance(nodes, target)
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
+
+// expire forgets the records past their expiry and the nodes not seen for
+// long.
+func (c *dhtClient) expire() {
+	now := time.Now()
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for id, rec := range c.records {
+		if rec.Expires < now.UnixNano() {
+			delete(c.records, id)
+		}
+	}
+	for i, bucket := range c.buckets {
+		kept := bucket[:0]
+		for _, node := range bucket {
+			if now.Sub(node.seen) < dhtStaleAfter {
+				kept = append(kept, node)
+			}
+		}
+		c.buckets[i] = kept
+	}
+}
+
+// signRecord returns the record of the addresses, signed with the key of
+// the certificate.
+func signRecord(cert tls.Certificate, addrs []string, expires time.Time) (dhtRecord, error) {
+	rec := dhtRecord{
+		Addresses: addrs,
+		Expires:   expires.UnixNano(),
+		Cert:      cert.Certificate[0],
+	}
+	sig, err := sign(cert, rec.signed())
+	if err != nil {
+		return dhtRecord{}, err
+	}
+	rec.Signature = sig
+	return rec, nil
+}
+
+// verify returns an error unless the record is the device's, signed by it,
+// and hasn't expired.
+func (r *dhtRecord) verify(device protocol.DeviceID, now time.Time) error {
+	if r.Expires < now.UnixNano() {
+		return errors.New("record expired")
+	}
+	if !protocol.NewDeviceID(r.Cert).Equals(device) {
+		return errors.New("record certificate is not the device's")
+	}
+	return checkSignature(r.Cert, r.signed(), r.Signature)
+}
+
+// signed returns the part of the record that is signed.
+func (r *dhtRecord) signed() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("syncthing dht record\n")
+	buf.WriteString(strconv.FormatInt(r.Expires, 10))
+	buf.WriteString("\n")
+	buf.WriteString(strings.Join(r.Addresses, "\n"))
+	return buf.Bytes()
+}
+
+// pingProof returns what the response to a ping with the nonce signs.
+func pingProof(nonce []byte) []byte {
+	return append([]byte("syncthing dht ping\n"), nonce...)
+}
+
+// sign returns the signature of the data with the key of the certificate.
+func sign(cert tls.Certificate, data []byte) ([]byte, error) {
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("certificate key can't sign")
+	}
+	digest := sha256.Sum256(data)
+	return signer.Sign(cryptorand.Reader, digest[:], crypto.SHA256)
+}
+
+// checkSignature returns an error unless the data is signed by the key of
+// the certificate, DER encoded.
+func checkSignature(der, data, sig []byte) error {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	var algo x509.SignatureAlgorithm
+	switch cert.PublicKeyAlgorithm {
+	case x509.ECDSA:
+		algo = x509.ECDSAWithSHA256
+	case x509.RSA:
+		algo = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("unsupported key algorithm %v", cert.PublicKeyAlgorithm)
+	}
+	return cert.CheckSignature(algo, data, sig)
+}
+
+func sortByDistance(nodes []dhtContact, target protocol.DeviceID) {
+	sort.Slice(nodes, func(a, b int) bool {
+		return closerTo(target, nodes[a].ID, nodes[b].ID)
+	})
+}
+
+// closerTo returns whether a is closer to the target than b, in XOR
+// distance.
+func closerTo(target, a, b protocol.DeviceID) bool {
+	for i := range target {
+		da, db := a[i]^target[i], b[i]^target[i]
+		if da != db {
+			return da < db
+		}
+	}
+	return false
+}
+
+// commonPrefixLen returns the number of leading bits a and b share, up to
+// one less than the number of bits in them so as to index the buckets.
+func commonPrefixLen(a, b protocol.DeviceID) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := i * 8
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+			return n
+		}
+	}
+	return 8*len(a) - 1
+}
+
+func containsContact(nodes []dhtContact, id protocol.DeviceID) bool {
+	for _, node := range nodes {
+		if node.ID.Equals(id) {
+			return true
+		}
+	}
+	return false
+}
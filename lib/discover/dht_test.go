@@ -0,0 +1,226 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package discover
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestDHTLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newNode := func(name string, bootstrap []string, addrList AddressLister) (FinderService, string, protocol.DeviceID) {
+		cert, err := tlsutil.NewCertificate(fmt.Sprintf("%s/%s-cert.pem", dir, name), fmt.Sprintf("%s/%s-key.pem", dir, name), "syncthing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := freeUDPAddr(t)
+		node, err := NewDHT(cert, addr, bootstrap, addrList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go node.Serve()
+		waitDHTRunning(t, node.(*dhtClient))
+		return node, addr, protocol.NewDeviceID(cert.Certificate[0])
+	}
+
+	// B is known to the others, A announces itself and C looks it up, only
+	// having met B.
+	b, bAddr, _ := newNode("b", nil, nil)
+	defer b.Stop()
+	a, _, aID := newNode("a", []string{bAddr}, new(fakeAddressLister))
+	defer a.Stop()
+	c, _, _ := newNode("c", []string{bAddr}, nil)
+	defer c.Stop()
+
+	t0 := time.Now()
+	for {
+		addrs, err := c.Lookup(aID)
+		if err == nil && len(addrs) > 0 {
+			if expected := []string{"tcp://0.0.0.0:22000"}; !reflect.DeepEqual(addrs, expected) {
+				t.Errorf("Got %v, expected %v", addrs, expected)
+			}
+			break
+		}
+		if time.Since(t0) > 10*time.Second {
+			t.Fatal("Device not found:", addrs, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestDHTUnverifiedContact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, err := tlsutil.NewCertificate(dir+"/cert.pem", dir+"/key.pem", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := freeUDPAddr(t)
+	node, err := NewDHT(cert, addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := node.(*dhtClient)
+	go c.Serve()
+	defer c.Stop()
+	waitDHTRunning(t, c)
+
+	// A node claiming an ID it can't prove, answering our ping without
+	// signing the nonce.
+	var claimed protocol.DeviceID
+	claimed[0] = 0x42
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dst, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &dhtClient{id: claimed}
+	if err := fake.send(conn, dst, dhtMessage{Type: dhtFindNode, Txn: 1, Target: claimed}); err != nil {
+		t.Fatal(err)
+	}
+
+	pinged := false
+	buf := make([]byte, dhtMaxPacketSize)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for !pinged {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal("Not pinged:", err)
+		}
+		var msg dhtMessage
+		if err := json.Unmarshal(buf[4:n], &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type == dhtPing {
+			pinged = true
+			if len(msg.Nonce) == 0 {
+				t.Error("Ping without nonce")
+			}
+			if err := fake.send(conn, dst, dhtMessage{Type: dhtResponse, Txn: msg.Txn}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if nodes := c.closest(claimed, dhtBucketSize); len(nodes) != 0 {
+		t.Errorf("Unverified node added: %v", nodes)
+	}
+}
+
+func TestDHTRecordVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cert, err := tlsutil.NewCertificate(dir+"/cert.pem", dir+"/key.pem", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := protocol.NewDeviceID(cert.Certificate[0])
+	other, err := tlsutil.NewCertificate(dir+"/other-cert.pem", dir+"/other-key.pem", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	rec, err := signRecord(cert, []string{"tcp://192.0.2.1:22000"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.verify(id, now); err != nil {
+		t.Error("Valid record:", err)
+	}
+
+	if err := rec.verify(protocol.NewDeviceID(other.Certificate[0]), now); err == nil {
+		t.Error("Record passed for another device")
+	}
+	if err := rec.verify(id, now.Add(2*time.Hour)); err == nil {
+		t.Error("Expired record passed")
+	}
+
+	tampered := rec
+	tampered.Addresses = []string{"tcp://192.0.2.66:22000"}
+	if err := tampered.verify(id, now); err == nil {
+		t.Error("Tampered record passed")
+	}
+
+	// Signed by another key, with our certificate.
+	forged, err := signRecord(tls.Certificate{Certificate: cert.Certificate, PrivateKey: other.PrivateKey}, rec.Addresses, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := forged.verify(id, now); err == nil {
+		t.Error("Forged record passed")
+	}
+}
+
+func TestDHTDistance(t *testing.T) {
+	var target, a, b protocol.DeviceID
+	a[0] = 0x01
+	b[0] = 0x80
+	if !closerTo(target, a, b) || closerTo(target, b, a) {
+		t.Error("Wrong XOR distance order")
+	}
+	if n := commonPrefixLen(target, a); n != 7 {
+		t.Errorf("Common prefix of %d bits, expected 7", n)
+	}
+	if n := commonPrefixLen(target, target); n != 8*len(target)-1 {
+		t.Errorf("Common prefix of %d bits with ourselves, expected %d", n, 8*len(target)-1)
+	}
+}
+
+func waitDHTRunning(t *testing.T, c *dhtClient) {
+	t0 := time.Now()
+	for {
+		c.mut.Lock()
+		running := c.conn != nil
+		c.mut.Unlock()
+		if running {
+			return
+		}
+		if time.Since(t0) > 10*time.Second {
+			t.Fatal("DHT node not running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func freeUDPAddr(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
@@ -0,0 +1,91 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package discover
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A dnsClient looks up the addresses of devices in the DNS records of a
+// domain. A device is found at _syncthing._tcp.<device ID>.<domain>, the
+// device ID in lower case, its labels fitting in a DNS name as they are.
+// SRV records there give TCP addresses, TXT records any address, such as
+// "relay://192.0.2.42:22067/?id=...".
+type dnsClient struct {
+	domain   string
+	resolver dnsResolver
+	errorHolder
+}
+
+type dnsResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+func NewDNS(domain string) Finder {
+	return &dnsClient{
+		domain:   strings.Trim(domain, "."),
+		resolver: net.DefaultResolver,
+	}
+}
+
+// Lookup returns the addresses the device has in DNS.
+func (c *dnsClient) Lookup(device protocol.DeviceID) (addresses []string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	name := strings.ToLower(device.String()) + "." + c.domain
+
+	_, srvs, srvErr := c.resolver.LookupSRV(ctx, "syncthing", "tcp", name)
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addresses = append(addresses, "tcp://"+net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+
+	txts, txtErr := c.resolver.LookupTXT(ctx, "_syncthing._tcp."+name)
+	for _, txt := range txts {
+		if u, err := url.Parse(txt); err == nil && u.Scheme != "" && u.Host != "" {
+			addresses = append(addresses, txt)
+		} else {
+			l.Debugln("dnsClient.Lookup", name, "ignoring TXT record", txt)
+		}
+	}
+
+	// A device without records isn't an error, just not found.
+	srvErr, txtErr = realDNSError(srvErr), realDNSError(txtErr)
+	if len(addresses) == 0 && srvErr != nil && txtErr != nil {
+		l.Debugln("dnsClient.Lookup", name, srvErr, txtErr)
+		c.setError(srvErr)
+		return nil, srvErr
+	}
+	c.setError(nil)
+	return addresses, nil
+}
+
+func (c *dnsClient) String() string {
+	return "dns@" + c.domain
+}
+
+func (c *dnsClient) Cache() map[protocol.DeviceID]CacheEntry {
+	// The dnsClient doesn't do caching
+	return nil
+}
+
+// realDNSError returns the error, or nil if it only says the name doesn't
+// exist.
+func realDNSError(err error) error {
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil
+	}
+	return err
+}
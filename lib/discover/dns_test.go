@@ -0,0 +1,86 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package discover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type fakeResolver struct {
+	srvs map[string][]*net.SRV
+	txts map[string][]string
+	err  error
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	name = "_" + service + "._" + proto + "." + name
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if srvs, ok := r.srvs[name]; ok {
+		return name, srvs, nil
+	}
+	return "", nil, &net.DNSError{Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if txts, ok := r.txts[name]; ok {
+		return txts, nil
+	}
+	return nil, &net.DNSError{Name: name, IsNotFound: true}
+}
+
+func TestDNSLookup(t *testing.T) {
+	dev, _ := protocol.DeviceIDFromString("P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2")
+	name := "_syncthing._tcp.p56ioi7-mzjnu2y-iqgdrey-dm2mgti-mgl3bxn-pq6w5bm-tbbz4tj-xzwicq2.example.com"
+
+	resolver := &fakeResolver{
+		srvs: map[string][]*net.SRV{
+			name: {
+				{Target: "host.example.com.", Port: 22000},
+				{Target: "2001:db8::1", Port: 22001},
+			},
+		},
+		txts: map[string][]string{
+			name: {"relay://192.0.2.42:22067/?id=abc", "v=spf1 -all"},
+		},
+	}
+	c := &dnsClient{domain: "example.com", resolver: resolver}
+
+	addrs, err := c.Lookup(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"tcp://host.example.com:22000", "tcp://[2001:db8::1]:22001", "relay://192.0.2.42:22067/?id=abc"}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("Got %v, expected %v", addrs, expected)
+	}
+
+	// A device without records isn't an error.
+	other, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	if addrs, err := c.Lookup(other); err != nil || len(addrs) != 0 {
+		t.Errorf("Got %v, %v for a device without records", addrs, err)
+	}
+
+	// Failures to resolve are.
+	resolver.err = errors.New("server misbehaving")
+	if _, err := c.Lookup(dev); err == nil {
+		t.Error("Expected an error")
+	}
+	if c.Error() == nil {
+		t.Error("Expected the error to stick")
+	}
+}
@@ -31,6 +31,7 @@ type globalClient struct {
 	queryClient    httpClient
 	noAnnounce     bool
 	noLookup       bool
+	token          tokenHolder
 	stop           chan struct{}
 	errorHolder
 }
@@ -38,6 +39,7 @@ type globalClient struct {
 type httpClient interface {
 	Get(url string) (*http.Response, error)
 	Post(url, ctype string, data io.Reader) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 const (
@@ -150,7 +152,7 @@ func (c *globalClient) Lookup(device protocol.DeviceID) (addresses []string, err
 	q.Set("device", device.String())
 	qURL.RawQuery = q.Encode()
 
-	resp, err := c.queryClient.Get(qURL.String())
+	resp, err := c.lookupRequest(qURL.String())
 	if err != nil {
 		l.Debugln("globalClient.Lookup", qURL, err)
 		return nil, err
@@ -179,6 +181,57 @@ func (c *globalClient) Lookup(device protocol.DeviceID) (addresses []string, err
 	return ann.Addresses, err
 }
 
+// lookupRequest performs the lookup, presenting our session token if we
+// have one. Private servers require a token; if ours is missing or stale a
+// new one is requested and the lookup retried once.
+func (c *globalClient) lookupRequest(qURL string) (*http.Response, error) {
+	resp, err := c.getWithToken(qURL)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	if err := c.requestToken(); err != nil {
+		l.Debugln("globalClient.Lookup token:", err)
+		return nil, err
+	}
+	return c.getWithToken(qURL)
+}
+
+func (c *globalClient) getWithToken(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := c.token.get(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.queryClient.Do(req)
+}
+
+// requestToken gets a new session token from the server, authenticating
+// with our certificate.
+func (c *globalClient) requestToken() error {
+	tURL, err := url.Parse(c.server)
+	if err != nil {
+		return err
+	}
+	tURL = tURL.ResolveReference(&url.URL{Path: "token"})
+
+	resp, err := c.announceClient.Get(tURL.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	if !c.token.update(resp) {
+		return errors.New("no session token in response")
+	}
+	return nil
+}
+
 func (c *globalClient) String() string {
 	return "global@" + c.server
 }
@@ -262,6 +315,7 @@ func (c *globalClient) sendAnnouncement(timer *time.Timer) {
 	}
 
 	c.setError(nil)
+	c.token.update(resp)
 
 	if h := resp.Header.Get("Reannounce-After"); h != "" {
 		// The server has a recommendation on when we should
@@ -384,6 +438,50 @@ func (c *idCheckingHTTPClient) Post(url, ctype string, data io.Reader) (*http.Re
 	return resp, nil
 }
 
+func (c *idCheckingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.check(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// tokenHolder keeps the session token handed out by private discovery
+// servers, until it expires.
+type tokenHolder struct {
+	token   string
+	expires time.Time
+	mut     stdsync.Mutex
+}
+
+func (t *tokenHolder) get() string {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if time.Now().After(t.expires) {
+		return ""
+	}
+	return t.token
+}
+
+// update takes the token from the response headers, returning whether there
+// was one.
+func (t *tokenHolder) update(resp *http.Response) bool {
+	token := resp.Header.Get("Session-Token")
+	secs, err := strconv.Atoi(resp.Header.Get("Session-Token-Expires"))
+	if token == "" || err != nil || secs <= 0 {
+		return false
+	}
+	t.mut.Lock()
+	t.token = token
+	t.expires = time.Now().Add(time.Duration(secs) * time.Second)
+	t.mut.Unlock()
+	return true
+}
+
 type errorHolder struct {
 	err error
 	mut stdsync.Mutex // uses stdlib sync as I want this to be trivially embeddable, and there is no risk of blocking
@@ -216,6 +216,73 @@ func TestGlobalAnnounce(t *testing.T) {
 	}
 }
 
+func TestGlobalPrivate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generate a server certificate.
+	cert, err := tlsutil.NewCertificate(dir+"/cert.pem", dir+"/key.pem", "syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tls.Listen("tcp4", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequestClientCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+
+	// The private server hands out tokens to clients with a certificate and
+	// only answers lookups with a token.
+	s := new(fakeDiscoveryServer)
+	tokenRequests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		tokenRequests++
+		w.Header().Set("Session-Token", "thetoken")
+		w.Header().Set("Session-Token-Expires", "3600")
+		w.WriteHeader(204)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer thetoken" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.handler(w, r)
+	})
+	go func() { _ = http.Serve(list, mux) }()
+
+	url := "https://" + list.Addr().String() + "?insecure&noannounce"
+	disco, err := NewGlobal(url, cert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		addresses, err := disco.Lookup(protocol.LocalDeviceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addresses) != 1 || addresses[0] != "tcp://192.0.2.42::22000" {
+			t.Errorf("incorrect addresses list: %+v", addresses)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected the token to be requested once, not %d times", tokenRequests)
+	}
+
+	// Without a certificate there is no token to be had.
+	if _, err := testLookup(url); err == nil {
+		t.Fatal("unexpected nil error for lookup without token")
+	}
+}
+
 func testLookup(url string) ([]string, error) {
 	disco, err := NewGlobal(url, tls.Certificate{}, nil)
 	if err != nil {
@@ -12,6 +12,7 @@ package discover
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"io"
 	"net"
 	"net/url"
@@ -47,6 +48,17 @@ const (
 )
 
 func NewLocal(id protocol.DeviceID, addr string, addrList AddressLister) (FinderService, error) {
+	return newLocal(id, addr, addrList, nil)
+}
+
+// NewLocalUnicast returns the IPv4 local discovery on the address, which
+// announces to the unicast destinations, IPv4 addresses or subnets, as well
+// as broadcasting.
+func NewLocalUnicast(id protocol.DeviceID, addr string, addrList AddressLister, unicast []string) (FinderService, error) {
+	return newLocal(id, addr, addrList, unicast)
+}
+
+func newLocal(id protocol.DeviceID, addr string, addrList AddressLister, unicast []string) (FinderService, error) {
 	c := &localClient{
 		Supervisor: suture.New("local", suture.Spec{
 			PassThroughPanics: true,
@@ -71,9 +83,12 @@ func NewLocal(id protocol.DeviceID, addr string, addrList AddressLister) (Finder
 		if err != nil {
 			return nil, err
 		}
-		c.startLocalIPv4Broadcasts(bcPort)
+		c.startLocalIPv4Broadcasts(bcPort, unicast)
 	} else {
 		// A multicast client
+		if len(unicast) > 0 {
+			return nil, errors.New("unicast announcements are only for IPv4 local discovery")
+		}
 		c.name = "IPv6 local"
 		c.startLocalIPv6Multicasts(addr)
 	}
@@ -83,8 +98,12 @@ func NewLocal(id protocol.DeviceID, addr string, addrList AddressLister) (Finder
 	return c, nil
 }
 
-func (c *localClient) startLocalIPv4Broadcasts(localPort int) {
-	c.beacon = beacon.NewBroadcast(localPort)
+func (c *localClient) startLocalIPv4Broadcasts(localPort int, unicast []string) {
+	if len(unicast) > 0 {
+		c.beacon = beacon.NewUnicast(localPort, unicast)
+	} else {
+		c.beacon = beacon.NewBroadcast(localPort)
+	}
 	c.Add(c.beacon)
 	go c.recvAnnouncements(c.beacon)
 }
@@ -146,6 +165,12 @@ func (c *localClient) sendLocalAnnouncements() {
 	var msg []byte
 	var ok bool
 	instanceID := rand.Int63()
+
+	// Announce right away when our network interfaces change, so that
+	// others don't keep using an address we no longer have.
+	eventSub := events.Default.Subscribe(events.InterfaceAddressesChanged)
+	defer events.Default.Unsubscribe(eventSub)
+
 	for {
 		if msg, ok = c.announcementPkt(instanceID, msg[:0]); ok {
 			c.beacon.Send(msg)
@@ -154,6 +179,7 @@ func (c *localClient) sendLocalAnnouncements() {
 		select {
 		case <-c.localBcastTick:
 		case <-c.forcedBcastTick:
+		case <-eventSub.C():
 		}
 	}
 }
@@ -10,13 +10,15 @@ package events
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
-type EventType int
+type EventType uint64
 
 const (
 	Starting EventType = 1 << iota
@@ -47,10 +49,20 @@ const (
 	FolderWatchStateChanged
 	ListenAddressesChanged
 	LoginAttempt
-
-	AllEvents = (1 << iota) - 1
+	ItemMigrated
+	InterfaceAddressesChanged
+	FolderDecommissioned
+	ConfigChanged
+	APIRequest
+	FolderQuotaExceeded
+
+	AllEvents EventType = (1 << iota) - 1
 )
 
+// AuditEvents are the events that record what the admins of the device do,
+// for an audit log.
+const AuditEvents = ConfigChanged | LoginAttempt | APIRequest
+
 var runningTests = false
 
 const eventLogTimeout = 15 * time.Millisecond
@@ -113,6 +125,18 @@ func (t EventType) String() string {
 		return "LoginAttempt"
 	case FolderWatchStateChanged:
 		return "FolderWatchStateChanged"
+	case ItemMigrated:
+		return "ItemMigrated"
+	case InterfaceAddressesChanged:
+		return "InterfaceAddressesChanged"
+	case FolderDecommissioned:
+		return "FolderDecommissioned"
+	case ConfigChanged:
+		return "ConfigChanged"
+	case APIRequest:
+		return "APIRequest"
+	case FolderQuotaExceeded:
+		return "FolderQuotaExceeded"
 	default:
 		return "Unknown"
 	}
@@ -192,11 +216,40 @@ func UnmarshalEventType(s string) EventType {
 		return LoginAttempt
 	case "FolderWatchStateChanged":
 		return FolderWatchStateChanged
+	case "ItemMigrated":
+		return ItemMigrated
+	case "InterfaceAddressesChanged":
+		return InterfaceAddressesChanged
+	case "FolderDecommissioned":
+		return FolderDecommissioned
+	case "ConfigChanged":
+		return ConfigChanged
+	case "APIRequest":
+		return APIRequest
+	case "FolderQuotaExceeded":
+		return FolderQuotaExceeded
 	default:
 		return 0
 	}
 }
 
+// UnmarshalEventMask returns the mask of the named event types, as in the
+// configuration of webhooks and the like, or AllEvents if there are none.
+func UnmarshalEventMask(names []string) (EventType, error) {
+	if len(names) == 0 {
+		return AllEvents, nil
+	}
+	var mask EventType
+	for _, name := range names {
+		t := UnmarshalEventType(name)
+		if t == 0 {
+			return 0, fmt.Errorf("unknown event type %q", name)
+		}
+		mask |= t
+	}
+	return mask, nil
+}
+
 const BufferSize = 64
 
 type Logger struct {
@@ -221,10 +274,71 @@ type Event struct {
 
 type Subscription struct {
 	mask    EventType
+	filter  Filter
 	events  chan Event
 	timeout *time.Timer
 }
 
+// A Filter further restricts the events delivered to a subscription to
+// those concerning the given folders and devices. Events that don't refer
+// to a folder or device at all are not affected by the respective list. An
+// empty Filter matches all events.
+type Filter struct {
+	Folders []string
+	Devices []string
+}
+
+// IsEmpty returns true if the filter matches all events.
+func (f Filter) IsEmpty() bool {
+	return len(f.Folders) == 0 && len(f.Devices) == 0
+}
+
+func (f Filter) String() string {
+	return fmt.Sprintf("folders=%s devices=%s", strings.Join(f.Folders, ","), strings.Join(f.Devices, ","))
+}
+
+func (f Filter) matches(e Event) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if folder, ok := dataField(e.Data, "folder"); ok && len(f.Folders) > 0 && !contains(f.Folders, folder) {
+		return false
+	}
+	deviceKey := "device"
+	if e.Type&(DeviceConnected|DeviceDisconnected) != 0 {
+		// These predate the "device" convention
+		deviceKey = "id"
+	}
+	if device, ok := dataField(e.Data, deviceKey); ok && len(f.Devices) > 0 && !contains(f.Devices, device) {
+		return false
+	}
+	return true
+}
+
+// dataField returns the given string field of the event data, if the data
+// is a map containing it.
+func dataField(data interface{}, key string) (string, bool) {
+	switch data := data.(type) {
+	case map[string]string:
+		v, ok := data[key]
+		return v, ok
+	case map[string]interface{}:
+		v, ok := data[key].(string)
+		return v, ok
+	default:
+		return "", false
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 var Default = NewLogger()
 
 func init() {
@@ -299,9 +413,13 @@ func (l *Logger) sendEvent(e Event) {
 	e.GlobalID = l.nextGlobalID
 
 	for i, s := range l.subs {
-		if s.mask&e.Type != 0 {
-			e.SubscriptionID = l.nextSubscriptionIDs[i]
-			l.nextSubscriptionIDs[i]++
+		if s.mask&e.Type != 0 && s.filter.matches(e) {
+			if s.filter.IsEmpty() {
+				e.SubscriptionID = l.nextSubscriptionIDs[i]
+				l.nextSubscriptionIDs[i]++
+			} else {
+				e.SubscriptionID = e.GlobalID
+			}
 
 			l.timeout.Reset(eventLogTimeout)
 			timedOut := false
@@ -324,12 +442,21 @@ func (l *Logger) sendEvent(e Event) {
 }
 
 func (l *Logger) Subscribe(mask EventType) *Subscription {
+	return l.SubscribeFiltered(mask, Filter{})
+}
+
+// SubscribeFiltered returns a subscription to the events of the given types
+// that also match the filter. With a filter the subscription IDs are the
+// global IDs, so that they carry on where they were when the subscription
+// is created again, instead of restarting.
+func (l *Logger) SubscribeFiltered(mask EventType, filter Filter) *Subscription {
 	res := make(chan *Subscription)
 	l.funcs <- func() {
-		dl.Debugln("subscribe", mask)
+		dl.Debugln("subscribe", mask, filter)
 
 		s := &Subscription{
 			mask:    mask,
+			filter:  filter,
 			events:  make(chan Event, BufferSize),
 			timeout: time.NewTimer(0),
 		}
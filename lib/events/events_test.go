@@ -230,6 +230,42 @@ func TestSubscriptionIDs(t *testing.T) {
 	}
 }
 
+func TestSubscribeFiltered(t *testing.T) {
+	l := NewLogger()
+	defer l.Stop()
+	go l.Serve()
+
+	s := l.SubscribeFiltered(ItemStarted|DeviceConnected|StartupComplete, Filter{
+		Folders: []string{"default"},
+		Devices: []string{"device1"},
+	})
+	defer l.Unsubscribe(s)
+
+	l.Log(ItemStarted, map[string]string{"folder": "other", "item": "a"})
+	l.Log(ItemStarted, map[string]string{"folder": "default", "item": "b"})
+	l.Log(DeviceConnected, map[string]string{"id": "device2"})
+	l.Log(DeviceConnected, map[string]string{"id": "device1"})
+	l.Log(ItemStarted, map[string]interface{}{"folder": "default", "device": "device2"})
+	l.Log(StartupComplete, "no folder or device")
+
+	for _, expected := range []int{2, 4, 6} {
+		ev, err := s.Poll(timeout)
+		if err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+		if ev.GlobalID != expected {
+			t.Errorf("Incorrect GlobalID %d, expected %d", ev.GlobalID, expected)
+		}
+		if ev.SubscriptionID != expected {
+			t.Errorf("Incorrect SubscriptionID %d, expected the global ID %d", ev.SubscriptionID, expected)
+		}
+	}
+
+	if _, err := s.Poll(timeout); err != ErrTimeout {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
 func TestBufferedSub(t *testing.T) {
 	l := NewLogger()
 	defer l.Stop()
@@ -367,6 +403,18 @@ func TestUnmarshalEvent(t *testing.T) {
 	}
 }
 
+func TestUnmarshalEventMask(t *testing.T) {
+	if mask, err := UnmarshalEventMask(nil); err != nil || mask != AllEvents {
+		t.Errorf("No names => %v, %v", mask, err)
+	}
+	if mask, err := UnmarshalEventMask([]string{"ItemStarted", "ItemFinished"}); err != nil || mask != ItemStarted|ItemFinished {
+		t.Errorf("Two names => %v, %v", mask, err)
+	}
+	if _, err := UnmarshalEventMask([]string{"ItemStarted", "NoSuchEvent"}); err == nil {
+		t.Error("Unknown name accepted")
+	}
+}
+
 func TestUnsubscribeContention(t *testing.T) {
 	// Check that we can unsubscribe without blocking the whole system.
 
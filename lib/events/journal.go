@@ -0,0 +1,212 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A JournalEntry is an event as stored in the journal. Journal IDs keep
+// increasing across restarts, unlike the IDs of events.
+type JournalEntry struct {
+	ID   int             `json:"id"`
+	Time time.Time       `json:"time"`
+	Type EventType       `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// The Journal persists events to disk, one JSON object per line, so that
+// they can be replayed after a restart. The journal is kept in two files,
+// the given path and the previous generation with ".1" appended, each
+// holding up to half of the maximum size.
+type Journal struct {
+	path    string
+	maxSize int64
+	logger  *Logger
+	sub     *Subscription
+	stop    chan struct{}
+
+	mut    sync.Mutex
+	fd     *os.File
+	size   int64
+	nextID int
+}
+
+// NewJournal opens the journal at path and subscribes to the given events.
+// Events are written once the journal is served.
+func NewJournal(l *Logger, path string, mask EventType, maxSize int64) (*Journal, error) {
+	j := &Journal{
+		path:    path,
+		maxSize: maxSize,
+		logger:  l,
+		stop:    make(chan struct{}),
+		mut:     sync.NewMutex(),
+		nextID:  1,
+	}
+
+	for _, name := range []string{j.previousPath(), path} {
+		entries, err := readJournalFile(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			j.nextID = entries[len(entries)-1].ID + 1
+		}
+	}
+
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	j.fd = fd
+	j.size = info.Size()
+
+	j.sub = l.Subscribe(mask)
+	return j, nil
+}
+
+func (j *Journal) Serve() {
+	defer func() {
+		j.mut.Lock()
+		j.fd.Close()
+		j.mut.Unlock()
+	}()
+	for {
+		select {
+		case ev, ok := <-j.sub.C():
+			if !ok {
+				return
+			}
+			if err := j.write(ev); err != nil {
+				dl.Debugln("writing journal:", err)
+			}
+		case <-j.stop:
+			j.logger.Unsubscribe(j.sub)
+			return
+		}
+	}
+}
+
+func (j *Journal) Stop() {
+	close(j.stop)
+}
+
+func (j *Journal) String() string {
+	return "events.Journal@" + j.path
+}
+
+func (j *Journal) write(ev Event) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return err
+	}
+
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	line, err := json.Marshal(JournalEntry{
+		ID:   j.nextID,
+		Time: ev.Time,
+		Type: ev.Type,
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if j.size > 0 && j.size+int64(len(line)) > j.maxSize/2 {
+		if err := j.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	n, err := j.fd.Write(line)
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	j.nextID++
+	return nil
+}
+
+func (j *Journal) rotateLocked() error {
+	j.fd.Close()
+	if err := os.Rename(j.path, j.previousPath()); err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	j.fd = fd
+	j.size = 0
+	return nil
+}
+
+func (j *Journal) previousPath() string {
+	return j.path + ".1"
+}
+
+// Since returns the journaled events with an ID larger than the given one,
+// oldest first. If limit is positive, at most that many of the most recent
+// of those are returned.
+func (j *Journal) Since(id, limit int) ([]JournalEntry, error) {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+
+	res := make([]JournalEntry, 0)
+	for _, name := range []string{j.previousPath(), j.path} {
+		entries, err := readJournalFile(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.ID > id {
+				res = append(res, e)
+			}
+		}
+	}
+	if limit > 0 && limit < len(res) {
+		res = res[len(res)-limit:]
+	}
+	return res, nil
+}
+
+func readJournalFile(name string) ([]JournalEntry, error) {
+	fd, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(fd)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Most likely a line cut short by a crash
+			dl.Debugln("skipping journal line:", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
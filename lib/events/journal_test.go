@@ -0,0 +1,131 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-journal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.log")
+
+	l := NewLogger()
+	defer l.Stop()
+	go l.Serve()
+
+	j, err := NewJournal(l, path, AllEvents, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go j.Serve()
+
+	l.Log(DeviceConnected, map[string]string{"id": "a"})
+	l.Log(DeviceDisconnected, map[string]string{"id": "a"})
+	l.Log(StateChanged, map[string]string{"folder": "default"})
+	waitForJournal(t, j, 3)
+	j.Stop()
+
+	// Reopening continues the ID sequence
+	j, err = NewJournal(l, path, AllEvents, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go j.Serve()
+	defer j.Stop()
+	l.Log(ItemStarted, map[string]string{"item": "foo"})
+	waitForJournal(t, j, 4)
+
+	entries, err := j.Since(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatal("Expected three entries, got", len(entries))
+	}
+	for i, e := range entries {
+		if e.ID != i+2 {
+			t.Errorf("Entry %d has ID %d, expected %d", i, e.ID, i+2)
+		}
+	}
+	if entries[0].Type != DeviceDisconnected || entries[2].Type != ItemStarted || string(entries[2].Data) != `{"item":"foo"}` {
+		t.Error("Unexpected entries:", entries)
+	}
+
+	entries, _ = j.Since(0, 1)
+	if len(entries) != 1 || entries[0].ID != 4 {
+		t.Error("Expected only the latest entry, got", entries)
+	}
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-journal-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events.log")
+
+	l := NewLogger()
+	defer l.Stop()
+	go l.Serve()
+
+	// Room for a few events per file
+	j, err := NewJournal(l, path, AllEvents, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go j.Serve()
+	defer j.Stop()
+
+	for i := 0; i < 20; i++ {
+		l.Log(ItemStarted, map[string]int{"item": i})
+	}
+	waitForJournal(t, j, 20)
+
+	var size int64
+	for _, name := range []string{path, path + ".1"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	if size > 1000 {
+		t.Error("Journal exceeds its maximum size:", size)
+	}
+
+	entries, err := j.Since(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) == 20 || entries[len(entries)-1].ID != 20 {
+		t.Error("Expected the most recent entries to be kept, got", len(entries))
+	}
+}
+
+func waitForJournal(t *testing.T, j *Journal, id int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		j.mut.Lock()
+		next := j.nextID
+		j.mut.Unlock()
+		if next > id {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timeout waiting for journal entry", id)
+}
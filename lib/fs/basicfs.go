@@ -175,6 +175,18 @@ func (f *BasicFilesystem) Rename(oldpath, newpath string) error {
 	return os.Rename(oldpath, newpath)
 }
 
+func (f *BasicFilesystem) Link(oldname, newname string) error {
+	oldname, err := f.rooted(oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = f.rooted(newname)
+	if err != nil {
+		return err
+	}
+	return os.Link(oldname, newname)
+}
+
 func (f *BasicFilesystem) Stat(name string) (FileInfo, error) {
 	name, err := f.rooted(name)
 	if err != nil {
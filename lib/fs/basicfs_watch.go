@@ -10,7 +10,6 @@ package fs
 
 import (
 	"context"
-	"errors"
 	"path/filepath"
 	"runtime"
 
@@ -22,7 +21,7 @@ import (
 // Not meant to be changed, but must be changeable for tests
 var backendBuffer = 500
 
-func (f *BasicFilesystem) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
+func (f *BasicFilesystem) watchNative(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
 	evalRoot, err := evalSymlinks(f.root)
 	if err != nil {
 		return nil, err
@@ -58,7 +57,7 @@ func (f *BasicFilesystem) Watch(name string, ignore Matcher, ctx context.Context
 	if err != nil {
 		notify.Stop(backendChan)
 		if reachedMaxUserWatches(err) {
-			err = errors.New("failed to setup inotify handler. Please increase inotify limits, see https://docs.syncthing.net/users/faq.html#inotify-limits")
+			err = errInotifyLimits
 		}
 		return nil, err
 	}
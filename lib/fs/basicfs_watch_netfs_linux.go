@@ -0,0 +1,16 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+// isNetworkFilesystem returns true if the path is on a filesystem that may
+// be changed by other hosts, without inotify noticing.
+func isNetworkFilesystem(path string) bool {
+	fsType, ok := statfsType(path)
+	return ok && fsType.network
+}
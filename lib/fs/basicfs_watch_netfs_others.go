@@ -1,10 +1,13 @@
-// Copyright (C) 2016 The Syncthing Authors.
+// Copyright (C) 2019 The Syncthing Authors.
 //
 // This Source Code Form is subject to the terms of the Mozilla Public
 // License, v. 2.0. If a copy of the MPL was not distributed with this file,
 // You can obtain one at https://mozilla.org/MPL/2.0/.
 
-// The existence of this file means we get 0% test coverage rather than no
-// test coverage at all. Remove when implementing an actual test.
+// +build !linux
 
-package pmp
+package fs
+
+func isNetworkFilesystem(path string) bool {
+	return false
+}
@@ -0,0 +1,196 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// The interval between polls doubles while nothing changes, from the
+// minimum up to the maximum, and drops back to the minimum on a change.
+// Not meant to be changed, but must be changeable for tests
+var (
+	minPollInterval = 10 * time.Second
+	maxPollInterval = 5 * time.Minute
+)
+
+const (
+	// A poll waits at least this many times as long as the previous one
+	// took, to keep the load of polling low on large folders.
+	pollLoadFactor = 10
+	// With more changes than this a poll sends a single event for the
+	// whole watched path instead, the same as on an event overflow.
+	maxPollEvents = 500
+)
+
+// Watch watches the named path by means of the native notifications of the
+// platform. On network filesystems, of which changes made on other hosts
+// aren't notified, and when native watching fails, it polls for changes
+// instead.
+func (f *BasicFilesystem) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
+	if isNetworkFilesystem(f.root) {
+		l.Infof("Watching %s by polling, as it's on a network filesystem", f.URI())
+		return f.watchPoll(name, ignore, ctx, ignorePerms)
+	}
+
+	eventChan, err := f.watchNative(name, ignore, ctx, ignorePerms)
+	if err == nil {
+		return eventChan, nil
+	}
+	l.Infof("Watching %s by polling, as native watching failed: %v", f.URI(), err)
+	return f.watchPoll(name, ignore, ctx, ignorePerms)
+}
+
+// A pollEntry is what is compared between polls to tell if a file changed.
+type pollEntry struct {
+	modTime time.Time
+	size    int64
+	mode    FileMode
+}
+
+type poller struct {
+	fs          Filesystem
+	name        string
+	ignore      Matcher
+	ignorePerms bool
+}
+
+func (f *BasicFilesystem) watchPoll(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
+	p := &poller{
+		fs:          NewWalkFilesystem(f),
+		name:        name,
+		ignore:      ignore,
+		ignorePerms: ignorePerms,
+	}
+
+	start := time.Now()
+	entries, err := p.poll()
+	if err != nil {
+		return nil, err
+	}
+
+	outChan := make(chan Event)
+	go p.pollLoop(entries, time.Since(start), outChan, ctx)
+	return outChan, nil
+}
+
+func (p *poller) pollLoop(entries map[string]pollEntry, took time.Duration, outChan chan<- Event, ctx context.Context) {
+	interval := nextPollInterval(0, took, true)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Stopped polling")
+			return
+		}
+
+		start := time.Now()
+		newEntries, err := p.poll()
+		took = time.Since(start)
+		if err != nil {
+			// The watched path is gone or unreadable, which the scan
+			// reports. Try again later.
+			l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Polling failed:", err)
+			timer.Reset(nextPollInterval(interval, took, false))
+			continue
+		}
+
+		evs := pollEvents(entries, newEntries)
+		entries = newEntries
+		if len(evs) > maxPollEvents {
+			l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Many changes while polling, send", p.name)
+			evs = []Event{{Name: p.name, Type: NonRemove}}
+		}
+		for _, ev := range evs {
+			select {
+			case outChan <- ev:
+				l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Sending", ev.Name, ev.Type)
+			case <-ctx.Done():
+				l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Stopped polling")
+				return
+			}
+		}
+
+		interval = nextPollInterval(interval, took, len(evs) > 0)
+		l.Debugln(p.fs.Type(), p.fs.URI(), "Watch: Next poll in", interval)
+		timer.Reset(interval)
+	}
+}
+
+// poll returns the entries of the files that aren't ignored below the
+// watched path.
+func (p *poller) poll() (map[string]pollEntry, error) {
+	entries := make(map[string]pollEntry)
+	err := p.fs.Walk(p.name, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			if path == p.name {
+				return err
+			}
+			// Vanished while walking, or unreadable, which the scan
+			// reports.
+			return nil
+		}
+		if path != p.name && p.ignore.ShouldIgnore(path) {
+			if info.IsDir() && p.ignore.SkipIgnoredDirs() {
+				return SkipDir
+			}
+			return nil
+		}
+		mode := info.Mode()
+		if p.ignorePerms {
+			mode &= ModeType
+		}
+		entries[path] = pollEntry{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			mode:    mode,
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// pollEvents returns the events for the changes between two polls, sorted
+// by name.
+func pollEvents(old, cur map[string]pollEntry) []Event {
+	var evs []Event
+	for name, entry := range cur {
+		if oldEntry, ok := old[name]; !ok || !oldEntry.modTime.Equal(entry.modTime) || oldEntry.size != entry.size || oldEntry.mode != entry.mode {
+			evs = append(evs, Event{Name: name, Type: NonRemove})
+		}
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			evs = append(evs, Event{Name: name, Type: Remove})
+		}
+	}
+	sort.Slice(evs, func(a, b int) bool {
+		return evs[a].Name < evs[b].Name
+	})
+	return evs
+}
+
+// nextPollInterval returns the interval until the next poll, given the
+// current one, how long the last poll took and whether it found changes.
+func nextPollInterval(cur, took time.Duration, changed bool) time.Duration {
+	next := 2 * cur
+	if changed || next < minPollInterval {
+		next = minPollInterval
+	}
+	if next > maxPollInterval {
+		next = maxPollInterval
+	}
+	if min := pollLoadFactor * took; next < min {
+		next = min
+	}
+	return next
+}
@@ -466,3 +466,72 @@ func (e fakeEventInfo) Event() notify.Event {
 func (e fakeEventInfo) Sys() interface{} {
 	return nil
 }
+
+func TestWatchPoll(t *testing.T) {
+	defer func(min, max time.Duration) {
+		minPollInterval, maxPollInterval = min, max
+	}(minPollInterval, maxPollInterval)
+	minPollInterval, maxPollInterval = 10*time.Millisecond, 20*time.Millisecond
+
+	name := "poll"
+	if err := testFs.MkdirAll(name, 0755); err != nil {
+		panic(fmt.Sprintf("Failed to create directory %s: %s", name, err))
+	}
+	defer testFs.RemoveAll(name)
+	createTestFile(name, "a")
+	createTestFile(name, "ignored")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fm := fakeMatcher{ignore: filepath.Join(name, "ignored")}
+	eventChan, err := newBasicFilesystem(testDirAbs).watchPoll(name, fm, ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createTestFile(name, "b")
+	if err := testFs.Remove(filepath.Join(name, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testFs.Chtimes(filepath.Join(name, "ignored"), time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[Event]struct{}{
+		{filepath.Join(name, "b"), NonRemove}: {},
+		{filepath.Join(name, "a"), Remove}:    {},
+	}
+	timeout := time.After(10 * time.Second)
+	for len(expected) > 0 {
+		select {
+		case ev := <-eventChan:
+			if ev.Name == fm.ignore {
+				t.Fatal("Received event for ignored file")
+			}
+			delete(expected, ev)
+		case <-timeout:
+			t.Fatalf("Timed out before receiving all expected events, missing %v", expected)
+		}
+	}
+}
+
+func TestNextPollInterval(t *testing.T) {
+	cases := []struct {
+		cur, took time.Duration
+		changed   bool
+		next      time.Duration
+	}{
+		{0, 0, true, minPollInterval},
+		{minPollInterval, 0, false, 2 * minPollInterval},
+		{8 * minPollInterval, 0, true, minPollInterval},
+		{maxPollInterval, 0, false, maxPollInterval},
+		// Slow polls are spaced out, also beyond the maximum.
+		{minPollInterval, minPollInterval, false, pollLoadFactor * minPollInterval},
+		{maxPollInterval, maxPollInterval, true, pollLoadFactor * maxPollInterval},
+	}
+	for _, tc := range cases {
+		if next := nextPollInterval(tc.cur, tc.took, tc.changed); next != tc.next {
+			t.Errorf("nextPollInterval(%v, %v, %v) == %v, expected %v", tc.cur, tc.took, tc.changed, next, tc.next)
+		}
+	}
+}
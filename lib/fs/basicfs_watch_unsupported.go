@@ -10,6 +10,6 @@ package fs
 
 import "context"
 
-func (f *BasicFilesystem) Watch(path string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
+func (f *BasicFilesystem) watchNative(path string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, error) {
 	return nil, ErrWatchNotSupported
 }
@@ -32,6 +32,7 @@ func (fs *errorFilesystem) ReadSymlink(name string) (string, error)
 func (fs *errorFilesystem) Remove(name string) error                                    { return fs.err }
 func (fs *errorFilesystem) RemoveAll(name string) error                                 { return fs.err }
 func (fs *errorFilesystem) Rename(oldname, newname string) error                        { return fs.err }
+func (fs *errorFilesystem) Link(oldname, newname string) error                          { return fs.err }
 func (fs *errorFilesystem) Stat(name string) (FileInfo, error)                          { return nil, fs.err }
 func (fs *errorFilesystem) SymlinksSupported() bool                                     { return false }
 func (fs *errorFilesystem) Walk(root string, walkFn WalkFunc) error                     { return fs.err }
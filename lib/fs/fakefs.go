@@ -454,6 +454,35 @@ func (fs *fakefs) Rename(oldname, newname string) error {
 	return nil
 }
 
+func (fs *fakefs) Link(oldname, newname string) error {
+	fs.mut.Lock()
+	defer fs.mut.Unlock()
+
+	p0 := fs.entryForName(filepath.Dir(oldname))
+	if p0 == nil {
+		return os.ErrNotExist
+	}
+
+	entry := p0.children[filepath.Base(oldname)]
+	if entry == nil {
+		return os.ErrNotExist
+	} else if entry.entryType == fakeEntryTypeDir {
+		return errors.New("is a directory")
+	}
+
+	p1 := fs.entryForName(filepath.Dir(newname))
+	if p1 == nil {
+		return os.ErrNotExist
+	}
+
+	if _, ok := p1.children[filepath.Base(newname)]; ok {
+		return os.ErrExist
+	}
+
+	p1.children[filepath.Base(newname)] = entry
+	return nil
+}
+
 func (fs *fakefs) Stat(name string) (FileInfo, error) {
 	return fs.Lstat(name)
 }
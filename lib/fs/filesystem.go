@@ -24,6 +24,7 @@ type Filesystem interface {
 	Create(name string) (File, error)
 	CreateSymlink(target, name string) error
 	DirNames(name string) ([]string, error)
+	Link(oldname, newname string) error
 	Lstat(name string) (FileInfo, error)
 	Mkdir(name string, perm FileMode) error
 	MkdirAll(name string, perm FileMode) error
@@ -134,6 +135,8 @@ func (evType EventType) String() string {
 
 var ErrWatchNotSupported = errors.New("watching is not supported")
 
+var errInotifyLimits = errors.New("failed to setup inotify handler. Please increase inotify limits, see https://docs.syncthing.net/users/faq.html#inotify-limits")
+
 // Equivalents from os package.
 
 const ModePerm = FileMode(os.ModePerm)
@@ -0,0 +1,47 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+import "syscall"
+
+type linuxFilesystemType struct {
+	name    string
+	network bool // may be changed by other hosts, without inotify noticing
+}
+
+// Magic numbers from statfs(2) of common filesystems.
+var linuxFilesystemTypes = map[uint32]linuxFilesystemType{
+	0x0000ef53: {name: "ext4"}, // also ext2 and ext3
+	0x9123683e: {name: "btrfs"},
+	0x58465342: {name: "xfs"},
+	0x2fc12fc1: {name: "zfs"},
+	0x01021994: {name: "tmpfs"},
+	0x794c7630: {name: "overlayfs"},
+	0x00006969: {name: "nfs", network: true},
+	0x0000517b: {name: "smb", network: true},
+	0xff534d42: {name: "cifs", network: true},
+	0xfe534d42: {name: "smb2", network: true},
+	0x00004d44: {name: "vfat"},
+	0x2011bab0: {name: "exfat"},
+	0x5346544e: {name: "ntfs"},
+	0x65735546: {name: "fuse", network: true},
+	0x0000f15f: {name: "ecryptfs"},
+}
+
+// statfsType returns the type of the filesystem the path is on, or false if
+// it can't be determined or isn't one we know.
+func statfsType(path string) (linuxFilesystemType, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return linuxFilesystemType{}, false
+	}
+	// The type of the field differs between architectures.
+	fsType, ok := linuxFilesystemTypes[uint32(st.Type)]
+	return fsType, ok
+}
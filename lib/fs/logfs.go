@@ -50,6 +50,12 @@ func (fs *logFilesystem) CreateSymlink(target, name string) error {
 	return err
 }
 
+func (fs *logFilesystem) Link(oldname, newname string) error {
+	err := fs.Filesystem.Link(oldname, newname)
+	l.Debugln(getCaller(), fs.Type(), fs.URI(), "Link", oldname, newname, err)
+	return err
+}
+
 func (fs *logFilesystem) DirNames(name string) ([]string, error) {
 	names, err := fs.Filesystem.DirNames(name)
 	l.Debugln(getCaller(), fs.Type(), fs.URI(), "DirNames", name, names, err)
@@ -0,0 +1,208 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// A PreflightCheck is the outcome of testing one capability. Transient
+// failures, such as hitting a resource limit, don't mean the capability is
+// missing.
+type PreflightCheck struct {
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	Transient bool   `json:"transient,omitempty"`
+}
+
+// A PreflightReport describes what a prospective folder location supports.
+// When the path doesn't exist yet the checks are done in the nearest
+// existing parent directory, given as CheckedPath, where the folder would
+// be created.
+type PreflightReport struct {
+	Path          string         `json:"path"`
+	Type          FilesystemType `json:"type"`
+	Exists        bool           `json:"exists"`
+	CheckedPath   string         `json:"checkedPath"`
+	OSType        string         `json:"osType,omitempty"` // e.g. "ext4", where known
+	CaseSensitive bool           `json:"caseSensitive"`
+	Usage         Usage          `json:"usage"`
+	Read          PreflightCheck `json:"read"`
+	Write         PreflightCheck `json:"write"`
+	Rename        PreflightCheck `json:"rename"`
+	Permissions   PreflightCheck `json:"permissions"`
+	Symlink       PreflightCheck `json:"symlink"`
+	Xattr         PreflightCheck `json:"xattr"`
+	Watch         PreflightCheck `json:"watch"`
+}
+
+var errNotADirectory = errors.New("not a directory")
+
+// Preflight tests what the filesystem at the given path supports, by
+// performing each operation on files in a temporary directory, which is
+// removed again.
+func Preflight(fsType FilesystemType, path string) PreflightReport {
+	report := PreflightReport{
+		Path: path,
+		Type: fsType,
+	}
+
+	checked := path
+	for {
+		info, err := NewFilesystem(fsType, checked).Lstat(".")
+		if err == nil {
+			if !info.IsDir() {
+				report.Read = preflightResult(errNotADirectory)
+				report.CheckedPath = checked
+				return report
+			}
+			break
+		}
+		parent := filepath.Dir(checked)
+		if !IsNotExist(err) || parent == checked {
+			report.Read = preflightResult(err)
+			return report
+		}
+		checked = parent
+	}
+	report.Exists = checked == path
+	report.CheckedPath = checked
+
+	ffs := NewFilesystem(fsType, checked)
+	report.Usage, _ = ffs.Usage(".")
+	if fsType == FilesystemTypeBasic {
+		report.OSType = osFilesystemType(ffs.URI())
+	}
+
+	_, err := ffs.DirNames(".")
+	report.Read = preflightResult(err)
+
+	dir := fmt.Sprintf(".stpreflight-%d", time.Now().UnixNano())
+	if err := ffs.Mkdir(dir, 0700); err != nil {
+		// Nothing else can be tested without a place to do it.
+		failed := preflightResult(err)
+		report.Write, report.Rename, report.Permissions, report.Symlink, report.Xattr, report.Watch = failed, failed, failed, failed, failed, failed
+		return report
+	}
+	defer ffs.RemoveAll(dir)
+
+	name := filepath.Join(dir, "file")
+	report.Write = preflightResult(preflightWrite(ffs, name))
+	if report.Write.OK {
+		report.Permissions = preflightResult(preflightPermissions(ffs, name))
+	} else {
+		report.Permissions = report.Write
+	}
+
+	renamed := filepath.Join(dir, "Renamed")
+	report.Rename = preflightResult(ffs.Rename(name, renamed))
+	if report.Rename.OK {
+		if info, err := ffs.Lstat(renamed); err == nil {
+			upper, err := ffs.Lstat(filepath.Join(dir, "RENAMED"))
+			report.CaseSensitive = err != nil || !ffs.SameFile(info, upper)
+		}
+	}
+
+	report.Symlink = preflightResult(preflightSymlink(ffs, filepath.Join(dir, "link")))
+
+	if fsType == FilesystemTypeBasic {
+		report.Xattr = preflightResult(preflightXattr(filepath.Join(ffs.URI(), renamed)))
+	} else {
+		report.Xattr = preflightResult(errors.New("not supported"))
+	}
+
+	err = preflightWatch(ffs, dir)
+	report.Watch = preflightResult(err)
+	report.Watch.Transient = err == errInotifyLimits
+	return report
+}
+
+func preflightResult(err error) PreflightCheck {
+	if err != nil {
+		return PreflightCheck{Error: err.Error()}
+	}
+	return PreflightCheck{OK: true}
+}
+
+func preflightWrite(ffs Filesystem, name string) error {
+	const data = "syncthing preflight"
+	fd, err := ffs.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write([]byte(data)); err != nil {
+		fd.Close()
+		return err
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	fd, err = ffs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	bs, err := ioutil.ReadAll(fd)
+	if err != nil {
+		return err
+	}
+	if string(bs) != data {
+		return errors.New("read back different data than written")
+	}
+	return nil
+}
+
+func preflightPermissions(ffs Filesystem, name string) error {
+	for _, mode := range []FileMode{0600, 0644} {
+		if err := ffs.Chmod(name, mode); err != nil {
+			return err
+		}
+		info, err := ffs.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&ModePerm != mode {
+			return fmt.Errorf("permissions %v set as %v", mode, info.Mode()&ModePerm)
+		}
+	}
+	return nil
+}
+
+func preflightSymlink(ffs Filesystem, name string) error {
+	if !ffs.SymlinksSupported() {
+		return errors.New("not supported")
+	}
+	if err := ffs.CreateSymlink("target", name); err != nil {
+		return err
+	}
+	target, err := ffs.ReadSymlink(name)
+	if err != nil {
+		return err
+	}
+	if target != "target" {
+		return errors.New("symlink target not preserved")
+	}
+	return nil
+}
+
+type matchNothing struct{}
+
+func (matchNothing) ShouldIgnore(string) bool { return false }
+func (matchNothing) SkipIgnoredDirs() bool    { return true }
+
+func preflightWatch(ffs Filesystem, dir string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := ffs.Watch(dir, matchNothing{}, ctx, false)
+	return err
+}
@@ -0,0 +1,37 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build linux
+
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+func osFilesystemType(path string) string {
+	fsType, _ := statfsType(path)
+	return fsType.name
+}
+
+func preflightXattr(path string) error {
+	const name = "user.syncthing.preflight"
+	value := []byte("1")
+	if err := syscall.Setxattr(path, name, value, 0); err != nil {
+		return err
+	}
+	buf := make([]byte, 16)
+	n, err := syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(buf[:n], value) {
+		return errors.New("extended attribute not preserved")
+	}
+	return syscall.Removexattr(path, name)
+}
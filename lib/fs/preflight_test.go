@@ -0,0 +1,58 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-preflight-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := Preflight(FilesystemTypeBasic, dir)
+	if !report.Exists || report.CheckedPath != dir {
+		t.Errorf("Unexpected location in %+v", report)
+	}
+	for name, check := range map[string]PreflightCheck{"read": report.Read, "write": report.Write, "rename": report.Rename} {
+		if !check.OK {
+			t.Errorf("Check %s failed: %s", name, check.Error)
+		}
+	}
+	if runtime.GOOS != "windows" && !report.Permissions.OK {
+		t.Errorf("Check permissions failed: %s", report.Permissions.Error)
+	}
+	if report.Symlink.OK != NewFilesystem(FilesystemTypeBasic, dir).SymlinksSupported() {
+		t.Errorf("Unexpected symlink result %+v", report.Symlink)
+	}
+	if names, err := ioutil.ReadDir(dir); err != nil || len(names) != 0 {
+		t.Errorf("Preflight left files behind: %v, %v", names, err)
+	}
+
+	// A folder that doesn't exist yet is checked where it would be created
+	report = Preflight(FilesystemTypeBasic, filepath.Join(dir, "new", "folder"))
+	if report.Exists || report.CheckedPath != dir || !report.Write.OK {
+		t.Errorf("Unexpected result for missing folder %+v", report)
+	}
+
+	// A file is no place for a folder
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report = Preflight(FilesystemTypeBasic, file)
+	if report.Read.OK || report.Read.Error != errNotADirectory.Error() {
+		t.Errorf("Unexpected result for file %+v", report)
+	}
+}
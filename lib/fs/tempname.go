@@ -61,3 +61,15 @@ func TempNameWithPrefix(name, prefix string) string {
 func TempName(name string) string {
 	return TempNameWithPrefix(name, TempPrefix)
 }
+
+// PartialName returns the name the temporary file is kept under when the
+// file it was pulled for vanished before it was complete. Like temporary
+// files it's not scanned, but it doesn't expire.
+func PartialName(tempName string) string {
+	return strings.TrimSuffix(tempName, ".tmp") + ".partial"
+}
+
+// IsPartial is true if the file name is that of a kept partial file.
+func IsPartial(name string) bool {
+	return IsTemporary(name) && strings.HasSuffix(name, ".partial")
+}
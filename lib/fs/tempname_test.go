@@ -24,3 +24,13 @@ func TestLongTempFilename(t *testing.T) {
 		t.Fatal("Invalid short filename", TempName("short"))
 	}
 }
+
+func TestPartialName(t *testing.T) {
+	name := PartialName(TempName("dir/file"))
+	if !strings.HasSuffix(name, "file.partial") || !IsTemporary(name) || !IsPartial(name) {
+		t.Error("Invalid partial name", name)
+	}
+	if IsPartial(TempName("file")) || IsPartial("file.partial") {
+		t.Error("Not partial names taken as partial")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type conditionKind int
+
+const (
+	conditionLarger conditionKind = iota
+	conditionSmaller
+	conditionOlder
+	conditionNewer
+)
+
+// A condition restricts a pattern to files of a certain size or age, like
+// (?size>1G) or (?older-than:365d).
+type condition struct {
+	text string // as written, including the parentheses
+	kind conditionKind
+	size int64
+	age  time.Duration
+}
+
+var sizeUnits = map[string]float64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+var ageUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// isCondition returns true if the line starts with a condition.
+func isCondition(line string) bool {
+	return strings.HasPrefix(line, "(?size") || strings.HasPrefix(line, "(?older-than:") || strings.HasPrefix(line, "(?newer-than:")
+}
+
+// parseCondition parses the condition at the start of the line, returning
+// it and the rest of the line.
+func parseCondition(line string) (condition, string, error) {
+	end := strings.IndexByte(line, ')')
+	if end < 0 {
+		return condition{}, "", fmt.Errorf("unterminated condition")
+	}
+	c := condition{text: line[:end+1]}
+	expr := line[2:end]
+
+	var err error
+	switch {
+	case strings.HasPrefix(expr, "size>"):
+		c.kind = conditionLarger
+		c.size, err = parseSize(expr[len("size>"):])
+	case strings.HasPrefix(expr, "size<"):
+		c.kind = conditionSmaller
+		c.size, err = parseSize(expr[len("size<"):])
+	case strings.HasPrefix(expr, "older-than:"):
+		c.kind = conditionOlder
+		c.age, err = parseAge(expr[len("older-than:"):])
+	case strings.HasPrefix(expr, "newer-than:"):
+		c.kind = conditionNewer
+		c.age, err = parseAge(expr[len("newer-than:"):])
+	default:
+		err = fmt.Errorf("unknown condition %s", c.text)
+	}
+	if err != nil {
+		return condition{}, "", err
+	}
+	return c, line[end+1:], nil
+}
+
+// parseSize parses sizes like 1024, 500k, 1.5G or 2GiB, the units being
+// powers of 1024.
+func parseSize(s string) (int64, error) {
+	s = strings.ToLower(s)
+	num := strings.TrimRight(s, "bikmgt")
+	unit := strings.TrimSuffix(strings.TrimSuffix(s[len(num):], "b"), "i")
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	val, err := strconv.ParseFloat(num, 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(val * mult), nil
+}
+
+// parseAge parses ages like 90s, 12h, 30d or 1y.
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	unit, ok := ageUnits[strings.ToLower(s[len(s)-1:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid age %q, missing unit", s)
+	}
+	val, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || val < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(val * float64(unit)), nil
+}
+
+func (c condition) match(size int64, modTime, now time.Time) bool {
+	switch c.kind {
+	case conditionLarger:
+		return size > c.size
+	case conditionSmaller:
+		return size < c.size
+	case conditionOlder:
+		return now.Sub(modTime) > c.age
+	case conditionNewer:
+		return now.Sub(modTime) < c.age
+	}
+	return false
+}
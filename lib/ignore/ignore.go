@@ -34,10 +34,15 @@ type Pattern struct {
 	pattern string
 	match   glob.Glob
 	result  Result
+	conds   []condition // all must hold for the pattern to match
+	regexp  bool        // a regular expression rather than a glob
 }
 
 func (p Pattern) String() string {
 	ret := p.pattern
+	if p.regexp {
+		ret = regexpPrefix + ret
+	}
 	if p.result&resultInclude != resultInclude {
 		ret = "!" + ret
 	}
@@ -47,6 +52,9 @@ func (p Pattern) String() string {
 	if p.result&resultDeletable == resultDeletable {
 		ret = "(?d)" + ret
 	}
+	for i := len(p.conds) - 1; i >= 0; i-- {
+		ret = p.conds[i].text + ret
+	}
 	return ret
 }
 
@@ -83,10 +91,15 @@ type Matcher struct {
 	withCache       bool
 	matches         *cache
 	curHash         string
+	statPatterns    bool // some patterns have conditions on size or age
 	stop            chan struct{}
 	changeDetector  ChangeDetector
 	skipIgnoredDirs bool
-	mut             sync.Mutex
+
+	patternSetLookup func(name string) ([]string, bool)
+	usedSets         map[string]patternSet // included by the last parse
+
+	mut sync.Mutex
 }
 
 // An Option can be passed to New()
@@ -130,7 +143,7 @@ func (m *Matcher) Load(file string) error {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
-	if m.changeDetector.Seen(m.fs, file) && !m.changeDetector.Changed() {
+	if m.changeDetector.Seen(m.fs, file) && !m.changeDetector.Changed() && !m.patternSetsChangedLocked() {
 		return nil
 	}
 
@@ -159,11 +172,13 @@ func (m *Matcher) Parse(r io.Reader, file string) error {
 }
 
 func (m *Matcher) parseLocked(r io.Reader, file string) error {
-	lines, patterns, err := parseIgnoreFile(m.fs, r, file, m.changeDetector, make(map[string]struct{}))
+	sets := newPatternSets(m.patternSetLookup)
+	lines, patterns, err := parseIgnoreFile(m.fs, r, file, m.changeDetector, make(map[string]struct{}), sets)
 	// Error is saved and returned at the end. We process the patterns
 	// (possibly blank) anyway.
 
 	m.lines = lines
+	m.usedSets = sets.used
 
 	newHash := hashPatterns(patterns)
 	if newHash == m.curHash {
@@ -172,10 +187,13 @@ func (m *Matcher) parseLocked(r io.Reader, file string) error {
 	}
 
 	m.skipIgnoredDirs = true
+	m.statPatterns = false
 	for _, p := range patterns {
 		if !p.result.IsIgnored() {
 			m.skipIgnoredDirs = false
-			break
+		}
+		if len(p.conds) > 0 {
+			m.statPatterns = true
 		}
 	}
 
@@ -213,26 +231,99 @@ func (m *Matcher) Match(file string) (result Result) {
 		}()
 	}
 
+	if i := m.matchLocked(file, nil); i >= 0 {
+		return m.patterns[i].result
+	}
+
+	// Default to not matching.
+	return resultNotMatched
+}
+
+type fileStat struct {
+	size    int64
+	modTime time.Time
+	now     time.Time
+}
+
+// MatchStat is like Match for a file of the given size and modification
+// time, thus also evaluating the patterns with conditions on size or age,
+// which Match skips. It is meant for files, not directories.
+func (m *Matcher) MatchStat(file string, size int64, modTime time.Time) Result {
+	if !m.HasStatPatterns() {
+		return m.Match(file)
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	stat := &fileStat{size: size, modTime: modTime, now: time.Now()}
+	if i := m.matchLocked(file, stat); i >= 0 {
+		return m.patterns[i].result
+	}
+	return resultNotMatched
+}
+
+// HasStatPatterns returns true if some patterns have conditions on size or
+// age, i.e. MatchStat may give another result than Match.
+func (m *Matcher) HasStatPatterns() bool {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.statPatterns
+}
+
+// MatchPattern returns the same result as Match, bypassing the cache, and
+// the pattern which gave it, or the empty string if none matched.
+func (m *Matcher) MatchPattern(file string) (Result, string) {
+	if file == "." {
+		return resultNotMatched, ""
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if i := m.matchLocked(file, nil); i >= 0 {
+		return m.patterns[i].result, m.patterns[i].String()
+	}
+	return resultNotMatched, ""
+}
+
+// matchLocked returns the index of the first pattern matching the file, or
+// -1 if there is none. Patterns with conditions are skipped when stat is
+// nil.
+func (m *Matcher) matchLocked(file string, stat *fileStat) int {
 	// Check all the patterns for a match.
 	file = filepath.ToSlash(file)
 	var lowercaseFile string
-	for _, pattern := range m.patterns {
+	for i, pattern := range m.patterns {
+		if len(pattern.conds) > 0 && !pattern.matchStat(stat) {
+			continue
+		}
 		if pattern.result.IsCaseFolded() {
 			if lowercaseFile == "" {
 				lowercaseFile = strings.ToLower(file)
 			}
 			if pattern.match.Match(lowercaseFile) {
-				return pattern.result
+				return i
 			}
 		} else {
 			if pattern.match.Match(file) {
-				return pattern.result
+				return i
 			}
 		}
 	}
+	return -1
+}
 
-	// Default to not matching.
-	return resultNotMatched
+func (p Pattern) matchStat(stat *fileStat) bool {
+	if stat == nil {
+		return false
+	}
+	for _, c := range p.conds {
+		if !c.match(stat.size, stat.modTime, stat.now) {
+			return false
+		}
+	}
+	return true
 }
 
 // Lines return a list of the unprocessed lines in .stignore at last load
@@ -330,7 +421,7 @@ func loadIgnoreFile(fs fs.Filesystem, file string, cd ChangeDetector) (fs.File,
 	return fd, info, err
 }
 
-func loadParseIncludeFile(filesystem fs.Filesystem, file string, cd ChangeDetector, linesSeen map[string]struct{}) ([]Pattern, error) {
+func loadParseIncludeFile(filesystem fs.Filesystem, file string, cd ChangeDetector, linesSeen map[string]struct{}, sets *patternSets) ([]Pattern, error) {
 	// Allow escaping the folders filesystem.
 	// TODO: Deprecate, somehow?
 	if filesystem.Type() == fs.FilesystemTypeBasic {
@@ -354,11 +445,28 @@ func loadParseIncludeFile(filesystem fs.Filesystem, file string, cd ChangeDetect
 
 	cd.Remember(filesystem, file, info.ModTime())
 
-	_, patterns, err := parseIgnoreFile(filesystem, fd, file, cd, linesSeen)
+	_, patterns, err := parseIgnoreFile(filesystem, fd, file, cd, linesSeen, sets)
 	return patterns, err
 }
 
-func parseIgnoreFile(fs fs.Filesystem, fd io.Reader, currentFile string, cd ChangeDetector, linesSeen map[string]struct{}) ([]string, []Pattern, error) {
+func parseIncludePatternSet(filesystem fs.Filesystem, line, currentFile string, cd ChangeDetector, linesSeen map[string]struct{}, sets *patternSets) ([]Pattern, error) {
+	name, err := parsePatternSetName(line)
+	if err != nil {
+		return nil, err
+	}
+	setPatterns, err := sets.get(name)
+	if err != nil {
+		return nil, err
+	}
+	// Includes in the set are relative to the including file.
+	_, patterns, err := parseIgnoreFile(filesystem, strings.NewReader(strings.Join(setPatterns, "\n")), currentFile, cd, linesSeen, sets)
+	if err != nil {
+		return nil, fmt.Errorf("ignore pattern set %q: %v", name, err)
+	}
+	return patterns, nil
+}
+
+func parseIgnoreFile(fs fs.Filesystem, fd io.Reader, currentFile string, cd ChangeDetector, linesSeen map[string]struct{}, sets *patternSets) ([]string, []Pattern, error) {
 	var lines []string
 	var patterns []Pattern
 
@@ -373,10 +481,19 @@ func parseIgnoreFile(fs fs.Filesystem, fd io.Reader, currentFile string, cd Chan
 		}
 
 		// Allow prefixes to be specified in any order, but only once.
+		// Conditions may be given several times, all having to hold.
+		// The regular expression prefix ends the prefixes.
 		var seenPrefix [3]bool
 
 		for {
-			if strings.HasPrefix(line, "!") && !seenPrefix[0] {
+			if isCondition(line) {
+				cond, rest, err := parseCondition(line)
+				if err != nil {
+					return fmt.Errorf("invalid pattern %q in ignore file (%v)", line, err)
+				}
+				pattern.conds = append(pattern.conds, cond)
+				line = rest
+			} else if strings.HasPrefix(line, "!") && !seenPrefix[0] {
 				seenPrefix[0] = true
 				line = line[1:]
 				pattern.result ^= resultInclude
@@ -388,11 +505,28 @@ func parseIgnoreFile(fs fs.Filesystem, fd io.Reader, currentFile string, cd Chan
 				seenPrefix[2] = true
 				pattern.result |= resultDeletable
 				line = line[4:]
+			} else if strings.HasPrefix(line, regexpPrefix) {
+				pattern.regexp = true
+				line = line[len(regexpPrefix):]
+				break
 			} else {
 				break
 			}
 		}
 
+		if pattern.regexp {
+			// Case folding is up to the expression, as lower casing it
+			// would change its meaning.
+			match, err := compileRegexp(line, pattern.result.IsCaseFolded())
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q in ignore file (%v)", line, err)
+			}
+			pattern.pattern = line
+			pattern.match = match
+			patterns = append(patterns, pattern)
+			return nil
+		}
+
 		if pattern.result.IsCaseFolded() {
 			line = strings.ToLower(line)
 		}
@@ -458,13 +592,27 @@ func parseIgnoreFile(fs fs.Filesystem, fd io.Reader, currentFile string, cd Chan
 			continue
 		}
 
+		if isRegexpPattern(line) {
+			// Taken as is, without converting backslashes or adding the
+			// variants for directory contents.
+			if err := addPattern(line); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
 		line = filepath.ToSlash(line)
 		switch {
+		case strings.HasPrefix(line, includeConfigPrefix):
+			var setPatterns []Pattern
+			if setPatterns, err = parseIncludePatternSet(fs, line, currentFile, cd, linesSeen, sets); err == nil {
+				patterns = append(patterns, setPatterns...)
+			}
 		case strings.HasPrefix(line, "#include"):
 			includeRel := strings.TrimSpace(line[len("#include "):])
 			includeFile := filepath.Join(filepath.Dir(currentFile), includeRel)
 			var includePatterns []Pattern
-			if includePatterns, err = loadParseIncludeFile(fs, includeFile, cd, linesSeen); err == nil {
+			if includePatterns, err = loadParseIncludeFile(fs, includeFile, cd, linesSeen, sets); err == nil {
 				patterns = append(patterns, includePatterns...)
 			} else {
 				// Wrap the error, as if the include does not exist, we get a
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 	"time"
@@ -1079,3 +1080,213 @@ func TestSpecialChars(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchPattern(t *testing.T) {
+	pats := New(fs.NewFilesystem(fs.FilesystemTypeBasic, "."), WithCache(true))
+
+	stignore := `!/keep.log
+(?d)*.log
+/build`
+	if err := pats.Parse(bytes.NewBufferString(stignore), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file      string
+		pattern   string
+		ignored   bool
+		deletable bool
+	}{
+		{"keep.log", "!/keep.log", false, false},
+		{"other.log", "(?d)*.log", true, true},
+		{filepath.FromSlash("build/out"), "/build/**", true, false},
+		{"src", "", false, false},
+	}
+
+	for _, tc := range cases {
+		res, pattern := pats.MatchPattern(tc.file)
+		if pattern != tc.pattern || res.IsIgnored() != tc.ignored || res.IsDeletable() != tc.deletable {
+			t.Errorf("%q: got %q (ignored %v, deletable %v), expected %q", tc.file, pattern, res.IsIgnored(), res.IsDeletable(), tc.pattern)
+		}
+		if res != pats.Match(tc.file) {
+			t.Errorf("%q: MatchPattern and Match disagree", tc.file)
+		}
+	}
+}
+
+func TestStatConditions(t *testing.T) {
+	pats := New(fs.NewFilesystem(fs.FilesystemTypeBasic, "."), WithCache(true))
+
+	stignore := `!(?size<1k)*.iso
+(?size>1G)*
+(?older-than:365d)(?d)/archive
+(?size>=1)foo`
+	if err := pats.Parse(bytes.NewBufferString(stignore), ".stignore"); err == nil {
+		t.Fatal("Expected error for invalid condition")
+	}
+
+	stignore = `!(?size<1k)*.iso
+*.iso
+(?size>1G)*
+(?older-than:365d)(?d)/archive
+(?newer-than:1h)(?size>1.5MiB)/incoming`
+	if err := pats.Parse(bytes.NewBufferString(stignore), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	if !pats.HasStatPatterns() {
+		t.Fatal("Expected stat patterns")
+	}
+
+	now := time.Now()
+	old := now.Add(-400 * 24 * time.Hour)
+	cases := []struct {
+		file      string
+		size      int64
+		modTime   time.Time
+		ignored   bool
+		deletable bool
+	}{
+		{"small.iso", 100, now, false, false},
+		{"big.iso", 100 << 20, now, true, false},
+		{"huge.bin", 2 << 30, now, true, false},
+		{"normal.bin", 100 << 20, now, false, false},
+		{filepath.FromSlash("archive/old.txt"), 10, old, true, true},
+		{filepath.FromSlash("archive/new.txt"), 10, now, false, false},
+		{filepath.FromSlash("incoming/part"), 2 << 20, now, true, false},
+		{filepath.FromSlash("incoming/small"), 1 << 20, now, false, false},
+		{filepath.FromSlash("incoming/stale"), 2 << 20, old, false, false},
+	}
+	for _, tc := range cases {
+		res := pats.MatchStat(tc.file, tc.size, tc.modTime)
+		if res.IsIgnored() != tc.ignored || res.IsDeletable() != tc.deletable {
+			t.Errorf("%q: got ignored %v, deletable %v, expected %v, %v", tc.file, res.IsIgnored(), res.IsDeletable(), tc.ignored, tc.deletable)
+		}
+	}
+
+	// Without size and age, the patterns with conditions don't apply.
+	if !pats.Match("small.iso").IsIgnored() {
+		t.Error("small.iso should be ignored by name only")
+	}
+	if pats.Match("huge.bin").IsIgnored() {
+		t.Error("huge.bin should not be ignored by name only")
+	}
+
+	expected := []string{"(?size<1k)!*.iso", "(?size<1k)!**/*.iso"}
+	if patterns := pats.Patterns(); patterns[0] != expected[0] || patterns[1] != expected[1] {
+		t.Errorf("Got patterns %v, expected to start with %v", patterns, expected)
+	}
+}
+
+func TestRegexpPatterns(t *testing.T) {
+	pats := New(fs.NewFilesystem(fs.FilesystemTypeBasic, "."), WithCache(true))
+
+	if err := pats.Parse(bytes.NewBufferString(`(?re)foo[`), ".stignore"); err == nil {
+		t.Fatal("Expected error for invalid regular expression")
+	}
+
+	stignore := `!(?re)(.*/)?keep-\d+\.log
+(?re)(.*/)?[^/]+-\d{8}\.log
+(?i)(?re)build/(debug|release)
+(?d)(?re)cache\d?
+*.tmp`
+	if err := pats.Parse(bytes.NewBufferString(stignore), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file      string
+		ignored   bool
+		deletable bool
+	}{
+		{"app-20190102.log", true, false},
+		{filepath.FromSlash("sub/dir/app-20190102.log"), true, false},
+		{"app-2019.log", false, false},
+		{filepath.FromSlash("sub/keep-20190102.log"), false, false},
+		{filepath.FromSlash("Build/Release/app.exe"), true, false},
+		{filepath.FromSlash("build/other/app.exe"), false, false},
+		{filepath.FromSlash("sub/build/debug"), false, false},
+		{"cache1", true, true},
+		{filepath.FromSlash("cache/data"), true, true},
+		{"cache12", false, false},
+		{"mycache", false, false},
+		{"x.tmp", true, false},
+	}
+	for _, tc := range cases {
+		res := pats.Match(tc.file)
+		if res.IsIgnored() != tc.ignored || res.IsDeletable() != tc.deletable {
+			t.Errorf("%q: got ignored %v, deletable %v, expected %v, %v", tc.file, res.IsIgnored(), res.IsDeletable(), tc.ignored, tc.deletable)
+		}
+	}
+
+	// Expressions are kept as written.
+	expected := []string{`!(?re)(.*/)?keep-\d+\.log`, `(?re)(.*/)?[^/]+-\d{8}\.log`, `(?i)(?re)build/(debug|release)`, `(?d)(?re)cache\d?`}
+	if patterns := pats.Patterns(); !reflect.DeepEqual(patterns[:4], expected) {
+		t.Errorf("got patterns %q, expected %q", patterns[:4], expected)
+	}
+}
+
+func TestPatternSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stignore := `#include-config:media-junk
+#include-config:media-junk
+*.tmp`
+	if err := ioutil.WriteFile(filepath.Join(dir, ".stignore"), []byte(stignore), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	sets := map[string][]string{
+		"media-junk": {"Thumbs.db", "(?i)*.thm", "#include-config:media-junk"},
+	}
+	lookup := func(name string) ([]string, bool) {
+		set, ok := sets[name]
+		return set, ok
+	}
+	pats := New(fs.NewFilesystem(fs.FilesystemTypeBasic, dir), WithCache(true), WithPatternSets(lookup))
+	if err := pats.Load(".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, ignored := range map[string]bool{
+		"Thumbs.db":                         true,
+		filepath.FromSlash("dir/Thumbs.db"): true,
+		"IMG_1.THM":                         true,
+		"x.tmp":                             true,
+		"IMG_1.jpg":                         false,
+	} {
+		if res := pats.Match(file); res.IsIgnored() != ignored {
+			t.Errorf("%q: got ignored %v, expected %v", file, res.IsIgnored(), ignored)
+		}
+	}
+	if names := pats.PatternSets(); !reflect.DeepEqual(names, []string{"media-junk"}) {
+		t.Errorf("got pattern sets %v, expected [media-junk]", names)
+	}
+
+	// Changing the set reloads the patterns, even though the ignore file
+	// didn't change.
+	sets["media-junk"] = []string{".DS_Store"}
+	if err := pats.Load(".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	if pats.Match("Thumbs.db").IsIgnored() || !pats.Match(".DS_Store").IsIgnored() {
+		t.Error("changed pattern set not applied")
+	}
+
+	// A removed set is an error, like a missing include file.
+	delete(sets, "media-junk")
+	if err := pats.Load(".stignore"); err == nil || fs.IsNotExist(err) {
+		t.Errorf("expected an error for an unknown pattern set, got %v", err)
+	}
+	if names := pats.PatternSets(); !reflect.DeepEqual(names, []string{"media-junk"}) {
+		t.Errorf("got pattern sets %v, expected [media-junk]", names)
+	}
+
+	// Without the lookup no set exists.
+	if err := New(fs.NewFilesystem(fs.FilesystemTypeBasic, dir)).Load(".stignore"); err == nil {
+		t.Error("expected an error without pattern sets")
+	}
+}
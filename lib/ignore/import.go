@@ -0,0 +1,205 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The formats of other sync tools that ImportPatterns reads.
+const (
+	FormatResilio   = "resilio"   // An IgnoreList of Resilio Sync
+	FormatUnison    = "unison"    // A Unison profile, its ignore and path preferences
+	FormatRsync     = "rsync"     // An rsync filter file, as given to --filter="merge ..." or --exclude-from
+	FormatSelective = "selective" // The paths synced with selective sync, one per line
+)
+
+// ImportPatterns reads ignore rules in the format of another sync tool and
+// returns the equivalent lines of an .stignore. Rules that can't be
+// converted are left out, and described by the returned warnings.
+func ImportPatterns(format string, r io.Reader) ([]string, []string, error) {
+	var convert func(lines []string) ([]string, []string)
+	switch format {
+	case FormatResilio:
+		convert = importResilio
+	case FormatUnison:
+		convert = importUnison
+	case FormatRsync:
+		convert = importRsync
+	case FormatSelective:
+		convert = func(lines []string) ([]string, []string) {
+			return SelectivePatterns(lines), nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown import format %q", format)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff") // Byte order mark
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	patterns, warnings := convert(lines)
+	return patterns, warnings, nil
+}
+
+// SelectivePatterns returns the lines of an .stignore that ignore everything
+// but the given paths, which are relative to the folder root.
+func SelectivePatterns(paths []string) []string {
+	var patterns []string
+	for _, path := range paths {
+		path = strings.Trim(strings.Replace(path, "\\", "/", -1), "/")
+		if path == "" || strings.HasPrefix(path, "#") {
+			continue
+		}
+		patterns = append(patterns, "!/"+path)
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	return append(patterns, "*")
+}
+
+// importResilio converts an IgnoreList, which has one glob per line. Globs
+// with a slash are relative to the folder root, the others match at any
+// depth, the same as .stignore patterns without a leading slash.
+func importResilio(lines []string) ([]string, []string) {
+	var patterns []string
+	for _, line := range lines {
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			patterns = append(patterns, line)
+		case strings.Contains(strings.TrimSuffix(line, "/"), "/"):
+			patterns = append(patterns, "/"+strings.Trim(line, "/"))
+		default:
+			patterns = append(patterns, strings.TrimSuffix(line, "/"))
+		}
+	}
+	return patterns, nil
+}
+
+// importUnison converts the ignore, ignorenot and path preferences of a
+// Unison profile. Unison applies ignorenot over ignore regardless of their
+// order, so the exceptions come first, and the paths to sync last.
+func importUnison(lines []string) ([]string, []string) {
+	var includes, excludes, paths, warnings []string
+	for i, line := range lines {
+		eq := strings.IndexByte(line, '=')
+		if line == "" || strings.HasPrefix(line, "#") || eq < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+
+		switch key {
+		case "path":
+			paths = append(paths, value)
+			continue
+		case "ignore", "ignorenot":
+		default:
+			continue
+		}
+
+		pattern, err := unisonPattern(value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: %v", i+1, err))
+			continue
+		}
+		if key == "ignorenot" {
+			includes = append(includes, "!"+pattern)
+		} else {
+			excludes = append(excludes, pattern)
+		}
+	}
+
+	patterns := append(includes, excludes...)
+	return append(patterns, SelectivePatterns(paths)...), warnings
+}
+
+func unisonPattern(value string) (string, error) {
+	sp := strings.IndexByte(value, ' ')
+	if sp < 0 {
+		return "", fmt.Errorf("no pattern in %q", value)
+	}
+	kind, pattern := value[:sp], strings.TrimSpace(value[sp+1:])
+	switch kind {
+	case "Name":
+		return pattern, nil
+	case "Path", "BelowPath":
+		return "/" + strings.Trim(pattern, "/"), nil
+	case "Regex":
+		// Unison matches the expression against the whole path, as we do.
+		return regexpPrefix + pattern, nil
+	default:
+		return "", fmt.Errorf("unsupported pattern type %q", kind)
+	}
+}
+
+// importRsync converts the include and exclude rules of an rsync filter
+// file. Like .stignore patterns, the first rule that matches wins. Lines
+// without a rule are patterns to exclude, as in a file for --exclude-from.
+func importRsync(lines []string) ([]string, []string) {
+	var patterns, warnings []string
+	for i, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		var rule, pattern string
+		if sp := strings.IndexAny(line, " _"); sp > 0 {
+			rule, pattern = line[:sp], line[sp+1:]
+		} else {
+			rule = line
+		}
+		if !isRsyncRule(rule) {
+			rule, pattern = "-", line
+		}
+
+		switch rule {
+		case "-", "exclude":
+			patterns = append(patterns, rsyncPattern(pattern))
+		case "+", "include":
+			patterns = append(patterns, "!"+rsyncPattern(pattern))
+		default:
+			// Merges, clears, protect rules and the like, or modifiers.
+			warnings = append(warnings, fmt.Sprintf("line %d: unsupported rule %q", i+1, line))
+		}
+	}
+	return patterns, warnings
+}
+
+// isRsyncRule returns whether the first word of a line is the name of a
+// filter rule, short or long, with or without modifiers.
+func isRsyncRule(rule string) bool {
+	if i := strings.IndexByte(rule, ','); i > 0 {
+		rule = rule[:i]
+	}
+	switch rule {
+	case "exclude", "include", "merge", "dir-merge", "hide", "show", "protect", "risk", "clear":
+		return true
+	}
+	return strings.IndexByte("-+.:HSPR!", rule[0]) >= 0 && strings.Trim(rule[1:], "/!Cenrswx+-,") == ""
+}
+
+// rsyncPattern converts the pattern of a rule, where a leading slash
+// anchors it to the root of the transfer, and a trailing slash, which we
+// can't express, matches only directories.
+func rsyncPattern(pattern string) string {
+	pattern = strings.TrimSuffix(pattern, "/***")
+	pattern = strings.TrimSuffix(pattern, "/")
+	return pattern
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportPatterns(t *testing.T) {
+	cases := []struct {
+		format   string
+		input    string
+		patterns []string
+		warnings int
+	}{
+		{
+			FormatResilio,
+			"\ufeff.DS_Store\n*.tmp\nbuild/\ndocs/drafts/\n",
+			[]string{".DS_Store", "*.tmp", "build", "/docs/drafts"},
+			0,
+		},
+		{
+			FormatUnison,
+			"root = /home/user\nignore = Name *.tmp\nignore = Path src/gen\nignore = Regex .*\\.bak\nignorenot = Name keep.tmp\nignore = Foo bar\npath = src\npath = docs\n",
+			[]string{"!keep.tmp", "*.tmp", "/src/gen", "(?re).*\\.bak", "!/src", "!/docs", "*"},
+			1,
+		},
+		{
+			FormatRsync,
+			"# comment\n+ /keep/\n- *.o\nexclude /cache/***\n: .rsync-filter\n",
+			[]string{"!/keep", "*.o", "/cache"},
+			1,
+		},
+		{
+			// As given to --exclude-from, with bare patterns.
+			FormatRsync,
+			"*.tmp\n/build/\nmy_notes.txt\nsome dir/***\n+ keep.tmp\n",
+			[]string{"*.tmp", "/build", "my_notes.txt", "some dir", "!keep.tmp"},
+			0,
+		},
+		{
+			FormatSelective,
+			"Photos/2019\n\\Music\\\n\n",
+			[]string{"!/Photos/2019", "!/Music", "*"},
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		patterns, warnings, err := ImportPatterns(tc.format, strings.NewReader(tc.input))
+		if err != nil {
+			t.Fatal(tc.format, err)
+		}
+		if !reflect.DeepEqual(patterns, tc.patterns) {
+			t.Errorf("%s: unexpected patterns %q", tc.format, patterns)
+		}
+		if len(warnings) != tc.warnings {
+			t.Errorf("%s: unexpected warnings %q", tc.format, warnings)
+		}
+
+		// The result parses.
+		pats := New(nil)
+		if err := pats.Parse(strings.NewReader(strings.Join(patterns, "\n")), ".stignore"); err != nil {
+			t.Errorf("%s: parsing result: %v", tc.format, err)
+		}
+	}
+
+	if _, _, err := ImportPatterns("dropbox", strings.NewReader("")); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestSelectivePatterns(t *testing.T) {
+	pats := New(nil)
+	if err := pats.Parse(strings.NewReader(strings.Join(SelectivePatterns([]string{"a/b", "c"}), "\n")), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+	for file, ignored := range map[string]bool{
+		"a/b":   false,
+		"a/b/x": false,
+		"c":     false,
+		"a/x":   true,
+		"d":     true,
+	} {
+		if res := pats.Match(file).IsIgnored(); res != ignored {
+			t.Errorf("Match(%q) ignored %v, expected %v", file, res, ignored)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The directive including a pattern set, like #include-config:media-junk.
+const includeConfigPrefix = "#include-config:"
+
+// WithPatternSets sets the lookup of the named pattern sets that ignore
+// files can include. Without it, including a pattern set is an error.
+func WithPatternSets(lookup func(name string) ([]string, bool)) Option {
+	return func(m *Matcher) {
+		m.patternSetLookup = lookup
+	}
+}
+
+// A patternSet is a pattern set as it was when included.
+type patternSet struct {
+	patterns []string
+	ok       bool
+}
+
+// patternSets resolves the pattern sets included while parsing, recording
+// them to be able to tell later on whether they changed.
+type patternSets struct {
+	lookup func(name string) ([]string, bool)
+	used   map[string]patternSet
+}
+
+func newPatternSets(lookup func(name string) ([]string, bool)) *patternSets {
+	return &patternSets{
+		lookup: lookup,
+		used:   make(map[string]patternSet),
+	}
+}
+
+func (s *patternSets) get(name string) ([]string, error) {
+	var set patternSet
+	if s.lookup != nil {
+		set.patterns, set.ok = s.lookup(name)
+	}
+	s.used[name] = set
+	if !set.ok {
+		return nil, fmt.Errorf("unknown ignore pattern set %q", name)
+	}
+	return set.patterns, nil
+}
+
+// patternSetsChangedLocked returns true if any of the pattern sets included
+// by the last parse changed since.
+func (m *Matcher) patternSetsChangedLocked() bool {
+	for name, set := range m.usedSets {
+		var cur patternSet
+		if m.patternSetLookup != nil {
+			cur.patterns, cur.ok = m.patternSetLookup(name)
+		}
+		if cur.ok != set.ok || !equalStrings(cur.patterns, set.patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// PatternSets returns the names of the pattern sets included by the ignore
+// files, including those that don't exist.
+func (m *Matcher) PatternSets() []string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	names := make([]string, 0, len(m.usedSets))
+	for name := range m.usedSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parsePatternSetName(line string) (string, error) {
+	name := strings.TrimSpace(line[len(includeConfigPrefix):])
+	if name == "" {
+		return "", fmt.Errorf("missing pattern set name in %q", line)
+	}
+	return name, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package ignore
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The prefix of patterns that are regular expressions rather than globs,
+// like (?re)(^|.*/)backup-\d{8}\.tar. It must come last among the prefixes,
+// as the rest of the line is taken as is.
+const regexpPrefix = "(?re)"
+
+// Compiled expressions are kept across reloads of the ignore files, up to
+// this many.
+const maxCachedRegexps = 1000
+
+var (
+	regexpCache    = make(map[string]*regexp.Regexp)
+	regexpCacheMut = sync.NewMutex()
+)
+
+// regexpMatcher matches the paths which, or a parent directory of which,
+// the expression matches in full. That way an expression for a directory
+// also matches its contents, the same as a glob does.
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (r regexpMatcher) Match(file string) bool {
+	if r.re.MatchString(file) {
+		return true
+	}
+	for i := strings.IndexByte(file, '/'); i >= 0; i = nextSlash(file, i) {
+		if r.re.MatchString(file[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func nextSlash(file string, i int) int {
+	if j := strings.IndexByte(file[i+1:], '/'); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// isRegexpPattern returns true if the line, after any other prefixes, has
+// the regular expression prefix.
+func isRegexpPattern(line string) bool {
+	for {
+		switch {
+		case strings.HasPrefix(line, regexpPrefix):
+			return true
+		case strings.HasPrefix(line, "!"):
+			line = line[1:]
+		case strings.HasPrefix(line, "(?i)"), strings.HasPrefix(line, "(?d)"):
+			line = line[4:]
+		case isCondition(line):
+			_, rest, err := parseCondition(line)
+			if err != nil {
+				return false
+			}
+			line = rest
+		default:
+			return false
+		}
+	}
+}
+
+// compileRegexp returns the compiled expression, anchored to match whole
+// paths, from the cache if it was compiled before.
+func compileRegexp(expr string, foldCase bool) (regexpMatcher, error) {
+	expr = "^(?:" + expr + ")$"
+	if foldCase {
+		expr = "(?i)" + expr
+	}
+
+	regexpCacheMut.Lock()
+	defer regexpCacheMut.Unlock()
+
+	if re, ok := regexpCache[expr]; ok {
+		return regexpMatcher{re}, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return regexpMatcher{}, err
+	}
+	if len(regexpCache) >= maxCachedRegexps {
+		regexpCache = make(map[string]*regexp.Regexp)
+	}
+	regexpCache[expr] = re
+	return regexpMatcher{re}, nil
+}
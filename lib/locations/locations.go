@@ -32,6 +32,8 @@ const (
 	CsrfTokens    LocationEnum = "csrfTokens"
 	PanicLog      LocationEnum = "panicLog"
 	AuditLog      LocationEnum = "auditLog"
+	EventJournal  LocationEnum = "eventJournal"
+	Warnings      LocationEnum = "warnings"
 	GUIAssets     LocationEnum = "GUIAssets"
 	DefFolder     LocationEnum = "defFolder"
 )
@@ -85,6 +87,8 @@ var locationTemplates = map[LocationEnum]string{
 	CsrfTokens:    "${config}/csrftokens.txt",
 	PanicLog:      "${config}/panic-${timestamp}.log",
 	AuditLog:      "${config}/audit-${timestamp}.log",
+	EventJournal:  "${config}/events.log",
+	Warnings:      "${config}/warnings.json",
 	GUIAssets:     "${config}/gui",
 	DefFolder:     "${home}/Sync",
 }
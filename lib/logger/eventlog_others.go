@@ -0,0 +1,13 @@
+// Copyright (C) 2019 Jakob Borg. All rights reserved. Use of this source code
+// is governed by an MIT-style license that can be found in the LICENSE file.
+
+// +build !windows
+
+package logger
+
+import "errors"
+
+// AddEventLogSink fails, there being no Windows Event Log but on Windows.
+func AddEventLogSink(l Logger, level LogLevel) error {
+	return errors.New("the Windows Event Log is only available on Windows")
+}
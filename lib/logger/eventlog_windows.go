@@ -0,0 +1,45 @@
+// Copyright (C) 2019 Jakob Borg. All rights reserved. Use of this source code
+// is governed by an MIT-style license that can be found in the LICENSE file.
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// AddEventLogSink sends the messages of the logger at the level and above to
+// the Windows Event Log, as events of a source named after the executable.
+// Registering the source takes administrator rights once; without it the
+// events are logged, but Event Viewer doesn't know how to show them.
+func AddEventLogSink(l Logger, level LogLevel) error {
+	source := strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+	// Fails when the source is already registered, or without the rights
+	// to, neither of which keeps us from logging.
+	eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+
+	el, err := eventlog.Open(source)
+	if err != nil {
+		return err
+	}
+	queue := make(chan func(), sinkQueueLen)
+	go func() {
+		for f := range queue {
+			f()
+		}
+	}()
+	l.AddHandler(level, func(level LogLevel, msg string) {
+		f := func() { el.Info(1, msg) }
+		if level == LevelWarn {
+			f = func() { el.Warning(1, msg) }
+		}
+		select {
+		case queue <- f:
+		default:
+		}
+	})
+	return nil
+}
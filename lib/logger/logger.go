@@ -5,6 +5,7 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,6 +29,14 @@ const (
 	NumLevels
 )
 
+var levelPrefixes = [NumLevels]string{"DEBUG: ", "VERBOSE: ", "INFO: ", "WARNING: "}
+
+// The formats of log lines.
+const (
+	FormatText = "text" // The level and message, after the prefix and flags
+	FormatJSON = "json" // A JSON object per line, see jsonLine
+)
+
 const (
 	DefaultFlags = log.Ltime
 	DebugFlags   = log.Ltime | log.Ldate | log.Lmicroseconds | log.Lshortfile
@@ -40,6 +49,8 @@ type Logger interface {
 	AddHandler(level LogLevel, h MessageHandler)
 	SetFlags(flag int)
 	SetPrefix(prefix string)
+	SetOutput(w io.Writer)
+	SetFormat(format string) error
 	Debugln(vals ...interface{})
 	Debugf(format string, vals ...interface{})
 	Verboseln(vals ...interface{})
@@ -53,10 +64,13 @@ type Logger interface {
 	Facilities() map[string]string
 	FacilityDebugging() []string
 	NewFacility(facility, description string) Logger
+	With(folder, device string) Logger
 }
 
 type logger struct {
 	logger     *log.Logger
+	w          io.Writer // where the logger writes, for the JSON format
+	format     string
 	handlers   [NumLevels][]MessageHandler
 	facilities map[string]string   // facility name => description
 	debug      map[string]struct{} // only facility names with debugging enabled
@@ -78,6 +92,8 @@ func New() Logger {
 func newLogger(w io.Writer) Logger {
 	return &logger{
 		logger:     log.New(w, "", DefaultFlags),
+		w:          w,
+		format:     FormatText,
 		facilities: make(map[string]string),
 		debug:      make(map[string]struct{}),
 	}
@@ -101,6 +117,28 @@ func (l *logger) SetPrefix(prefix string) {
 	l.logger.SetPrefix(prefix)
 }
 
+// See log.SetOutput
+func (l *logger) SetOutput(w io.Writer) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.w = controlStripper{w}
+	l.logger.SetOutput(l.w)
+}
+
+// SetFormat sets the format of the lines logged from now on, FormatText or
+// FormatJSON.
+func (l *logger) SetFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	l.mut.Lock()
+	l.format = format
+	l.mut.Unlock()
+	return nil
+}
+
 func (l *logger) callHandlers(level LogLevel, s string) {
 	for ll := LevelDebug; ll <= level; ll++ {
 		for _, h := range l.handlers[ll] {
@@ -109,82 +147,64 @@ func (l *logger) callHandlers(level LogLevel, s string) {
 	}
 }
 
-// Debugln logs a line with a DEBUG prefix.
-func (l *logger) Debugln(vals ...interface{}) {
-	l.debugln(3, vals...)
+// The fields of a line besides the message, logged in the JSON format.
+type fields struct {
+	facility string
+	folder   string
+	device   string
 }
-func (l *logger) debugln(level int, vals ...interface{}) {
-	s := fmt.Sprintln(vals...)
+
+// output writes the line, in the format of the logger, and passes it to
+// the handlers. The calldepth is that of the caller's caller.
+func (l *logger) output(calldepth int, level LogLevel, f fields, s string) {
 	l.mut.Lock()
 	defer l.mut.Unlock()
-	l.logger.Output(level, "DEBUG: "+s)
-	l.callHandlers(LevelDebug, s)
+	if l.format == FormatJSON {
+		l.writeJSON(level, f, s)
+	} else {
+		l.logger.Output(calldepth+1, levelPrefixes[level]+s)
+	}
+	l.callHandlers(level, s)
+}
+
+// Debugln logs a line with a DEBUG prefix.
+func (l *logger) Debugln(vals ...interface{}) {
+	l.output(2, LevelDebug, fields{}, fmt.Sprintln(vals...))
 }
 
 // Debugf logs a formatted line with a DEBUG prefix.
 func (l *logger) Debugf(format string, vals ...interface{}) {
-	l.debugf(3, format, vals...)
-}
-func (l *logger) debugf(level int, format string, vals ...interface{}) {
-	s := fmt.Sprintf(format, vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(level, "DEBUG: "+s)
-	l.callHandlers(LevelDebug, s)
+	l.output(2, LevelDebug, fields{}, fmt.Sprintf(format, vals...))
 }
 
 // Infoln logs a line with a VERBOSE prefix.
 func (l *logger) Verboseln(vals ...interface{}) {
-	s := fmt.Sprintln(vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "VERBOSE: "+s)
-	l.callHandlers(LevelVerbose, s)
+	l.output(2, LevelVerbose, fields{}, fmt.Sprintln(vals...))
 }
 
 // Infof logs a formatted line with a VERBOSE prefix.
 func (l *logger) Verbosef(format string, vals ...interface{}) {
-	s := fmt.Sprintf(format, vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "VERBOSE: "+s)
-	l.callHandlers(LevelVerbose, s)
+	l.output(2, LevelVerbose, fields{}, fmt.Sprintf(format, vals...))
 }
 
 // Infoln logs a line with an INFO prefix.
 func (l *logger) Infoln(vals ...interface{}) {
-	s := fmt.Sprintln(vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "INFO: "+s)
-	l.callHandlers(LevelInfo, s)
+	l.output(2, LevelInfo, fields{}, fmt.Sprintln(vals...))
 }
 
 // Infof logs a formatted line with an INFO prefix.
 func (l *logger) Infof(format string, vals ...interface{}) {
-	s := fmt.Sprintf(format, vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "INFO: "+s)
-	l.callHandlers(LevelInfo, s)
+	l.output(2, LevelInfo, fields{}, fmt.Sprintf(format, vals...))
 }
 
 // Warnln logs a formatted line with a WARNING prefix.
 func (l *logger) Warnln(vals ...interface{}) {
-	s := fmt.Sprintln(vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "WARNING: "+s)
-	l.callHandlers(LevelWarn, s)
+	l.output(2, LevelWarn, fields{}, fmt.Sprintln(vals...))
 }
 
 // Warnf logs a formatted line with a WARNING prefix.
 func (l *logger) Warnf(format string, vals ...interface{}) {
-	s := fmt.Sprintf(format, vals...)
-	l.mut.Lock()
-	defer l.mut.Unlock()
-	l.logger.Output(2, "WARNING: "+s)
-	l.callHandlers(LevelWarn, s)
+	l.output(2, LevelWarn, fields{}, fmt.Sprintf(format, vals...))
 }
 
 // ShouldDebug returns true if the given facility has debugging enabled.
@@ -241,33 +261,90 @@ func (l *logger) NewFacility(facility, description string) Logger {
 	l.mut.Unlock()
 
 	return &facilityLogger{
-		logger:   l,
-		facility: facility,
+		logger: l,
+		fields: fields{facility: facility},
 	}
 }
 
-// A facilityLogger is a regular logger but bound to a facility name. The
-// Debugln and Debugf methods are no-ops unless debugging has been enabled for
-// this facility on the parent logger.
+// With returns a logger that logs the given folder ID and device ID with
+// each line; empty ones are left out.
+func (l *logger) With(folder, device string) Logger {
+	return &facilityLogger{
+		logger: l,
+		fields: fields{folder: folder, device: device},
+	}
+}
+
+// A facilityLogger is a regular logger but bound to a facility name, and
+// possibly a folder and device. The Debugln and Debugf methods are no-ops
+// unless debugging has been enabled for this facility on the parent logger,
+// if it has one.
 type facilityLogger struct {
 	*logger
-	facility string
+	fields
+}
+
+// With returns a logger for the same facility, that logs the given folder
+// ID and device ID with each line. Empty ones keep those of this logger.
+func (l *facilityLogger) With(folder, device string) Logger {
+	f := l.fields
+	if folder != "" {
+		f.folder = folder
+	}
+	if device != "" {
+		f.device = device
+	}
+	return &facilityLogger{
+		logger: l.logger,
+		fields: f,
+	}
+}
+
+func (l *facilityLogger) shouldDebug() bool {
+	return l.facility == "" || l.ShouldDebug(l.facility)
 }
 
 // Debugln logs a line with a DEBUG prefix.
 func (l *facilityLogger) Debugln(vals ...interface{}) {
-	if !l.ShouldDebug(l.facility) {
+	if !l.shouldDebug() {
 		return
 	}
-	l.logger.debugln(3, vals...)
+	l.output(2, LevelDebug, l.fields, fmt.Sprintln(vals...))
 }
 
 // Debugf logs a formatted line with a DEBUG prefix.
 func (l *facilityLogger) Debugf(format string, vals ...interface{}) {
-	if !l.ShouldDebug(l.facility) {
+	if !l.shouldDebug() {
 		return
 	}
-	l.logger.debugf(3, format, vals...)
+	l.output(2, LevelDebug, l.fields, fmt.Sprintf(format, vals...))
+}
+
+// The other levels are logged regardless of debugging, but with the
+// fields, for the JSON format.
+
+func (l *facilityLogger) Verboseln(vals ...interface{}) {
+	l.output(2, LevelVerbose, l.fields, fmt.Sprintln(vals...))
+}
+
+func (l *facilityLogger) Verbosef(format string, vals ...interface{}) {
+	l.output(2, LevelVerbose, l.fields, fmt.Sprintf(format, vals...))
+}
+
+func (l *facilityLogger) Infoln(vals ...interface{}) {
+	l.output(2, LevelInfo, l.fields, fmt.Sprintln(vals...))
+}
+
+func (l *facilityLogger) Infof(format string, vals ...interface{}) {
+	l.output(2, LevelInfo, l.fields, fmt.Sprintf(format, vals...))
+}
+
+func (l *facilityLogger) Warnln(vals ...interface{}) {
+	l.output(2, LevelWarn, l.fields, fmt.Sprintln(vals...))
+}
+
+func (l *facilityLogger) Warnf(format string, vals ...interface{}) {
+	l.output(2, LevelWarn, l.fields, fmt.Sprintf(format, vals...))
 }
 
 // A Recorder keeps a size limited record of log events.
@@ -351,6 +428,38 @@ func (r *recorder) append(l LogLevel, msg string) {
 	}
 }
 
+// A jsonLine is a line logged in the JSON format. The folder and device
+// are those the message is about, if it was logged through a logger from
+// With.
+type jsonLine struct {
+	Time     time.Time `json:"time"`
+	Prefix   string    `json:"prefix,omitempty"`
+	Level    string    `json:"level"`
+	Facility string    `json:"facility,omitempty"`
+	Folder   string    `json:"folder,omitempty"`
+	Device   string    `json:"device,omitempty"`
+	Message  string    `json:"message"`
+}
+
+var levelNames = [NumLevels]string{"debug", "verbose", "info", "warning"}
+
+func (l *logger) writeJSON(level LogLevel, f fields, s string) {
+	line := jsonLine{
+		Time:     time.Now(),
+		Prefix:   strings.TrimSpace(l.logger.Prefix()),
+		Level:    levelNames[level],
+		Facility: f.facility,
+		Folder:   f.folder,
+		Device:   f.device,
+		Message:  strings.TrimSpace(s),
+	}
+	bs, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	l.w.Write(append(bs, '\n'))
+}
+
 // controlStripper is a Writer that replaces control characters
 // with spaces.
 type controlStripper struct {
@@ -5,6 +5,7 @@ package logger
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -185,6 +186,53 @@ func TestControlStripper(t *testing.T) {
 	}
 }
 
+func TestJSONFormat(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := newLogger(b)
+	l.SetPrefix("[ABCDE] ")
+	if err := l.SetFormat("xml"); err == nil {
+		t.Error("Unknown format accepted")
+	}
+	if err := l.SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string, 3)
+	l.AddHandler(LevelInfo, func(_ LogLevel, msg string) { lines <- msg })
+
+	f := l.NewFacility("model", "The root hub")
+	f.With("abcd-1234", "").With("", "P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2").Infof(`Ready to synchronize "Photos" (abcd-1234) (sendreceive)`)
+	l.With("other", "").Warnln(`Stopping folder "other" for now`)
+	f.Infoln("plain", "line", `"Photos" (abcd-1234)`)
+
+	var res []jsonLine
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var jl jsonLine
+		if err := json.Unmarshal([]byte(line), &jl); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		res = append(res, jl)
+	}
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 lines, got %d", len(res))
+	}
+	if res[0].Level != "info" || res[0].Facility != "model" || res[0].Folder != "abcd-1234" || res[0].Device != "P56IOI7-MZJNU2Y-IQGDREY-DM2MGTI-MGL3BXN-PQ6W5BM-TBBZ4TJ-XZWICQ2" || res[0].Prefix != "[ABCDE]" || res[0].Time.IsZero() {
+		t.Errorf("Unexpected line %+v", res[0])
+	}
+	if res[1].Level != "warning" || res[1].Facility != "" || res[1].Folder != "other" || res[1].Device != "" || res[1].Message != `Stopping folder "other" for now` {
+		t.Errorf("Unexpected line %+v", res[1])
+	}
+	// Nothing is guessed from the message
+	if res[2].Facility != "model" || res[2].Folder != "" || res[2].Message != `plain line "Photos" (abcd-1234)` {
+		t.Errorf("Unexpected line %+v", res[2])
+	}
+
+	// Handlers still get the plain message
+	if msg := <-lines; !strings.HasPrefix(msg, "Ready to synchronize") {
+		t.Error("Unexpected message", msg)
+	}
+}
+
 func BenchmarkLog(b *testing.B) {
 	l := newLogger(controlStripper{ioutil.Discard})
 	benchmarkLogger(b, l)
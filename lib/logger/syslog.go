@@ -0,0 +1,134 @@
+// Copyright (C) 2019 Jakob Borg. All rights reserved. Use of this source code
+// is governed by an MIT-style license that can be found in the LICENSE file.
+
+package logger
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The messages queued for a sink, beyond which they are dropped rather
+// than holding up logging.
+const sinkQueueLen = 1000
+
+// The syslog facility of the messages, "daemon".
+const syslogFacility = 3
+
+// The syslog severities of the log levels.
+var syslogSeverities = [NumLevels]int{7, 6, 6, 4} // debug, info, info, warning
+
+// AddSyslogSink sends the messages of the logger at the level and above to
+// the syslog server at the URL, udp://host:port, tcp://host:port or
+// unix:///path/to/socket, in the RFC 5424 format. Messages are sent in the
+// background, and dropped when the server can't be reached or doesn't keep
+// up.
+func AddSyslogSink(l Logger, sinkURL string, level LogLevel) error {
+	u, err := url.Parse(sinkURL)
+	if err != nil {
+		return err
+	}
+	s := &syslogSink{
+		queue: make(chan string, sinkQueueLen),
+		app:   filepath.Base(os.Args[0]),
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Host == "" {
+			return fmt.Errorf("syslog URL %q without host", sinkURL)
+		}
+		s.networks = []string{u.Scheme}
+		s.address = u.Host
+	case "unix":
+		if u.Path == "" {
+			return fmt.Errorf("syslog URL %q without path", sinkURL)
+		}
+		// The local syslog socket is usually a datagram one.
+		s.networks = []string{"unixgram", "unix"}
+		s.address = u.Path
+	default:
+		return fmt.Errorf("unsupported syslog URL %q", sinkURL)
+	}
+	if s.hostname, err = os.Hostname(); err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+
+	go s.serve()
+	l.AddHandler(level, s.handle)
+	return nil
+}
+
+type syslogSink struct {
+	networks []string // to try in order
+	address  string
+	hostname string
+	app      string
+	queue    chan string
+
+	conn    net.Conn
+	network string
+}
+
+func (s *syslogSink) handle(level LogLevel, msg string) {
+	select {
+	case s.queue <- s.format(time.Now(), level, msg):
+	default:
+	}
+}
+
+// format returns the message in the RFC 5424 format, with neither message
+// ID nor structured data.
+func (s *syslogSink) format(t time.Time, level LogLevel, msg string) string {
+	pri := syslogFacility*8 + syslogSeverities[level]
+	return fmt.Sprintf("<%d>1 %s %s %s %d - - %s", pri, t.UTC().Format(time.RFC3339Nano), s.hostname, s.app, os.Getpid(), msg)
+}
+
+func (s *syslogSink) serve() {
+	for msg := range s.queue {
+		if err := s.send(msg); err != nil {
+			// Try again with a new connection, and otherwise drop the
+			// message.
+			s.close()
+			s.send(msg)
+		}
+	}
+}
+
+func (s *syslogSink) send(msg string) error {
+	if s.conn == nil {
+		var err error
+		for _, network := range s.networks {
+			if s.conn, err = net.DialTimeout(network, s.address, 10*time.Second); err == nil {
+				s.network = network
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	switch s.network {
+	case "tcp", "unix":
+		// Streams need the messages framed, by octet counting as in
+		// RFC 6587.
+		_, err := fmt.Fprintf(s.conn, "%d %s", len(msg), msg)
+		return err
+	default:
+		// A datagram per message.
+		_, err := s.conn.Write([]byte(msg))
+		return err
+	}
+}
+
+func (s *syslogSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
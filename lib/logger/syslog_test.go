@@ -0,0 +1,95 @@
+// Copyright (C) 2019 Jakob Borg. All rights reserved. Use of this source code
+// is governed by an MIT-style license that can be found in the LICENSE file.
+
+package logger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	s := &syslogSink{hostname: "host", app: "syncthing"}
+	msg := s.format(time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC), LevelWarn, "something broke")
+	if !regexp.MustCompile(`^<28>1 2019-03-04T05:06:07Z host syncthing \d+ - - something broke$`).MatchString(msg) {
+		t.Errorf("Unexpected message %q", msg)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	l := newLogger(ioutil.Discard)
+	if err := AddSyslogSink(l, "udp://"+pc.LocalAddr().String(), LevelInfo); err != nil {
+		t.Fatal(err)
+	}
+	l.Debugln("not sent")
+	l.Infoln("hello syslog")
+
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<30>1 ") || !strings.HasSuffix(msg, " - - hello syslog") {
+		t.Errorf("Unexpected message %q", msg)
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	l := newLogger(ioutil.Discard)
+	if err := AddSyslogSink(l, "tcp://"+ln.Addr().String(), LevelInfo); err != nil {
+		t.Fatal(err)
+	}
+	l.Warnln("first")
+	l.Infoln("second")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	for _, expected := range []string{"first", "second"} {
+		// Octet counted frames
+		var n int
+		if _, err := fmt.Fscanf(br, "%d ", &n); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			t.Fatal(err)
+		}
+		if msg := string(buf); !strings.HasSuffix(msg, " - - "+expected) {
+			t.Errorf("Unexpected message %q", msg)
+		}
+	}
+}
+
+func TestSyslogSinkURL(t *testing.T) {
+	l := newLogger(ioutil.Discard)
+	for _, u := range []string{"http://example.com", "udp://", "unix://", "::"} {
+		if err := AddSyslogSink(l, u, LevelInfo); err == nil {
+			t.Errorf("URL %q accepted", u)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// An AttentionFile is a file in the folder that the user probably wants to
+// look at: a conflict copy, or a local change in a receive only folder.
+type AttentionFile struct {
+	Name    string    `json:"name"`
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	Deleted bool      `json:"deleted"`
+	// Conflict is set for conflict copies, with the name of the file the
+	// copy was made of and when, as far as the name tells.
+	Conflict     bool      `json:"conflict"`
+	ConflictOf   string    `json:"conflictOf,omitempty"`
+	ConflictTime time.Time `json:"conflictTime,omitempty"`
+	// ReceiveOnlyChanged is set for files changed locally in a receive
+	// only folder, which are not sent to other devices.
+	ReceiveOnlyChanged bool `json:"receiveOnlyChanged"`
+}
+
+// AttentionFiles lists the conflict copies and, in receive only folders,
+// the locally changed files of the folder as known from the index, sorted
+// by name.
+func (m *model) AttentionFiles(folder string) ([]AttentionFile, error) {
+	if _, ok := m.cfg.Folder(folder); !ok {
+		return nil, errFolderMissing
+	}
+
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, ErrFolderPaused
+	}
+
+	return attentionFiles(fset), nil
+}
+
+func attentionFiles(fset *db.FileSet) []AttentionFile {
+	res := make([]AttentionFile, 0)
+	fset.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		conflict := !f.IsDeleted() && !f.IsDirectory() && isConflict(f.Name)
+		if !conflict && !f.IsReceiveOnlyChanged() {
+			return true
+		}
+		af := AttentionFile{
+			Name:               f.Name,
+			ModTime:            f.ModTime(),
+			Size:               f.FileSize(),
+			Deleted:            f.IsDeleted(),
+			Conflict:           conflict,
+			ReceiveOnlyChanged: f.IsReceiveOnlyChanged(),
+		}
+		if conflict {
+			af.ConflictOf, af.ConflictTime, _ = parseConflictName(f.Name)
+		}
+		res = append(res, af)
+		return true
+	})
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].Name < res[b].Name
+	})
+	return res
+}
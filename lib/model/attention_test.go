@@ -0,0 +1,50 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestAttentionFiles(t *testing.T) {
+	fset := db.NewFileSet("default", fs.NewFilesystem(fs.FilesystemTypeFake, ""), db.OpenMemory())
+	version := protocol.Vector{}.Update(myID.Short())
+	fset.Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "plain", Version: version, Size: 1},
+		{Name: "report.sync-conflict-20190102-030405-ABCDEFG.txt", Version: version, Size: 2, ModifiedS: 1000},
+		{Name: "gone.sync-conflict-20190102-030405-ABCDEFG", Version: version, Deleted: true},
+		{Name: "changed", Version: version, Size: 3, LocalFlags: protocol.FlagLocalReceiveOnly},
+		{Name: "removed", Version: version, Deleted: true, LocalFlags: protocol.FlagLocalReceiveOnly},
+	})
+
+	files := attentionFiles(fset)
+	expected := []string{"changed", "removed", "report.sync-conflict-20190102-030405-ABCDEFG.txt"}
+	if len(files) != len(expected) {
+		t.Fatalf("Got %+v, expected %v", files, expected)
+	}
+	for i, name := range expected {
+		if files[i].Name != name {
+			t.Fatalf("Got %+v, expected %v", files, expected)
+		}
+	}
+
+	if f := files[0]; !f.ReceiveOnlyChanged || f.Conflict || f.Size != 3 {
+		t.Errorf("Unexpected entry %+v", f)
+	}
+	if f := files[1]; !f.ReceiveOnlyChanged || !f.Deleted {
+		t.Errorf("Unexpected entry %+v", f)
+	}
+	when := time.Date(2019, 1, 2, 3, 4, 5, 0, time.Local)
+	if f := files[2]; !f.Conflict || f.ReceiveOnlyChanged || f.ConflictOf != "report.txt" || !f.ConflictTime.Equal(when) || f.Size != 2 || !f.ModTime.Equal(time.Unix(1000, 0)) {
+		t.Errorf("Unexpected entry %+v", f)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/sha256"
+)
+
+const (
+	defaultAuditSamples = 100
+	maxAuditSamples     = 10000
+)
+
+var (
+	errFolderNotShared    = errors.New("folder is not shared with the device")
+	errDeviceNotConnected = errors.New("device is not connected")
+	errAuditHashMismatch  = errors.New("hash mismatch")
+)
+
+// An AuditReport is the result of auditing the data of a remote device.
+type AuditReport struct {
+	Device   protocol.DeviceID `json:"device"`
+	Folder   string            `json:"folder"`
+	Started  time.Time         `json:"started"`
+	Duration time.Duration     `json:"duration"`
+	// Files and blocks which the device announces at the current global
+	// version, and which are sampled from.
+	Files  int   `json:"files"`
+	Blocks int64 `json:"blocks"`
+	// Files which the device announces at another than the global
+	// version, and which are not audited.
+	OutOfDate int            `json:"outOfDate"`
+	Sampled   int            `json:"sampled"`
+	Verified  int            `json:"verified"`
+	Failures  []AuditFailure `json:"failures"`
+	// With 95% confidence at most this fraction of the blocks are missing
+	// or bad on the device.
+	MaxBadFraction float64 `json:"maxBadFraction"`
+	// The audit was cancelled before all samples were verified.
+	Cancelled bool `json:"cancelled"`
+}
+
+// An AuditFailure is a sampled block the device did not return correctly.
+type AuditFailure struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Error  string `json:"error"`
+}
+
+type auditSample struct {
+	name  string
+	block protocol.BlockInfo
+}
+
+// AuditRemote requests a random sample of the blocks the device announces
+// for the folder and verifies their hashes, to estimate how much of the
+// data the device actually holds. A non-positive number of samples selects
+// the default.
+func (m *model) AuditRemote(device protocol.DeviceID, folder string, samples int) (AuditReport, error) {
+	if samples <= 0 {
+		samples = defaultAuditSamples
+	} else if samples > maxAuditSamples {
+		samples = maxAuditSamples
+	}
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return AuditReport{}, errFolderMissing
+	}
+	if !cfg.SharedWith(device) {
+		return AuditReport{}, errFolderNotShared
+	}
+	m.pmut.RLock()
+	_, ok = m.conn[device]
+	m.pmut.RUnlock()
+	if !ok {
+		return AuditReport{}, errDeviceNotConnected
+	}
+
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return AuditReport{}, ErrFolderPaused
+	}
+
+	ctx, done := m.folderJobs.start(context.Background(), folder, FolderJobAudit)
+	defer done()
+
+	report := AuditReport{
+		Device:  device,
+		Folder:  folder,
+		Started: time.Now(),
+	}
+
+	// Reservoir sampling of blocks, so that every block the device should
+	// have is equally likely to be picked.
+	var sample []auditSample
+	fset.WithHave(device, func(fi db.FileIntf) bool {
+		f := fi.(protocol.FileInfo)
+		if f.IsDeleted() || f.IsInvalid() || f.IsDirectory() || f.IsSymlink() || len(f.Blocks) == 0 {
+			return true
+		}
+		if global, ok := fset.GetGlobalTruncated(f.Name); !ok || !global.Version.Equal(f.Version) {
+			report.OutOfDate++
+			return true
+		}
+		report.Files++
+		for _, block := range f.Blocks {
+			report.Blocks++
+			if len(sample) < samples {
+				sample = append(sample, auditSample{f.Name, block})
+			} else if i := rand.Int63() % report.Blocks; i < int64(samples) {
+				sample[i] = auditSample{f.Name, block}
+			}
+		}
+		return true
+	})
+
+	for _, s := range sample {
+		if ctx.Err() != nil {
+			report.Cancelled = true
+			break
+		}
+		err := m.auditBlock(device, folder, s)
+		report.Sampled++
+		if err != nil {
+			l.Debugf("Audit of %s: %s at %d: %v", device, s.name, s.block.Offset, err)
+			report.Failures = append(report.Failures, AuditFailure{
+				Name:   s.name,
+				Offset: s.block.Offset,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		report.Verified++
+	}
+
+	report.MaxBadFraction = wilsonUpperBound(report.Sampled-report.Verified, report.Sampled)
+	report.Duration = time.Since(report.Started)
+	return report, nil
+}
+
+func (m *model) auditBlock(device protocol.DeviceID, folder string, s auditSample) error {
+	data, err := m.requestGlobal(device, folder, s.name, s.block.Offset, int(s.block.Size), s.block.Hash, s.block.WeakHash, false)
+	if err != nil {
+		return err
+	}
+	if hash := sha256.Sum256(data); !bytes.Equal(hash[:], s.block.Hash) {
+		return errAuditHashMismatch
+	}
+	return nil
+}
+
+// wilsonUpperBound returns the upper bound of the 95% Wilson score interval
+// for the proportion of failures among the trials. Without trials nothing
+// is known, and the bound is one.
+func wilsonUpperBound(failures, trials int) float64 {
+	if trials == 0 {
+		return 1
+	}
+	const z = 1.96
+	n := float64(trials)
+	p := float64(failures) / n
+	centre := p + z*z/(2*n)
+	spread := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Min(1, (centre+spread)/(1+z*z/n))
+}
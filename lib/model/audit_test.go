@@ -0,0 +1,96 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestAuditRemote(t *testing.T) {
+	m, fc, fcfg, w := setupModelWithConnection()
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	data := map[string][]byte{
+		"good":    []byte("good data"),
+		"bad":     []byte("bad data"),
+		"missing": []byte("missing data"),
+	}
+	for name, bs := range data {
+		fc.addFile(name, 0644, protocol.FileInfoTypeFile, bs)
+	}
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+		switch name {
+		case "bad":
+			return []byte("bad dat!"), nil
+		case "missing":
+			return nil, errors.New("no such file")
+		}
+		return data[name], nil
+	}
+	fc.sendIndexUpdate()
+
+	report, err := m.AuditRemote(device1, "default", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 3 || report.Blocks != 3 || report.Sampled != 3 || report.Verified != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if len(report.Failures) != 2 {
+		t.Fatal("Expected two failures, got", report.Failures)
+	}
+	for _, f := range report.Failures {
+		if f.Name == "good" {
+			t.Error("Good file should not fail")
+		}
+		if f.Name == "bad" && f.Error != errAuditHashMismatch.Error() {
+			t.Error("Unexpected error for bad file:", f.Error)
+		}
+	}
+	if report.MaxBadFraction < 2.0/3 || report.MaxBadFraction > 1 {
+		t.Error("Unexpected bound", report.MaxBadFraction)
+	}
+
+	// Sampling limits the number of requests
+	report, err = m.AuditRemote(device1, "default", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sampled != 2 {
+		t.Error("Expected two samples, got", report.Sampled)
+	}
+
+	if _, err := m.AuditRemote(device2, "default", 0); err != errFolderNotShared {
+		t.Error("Expected not shared error, got", err)
+	}
+}
+
+func TestWilsonUpperBound(t *testing.T) {
+	cases := []struct {
+		failures, trials int
+		min, max         float64
+	}{
+		{0, 0, 1, 1},
+		{0, 100, 0.03, 0.04},
+		{0, 1000, 0.003, 0.004},
+		{10, 100, 0.17, 0.18},
+		{100, 100, 0.96, 1},
+	}
+	for _, tc := range cases {
+		if b := wilsonUpperBound(tc.failures, tc.trials); b < tc.min || b > tc.max {
+			t.Errorf("wilsonUpperBound(%d, %d) = %f, expected in [%f, %f]", tc.failures, tc.trials, b, tc.min, tc.max)
+		}
+	}
+}
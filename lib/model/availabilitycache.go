@@ -0,0 +1,100 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The most files per folder whose availability is kept. Beyond that the
+// cache of the folder starts over.
+const maxAvailabilityCacheFiles = 10000
+
+// availabilityCache keeps the devices that have the global version of the
+// files being pulled. Otherwise the version list of the file is read from
+// the database for every block, which in a folder shared with many devices
+// is where most of the time pulling goes.
+//
+// Entries are dropped as the files change, locally or on other devices, and
+// are only used for the version they were looked up for.
+type availabilityCache struct {
+	mut     sync.Mutex
+	folders map[string]*folderAvailability
+}
+
+type folderAvailability struct {
+	files map[string]cachedAvailability
+	// Incremented by each change, so that a lookup which raced with a
+	// change isn't cached.
+	generation int64
+}
+
+type cachedAvailability struct {
+	version protocol.Vector
+	devices []protocol.DeviceID
+}
+
+func newAvailabilityCache() *availabilityCache {
+	return &availabilityCache{
+		mut:     sync.NewMutex(),
+		folders: make(map[string]*folderAvailability),
+	}
+}
+
+// devices returns the devices that have the file at its version, which the
+// caller must not modify.
+func (c *availabilityCache) devices(fset *db.FileSet, folder string, file protocol.FileInfo) []protocol.DeviceID {
+	c.mut.Lock()
+	fa, ok := c.folders[folder]
+	if !ok {
+		fa = &folderAvailability{files: make(map[string]cachedAvailability)}
+		c.folders[folder] = fa
+	}
+	if cached, ok := fa.files[file.Name]; ok && cached.version.Equal(file.Version) {
+		c.mut.Unlock()
+		return cached.devices
+	}
+	generation := fa.generation
+	c.mut.Unlock()
+
+	devices := fset.Availability(file.Name)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if cur, ok := c.folders[folder]; !ok || cur != fa || fa.generation != generation {
+		return devices
+	}
+	if len(fa.files) >= maxAvailabilityCacheFiles {
+		fa.files = make(map[string]cachedAvailability)
+	}
+	fa.files[file.Name] = cachedAvailability{version: file.Version, devices: devices}
+	return devices
+}
+
+// changed drops the cached availability of the files.
+func (c *availabilityCache) changed(folder string, files []protocol.FileInfo) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	fa, ok := c.folders[folder]
+	if !ok {
+		return
+	}
+	fa.generation++
+	for _, file := range files {
+		delete(fa.files, file.Name)
+	}
+}
+
+// forget drops all of the folder, e.g. as the files of a device were
+// dropped.
+func (c *availabilityCache) forget(folder string) {
+	c.mut.Lock()
+	delete(c.folders, folder)
+	c.mut.Unlock()
+}
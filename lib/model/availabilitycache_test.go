@@ -0,0 +1,73 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestAvailabilityCache(t *testing.T) {
+	fset := db.NewFileSet("default", fs.NewFilesystem(fs.FilesystemTypeFake, ""), db.OpenMemory())
+	c := newAvailabilityCache()
+
+	file := protocol.FileInfo{Name: "file", Version: protocol.Vector{}.Update(device1.Short())}
+	fset.Update(device1, []protocol.FileInfo{file})
+
+	if devices := c.devices(fset, "default", file); len(devices) != 1 || devices[0] != device1 {
+		t.Fatalf("Unexpected devices %v", devices)
+	}
+
+	// Cached until told about the change.
+	fset.Update(device2, []protocol.FileInfo{file})
+	if devices := c.devices(fset, "default", file); len(devices) != 1 {
+		t.Fatalf("Expected the cached devices, got %v", devices)
+	}
+	c.changed("default", []protocol.FileInfo{file})
+	if devices := c.devices(fset, "default", file); len(devices) != 2 {
+		t.Fatalf("Expected both devices after the change, got %v", devices)
+	}
+
+	// Only used for the version it was looked up for.
+	newer := file
+	newer.Version = file.Version.Update(device2.Short())
+	fset.Update(device2, []protocol.FileInfo{newer})
+	if devices := c.devices(fset, "default", newer); len(devices) != 1 || devices[0] != device2 {
+		t.Fatalf("Unexpected devices for the newer version %v", devices)
+	}
+
+	fset.Drop(device2)
+	c.forget("default")
+	if devices := c.devices(fset, "default", file); len(devices) != 1 || devices[0] != device1 {
+		t.Fatalf("Unexpected devices after dropping device2 %v", devices)
+	}
+}
+
+func BenchmarkAvailability(b *testing.B) {
+	fset := db.NewFileSet("default", fs.NewFilesystem(fs.FilesystemTypeFake, ""), db.OpenMemory())
+	file := protocol.FileInfo{Name: "file", Version: protocol.Vector{}.Update(device1.Short())}
+	devices := make([]protocol.DeviceID, 50)
+	for i := range devices {
+		devices[i][0] = byte(i)
+		fset.Update(devices[i], []protocol.FileInfo{file})
+	}
+
+	b.Run("db", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fset.Availability(file.Name)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		c := newAvailabilityCache()
+		for i := 0; i < b.N; i++ {
+			c.devices(fset, "default", file)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"container/heap"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+// Orders for GlobalDirectoryList
+const (
+	BrowseSortName     = "name"
+	BrowseSortSize     = "size"
+	BrowseSortModified = "modified"
+)
+
+var errUnknownBrowseSort = errors.New("unknown sort order")
+
+// GlobalDirectoryList returns one page of the global files and directories
+// below prefix, nested at most levels directories deep unless levels is
+// negative. The entries are sorted by sortBy, one of the BrowseSort
+// constants, descending if desc is set. Entries are collected while
+// iterating the database and only those up to the end of the requested
+// page are kept in memory.
+func (m *model) GlobalDirectoryList(folder, prefix string, levels int, dirsonly bool, sortBy string, desc bool, page, perpage int) ([]db.FileInfoTruncated, error) {
+	var less func(a, b db.FileInfoTruncated) bool
+	switch sortBy {
+	case "", BrowseSortName:
+		less = func(a, b db.FileInfoTruncated) bool {
+			return a.Name < b.Name
+		}
+	case BrowseSortSize:
+		less = func(a, b db.FileInfoTruncated) bool {
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+			return a.Name < b.Name
+		}
+	case BrowseSortModified:
+		less = func(a, b db.FileInfoTruncated) bool {
+			if at, bt := a.ModTime(), b.ModTime(); !at.Equal(bt) {
+				return at.Before(bt)
+			}
+			return a.Name < b.Name
+		}
+	default:
+		return nil, errUnknownBrowseSort
+	}
+	if desc {
+		asc := less
+		less = func(a, b db.FileInfoTruncated) bool {
+			return asc(b, a)
+		}
+	}
+
+	m.fmut.RLock()
+	files, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	sep := string(filepath.Separator)
+	prefix = osutil.NativeFilename(prefix)
+	if prefix != "" && !strings.HasSuffix(prefix, sep) {
+		prefix = prefix + sep
+	}
+
+	skip := (page - 1) * perpage
+	// The database is iterated in name order, so unless we want something
+	// else we can stop as soon as the page is complete.
+	inOrder := (sortBy == "" || sortBy == BrowseSortName) && !desc
+	sel := &fileSelection{max: skip + perpage, less: less}
+
+	files.WithPrefixedGlobalTruncatedLevels(prefix, levels, func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+
+		// Don't include the prefix itself.
+		if f.IsInvalid() || f.IsDeleted() || strings.HasPrefix(prefix, f.Name) {
+			return true
+		}
+		if dirsonly && (!f.IsDirectory() || f.IsSymlink()) {
+			return true
+		}
+
+		if inOrder {
+			sel.files = append(sel.files, f)
+			return len(sel.files) < sel.max
+		}
+		sel.add(f)
+		return true
+	})
+
+	res := sel.sorted()
+	if skip >= len(res) {
+		return []db.FileInfoTruncated{}, nil
+	}
+	return res[skip:], nil
+}
+
+// fileSelection keeps the max smallest files added to it, with the largest
+// of them at the root of the heap so that it can be replaced cheaply.
+type fileSelection struct {
+	files []db.FileInfoTruncated
+	max   int
+	less  func(a, b db.FileInfoTruncated) bool
+}
+
+func (s *fileSelection) add(f db.FileInfoTruncated) {
+	if len(s.files) < s.max {
+		heap.Push(s, f)
+	} else if len(s.files) > 0 && s.less(f, s.files[0]) {
+		s.files[0] = f
+		heap.Fix(s, 0)
+	}
+}
+
+func (s *fileSelection) sorted() []db.FileInfoTruncated {
+	sort.Slice(s.files, func(a, b int) bool {
+		return s.less(s.files[a], s.files[b])
+	})
+	return s.files
+}
+
+func (s *fileSelection) Len() int           { return len(s.files) }
+func (s *fileSelection) Less(a, b int) bool { return s.less(s.files[b], s.files[a]) }
+func (s *fileSelection) Swap(a, b int)      { s.files[a], s.files[b] = s.files[b], s.files[a] }
+
+func (s *fileSelection) Push(x interface{}) {
+	s.files = append(s.files, x.(db.FileInfoTruncated))
+}
+
+func (s *fileSelection) Pop() interface{} {
+	f := s.files[len(s.files)-1]
+	s.files = s.files[:len(s.files)-1]
+	return f
+}
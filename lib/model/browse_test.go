@@ -0,0 +1,77 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestGlobalDirectoryList(t *testing.T) {
+	db := db.OpenMemory()
+	m := newModel(defaultCfgWrapper, myID, "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+	m.ServeBackground()
+	defer m.Stop()
+
+	m.Index(device1, "default", []protocol.FileInfo{
+		{Name: "dir", Type: protocol.FileInfoTypeDirectory, ModifiedS: 4},
+		{Name: filepath.Join("dir", "a"), Size: 30, ModifiedS: 1},
+		{Name: filepath.Join("dir", "b"), Size: 10, ModifiedS: 3},
+		{Name: filepath.Join("dir", "c"), Size: 20, ModifiedS: 2},
+		{Name: filepath.Join("dir", "sub"), Type: protocol.FileInfoTypeDirectory, ModifiedS: 5},
+		{Name: filepath.Join("dir", "sub", "deep"), Size: 40, ModifiedS: 6},
+		{Name: "file", Size: 50, ModifiedS: 7},
+	})
+
+	cases := []struct {
+		prefix   string
+		levels   int
+		dirsonly bool
+		sortBy   string
+		desc     bool
+		page     int
+		perpage  int
+		expected []string
+	}{
+		{"", -1, false, "", false, 1, 100, []string{"dir", "dir/a", "dir/b", "dir/c", "dir/sub", "dir/sub/deep", "file"}},
+		{"", 0, false, "", false, 1, 100, []string{"dir", "file"}},
+		{"", -1, true, "", false, 1, 100, []string{"dir", "dir/sub"}},
+		{"dir", 0, false, "", false, 1, 2, []string{"dir/a", "dir/b"}},
+		{"dir", 0, false, "", false, 2, 2, []string{"dir/c", "dir/sub"}},
+		{"dir", 0, false, "", false, 3, 2, []string{}},
+		{"dir", 0, false, BrowseSortName, true, 1, 3, []string{"dir/sub", "dir/c", "dir/b"}},
+		{"dir", -1, false, BrowseSortSize, true, 1, 2, []string{"dir/sub/deep", "dir/a"}},
+		{"dir", 0, false, BrowseSortSize, false, 2, 2, []string{"dir/c", "dir/a"}},
+		{"", -1, false, BrowseSortModified, false, 1, 3, []string{"dir/a", "dir/c", "dir/b"}},
+	}
+
+	for _, tc := range cases {
+		files, err := m.GlobalDirectoryList("default", tc.prefix, tc.levels, tc.dirsonly, tc.sortBy, tc.desc, tc.page, tc.perpage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, len(files))
+		for i, f := range files {
+			names[i] = filepath.ToSlash(f.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tc.expected) {
+			t.Errorf("%+v: got %v", tc, names)
+		}
+	}
+
+	if _, err := m.GlobalDirectoryList("default", "", -1, false, "color", false, 1, 10); err != errUnknownBrowseSort {
+		t.Error("Expected error for unknown sort order, got", err)
+	}
+	if _, err := m.GlobalDirectoryList("nonexistent", "", -1, false, "", false, 1, 10); err != errFolderMissing {
+		t.Error("Expected error for missing folder, got", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Changes are counted by the hour, and kept for a week.
+const changeHeatmapHours = 7 * 24
+
+// DirChanges is how often the files in a directory changed over the last
+// hour, day and week. When directories are aggregated to a number of
+// levels, the changes below a directory count towards it.
+type DirChanges struct {
+	Path     string       `json:"path"`
+	LastHour ChangeCounts `json:"lastHour"`
+	LastDay  ChangeCounts `json:"lastDay"`
+	LastWeek ChangeCounts `json:"lastWeek"`
+}
+
+type ChangeCounts struct {
+	Local  int   `json:"local"`  // Changes found by scanning
+	Remote int   `json:"remote"` // Changes pulled from other devices
+	Bytes  int64 `json:"bytes"`  // Size of the changed files
+}
+
+func (c ChangeCounts) total() int {
+	return c.Local + c.Remote
+}
+
+func (c *ChangeCounts) add(o ChangeCounts) {
+	c.Local += o.Local
+	c.Remote += o.Remote
+	c.Bytes += o.Bytes
+}
+
+type changeBucket struct {
+	hour   int64 // Hours since the epoch
+	counts ChangeCounts
+}
+
+// changeHeatmap counts the changes to each directory of each folder, by
+// the hour, so that one can tell which parts of a folder cause most of the
+// syncing.
+type changeHeatmap struct {
+	mut    sync.Mutex
+	dirs   map[string]map[string][]changeBucket // folder -> directory -> buckets, oldest first
+	pruned int64                                // The hour buckets were last pruned
+}
+
+func newChangeHeatmap() *changeHeatmap {
+	return &changeHeatmap{
+		mut:  sync.NewMutex(),
+		dirs: make(map[string]map[string][]changeBucket),
+	}
+}
+
+// record counts the changed files towards their parent directories.
+func (h *changeHeatmap) record(folder string, files []protocol.FileInfo, remote bool, now time.Time) {
+	hour := now.Unix() / 3600
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if hour != h.pruned {
+		h.pruneLocked(hour)
+	}
+
+	dirs, ok := h.dirs[folder]
+	if !ok {
+		dirs = make(map[string][]changeBucket)
+		h.dirs[folder] = dirs
+	}
+	for _, file := range files {
+		if file.IsInvalid() {
+			continue
+		}
+		dir := filepath.Dir(file.Name)
+		buckets := dirs[dir]
+		if len(buckets) == 0 || buckets[len(buckets)-1].hour != hour {
+			buckets = append(buckets, changeBucket{hour: hour})
+		}
+		counts := &buckets[len(buckets)-1].counts
+		if remote {
+			counts.Remote++
+		} else {
+			counts.Local++
+		}
+		if !file.IsDeleted() && !file.IsDirectory() {
+			counts.Bytes += file.Size
+		}
+		dirs[dir] = buckets
+	}
+}
+
+// pruneLocked drops the buckets older than a week, and the directories
+// left without any.
+func (h *changeHeatmap) pruneLocked(hour int64) {
+	for folder, dirs := range h.dirs {
+		for dir, buckets := range dirs {
+			i := 0
+			for i < len(buckets) && hour-buckets[i].hour >= changeHeatmapHours {
+				i++
+			}
+			if i == len(buckets) {
+				delete(dirs, dir)
+			} else if i > 0 {
+				dirs[dir] = append(buckets[:0], buckets[i:]...)
+			}
+		}
+		if len(dirs) == 0 {
+			delete(h.dirs, folder)
+		}
+	}
+	h.pruned = hour
+}
+
+// changes returns the changes of the directories of the folder, those
+// deeper than levels counting towards their ancestor at that depth unless
+// levels is zero or less. The directories that changed most over the last
+// week come first, at most limit of them unless it's zero or less.
+func (h *changeHeatmap) changes(folder string, levels, limit int, now time.Time) []DirChanges {
+	hour := now.Unix() / 3600
+
+	h.mut.Lock()
+	byPath := make(map[string]*DirChanges)
+	for dir, buckets := range h.dirs[folder] {
+		path := dir
+		if levels > 0 {
+			if parts := strings.Split(dir, string(filepath.Separator)); len(parts) > levels {
+				path = filepath.Join(parts[:levels]...)
+			}
+		}
+		changes, ok := byPath[path]
+		if !ok {
+			changes = &DirChanges{Path: path}
+			byPath[path] = changes
+		}
+		for _, bucket := range buckets {
+			switch age := hour - bucket.hour; {
+			case age >= changeHeatmapHours:
+				continue
+			case age < 1:
+				changes.LastHour.add(bucket.counts)
+				fallthrough
+			case age < 24:
+				changes.LastDay.add(bucket.counts)
+			}
+			changes.LastWeek.add(bucket.counts)
+		}
+	}
+	h.mut.Unlock()
+
+	res := make([]DirChanges, 0, len(byPath))
+	for _, changes := range byPath {
+		if changes.LastWeek.total() > 0 {
+			res = append(res, *changes)
+		}
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if ta, tb := res[a].LastWeek.total(), res[b].LastWeek.total(); ta != tb {
+			return ta > tb
+		}
+		return res[a].Path < res[b].Path
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+func (h *changeHeatmap) forget(folder string) {
+	h.mut.Lock()
+	delete(h.dirs, folder)
+	h.mut.Unlock()
+}
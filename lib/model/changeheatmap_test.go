@@ -0,0 +1,73 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestChangeHeatmap(t *testing.T) {
+	h := newChangeHeatmap()
+	now := time.Unix(1000*3600, 0)
+
+	h.record("default", []protocol.FileInfo{
+		{Name: filepath.Join("a", "b", "old"), Size: 1},
+	}, true, now.Add(-30*time.Hour))
+	h.record("default", []protocol.FileInfo{
+		{Name: filepath.Join("a", "b", "day"), Size: 10},
+	}, false, now.Add(-2*time.Hour))
+	h.record("default", []protocol.FileInfo{
+		{Name: filepath.Join("a", "b", "hour"), Size: 100},
+		{Name: filepath.Join("a", "c", "hour"), Size: 100, Deleted: true},
+		{Name: "top", Size: 1000},
+		{Name: "invalid", LocalFlags: protocol.FlagLocalIgnored},
+	}, true, now)
+
+	changes := h.changes("default", 0, 0, now)
+	if len(changes) != 3 {
+		t.Fatalf("Unexpected changes %+v", changes)
+	}
+	ab := changes[0]
+	if ab.Path != filepath.Join("a", "b") {
+		t.Fatalf("Unexpected first directory %v", ab.Path)
+	}
+	if (ab.LastHour != ChangeCounts{Remote: 1, Bytes: 100}) {
+		t.Errorf("Unexpected last hour %+v", ab.LastHour)
+	}
+	if (ab.LastDay != ChangeCounts{Local: 1, Remote: 1, Bytes: 110}) {
+		t.Errorf("Unexpected last day %+v", ab.LastDay)
+	}
+	if (ab.LastWeek != ChangeCounts{Local: 1, Remote: 2, Bytes: 111}) {
+		t.Errorf("Unexpected last week %+v", ab.LastWeek)
+	}
+	if changes[1].Path != "." || changes[2].Path != filepath.Join("a", "c") || changes[2].LastWeek.Bytes != 0 {
+		t.Errorf("Unexpected other directories %+v", changes[1:])
+	}
+
+	// Aggregated to the top level, and limited.
+	changes = h.changes("default", 1, 1, now)
+	if len(changes) != 1 || changes[0].Path != "a" || changes[0].LastWeek.total() != 4 {
+		t.Errorf("Unexpected aggregated changes %+v", changes)
+	}
+
+	// A week later only the latest changes are left.
+	later := now.Add(changeHeatmapHours*time.Hour - time.Hour)
+	h.record("default", nil, false, later)
+	changes = h.changes("default", 1, 0, later)
+	if len(changes) != 2 || changes[0].Path != "a" || changes[0].LastWeek.total() != 2 || changes[0].LastDay.total() != 0 {
+		t.Errorf("Unexpected changes a week later %+v", changes)
+	}
+
+	h.forget("default")
+	if changes := h.changes("default", 0, 0, now); len(changes) != 0 {
+		t.Errorf("Unexpected changes of forgotten folder %+v", changes)
+	}
+}
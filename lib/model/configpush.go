@@ -0,0 +1,208 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// How long we wait for a managed device to acknowledge a config push.
+const configPushTimeout = time.Minute
+
+var (
+	errNoConfigPushKey        = errors.New("no config push key shared with the device")
+	errConfigPushNotAccepted  = errors.New("device doesn't accept config pushes from us")
+	errConfigPushTimeout      = errors.New("timed out waiting for the device to acknowledge the config push")
+	errConfigPushBadSignature = errors.New("bad config push signature")
+	errConfigPushReplayed     = errors.New("config push is older than one already applied")
+	errConfigManagerChanged   = errors.New("config management changed")
+	errNotConfigManager       = errors.New("device is not a config manager of ours")
+)
+
+// configPushTracker keeps the config pushes waiting for acknowledgement,
+// and the last push applied from each manager, so that a push can't be
+// applied again.
+type configPushTracker struct {
+	mut     sync.Mutex
+	waiting map[configPushKey]chan string
+	applied map[protocol.DeviceID]int64
+}
+
+type configPushKey struct {
+	device protocol.DeviceID
+	id     int64
+}
+
+func newConfigPushTracker() *configPushTracker {
+	return &configPushTracker{
+		mut:     sync.NewMutex(),
+		waiting: make(map[configPushKey]chan string),
+		applied: make(map[protocol.DeviceID]int64),
+	}
+}
+
+func (t *configPushTracker) wait(device protocol.DeviceID, id int64) chan string {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	c := make(chan string, 1)
+	t.waiting[configPushKey{device, id}] = c
+	return c
+}
+
+func (t *configPushTracker) done(device protocol.DeviceID, id int64) {
+	t.mut.Lock()
+	delete(t.waiting, configPushKey{device, id})
+	t.mut.Unlock()
+}
+
+func (t *configPushTracker) acknowledge(device protocol.DeviceID, id int64, err string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	key := configPushKey{device, id}
+	if c, ok := t.waiting[key]; ok {
+		c <- err
+		delete(t.waiting, key)
+	}
+}
+
+// apply records the push as applied, unless one as new was applied before.
+func (t *configPushTracker) apply(device protocol.DeviceID, id int64) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if id <= t.applied[device] {
+		return false
+	}
+	t.applied[device] = id
+	return true
+}
+
+// configPushSignature is the HMAC-SHA256 of the push ID and configuration,
+// keyed with the key shared by the manager and the managed device.
+func configPushSignature(key string, id int64, data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], uint64(id))
+	mac.Write(bs[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// PushConfig sends configuration to a device that accepts us as its config
+// manager, and waits until the device has applied it.
+func (m *model) PushConfig(device protocol.DeviceID, cfg config.PushedConfig) error {
+	devCfg, ok := m.cfg.Device(device)
+	if !ok {
+		return errDeviceUnknown
+	}
+	if devCfg.ConfigPushKey == "" {
+		return errNoConfigPushKey
+	}
+
+	m.pmut.RLock()
+	conn, connected := m.conn[device]
+	_, accepted := m.configPushPeers[device]
+	m.pmut.RUnlock()
+	if !connected {
+		return errors.New("device is not connected")
+	}
+	if !accepted {
+		return errConfigPushNotAccepted
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	// The ID increases between pushes, also across restarts, so that the
+	// device can refuse old ones.
+	id := time.Now().UnixNano()
+	ack := m.configPushes.wait(device, id)
+	defer m.configPushes.done(device, id)
+
+	l.Debugf("%v pushing config to %s, id %d", m, device, id)
+	conn.ConfigPush(id, data, configPushSignature(devCfg.ConfigPushKey, id, data))
+
+	select {
+	case resp := <-ack:
+		if resp != "" {
+			return errors.New(resp)
+		}
+		return nil
+	case <-time.After(configPushTimeout):
+		return errConfigPushTimeout
+	}
+}
+
+// ConfigPush applies configuration pushed by a device we accept as config
+// manager, and acknowledges it.
+func (m *model) ConfigPush(deviceID protocol.DeviceID, push protocol.ConfigPush) {
+	// Applying the configuration can close connections, which mustn't be
+	// waited for from the connection itself.
+	go func() {
+		err := m.applyConfigPush(deviceID, push)
+		resp := ""
+		if err != nil {
+			l.Infof("Rejected configuration pushed by %v: %v", deviceID, err)
+			resp = err.Error()
+		} else {
+			l.Infof("Applied configuration pushed by %v", deviceID)
+		}
+
+		m.pmut.RLock()
+		conn, ok := m.conn[deviceID]
+		m.pmut.RUnlock()
+		if ok {
+			conn.ConfigPushResponse(push.ID, resp)
+		}
+	}()
+}
+
+func (m *model) applyConfigPush(deviceID protocol.DeviceID, push protocol.ConfigPush) error {
+	devCfg, ok := m.cfg.Device(deviceID)
+	if !ok || !devCfg.ConfigManager || devCfg.ConfigPushKey == "" {
+		return errNotConfigManager
+	}
+	if !hmac.Equal(push.Signature, configPushSignature(devCfg.ConfigPushKey, push.ID, push.Config)) {
+		return errConfigPushBadSignature
+	}
+
+	var pushed config.PushedConfig
+	if err := json.Unmarshal(push.Config, &pushed); err != nil {
+		return fmt.Errorf("decoding config: %v", err)
+	}
+	raw := m.cfg.RawCopy()
+	if err := pushed.ApplyTo(&raw); err != nil {
+		return err
+	}
+	if !m.configPushes.apply(deviceID, push.ID) {
+		return errConfigPushReplayed
+	}
+
+	waiter, err := m.cfg.Replace(raw)
+	if err != nil {
+		return err
+	}
+	waiter.Wait()
+	return m.cfg.Save()
+}
+
+// ConfigPushResponse hands the acknowledgement of a config push to the
+// waiting PushConfig.
+func (m *model) ConfigPushResponse(deviceID protocol.DeviceID, resp protocol.ConfigPushResponse) {
+	l.Debugf("%v config push %d acknowledged by %s: %q", m, resp.ID, deviceID, resp.Error)
+	m.configPushes.acknowledge(deviceID, resp.ID, resp.Error)
+}
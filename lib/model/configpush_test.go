@@ -0,0 +1,143 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestApplyConfigPush(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	dev1Cfg, _ := w.Device(device1)
+	dev1Cfg.ConfigManager = true
+	dev1Cfg.ConfigPushKey = "key"
+	w.SetDevice(dev1Cfg)
+	m, fc := setupModelWithConnectionFromWrapper(w)
+	dir := createTmpDir()
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.RemoveAll(dir)
+		os.Remove(w.ConfigPath())
+	}()
+
+	if !m.generateClusterConfig(device1).ConfigPush {
+		t.Error("Expected accepting config pushes to be announced")
+	}
+
+	responses := make(chan string, 1)
+	fc.configPushResponseFn = func(_ int64, err string) {
+		responses <- err
+	}
+	push := func(id int64, key string, cfg config.PushedConfig) string {
+		data, err := json.Marshal(cfg)
+		must(t, err)
+		m.ConfigPush(device1, protocol.ConfigPush{ID: id, Config: data, Signature: configPushSignature(key, id, data)})
+		select {
+		case err := <-responses:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the response")
+		}
+		return ""
+	}
+
+	pushedDefault := fcfg.Copy()
+	pushedDefault.Path = "elsewhere"
+	pushedDefault.RescanIntervalS = 123
+	added := config.NewFolderConfiguration(myID, "added", "added", fs.FilesystemTypeBasic, dir)
+	opts := w.Options()
+	opts.MaxSendKbps = 456
+	opts.URUniqueID = "pushed"
+	manager := dev1Cfg.Copy()
+	manager.ConfigManager = false
+	manager.ConfigPushKey = ""
+	manager.Name = "manager"
+	pushed := config.PushedConfig{
+		Folders: []config.FolderConfiguration{pushedDefault, added},
+		Devices: []config.DeviceConfiguration{manager},
+		Options: &opts,
+	}
+
+	if err := push(1, "wrong", pushed); err != errConfigPushBadSignature.Error() {
+		t.Error("Expected a bad signature, got", err)
+	}
+	if err := push(2, "key", pushed); err != "" {
+		t.Fatal("Unexpected error applying push:", err)
+	}
+	if err := push(2, "key", pushed); err != errConfigPushReplayed.Error() {
+		t.Error("Expected a replayed push, got", err)
+	}
+
+	if cfg, _ := w.Folder("default"); cfg.RescanIntervalS != 123 || cfg.Path != fcfg.Path {
+		t.Errorf("Unexpected pushed folder, rescan interval %v, path %v", cfg.RescanIntervalS, cfg.Path)
+	}
+	if _, ok := w.Folder("added"); !ok {
+		t.Error("Pushed folder wasn't added")
+	}
+	if cfg, _ := w.Device(device1); cfg.Name != "manager" || !cfg.ConfigManager || cfg.ConfigPushKey != "key" {
+		t.Errorf("Unexpected pushed device %+v", cfg)
+	}
+	if opts := w.Options(); opts.MaxSendKbps != 456 || opts.URUniqueID == "pushed" {
+		t.Errorf("Unexpected pushed options, max send %v, UR ID %v", opts.MaxSendKbps, opts.URUniqueID)
+	}
+
+	// New folders need a path.
+	pushed.Folders = []config.FolderConfiguration{config.NewFolderConfiguration(myID, "nopath", "", fs.FilesystemTypeBasic, "")}
+	if err := push(3, "key", pushed); err == "" {
+		t.Error("Expected a new folder without a path to be rejected")
+	}
+}
+
+func TestPushConfig(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	dev1Cfg, _ := w.Device(device1)
+	dev1Cfg.ConfigPushKey = "key"
+	w.SetDevice(dev1Cfg)
+	m, fc := setupModelWithConnectionFromWrapper(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	var pushed config.PushedConfig
+	fc.configPushFn = func(id int64, data, signature []byte) {
+		if err := json.Unmarshal(data, &pushed); err != nil {
+			t.Error(err)
+		}
+		resp := ""
+		if string(signature) != string(configPushSignature("key", id, data)) {
+			resp = "bad signature"
+		}
+		go m.ConfigPushResponse(device1, protocol.ConfigPushResponse{ID: id, Error: resp})
+	}
+
+	cfg := config.PushedConfig{Folders: []config.FolderConfiguration{fcfg}}
+	if err := m.PushConfig(device1, cfg); err != errConfigPushNotAccepted {
+		t.Error("Expected the push to be refused, got", err)
+	}
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{ConfigPush: true})
+	if err := m.PushConfig(device1, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(pushed.Folders) != 1 || pushed.Folders[0].ID != "default" {
+		t.Errorf("Unexpected pushed config %+v", pushed)
+	}
+
+	if err := m.PushConfig(device2, cfg); err != errDeviceUnknown && err != errNoConfigPushKey {
+		t.Error("Expected the push to an unknown device to fail, got", err)
+	}
+}
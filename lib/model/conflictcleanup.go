@@ -0,0 +1,106 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// conflictNameExp matches the base name of a conflict copy as created by
+// conflictName, capturing the original name around the conflict marker and
+// the time the conflict copy was made.
+var conflictNameExp = regexp.MustCompile(`^(.*)\.sync-conflict-(\d{8}-\d{6})-[A-Z0-9]*(.*)$`)
+
+type conflictCopy struct {
+	name string
+	when time.Time
+}
+
+// parseConflictName returns the name of the file the conflict copy was made
+// of, and when it was made.
+func parseConflictName(name string) (string, time.Time, bool) {
+	m := conflictNameExp.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return "", time.Time{}, false
+	}
+	when, err := time.ParseInLocation("20060102-150405", m[2], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return filepath.Join(filepath.Dir(name), m[1]+m[3]), when, true
+}
+
+// expiredConflicts returns the conflict copies which are older than maxAge,
+// if positive, or beyond the newest maxPerFile copies of the same file, if
+// positive. A maxPerFile of zero only means that no new conflict copies are
+// made, not that the existing ones should go.
+func expiredConflicts(conflicts []string, now time.Time, maxAge time.Duration, maxPerFile int) []string {
+	byFile := make(map[string][]conflictCopy)
+	for _, name := range conflicts {
+		orig, when, ok := parseConflictName(name)
+		if !ok {
+			continue
+		}
+		byFile[orig] = append(byFile[orig], conflictCopy{name, when})
+	}
+
+	var expired []string
+	for _, copies := range byFile {
+		sort.Slice(copies, func(a, b int) bool {
+			return copies[a].when.After(copies[b].when)
+		})
+		for i, c := range copies {
+			if (maxPerFile > 0 && i >= maxPerFile) || (maxAge > 0 && now.Sub(c.when) > maxAge) {
+				expired = append(expired, c.name)
+			}
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// cleanConflicts removes the conflict copies in the folder which are older
+// than ConflictMaxAgeS or exceed MaxConflicts per file, and scans for their
+// removal.
+func (f *folder) cleanConflicts() {
+	maxAge := time.Duration(f.ConflictMaxAgeS) * time.Second
+	if maxAge <= 0 && f.MaxConflicts <= 0 {
+		return
+	}
+
+	var conflicts []string
+	f.fset.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		if !fi.IsDeleted() && !fi.IsDirectory() && isConflict(fi.FileName()) {
+			conflicts = append(conflicts, fi.FileName())
+		}
+		return true
+	})
+
+	var removed []string
+	for _, name := range expiredConflicts(conflicts, time.Now(), maxAge, f.MaxConflicts) {
+		if err := f.Filesystem().Remove(name); err != nil && !fs.IsNotExist(err) {
+			l.Debugln(f, "removing expired conflict", err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	l.Infof("Removed %d expired conflict copies in folder %v", len(removed), f.Description())
+	if err := f.scanSubdirs(removed, nil); err != nil {
+		l.Debugln(f, "scanning after conflict cleanup", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseConflictName(t *testing.T) {
+	for _, name := range []string{"file.txt", filepath.Join("dir", "file"), "archive.tar.gz"} {
+		conflict := conflictName(name, "ABCDEFG")
+		orig, when, ok := parseConflictName(conflict)
+		if !ok || orig != name {
+			t.Errorf("%s: got %q, %v", conflict, orig, ok)
+		}
+		if d := time.Since(when); d < 0 || d > time.Minute {
+			t.Errorf("%s: unexpected time %v", conflict, when)
+		}
+	}
+	if _, _, ok := parseConflictName("file.txt"); ok {
+		t.Error("Plain file parsed as conflict")
+	}
+}
+
+func TestExpiredConflicts(t *testing.T) {
+	conflicts := []string{
+		"a.sync-conflict-20190101-120000-ABCDEFG.txt",
+		"a.sync-conflict-20190102-120000-ABCDEFG.txt",
+		"a.sync-conflict-20190103-120000-HIJKLMN.txt",
+		"b.sync-conflict-20190101-120000-ABCDEFG",
+		"unrelated.txt",
+	}
+	now := time.Date(2019, 1, 4, 0, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		maxAge     time.Duration
+		maxPerFile int
+		expired    []string
+	}{
+		{0, -1, nil},
+		{48 * time.Hour, -1, []string{conflicts[0], conflicts[3]}},
+		{0, 1, []string{conflicts[0], conflicts[1]}},
+		{0, 0, nil},
+		{48 * time.Hour, 0, []string{conflicts[0], conflicts[3]}},
+		{59 * time.Hour, 2, []string{conflicts[0], conflicts[3]}},
+	}
+	for i, tc := range cases {
+		expired := expiredConflicts(conflicts, now, tc.maxAge, tc.maxPerFile)
+		if len(expired) != len(tc.expired) {
+			t.Errorf("%d: got %v, expected %v", i, expired, tc.expired)
+			continue
+		}
+		for j := range expired {
+			if expired[j] != tc.expired[j] {
+				t.Errorf("%d: got %v, expected %v", i, expired, tc.expired)
+				break
+			}
+		}
+	}
+}
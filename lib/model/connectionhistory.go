@@ -0,0 +1,107 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/connections"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The number of connections to each device kept in its history.
+const connectionHistoryLength = 32
+
+// A ConnectionRecord describes a connection to a device, for
+// troubleshooting links that keep dropping.
+type ConnectionRecord struct {
+	Connected     time.Time `json:"connected"`
+	Disconnected  time.Time `json:"disconnected"` // Zero while connected
+	Duration      float64   `json:"duration"`     // In seconds, up to now while connected
+	Type          string    `json:"type"`
+	Transport     string    `json:"transport"`
+	Address       string    `json:"address"`
+	Relay         bool      `json:"relay"` // The address is that of the relay
+	Crypto        string    `json:"crypto"`
+	ClientName    string    `json:"clientName"`
+	ClientVersion string    `json:"clientVersion"`
+	Error         string    `json:"error,omitempty"` // Why the connection was closed
+}
+
+func newConnectionRecord(conn connections.Connection, hello protocol.HelloResult) ConnectionRecord {
+	rec := ConnectionRecord{
+		Connected:     time.Now(),
+		Type:          conn.Type(),
+		Transport:     conn.Transport(),
+		Relay:         strings.HasPrefix(conn.Type(), "relay"),
+		Crypto:        conn.Crypto(),
+		ClientName:    hello.ClientName,
+		ClientVersion: hello.ClientVersion,
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		rec.Address = addr.String()
+	}
+	return rec
+}
+
+// event returns the fields of the record to add to a DeviceConnected or
+// DeviceDisconnected event.
+func (rec ConnectionRecord) event(event map[string]string) {
+	event["type"] = rec.Type
+	event["transport"] = rec.Transport
+	event["crypto"] = rec.Crypto
+	event["clientVersion"] = rec.ClientVersion
+	if rec.Address != "" {
+		event["addr"] = rec.Address
+		if rec.Relay {
+			event["relay"] = rec.Address
+		}
+	}
+	if !rec.Disconnected.IsZero() {
+		event["connected"] = rec.Connected.Format(time.RFC3339)
+		event["duration"] = rec.Disconnected.Sub(rec.Connected).Truncate(time.Second).String()
+	}
+}
+
+// Must be called with pmut held.
+func (m *model) recordConnectedLocked(device protocol.DeviceID, rec ConnectionRecord) {
+	history := append(m.connHistory[device], rec)
+	if len(history) > connectionHistoryLength {
+		history = append([]ConnectionRecord(nil), history[len(history)-connectionHistoryLength:]...)
+	}
+	m.connHistory[device] = history
+}
+
+// recordDisconnectedLocked completes the record of the current connection
+// to the device and returns it. Must be called with pmut held.
+func (m *model) recordDisconnectedLocked(device protocol.DeviceID, err error) (ConnectionRecord, bool) {
+	history := m.connHistory[device]
+	if len(history) == 0 || !history[len(history)-1].Disconnected.IsZero() {
+		return ConnectionRecord{}, false
+	}
+	rec := &history[len(history)-1]
+	rec.Disconnected = time.Now()
+	rec.Duration = rec.Disconnected.Sub(rec.Connected).Seconds()
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return *rec, true
+}
+
+// ConnectionHistory returns the latest connections to the device, the most
+// recent last.
+func (m *model) ConnectionHistory(device protocol.DeviceID) []ConnectionRecord {
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+
+	history := append([]ConnectionRecord(nil), m.connHistory[device]...)
+	if n := len(history); n > 0 && history[n-1].Disconnected.IsZero() {
+		history[n-1].Duration = time.Since(history[n-1].Connected).Seconds()
+	}
+	return history
+}
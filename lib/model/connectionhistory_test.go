@@ -0,0 +1,71 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestConnectionHistory(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	sub := events.Default.Subscribe(events.DeviceConnected | events.DeviceDisconnected)
+	defer events.Default.Unsubscribe(sub)
+
+	hello := protocol.HelloResult{ClientName: "syncthing", ClientVersion: "v1.2.3"}
+	for i := 0; i < connectionHistoryLength+1; i++ {
+		conn := &fakeConnection{id: device1, model: m}
+		m.AddConnection(conn, hello)
+
+		ev, err := sub.Poll(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data := ev.Data.(map[string]string); ev.Type != events.DeviceConnected || data["clientVersion"] != "v1.2.3" || data["transport"] != "fake" || data["crypto"] != "fake" || data["addr"] != "address" {
+			t.Fatalf("Unexpected connected event %v", ev)
+		}
+
+		history := m.ConnectionHistory(device1)
+		if cur := history[len(history)-1]; !cur.Disconnected.IsZero() || cur.ClientVersion != "v1.2.3" {
+			t.Fatalf("Unexpected current connection %+v", cur)
+		}
+
+		m.Closed(conn, errors.New("testing"))
+		ev, err = sub.Poll(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data := ev.Data.(map[string]string); ev.Type != events.DeviceDisconnected || data["error"] != "testing" || data["duration"] == "" || data["crypto"] != "fake" {
+			t.Fatalf("Unexpected disconnected event %v", ev)
+		}
+	}
+
+	history := m.ConnectionHistory(device1)
+	if len(history) != connectionHistoryLength {
+		t.Fatalf("Expected %d connections, got %d", connectionHistoryLength, len(history))
+	}
+	for _, rec := range history {
+		if rec.Disconnected.IsZero() || rec.Error != "testing" || rec.Address != "address" || rec.Relay {
+			t.Errorf("Unexpected connection record %+v", rec)
+		}
+	}
+	if history := m.ConnectionHistory(device2); len(history) != 0 {
+		t.Errorf("Unexpected history for unconnected device: %v", history)
+	}
+}
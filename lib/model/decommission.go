@@ -0,0 +1,169 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// DecommissionOptions controls what DecommissionFolder requires before
+// removing a folder, and what it removes.
+type DecommissionOptions struct {
+	// MinPeers is the number of devices that must be in sync, and pass the
+	// audit if one is requested. Non-positive means one.
+	MinPeers int
+	// AuditSamples, if positive, is the number of blocks to audit on each
+	// device that is in sync.
+	AuditSamples int
+	// DeleteData also removes the files in the folder's index from disk.
+	// Anything else, such as ignored files and old versions, is kept.
+	DeleteData bool
+}
+
+// A DecommissionReport describes the outcome of decommissioning a folder.
+type DecommissionReport struct {
+	Folder string              `json:"folder"`
+	Label  string              `json:"label"`
+	Path   string              `json:"path"`
+	InSync []protocol.DeviceID `json:"inSync"`
+	// NotInSync holds the devices sharing the folder that don't count
+	// towards the required peers, with the reason.
+	NotInSync   map[string]string `json:"notInSync"`
+	Audits      []AuditReport     `json:"audits,omitempty"`
+	Removed     bool              `json:"removed"`
+	DataDeleted bool              `json:"dataDeleted"`
+	// DataError is set when the folder was removed, but deleting its
+	// contents failed.
+	DataError string    `json:"dataError,omitempty"`
+	Finished  time.Time `json:"finished"`
+}
+
+// DecommissionFolder removes the folder from the configuration and the
+// database, and optionally its data, once enough devices sharing it are
+// connected and fully in sync. If the checks fail nothing is changed and
+// the report says why. A FolderDecommissioned event with the report is
+// emitted when the folder has been removed.
+func (m *model) DecommissionFolder(folder string, opts DecommissionOptions) (DecommissionReport, error) {
+	if opts.MinPeers <= 0 {
+		opts.MinPeers = 1
+	}
+
+	restartMut := m.folderRestartMuts.Get(folder)
+	restartMut.Lock()
+	defer restartMut.Unlock()
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return DecommissionReport{}, errFolderMissing
+	}
+	if cfg.Paused {
+		return DecommissionReport{}, ErrFolderPaused
+	}
+
+	report := DecommissionReport{
+		Folder:    cfg.ID,
+		Label:     cfg.Label,
+		Path:      cfg.Path,
+		NotInSync: make(map[string]string),
+	}
+
+	for _, device := range cfg.DeviceIDs() {
+		if device == m.id {
+			continue
+		}
+		if reason := m.decommissionCheck(device, folder, opts, &report); reason != "" {
+			report.NotInSync[device.String()] = reason
+			continue
+		}
+		report.InSync = append(report.InSync, device)
+	}
+
+	if len(report.InSync) < opts.MinPeers {
+		report.Finished = time.Now()
+		return report, fmt.Errorf("only %d of the required %d devices are in sync: %v", len(report.InSync), opts.MinPeers, report.NotInSync)
+	}
+
+	// The index is gone with the folder, so get its files first. Only those
+	// are what the devices in sync have.
+	var names []string
+	if opts.DeleteData {
+		names = m.localFileNames(folder)
+	}
+	if err := m.removeFolderConfig(folder); err != nil {
+		report.Finished = time.Now()
+		return report, err
+	}
+	report.Removed = true
+
+	if opts.DeleteData {
+		if err := removeIndexedFiles(cfg, names); err != nil {
+			l.Warnf("Deleting data of decommissioned folder %v: %v", cfg.Description(), err)
+			report.DataError = err.Error()
+		} else {
+			report.DataDeleted = true
+		}
+	}
+
+	report.Finished = time.Now()
+	l.Infof("Decommissioned folder %v, in sync on %d devices", cfg.Description(), len(report.InSync))
+	events.Default.Log(events.FolderDecommissioned, report)
+	return report, nil
+}
+
+// decommissionCheck returns the reason the device doesn't count as in
+// sync, or the empty string if it does.
+func (m *model) decommissionCheck(device protocol.DeviceID, folder string, opts DecommissionOptions, report *DecommissionReport) string {
+	m.pmut.RLock()
+	_, ok := m.conn[device]
+	m.pmut.RUnlock()
+	if !ok {
+		return errDeviceNotConnected.Error()
+	}
+
+	comp := m.Completion(device, folder)
+	if comp.NeedItems > 0 || comp.NeedDeletes > 0 || comp.NeedBytes > 0 {
+		return fmt.Sprintf("needs %d items and %d deletes", comp.NeedItems, comp.NeedDeletes)
+	}
+
+	if opts.AuditSamples <= 0 {
+		return ""
+	}
+	audit, err := m.AuditRemote(device, folder, opts.AuditSamples)
+	if err != nil {
+		return fmt.Sprintf("audit: %v", err)
+	}
+	report.Audits = append(report.Audits, audit)
+	if len(audit.Failures) > 0 {
+		return fmt.Sprintf("audit: %d of %d sampled blocks failed", len(audit.Failures), audit.Sampled)
+	}
+	return ""
+}
+
+// removeFolderConfig removes the folder from the configuration and saves
+// it. Committing the change removes the folder from the model and drops
+// its index.
+func (m *model) removeFolderConfig(folder string) error {
+	raw := m.cfg.RawCopy()
+	folders := raw.Folders[:0]
+	for _, fcfg := range raw.Folders {
+		if fcfg.ID != folder {
+			folders = append(folders, fcfg)
+		}
+	}
+	raw.Folders = folders
+
+	waiter, err := m.cfg.Replace(raw)
+	if err != nil {
+		return err
+	}
+	waiter.Wait()
+	return m.cfg.Save()
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestDecommissionFolder(t *testing.T) {
+	m, fc, fcfg, w := setupModelWithConnection()
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	data := map[string][]byte{
+		"good": []byte("good data"),
+		"bad":  []byte("bad data"),
+	}
+	for name, bs := range data {
+		fc.addFile(name, 0644, protocol.FileInfoTypeFile, bs)
+	}
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+		if name == "bad" {
+			return []byte("bad dat!"), nil
+		}
+		return data[name], nil
+	}
+	fc.sendIndexUpdate()
+
+	// Not enough devices share the folder
+	report, err := m.DecommissionFolder("default", DecommissionOptions{MinPeers: 2})
+	if err == nil || report.Removed || len(report.InSync) != 1 || report.InSync[0] != device1 {
+		t.Fatalf("Expected failure with one device in sync, got %v, %+v", err, report)
+	}
+
+	// The audit finds the bad block
+	report, err = m.DecommissionFolder("default", DecommissionOptions{AuditSamples: 10})
+	if err == nil || report.Removed || len(report.Audits) != 1 || report.NotInSync[device1.String()] == "" {
+		t.Fatalf("Expected failed audit, got %v, %+v", err, report)
+	}
+	if _, ok := w.Folder("default"); !ok {
+		t.Fatal("Folder removed despite failed checks")
+	}
+
+	// Only what's in the index is deleted, which the devices in sync have.
+	t0 := time.Now()
+	for {
+		if _, ok := m.CurrentFolderFile("default", "good"); ok {
+			break
+		}
+		if time.Since(t0) > 10*time.Second {
+			t.Fatal("File not pulled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ffs := fcfg.Filesystem()
+	indexed := m.localFileNames("default")
+	for _, name := range []string{"untracked", ".stversions/old"} {
+		if err := ffs.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if fd, err := ffs.Create(name); err != nil {
+			t.Fatal(err)
+		} else {
+			fd.Close()
+		}
+	}
+
+	report, err = m.DecommissionFolder("default", DecommissionOptions{DeleteData: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Removed || !report.DataDeleted {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if _, ok := w.Folder("default"); ok {
+		t.Error("Folder still in the config")
+	}
+	for _, name := range indexed {
+		if _, err := ffs.Lstat(name); !fs.IsNotExist(err) {
+			t.Errorf("Indexed %v not deleted: %v", name, err)
+		}
+	}
+	for _, name := range []string{"untracked", ".stversions/old"} {
+		if _, err := ffs.Lstat(name); err != nil {
+			t.Errorf("Unindexed %v deleted: %v", name, err)
+		}
+	}
+	if _, err := m.DecommissionFolder("default", DecommissionOptions{}); err != errFolderMissing {
+		t.Error("Expected missing folder, got", err)
+	}
+}
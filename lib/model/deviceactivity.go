@@ -7,39 +7,111 @@
 package model
 
 import (
+	"sort"
+	"time"
+
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
 )
 
+const (
+	// The weight of the latest request in the moving averages of the
+	// source stats.
+	sourceStatsWeight = 0.1
+	// A device isn't demoted before this many requests to it.
+	sourceMinRequests = 20
+	// A device failing more than this share of requests is demoted.
+	sourceMaxErrorRate = 0.5
+	// A device this many times slower than the fastest one is demoted.
+	sourceSlowFactor = 10
+	// A demoted device is given a request again after this long without
+	// one, as it would otherwise never get to show that it has recovered.
+	sourceReprobeInterval = time.Minute
+)
+
 // deviceActivity tracks the number of outstanding requests per device and can
-// answer which device is least busy. It is safe for use from multiple
-// goroutines.
+// answer which device is least busy. It also keeps track of how well each
+// device performs as a source of blocks, to avoid the persistently slow or
+// failing ones. It is safe for use from multiple goroutines.
 type deviceActivity struct {
-	act map[protocol.DeviceID]int
-	mut sync.Mutex
+	act   map[protocol.DeviceID]int
+	stats map[protocol.DeviceID]*sourceStats
+	mut   sync.Mutex
+}
+
+type sourceStats struct {
+	rate     float64 // bytes per second, moving average
+	errRate  float64 // share of failed requests, moving average
+	requests int
+	last     time.Time // of the last request
+	demoted  bool      // as of the last request
+}
+
+// A RankedAvailability is an Availability together with how well the
+// device has performed as a source of blocks. Rank 1 is the preferred
+// source.
+type RankedAvailability struct {
+	Availability
+	Rank           int     `json:"rank"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	ErrorRate      float64 `json:"errorRate"`
+	Requests       int     `json:"requests"`
+	Demoted        bool    `json:"demoted"`
 }
 
 func newDeviceActivity() *deviceActivity {
 	return &deviceActivity{
-		act: make(map[protocol.DeviceID]int),
-		mut: sync.NewMutex(),
+		act:   make(map[protocol.DeviceID]int),
+		stats: make(map[protocol.DeviceID]*sourceStats),
+		mut:   sync.NewMutex(),
 	}
 }
 
+// leastBusy returns the device of the given ones expected to return a
+// block the soonest, preferring those that aren't demoted. That is the one
+// with the fewest outstanding requests relative to its measured rate, so
+// that requests are spread across devices by their throughput. Devices
+// whose rate isn't known well enough yet are taken to be as fast as the
+// fastest one.
 func (m *deviceActivity) leastBusy(availability []Availability) (Availability, bool) {
 	m.mut.Lock()
-	low := 2<<30 - 1
-	found := false
-	var selected Availability
-	for _, info := range availability {
-		if usage := m.act[info.ID]; usage < low {
-			low = usage
-			selected = info
-			found = true
+	defer m.mut.Unlock()
+
+	best := m.bestRateLocked()
+	for _, demoted := range []bool{false, true} {
+		var low float64
+		found := false
+		var selected Availability
+		for _, info := range availability {
+			if m.demotedLocked(info.ID, best) && !demoted {
+				continue
+			}
+			if wait := m.expectedWaitLocked(info.ID, best); !found || wait < low {
+				low = wait
+				selected = info
+				found = true
+			}
+		}
+		if found {
+			return selected, true
 		}
 	}
-	m.mut.Unlock()
-	return selected, found
+	return Availability{}, false
+}
+
+// expectedWaitLocked returns how long, relatively, a new request to the
+// device should take given its outstanding requests.
+func (m *deviceActivity) expectedWaitLocked(device protocol.DeviceID, best float64) float64 {
+	pending := float64(m.act[device] + 1)
+	if best <= 0 {
+		// Nothing to compare the devices by but how busy they are.
+		return pending
+	}
+	rate := best
+	if s, ok := m.stats[device]; ok && s.requests >= sourceMinRequests && s.rate > 0 {
+		rate = s.rate
+	}
+	return pending / rate
 }
 
 func (m *deviceActivity) using(availability Availability) {
@@ -53,3 +125,126 @@ func (m *deviceActivity) done(availability Availability) {
 	m.act[availability.ID]--
 	m.mut.Unlock()
 }
+
+// succeeded records a request to the device that returned the given number
+// of bytes after the given time.
+func (m *deviceActivity) succeeded(device protocol.DeviceID, bytes int, took time.Duration) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	s := m.statsLocked(device)
+	if took > 0 {
+		rate := float64(bytes) / took.Seconds()
+		if s.requests == 0 {
+			s.rate = rate
+		} else {
+			s.rate = (1-sourceStatsWeight)*s.rate + sourceStatsWeight*rate
+		}
+	}
+	s.errRate = (1 - sourceStatsWeight) * s.errRate
+	s.requests++
+	s.last = time.Now()
+	m.updateDemotedLocked(device, s)
+}
+
+// failed records a request to the device that failed.
+func (m *deviceActivity) failed(device protocol.DeviceID) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	s := m.statsLocked(device)
+	s.errRate = (1-sourceStatsWeight)*s.errRate + sourceStatsWeight
+	s.requests++
+	s.last = time.Now()
+	m.updateDemotedLocked(device, s)
+}
+
+// forget drops what is known about the device, so that it starts over
+// when it reconnects.
+func (m *deviceActivity) forget(device protocol.DeviceID) {
+	m.mut.Lock()
+	delete(m.stats, device)
+	m.mut.Unlock()
+}
+
+// rank returns the given devices ordered from the preferred source of
+// blocks to the least preferred one.
+func (m *deviceActivity) rank(availability []Availability) []RankedAvailability {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	best := m.bestRateLocked()
+	ranked := make([]RankedAvailability, len(availability))
+	for i, info := range availability {
+		ranked[i].Availability = info
+		ranked[i].Demoted = m.slowOrFailingLocked(info.ID, best)
+		if s, ok := m.stats[info.ID]; ok {
+			ranked[i].BytesPerSecond = s.rate
+			ranked[i].ErrorRate = s.errRate
+			ranked[i].Requests = s.requests
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		if ranked[a].Demoted != ranked[b].Demoted {
+			return !ranked[a].Demoted
+		}
+		return ranked[a].BytesPerSecond > ranked[b].BytesPerSecond
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
+
+func (m *deviceActivity) statsLocked(device protocol.DeviceID) *sourceStats {
+	s, ok := m.stats[device]
+	if !ok {
+		s = &sourceStats{}
+		m.stats[device] = s
+	}
+	return s
+}
+
+func (m *deviceActivity) updateDemotedLocked(device protocol.DeviceID, s *sourceStats) {
+	demoted := m.slowOrFailingLocked(device, m.bestRateLocked())
+	if demoted == s.demoted {
+		return
+	}
+	s.demoted = demoted
+	if demoted {
+		l.Infof("Avoiding device %v as a source of blocks, as it is slow or failing (%.0f B/s, %.0f%% errors)", device, s.rate, 100*s.errRate)
+	} else {
+		l.Infof("Using device %v as a source of blocks again", device)
+	}
+}
+
+// bestRateLocked returns the rate of the fastest device that is known well
+// enough to compare others to.
+func (m *deviceActivity) bestRateLocked() float64 {
+	var best float64
+	for _, s := range m.stats {
+		if s.requests >= sourceMinRequests && s.errRate <= sourceMaxErrorRate && s.rate > best {
+			best = s.rate
+		}
+	}
+	return best
+}
+
+// demotedLocked returns true if the device should only be used when there
+// is no other. A slow or failing device is let through for a request when
+// it has had none for a while and has nothing outstanding, to measure it
+// anew.
+func (m *deviceActivity) demotedLocked(device protocol.DeviceID, best float64) bool {
+	if !m.slowOrFailingLocked(device, best) {
+		return false
+	}
+	return m.act[device] > 0 || time.Since(m.stats[device].last) < sourceReprobeInterval
+}
+
+func (m *deviceActivity) slowOrFailingLocked(device protocol.DeviceID, best float64) bool {
+	s, ok := m.stats[device]
+	if !ok || s.requests < sourceMinRequests {
+		return false
+	}
+	return s.errRate > sourceMaxErrorRate || s.rate*sourceSlowFactor < best
+}
@@ -8,6 +8,7 @@ package model
 
 import (
 	"testing"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/protocol"
 )
@@ -58,3 +59,102 @@ func TestDeviceActivity(t *testing.T) {
 		t.Errorf("Least busy device should be n0 (%v) not %v", n0, lb)
 	}
 }
+
+func TestDeviceActivityDemotion(t *testing.T) {
+	fast := Availability{protocol.DeviceID([32]byte{1, 2, 3, 4}), false}
+	slow := Availability{protocol.DeviceID([32]byte{5, 6, 7, 8}), false}
+	failing := Availability{protocol.DeviceID([32]byte{9, 10, 11, 12}), false}
+	devices := []Availability{failing, slow, fast}
+	na := newDeviceActivity()
+
+	for i := 0; i < sourceMinRequests-1; i++ {
+		na.succeeded(fast.ID, 128<<10, 10*time.Millisecond)
+		na.succeeded(slow.ID, 128<<10, time.Second)
+		na.failed(failing.ID)
+	}
+	// Not enough requests yet to tell.
+	if lb, ok := na.leastBusy(devices); !ok || lb != failing {
+		t.Errorf("Least busy device should be failing (%v) not %v", failing, lb)
+	}
+
+	na.succeeded(fast.ID, 128<<10, 10*time.Millisecond)
+	na.succeeded(slow.ID, 128<<10, time.Second)
+	na.failed(failing.ID)
+
+	na.using(fast)
+	na.using(fast)
+	if lb, ok := na.leastBusy(devices); !ok || lb != fast {
+		t.Errorf("Busy but only good device should be fast (%v) not %v", fast, lb)
+	}
+	if lb, ok := na.leastBusy([]Availability{failing, slow}); !ok || lb != failing {
+		t.Errorf("Demoted devices should still be used when there is nothing else, got %v", lb)
+	}
+
+	ranked := na.rank(devices)
+	expected := []Availability{fast, slow, failing}
+	for i, r := range ranked {
+		if r.Availability != expected[i] || r.Rank != i+1 {
+			t.Errorf("Rank %d is %v, expected %v", r.Rank, r.Availability, expected[i])
+		}
+		if r.Demoted != (r.Availability != fast) {
+			t.Errorf("Device %v has demoted %v", r.ID, r.Demoted)
+		}
+	}
+
+	// A demoted device gets a request again after a while without one.
+	na.done(fast)
+	na.done(fast)
+	na.mut.Lock()
+	na.stats[failing.ID].last = time.Now().Add(-sourceReprobeInterval)
+	na.mut.Unlock()
+	if lb, ok := na.leastBusy(devices); !ok || lb != failing {
+		t.Errorf("Demoted device should be probed, got %v", lb)
+	}
+	na.using(failing)
+	if lb, ok := na.leastBusy(devices); !ok || lb != fast {
+		t.Errorf("Demoted device should be probed once, got %v", lb)
+	}
+	na.done(failing)
+	na.failed(failing.ID)
+	if lb, ok := na.leastBusy(devices); !ok || lb != fast {
+		t.Errorf("Failed probe should keep the device demoted, got %v", lb)
+	}
+
+	// Reconnecting starts over.
+	na.forget(slow.ID)
+	if ranked := na.rank([]Availability{slow}); ranked[0].Demoted || ranked[0].Requests != 0 {
+		t.Errorf("Forgotten device should start over, got %+v", ranked[0])
+	}
+}
+
+func TestDeviceActivityThroughput(t *testing.T) {
+	fast := Availability{protocol.DeviceID([32]byte{1, 2, 3, 4}), false}
+	slow := Availability{protocol.DeviceID([32]byte{5, 6, 7, 8}), false}
+	unknown := Availability{protocol.DeviceID([32]byte{9, 10, 11, 12}), false}
+	na := newDeviceActivity()
+
+	for i := 0; i < sourceMinRequests; i++ {
+		na.succeeded(fast.ID, 128<<10, 10*time.Millisecond)
+		na.succeeded(slow.ID, 128<<10, 40*time.Millisecond)
+	}
+
+	// The fast device gets requests in proportion to its rate, four times
+	// as many as the slow one.
+	devices := []Availability{slow, fast}
+	for i := 0; i < 3; i++ {
+		if lb, ok := na.leastBusy(devices); !ok || lb != fast {
+			t.Fatalf("With %d outstanding requests on it, the device should be fast (%v) not %v", i, fast, lb)
+		}
+		na.using(fast)
+	}
+	na.using(fast)
+	if lb, ok := na.leastBusy(devices); !ok || lb != slow {
+		t.Errorf("With 4 outstanding requests on fast, the device should be slow (%v) not %v", slow, lb)
+	}
+
+	// A device that isn't known yet is taken to be as fast as the fastest.
+	na.using(slow)
+	if lb, ok := na.leastBusy([]Availability{slow, fast, unknown}); !ok || lb != unknown {
+		t.Errorf("Least busy device should be unknown (%v) not %v", unknown, lb)
+	}
+}
@@ -47,6 +47,19 @@ func (p *deviceFolderDownloadState) Has(file string, version protocol.Vector, in
 	return false
 }
 
+// BlockIndexes returns the indexes of the blocks of that specific version
+// of the file that the remote device has in a temporary file.
+func (p *deviceFolderDownloadState) BlockIndexes(file string, version protocol.Vector) []int32 {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+
+	local, ok := p.files[file]
+	if !ok || !local.version.Equal(version) {
+		return nil
+	}
+	return append([]int32(nil), local.blockIndexes...)
+}
+
 // Update updates internal state of what has been downloaded into the temporary
 // files by the remote device for this specific folder.
 func (p *deviceFolderDownloadState) Update(updates []protocol.FileDownloadProgressUpdate) {
@@ -132,6 +145,23 @@ func (t *deviceDownloadState) Has(folder, file string, version protocol.Vector,
 	return f.Has(file, version, index)
 }
 
+// BlockIndexes returns the indexes of the blocks of that specific version
+// of the file that the remote device has in a temporary file.
+func (t *deviceDownloadState) BlockIndexes(folder, file string, version protocol.Vector) []int32 {
+	if t == nil {
+		return nil
+	}
+	t.mut.RLock()
+	f, ok := t.folders[folder]
+	t.mut.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	return f.BlockIndexes(file, version)
+}
+
 // GetBlockCounts returns a map filename -> number of blocks downloaded for the
 // given folder.
 func (t *deviceDownloadState) GetBlockCounts(folder string) map[string]int {
@@ -0,0 +1,98 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// downgradeFeatures probes the filesystem of the folder and, if it lacks
+// support for features that are enabled, disables them in the
+// configuration. That way the folder doesn't fail on every single file,
+// and the reason is kept with the folder.
+func (f *folder) downgradeFeatures() {
+	if f.FilesystemType != fs.FilesystemTypeBasic || f.CheckPath() != nil {
+		return
+	}
+
+	report := fs.Preflight(f.FilesystemType, f.Path)
+	if report.Exists && !report.Write.OK {
+		l.Warnf("Folder %v: Couldn't check which features the filesystem supports: %v", f.Description(), report.Write.Error)
+		return
+	}
+	cfg, changed := featureDowngrades(f.FolderConfiguration, report, time.Now())
+	if !changed {
+		return
+	}
+
+	for _, d := range cfg.Downgrades[len(f.Downgrades):] {
+		l.Infof("Folder %v: Changed setting %v, as %v", f.Description(), d.Setting, d.Reason)
+	}
+	// Committing the changed configuration restarts the folder, thus
+	// waiting for it here would deadlock.
+	if _, err := f.model.cfg.SetFolder(cfg); err != nil {
+		l.Warnf("Folder %v: Failed to disable unsupported features: %v", f.Description(), err)
+		return
+	}
+	if err := f.model.cfg.Save(); err != nil {
+		l.Warnln("Saving config:", err)
+	}
+}
+
+// featureDowngrades returns the folder configuration with the features the
+// report shows the filesystem doesn't support disabled, and whether
+// anything changed. A setting that was changed before is not changed
+// again, as the user re-enabling it means they know better. Nothing is
+// changed when the probes couldn't write to the filesystem, as then it's
+// not known what it supports.
+func featureDowngrades(cfg config.FolderConfiguration, report fs.PreflightReport, now time.Time) (config.FolderConfiguration, bool) {
+	if !report.Exists || !report.Write.OK {
+		return cfg, false
+	}
+
+	cfg = cfg.Copy()
+	changed := false
+	downgrade := func(setting string, check fs.PreflightCheck, what string) bool {
+		for _, d := range cfg.Downgrades {
+			if d.Setting == setting {
+				return false
+			}
+		}
+		reason := fmt.Sprintf("the filesystem doesn't support %v (%v)", what, check.Error)
+		if report.OSType != "" {
+			reason = fmt.Sprintf("the %v filesystem doesn't support %v (%v)", report.OSType, what, check.Error)
+		}
+		cfg.Downgrades = append(cfg.Downgrades, config.FolderDowngrade{
+			Setting: setting,
+			Reason:  reason,
+			When:    now,
+		})
+		changed = true
+		return true
+	}
+
+	// Windows has neither permissions nor, usually, symlinks, which is
+	// handled regardless of the filesystem.
+	if runtime.GOOS != "windows" {
+		if !cfg.IgnorePerms && !report.Permissions.OK && downgrade("ignorePerms", report.Permissions, "permissions") {
+			cfg.IgnorePerms = true
+		}
+		if !cfg.DisableSymlinks && !report.Symlink.OK && downgrade("disableSymlinks", report.Symlink, "symlinks") {
+			cfg.DisableSymlinks = true
+		}
+	}
+	if cfg.FSWatcherEnabled && !report.Watch.OK && !report.Watch.Transient && downgrade("fsWatcherEnabled", report.Watch, "watching for changes") {
+		cfg.FSWatcherEnabled = false
+	}
+
+	return cfg, changed
+}
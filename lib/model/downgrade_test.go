@@ -0,0 +1,67 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestFeatureDowngrades(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions and symlinks are not downgraded on windows")
+	}
+
+	ok := fs.PreflightCheck{OK: true}
+	failed := fs.PreflightCheck{Error: "operation not permitted"}
+	now := time.Now()
+
+	cfg := config.FolderConfiguration{ID: "default", FSWatcherEnabled: true}
+	supported := fs.PreflightReport{Exists: true, Write: ok, Permissions: ok, Symlink: ok, Watch: ok}
+	if _, changed := featureDowngrades(cfg, supported, now); changed {
+		t.Error("Downgraded features on a capable filesystem")
+	}
+
+	fat := fs.PreflightReport{Exists: true, Write: ok, OSType: "vfat", Permissions: failed, Symlink: failed, Watch: ok}
+	fatCfg, changed := featureDowngrades(cfg, fat, now)
+	if !changed || !fatCfg.IgnorePerms || !fatCfg.DisableSymlinks || !fatCfg.FSWatcherEnabled {
+		t.Errorf("Unexpected downgrade on vfat: %v %+v", changed, fatCfg)
+	}
+	if len(fatCfg.Downgrades) != 2 || fatCfg.Downgrades[0].Setting != "ignorePerms" || fatCfg.Downgrades[1].Setting != "disableSymlinks" {
+		t.Errorf("Unexpected downgrades: %+v", fatCfg.Downgrades)
+	}
+	if len(cfg.Downgrades) != 0 {
+		t.Error("Original configuration was modified")
+	}
+
+	// A read only filesystem, where nothing could be probed, isn't known
+	// not to support anything.
+	readOnly := fs.PreflightReport{Exists: true, Write: failed, Permissions: failed, Symlink: failed, Watch: failed}
+	if _, changed := featureDowngrades(cfg, readOnly, now); changed {
+		t.Error("Downgraded features without being able to probe them")
+	}
+
+	// Settings the user re-enabled after a downgrade are left alone.
+	fatCfg.IgnorePerms = false
+	if again, changed := featureDowngrades(fatCfg, fat, now); changed || again.IgnorePerms {
+		t.Errorf("Downgraded a setting again: %+v", again)
+	}
+
+	// Running out of inotify watches doesn't mean watching isn't supported.
+	limited := fs.PreflightReport{Exists: true, Write: ok, Permissions: ok, Symlink: ok, Watch: fs.PreflightCheck{Error: "limits", Transient: true}}
+	if _, changed := featureDowngrades(cfg, limited, now); changed {
+		t.Error("Disabled watcher on a transient failure")
+	}
+	unwatchable := fs.PreflightReport{Exists: true, Write: ok, Permissions: ok, Symlink: ok, Watch: failed}
+	if noWatch, changed := featureDowngrades(cfg, unwatchable, now); !changed || noWatch.FSWatcherEnabled {
+		t.Errorf("Watcher not disabled: %+v", noWatch)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var errNoSuchFile = errors.New("no such file in the index")
+
+type fileSyncState int
+
+const (
+	FileInSync fileSyncState = iota
+	FilePendingDownload
+	FileLocallyChanged // in a receive only folder
+	FileIgnored
+	FileConflict // a conflict copy, or versions that will conflict
+)
+
+func (s fileSyncState) String() string {
+	switch s {
+	case FileInSync:
+		return "in-sync"
+	case FilePendingDownload:
+		return "pending-download"
+	case FileLocallyChanged:
+		return "locally-changed"
+	case FileIgnored:
+		return "ignored"
+	case FileConflict:
+		return "conflict"
+	default:
+		return "unknown"
+	}
+}
+
+// FileStatus is the sync state of a file, and the version of it on each
+// device that has it.
+type FileStatus struct {
+	State    fileSyncState
+	Versions []FileVersion
+}
+
+// FileStatus returns the sync state of the file, the per file equivalent
+// of the folder completion. Ignored files are reported as such even when
+// they aren't in the index.
+func (m *model) FileStatus(folder, file string) (FileStatus, error) {
+	m.fmut.RLock()
+	_, ok := m.folderFiles[folder]
+	ignores := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return FileStatus{}, errFolderMissing
+	}
+
+	versions, err := m.FileVersions(folder, file)
+	if err != nil {
+		return FileStatus{}, err
+	}
+	status := FileStatus{Versions: versions}
+
+	var local *FileVersion
+	for i := range versions {
+		if versions[i].Device == m.id {
+			local = &versions[i]
+			break
+		}
+	}
+
+	switch {
+	case (local != nil && local.File.IsIgnored()) || (ignores != nil && ignores.Match(file).IsIgnored()):
+		status.State = FileIgnored
+	case versions == nil:
+		return FileStatus{}, errNoSuchFile
+	case isConflict(file) || hasConcurrentVersion(versions):
+		status.State = FileConflict
+	case local != nil && local.File.IsReceiveOnlyChanged():
+		status.State = FileLocallyChanged
+	case needsGlobal(versions, local):
+		status.State = FilePendingDownload
+	default:
+		status.State = FileInSync
+	}
+	return status, nil
+}
+
+func hasConcurrentVersion(versions []FileVersion) bool {
+	for _, v := range versions {
+		if v.Ordering == protocol.ConcurrentLesser || v.Ordering == protocol.ConcurrentGreater {
+			return true
+		}
+	}
+	return false
+}
+
+// needsGlobal returns whether the global version is to be pulled, going by
+// the same rules as the need of the database.
+func needsGlobal(versions []FileVersion, local *FileVersion) bool {
+	var global *protocol.FileInfo
+	for i := range versions {
+		if versions[i].Ordering == protocol.Equal {
+			global = &versions[i].File
+			break
+		}
+	}
+	switch {
+	case global == nil || global.IsInvalid():
+		return false
+	case local == nil:
+		return !global.IsDeleted()
+	default:
+		return !local.File.Version.GreaterEqual(global.Version)
+	}
+}
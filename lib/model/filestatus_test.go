@@ -0,0 +1,74 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFileStatus(t *testing.T) {
+	db := db.OpenMemory()
+	m := newModel(defaultCfgWrapper, myID, "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+	m.ServeBackground()
+	defer m.Stop()
+
+	local := protocol.Vector{}.Update(myID.Short())
+	// Update changes the vector in place, so the concurrent ones are
+	// built separately.
+	localChange := protocol.Vector{}.Update(myID.Short()).Update(myID.Short())
+	remoteChange := protocol.Vector{}.Update(myID.Short()).Update(device1.Short())
+	m.folderFiles["default"].Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "insync", Version: local, Sequence: 1},
+		{Name: "pending", Version: local, Sequence: 2},
+		{Name: "changed", Version: local, Sequence: 3, LocalFlags: protocol.FlagLocalReceiveOnly},
+		{Name: "conflicting", Version: localChange, Sequence: 4},
+		{Name: "ignored", Version: local, Sequence: 5, LocalFlags: protocol.FlagLocalIgnored},
+		{Name: "file.sync-conflict-20190101-120000-ABCDEFG", Version: local, Sequence: 6},
+	})
+	m.Index(device1, "default", []protocol.FileInfo{
+		{Name: "insync", Version: local, Sequence: 1},
+		{Name: "pending", Version: local.Update(device1.Short()), Sequence: 2},
+		{Name: "conflicting", Version: remoteChange, Sequence: 3},
+		{Name: "remote", Version: protocol.Vector{}.Update(device1.Short()), Sequence: 4},
+	})
+
+	for name, state := range map[string]fileSyncState{
+		"insync":      FileInSync,
+		"pending":     FilePendingDownload,
+		"remote":      FilePendingDownload,
+		"changed":     FileLocallyChanged,
+		"conflicting": FileConflict,
+		"ignored":     FileIgnored,
+		"file.sync-conflict-20190101-120000-ABCDEFG": FileConflict,
+	} {
+		status, err := m.FileStatus("default", name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if status.State != state {
+			t.Errorf("%s: expected %v, got %v", name, state, status.State)
+		}
+	}
+
+	status, err := m.FileStatus("default", "pending")
+	must(t, err)
+	if len(status.Versions) != 2 || status.Versions[0].Ordering != protocol.Lesser || status.Versions[1].Device != device1 {
+		t.Errorf("Unexpected versions %+v", status.Versions)
+	}
+
+	if _, err := m.FileStatus("default", "missing"); err != errNoSuchFile {
+		t.Error("Expected missing file, got", err)
+	}
+	if _, err := m.FileStatus("missing", "file"); err != errFolderMissing {
+		t.Error("Expected missing folder, got", err)
+	}
+}
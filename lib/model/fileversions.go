@@ -0,0 +1,53 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A FileVersion is the file as a device announced it, and how its version
+// relates to the global version. The versions are vectors, so they tell
+// which change came after which regardless of the clocks of the devices.
+type FileVersion struct {
+	Device   protocol.DeviceID
+	File     protocol.FileInfo
+	Ordering protocol.Ordering // Of the version, compared to the global version
+}
+
+// FileVersions returns the file as we and each device that has it know it,
+// us first.
+func (m *model) FileVersions(folder, file string) ([]FileVersion, error) {
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	global, ok := fset.GetGlobal(file)
+	if !ok {
+		return nil, nil
+	}
+
+	var versions []FileVersion
+	if local, ok := fset.Get(protocol.LocalDeviceID, file); ok {
+		versions = append(versions, FileVersion{Device: m.id, File: local, Ordering: local.Version.Compare(global.Version)})
+	}
+	devices := fset.ListDevices()
+	sort.Slice(devices, func(a, b int) bool {
+		return devices[a].Compare(devices[b]) == -1
+	})
+	for _, device := range devices {
+		if f, ok := fset.Get(device, file); ok {
+			versions = append(versions, FileVersion{Device: device, File: f, Ordering: f.Version.Compare(global.Version)})
+		}
+	}
+	return versions, nil
+}
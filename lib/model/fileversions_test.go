@@ -0,0 +1,49 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFileVersions(t *testing.T) {
+	db := db.OpenMemory()
+	m := newModel(defaultCfgWrapper, myID, "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+	m.ServeBackground()
+	defer m.Stop()
+
+	local := protocol.Vector{}.Update(myID.Short())
+	m.folderFiles["default"].Update(protocol.LocalDeviceID, []protocol.FileInfo{
+		{Name: "file", Version: local, Sequence: 1},
+	})
+	m.Index(device1, "default", []protocol.FileInfo{
+		{Name: "file", Version: local.Update(device1.Short()), Sequence: 1},
+	})
+
+	versions, err := m.FileVersions("default", "file")
+	must(t, err)
+	if len(versions) != 2 {
+		t.Fatalf("Expected two versions, got %+v", versions)
+	}
+	if versions[0].Device != myID || versions[0].Ordering != protocol.Lesser {
+		t.Errorf("Unexpected local version %+v", versions[0])
+	}
+	if versions[1].Device != device1 || versions[1].Ordering != protocol.Equal {
+		t.Errorf("Unexpected version of device1 %+v", versions[1])
+	}
+
+	if versions, err := m.FileVersions("default", "missing"); err != nil || versions != nil {
+		t.Errorf("Expected no versions of a missing file, got %+v, %v", versions, err)
+	}
+	if _, err := m.FileVersions("missing", "file"); err != errFolderMissing {
+		t.Error("Expected missing folder, got", err)
+	}
+}
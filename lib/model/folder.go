@@ -22,11 +22,13 @@ import (
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
 	"github.com/syncthing/syncthing/lib/locations"
+	"github.com/syncthing/syncthing/lib/logger"
 	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/tracing"
 	"github.com/syncthing/syncthing/lib/watchaggregator"
 )
 
@@ -57,6 +59,8 @@ type folder struct {
 	stopped             chan struct{}
 	scanErrors          []FileError
 	scanErrorsMut       sync.Mutex
+	scanProgress        *scanner.Progress // of the scan running, if any
+	scanProgressMut     sync.Mutex
 
 	pullScheduled chan struct{}
 
@@ -71,6 +75,7 @@ type folder struct {
 
 type rescanRequest struct {
 	subdirs []string
+	rehash  *RehashReport // to fill in when rehashing
 	err     chan error
 }
 
@@ -100,6 +105,7 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 		initialScanFinished: make(chan struct{}),
 		stopped:             make(chan struct{}),
 		scanErrorsMut:       sync.NewMutex(),
+		scanProgressMut:     sync.NewMutex(),
 
 		pullScheduled: make(chan struct{}, 1), // This needs to be 1-buffered so that we queue a pull if we're busy when it comes.
 
@@ -126,12 +132,30 @@ func (f *folder) Serve() {
 	pullFailTimer := time.NewTimer(0)
 	<-pullFailTimer.C
 
+	f.downgradeFeatures()
+
 	if f.FSWatcherEnabled && f.CheckHealth() == nil {
 		f.startWatch()
 	}
 
 	initialCompleted := f.initialScanFinished
 
+	var snapshotTimer *time.Timer
+	var snapshotChan <-chan time.Time
+	if f.IndexSnapshotIntervalS > 0 {
+		snapshotTimer = time.NewTimer(f.indexSnapshotDelay())
+		defer snapshotTimer.Stop()
+		snapshotChan = snapshotTimer.C
+	}
+
+	var conflictTimer *time.Timer
+	var conflictChan <-chan time.Time
+	if f.Type == config.FolderTypeSendReceive && f.CleanConflictsIntervalS > 0 {
+		conflictTimer = time.NewTimer(time.Duration(f.CleanConflictsIntervalS) * time.Second)
+		defer conflictTimer.Stop()
+		conflictChan = conflictTimer.C
+	}
+
 	pull := func() {
 		startTime := time.Now()
 		if f.puller.pull() {
@@ -142,7 +166,7 @@ func (f *folder) Serve() {
 		}
 		// Pulling failed, try again later.
 		delay := pause + time.Since(startTime)
-		l.Infof("Folder %v isn't making sync progress - retrying in %v.", f.Description(), delay)
+		f.log().Infof("Folder %v isn't making sync progress - retrying in %v.", f.Description(), delay)
 		pullFailTimer.Reset(delay)
 		if pause < 60*f.basePause() {
 			pause *= 2
@@ -181,21 +205,50 @@ func (f *folder) Serve() {
 			f.scanTimerFired()
 
 		case req := <-f.scanNow:
-			req.err <- f.scanSubdirs(req.subdirs)
+			req.err <- f.scanSubdirs(req.subdirs, req.rehash)
 
 		case next := <-f.scanDelay:
 			f.scanTimer.Reset(next)
 
 		case fsEvents := <-f.watchChan:
 			l.Debugln(f, "filesystem notification rescan")
-			f.scanSubdirs(fsEvents)
+			f.scanSubdirs(fsEvents, nil)
 
 		case <-f.restartWatchChan:
 			f.restartWatch()
+
+		case <-snapshotChan:
+			f.takeIndexSnapshot()
+			snapshotTimer.Reset(f.indexSnapshotDelay())
+
+		case <-conflictChan:
+			f.cleanConflicts()
+			conflictTimer.Reset(time.Duration(f.CleanConflictsIntervalS) * time.Second)
 		}
 	}
 }
 
+// indexSnapshotDelay returns the time until the next index snapshot is due.
+func (f *folder) indexSnapshotDelay() time.Duration {
+	snaps := f.fset.Snapshots()
+	if len(snaps) == 0 {
+		return 0
+	}
+	interval := time.Duration(f.IndexSnapshotIntervalS) * time.Second
+	if delay := interval - time.Since(snaps[len(snaps)-1]); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+func (f *folder) takeIndexSnapshot() {
+	l.Debugln(f, "taking index snapshot")
+	f.fset.TakeSnapshot(time.Now())
+	if f.IndexSnapshotsKeep > 0 {
+		f.fset.PruneSnapshots(f.IndexSnapshotsKeep)
+	}
+}
+
 func (f *folder) BringToFront(string) {}
 
 func (f *folder) Override() {}
@@ -210,6 +263,11 @@ func (f *folder) ignoresUpdated() {
 	if f.FSWatcherEnabled {
 		f.scheduleWatchRestart()
 	}
+	if f.ShareIgnores {
+		// The patterns are announced in the cluster config, which is only
+		// sent when connecting.
+		f.model.closeConns(f.DeviceIDs(), fmt.Errorf("shared ignore patterns of folder %v changed", f.Description()))
+	}
 }
 
 func (f *folder) SchedulePull() {
@@ -278,6 +336,10 @@ func (f *folder) getHealthError() error {
 		return err
 	}
 
+	if err := checkFolderOverlap(f.FolderConfiguration, f.model.cfg.FolderList(), protectedDirs(), f.ignores); err != nil {
+		return err
+	}
+
 	dbPath := locations.Get(locations.Database)
 	if usage, err := fs.NewFilesystem(fs.FilesystemTypeBasic, dbPath).Usage("."); err == nil {
 		if err = config.CheckFreeSpace(f.model.cfg.Options().MinHomeDiskFree, usage); err != nil {
@@ -288,7 +350,10 @@ func (f *folder) getHealthError() error {
 	return nil
 }
 
-func (f *folder) scanSubdirs(subDirs []string) error {
+// scanSubdirs scans the subdirs, or the whole folder if there are none.
+// With a report, the regular files are rehashed even if they seem
+// unchanged, and the report is filled in.
+func (f *folder) scanSubdirs(subDirs []string, rehash *RehashReport) error {
 	if err := f.CheckHealth(); err != nil {
 		return err
 	}
@@ -338,22 +403,46 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 		return ok
 	})
 
+	// Full rescans can take long, and may be cancelled.
+	ctx := f.ctx
+	if len(subDirs) == 0 {
+		typ := FolderJobScan
+		if rehash != nil {
+			typ = FolderJobRehash
+		}
+		var done func()
+		ctx, done = f.model.folderJobs.start(f.ctx, f.ID, typ)
+		defer done()
+	}
+
 	f.setState(FolderScanning)
 
-	fchan := scanner.Walk(f.ctx, scanner.Config{
+	ctx, span := tracing.Start(ctx, "folder.scan")
+	span.SetAttribute("folder", f.ID)
+	span.SetAttribute("subdirs", subDirs)
+	defer span.End()
+
+	defer f.setScanProgress(scanner.Progress{})
+	fchan := scanner.Walk(ctx, scanner.Config{
 		Folder:                f.ID,
 		Subs:                  subDirs,
 		Matcher:               f.ignores,
 		TempLifetime:          time.Duration(f.model.cfg.Options().KeepTemporariesH) * time.Hour,
 		CurrentFiler:          cFiler{f.fset},
 		Filesystem:            mtimefs,
-		IgnorePerms:           f.IgnorePerms,
+		IgnorePerms:           f.IgnoredLocalPermBits() == 0777,
+		IgnorePermBits:        f.IgnoredLocalPermBits(),
 		AutoNormalize:         f.AutoNormalize,
 		Hashers:               f.model.numHashers(f.ID),
 		ShortID:               f.shortID,
 		ProgressTickIntervalS: f.ScanProgressIntervalS,
+		ProgressFn:            f.setScanProgress,
 		UseLargeBlocks:        f.UseLargeBlocks,
 		LocalFlags:            f.localFlags,
+		IdleIOPriority:        f.IdleIOPriority,
+		MaxReadRate:           int64(f.ScanMaxRateMBps) * 1000 * 1000,
+		FileSleep:             time.Duration(f.ScanFileSleepMs) * time.Millisecond,
+		Rehash:                rehash != nil,
 	})
 
 	batchFn := func(fs []protocol.FileInfo) error {
@@ -404,6 +493,9 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 			f.newScanError(res.Path, res.Err)
 			continue
 		}
+		if rehash != nil && f.rehashed(res.File, rehash) {
+			continue
+		}
 		if err := batch.flushIfFull(); err != nil {
 			return err
 		}
@@ -415,6 +507,9 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 	if err := batch.flush(); err != nil {
 		return err
 	}
+	if ctx.Err() != nil {
+		return f.scanCancelled()
+	}
 
 	if len(subDirs) == 0 {
 		// If we have no specific subdirectories to traverse, set it to one
@@ -436,6 +531,10 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 				iterError = err
 				return false
 			}
+			if ctx.Err() != nil {
+				iterError = errFolderJobCancelled
+				return false
+			}
 
 			if ignoredParent != "" && !fs.IsParent(file.Name, ignoredParent) {
 				for _, file := range toIgnore {
@@ -452,7 +551,16 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 				ignoredParent = ""
 			}
 
-			switch ignored := f.ignores.Match(file.Name).IsIgnored(); {
+			ignored := f.ignores.Match(file.Name).IsIgnored()
+			if !file.IsDirectory() && !file.IsDeleted() && f.ignores.HasStatPatterns() {
+				// The file may have grown or aged into being ignored
+				// since it was last scanned.
+				if info, err := mtimefs.Lstat(file.Name); err == nil && info.IsRegular() {
+					ignored = f.ignores.MatchStat(file.Name, info.Size(), info.ModTime()).IsIgnored()
+				}
+			}
+
+			switch {
 			case !file.IsIgnored() && ignored:
 				// File was not ignored at last pass but has been ignored.
 				if file.IsDirectory() {
@@ -527,6 +635,12 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 			toIgnore = toIgnore[:0]
 		}
 
+		if iterError == errFolderJobCancelled {
+			if err := batch.flush(); err != nil {
+				return err
+			}
+			return f.scanCancelled()
+		}
 		if iterError != nil {
 			return iterError
 		}
@@ -541,8 +655,39 @@ func (f *folder) scanSubdirs(subDirs []string) error {
 	return nil
 }
 
+// scanCancelled ends a scan that was cancelled. The changes committed so
+// far are kept, and the rest is found by the next scan.
+func (f *folder) scanCancelled() error {
+	f.log().Infof("Scan of folder %v cancelled", f.Description())
+	f.setState(FolderIdle)
+	return errFolderJobCancelled
+}
+
+// setScanProgress records the progress of the running scan, or that none
+// is running if it's the zero Progress.
+func (f *folder) setScanProgress(p scanner.Progress) {
+	f.scanProgressMut.Lock()
+	defer f.scanProgressMut.Unlock()
+	if p.Folder == "" {
+		f.scanProgress = nil
+		return
+	}
+	f.scanProgress = &p
+}
+
+// ScanProgress returns the progress of the running scan, if any. It is
+// known once the first progress interval passed.
+func (f *folder) ScanProgress() (scanner.Progress, bool) {
+	f.scanProgressMut.Lock()
+	defer f.scanProgressMut.Unlock()
+	if f.scanProgress == nil {
+		return scanner.Progress{}, false
+	}
+	return *f.scanProgress, true
+}
+
 func (f *folder) scanTimerFired() {
-	err := f.scanSubdirs(nil)
+	err := f.scanSubdirs(nil, nil)
 
 	select {
 	case <-f.initialScanFinished:
@@ -551,7 +696,7 @@ func (f *folder) scanTimerFired() {
 		if err != nil {
 			status = "Failed"
 		}
-		l.Infoln(status, "initial scan of", f.Type.String(), "folder", f.Description())
+		f.log().Infoln(status, "initial scan of", f.Type.String(), "folder", f.Description())
 		close(f.initialScanFinished)
 	}
 
@@ -600,7 +745,7 @@ func (f *folder) scheduleWatchRestart() {
 func (f *folder) restartWatch() {
 	f.stopWatch()
 	f.startWatch()
-	f.scanSubdirs(nil)
+	f.scanSubdirs(nil, nil)
 }
 
 // startWatch should only ever be called synchronously. If you want to use
@@ -621,7 +766,7 @@ func (f *folder) startWatchAsync(ctx context.Context) {
 	for {
 		select {
 		case <-timer.C:
-			eventChan, err := f.Filesystem().Watch(".", f.ignores, ctx, f.IgnorePerms)
+			eventChan, err := f.Filesystem().Watch(".", f.ignores, ctx, f.IgnoredLocalPermBits() == 0777)
 			f.watchMut.Lock()
 			prevErr := f.watchErr
 			f.watchErr = err
@@ -640,7 +785,7 @@ func (f *folder) startWatchAsync(ctx context.Context) {
 			}
 			if err != nil {
 				if prevErr == errWatchNotStarted {
-					l.Infof("Error while trying to start filesystem watcher for folder %s, trying again in 1min: %v", f.Description(), err)
+					f.log().Infof("Error while trying to start filesystem watcher for folder %s, trying again in 1min: %v", f.Description(), err)
 				} else {
 					l.Debugf("Repeat error while trying to start filesystem watcher for folder %s, trying again in 1min: %v", f.Description(), err)
 				}
@@ -672,12 +817,12 @@ func (f *folder) setError(err error) {
 
 	if err != nil {
 		if oldErr == nil {
-			l.Warnf("Error on folder %s: %v", f.Description(), err)
+			f.log().Warnf("Error on folder %s: %v", f.Description(), err)
 		} else {
-			l.Infof("Error on folder %s changed: %q -> %q", f.Description(), oldErr, err)
+			f.log().Infof("Error on folder %s changed: %q -> %q", f.Description(), oldErr, err)
 		}
 	} else {
-		l.Infoln("Cleared error on folder", f.Description())
+		f.log().Infoln("Cleared error on folder", f.Description())
 	}
 
 	if f.FSWatcherEnabled {
@@ -702,6 +847,11 @@ func (f *folder) String() string {
 	return fmt.Sprintf("%s/%s@%p", f.Type, f.folderID, f)
 }
 
+// log returns the logger for messages about the folder.
+func (f *folder) log() logger.Logger {
+	return l.With(f.folderID, "")
+}
+
 func (f *folder) newScanError(path string, err error) {
 	f.scanErrorsMut.Lock()
 	f.scanErrors = append(f.scanErrors, FileError{
@@ -749,17 +899,24 @@ func (f *folder) ForceRescan(file protocol.FileInfo) error {
 func (f *folder) updateLocalsFromScanning(fs []protocol.FileInfo) {
 	f.updateLocals(fs)
 
+	f.model.changeHeat.record(f.ID, fs, false, time.Now())
 	f.emitDiskChangeEvents(fs, events.LocalChangeDetected)
 }
 
 func (f *folder) updateLocalsFromPulling(fs []protocol.FileInfo) {
 	f.updateLocals(fs)
 
+	f.model.changeHeat.record(f.ID, fs, true, time.Now())
 	f.emitDiskChangeEvents(fs, events.RemoteChangeDetected)
 }
 
 func (f *folder) updateLocals(fs []protocol.FileInfo) {
+	_, span := tracing.Start(f.ctx, "db.update")
+	span.SetAttribute("folder", f.ID)
+	span.SetAttribute("files", len(fs))
 	f.fset.Update(protocol.LocalDeviceID, fs)
+	span.End()
+	f.model.availability.changed(f.ID, fs)
 
 	filenames := make([]string, len(fs))
 	for i, file := range fs {
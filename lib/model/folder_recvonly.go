@@ -20,6 +20,8 @@ import (
 
 func init() {
 	folderFactories[config.FolderTypeReceiveOnly] = newReceiveOnlyFolder
+	// The data of an encrypted folder is only ever changed by pulling.
+	folderFactories[config.FolderTypeReceiveEncrypted] = newReceiveOnlyFolder
 }
 
 /*
@@ -63,6 +65,9 @@ func newReceiveOnlyFolder(model *model, fset *db.FileSet, ignores *ignore.Matche
 }
 
 func (f *receiveOnlyFolder) Revert() {
+	ctx, done := f.model.folderJobs.start(f.ctx, f.ID, FolderJobRevert)
+	defer done()
+
 	f.setState(FolderScanning)
 	defer f.setState(FolderIdle)
 
@@ -127,6 +132,9 @@ func (f *receiveOnlyFolder) Revert() {
 			f.updateLocalsFromScanning(batch)
 			batch = batch[:0]
 			batchSizeBytes = 0
+			if ctx.Err() != nil {
+				return false
+			}
 		}
 		return true
 	})
@@ -136,9 +144,13 @@ func (f *receiveOnlyFolder) Revert() {
 	batch = batch[:0]
 	batchSizeBytes = 0
 
-	// Handle any queued directories
-	deleted, err := delQueue.flush()
-	if err != nil {
+	// Handle any queued directories, unless cancelled. Those not deleted
+	// remain changed locally, to be reverted another time.
+	var deleted []string
+	var err error
+	if ctx.Err() != nil {
+		f.log().Infof("Revert of folder %v cancelled", f.Description())
+	} else if deleted, err = delQueue.flush(); err != nil {
 		l.Infoln("Revert:", err)
 	}
 	now := time.Now()
@@ -328,6 +328,7 @@ func setupROFolder() (*model, *sendOnlyFolder) {
 
 	f := &sendOnlyFolder{
 		folder: folder{
+			model:               m,
 			fset:                m.folderFiles[fcfg.ID],
 			FolderConfiguration: fcfg,
 		},
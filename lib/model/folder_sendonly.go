@@ -72,7 +72,7 @@ func (f *sendOnlyFolder) pull() bool {
 		}
 
 		file := intf.(protocol.FileInfo)
-		if !file.IsEquivalentOptional(curFile, f.IgnorePerms, false, 0) {
+		if !file.IsEquivalentPermBits(curFile, f.IgnoredLocalPermBits(), false, 0) {
 			return true
 		}
 
@@ -92,6 +92,9 @@ func (f *sendOnlyFolder) pull() bool {
 }
 
 func (f *sendOnlyFolder) Override() {
+	ctx, done := f.model.folderJobs.start(f.ctx, f.ID, FolderJobOverride)
+	defer done()
+
 	f.setState(FolderScanning)
 	batch := make([]protocol.FileInfo, 0, maxBatchSizeFiles)
 	batchSizeBytes := 0
@@ -101,6 +104,10 @@ func (f *sendOnlyFolder) Override() {
 			f.updateLocalsFromScanning(batch)
 			batch = batch[:0]
 			batchSizeBytes = 0
+			if ctx.Err() != nil {
+				f.log().Infof("Override of folder %v cancelled", f.Description())
+				return false
+			}
 		}
 
 		have, ok := f.fset.Get(protocol.LocalDeviceID, need.Name)
@@ -28,6 +28,7 @@ import (
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/sha256"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/tracing"
 	"github.com/syncthing/syncthing/lib/versioner"
 	"github.com/syncthing/syncthing/lib/weakhash"
 )
@@ -99,12 +100,33 @@ type sendReceiveFolder struct {
 	folder
 
 	fs        fs.Filesystem
+	tempFs    fs.Filesystem // The TempDir, if set
 	versioner versioner.Versioner
 
 	queue *jobQueue
 
 	pullErrors    map[string]string // path -> error string
 	pullErrorsMut sync.Mutex
+
+	// The local data plus that to be pulled in the current puller
+	// iteration, against the quota, and the most it would have been had
+	// all the files fit.
+	quotaUsed     int64
+	quotaNeeded   int64
+	quotaExceeded bool
+
+	// Delete the files to delete before pulling the others, to make room.
+	deletionsFirst bool
+}
+
+// quotaError is the error of files and the folder when pulling would take
+// the folder beyond its quota.
+type quotaError struct {
+	quota int64
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("folder quota of %d bytes exceeded", e.quota)
 }
 
 func newSendReceiveFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg config.FolderConfiguration, ver versioner.Versioner, fs fs.Filesystem) service {
@@ -117,6 +139,10 @@ func newSendReceiveFolder(model *model, fset *db.FileSet, ignores *ignore.Matche
 	}
 	f.folder.puller = f
 
+	if f.TempDir != "" {
+		f.tempFs = cfg.TempFilesystem()
+	}
+
 	if f.Copiers == 0 {
 		f.Copiers = defaultCopiers
 	}
@@ -144,6 +170,12 @@ func (f *sendReceiveFolder) pull() bool {
 		return true
 	}
 
+	// Files that vanished while being pulled are no longer needed, so this
+	// happens regardless of there being anything to pull.
+	if promoted := f.handleVanished(); len(promoted) > 0 {
+		go f.Scan(promoted)
+	}
+
 	// If there is nothing to do, don't even enter pulling state.
 	abort := true
 	f.fset.WithNeed(protocol.LocalDeviceID, func(intf db.FileIntf) bool {
@@ -172,17 +204,39 @@ func (f *sendReceiveFolder) pull() bool {
 		return false
 	}
 
+	if err := f.prepareTempDir(); err != nil {
+		f.setError(err)
+		return false
+	}
+
+	deletionsFirst, err := f.checkSpace()
+	if err != nil {
+		f.log().Infof("Not pulling %v: %v", f.Description(), err)
+		f.setError(err)
+		return false
+	}
+	f.deletionsFirst = deletionsFirst
+
 	l.Debugf("%v pulling", f)
 
+	_, span := tracing.Start(f.ctx, "folder.pull")
+	span.SetAttribute("folder", f.folderID)
+	defer span.End()
+
 	f.setState(FolderSyncing)
 	f.clearPullErrors()
 
 	scanChan := make(chan string)
 	go f.pullScannerRoutine(scanChan)
 
+	overQuota := false
 	defer func() {
 		close(scanChan)
-		f.setState(FolderIdle)
+		if overQuota {
+			f.setQuotaExceeded()
+		} else {
+			f.setState(FolderIdle)
+		}
 	}()
 
 	for tries := 0; tries < maxPullerIterations; tries++ {
@@ -196,6 +250,14 @@ func (f *sendReceiveFolder) pull() bool {
 
 		l.Debugln(f, "changed", changed, "on try", tries+1)
 
+		if f.quotaNeeded > f.QuotaBytes && f.QuotaBytes > 0 {
+			// Trying again won't make it fit. Stop until the quota is
+			// raised or data removed, which reschedules a pull.
+			overQuota = true
+			return false
+		}
+		f.quotaExceeded = false
+
 		if changed == 0 {
 			// No files were changed by the puller, so we are in
 			// sync. Any errors were just transitional.
@@ -218,6 +280,36 @@ func (f *sendReceiveFolder) pull() bool {
 	return false
 }
 
+// reserveQuota accounts for the folder growing by the given number of bytes
+// while pulling, which fails if it would take the folder beyond its quota.
+func (f *sendReceiveFolder) reserveQuota(growth int64) error {
+	if f.QuotaBytes <= 0 || growth <= 0 {
+		return nil
+	}
+	f.quotaNeeded += growth
+	if f.quotaUsed+growth > f.QuotaBytes {
+		return &quotaError{f.QuotaBytes}
+	}
+	f.quotaUsed += growth
+	return nil
+}
+
+// setQuotaExceeded sets the quota error on the folder, announcing it the
+// first time.
+func (f *sendReceiveFolder) setQuotaExceeded() {
+	f.setError(&quotaError{f.QuotaBytes})
+	if f.quotaExceeded {
+		return
+	}
+	f.quotaExceeded = true
+	f.log().Infof("Stopped pulling %v at its quota of %d bytes, needing %d bytes", f.Description(), f.QuotaBytes, f.quotaNeeded)
+	events.Default.Log(events.FolderQuotaExceeded, map[string]interface{}{
+		"folder": f.folderID,
+		"quota":  f.QuotaBytes,
+		"needed": f.quotaNeeded,
+	})
+}
+
 // pullerIteration runs a single puller iteration for the given folder and
 // returns the number items that should have been synced (even those that
 // might have failed). One puller iteration handles all files currently
@@ -235,6 +327,9 @@ func (f *sendReceiveFolder) pullerIteration(scanChan chan<- string) int {
 
 	l.Debugln(f, "copiers:", f.Copiers, "pullerPendingKiB:", f.PullerMaxPendingKiB)
 
+	f.quotaUsed = f.fset.LocalSize().Bytes
+	f.quotaNeeded = f.quotaUsed
+
 	updateWg.Add(1)
 	go func() {
 		// dbUpdaterRoutine finishes when dbUpdateChan is closed
@@ -317,7 +412,7 @@ func (f *sendReceiveFolder) processNeeded(dbUpdateChan chan<- dbUpdateJob, copyC
 		file := intf.(protocol.FileInfo)
 
 		switch {
-		case f.ignores.ShouldIgnore(file.Name):
+		case f.shouldIgnore(file):
 			file.SetIgnored(f.shortID)
 			l.Debugln(f, "Handling ignored file", file)
 			dbUpdateChan <- dbUpdateJob{file, dbUpdateInvalidate}
@@ -358,7 +453,7 @@ func (f *sendReceiveFolder) processNeeded(dbUpdateChan chan<- dbUpdateJob, copyC
 				f.queue.Push(file.Name, file.Size, file.ModTime())
 			}
 
-		case runtime.GOOS == "windows" && file.IsSymlink():
+		case (runtime.GOOS == "windows" || f.DisableSymlinks) && file.IsSymlink():
 			file.SetUnsupported(f.shortID)
 			l.Debugln(f, "Invalidating symlink (unsupported)", file.Name)
 			dbUpdateChan <- dbUpdateJob{file, dbUpdateInvalidate}
@@ -392,6 +487,14 @@ func (f *sendReceiveFolder) processNeeded(dbUpdateChan chan<- dbUpdateJob, copyC
 	default:
 	}
 
+	if f.deletionsFirst {
+		// There isn't room for the new files alongside the old ones, so
+		// the deleted files go first, forgoing renaming them.
+		f.processDeletions(fileDeletions, nil, dbUpdateChan, scanChan)
+		fileDeletions = map[string]protocol.FileInfo{}
+		buckets = nil
+	}
+
 	// Now do the file queue. Reorder it according to configuration.
 
 	switch f.Order {
@@ -472,7 +575,15 @@ nextFile:
 			}
 		}
 
-		devices := f.fset.Availability(fileName)
+		// Check whether the file is being moved here from another folder,
+		// in which case we can copy it locally instead of pulling it.
+		if f.migrateFile(fi, dbUpdateChan, scanChan) {
+			changed++
+			f.queue.Done(fileName)
+			continue nextFile
+		}
+
+		devices := f.model.availability.devices(f.fset, f.ID, fi)
 		for _, dev := range devices {
 			if _, ok := f.model.Connection(dev); ok {
 				changed++
@@ -523,6 +634,8 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, dbUpdateChan chan<
 	// care not declare another err.
 	var err error
 
+	file = f.withLocalPermBits(file)
+
 	events.Default.Log(events.ItemStarted, map[string]string{
 		"folder": f.folderID,
 		"item":   file.Name,
@@ -601,7 +714,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, dbUpdateChan chan<
 			}
 
 			// Copy the parent owner and group, if we are supposed to do that.
-			if err := f.maybeCopyOwner(path); err != nil {
+			if err := f.maybeCopyOwner(f.fs, path, path); err != nil {
 				return err
 			}
 
@@ -641,6 +754,33 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, dbUpdateChan chan<
 	dbUpdateChan <- dbUpdateJob{file, dbUpdateHandleDir}
 }
 
+// withLocalPermBits returns the file with the permission bits that aren't
+// applied from other devices taken from the item on disk, so that the
+// permissions recorded in the database are those on disk. The bits never
+// synced are set as for a new item, if there is none.
+func (f *sendReceiveFolder) withLocalPermBits(file protocol.FileInfo) protocol.FileInfo {
+	if f.IgnorePerms || file.NoPermissions || file.IsSymlink() {
+		return file
+	}
+	ignored := f.IgnoredRemotePermBits()
+	if ignored == 0 {
+		return file
+	}
+
+	info, err := f.fs.Lstat(file.Name)
+	if err != nil || info.IsSymlink() || info.IsDir() != file.IsDirectory() {
+		ignored = f.IgnoredPermBits()
+		newPerms := uint32(0644)
+		if file.IsDirectory() {
+			newPerms = 0755
+		}
+		file.Permissions = file.Permissions&^ignored | newPerms&ignored
+		return file
+	}
+	file.Permissions = file.Permissions&^ignored | uint32(info.Mode()&fs.ModePerm)&ignored
+	return file
+}
+
 // checkParent verifies that the thing we are handling lives inside a directory,
 // and not a symlink or regular file. It also resurrects missing parent dirs.
 func (f *sendReceiveFolder) checkParent(file string, scanChan chan<- string) bool {
@@ -751,7 +891,7 @@ func (f *sendReceiveFolder) handleSymlink(file protocol.FileInfo, dbUpdateChan c
 		if err := f.fs.CreateSymlink(file.SymlinkTarget, path); err != nil {
 			return err
 		}
-		return f.maybeCopyOwner(path)
+		return f.maybeCopyOwner(f.fs, path, path)
 	}
 
 	if err = osutil.InWritableDir(createLink, f.fs, file.Name); err == nil {
@@ -906,33 +1046,8 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, db
 		return err
 	}
 	// Check that the target corresponds to what we have in the DB
-	curTarget, ok := f.fset.Get(protocol.LocalDeviceID, target.Name)
-	switch stat, serr := f.fs.Lstat(target.Name); {
-	case serr != nil && fs.IsNotExist(serr):
-		if !ok || curTarget.IsDeleted() {
-			break
-		}
-		scanChan <- target.Name
-		err = errModified
-	case serr != nil:
-		// We can't check whether the file changed as compared to the db,
-		// do not delete.
-		err = serr
-	case !ok:
-		// Target appeared from nowhere
-		scanChan <- target.Name
-		err = errModified
-	default:
-		var fi protocol.FileInfo
-		if fi, err = scanner.CreateFileInfo(stat, target.Name, f.fs); err == nil {
-			if !fi.IsEquivalentOptional(curTarget, f.IgnorePerms, true, protocol.LocalAllFlags) {
-				// Target changed
-				scanChan <- target.Name
-				err = errModified
-			}
-		}
-	}
-	if err != nil {
+	var curTarget protocol.FileInfo
+	if curTarget, err = f.checkTarget(target, scanChan); err != nil {
 		return err
 	}
 
@@ -962,7 +1077,7 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, db
 	// of the source and the creation of the target temp file. Fix-up the metadata,
 	// update the local index of the target file and rename from temp to real name.
 
-	if err = f.performFinish(target, curTarget, true, tempName, dbUpdateChan, scanChan); err != nil {
+	if err = f.performFinish(target, curTarget, true, f.fs, tempName, dbUpdateChan, scanChan); err != nil {
 		return err
 	}
 
@@ -971,6 +1086,131 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, db
 	return nil
 }
 
+// checkTarget verifies that whatever is on disk at the target location
+// corresponds to what we have in the DB, and returns the current DB state
+// of the target.
+func (f *sendReceiveFolder) checkTarget(target protocol.FileInfo, scanChan chan<- string) (protocol.FileInfo, error) {
+	curTarget, ok := f.fset.Get(protocol.LocalDeviceID, target.Name)
+	switch stat, err := f.fs.Lstat(target.Name); {
+	case err != nil && fs.IsNotExist(err):
+		if !ok || curTarget.IsDeleted() {
+			return curTarget, nil
+		}
+		scanChan <- target.Name
+		return curTarget, errModified
+	case err != nil:
+		// We can't check whether the file changed as compared to the db,
+		// do not delete.
+		return curTarget, err
+	case !ok:
+		// Target appeared from nowhere
+		scanChan <- target.Name
+		return curTarget, errModified
+	default:
+		fi, err := scanner.CreateFileInfo(stat, target.Name, f.fs)
+		if err != nil {
+			return curTarget, err
+		}
+		if !fi.IsEquivalentPermBits(curTarget, f.IgnoredLocalPermBits(), true, protocol.LocalAllFlags) {
+			// Target changed
+			scanChan <- target.Name
+			return curTarget, errModified
+		}
+	}
+	return curTarget, nil
+}
+
+// migrateFile attempts to create the given file by copying an identical
+// file that is being removed from another folder on this device, instead of
+// pulling it from the network. It returns true if the file was handled,
+// successfully or not, and false if it should be pulled instead.
+func (f *sendReceiveFolder) migrateFile(file protocol.FileInfo, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) bool {
+	srcFolder, src, ok := f.model.crossFolderSource(f.folderID, file)
+	if !ok {
+		return false
+	}
+	srcCfg, ok := f.model.cfg.Folder(srcFolder)
+	if !ok {
+		return false
+	}
+	srcFs := srcCfg.Filesystem()
+
+	// Check that the source is still what the other folder has in the DB.
+	// We only ever copy; removing the source is left to the other folder.
+	stat, err := srcFs.Lstat(src.Name)
+	if err != nil {
+		return false
+	}
+	if srcFi, err := scanner.CreateFileInfo(stat, src.Name, srcFs); err != nil || !srcFi.IsEquivalentPermBits(src, srcCfg.IgnoredLocalPermBits(), true, protocol.LocalAllFlags) {
+		return false
+	}
+
+	l.Debugln(f, "taking cross folder migration shortcut", srcFolder, src.Name, "->", file.Name)
+
+	curTarget, err := f.checkTarget(file, scanChan)
+	if err != nil {
+		return false
+	}
+	if err := f.CheckAvailableSpace(file.Size); err != nil {
+		return false
+	}
+
+	// The source may have changed on disk since it was scanned, so the copy
+	// must have the blocks of the file we are pulling to be of use.
+	tempName := fs.TempName(file.Name)
+	if err := osutil.Copy(srcFs, f.fs, src.Name, tempName); err != nil {
+		l.Debugln(f, "cross folder migration copy:", err)
+		f.fs.Remove(tempName)
+		return false
+	}
+	if err := verifyFileBlocks(f.fs, tempName, file); err != nil {
+		l.Debugln(f, "cross folder migration verification:", err)
+		f.fs.Remove(tempName)
+		return false
+	}
+
+	// Until here we can still fall back to pulling the file, which reports
+	// its own progress. From here on the migration reports it, once, and a
+	// failure is a pull error like any other.
+	events.Default.Log(events.ItemStarted, map[string]string{
+		"folder": f.folderID,
+		"item":   file.Name,
+		"type":   "file",
+		"action": "update",
+	})
+
+	defer func() {
+		if err != nil {
+			f.newPullError(file.Name, err)
+		}
+		events.Default.Log(events.ItemFinished, map[string]interface{}{
+			"folder": f.folderID,
+			"item":   file.Name,
+			"error":  events.Error(err),
+			"type":   "file",
+			"action": "update",
+		})
+	}()
+
+	blockStatsMut.Lock()
+	blockStats["total"] += len(file.Blocks)
+	blockStats["copyElsewhere"] += len(file.Blocks)
+	blockStatsMut.Unlock()
+
+	if err = f.performFinish(file, curTarget, true, f.fs, tempName, dbUpdateChan, scanChan); err != nil {
+		return true
+	}
+
+	events.Default.Log(events.ItemMigrated, map[string]string{
+		"folder":     f.folderID,
+		"item":       file.Name,
+		"fromFolder": srcFolder,
+		"fromItem":   src.Name,
+	})
+
+	return true
+}
+
 // This is the flow of data and events here, I think...
 //
 // +-----------------------+
@@ -1008,11 +1248,13 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, db
 // handleFile queues the copies and pulls as necessary for a single new or
 // changed file.
 func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- copyBlocksState, dbUpdateChan chan<- dbUpdateJob) {
+	file = f.withLocalPermBits(file)
 	curFile, hasCurFile := f.fset.Get(protocol.LocalDeviceID, file.Name)
 
 	have, _ := blockDiff(curFile.Blocks, file.Blocks)
 
-	tempName := fs.TempName(file.Name)
+	tempFs := f.tempFilesystem()
+	tempName := f.TempName(file.Name)
 
 	populateOffsets(file.Blocks)
 
@@ -1020,10 +1262,26 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 	var blocksSize int64
 	reused := make([]int32, 0, len(file.Blocks))
 
+	f.restorePartial(tempFs, tempName)
+
 	// Check for an old temporary file which might have some blocks we could
-	// reuse.
-	tempBlocks, err := scanner.HashFile(f.ctx, f.fs, tempName, file.BlockSize(), nil, false)
-	if err == nil {
+	// reuse. If we recorded which of its blocks are complete while pulling
+	// it before, we take those rather than hashing the whole file.
+	partial, hasPartial := f.partialBlocks(file, tempName)
+	if hasPartial {
+		complete := make(map[int32]struct{}, len(partial))
+		for _, index := range partial {
+			complete[index] = struct{}{}
+		}
+		for i, block := range file.Blocks {
+			if _, ok := complete[int32(i)]; ok {
+				reused = append(reused, int32(i))
+			} else {
+				blocks = append(blocks, block)
+				blocksSize += int64(block.Size)
+			}
+		}
+	} else if tempBlocks, err := scanner.HashFile(f.ctx, tempFs, tempName, file.BlockSize(), nil, false); err == nil {
 		// Check for any reusable blocks in the temp file
 		tempCopyBlocks, _ := blockDiff(tempBlocks, file.Blocks)
 
@@ -1050,7 +1308,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 			// Otherwise, discard the file ourselves in order for the
 			// sharedpuller not to panic when it fails to exclusively create a
 			// file which already exists
-			osutil.InWritableDir(f.fs.Remove, f.fs, tempName)
+			osutil.InWritableDir(tempFs.Remove, tempFs, tempName)
 		}
 	} else {
 		// Copy the blocks, as we don't want to shuffle them on the FileInfo
@@ -1058,17 +1316,29 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		blocksSize = file.Size
 	}
 
+	growth := file.Size
+	if hasCurFile && !curFile.IsDeleted() && !curFile.IsInvalid() {
+		growth -= curFile.Size
+	}
+	if err := f.reserveQuota(growth); err != nil {
+		f.newPullError(file.Name, err)
+		f.queue.Done(file.Name)
+		return
+	}
+
 	if err := f.CheckAvailableSpace(blocksSize); err != nil {
 		f.newPullError(file.Name, err)
 		f.queue.Done(file.Name)
 		return
 	}
 
-	// Shuffle the blocks
+	// Shuffle the blocks, then put the rarest first. Blocks that are as
+	// rare remain in random order.
 	for i := range blocks {
 		j := rand.Intn(i + 1)
 		blocks[i], blocks[j] = blocks[j], blocks[i]
 	}
+	orderRarestFirst(blocks, file.BlockSize(), f.model.tempBlockHolders(f.folderID, file))
 
 	events.Default.Log(events.ItemStarted, map[string]string{
 		"folder": f.folderID,
@@ -1079,7 +1349,7 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 
 	s := sharedPullerState{
 		file:             file,
-		fs:               f.fs,
+		fs:               tempFs,
 		folder:           f.folderID,
 		tempName:         tempName,
 		realName:         file.Name,
@@ -1095,6 +1365,9 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, copyChan chan<- c
 		mut:              sync.NewRWMutex(),
 		sparse:           !f.DisableSparseFiles,
 		created:          time.Now(),
+		fset:             f.fset,
+		partialSaved:     time.Now(),
+		partialRecorded:  hasPartial,
 	}
 
 	l.Debugf("%v need file %s; copy %d, reused %v", f, file.Name, len(blocks), len(reused))
@@ -1203,11 +1476,14 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 
 		f.model.progressEmitter.Register(state.sharedPullerState)
 
-		folderFilesystems := make(map[string]fs.Filesystem)
-		var folders []string
+		// Blocks are looked for in this folder first, then in the others.
+		folderFilesystems := map[string]fs.Filesystem{f.folderID: f.fs}
+		folders := []string{f.folderID}
 		for folder, cfg := range f.model.cfg.Folders() {
-			folderFilesystems[folder] = cfg.Filesystem()
-			folders = append(folders, folder)
+			if folder != f.folderID {
+				folderFilesystems[folder] = cfg.Filesystem()
+				folders = append(folders, folder)
+			}
 		}
 
 		var file fs.File
@@ -1288,14 +1564,14 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 			}
 
 			if !found {
-				found = f.model.finder.Iterate(folders, block.Hash, func(folder, path string, index int32) bool {
+				found = f.model.finder.IterateOffsets(folders, block.Hash, func(folder, path string, offset int64) bool {
 					fs := folderFilesystems[folder]
 					fd, err := fs.Open(path)
 					if err != nil {
 						return false
 					}
 
-					_, err = fd.ReadAt(buf, int64(state.file.BlockSize())*int64(index))
+					_, err = fd.ReadAt(buf, offset)
 					fd.Close()
 					if err != nil {
 						return false
@@ -1310,7 +1586,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 					if err != nil {
 						state.fail(errors.Wrap(err, "dst write"))
 					}
-					if path == state.file.Name {
+					if folder == f.folderID && path == state.file.Name {
 						state.copiedFromOrigin()
 					}
 					return true
@@ -1342,6 +1618,41 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 	}
 }
 
+// verifyFileBlocks checks that the named file has exactly the blocks of
+// the given file.
+func verifyFileBlocks(ffs fs.Filesystem, name string, file protocol.FileInfo) error {
+	fd, err := ffs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != file.Size {
+		return fmt.Errorf("size mismatch %d != %d", info.Size(), file.Size)
+	}
+
+	var buf []byte
+	var offset int64
+	for _, block := range file.Blocks {
+		if cap(buf) < int(block.Size) {
+			buf = make([]byte, block.Size)
+		}
+		buf = buf[:block.Size]
+		if _, err := fd.ReadAt(buf, offset); err != nil {
+			return err
+		}
+		if err := verifyBuffer(buf, block); err != nil {
+			return err
+		}
+		offset += int64(block.Size)
+	}
+	return nil
+}
+
 func verifyBuffer(buf []byte, block protocol.BlockInfo) error {
 	if len(buf) != int(block.Size) {
 		return fmt.Errorf("length mismatch %d != %d", len(buf), block.Size)
@@ -1437,20 +1748,48 @@ func (f *sendReceiveFolder) pullBlock(state pullBlockState, out chan<- *sharedPu
 		// leastBusy can select another device when someone else asks.
 		activity.using(selected)
 		var buf []byte
+		_, span := tracing.Start(f.ctx, "puller.request")
+		span.SetAttribute("folder", f.folderID)
+		span.SetAttribute("file", state.file.Name)
+		span.SetAttribute("offset", state.block.Offset)
+		span.SetAttribute("size", state.block.Size)
+		span.SetAttribute("device", selected.ID.String())
+		start := time.Now()
 		buf, lastError = f.model.requestGlobal(selected.ID, f.folderID, state.file.Name, state.block.Offset, int(state.block.Size), state.block.Hash, state.block.WeakHash, selected.FromTemporary)
+		took := time.Since(start)
+		span.SetError(lastError)
+		span.End()
 		activity.done(selected)
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "returned error:", lastError)
+			if !selected.FromTemporary {
+				// Temporary files come and go, so failing to get a block
+				// from one isn't held against the device.
+				activity.failed(selected.ID)
+			}
+			if lastError == protocol.ErrNoSuchFile && !selected.FromTemporary {
+				// The device announced the file but doesn't have the block;
+				// its index is probably out of date.
+				f.model.sendRescanHint(selected.ID, f.folderID, state.file.Name)
+			}
 			continue
 		}
 
 		// Verify that the received block matches the desired hash, if not
-		// try pulling it from another device.
-		lastError = verifyBuffer(buf, state.block)
+		// try pulling it from another device. The hashes of encrypted
+		// blocks are tokens that can't be verified against.
+		if f.Type != config.FolderTypeReceiveEncrypted {
+			lastError = verifyBuffer(buf, state.block)
+		}
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "hash mismatch")
+			if !selected.FromTemporary {
+				activity.failed(selected.ID)
+				f.model.reportBlockMismatch(selected.ID, f.folderID, state.file.Name, state.block)
+			}
 			continue
 		}
+		activity.succeeded(selected.ID, len(buf), took)
 
 		// Save the block data we got from the cluster
 		_, err = fd.WriteAt(buf, state.block.Offset)
@@ -1464,16 +1803,29 @@ func (f *sendReceiveFolder) pullBlock(state pullBlockState, out chan<- *sharedPu
 	out <- state.sharedPullerState
 }
 
-func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCurFile bool, tempName string, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) error {
+// performFinish moves the temporary file, in tempFs, into place as the new
+// file.
+func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCurFile bool, tempFs fs.Filesystem, tempName string, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) error {
+	// A temporary file in the TempDir, possibly on another device, is
+	// first moved next to the file. That way the file is replaced by a
+	// rename, never by a copy which could be left half done.
+	if tempFs != f.fs {
+		localName := fs.TempName(file.Name)
+		if err := osutil.RenameOrCopy(tempFs, f.fs, tempName, localName); err != nil {
+			return err
+		}
+		tempFs, tempName = f.fs, localName
+	}
+
 	// Set the correct permission bits on the new file
 	if !f.IgnorePerms && !file.NoPermissions {
-		if err := f.fs.Chmod(tempName, fs.FileMode(file.Permissions&0777)); err != nil {
+		if err := tempFs.Chmod(tempName, fs.FileMode(file.Permissions&0777)); err != nil {
 			return err
 		}
 	}
 
 	// Copy the parent owner and group, if we are supposed to do that.
-	if err := f.maybeCopyOwner(tempName); err != nil {
+	if err := f.maybeCopyOwner(tempFs, tempName, file.Name); err != nil {
 		return err
 	}
 
@@ -1490,16 +1842,32 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 		}
 
 		if !curFile.IsDirectory() && !curFile.IsSymlink() && f.inConflict(curFile.Version, file.Version) {
-			// The new file has been changed in conflict with the existing one. We
-			// should file it away as a conflict instead of just removing or
-			// archiving. Also merge with the version vector we had, to indicate
-			// we have resolved the conflict.
+			// The new file has been changed in conflict with the existing one.
+			// Depending on the conflict policy we either keep the local file,
+			// replace it, or file it away as a conflict copy. In every case
+			// the version vectors are merged, to indicate we have resolved
+			// the conflict.
 			// Directories and symlinks aren't checked for conflicts.
 
-			file.Version = file.Version.Merge(curFile.Version)
-			err = osutil.InWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, f.fs, curFile.Name)
+			switch f.resolveConflict(curFile, file) {
+			case conflictKeepLocal:
+				// Discard what we pulled and announce the local file as a
+				// change superseding both.
+				l.Debugln(f, "conflict resolved in favour of the local file:", file.Name)
+				tempFs.Remove(tempName)
+				curFile.Version = curFile.Version.Merge(file.Version).Update(f.shortID)
+				dbUpdateChan <- dbUpdateJob{curFile, dbUpdateHandleFile}
+				return nil
+			case conflictKeepRemote:
+				l.Debugln(f, "conflict resolved in favour of the pulled file:", file.Name)
+				file.Version = file.Version.Merge(curFile.Version)
+				err = f.deleteItemOnDisk(curFile, scanChan)
+			default:
+				file.Version = file.Version.Merge(curFile.Version)
+				err = osutil.InWritableDir(func(name string) error {
+					return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
+				}, f.fs, curFile.Name)
+			}
 		} else {
 			err = f.deleteItemOnDisk(curFile, scanChan)
 		}
@@ -1510,7 +1878,7 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 
 	// Replace the original content with the new one. If it didn't work,
 	// leave the temp file in place for reuse.
-	if err := osutil.RenameOrCopy(f.fs, f.fs, tempName, file.Name); err != nil {
+	if err := osutil.RenameOrCopy(tempFs, f.fs, tempName, file.Name); err != nil {
 		return err
 	}
 
@@ -1530,7 +1898,7 @@ func (f *sendReceiveFolder) finisherRoutine(in <-chan *sharedPullerState, dbUpda
 			f.queue.Done(state.file.Name)
 
 			if err == nil {
-				err = f.performFinish(state.file, state.curFile, state.hasCurFile, state.tempName, dbUpdateChan, scanChan)
+				err = f.performFinish(state.file, state.curFile, state.hasCurFile, state.fs, state.tempName, dbUpdateChan, scanChan)
 			}
 
 			if err != nil {
@@ -1684,6 +2052,52 @@ func (f *sendReceiveFolder) inConflict(current, replacement protocol.Vector) boo
 	return false
 }
 
+type conflictResolution int
+
+const (
+	conflictMakeCopy conflictResolution = iota
+	conflictKeepLocal
+	conflictKeepRemote
+)
+
+// resolveConflict decides, according to the folder's conflict policy,
+// which of the conflicting local and pulled files is kept. Ties make a
+// conflict copy.
+func (f *sendReceiveFolder) resolveConflict(local, remote protocol.FileInfo) conflictResolution {
+	switch f.ConflictPolicy {
+	case config.ConflictNewest:
+		switch {
+		case remote.ModTime().After(local.ModTime()):
+			return conflictKeepRemote
+		case local.ModTime().After(remote.ModTime()):
+			return conflictKeepLocal
+		}
+	case config.ConflictPreferDevice:
+		preferred := f.ConflictPreferDevice.Short()
+		switch {
+		case f.ConflictPreferDevice == protocol.EmptyDeviceID:
+		case remote.ModifiedBy == preferred:
+			return conflictKeepRemote
+		case local.ModifiedBy == preferred:
+			return conflictKeepLocal
+		}
+	case config.ConflictPreferLocal:
+		return conflictKeepLocal
+	case config.ConflictPreferRemote:
+		return conflictKeepRemote
+	}
+	return conflictMakeCopy
+}
+
+// shouldIgnore is like ShouldIgnore of the ignore patterns, taking the size
+// and age of files into account.
+func (f *sendReceiveFolder) shouldIgnore(file protocol.FileInfo) bool {
+	if file.Type != protocol.FileInfoTypeFile || file.IsDeleted() || !f.ignores.HasStatPatterns() {
+		return f.ignores.ShouldIgnore(file.Name)
+	}
+	return fs.IsTemporary(file.Name) || fs.IsInternal(file.Name) || f.ignores.MatchStat(file.Name, file.Size, file.ModTime()).IsIgnored()
+}
+
 func removeAvailability(availabilities []Availability, availability Availability) []Availability {
 	for i := range availabilities {
 		if availabilities[i] == availability {
@@ -1747,7 +2161,7 @@ func (f *sendReceiveFolder) newPullError(path string, err error) {
 		return
 	}
 
-	l.Infof("Puller (folder %s, item %q): %v", f.Description(), path, err)
+	f.log().Infof("Puller (folder %s, item %q): %v", f.Description(), path, err)
 
 	// Establish context to differentiate from errors while scanning.
 	// Use "syncing" as opposed to "pulling" as the latter might be used
@@ -1885,7 +2299,7 @@ func (f *sendReceiveFolder) itemChanged(stat fs.FileInfo, item protocol.FileInfo
 		return false, errors.Wrap(err, "comparing item on disk to db")
 	}
 
-	return !statItem.IsEquivalentOptional(item, f.IgnorePerms, true, protocol.LocalAllFlags), nil
+	return !statItem.IsEquivalentPermBits(item, f.IgnoredLocalPermBits(), true, protocol.LocalAllFlags), nil
 }
 
 // checkToBeDeleted makes sure the file on disk is compatible with what there is
@@ -1912,7 +2326,9 @@ func (f *sendReceiveFolder) checkToBeDeleted(cur protocol.FileInfo, scanChan cha
 	return nil
 }
 
-func (f *sendReceiveFolder) maybeCopyOwner(path string) error {
+// maybeCopyOwner gives path in ffs the owner and group of the parent
+// directory of name in the folder, if we are supposed to do that.
+func (f *sendReceiveFolder) maybeCopyOwner(ffs fs.Filesystem, path, name string) error {
 	if !f.CopyOwnershipFromParent {
 		// Not supposed to do anything.
 		return nil
@@ -1922,11 +2338,11 @@ func (f *sendReceiveFolder) maybeCopyOwner(path string) error {
 		return nil
 	}
 
-	info, err := f.fs.Lstat(filepath.Dir(path))
+	info, err := f.fs.Lstat(filepath.Dir(name))
 	if err != nil {
 		return errors.Wrap(err, "copy owner from parent")
 	}
-	if err := f.fs.Lchown(path, info.Owner(), info.Group()); err != nil {
+	if err := ffs.Lchown(path, info.Owner(), info.Group()); err != nil {
 		return errors.Wrap(err, "copy owner from parent")
 	}
 	return nil
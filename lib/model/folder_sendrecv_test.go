@@ -10,11 +10,13 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -168,6 +170,41 @@ func TestHandleFile(t *testing.T) {
 	}
 }
 
+func TestHandleFileQuota(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.Remove(f.Filesystem().URI())
+	}()
+	f.QuotaBytes = 100
+	f.quotaUsed = 60
+
+	copyChan := make(chan copyBlocksState, 1)
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+
+	big := protocol.FileInfo{Name: "big", Size: 50, Blocks: blocks[1:2]}
+	f.handleFile(big, copyChan, dbUpdateChan)
+	select {
+	case <-copyChan:
+		t.Error("Unexpected pull of a file beyond the quota")
+	default:
+	}
+	if err, ok := f.pullErrors["big"]; !ok || !strings.Contains(err, (&quotaError{100}).Error()) {
+		t.Errorf("Expected quota error, got %q", err)
+	}
+
+	small := protocol.FileInfo{Name: "small", Size: 40, Blocks: blocks[1:2]}
+	f.handleFile(small, copyChan, dbUpdateChan)
+	select {
+	case <-copyChan:
+	default:
+		t.Error("Expected pull of a file within the quota")
+	}
+	if f.quotaUsed != 100 || f.quotaNeeded != 90 {
+		t.Errorf("Unexpected quota accounting, used %d, needed %d", f.quotaUsed, f.quotaNeeded)
+	}
+}
+
 func TestHandleFileWithTemp(t *testing.T) {
 	// After diff between required and existing we should:
 	// Copy: 2, 5, 8
@@ -299,6 +336,136 @@ func TestCopierFinder(t *testing.T) {
 	finish.fd.Close()
 }
 
+// Test that blocks are copied from files in other folders, which may have a
+// different block size.
+func TestCopierOtherFolder(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.Remove(f.Filesystem().URI())
+	}()
+
+	// A paused folder, so it isn't started, holding a file with the
+	// default block size.
+	other := testFolderConfigTmp()
+	other.ID = "other"
+	other.Paused = true
+	defer os.RemoveAll(other.Filesystem().URI())
+	waiter, err := m.cfg.SetFolder(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+
+	data := make([]byte, 4*protocol.MinBlockSize)
+	rand.Read(data)
+	if err := ioutil.WriteFile(filepath.Join(other.Filesystem().URI(), "source"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcBlocks, err := scanner.Blocks(context.TODO(), bytes.NewReader(data), protocol.MinBlockSize, -1, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.NewFileSet(other.ID, other.Filesystem(), m.db).Update(protocol.LocalDeviceID, []protocol.FileInfo{{
+		Name:    "source",
+		Type:    protocol.FileInfoTypeFile,
+		Size:    int64(len(data)),
+		Version: protocol.Vector{}.Update(myID.Short()),
+		Blocks:  srcBlocks,
+	}})
+
+	// A file with larger blocks, whose last and short block is the last
+	// block of the source file.
+	last := srcBlocks[3]
+	last.Offset = 4 * protocol.MinBlockSize
+	requiredFile := protocol.FileInfo{
+		Name:         "file",
+		Type:         protocol.FileInfoTypeFile,
+		Size:         5 * protocol.MinBlockSize,
+		RawBlockSize: 4 * protocol.MinBlockSize,
+		Version:      protocol.Vector{}.Update(device1.Short()),
+		Blocks: []protocol.BlockInfo{
+			{Offset: 0, Size: 4 * protocol.MinBlockSize, Hash: blocks[1].Hash},
+			last,
+		},
+	}
+
+	copyChan := make(chan copyBlocksState)
+	pullChan := make(chan pullBlockState, 2)
+	finisherChan := make(chan *sharedPullerState, 1)
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+
+	go f.copierRoutine(copyChan, pullChan, finisherChan)
+	f.handleFile(requiredFile, copyChan, dbUpdateChan)
+
+	pull := <-pullChan
+	finish := <-finisherChan
+	defer finish.fd.Close()
+
+	select {
+	case <-pullChan:
+		t.Fatal("Block from the other folder was pulled")
+	default:
+	}
+	if pull.block.Offset != 0 {
+		t.Errorf("Pulled block at offset %d, expected 0", pull.block.Offset)
+	}
+
+	buf := make([]byte, protocol.MinBlockSize)
+	if _, err := finish.fd.ReadAt(buf, last.Offset); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[3*protocol.MinBlockSize:]) {
+		t.Error("Block copied from the wrong offset of the source file")
+	}
+}
+
+func TestWithLocalPermBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bits on Windows")
+	}
+
+	m, f := setupSendReceiveFolder()
+	ffs := f.Filesystem()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.RemoveAll(ffs.URI())
+	}()
+
+	if err := ioutil.WriteFile(filepath.Join(ffs.URI(), "exec"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ffs.Chmod("exec", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	f.IgnorePermBits = "0111"
+	cases := []struct {
+		file  protocol.FileInfo
+		perms uint32
+	}{
+		{protocol.FileInfo{Name: "exec", Type: protocol.FileInfoTypeFile, Permissions: 0640}, 0751},
+		{protocol.FileInfo{Name: "new", Type: protocol.FileInfoTypeFile, Permissions: 0755}, 0644},
+		{protocol.FileInfo{Name: "newdir", Type: protocol.FileInfoTypeDirectory, Permissions: 0700}, 0711},
+	}
+	for _, tc := range cases {
+		if perms := f.withLocalPermBits(tc.file).Permissions; perms != tc.perms {
+			t.Errorf("%s: permissions %o, expected %o", tc.file.Name, perms, tc.perms)
+		}
+	}
+
+	f.IgnorePermBits = ""
+	f.IgnoreRemotePerms = true
+	file := protocol.FileInfo{Name: "exec", Type: protocol.FileInfoTypeFile, Permissions: 0600}
+	if perms := f.withLocalPermBits(file).Permissions; perms != 0755 {
+		t.Errorf("permissions %o, expected those on disk", perms)
+	}
+	file.Name = "new"
+	if perms := f.withLocalPermBits(file).Permissions; perms != 0600 {
+		t.Errorf("new file permissions %o, expected those announced", perms)
+	}
+}
+
 func TestWeakHash(t *testing.T) {
 	// Setup the model/pull environment
 	model, fo := setupSendReceiveFolder()
@@ -933,3 +1100,170 @@ func TestSRConflictReplaceFileByLink(t *testing.T) {
 		t.Fatal("Expected request to scan", confls[0], "got", scan)
 	}
 }
+
+// TestSRConflictPolicy checks that conflicts between a local and a pulled
+// file are resolved according to the folder's conflict policy.
+func TestSRConflictPolicy(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	f.shortID = myID.Short()
+	ffs := f.Filesystem()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.RemoveAll(ffs.URI())
+	}()
+
+	cases := []struct {
+		policy     config.ConflictPolicy
+		prefer     protocol.DeviceID
+		remoteAge  time.Duration
+		keepRemote bool
+		conflict   bool
+	}{
+		{config.ConflictCopy, protocol.EmptyDeviceID, 0, true, true},
+		{config.ConflictNewest, protocol.EmptyDeviceID, -time.Hour, false, false},
+		{config.ConflictNewest, protocol.EmptyDeviceID, time.Hour, true, false},
+		{config.ConflictPreferDevice, device1, -time.Hour, true, false},
+		{config.ConflictPreferDevice, device2, 0, true, true},
+		{config.ConflictPreferLocal, protocol.EmptyDeviceID, time.Hour, false, false},
+		{config.ConflictPreferRemote, protocol.EmptyDeviceID, -time.Hour, true, false},
+	}
+
+	for i, tc := range cases {
+		f.ConflictPolicy = tc.policy
+		f.ConflictPreferDevice = tc.prefer
+		name := fmt.Sprintf("file%d", i)
+
+		cur := createFile(t, name, ffs)
+		cur.Version = protocol.Vector{}.Update(myID.Short())
+		f.updateLocalsFromScanning([]protocol.FileInfo{cur})
+
+		rem := device1.Short()
+		file := cur
+		file.Version = protocol.Vector{}.Update(rem)
+		file.ModifiedBy = rem
+		file.Size = 6
+		file.ModifiedS = cur.ModTime().Add(tc.remoteAge).Unix()
+		tempName := fs.TempName(name)
+		must(t, ioutil.WriteFile(filepath.Join(ffs.URI(), tempName), []byte("remote"), 0644))
+
+		dbUpdateChan := make(chan dbUpdateJob, 1)
+		scanChan := make(chan string, 1)
+		if err := f.performFinish(file, cur, true, f.fs, tempName, dbUpdateChan, scanChan); err != nil {
+			t.Fatal(i, err)
+		}
+
+		job := <-dbUpdateChan
+		bs, err := ioutil.ReadFile(filepath.Join(ffs.URI(), name))
+		must(t, err)
+		if tc.keepRemote {
+			if string(bs) != "remote" || job.file.ModifiedBy != rem {
+				t.Errorf("%d: expected the pulled file to be kept", i)
+			}
+		} else {
+			if len(bs) != 0 || job.file.ModifiedBy == rem {
+				t.Errorf("%d: expected the local file to be kept", i)
+			}
+			if job.file.Version.Compare(file.Version) != protocol.Greater || job.file.Version.Compare(cur.Version) != protocol.Greater {
+				t.Errorf("%d: kept local file doesn't supersede both versions: %v", i, job.file.Version)
+			}
+			if _, err := ffs.Lstat(tempName); !fs.IsNotExist(err) {
+				t.Errorf("%d: temporary file not removed", i)
+			}
+		}
+		if confls := existingConflicts(name, ffs); tc.conflict != (len(confls) == 1) {
+			t.Errorf("%d: unexpected conflict copies %v", i, confls)
+		}
+	}
+}
+
+// TestSRMigrateFromOtherFolder checks that a file which is moved from one
+// folder to another is copied locally from the folder it vanishes from.
+func TestSRMigrateFromOtherFolder(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	ffs := f.Filesystem()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.RemoveAll(ffs.URI())
+	}()
+
+	ocfg := testFolderConfigTmp()
+	ocfg.ID = "other"
+	ofs := ocfg.Filesystem()
+	defer os.RemoveAll(ofs.URI())
+	m.AddFolder(ocfg)
+	w, _ := m.cfg.SetFolder(ocfg)
+	w.Wait()
+
+	// Create the source file in the other folder and put it in the index
+	contents := []byte("some file contents")
+	must(t, ioutil.WriteFile(filepath.Join(ofs.URI(), "source"), contents, 0644))
+	info, err := ofs.Lstat("source")
+	must(t, err)
+	src, err := scanner.CreateFileInfo(info, "source", ofs)
+	must(t, err)
+	src.Blocks, err = scanner.HashFile(context.TODO(), ofs, "source", protocol.MinBlockSize, nil, true)
+	must(t, err)
+	src.Version = protocol.Vector{}.Update(myID.Short())
+	oset := m.folderFiles["other"]
+	oset.Update(protocol.LocalDeviceID, []protocol.FileInfo{src})
+
+	// Without a deletion in the other folder there is nothing to migrate
+	target := src
+	target.Name = "target"
+	target.Version = protocol.Vector{}.Update(device1.Short())
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	scanChan := make(chan string, 1)
+	if f.migrateFile(target, dbUpdateChan, scanChan) {
+		t.Fatal("Unexpected migration of file that is not being removed")
+	}
+
+	// The remote deletes the file in the other folder
+	deleted := src
+	deleted.Deleted = true
+	deleted.Blocks = nil
+	deleted.Version = src.Version.Update(device1.Short())
+	oset.Update(device1, []protocol.FileInfo{deleted})
+
+	// A copy without the blocks we are after is not used, but pulled
+	changed := target
+	changed.Blocks = append([]protocol.BlockInfo(nil), target.Blocks...)
+	changed.Blocks[0].Hash = make([]byte, len(changed.Blocks[0].Hash))
+	if f.migrateFile(changed, dbUpdateChan, scanChan) {
+		t.Fatal("Unexpected migration of file with other blocks")
+	}
+	if _, err := ffs.Lstat(fs.TempName(target.Name)); !fs.IsNotExist(err) {
+		t.Fatal("Temporary file not removed:", err)
+	}
+
+	sub := events.Default.Subscribe(events.ItemStarted | events.ItemFinished | events.ItemMigrated)
+	defer events.Default.Unsubscribe(sub)
+
+	if !f.migrateFile(target, dbUpdateChan, scanChan) {
+		t.Fatal("Expected file to be migrated")
+	}
+	if job := <-dbUpdateChan; job.file.Name != target.Name || job.jobType != dbUpdateHandleFile {
+		t.Fatalf("Unexpected db update %v", job)
+	}
+	if err := equalContents(filepath.Join(ffs.URI(), target.Name), contents); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ofs.Lstat("source"); err != nil {
+		t.Fatal("Source file should be left to its own folder:", err)
+	}
+
+	// The item is started and finished once, around the migration
+	var evs []events.Event
+	for {
+		ev, err := sub.Poll(100 * time.Millisecond)
+		if err != nil {
+			break
+		}
+		evs = append(evs, ev)
+	}
+	if len(evs) != 3 || evs[0].Type != events.ItemStarted || evs[1].Type != events.ItemMigrated || evs[2].Type != events.ItemFinished {
+		t.Fatal("Unexpected events", evs)
+	}
+	if data := evs[1].Data.(map[string]string); data["fromFolder"] != "other" || data["fromItem"] != "source" {
+		t.Fatal("Unexpected event data", data)
+	}
+}
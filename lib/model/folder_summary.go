@@ -23,6 +23,7 @@ const minSummaryInterval = time.Minute
 type FolderSummaryService interface {
 	suture.Service
 	Summary(folder string) (map[string]interface{}, error)
+	ViewSummary(view *ReadView, folder string) (map[string]interface{}, error)
 	OnEventRequest()
 }
 
@@ -77,6 +78,14 @@ func (c *folderSummaryService) String() string {
 }
 
 func (c *folderSummaryService) Summary(folder string) (map[string]interface{}, error) {
+	view := c.model.ReadView(folder)
+	defer view.Release()
+	return c.ViewSummary(view, folder)
+}
+
+// ViewSummary returns the summary of the folder, with sizes and sequences
+// as seen in the given view.
+func (c *folderSummaryService) ViewSummary(view *ReadView, folder string) (map[string]interface{}, error) {
 	var res = make(map[string]interface{})
 
 	errors, err := c.model.FolderErrors(folder)
@@ -89,19 +98,19 @@ func (c *folderSummaryService) Summary(folder string) (map[string]interface{}, e
 
 	res["invalid"] = "" // Deprecated, retains external API for now
 
-	global := c.model.GlobalSize(folder)
+	global := view.GlobalSize(folder)
 	res["globalFiles"], res["globalDirectories"], res["globalSymlinks"], res["globalDeleted"], res["globalBytes"], res["globalTotalItems"] = global.Files, global.Directories, global.Symlinks, global.Deleted, global.Bytes, global.TotalItems()
 
-	local := c.model.LocalSize(folder)
+	local := view.LocalSize(folder)
 	res["localFiles"], res["localDirectories"], res["localSymlinks"], res["localDeleted"], res["localBytes"], res["localTotalItems"] = local.Files, local.Directories, local.Symlinks, local.Deleted, local.Bytes, local.TotalItems()
 
-	need := c.model.NeedSize(folder)
+	need := view.NeedSize(folder)
 	res["needFiles"], res["needDirectories"], res["needSymlinks"], res["needDeletes"], res["needBytes"], res["needTotalItems"] = need.Files, need.Directories, need.Symlinks, need.Deleted, need.Bytes, need.TotalItems()
 
-	if c.cfg.Folders()[folder].Type == config.FolderTypeReceiveOnly {
+	if typ := c.cfg.Folders()[folder].Type; typ == config.FolderTypeReceiveOnly || typ == config.FolderTypeReceiveEncrypted {
 		// Add statistics for things that have changed locally in a receive
 		// only folder.
-		ro := c.model.ReceiveOnlyChangedSize(folder)
+		ro := view.ReceiveOnlyChangedSize(folder)
 		res["receiveOnlyChangedFiles"] = ro.Files
 		res["receiveOnlyChangedDirectories"] = ro.Directories
 		res["receiveOnlyChangedSymlinks"] = ro.Symlinks
@@ -117,8 +126,8 @@ func (c *folderSummaryService) Summary(folder string) (map[string]interface{}, e
 		res["error"] = err.Error()
 	}
 
-	ourSeq, _ := c.model.CurrentSequence(folder)
-	remoteSeq, _ := c.model.RemoteSequence(folder)
+	ourSeq, _ := view.CurrentSequence(folder)
+	remoteSeq, _ := view.RemoteSequence(folder)
 
 	res["version"] = ourSeq + remoteSeq  // legacy
 	res["sequence"] = ourSeq + remoteSeq // new name
@@ -0,0 +1,156 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How often we send a summary of our folders to devices supporting
+// FolderActivity messages.
+const folderActivityInterval = time.Minute
+
+// RemoteFolderStatus is the state of a folder as last reported by a remote
+// device in a FolderActivity message.
+type RemoteFolderStatus struct {
+	Sequence   int64     `json:"sequence"`
+	LocalFiles int64     `json:"localFiles"`
+	LocalBytes int64     `json:"localBytes"`
+	Errors     int       `json:"errors"`
+	Paused     bool      `json:"paused"`
+	State      string    `json:"state"`
+	Updated    time.Time `json:"updated"`
+}
+
+// folderActivitySender periodically sends a FolderActivity message to each
+// connected device that announced support for it.
+type folderActivitySender struct {
+	model    *model
+	interval time.Duration
+	stop     chan struct{}
+}
+
+func newFolderActivitySender(m *model) *folderActivitySender {
+	return &folderActivitySender{
+		model:    m,
+		interval: folderActivityInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *folderActivitySender) Serve() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.model.sendFolderActivity()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *folderActivitySender) Stop() {
+	close(s.stop)
+}
+
+func (s *folderActivitySender) String() string {
+	return "folderActivitySender"
+}
+
+// FolderActivity stores the folder states reported by the remote device, for
+// the folders we share with it.
+func (m *model) FolderActivity(deviceID protocol.DeviceID, activity protocol.FolderActivity) {
+	now := time.Now()
+	statuses := make(map[string]RemoteFolderStatus, len(activity.Folders))
+	for _, folder := range activity.Folders {
+		cfg, ok := m.cfg.Folder(folder.ID)
+		if !ok || !cfg.SharedWith(deviceID) {
+			l.Debugf("Ignoring activity for unshared folder %q from %v", folder.ID, deviceID)
+			continue
+		}
+		statuses[folder.ID] = RemoteFolderStatus{
+			Sequence:   folder.Sequence,
+			LocalFiles: folder.LocalFiles,
+			LocalBytes: folder.LocalBytes,
+			Errors:     int(folder.Errors),
+			Paused:     folder.Paused,
+			State:      folder.State,
+			Updated:    now,
+		}
+	}
+
+	m.pmut.Lock()
+	if _, ok := m.conn[deviceID]; ok {
+		m.remoteFolderStatus[deviceID] = statuses
+	}
+	m.pmut.Unlock()
+}
+
+// RemoteFolderStatus returns the folder states last reported by the given
+// device, keyed by folder ID. It is empty if the device isn't connected or
+// doesn't send FolderActivity messages.
+func (m *model) RemoteFolderStatus(device protocol.DeviceID) map[string]RemoteFolderStatus {
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+	res := make(map[string]RemoteFolderStatus, len(m.remoteFolderStatus[device]))
+	for folder, status := range m.remoteFolderStatus[device] {
+		res[folder] = status
+	}
+	return res
+}
+
+// sendFolderActivity sends our folder states to all connected devices
+// supporting it.
+func (m *model) sendFolderActivity() {
+	m.pmut.RLock()
+	conns := make([]protocol.Connection, 0, len(m.folderActivityPeers))
+	for device := range m.folderActivityPeers {
+		if conn, ok := m.conn[device]; ok {
+			conns = append(conns, conn)
+		}
+	}
+	m.pmut.RUnlock()
+
+	for _, conn := range conns {
+		conn.FolderActivity(m.folderStatuses(conn.ID()))
+	}
+}
+
+// folderStatuses returns the state of the folders we share with the given
+// device.
+func (m *model) folderStatuses(device protocol.DeviceID) []protocol.FolderStatus {
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+
+	var statuses []protocol.FolderStatus
+	for _, folderCfg := range m.cfg.FolderList() {
+		if !folderCfg.SharedWith(device) {
+			continue
+		}
+		status := protocol.FolderStatus{
+			ID:     folderCfg.ID,
+			Paused: folderCfg.Paused,
+		}
+		if fs, ok := m.folderFiles[folderCfg.ID]; ok && !folderCfg.Paused {
+			status.Sequence = fs.Sequence(protocol.LocalDeviceID)
+			local := fs.LocalSize()
+			status.LocalFiles = int64(local.Files + local.Directories + local.Symlinks)
+			status.LocalBytes = local.Bytes
+		}
+		if runner, ok := m.folderRunners[folderCfg.ID]; ok {
+			state, _, _ := runner.getState()
+			status.State = state.String()
+			status.Errors = int32(len(runner.Errors()))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,70 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFolderActivity(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer m.Stop()
+
+	conn := &fakeConnection{id: device1, model: m}
+	m.AddConnection(conn, protocol.HelloResult{})
+
+	if cm := m.generateClusterConfig(device1); !cm.FolderActivity {
+		t.Error("Expected cluster config to announce folder activity support")
+	}
+
+	// Peers not announcing support don't get anything.
+	m.ClusterConfig(device1, protocol.ClusterConfig{})
+	m.sendFolderActivity()
+	if len(conn.folderActivityMessages) != 0 {
+		t.Fatal("Unexpected folder activity message to unsupporting peer")
+	}
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{FolderActivity: true})
+	if len(conn.folderActivityMessages) != 1 {
+		t.Fatal("Expected a folder activity message after the cluster config, got", len(conn.folderActivityMessages))
+	}
+	folders := conn.folderActivityMessages[0]
+	if len(folders) != 1 || folders[0].ID != "default" {
+		t.Fatal("Unexpected folders in activity message:", folders)
+	}
+	seq, _ := m.CurrentSequence("default")
+	if folders[0].Sequence != seq || folders[0].LocalFiles == 0 || folders[0].Paused {
+		t.Error("Unexpected folder status:", folders[0])
+	}
+
+	m.sendFolderActivity()
+	if len(conn.folderActivityMessages) != 2 {
+		t.Fatal("Expected a second folder activity message, got", len(conn.folderActivityMessages))
+	}
+
+	m.FolderActivity(device1, protocol.FolderActivity{
+		Folders: []protocol.FolderStatus{
+			{ID: "default", Sequence: 42, LocalBytes: 1234, Errors: 2, State: "syncing"},
+			{ID: "unshared", Sequence: 1},
+		},
+	})
+	statuses := m.RemoteFolderStatus(device1)
+	if len(statuses) != 1 {
+		t.Fatal("Expected status for exactly one folder, got", statuses)
+	}
+	if st := statuses["default"]; st.Sequence != 42 || st.LocalBytes != 1234 || st.Errors != 2 || st.State != "syncing" || st.Updated.IsZero() {
+		t.Error("Unexpected remote folder status:", st)
+	}
+
+	m.Closed(conn, errors.New("testing"))
+	if statuses := m.RemoteFolderStatus(device1); len(statuses) != 0 {
+		t.Error("Expected status to be forgotten on disconnect, got", statuses)
+	}
+}
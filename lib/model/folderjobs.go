@@ -0,0 +1,132 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The types of folder jobs.
+const (
+	FolderJobScan     = "scan"
+	FolderJobOverride = "override"
+	FolderJobRevert   = "revert"
+	FolderJobAudit    = "audit"
+	FolderJobRehash   = "rehash"
+	FolderJobMove     = "move"
+)
+
+var (
+	errNoSuchFolderJob    = errors.New("no such folder job")
+	errFolderJobCancelled = errors.New("cancelled")
+)
+
+// A FolderJob is a long running operation on a folder, such as a full
+// rescan or a revert. Cancelling a job stops it after the batch at hand;
+// the changes of batches already committed to the database are kept.
+type FolderJob struct {
+	ID        int       `json:"id"`
+	Folder    string    `json:"folder"`
+	Type      string    `json:"type"`
+	Started   time.Time `json:"started"`
+	Cancelled bool      `json:"cancelled"`
+}
+
+// folderJobTracker keeps the folder jobs currently running.
+type folderJobTracker struct {
+	mut     sync.Mutex
+	serial  int // of the last job started
+	running map[int]*runningFolderJob
+}
+
+type runningFolderJob struct {
+	FolderJob
+	cancel context.CancelFunc
+}
+
+func newFolderJobTracker() *folderJobTracker {
+	return &folderJobTracker{
+		mut:     sync.NewMutex(),
+		running: make(map[int]*runningFolderJob),
+	}
+}
+
+// start registers a job, and returns the context to run it in and a
+// function to call when it's done.
+func (t *folderJobTracker) start(ctx context.Context, folder, typ string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.serial++
+	id := t.serial
+	t.running[id] = &runningFolderJob{
+		FolderJob: FolderJob{
+			ID:      id,
+			Folder:  folder,
+			Type:    typ,
+			Started: time.Now(),
+		},
+		cancel: cancel,
+	}
+	l.Debugf("Started folder job %d, %s of %q", id, typ, folder)
+
+	return ctx, func() {
+		cancel()
+		t.mut.Lock()
+		delete(t.running, id)
+		t.mut.Unlock()
+	}
+}
+
+// jobs returns the running jobs of the folder, or of all folders if it's
+// empty, oldest first.
+func (t *folderJobTracker) jobs(folder string) []FolderJob {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	jobs := make([]FolderJob, 0, len(t.running))
+	for _, job := range t.running {
+		if folder == "" || job.Folder == folder {
+			jobs = append(jobs, job.FolderJob)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs
+}
+
+func (t *folderJobTracker) cancel(id int) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	job, ok := t.running[id]
+	if !ok {
+		return errNoSuchFolderJob
+	}
+	l.Infof("Cancelling %s of folder %q", job.Type, job.Folder)
+	job.Cancelled = true
+	job.cancel()
+	return nil
+}
+
+// FolderJobs returns the long running operations currently running on the
+// folder, or on all folders if it's empty.
+func (m *model) FolderJobs(folder string) []FolderJob {
+	return m.folderJobs.jobs(folder)
+}
+
+// CancelFolderJob stops the folder job with the given ID.
+func (m *model) CancelFolderJob(id int) error {
+	return m.folderJobs.cancel(id)
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestFolderJobTracker(t *testing.T) {
+	tr := newFolderJobTracker()
+
+	scanCtx, scanDone := tr.start(context.Background(), "a", FolderJobScan)
+	revertCtx, revertDone := tr.start(context.Background(), "b", FolderJobRevert)
+	defer revertDone()
+
+	jobs := tr.jobs("")
+	if len(jobs) != 2 || jobs[0].Type != FolderJobScan || jobs[1].Type != FolderJobRevert {
+		t.Fatalf("unexpected jobs %+v", jobs)
+	}
+	if jobs := tr.jobs("b"); len(jobs) != 1 || jobs[0].Folder != "b" {
+		t.Fatalf("unexpected jobs of folder b %+v", jobs)
+	}
+
+	if err := tr.cancel(jobs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if revertCtx.Err() == nil {
+		t.Error("cancelled job's context not done")
+	}
+	if scanCtx.Err() != nil {
+		t.Error("other job's context done")
+	}
+	if jobs := tr.jobs("b"); len(jobs) != 1 || !jobs[0].Cancelled {
+		t.Errorf("cancelled job not marked as such until done: %+v", jobs)
+	}
+
+	scanDone()
+	if jobs := tr.jobs("a"); len(jobs) != 0 {
+		t.Errorf("job still listed when done: %+v", jobs)
+	}
+	if err := tr.cancel(jobs[0].ID); err != errNoSuchFolderJob {
+		t.Errorf("cancelling done job returned %v, expected %v", err, errNoSuchFolderJob)
+	}
+}
+
+func TestFolderJobCancelledOverride(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.Type = config.FolderTypeSendOnly
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	// More needed files than fit in a batch.
+	files := make([]protocol.FileInfo, maxBatchSizeFiles+10)
+	for i := range files {
+		files[i] = protocol.FileInfo{
+			Name:    fmt.Sprintf("f%d", i),
+			Size:    1,
+			Version: protocol.Vector{}.Update(device1.Short()),
+			Blocks:  []protocol.BlockInfo{{Size: 1}},
+		}
+	}
+	m.Index(device1, fcfg.ID, files)
+	if n := m.NeedSize(fcfg.ID).Files; int(n) != len(files) {
+		t.Fatalf("need %d files, expected %d", n, len(files))
+	}
+
+	// A folder stopped before the override cancels it right away, so that
+	// it stops after committing the first batch.
+	m.fmut.RLock()
+	f := newSendOnlyFolder(m, m.folderFiles[fcfg.ID], m.folderIgnores[fcfg.ID], fcfg, nil, nil).(*sendOnlyFolder)
+	m.fmut.RUnlock()
+	f.cancel()
+	f.Override()
+
+	if n := m.NeedSize(fcfg.ID).Files; int(n) != len(files)-maxBatchSizeFiles {
+		t.Errorf("need %d files after cancelled override, expected %d", n, len(files)-maxBatchSizeFiles)
+	}
+	if jobs := m.FolderJobs(fcfg.ID); len(jobs) != 0 {
+		t.Errorf("job still listed when done: %+v", jobs)
+	}
+}
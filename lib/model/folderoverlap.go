@@ -0,0 +1,128 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/locations"
+)
+
+// A folderOverlapError is the error of a folder whose root overlaps the
+// directories Syncthing keeps its configuration and database in, or the
+// root of another folder. Syncing it would sync files which Syncthing or
+// the other folder changes in the meantime, corrupting them.
+type folderOverlapError struct {
+	msg string
+}
+
+func (e *folderOverlapError) Error() string {
+	return e.msg
+}
+
+func isFolderOverlapError(err error) bool {
+	_, ok := err.(*folderOverlapError)
+	return ok
+}
+
+// protectedDirs returns the directories no folder must sync.
+func protectedDirs() []string {
+	return []string{
+		locations.GetBaseDir(locations.ConfigBaseDir),
+		locations.Get(locations.Database),
+	}
+}
+
+// checkFolderRootOverlap returns an error if the root of the folder is, or
+// is inside, a protected directory or is the root of one of the other
+// folders. Such a folder can't be fixed by ignore patterns, so it's
+// refused when added.
+func checkFolderRootOverlap(cfg config.FolderConfiguration, others []config.FolderConfiguration, protected []string) error {
+	root, ok := overlapPath(cfg)
+	if !ok {
+		return nil
+	}
+	for _, dir := range protected {
+		if p := normalizeOverlapPath(dir); p == root || fs.IsParent(root, p) {
+			return &folderOverlapError{fmt.Sprintf("folder path is inside the Syncthing configuration or database directory %s", dir)}
+		}
+	}
+	for _, other := range others {
+		if other.ID == cfg.ID {
+			continue
+		}
+		if otherRoot, ok := overlapPath(other); ok && otherRoot == root {
+			return &folderOverlapError{fmt.Sprintf("folder path is the same as that of folder %s", other.Description())}
+		}
+	}
+	return nil
+}
+
+// checkFolderOverlap returns an error if the folder overlaps a protected
+// directory or another folder. Protected directories and other folders
+// inside the folder are fine as long as they are ignored.
+func checkFolderOverlap(cfg config.FolderConfiguration, others []config.FolderConfiguration, protected []string, ignores *ignore.Matcher) error {
+	if err := checkFolderRootOverlap(cfg, others, protected); err != nil {
+		return err
+	}
+	root, ok := overlapPath(cfg)
+	if !ok {
+		return nil
+	}
+	ignored := func(path string) bool {
+		if ignores == nil {
+			return false
+		}
+		rel, err := filepath.Rel(root, path)
+		return err == nil && ignores.ShouldIgnore(rel)
+	}
+	for _, dir := range protected {
+		if p := normalizeOverlapPath(dir); fs.IsParent(p, root) && !ignored(p) {
+			return &folderOverlapError{fmt.Sprintf("folder contains the Syncthing configuration or database directory %s, which must be ignored", dir)}
+		}
+	}
+	for _, other := range others {
+		if other.ID == cfg.ID {
+			continue
+		}
+		if otherRoot, ok := overlapPath(other); ok && fs.IsParent(otherRoot, root) && !ignored(otherRoot) {
+			return &folderOverlapError{fmt.Sprintf("folder contains folder %s, which must be ignored", other.Description())}
+		}
+	}
+	return nil
+}
+
+// overlapPath returns the normalized root of a folder on the local disk.
+func overlapPath(cfg config.FolderConfiguration) (string, bool) {
+	if cfg.FilesystemType != fs.FilesystemTypeBasic || cfg.Path == "" {
+		return "", false
+	}
+	return normalizeOverlapPath(cfg.Filesystem().URI()), true
+}
+
+// normalizeOverlapPath returns the absolute path, with symlinks resolved
+// where it exists, in a form that is comparable to other such paths.
+func normalizeOverlapPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	path = strings.TrimPrefix(path, `\\?\`)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		// Case insensitive, usually.
+		path = fs.UnicodeLowercase(path)
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+)
+
+func TestCheckFolderOverlap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syncthing-overlap-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	protected := []string{filepath.Join(dir, "config")}
+	folder := func(id string, path ...string) config.FolderConfiguration {
+		return config.NewFolderConfiguration(myID, id, id, fs.FilesystemTypeBasic, filepath.Join(append([]string{dir}, path...)...))
+	}
+	ignoring := func(pattern string) *ignore.Matcher {
+		m := ignore.New(fs.NewFilesystem(fs.FilesystemTypeBasic, dir))
+		if err := m.Parse(bytes.NewBufferString(pattern), ".stignore"); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	cases := []struct {
+		name    string
+		cfg     config.FolderConfiguration
+		others  []config.FolderConfiguration
+		ignores *ignore.Matcher
+		overlap bool
+	}{
+		{"separate", folder("a", "a"), []config.FolderConfiguration{folder("b", "b")}, nil, false},
+		{"config dir", folder("a", "config"), nil, nil, true},
+		{"inside config dir", folder("a", "config", "sub"), nil, nil, true},
+		{"contains config dir", folder("a"), nil, nil, true},
+		{"contains ignored config dir", folder("a"), nil, ignoring("/config"), false},
+		{"same as other", folder("a", "a"), []config.FolderConfiguration{folder("b", "a")}, nil, true},
+		{"same as other, ignored", folder("a", "a"), []config.FolderConfiguration{folder("b", "a")}, ignoring("*"), true},
+		{"contains other", folder("a", "a"), []config.FolderConfiguration{folder("b", "a", "b")}, nil, true},
+		{"contains ignored other", folder("a", "a"), []config.FolderConfiguration{folder("b", "a", "b")}, ignoring("b"), false},
+		{"inside other", folder("a", "a", "b"), []config.FolderConfiguration{folder("b", "a")}, nil, false},
+	}
+	for _, tc := range cases {
+		err := checkFolderOverlap(tc.cfg, append(tc.others, tc.cfg), protected, tc.ignores)
+		if tc.overlap && !isFolderOverlapError(err) {
+			t.Errorf("%s: expected overlap error, got %v", tc.name, err)
+		} else if !tc.overlap && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+}
+
+func TestFolderOverlapRefused(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	defer func() {
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+	m := setupModel(w)
+	defer m.Stop()
+
+	other := config.NewFolderConfiguration(myID, "other", "other", fs.FilesystemTypeBasic, fcfg.Path)
+	if _, err := w.SetFolder(other); err == nil {
+		t.Error("folder with the same path as another one accepted")
+	}
+
+	// Changes to other existing folders are not refused.
+	fcfg.Label = "changed"
+	if _, err := w.SetFolder(fcfg); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFolderOverlapState(t *testing.T) {
+	s := newStateTracker("default")
+	s.setError(&folderOverlapError{"overlap"})
+	if state, _, err := s.getState(); state != FolderOverlap || err == nil {
+		t.Errorf("state %v, error %v after overlap error", state, err)
+	}
+	s.setError(nil)
+	if state, _, _ := s.getState(); state != FolderIdle {
+		t.Errorf("state %v after clearing the error", state)
+	}
+}
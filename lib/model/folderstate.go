@@ -21,6 +21,7 @@ const (
 	FolderScanWaiting
 	FolderSyncing
 	FolderError
+	FolderOverlap // an error, due to the folder overlapping another or the config
 )
 
 func (s folderState) String() string {
@@ -35,6 +36,8 @@ func (s folderState) String() string {
 		return "syncing"
 	case FolderError:
 		return "error"
+	case FolderOverlap:
+		return "overlap"
 	default:
 		return "unknown"
 	}
@@ -56,9 +59,10 @@ func newStateTracker(id string) stateTracker {
 	}
 }
 
-// setState sets the new folder state, for states other than FolderError.
+// setState sets the new folder state, for states other than FolderError
+// and FolderOverlap.
 func (s *stateTracker) setState(newState folderState) {
-	if newState == FolderError {
+	if newState == FolderError || newState == FolderOverlap {
 		panic("must use setError")
 	}
 
@@ -97,8 +101,9 @@ func (s *stateTracker) getState() (current folderState, changed time.Time, err e
 	return
 }
 
-// setError sets the folder state to FolderError with the specified error or
-// to FolderIdle if the error is nil
+// setError sets the folder state to FolderError, or FolderOverlap for an
+// overlap error, with the specified error or to FolderIdle if the error is
+// nil
 func (s *stateTracker) setError(err error) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -111,6 +116,9 @@ func (s *stateTracker) setError(err error) {
 	if err != nil {
 		eventData["error"] = err.Error()
 		s.current = FolderError
+		if isFolderOverlapError(err) {
+			s.current = FolderOverlap
+		}
 	} else {
 		s.current = FolderIdle
 	}
@@ -0,0 +1,31 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+)
+
+// writeTemplateIgnores gives the new folder the ignore patterns of the
+// template it was created from, unless it already has an .stignore, e.g.
+// as it's being added for existing data. It must be called before the
+// folder is added, to load the patterns.
+func writeTemplateIgnores(cfg config.FolderConfiguration, tmpl config.FolderTemplate) {
+	ffs := cfg.Filesystem()
+	if _, err := ffs.Lstat(".stignore"); !fs.IsNotExist(err) {
+		return
+	}
+	if err := cfg.CreateRoot(); err != nil {
+		l.Warnln("Failed to create folder root directory", err)
+		return
+	}
+	if err := ignore.WriteIgnores(ffs, ".stignore", tmpl.Ignores); err != nil {
+		l.Warnf("Writing ignores of folder template %q to %v: %v", tmpl.Name, cfg.Description(), err)
+	}
+}
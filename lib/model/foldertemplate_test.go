@@ -0,0 +1,59 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	srand "github.com/syncthing/syncthing/lib/rand"
+)
+
+func TestAutoAcceptFolderTemplate(t *testing.T) {
+	cfg := defaultAutoAcceptCfg.Copy()
+	tmpl := config.NewFolderTemplate("media")
+	tmpl.Versioning = config.VersioningConfiguration{Type: "trashcan", Params: map[string]string{"cleanoutDays": "7"}}
+	tmpl.RescanIntervalS = 600
+	tmpl.Order = config.OrderSmallestFirst
+	tmpl.Ignores = []string{"*.tmp"}
+	cfg.FolderTemplates = []config.FolderTemplate{tmpl}
+	cfg.Options.DefaultFolderTemplate = "media"
+
+	wcfg, m := newState(cfg)
+	defer m.Stop()
+	defer os.Remove(wcfg.ConfigPath())
+	id := srand.String(8)
+	defer os.RemoveAll(id)
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{
+				ID:    id,
+				Label: id,
+			},
+		},
+	})
+
+	fcfg, ok := wcfg.Folder(id)
+	if !ok || !fcfg.SharedWith(device1) {
+		t.Fatal("expected shared", id)
+	}
+	if fcfg.Template != "media" || fcfg.Versioning.Type != "trashcan" || fcfg.Versioning.Params["cleanoutDays"] != "7" || fcfg.RescanIntervalS != 600 || fcfg.Order != config.OrderSmallestFirst {
+		t.Errorf("Template not applied: %+v", fcfg)
+	}
+	bs, err := ioutil.ReadFile(filepath.Join(fcfg.Path, ".stignore"))
+	must(t, err)
+	if string(bs) != "*.tmp\n" {
+		t.Errorf("Unexpected ignores %q", bs)
+	}
+	if ignores, _, err := m.GetIgnores(id); err != nil || len(ignores) != 1 || ignores[0] != "*.tmp" {
+		t.Errorf("Unexpected loaded ignores %v, %v", ignores, err)
+	}
+}
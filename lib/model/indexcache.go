@@ -0,0 +1,102 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The most bytes of encoded index batches kept, the oldest being dropped
+// first.
+const maxIndexCacheBytes = 32 << 20
+
+// indexCache keeps the index batches recently encoded, so that a folder
+// shared with many devices encodes each batch once rather than once per
+// device. Devices which are up to date ask for the same sequences at about
+// the same time.
+type indexCache struct {
+	mut     sync.Mutex
+	batches map[indexCacheKey]*protocol.EncodedIndex
+	order   []indexCacheKey // oldest first
+	size    int
+}
+
+// An indexCacheKey identifies a batch by the range of sequences it covers.
+// A sequence number is never reused for another file or version, and later
+// changes only remove sequences from a range, so the first and last
+// sequence and the number of files in between determine the batch.
+type indexCacheKey struct {
+	folder       string
+	first, last  int64
+	files        int
+	update       bool
+	dropSymlinks bool
+}
+
+func newIndexCache() *indexCache {
+	return &indexCache{
+		mut:     sync.NewMutex(),
+		batches: make(map[indexCacheKey]*protocol.EncodedIndex),
+	}
+}
+
+// encoded returns the encoded batch of files, encoding it unless it was
+// before. A nil indexCache encodes every time.
+func (c *indexCache) encoded(folder string, files []protocol.FileInfo, update, dropSymlinks bool) (*protocol.EncodedIndex, error) {
+	if c == nil || len(files) == 0 {
+		return protocol.EncodeIndex(folder, files, update)
+	}
+
+	key := indexCacheKey{
+		folder:       folder,
+		first:        files[0].Sequence,
+		last:         files[len(files)-1].Sequence,
+		files:        len(files),
+		update:       update,
+		dropSymlinks: dropSymlinks,
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if enc, ok := c.batches[key]; ok {
+		l.Debugf("Reusing encoded index batch for %s, sequences %d to %d", folder, key.first, key.last)
+		return enc, nil
+	}
+
+	enc, err := protocol.EncodeIndex(folder, files, update)
+	if err != nil {
+		return nil, err
+	}
+	c.batches[key] = enc
+	c.order = append(c.order, key)
+	c.size += enc.ProtoSize()
+	for c.size > maxIndexCacheBytes && len(c.order) > 1 {
+		c.size -= c.batches[c.order[0]].ProtoSize()
+		delete(c.batches, c.order[0])
+		c.order = c.order[1:]
+	}
+	return enc, nil
+}
+
+// forget drops the batches of the folder, e.g. when its index is reset.
+func (c *indexCache) forget(folder string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	order := c.order[:0]
+	for _, key := range c.order {
+		if key.folder == folder {
+			c.size -= c.batches[key].ProtoSize()
+			delete(c.batches, key)
+			continue
+		}
+		order = append(order, key)
+	}
+	c.order = order
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func indexCacheFiles(first, n int) []protocol.FileInfo {
+	files := make([]protocol.FileInfo, n)
+	for i := range files {
+		files[i] = protocol.FileInfo{
+			Name:     fmt.Sprintf("f%d", first+i),
+			Sequence: int64(first + i),
+		}
+	}
+	return files
+}
+
+func TestIndexCache(t *testing.T) {
+	c := newIndexCache()
+
+	files := indexCacheFiles(1, 10)
+	enc, err := c.encoded("default", files, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.encoded("default", files, true, false); again != enc {
+		t.Error("same batch encoded twice")
+	}
+
+	// Any difference in the key is another batch.
+	if other, _ := c.encoded("default", files, false, false); other == enc {
+		t.Error("index reused as index update")
+	}
+	if other, _ := c.encoded("default", files, true, true); other == enc {
+		t.Error("batch reused regardless of dropped symlinks")
+	}
+	if other, _ := c.encoded("other", files, true, false); other == enc {
+		t.Error("batch reused for another folder")
+	}
+	if other, _ := c.encoded("default", append(files[:1:1], files[2:]...), true, false); other == enc {
+		t.Error("batch reused with a file less")
+	}
+
+	c.forget("default")
+	if again, _ := c.encoded("default", files, true, false); again == enc {
+		t.Error("batch reused after forgetting the folder")
+	}
+	if len(c.order) != len(c.batches) {
+		t.Errorf("%d keys in order, %d batches", len(c.order), len(c.batches))
+	}
+
+	// A nil cache encodes every time.
+	var nilCache *indexCache
+	first, _ := nilCache.encoded("default", files, true, false)
+	if second, _ := nilCache.encoded("default", files, true, false); first == second {
+		t.Error("nil cache reused batch")
+	}
+}
+
+func TestIndexCacheEviction(t *testing.T) {
+	c := newIndexCache()
+
+	first, err := c.encoded("default", indexCacheFiles(1, 10), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add batches until the first is evicted.
+	for seq := 11; c.batches[c.order[0]] == first; seq += 1000 {
+		if seq > maxIndexCacheBytes {
+			t.Fatal("first batch never evicted")
+		}
+		if _, err := c.encoded("default", indexCacheFiles(seq, 1000), true, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c.size > maxIndexCacheBytes {
+		t.Errorf("cache holds %d bytes, more than %d", c.size, maxIndexCacheBytes)
+	}
+	if again, _ := c.encoded("default", indexCacheFiles(1, 10), true, false); again == first {
+		t.Error("oldest batch not evicted")
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// An index export starts with the magic number, followed by any number of
+// FileInfos in wire format, each prefixed by its length as a 32 bit big
+// endian integer.
+const indexExportMagic uint32 = 0x1D3E9A52
+
+var (
+	errNotIndexExport  = errors.New("not an index export")
+	errFolderNotPaused = errors.New("folder must be paused")
+)
+
+// ExportIndex writes the local index of the given file set, including
+// block hashes, to w. Deleted and invalid files are left out. The number
+// of files written is returned.
+func ExportIndex(fset *db.FileSet, w io.Writer) (int, error) {
+	iw := newIndexWriter(w)
+	if err := iw.writeMagic(); err != nil {
+		return 0, err
+	}
+
+	var err error
+	n := 0
+	fset.WithHave(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(protocol.FileInfo)
+		if f.IsDeleted() || f.IsInvalid() {
+			return true
+		}
+		if err = iw.write(f); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, iw.Flush()
+}
+
+// GenerateIndex scans the folder on disk, without a database, and writes
+// its index in the format of ExportIndex to w. The files carry no version,
+// so that an importing device takes them as its own. The number of files
+// written is returned.
+func GenerateIndex(ctx context.Context, cfg config.FolderConfiguration, w io.Writer) (int, error) {
+	ffs := cfg.Filesystem()
+	ignores := ignore.New(ffs)
+	if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
+		return 0, err
+	}
+
+	hashers := cfg.Hashers
+	if hashers < 1 {
+		// Nothing else is running, so use all cores.
+		hashers = runtime.GOMAXPROCS(-1)
+	}
+
+	iw := newIndexWriter(w)
+	if err := iw.writeMagic(); err != nil {
+		return 0, err
+	}
+
+	fchan := scanner.Walk(ctx, scanner.Config{
+		Folder:                cfg.ID,
+		Matcher:               ignores,
+		Filesystem:            ffs,
+		IgnorePerms:           cfg.IgnoredLocalPermBits() == 0777,
+		IgnorePermBits:        cfg.IgnoredLocalPermBits(),
+		AutoNormalize:         cfg.AutoNormalize,
+		Hashers:               hashers,
+		ProgressTickIntervalS: -1,
+		UseLargeBlocks:        cfg.UseLargeBlocks,
+	})
+
+	n := 0
+	for res := range fchan {
+		if res.Err != nil {
+			l.Infof("Generating index: %s: %v", res.Path, res.Err)
+			continue
+		}
+		f := res.File
+		f.Version = protocol.Vector{}
+		f.ModifiedBy = 0
+		if err := iw.write(f); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := ctx.Err(); err != nil {
+		return n, err
+	}
+	return n, iw.Flush()
+}
+
+type indexWriter struct {
+	*bufio.Writer
+	buf [4]byte
+}
+
+func newIndexWriter(w io.Writer) *indexWriter {
+	return &indexWriter{Writer: bufio.NewWriter(w)}
+}
+
+func (w *indexWriter) writeMagic() error {
+	binary.BigEndian.PutUint32(w.buf[:], indexExportMagic)
+	_, err := w.Write(w.buf[:])
+	return err
+}
+
+func (w *indexWriter) write(f protocol.FileInfo) error {
+	f.Name = osutil.NormalizedFilename(f.Name)
+	bs, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(w.buf[:], uint32(len(bs)))
+	if _, err := w.Write(w.buf[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(bs)
+	return err
+}
+
+// ImportIndex reads an index written by ExportIndex and adds the files to
+// the local index of the given file set, as long as they are not already
+// known locally and are present unchanged on disk. Their hashes and
+// versions are taken as is, so that they need neither be hashed nor pulled
+// again. Files without a version, as written by GenerateIndex, are given
+// one as if they had been scanned by the device with the given short ID.
+// The number of imported files is returned.
+func ImportIndex(fset *db.FileSet, cfg config.FolderConfiguration, short protocol.ShortID, r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	var buf [4]byte
+	if _, err := io.ReadFull(br, buf[:]); err != nil || binary.BigEndian.Uint32(buf[:]) != indexExportMagic {
+		return 0, errNotIndexExport
+	}
+
+	ffs := cfg.Filesystem()
+	imported := 0
+	batch := newFileInfoBatch(func(fs []protocol.FileInfo) error {
+		fset.Update(protocol.LocalDeviceID, fs)
+		imported += len(fs)
+		return nil
+	})
+
+	var bs []byte
+	for {
+		if _, err := io.ReadFull(br, buf[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return imported, err
+		}
+		size := binary.BigEndian.Uint32(buf[:])
+		if size > protocol.MaxMessageLen {
+			return imported, fmt.Errorf("%v: file info of %d bytes exceeds maximum", errNotIndexExport, size)
+		}
+		if cap(bs) < int(size) {
+			bs = make([]byte, size)
+		}
+		bs = bs[:size]
+		if _, err := io.ReadFull(br, bs); err != nil {
+			return imported, err
+		}
+
+		var f protocol.FileInfo
+		if err := f.Unmarshal(bs); err != nil {
+			return imported, err
+		}
+		if f.IsDeleted() || f.IsInvalid() {
+			continue
+		}
+		f.Name = osutil.NativeFilename(f.Name)
+
+		if _, ok := fset.Get(protocol.LocalDeviceID, f.Name); ok {
+			l.Debugln("index import: already have", f.Name)
+			continue
+		}
+		stat, err := ffs.Lstat(f.Name)
+		if err != nil {
+			l.Debugln("index import:", err)
+			continue
+		}
+		cur, err := scanner.CreateFileInfo(stat, f.Name, ffs)
+		if err != nil || !cur.IsEquivalentPermBits(f, cfg.IgnoredLocalPermBits(), true, protocol.LocalAllFlags) {
+			l.Debugln("index import: changed on disk", f.Name)
+			continue
+		}
+
+		if len(f.Version.Counters) == 0 {
+			f.Version = f.Version.Update(short)
+			f.ModifiedBy = short
+		}
+		f.Sequence = 0
+		f.LocalFlags = 0
+		batch.append(f)
+		if err := batch.flushIfFull(); err != nil {
+			return imported, err
+		}
+	}
+
+	err := batch.flush()
+	return imported, err
+}
+
+// ExportIndex writes the local index of the folder to w, see ExportIndex.
+func (m *model) ExportIndex(folder string, w io.Writer) error {
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if ok {
+		_, err := ExportIndex(fset, w)
+		return err
+	}
+
+	// The folder is paused, so make sure it stays that way while we read
+	// from the database.
+	restartMut := m.folderRestartMuts.Get(folder)
+	restartMut.Lock()
+	defer restartMut.Unlock()
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return errFolderMissing
+	}
+	_, err := ExportIndex(db.NewFileSet(folder, cfg.Filesystem(), m.db), w)
+	return err
+}
+
+// ImportIndex adds files from an index export to the local index of the
+// folder, see ImportIndex. The folder must be paused.
+func (m *model) ImportIndex(folder string, r io.Reader) (int, error) {
+	restartMut := m.folderRestartMuts.Get(folder)
+	restartMut.Lock()
+	defer restartMut.Unlock()
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return 0, errFolderMissing
+	}
+	if !cfg.Paused {
+		return 0, errFolderNotPaused
+	}
+
+	n, err := ImportIndex(db.NewFileSet(folder, cfg.Filesystem(), m.db), cfg, m.shortID, r)
+	if n > 0 {
+		l.Infof("Imported %d files into the index of folder %v", n, cfg.Description())
+	}
+	return n, err
+}
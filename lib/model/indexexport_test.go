@@ -0,0 +1,136 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+func TestIndexExportImport(t *testing.T) {
+	fcfg := testFolderConfigTmp()
+	defer os.RemoveAll(fcfg.Path)
+	ffs := fcfg.Filesystem()
+
+	version := protocol.Vector{}.Update(device1.Short())
+	var files []protocol.FileInfo
+	for _, name := range []string{"unchanged", "changed", "known"} {
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte(name))
+		must(t, err)
+		must(t, fd.Close())
+		stat, err := ffs.Lstat(name)
+		must(t, err)
+		f, err := scanner.CreateFileInfo(stat, name, ffs)
+		must(t, err)
+		f.Version = version
+		f.ModifiedBy = device1.Short()
+		f.Blocks = []protocol.BlockInfo{{Size: int32(len(name)), Hash: make([]byte, 32)}}
+		files = append(files, f)
+	}
+	files = append(files, protocol.FileInfo{Name: "deleted", Deleted: true, Version: version})
+
+	src := db.NewFileSet(fcfg.ID, ffs, db.OpenMemory())
+	src.Update(protocol.LocalDeviceID, files)
+
+	buf := new(bytes.Buffer)
+	n, err := ExportIndex(src, buf)
+	must(t, err)
+	if n != 3 {
+		t.Fatalf("Exported %d files, expected 3", n)
+	}
+
+	// The receiving side has modified one file and already knows about
+	// another one.
+	fd, err := ffs.OpenFile("changed", fs.OptReadWrite|fs.OptAppend, 0644)
+	must(t, err)
+	_, err = fd.Write([]byte("more data"))
+	must(t, err)
+	must(t, fd.Close())
+
+	dst := db.NewFileSet(fcfg.ID, ffs, db.OpenMemory())
+	dst.Update(protocol.LocalDeviceID, []protocol.FileInfo{{Name: "known", Version: protocol.Vector{}.Update(myID.Short())}})
+
+	n, err = ImportIndex(dst, fcfg, myID.Short(), buf)
+	must(t, err)
+	if n != 1 {
+		t.Fatalf("Imported %d files, expected 1", n)
+	}
+
+	f, ok := dst.Get(protocol.LocalDeviceID, "unchanged")
+	if !ok {
+		t.Fatal("Unchanged file was not imported")
+	}
+	if !f.Version.Equal(version) || len(f.Blocks) != 1 || f.Sequence == 0 {
+		t.Error("Unexpected imported file", f)
+	}
+	if _, ok := dst.Get(protocol.LocalDeviceID, "changed"); ok {
+		t.Error("Changed file should not have been imported")
+	}
+	if f, _ := dst.Get(protocol.LocalDeviceID, "known"); f.Version.Equal(version) {
+		t.Error("Known file should not have been overwritten")
+	}
+
+	if _, err := ImportIndex(dst, fcfg, myID.Short(), bytes.NewReader([]byte("garbage"))); err != errNotIndexExport {
+		t.Error("Expected errNotIndexExport, got", err)
+	}
+}
+
+func TestGenerateIndex(t *testing.T) {
+	fcfg := testFolderConfigTmp()
+	defer os.RemoveAll(fcfg.Path)
+	ffs := fcfg.Filesystem()
+
+	for _, name := range []string{"file", "ignored", filepath.Join("dir", "nested")} {
+		must(t, ffs.MkdirAll(filepath.Dir(name), 0755))
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte(name))
+		must(t, err)
+		must(t, fd.Close())
+	}
+	fd, err := ffs.Create(".stignore")
+	must(t, err)
+	_, err = fd.Write([]byte("ignored\n"))
+	must(t, err)
+	must(t, fd.Close())
+
+	buf := new(bytes.Buffer)
+	n, err := GenerateIndex(context.Background(), fcfg, buf)
+	must(t, err)
+	// file, dir and dir/nested
+	if n != 3 {
+		t.Fatalf("Generated %d files, expected 3", n)
+	}
+
+	dst := db.NewFileSet(fcfg.ID, ffs, db.OpenMemory())
+	n, err = ImportIndex(dst, fcfg, myID.Short(), buf)
+	must(t, err)
+	if n != 3 {
+		t.Fatalf("Imported %d files, expected 3", n)
+	}
+
+	f, ok := dst.Get(protocol.LocalDeviceID, filepath.Join("dir", "nested"))
+	if !ok {
+		t.Fatal("Nested file was not imported")
+	}
+	if !f.Version.Equal(protocol.Vector{}.Update(myID.Short())) || f.ModifiedBy != myID.Short() || len(f.Blocks) != 1 {
+		t.Error("Unexpected imported file", f)
+	}
+	if _, ok := dst.Get(protocol.LocalDeviceID, "ignored"); ok {
+		t.Error("Ignored file should not have been generated")
+	}
+}
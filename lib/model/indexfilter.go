@@ -0,0 +1,50 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// An indexFilter tells which files of a folder are never announced to a
+// device, nor served to it. Files already announced before the filter was
+// set remain known to the device until it resets its index. The nil
+// indexFilter filters nothing.
+type indexFilter struct {
+	matcher *ignore.Matcher
+	all     bool // the patterns are invalid, so to be safe nothing is announced
+}
+
+// indexFilters returns the index filters of the devices of the folder that
+// have one.
+func indexFilters(cfg config.FolderConfiguration) map[protocol.DeviceID]*indexFilter {
+	filters := make(map[protocol.DeviceID]*indexFilter)
+	for _, dev := range cfg.Devices {
+		if len(dev.IndexFilter) == 0 {
+			continue
+		}
+		matcher := ignore.New(cfg.Filesystem())
+		if err := matcher.Parse(strings.NewReader(strings.Join(dev.IndexFilter, "\n")), ""); err != nil {
+			l.Warnf("Folder %v: Not announcing any files to device %v, as its index filter is invalid: %v", cfg.Description(), dev.DeviceID, err)
+			filters[dev.DeviceID] = &indexFilter{all: true}
+			continue
+		}
+		filters[dev.DeviceID] = &indexFilter{matcher: matcher}
+	}
+	return filters
+}
+
+func (f *indexFilter) filtered(name string) bool {
+	if f == nil {
+		return false
+	}
+	return f.all || f.matcher.Match(name).IsIgnored()
+}
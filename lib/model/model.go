@@ -8,10 +8,13 @@ package model
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -31,6 +34,7 @@ import (
 	"github.com/syncthing/syncthing/lib/scanner"
 	"github.com/syncthing/syncthing/lib/stats"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/tracing"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/versioner"
 	"github.com/thejerf/suture"
@@ -60,6 +64,8 @@ type service interface {
 	SchedulePull()              // something relevant changed, we should try a pull
 	Jobs() ([]string, []string) // In progress, Queued
 	Scan(subs []string) error
+	Rehash(subs []string) (RehashReport, error)
+	ScanProgress() (scanner.Progress, bool)
 	Serve()
 	Stop()
 	CheckHealth() error
@@ -91,7 +97,9 @@ type Model interface {
 	ScanFolder(folder string) error
 	ScanFolders() map[string]error
 	ScanFolderSubdirs(folder string, subs []string) error
+	RehashFolderSubdirs(folder string, subs []string) (RehashReport, error)
 	State(folder string) (string, time.Time, error)
+	DatabaseError() error
 	FolderErrors(folder string) ([]FileError, error)
 	WatchError(folder string) error
 	Override(folder string)
@@ -99,16 +107,40 @@ type Model interface {
 	BringToFront(folder, file string)
 	GetIgnores(folder string) ([]string, []string, error)
 	SetIgnores(folder string, content []string) error
+	TestIgnores(folder string, files []string) ([]IgnoreTestResult, error)
 
 	GetFolderVersions(folder string) (map[string][]versioner.FileVersion, error)
 	RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]string, error)
+	RestoreFolderVersion(folder, file string, versionTime time.Time) (versioner.FileVersion, error)
+	GetFolderVersionAt(folder, file string, when time.Time) (versioner.FileVersion, fs.File, error)
+	DeletedFiles(folder string, since time.Time, search string) ([]DeletedFile, error)
+	AttentionFiles(folder string) ([]AttentionFile, error)
+
+	ChangeHeatmap(folder string, levels, limit int) ([]DirChanges, error)
+	IndexSnapshots(folder string) ([]time.Time, error)
+	DiffIndexSnapshots(folder string, from, to time.Time) ([]db.SnapshotChange, error)
+	ExportIndex(folder string, w io.Writer) error
+	ImportIndex(folder string, r io.Reader) (int, error)
+	SeedFolder(folder, source string) (SeedResult, error)
+	AuditRemote(device protocol.DeviceID, folder string, samples int) (AuditReport, error)
+	FolderJobs(folder string) []FolderJob
+	ScanProgress() map[string]scanner.Progress
+	CancelFolderJob(id int) error
+	DecommissionFolder(folder string, opts DecommissionOptions) (DecommissionReport, error)
+	MoveFolder(folder, path string) (MoveReport, error)
+	KeptPartials(folder string) ([]KeptPartial, error)
+	ResumePartial(folder, file string) error
+	DiscardPartial(folder, file string) error
 
 	LocalChangedFiles(folder string, page, perpage int) []db.FileInfoTruncated
 	NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated)
 	RemoteNeedFolderFiles(device protocol.DeviceID, folder string, page, perpage int) ([]db.FileInfoTruncated, error)
 	CurrentFolderFile(folder string, file string) (protocol.FileInfo, bool)
 	CurrentGlobalFile(folder string, file string) (protocol.FileInfo, bool)
+	FileVersions(folder, file string) ([]FileVersion, error)
+	FileStatus(folder, file string) (FileStatus, error)
 	Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []Availability
+	RankSources(availability []Availability) []RankedAvailability
 
 	GlobalSize(folder string) db.Counts
 	LocalSize(folder string) db.Counts
@@ -117,15 +149,23 @@ type Model interface {
 
 	CurrentSequence(folder string) (int64, bool)
 	RemoteSequence(folder string) (int64, bool)
+	RemoteFolderStatus(device protocol.DeviceID) map[string]RemoteFolderStatus
 
 	Completion(device protocol.DeviceID, folder string) FolderCompletion
+	ReadView(folders ...string) *ReadView
 	ConnectionStats() map[string]interface{}
+	ConnectionHistory(device protocol.DeviceID) []ConnectionRecord
+	StartProtocolTrace(device protocol.DeviceID) error
+	StopProtocolTrace(device protocol.DeviceID) error
+	ProtocolTrace(device protocol.DeviceID) ([]protocol.TraceEntry, error)
+	PushConfig(device protocol.DeviceID, cfg config.PushedConfig) error
 	DeviceStatistics() map[string]stats.DeviceStatistics
 	FolderStatistics() map[string]stats.FolderStatistics
 	UsageReportingStats(version int, preview bool) map[string]interface{}
 
 	StartDeadlockDetector(timeout time.Duration)
 	GlobalDirectoryTree(folder, prefix string, levels int, dirsonly bool) map[string]interface{}
+	GlobalDirectoryList(folder, prefix string, levels int, dirsonly bool, sortBy string, desc bool, page, perpage int) ([]db.FileInfoTruncated, error)
 }
 
 type model struct {
@@ -135,6 +175,7 @@ type model struct {
 	db                *db.Lowlevel
 	finder            *db.BlockFinder
 	progressEmitter   *ProgressEmitter
+	requestScheduler  *requestScheduler
 	id                protocol.DeviceID
 	shortID           protocol.ShortID
 	cacheIgnoredFiles bool
@@ -148,6 +189,7 @@ type model struct {
 	folderFiles        map[string]*db.FileSet                                 // folder -> files
 	deviceStatRefs     map[protocol.DeviceID]*stats.DeviceStatisticsReference // deviceID -> statsRef
 	folderIgnores      map[string]*ignore.Matcher                             // folder -> matcher object
+	folderIndexFilters map[string]map[protocol.DeviceID]*indexFilter          // folder -> device -> files not announced to it
 	folderRunners      map[string]service                                     // folder -> puller or scanner
 	folderRunnerTokens map[string][]suture.ServiceToken                       // folder -> tokens for puller or scanner
 	folderRestartMuts  syncMutexMap                                           // folder -> restart mutex
@@ -159,6 +201,20 @@ type model struct {
 	helloMessages       map[protocol.DeviceID]protocol.HelloResult
 	deviceDownloads     map[protocol.DeviceID]*deviceDownloadState
 	remotePausedFolders map[protocol.DeviceID][]string // deviceID -> folders
+	folderActivityPeers map[protocol.DeviceID]struct{} // devices that want FolderActivity messages
+	remoteFolderStatus  map[protocol.DeviceID]map[string]RemoteFolderStatus
+	connHistory         map[protocol.DeviceID][]ConnectionRecord
+	rescanHintPeers     map[protocol.DeviceID]struct{} // devices that accept RescanHint messages
+	blockMismatchPeers  map[protocol.DeviceID]struct{} // devices that accept BlockMismatch messages
+	configPushPeers     map[protocol.DeviceID]struct{} // devices that accept ConfigPush messages from us
+	protocolTracers     map[protocol.DeviceID]*protocol.Tracer
+
+	rescanHints  *rescanHintTracker
+	folderJobs   *folderJobTracker
+	indexCache   *indexCache
+	changeHeat   *changeHeatmap
+	availability *availabilityCache
+	configPushes *configPushTracker
 
 	foldersRunning int32 // for testing only
 }
@@ -176,7 +232,10 @@ var (
 	ErrFolderPaused      = errors.New("folder is paused")
 	errFolderNotRunning  = errors.New("folder is not running")
 	errFolderMissing     = errors.New("no such folder")
+	errNoVersionAt       = errors.New("no version of the file at the given time")
+	errNoSuchVersion     = errors.New("no such version of the file")
 	errNetworkNotAllowed = errors.New("network not allowed")
+	errNoProtocolTrace   = errors.New("no protocol trace for the device")
 	// errors about why a connection is closed
 	errIgnoredFolderRemoved = errors.New("folder no longer ignored")
 	errReplacingConnection  = errors.New("replacing connection")
@@ -197,6 +256,7 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, clientName, clientVersio
 		db:                  ldb,
 		finder:              db.NewBlockFinder(ldb),
 		progressEmitter:     NewProgressEmitter(cfg),
+		requestScheduler:    newRequestScheduler(incomingRequestCapacity(cfg.Options())),
 		id:                  id,
 		shortID:             id.Short(),
 		cacheIgnoredFiles:   cfg.Options().CacheIgnoredFiles,
@@ -207,6 +267,7 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, clientName, clientVersio
 		folderFiles:         make(map[string]*db.FileSet),
 		deviceStatRefs:      make(map[protocol.DeviceID]*stats.DeviceStatisticsReference),
 		folderIgnores:       make(map[string]*ignore.Matcher),
+		folderIndexFilters:  make(map[string]map[protocol.DeviceID]*indexFilter),
 		folderRunners:       make(map[string]service),
 		folderRunnerTokens:  make(map[string][]suture.ServiceToken),
 		conn:                make(map[protocol.DeviceID]connections.Connection),
@@ -215,11 +276,28 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, clientName, clientVersio
 		helloMessages:       make(map[protocol.DeviceID]protocol.HelloResult),
 		deviceDownloads:     make(map[protocol.DeviceID]*deviceDownloadState),
 		remotePausedFolders: make(map[protocol.DeviceID][]string),
+		folderActivityPeers: make(map[protocol.DeviceID]struct{}),
+		remoteFolderStatus:  make(map[protocol.DeviceID]map[string]RemoteFolderStatus),
+		connHistory:         make(map[protocol.DeviceID][]ConnectionRecord),
+		rescanHintPeers:     make(map[protocol.DeviceID]struct{}),
+		blockMismatchPeers:  make(map[protocol.DeviceID]struct{}),
+		configPushPeers:     make(map[protocol.DeviceID]struct{}),
+		protocolTracers:     make(map[protocol.DeviceID]*protocol.Tracer),
+		rescanHints:         newRescanHintTracker(),
+		folderJobs:          newFolderJobTracker(),
+		indexCache:          newIndexCache(),
+		changeHeat:          newChangeHeatmap(),
+		availability:        newAvailabilityCache(),
+		configPushes:        newConfigPushTracker(),
 		fmut:                sync.NewRWMutex(),
 		pmut:                sync.NewRWMutex(),
 	}
 	m.Add(m.progressEmitter)
+	m.Add(newFolderActivitySender(m))
+	m.Add(newShareExpirer(m))
+	m.Add(newScrubber(m))
 	scanLimiter.setCapacity(cfg.Options().MaxConcurrentScans)
+	m.requestScheduler.setWeights(cfg.RawCopy().Devices)
 	cfg.Subscribe(m)
 
 	return m
@@ -242,7 +320,7 @@ func (m *model) StartFolder(folder string) {
 	folderCfg := m.folderCfgs[folder]
 	m.startFolderLocked(folderCfg)
 
-	l.Infof("Ready to synchronize %s (%s)", folderCfg.Description(), folderCfg.Type)
+	l.With(folderCfg.ID, "").Infof("Ready to synchronize %s (%s)", folderCfg.Description(), folderCfg.Type)
 }
 
 // Need to hold lock on m.fmut when calling this.
@@ -269,6 +347,7 @@ func (m *model) startFolderLocked(cfg config.FolderConfiguration) {
 		if _, ok := expected[available]; !ok {
 			l.Debugln("dropping", folder, "state for", available)
 			fset.Drop(available)
+			m.availability.forget(folder)
 		}
 	}
 
@@ -372,11 +451,12 @@ func (m *model) addFolderLocked(cfg config.FolderConfiguration) {
 	folderFs := cfg.Filesystem()
 	m.folderFiles[cfg.ID] = db.NewFileSet(cfg.ID, folderFs, m.db)
 
-	ignores := ignore.New(folderFs, ignore.WithCache(m.cacheIgnoredFiles))
+	ignores := ignore.New(folderFs, ignore.WithCache(m.cacheIgnoredFiles), ignore.WithPatternSets(m.cfg.IgnorePatternSet))
 	if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
 		l.Warnln("Loading ignores:", err)
 	}
 	m.folderIgnores[cfg.ID] = ignores
+	m.folderIndexFilters[cfg.ID] = indexFilters(cfg)
 }
 
 func (m *model) RemoveFolder(cfg config.FolderConfiguration) {
@@ -414,6 +494,10 @@ func (m *model) tearDownFolderLocked(cfg config.FolderConfiguration, err error)
 	delete(m.folderCfgs, cfg.ID)
 	delete(m.folderFiles, cfg.ID)
 	delete(m.folderIgnores, cfg.ID)
+	delete(m.folderIndexFilters, cfg.ID)
+	m.indexCache.forget(cfg.ID)
+	m.changeHeat.forget(cfg.ID)
+	m.availability.forget(cfg.ID)
 	delete(m.folderRunners, cfg.ID)
 	delete(m.folderRunnerTokens, cfg.ID)
 }
@@ -458,7 +542,7 @@ func (m *model) RestartFolder(from, to config.FolderConfiguration) {
 		m.addFolderLocked(to)
 		m.startFolderLocked(to)
 	}
-	l.Infof("%v folder %v (%v)", infoMsg, to.Description(), to.Type)
+	l.With(to.ID, "").Infof("%v folder %v (%v)", infoMsg, to.Description(), to.Type)
 }
 
 func (m *model) UsageReportingStats(version int, preview bool) map[string]interface{} {
@@ -671,69 +755,9 @@ func (comp FolderCompletion) Map() map[string]interface{} {
 // Completion returns the completion status, in percent, for the given device
 // and folder.
 func (m *model) Completion(device protocol.DeviceID, folder string) FolderCompletion {
-	m.fmut.RLock()
-	rf, ok := m.folderFiles[folder]
-	m.fmut.RUnlock()
-	if !ok {
-		return FolderCompletion{} // Folder doesn't exist, so we hardly have any of it
-	}
-
-	tot := rf.GlobalSize().Bytes
-	if tot == 0 {
-		// Folder is empty, so we have all of it
-		return FolderCompletion{
-			CompletionPct: 100,
-		}
-	}
-
-	m.pmut.RLock()
-	counts := m.deviceDownloads[device].GetBlockCounts(folder)
-	m.pmut.RUnlock()
-
-	var need, items, fileNeed, downloaded, deletes int64
-	rf.WithNeedTruncated(device, func(f db.FileIntf) bool {
-		ft := f.(db.FileInfoTruncated)
-
-		// If the file is deleted, we account it only in the deleted column.
-		if ft.Deleted {
-			deletes++
-			return true
-		}
-
-		// This might might be more than it really is, because some blocks can be of a smaller size.
-		downloaded = int64(counts[ft.Name] * int(ft.BlockSize()))
-
-		fileNeed = ft.FileSize() - downloaded
-		if fileNeed < 0 {
-			fileNeed = 0
-		}
-
-		need += fileNeed
-		items++
-
-		return true
-	})
-
-	needRatio := float64(need) / float64(tot)
-	completionPct := 100 * (1 - needRatio)
-
-	// If the completion is 100% but there are deletes we need to handle,
-	// drop it down a notch. Hack for consumers that look only at the
-	// percentage (our own GUI does the same calculation as here on its own
-	// and needs the same fixup).
-	if need == 0 && deletes > 0 {
-		completionPct = 95 // chosen by fair dice roll
-	}
-
-	l.Debugf("%v Completion(%s, %q): %f (%d / %d = %f)", m, device, folder, completionPct, need, tot, needRatio)
-
-	return FolderCompletion{
-		CompletionPct: completionPct,
-		NeedBytes:     need,
-		NeedItems:     items,
-		GlobalBytes:   tot,
-		NeedDeletes:   deletes,
-	}
+	v := m.ReadView(folder)
+	defer v.Release()
+	return v.Completion(device, folder)
 }
 
 func addSizeOfFile(s *db.Counts, f db.FileIntf) {
@@ -786,91 +810,18 @@ func (m *model) ReceiveOnlyChangedSize(folder string) db.Counts {
 
 // NeedSize returns the number and total size of currently needed files.
 func (m *model) NeedSize(folder string) db.Counts {
-	m.fmut.RLock()
-	defer m.fmut.RUnlock()
-
-	var result db.Counts
-	if rf, ok := m.folderFiles[folder]; ok {
-		cfg := m.folderCfgs[folder]
-		rf.WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
-			if cfg.IgnoreDelete && f.IsDeleted() {
-				return true
-			}
-
-			addSizeOfFile(&result, f)
-			return true
-		})
-	}
-	result.Bytes -= m.progressEmitter.BytesCompleted(folder)
-	l.Debugf("%v NeedSize(%q): %v", m, folder, result)
-	return result
+	v := m.ReadView(folder)
+	defer v.Release()
+	return v.NeedSize(folder)
 }
 
 // NeedFolderFiles returns paginated list of currently needed files in
 // progress, queued, and to be queued on next puller iteration, as well as the
 // total number of files currently needed.
 func (m *model) NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated) {
-	m.fmut.RLock()
-	defer m.fmut.RUnlock()
-
-	rf, ok := m.folderFiles[folder]
-	if !ok {
-		return nil, nil, nil
-	}
-
-	var progress, queued, rest []db.FileInfoTruncated
-	var seen map[string]struct{}
-
-	skip := (page - 1) * perpage
-	get := perpage
-
-	runner, ok := m.folderRunners[folder]
-	if ok {
-		allProgressNames, allQueuedNames := runner.Jobs()
-
-		var progressNames, queuedNames []string
-		progressNames, skip, get = getChunk(allProgressNames, skip, get)
-		queuedNames, skip, get = getChunk(allQueuedNames, skip, get)
-
-		progress = make([]db.FileInfoTruncated, len(progressNames))
-		queued = make([]db.FileInfoTruncated, len(queuedNames))
-		seen = make(map[string]struct{}, len(progressNames)+len(queuedNames))
-
-		for i, name := range progressNames {
-			if f, ok := rf.GetGlobalTruncated(name); ok {
-				progress[i] = f
-				seen[name] = struct{}{}
-			}
-		}
-
-		for i, name := range queuedNames {
-			if f, ok := rf.GetGlobalTruncated(name); ok {
-				queued[i] = f
-				seen[name] = struct{}{}
-			}
-		}
-	}
-
-	rest = make([]db.FileInfoTruncated, 0, perpage)
-	cfg := m.folderCfgs[folder]
-	rf.WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
-		if cfg.IgnoreDelete && f.IsDeleted() {
-			return true
-		}
-
-		if skip > 0 {
-			skip--
-			return true
-		}
-		ft := f.(db.FileInfoTruncated)
-		if _, ok := seen[ft.Name]; !ok {
-			rest = append(rest, ft)
-			get--
-		}
-		return get > 0
-	})
-
-	return progress, queued, rest
+	v := m.ReadView(folder)
+	defer v.Release()
+	return v.NeedFolderFiles(folder, page, perpage)
 }
 
 // LocalChangedFiles returns a paginated list of currently needed files in
@@ -885,7 +836,7 @@ func (m *model) LocalChangedFiles(folder string, page, perpage int) []db.FileInf
 		return nil
 	}
 	fcfg := m.folderCfgs[folder]
-	if fcfg.Type != config.FolderTypeReceiveOnly {
+	if fcfg.Type != config.FolderTypeReceiveOnly && fcfg.Type != config.FolderTypeReceiveEncrypted {
 		return nil
 	}
 	if rf.ReceiveOnlyChangedSize().TotalItems() == 0 {
@@ -965,7 +916,7 @@ func (m *model) handleIndex(deviceID protocol.DeviceID, folder string, fs []prot
 	l.Debugf("%v (in): %s / %q: %d files", op, deviceID, folder, len(fs))
 
 	if cfg, ok := m.cfg.Folder(folder); !ok || !cfg.SharedWith(deviceID) {
-		l.Infof("%v for unexpected folder ID %q sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", op, folder, deviceID)
+		l.With(folder, deviceID.String()).Infof("%v for unexpected folder ID %q sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", op, folder, deviceID)
 		return
 	} else if cfg.Paused {
 		l.Debugf("%v for paused folder (ID %q) sent from device %q.", op, folder, deviceID)
@@ -995,6 +946,7 @@ func (m *model) handleIndex(deviceID protocol.DeviceID, folder string, fs []prot
 
 	if !update {
 		files.Drop(deviceID)
+		m.availability.forget(folder)
 	}
 	for i := range fs {
 		// The local flags should never be transmitted over the wire. Make
@@ -1002,6 +954,7 @@ func (m *model) handleIndex(deviceID protocol.DeviceID, folder string, fs []prot
 		fs[i].LocalFlags = 0
 	}
 	files.Update(deviceID, fs)
+	m.availability.changed(folder, fs)
 
 	events.Default.Log(events.RemoteIndexUpdated, map[string]interface{}{
 		"device":  deviceID.String(),
@@ -1039,19 +992,21 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 	}
 
 	// Needs to happen outside of the fmut, as can cause CommitConfiguration
-	if deviceCfg.AutoAcceptFolders {
+	if policy, ok := m.autoAcceptPolicy(deviceCfg); ok {
 		for _, folder := range cm.Folders {
-			changed = m.handleAutoAccepts(deviceCfg, folder) || changed
+			changed = m.handleAutoAccepts(deviceCfg, policy, folder) || changed
 		}
 	}
 
 	m.fmut.Lock()
 	var paused []string
+	sharedIgnores := make(map[string][]string)
+	var sharedMetadata []protocol.Folder
 	for _, folder := range cm.Folders {
 		cfg, ok := m.cfg.Folder(folder.ID)
 		if !ok || !cfg.SharedWith(deviceID) {
 			if deviceCfg.IgnoredFolder(folder.ID) {
-				l.Infof("Ignoring folder %s from device %s since we are configured to", folder.Description(), deviceID)
+				l.With(folder.ID, deviceID.String()).Infof("Ignoring folder %s from device %s since we are configured to", folder.Description(), deviceID)
 				continue
 			}
 			m.cfg.AddOrUpdatePendingFolder(folder.ID, folder.Label, deviceID)
@@ -1060,7 +1015,7 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 				"folderLabel": folder.Label,
 				"device":      deviceID.String(),
 			})
-			l.Infof("Unexpected folder %s sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", folder.Description(), deviceID)
+			l.With(folder.ID, deviceID.String()).Infof("Unexpected folder %s sent from device %q; ensure that the folder exists and that this device is selected under \"Share With\" in the folder configuration.", folder.Description(), deviceID)
 			continue
 		}
 		if folder.Paused {
@@ -1070,6 +1025,12 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 		if cfg.Paused {
 			continue
 		}
+		if folder.SharesIgnores && cfg.IgnoresFrom == deviceID {
+			sharedIgnores[folder.ID] = folder.IgnorePatterns
+		}
+		if folder.SharesMetadata && cfg.ShareMetadata {
+			sharedMetadata = append(sharedMetadata, folder)
+		}
 		fs, ok := m.folderFiles[folder.ID]
 		if !ok {
 			// Shouldn't happen because !cfg.Paused, but might happen
@@ -1077,7 +1038,9 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			continue
 		}
 
-		if !folder.DisableTempIndexes {
+		filter := m.folderIndexFilters[folder.ID][deviceID]
+		// Download progress would tell about filtered files.
+		if !folder.DisableTempIndexes && filter == nil {
 			tempIndexFolders = append(tempIndexFolders, folder.ID)
 		}
 
@@ -1102,7 +1065,7 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 						// the IndexID, or something else weird has
 						// happened. We send a full index to reset the
 						// situation.
-						l.Infof("Device %v folder %s is delta index compatible, but seems out of sync with reality", deviceID, folder.Description())
+						l.With(folder.ID, deviceID.String()).Infof("Device %v folder %s is delta index compatible, but seems out of sync with reality", deviceID, folder.Description())
 						startSequence = 0
 						continue
 					}
@@ -1114,7 +1077,7 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 					// not the right one. Either they are confused or we
 					// must have reset our database since last talking to
 					// them. We'll start with a full index transfer.
-					l.Infof("Device %v folder %s has mismatching index ID for us (%v != %v)", deviceID, folder.Description(), dev.IndexID, myIndexID)
+					l.With(folder.ID, deviceID.String()).Infof("Device %v folder %s has mismatching index ID for us (%v != %v)", deviceID, folder.Description(), dev.IndexID, myIndexID)
 					startSequence = 0
 				}
 			} else if dev.ID == deviceID && dev.IndexID != 0 {
@@ -1130,14 +1093,16 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 					// information we have from them before accepting their
 					// index, which will presumably be a full index.
 					fs.Drop(deviceID)
+					m.availability.forget(folder.ID)
 				} else if dev.IndexID != theirIndexID {
 					// The index ID we have on file is not what they're
 					// announcing. They must have reset their database and
 					// will probably send us a full index. We drop any
 					// information we have and remember this new index ID
 					// instead.
-					l.Infof("Device %v folder %s has a new index ID (%v)", deviceID, folder.Description(), dev.IndexID)
+					l.With(folder.ID, deviceID.String()).Infof("Device %v folder %s has a new index ID (%v)", deviceID, folder.Description(), dev.IndexID)
 					fs.Drop(deviceID)
+					m.availability.forget(folder.ID)
 					fs.SetIndexID(deviceID, dev.IndexID)
 				} else {
 					// They're sending a recognized index ID and will most
@@ -1151,11 +1116,23 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			}
 		}
 
-		go sendIndexes(conn, folder.ID, fs, startSequence, dropSymlinks)
+		go sendIndexes(conn, folder.ID, fs, startSequence, dropSymlinks, filter, m.indexCache)
 	}
 
 	m.pmut.Lock()
 	m.remotePausedFolders[deviceID] = paused
+	if cm.FolderActivity {
+		m.folderActivityPeers[deviceID] = struct{}{}
+	}
+	if cm.RescanHints {
+		m.rescanHintPeers[deviceID] = struct{}{}
+	}
+	if cm.BlockMismatch {
+		m.blockMismatchPeers[deviceID] = struct{}{}
+	}
+	if cm.ConfigPush {
+		m.configPushPeers[deviceID] = struct{}{}
+	}
 	m.pmut.Unlock()
 
 	// This breaks if we send multiple CM messages during the same connection.
@@ -1188,6 +1165,28 @@ func (m *model) ClusterConfig(deviceID protocol.DeviceID, cm protocol.ClusterCon
 			l.Warnln("Failed to save config", err)
 		}
 	}
+
+	// Changes the config, so has to be done after fmut was released above.
+	for _, folder := range sharedMetadata {
+		m.useSharedMetadata(deviceID, folder)
+	}
+
+	// Writing the patterns rescans the folder, which must not hold up the
+	// connection.
+	for folder, patterns := range sharedIgnores {
+		go m.useSharedIgnores(folder, deviceID, patterns)
+	}
+
+	// Removing a folder closes the connections of the devices sharing it,
+	// so it mustn't happen on the connection either.
+	if len(cm.PurgeFolders) > 0 {
+		go m.purgeRetractedFolders(deviceID, cm.PurgeFolders)
+	}
+
+	if cm.FolderActivity {
+		// Acquires fmut, so has to be done after it was released above.
+		conn.FolderActivity(m.folderStatuses(deviceID))
+	}
 }
 
 // handleIntroductions handles adding devices/shares that are shared by an introducer device
@@ -1230,7 +1229,7 @@ func (m *model) handleIntroductions(introducerCfg config.DeviceConfiguration, cm
 
 			// We don't yet share this folder with this device. Add the device
 			// to sharing list of the folder.
-			l.Infof("Sharing folder %s with %v (vouched for by introducer %v)", folder.Description(), device.ID, introducerCfg.DeviceID)
+			l.With(folder.ID, device.ID.String()).Infof("Sharing folder %s with %v (vouched for by introducer %v)", folder.Description(), device.ID, introducerCfg.DeviceID)
 			fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{
 				DeviceID:     device.ID,
 				IntroducedBy: introducerCfg.DeviceID,
@@ -1263,7 +1262,7 @@ func (m *model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 				// We could not find that folder shared on the
 				// introducer with the device that was introduced to us.
 				// We should follow and unshare as well.
-				l.Infof("Unsharing folder %s with %v as introducer %v no longer shares the folder with that device", folders[i].Description(), folders[i].Devices[k].DeviceID, folders[i].Devices[k].IntroducedBy)
+				l.With(folders[i].ID, folders[i].Devices[k].DeviceID.String()).Infof("Unsharing folder %s with %v as introducer %v no longer shares the folder with that device", folders[i].Description(), folders[i].Devices[k].DeviceID, folders[i].Devices[k].IntroducedBy)
 				folders[i].Devices = append(folders[i].Devices[:k], folders[i].Devices[k+1:]...)
 				k--
 				changed = true
@@ -1282,11 +1281,11 @@ func (m *model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 				if _, ok := devicesNotIntroduced[deviceID]; !ok {
 					// The introducer no longer shares any folder with the
 					// device, remove the device.
-					l.Infof("Removing device %v as introducer %v no longer shares any folders with that device", deviceID, device.IntroducedBy)
+					l.With("", deviceID.String()).Infof("Removing device %v as introducer %v no longer shares any folders with that device", deviceID, device.IntroducedBy)
 					changed = true
 					continue
 				}
-				l.Infof("Would have removed %v as %v no longer shares any folders, yet there are other folders that are shared with this device that haven't been introduced by this introducer.", deviceID, device.IntroducedBy)
+				l.With("", deviceID.String()).Infof("Would have removed %v as %v no longer shares any folders, yet there are other folders that are shared with this device that haven't been introduced by this introducer.", deviceID, device.IntroducedBy)
 			}
 		}
 		devices = append(devices, device)
@@ -1302,25 +1301,63 @@ func (m *model) handleDeintroductions(introducerCfg config.DeviceConfiguration,
 	return changed
 }
 
+// autoAcceptPolicy returns the device whose auto-accept settings apply to
+// the folders of the given one: the device itself, if it has
+// AutoAcceptFolders set, or else the introducer which introduced it, if
+// that has AutoAcceptIntroduced set as well.
+func (m *model) autoAcceptPolicy(deviceCfg config.DeviceConfiguration) (config.DeviceConfiguration, bool) {
+	if deviceCfg.AutoAcceptFolders {
+		return deviceCfg, true
+	}
+	if deviceCfg.IntroducedBy == protocol.EmptyDeviceID {
+		return config.DeviceConfiguration{}, false
+	}
+	introducerCfg, ok := m.cfg.Device(deviceCfg.IntroducedBy)
+	if !ok || !introducerCfg.AutoAcceptFolders || !introducerCfg.AutoAcceptIntroduced {
+		return config.DeviceConfiguration{}, false
+	}
+	return introducerCfg, true
+}
+
 // handleAutoAccepts handles adding and sharing folders for devices that have
-// AutoAcceptFolders set to true.
-func (m *model) handleAutoAccepts(deviceCfg config.DeviceConfiguration, folder protocol.Folder) bool {
+// AutoAcceptFolders set to true, on the path and with the folder template
+// the policy device sets.
+func (m *model) handleAutoAccepts(deviceCfg, policy config.DeviceConfiguration, folder protocol.Folder) bool {
+	if err := autoAcceptAllowed(deviceCfg.DeviceID, policy, folder); err != nil {
+		l.With(folder.ID, deviceCfg.DeviceID.String()).Infof("Not auto-accepting folder %s from %s: %v", folder.Description(), deviceCfg.DeviceID, err)
+		return false
+	}
 	if cfg, ok := m.cfg.Folder(folder.ID); !ok {
-		defaultPath := m.cfg.Options().DefaultFolderPath
-		defaultPathFs := fs.NewFilesystem(fs.FilesystemTypeBasic, defaultPath)
-		pathAlternatives := []string{
-			sanitizePath(folder.Label),
-			sanitizePath(folder.ID),
-		}
-		for _, path := range pathAlternatives {
-			if _, err := defaultPathFs.Lstat(path); !fs.IsNotExist(err) {
+		if max := policy.AutoAcceptMaxSize.BaseValue(); max > 0 {
+			if size := m.sharedSize(deviceCfg.DeviceID); float64(size) >= max {
+				l.With(folder.ID, deviceCfg.DeviceID.String()).Infof("Not auto-accepting folder %s from %s: the folders shared with it hold %d bytes, the limit is %v", folder.Description(), deviceCfg.DeviceID, size, policy.AutoAcceptMaxSize)
+				return false
+			}
+		}
+		for _, path := range NewFolderPaths(policy.AutoAcceptPath, m.cfg.Options().DefaultFolderPath, deviceCfg, folder) {
+			parentFs := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Dir(path))
+			if _, err := parentFs.Lstat(filepath.Base(path)); !fs.IsNotExist(err) {
 				continue
 			}
 
-			fcfg := config.NewFolderConfiguration(m.id, folder.ID, folder.Label, fs.FilesystemTypeBasic, filepath.Join(defaultPath, path))
+			fcfg := config.NewFolderConfiguration(m.id, folder.ID, folder.Label, fs.FilesystemTypeBasic, path)
 			fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{
 				DeviceID: deviceCfg.DeviceID,
 			})
+			name := policy.AutoAcceptTemplate
+			if name == "" {
+				name = m.cfg.Options().DefaultFolderTemplate
+			}
+			if name != "" {
+				if tmpl, ok := m.cfg.FolderTemplate(name); ok {
+					tmpl.Apply(&fcfg)
+				} else {
+					l.With(folder.ID, deviceCfg.DeviceID.String()).Warnf("Auto-accepting folder %s: folder template %q does not exist", folder.Description(), name)
+				}
+			}
+			if policy.AutoAcceptReceiveOnly {
+				fcfg.Type = config.FolderTypeReceiveOnly
+			}
 			// Need to wait for the waiter, as this calls CommitConfiguration,
 			// which sets up the folder and as we return from this call,
 			// ClusterConfig starts poking at m.folderFiles and other things
@@ -1328,10 +1365,10 @@ func (m *model) handleAutoAccepts(deviceCfg config.DeviceConfiguration, folder p
 			w, _ := m.cfg.SetFolder(fcfg)
 			w.Wait()
 
-			l.Infof("Auto-accepted %s folder %s at path %s", deviceCfg.DeviceID, folder.Description(), fcfg.Path)
+			l.With(folder.ID, deviceCfg.DeviceID.String()).Infof("Auto-accepted %s folder %s at path %s", deviceCfg.DeviceID, folder.Description(), fcfg.Path)
 			return true
 		}
-		l.Infof("Failed to auto-accept folder %s from %s due to path conflict", folder.Description(), deviceCfg.DeviceID)
+		l.With(folder.ID, deviceCfg.DeviceID.String()).Infof("Failed to auto-accept folder %s from %s due to path conflict", folder.Description(), deviceCfg.DeviceID)
 		return false
 	} else {
 		for _, device := range cfg.DeviceIDs() {
@@ -1345,11 +1382,50 @@ func (m *model) handleAutoAccepts(deviceCfg config.DeviceConfiguration, folder p
 		})
 		w, _ := m.cfg.SetFolder(cfg)
 		w.Wait()
-		l.Infof("Shared %s with %s due to auto-accept", folder.ID, deviceCfg.DeviceID)
+		l.With(folder.ID, deviceCfg.DeviceID.String()).Infof("Shared %s with %s due to auto-accept", folder.ID, deviceCfg.DeviceID)
 		return true
 	}
 }
 
+// autoAcceptAllowed returns why the policy doesn't let the folder the
+// device offers be auto-accepted, if it doesn't.
+func autoAcceptAllowed(device protocol.DeviceID, policy config.DeviceConfiguration, folder protocol.Folder) error {
+	if len(policy.AutoAcceptDevices) > 0 {
+		allowed := false
+		for _, id := range policy.AutoAcceptDevices {
+			if id == device {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.New("device not among those to auto-accept from")
+		}
+	}
+	if policy.AutoAcceptFolderPattern != "" {
+		match, err := path.Match(policy.AutoAcceptFolderPattern, folder.ID)
+		if err != nil {
+			return fmt.Errorf("folder pattern %q: %v", policy.AutoAcceptFolderPattern, err)
+		}
+		if !match {
+			return fmt.Errorf("folder ID doesn't match %q", policy.AutoAcceptFolderPattern)
+		}
+	}
+	return nil
+}
+
+// sharedSize returns the global size, in bytes, of the folders shared with
+// the device.
+func (m *model) sharedSize(device protocol.DeviceID) int64 {
+	var size int64
+	for _, fcfg := range m.cfg.Folders() {
+		if fcfg.SharedWith(device) {
+			size += m.GlobalSize(fcfg.ID).Bytes
+		}
+	}
+	return size
+}
+
 func (m *model) introduceDevice(device protocol.Device, introducerCfg config.DeviceConfiguration) {
 	addresses := []string{"dynamic"}
 	for _, addr := range device.Addresses {
@@ -1358,7 +1434,7 @@ func (m *model) introduceDevice(device protocol.Device, introducerCfg config.Dev
 		}
 	}
 
-	l.Infof("Adding device %v to config (vouched for by introducer %v)", device.ID, introducerCfg.DeviceID)
+	l.With("", device.ID.String()).Infof("Adding device %v to config (vouched for by introducer %v)", device.ID, introducerCfg.DeviceID)
 	newDeviceCfg := config.DeviceConfiguration{
 		DeviceID:     device.ID,
 		Name:         device.Name,
@@ -1370,7 +1446,7 @@ func (m *model) introduceDevice(device protocol.Device, introducerCfg config.Dev
 
 	// The introducers' introducers are also our introducers.
 	if device.Introducer {
-		l.Infof("Device %v is now also an introducer", device.ID)
+		l.With("", device.ID.String()).Infof("Device %v is now also an introducer", device.ID)
 		newDeviceCfg.Introducer = true
 		newDeviceCfg.SkipIntroductionRemovals = device.SkipIntroductionRemovals
 	}
@@ -1392,15 +1468,26 @@ func (m *model) Closed(conn protocol.Connection, err error) {
 	delete(m.helloMessages, device)
 	delete(m.deviceDownloads, device)
 	delete(m.remotePausedFolders, device)
+	delete(m.folderActivityPeers, device)
+	delete(m.rescanHintPeers, device)
+	delete(m.blockMismatchPeers, device)
+	delete(m.configPushPeers, device)
+	delete(m.remoteFolderStatus, device)
 	closed := m.closed[device]
 	delete(m.closed, device)
+	rec, recorded := m.recordDisconnectedLocked(device, err)
 	m.pmut.Unlock()
+	activity.forget(device)
 
-	l.Infof("Connection to %s at %s closed: %v", device, conn.Name(), err)
-	events.Default.Log(events.DeviceDisconnected, map[string]string{
+	l.With("", device.String()).Infof("Connection to %s at %s closed: %v", device, conn.Name(), err)
+	event := map[string]string{
 		"id":    device.String(),
 		"error": err.Error(),
-	})
+	}
+	if recorded {
+		rec.event(event)
+	}
+	events.Default.Log(events.DeviceDisconnected, event)
 	close(closed)
 }
 
@@ -1459,9 +1546,21 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 		return nil, protocol.ErrInvalid
 	}
 
+	_, span := tracing.Start(context.Background(), "model.request")
+	span.SetAttribute("folder", folder)
+	span.SetAttribute("file", name)
+	span.SetAttribute("offset", offset)
+	span.SetAttribute("size", size)
+	span.SetAttribute("device", deviceID.String())
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
 	m.fmut.RLock()
 	folderCfg, ok := m.folderCfgs[folder]
 	folderIgnores := m.folderIgnores[folder]
+	filter := m.folderIndexFilters[folder][deviceID]
 	m.fmut.RUnlock()
 	if !ok {
 		// The folder might be already unpaused in the config, but not yet
@@ -1471,7 +1570,7 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 	}
 
 	if !folderCfg.SharedWith(deviceID) {
-		l.Warnf("Request from %s for file %s in unshared folder %q", deviceID, name, folder)
+		l.With(folder, deviceID.String()).Warnf("Request from %s for file %s in unshared folder %q", deviceID, name, folder)
 		return nil, protocol.ErrGeneric
 	}
 	if folderCfg.Paused {
@@ -1499,6 +1598,12 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 		return nil, protocol.ErrInvalid
 	}
 
+	if filter.filtered(name) {
+		// As far as the device knows, the file doesn't exist.
+		l.Debugf("%v REQ(in) for filtered file: %s: %q / %q o=%d s=%d", m, deviceID, folder, name, offset, size)
+		return nil, protocol.ErrNoSuchFile
+	}
+
 	folderFs := folderCfg.Filesystem()
 
 	if err := osutil.TraversesSymlink(folderFs, filepath.Dir(name)); err != nil {
@@ -1516,6 +1621,9 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 		limiter.take(int(size))
 	}
 
+	// Share the capacity for handling requests fairly between devices
+	m.requestScheduler.take(deviceID, int(size))
+
 	// The requestResponse releases the bytes to the limiter when its Close method is called.
 	res := newRequestResponse(int(size))
 	defer func() {
@@ -1525,26 +1633,36 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 		}
 	}()
 
-	if limiter != nil {
-		go func() {
-			res.Wait()
+	go func() {
+		res.Wait()
+		m.requestScheduler.give(int(size))
+		if limiter != nil {
 			limiter.give(int(size))
-		}()
+		}
+	}()
+
+	if folderCfg.IdleIOPriority {
+		restore := osutil.SetIdleIOPriority()
+		defer restore()
 	}
 
 	// Only check temp files if the flag is set, and if we are set to advertise
 	// the temp indexes.
 	if fromTemporary && !folderCfg.DisableTempIndexes {
-		tempFn := fs.TempName(name)
+		tempFs := folderFs
+		if folderCfg.TempDir != "" {
+			tempFs = folderCfg.TempFilesystem()
+		}
+		tempFn := folderCfg.TempName(name)
 
-		if info, err := folderFs.Lstat(tempFn); err != nil || !info.IsRegular() {
+		if info, err := tempFs.Lstat(tempFn); err != nil || !info.IsRegular() {
 			// Reject reads for anything that doesn't exist or is something
 			// other than a regular file.
 			l.Debugf("%v REQ(in) failed stating temp file (%v): %s: %q / %q o=%d s=%d", m, err, deviceID, folder, name, offset, size)
 			return nil, protocol.ErrNoSuchFile
 		}
-		err := readOffsetIntoBuf(folderFs, tempFn, offset, res.data)
-		if err == nil && scanner.Validate(res.data, hash, weakHash) {
+		err := readOffsetIntoBuf(tempFs, tempFn, offset, res.data)
+		if err == nil && (folderCfg.Type == config.FolderTypeReceiveEncrypted || scanner.Validate(res.data, hash, weakHash)) {
 			return res, nil
 		}
 		// Fall through to reading from a non-temp file, just incase the temp
@@ -1566,7 +1684,7 @@ func (m *model) Request(deviceID protocol.DeviceID, folder, name string, size in
 		return nil, protocol.ErrGeneric
 	}
 
-	if !scanner.Validate(res.data, hash, weakHash) {
+	if folderCfg.Type != config.FolderTypeReceiveEncrypted && !scanner.Validate(res.data, hash, weakHash) {
 		m.recheckFile(deviceID, folderFs, folder, name, size, offset, hash)
 		l.Debugf("%v REQ(in) failed validating data (%v): %s: %q / %q o=%d s=%d", m, err, deviceID, folder, name, offset, size)
 		return nil, protocol.ErrNoSuchFile
@@ -1619,6 +1737,58 @@ func (m *model) recheckFile(deviceID protocol.DeviceID, folderFs fs.Filesystem,
 	l.Debugf("%v recheckFile: %s: %q / %q", m, deviceID, folder, name)
 }
 
+// crossFolderSource looks for a file in another folder on this device that
+// has exactly the same contents as the given file and is about to vanish
+// from that folder, i.e. the global version of it is deleted. This is what
+// a move of a file between two shared folders looks like from the
+// receiving side. The folder and the current local file info of the source
+// are returned.
+func (m *model) crossFolderSource(folder string, file protocol.FileInfo) (string, protocol.FileInfo, bool) {
+	if len(file.Blocks) == 0 {
+		return "", protocol.FileInfo{}, false
+	}
+
+	m.fmut.RLock()
+	folders := make([]string, 0, len(m.folderFiles))
+	fsets := make(map[string]*db.FileSet, len(m.folderFiles))
+	for id, fset := range m.folderFiles {
+		if id == folder {
+			continue
+		}
+		folders = append(folders, id)
+		fsets[id] = fset
+	}
+	m.fmut.RUnlock()
+
+	type candidate struct {
+		folder, name string
+	}
+	var candidates []candidate
+	m.finder.Iterate(folders, file.Blocks[0].Hash, func(folder, name string, index int32) bool {
+		if index == 0 {
+			candidates = append(candidates, candidate{folder, name})
+		}
+		return false
+	})
+
+	for _, c := range candidates {
+		fset := fsets[c.folder]
+		cur, ok := fset.Get(protocol.LocalDeviceID, c.name)
+		if !ok || cur.IsDeleted() || cur.IsInvalid() || cur.Type != protocol.FileInfoTypeFile {
+			continue
+		}
+		if !protocol.BlocksEqual(cur.Blocks, file.Blocks) {
+			continue
+		}
+		if global, ok := fset.GetGlobal(c.name); !ok || !global.IsDeleted() {
+			continue
+		}
+		return c.folder, cur, true
+	}
+
+	return "", protocol.FileInfo{}, false
+}
+
 func (m *model) CurrentFolderFile(folder string, file string) (protocol.FileInfo, bool) {
 	m.fmut.RLock()
 	fs, ok := m.folderFiles[folder]
@@ -1669,7 +1839,7 @@ func (m *model) GetIgnores(folder string) ([]string, []string, error) {
 
 	ignores, ok := m.folderIgnores[folder]
 	if !ok {
-		ignores = ignore.New(fs.NewFilesystem(cfg.FilesystemType, cfg.Path))
+		ignores = ignore.New(fs.NewFilesystem(cfg.FilesystemType, cfg.Path), ignore.WithPatternSets(m.cfg.IgnorePatternSet))
 	}
 
 	if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
@@ -1679,6 +1849,58 @@ func (m *model) GetIgnores(folder string) ([]string, []string, error) {
 	return ignores.Lines(), ignores.Patterns(), nil
 }
 
+// An IgnoreTestResult tells whether a file is ignored, and by which
+// pattern. Internal files are always ignored, regardless of the patterns.
+type IgnoreTestResult struct {
+	File      string `json:"file"`
+	Pattern   string `json:"pattern,omitempty"`
+	Ignored   bool   `json:"ignored"`
+	Deletable bool   `json:"deletable"`
+	Internal  bool   `json:"internal,omitempty"`
+}
+
+// TestIgnores evaluates the given files, relative to the folder root,
+// against the ignore patterns currently in use by the folder.
+func (m *model) TestIgnores(folder string, files []string) ([]IgnoreTestResult, error) {
+	m.fmut.RLock()
+	cfg, cfgOk := m.folderCfgs[folder]
+	ignores, ok := m.folderIgnores[folder]
+	m.fmut.RUnlock()
+
+	if !cfgOk {
+		if cfg, cfgOk = m.cfg.Folders()[folder]; !cfgOk {
+			return nil, errFolderMissing
+		}
+	}
+	if !ok {
+		// The folder isn't running, so use what it would load.
+		ignores = ignore.New(fs.NewFilesystem(cfg.FilesystemType, cfg.Path), ignore.WithPatternSets(m.cfg.IgnorePatternSet))
+		if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	res := make([]IgnoreTestResult, len(files))
+	for i, file := range files {
+		name, err := fs.Canonicalize(filepath.FromSlash(file))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		result, pattern := ignores.MatchPattern(name)
+		res[i] = IgnoreTestResult{
+			File:      file,
+			Pattern:   pattern,
+			Ignored:   result.IsIgnored(),
+			Deletable: result.IsDeletable(),
+		}
+		if fs.IsInternal(name) {
+			res[i].Internal = true
+			res[i].Ignored = true
+		}
+	}
+	return res, nil
+}
+
 func (m *model) SetIgnores(folder string, content []string) error {
 	cfg, ok := m.cfg.Folders()[folder]
 	if !ok {
@@ -1768,7 +1990,7 @@ func (m *model) AddConnection(conn connections.Connection, hello protocol.HelloR
 
 	m.pmut.Lock()
 	if oldConn, ok := m.conn[deviceID]; ok {
-		l.Infoln("Replacing old connection", oldConn, "with", conn, "for", deviceID)
+		l.With("", deviceID.String()).Infoln("Replacing old connection", oldConn, "with", conn, "for", deviceID)
 		// There is an existing connection to this device that we are
 		// replacing. We must close the existing connection and wait for the
 		// close to complete before adding the new connection. We do the
@@ -1794,22 +2016,23 @@ func (m *model) AddConnection(conn connections.Connection, hello protocol.HelloR
 
 	m.helloMessages[deviceID] = hello
 
-	event := map[string]string{
-		"id":            deviceID.String(),
-		"deviceName":    hello.DeviceName,
-		"clientName":    hello.ClientName,
-		"clientVersion": hello.ClientVersion,
-		"type":          conn.Type(),
-	}
+	rec := newConnectionRecord(conn, hello)
+	m.recordConnectedLocked(deviceID, rec)
 
-	addr := conn.RemoteAddr()
-	if addr != nil {
-		event["addr"] = addr.String()
+	event := map[string]string{
+		"id":         deviceID.String(),
+		"deviceName": hello.DeviceName,
+		"clientName": hello.ClientName,
 	}
+	rec.event(event)
 
 	events.Default.Log(events.DeviceConnected, event)
 
-	l.Infof(`Device %s client is "%s %s" named "%s" at %s`, deviceID, hello.ClientName, hello.ClientVersion, hello.DeviceName, conn)
+	l.With("", deviceID.String()).Infof(`Device %s client is "%s %s" named "%s" at %s`, deviceID, hello.ClientName, hello.ClientVersion, hello.DeviceName, conn)
+
+	if tracer, ok := m.protocolTracers[deviceID]; ok && !tracer.Stopped() {
+		conn.SetTracer(tracer)
+	}
 
 	conn.Start()
 	m.pmut.Unlock()
@@ -1865,7 +2088,7 @@ func (m *model) deviceWasSeen(deviceID protocol.DeviceID) {
 	m.deviceStatRef(deviceID).WasSeen()
 }
 
-func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, prevSequence int64, dropSymlinks bool) {
+func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, prevSequence int64, dropSymlinks bool, filter *indexFilter, cache *indexCache) {
 	deviceID := conn.ID()
 	var err error
 
@@ -1873,7 +2096,7 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, prevSe
 	defer l.Debugf("Exiting sendIndexes for %s to %s at %s: %v", folder, deviceID, conn, err)
 
 	// We need to send one index, regardless of whether there is something to send or not
-	prevSequence, err = sendIndexTo(prevSequence, conn, folder, fs, dropSymlinks)
+	prevSequence, err = sendIndexTo(prevSequence, conn, folder, fs, dropSymlinks, filter, cache)
 
 	// Subscribe to LocalIndexUpdated (we have new information to send) and
 	// DeviceDisconnected (it might be us who disconnected, so we should
@@ -1896,7 +2119,7 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, prevSe
 			continue
 		}
 
-		prevSequence, err = sendIndexTo(prevSequence, conn, folder, fs, dropSymlinks)
+		prevSequence, err = sendIndexTo(prevSequence, conn, folder, fs, dropSymlinks, filter, cache)
 
 		// Wait a short amount of time before entering the next loop. If there
 		// are continuous changes happening to the local index, this gives us
@@ -1907,17 +2130,22 @@ func sendIndexes(conn protocol.Connection, folder string, fs *db.FileSet, prevSe
 
 // sendIndexTo sends file infos with a sequence number higher than prevSequence and
 // returns the highest sent sequence number.
-func sendIndexTo(prevSequence int64, conn protocol.Connection, folder string, fs *db.FileSet, dropSymlinks bool) (int64, error) {
+func sendIndexTo(prevSequence int64, conn protocol.Connection, folder string, fs *db.FileSet, dropSymlinks bool, filter *indexFilter, cache *indexCache) (int64, error) {
 	deviceID := conn.ID()
 	initial := prevSequence == 0
+	if filter != nil {
+		// Batches of filtered files are particular to the device.
+		cache = nil
+	}
 	batch := newFileInfoBatch(nil)
 	batch.flushFn = func(fs []protocol.FileInfo) error {
 		l.Debugf("Sending indexes for %s to %s at %s: %d files (<%d bytes)", folder, deviceID, conn, len(batch.infos), batch.size)
-		if initial {
-			initial = false
-			return conn.Index(folder, fs)
+		enc, err := cache.encoded(folder, fs, !initial, dropSymlinks)
+		if err != nil {
+			return err
 		}
-		return conn.IndexUpdate(folder, fs)
+		initial = false
+		return conn.SendEncodedIndex(enc)
 	}
 
 	var err error
@@ -1956,6 +2184,10 @@ func sendIndexTo(prevSequence int64, conn protocol.Connection, folder string, fs
 			return true
 		}
 
+		if filter.filtered(f.Name) {
+			return true
+		}
+
 		batch.append(f)
 		return true
 	})
@@ -2041,6 +2273,20 @@ func (m *model) ScanFolderSubdirs(folder string, subs []string) error {
 	return runner.Scan(subs)
 }
 
+// RehashFolderSubdirs scans the subdirs of the folder, or the whole folder
+// if there are none, hashing all regular files even if they seem unchanged.
+func (m *model) RehashFolderSubdirs(folder string, subs []string) (RehashReport, error) {
+	m.fmut.RLock()
+	if err := m.checkFolderRunningLocked(folder); err != nil {
+		m.fmut.RUnlock()
+		return RehashReport{}, err
+	}
+	runner := m.folderRunners[folder]
+	m.fmut.RUnlock()
+
+	return runner.Rehash(subs)
+}
+
 func (m *model) DelayScan(folder string, next time.Duration) {
 	m.fmut.Lock()
 	runner, ok := m.folderRunners[folder]
@@ -2083,11 +2329,16 @@ func (m *model) numHashers(folder string) int {
 // generateClusterConfig returns a ClusterConfigMessage that is correct for
 // the given peer device
 func (m *model) generateClusterConfig(device protocol.DeviceID) protocol.ClusterConfig {
-	var message protocol.ClusterConfig
+	message := protocol.ClusterConfig{
+		FolderActivity: true,
+		RescanHints:    true,
+		BlockMismatch:  true,
+	}
 
 	m.fmut.RLock()
 	defer m.fmut.RUnlock()
 
+	now := time.Now()
 	for _, folderCfg := range m.cfg.FolderList() {
 		if !folderCfg.SharedWith(device) {
 			continue
@@ -2103,12 +2354,29 @@ func (m *model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 			Paused:             folderCfg.Paused,
 		}
 
+		if folderCfg.ShareIgnores {
+			// The lines are sent as written, so files they include must
+			// exist on the receiving side as well.
+			protocolFolder.SharesIgnores = true
+			if ignores, ok := m.folderIgnores[folderCfg.ID]; ok {
+				protocolFolder.IgnorePatterns = ignores.Lines()
+			}
+		}
+
+		if folderCfg.ShareMetadata {
+			setSharedMetadata(&protocolFolder, folderCfg)
+		}
+
 		var fs *db.FileSet
 		if !folderCfg.Paused {
 			fs = m.folderFiles[folderCfg.ID]
 		}
 
 		for _, device := range folderCfg.Devices {
+			if device.Expired(now) {
+				// Not to be introduced to others.
+				continue
+			}
 			deviceCfg, _ := m.cfg.Device(device.DeviceID)
 
 			protocolDevice := protocol.Device{
@@ -2136,6 +2404,11 @@ func (m *model) generateClusterConfig(device protocol.DeviceID) protocol.Cluster
 		message.Folders = append(message.Folders, protocolFolder)
 	}
 
+	if deviceCfg, ok := m.cfg.Device(device); ok {
+		message.PurgeFolders = deviceCfg.RetractedFolders
+		message.ConfigPush = deviceCfg.ConfigManager && deviceCfg.ConfigPushKey != ""
+	}
+
 	return message
 }
 
@@ -2153,6 +2426,24 @@ func (m *model) State(folder string) (string, time.Time, error) {
 	return state.String(), changed, err
 }
 
+// DatabaseError returns an error when the database can't be used.
+func (m *model) DatabaseError() error {
+	return m.db.Check()
+}
+
+// ScanProgress returns the progress of the scans running, by folder.
+func (m *model) ScanProgress() map[string]scanner.Progress {
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+	res := make(map[string]scanner.Progress)
+	for folder, runner := range m.folderRunners {
+		if p, ok := runner.ScanProgress(); ok {
+			res[folder] = p
+		}
+	}
+	return res
+}
+
 func (m *model) FolderErrors(folder string) ([]FileError, error) {
 	m.fmut.RLock()
 	defer m.fmut.RUnlock()
@@ -2256,7 +2547,7 @@ func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsonly
 		prefix = prefix + sep
 	}
 
-	files.WithPrefixedGlobalTruncated(prefix, func(fi db.FileIntf) bool {
+	files.WithPrefixedGlobalTruncatedLevels(prefix, levels, func(fi db.FileIntf) bool {
 		f := fi.(db.FileInfoTruncated)
 
 		// Don't include the prefix itself.
@@ -2342,6 +2633,165 @@ func (m *model) RestoreFolderVersions(folder string, versions map[string]time.Ti
 	return restoreErrors, nil
 }
 
+// RestoreFolderVersion puts the given version of the file back in place of
+// the current one, which is archived in turn. A zero version time restores
+// the newest version.
+func (m *model) RestoreFolderVersion(folder, file string, versionTime time.Time) (versioner.FileVersion, error) {
+	fcfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return versioner.FileVersion{}, errFolderMissing
+	}
+
+	ver := fcfg.Versioner()
+	if ver == nil {
+		return versioner.FileVersion{}, errors.New("no versioner configured")
+	}
+
+	allVersions, err := ver.GetVersions()
+	if err != nil {
+		return versioner.FileVersion{}, err
+	}
+
+	var found *versioner.FileVersion
+	versions := allVersions[osutil.NormalizedFilename(file)]
+	for i := range versions {
+		switch {
+		case versionTime.IsZero():
+			if found == nil || versions[i].VersionTime.After(found.VersionTime) {
+				found = &versions[i]
+			}
+		case versions[i].VersionTime.Equal(versionTime):
+			found = &versions[i]
+		}
+	}
+	if found == nil {
+		return versioner.FileVersion{}, errNoSuchVersion
+	}
+
+	if err := ver.Restore(file, found.VersionTime); err != nil {
+		return versioner.FileVersion{}, err
+	}
+
+	// Trigger scan
+	if !fcfg.FSWatcherEnabled {
+		go func() { _ = m.ScanFolderSubdirs(folder, []string{file}) }()
+	}
+
+	return *found, nil
+}
+
+// GetFolderVersionAt opens the version of the file that was current at the
+// given time. That's the file in the folder if it was last modified at or
+// before then, otherwise an archived version. If the index snapshot from
+// then knows the file, the version with the modification time recorded
+// there is used. Otherwise it's the newest version modified at or before
+// the given time.
+func (m *model) GetFolderVersionAt(folder, file string, when time.Time) (versioner.FileVersion, fs.File, error) {
+	fcfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return versioner.FileVersion{}, nil, errFolderMissing
+	}
+
+	m.fmut.RLock()
+	files, haveIndex := m.folderFiles[folder]
+	m.fmut.RUnlock()
+
+	if haveIndex {
+		if cur, ok := files.Get(protocol.LocalDeviceID, file); ok && !cur.IsDeleted() && !cur.IsInvalid() && !cur.ModTime().After(when) {
+			if fd, err := fcfg.Filesystem().Open(file); err == nil {
+				modTime := cur.ModTime().Truncate(time.Second)
+				return versioner.FileVersion{VersionTime: modTime, ModTime: modTime, Size: cur.Size}, fd, nil
+			}
+		}
+	}
+
+	ver := fcfg.Versioner()
+	if ver == nil {
+		return versioner.FileVersion{}, nil, errors.New("no versioner configured")
+	}
+
+	allVersions, err := ver.GetVersions()
+	if err != nil {
+		return versioner.FileVersion{}, nil, err
+	}
+	versions := allVersions[osutil.NormalizedFilename(file)]
+
+	var found *versioner.FileVersion
+
+	if haveIndex {
+		if f, ok, err := files.GetSnapshotFile(when, file); err == nil && ok {
+			if f.IsDeleted() {
+				return versioner.FileVersion{}, nil, errNoVersionAt
+			}
+			modTime := f.ModTime().Truncate(time.Second)
+			for i := range versions {
+				if versions[i].VersionTime.Equal(modTime) {
+					found = &versions[i]
+					break
+				}
+			}
+		}
+	}
+
+	if found == nil {
+		for i := range versions {
+			if versions[i].VersionTime.After(when) {
+				continue
+			}
+			if found == nil || versions[i].VersionTime.After(found.VersionTime) {
+				found = &versions[i]
+			}
+		}
+	}
+
+	if found == nil {
+		return versioner.FileVersion{}, nil, errNoVersionAt
+	}
+
+	fd, err := ver.Open(file, found.VersionTime)
+	if err != nil {
+		return versioner.FileVersion{}, nil, err
+	}
+	return *found, fd, nil
+}
+
+// ChangeHeatmap returns how often the directories of the folder changed
+// recently, those that changed most first.
+func (m *model) ChangeHeatmap(folder string, levels, limit int) ([]DirChanges, error) {
+	m.fmut.RLock()
+	_, ok := m.folderCfgs[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	return m.changeHeat.changes(folder, levels, limit, time.Now()), nil
+}
+
+// IndexSnapshots returns the times of the stored index snapshots of the
+// folder, oldest first.
+func (m *model) IndexSnapshots(folder string) ([]time.Time, error) {
+	m.fmut.RLock()
+	fs, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	return fs.Snapshots(), nil
+}
+
+// DiffIndexSnapshots returns what appeared, changed and vanished in the
+// folder between the index snapshots in effect at from and to. A zero to
+// compares against the current global state.
+func (m *model) DiffIndexSnapshots(folder string, from, to time.Time) ([]db.SnapshotChange, error) {
+	m.fmut.RLock()
+	fs, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+	return fs.DiffSnapshots(from, to)
+}
+
 func (m *model) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) []Availability {
 	// The slightly unusual locking sequence here is because we need to hold
 	// pmut for the duration (as the value returned from foldersFiles can
@@ -2361,7 +2811,7 @@ func (m *model) Availability(folder string, file protocol.FileInfo, block protoc
 
 	var availabilities []Availability
 next:
-	for _, device := range fs.Availability(file.Name) {
+	for _, device := range m.availability.devices(fs, folder, file) {
 		for _, pausedFolder := range m.remotePausedFolders[device] {
 			if pausedFolder == folder {
 				continue next
@@ -2382,6 +2832,12 @@ next:
 	return availabilities
 }
 
+// RankSources returns the given availability ordered by how well the devices
+// have performed as sources of blocks, best first.
+func (m *model) RankSources(availability []Availability) []RankedAvailability {
+	return activity.rank(availability)
+}
+
 // BringToFront bumps the given files priority in the job queue.
 func (m *model) BringToFront(folder, file string) {
 	m.pmut.RLock()
@@ -2394,7 +2850,7 @@ func (m *model) BringToFront(folder, file string) {
 }
 
 func (m *model) ResetFolder(folder string) {
-	l.Infof("Cleaning data for folder %q", folder)
+	l.With(folder, "").Infof("Cleaning data for folder %q", folder)
 	db.DropFolder(m.db, folder)
 }
 
@@ -2403,6 +2859,17 @@ func (m *model) String() string {
 }
 
 func (m *model) VerifyConfiguration(from, to config.Configuration) error {
+	// Refuse new folders and new folder paths which overlap in a way that
+	// ignore patterns can't fix.
+	fromFolders := mapFolders(from.Folders)
+	for _, cfg := range to.Folders {
+		if fromCfg, ok := fromFolders[cfg.ID]; ok && fromCfg.Path == cfg.Path && fromCfg.FilesystemType == cfg.FilesystemType {
+			continue
+		}
+		if err := checkFolderRootOverlap(cfg, to.Folders, protectedDirs()); err != nil {
+			return fmt.Errorf("folder %s: %v", cfg.Description(), err)
+		}
+	}
 	return nil
 }
 
@@ -2416,10 +2883,13 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 	for folderID, cfg := range toFolders {
 		if _, ok := fromFolders[folderID]; !ok {
 			// A folder was added.
+			if tmpl, ok := to.FolderTemplate(cfg.Template); ok && len(tmpl.Ignores) > 0 {
+				writeTemplateIgnores(cfg, tmpl)
+			}
 			if cfg.Paused {
-				l.Infoln("Paused folder", cfg.Description())
+				l.With(cfg.ID, "").Infoln("Paused folder", cfg.Description())
 			} else {
-				l.Infoln("Adding folder", cfg.Description())
+				l.With(cfg.ID, "").Infoln("Adding folder", cfg.Description())
 				m.AddFolder(cfg)
 				m.StartFolder(folderID)
 			}
@@ -2442,6 +2912,10 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 		// Check if anything differs that requires a restart.
 		if !reflect.DeepEqual(fromCfg.RequiresRestartOnly(), toCfg.RequiresRestartOnly()) {
 			m.RestartFolder(fromCfg, toCfg)
+		} else if toCfg.ShareMetadata && !fromCfg.MetadataChanged.Equal(toCfg.MetadataChanged) {
+			// The metadata is announced in the cluster config, which is
+			// only sent when connecting.
+			m.closeConns(toCfg.DeviceIDs(), fmt.Errorf("shared metadata of folder %v changed", toCfg.Description()))
 		}
 
 		// Emit the folder pause/resume event
@@ -2466,6 +2940,11 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 	toDevices := to.DeviceMap()
 	for deviceID, toCfg := range toDevices {
 		fromCfg, ok := fromDevices[deviceID]
+		if ok && !toCfg.Paused && (fromCfg.ConfigManager != toCfg.ConfigManager || fromCfg.ConfigPushKey != toCfg.ConfigPushKey) {
+			// Whether we accept config pushes from the device is announced
+			// in the cluster config, which is only sent when connecting.
+			m.closeConn(deviceID, errConfigManagerChanged)
+		}
 		if !ok || fromCfg.Paused == toCfg.Paused {
 			continue
 		}
@@ -2476,7 +2955,7 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 		}
 
 		if toCfg.Paused {
-			l.Infoln("Pausing", deviceID)
+			l.With("", deviceID.String()).Infoln("Pausing", deviceID)
 			m.closeConn(deviceID, errDevicePaused)
 			events.Default.Log(events.DevicePaused, map[string]string{"device": deviceID.String()})
 		} else {
@@ -2484,7 +2963,11 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 		}
 	}
 
+	m.rescanForPatternSets(from.IgnorePatternSets, to.IgnorePatternSets)
+
 	scanLimiter.setCapacity(to.Options.MaxConcurrentScans)
+	m.requestScheduler.setCapacity(incomingRequestCapacity(to.Options))
+	m.requestScheduler.setWeights(to.Devices)
 
 	// Some options don't require restart as those components handle it fine
 	// by themselves. Compare the options structs containing only the
@@ -2497,6 +2980,47 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 	return true
 }
 
+// rescanForPatternSets scans the folders whose ignore patterns include an
+// ignore pattern set that changed, so that the new patterns take effect.
+func (m *model) rescanForPatternSets(from, to []config.IgnorePatternSet) {
+	fromSets := make(map[string][]string, len(from))
+	for _, set := range from {
+		fromSets[set.Name] = set.Patterns
+	}
+	changed := make(map[string]struct{})
+	for _, set := range to {
+		if fromPatterns, ok := fromSets[set.Name]; !ok || !reflect.DeepEqual(fromPatterns, set.Patterns) {
+			changed[set.Name] = struct{}{}
+		}
+		delete(fromSets, set.Name)
+	}
+	for name := range fromSets {
+		// Removed.
+		changed[name] = struct{}{}
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+	for folder, ignores := range m.folderIgnores {
+		runner, ok := m.folderRunners[folder]
+		if !ok {
+			continue
+		}
+		for _, name := range ignores.PatternSets() {
+			if _, ok := changed[name]; ok {
+				l.Debugf("%v rescanning folder %s, ignore pattern set %q changed", m, folder, name)
+				// The scan reloads the ignores. Not waiting for it, as
+				// it may take a while.
+				go runner.Scan(nil)
+				break
+			}
+		}
+	}
+}
+
 // checkFolderRunningLocked returns nil if the folder is up and running and a
 // descriptive error if not.
 // Need to hold (read) lock on m.fmut when calling this.
@@ -2699,6 +3223,53 @@ func (m *syncMutexMap) Get(key string) sync.Mutex {
 // We include whitespace in the invalid characters so that multiple
 // whitespace is collapsed to a single space. Additionally, whitespace at
 // either end is removed.
+// NewFolderPaths returns the paths to create a folder shared by the device
+// at, such as an auto-accepted one, in order of preference, from the path
+// template. The template may contain
+// {folderlabel}, which is the label of the folder or its ID if that path is
+// taken, {folderid} and {devicename}, the name of the device sharing the
+// folder, and is relative to the default folder path unless absolute or
+// starting with a tilde.
+func NewFolderPaths(template, defaultPath string, deviceCfg config.DeviceConfiguration, folder protocol.Folder) []string {
+	if template == "" {
+		template = "{folderlabel}"
+	}
+	deviceName := deviceCfg.Name
+	if deviceName == "" {
+		deviceName = deviceCfg.DeviceID.Short().String()
+	}
+	var paths []string
+	for _, name := range []string{folder.Label, folder.ID} {
+		if strings.Trim(sanitizePath(name), ".") == "" {
+			continue
+		}
+		path := strings.NewReplacer(
+			"{folderlabel}", sanitizePath(name),
+			"{folderid}", sanitizePath(folder.ID),
+			"{devicename}", sanitizePath(deviceName),
+		).Replace(template)
+		relative := !filepath.IsAbs(path) && !strings.HasPrefix(path, "~")
+		if relative {
+			path = filepath.Join(defaultPath, path)
+		}
+		if expanded, err := fs.ExpandTilde(path); err == nil {
+			path = expanded
+		}
+		path = filepath.Clean(path)
+		if relative {
+			// The names come from the remote device and must not take
+			// the path out of the default folder path.
+			if rel, err := filepath.Rel(filepath.Clean(defaultPath), path); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+		}
+		if len(paths) == 0 || paths[len(paths)-1] != path {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 func sanitizePath(path string) string {
 	invalid := regexp.MustCompile(`([[:cntrl:]]|[<>:"'/\\|?*\n\r\t \[\]\{\};:!@$%&^#])+`)
 	return strings.TrimSpace(invalid.ReplaceAllString(path, " "))
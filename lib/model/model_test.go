@@ -17,6 +17,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"runtime/pprof"
 	"strconv"
@@ -312,6 +313,9 @@ type downloadProgressMessage struct {
 type fakeConnection struct {
 	id                       protocol.DeviceID
 	downloadProgressMessages []downloadProgressMessage
+	folderActivityMessages   [][]protocol.FolderStatus
+	rescanHintMessages       []protocol.RescanHint
+	blockMismatchMessages    []protocol.BlockMismatch
 	closed                   bool
 	files                    []protocol.FileInfo
 	fileData                 map[string][]byte
@@ -319,6 +323,8 @@ type fakeConnection struct {
 	model                    *model
 	indexFn                  func(string, []protocol.FileInfo)
 	requestFn                func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error)
+	configPushFn             func(id int64, config, signature []byte)
+	configPushResponseFn     func(id int64, err string)
 	mut                      sync.Mutex
 }
 
@@ -365,6 +371,19 @@ func (f *fakeConnection) IndexUpdate(folder string, fs []protocol.FileInfo) erro
 	return nil
 }
 
+func (f *fakeConnection) SendEncodedIndex(idx *protocol.EncodedIndex) error {
+	fs, err := idx.Files()
+	if err != nil {
+		return err
+	}
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.indexFn != nil {
+		f.indexFn(idx.Folder(), fs)
+	}
+	return nil
+}
+
 func (f *fakeConnection) Request(folder, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
 	f.mut.Lock()
 	defer f.mut.Unlock()
@@ -388,6 +407,8 @@ func (f *fakeConnection) Closed() bool {
 	return f.closed
 }
 
+func (f *fakeConnection) SetTracer(t *protocol.Tracer) {}
+
 func (f *fakeConnection) Statistics() protocol.Statistics {
 	return protocol.Statistics{}
 }
@@ -419,6 +440,36 @@ func (f *fakeConnection) DownloadProgress(folder string, updates []protocol.File
 	})
 }
 
+func (f *fakeConnection) FolderActivity(folders []protocol.FolderStatus) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.folderActivityMessages = append(f.folderActivityMessages, folders)
+}
+
+func (f *fakeConnection) RescanHint(folder string, names []string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.rescanHintMessages = append(f.rescanHintMessages, protocol.RescanHint{Folder: folder, Names: names})
+}
+
+func (f *fakeConnection) BlockMismatch(folder, name string, offset int64, size int32, hash []byte) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.blockMismatchMessages = append(f.blockMismatchMessages, protocol.BlockMismatch{Folder: folder, Name: name, Offset: offset, Size: size, Hash: hash})
+}
+
+func (f *fakeConnection) ConfigPush(id int64, config, signature []byte) {
+	if f.configPushFn != nil {
+		f.configPushFn(id, config, signature)
+	}
+}
+
+func (f *fakeConnection) ConfigPushResponse(id int64, err string) {
+	if f.configPushResponseFn != nil {
+		f.configPushResponseFn(id, err)
+	}
+}
+
 func (f *fakeConnection) addFileLocked(name string, flags uint32, ftype protocol.FileInfoType, data []byte, version protocol.Vector) {
 	blockSize := protocol.BlockSize(int64(len(data)))
 	blocks, _ := scanner.Blocks(context.TODO(), bytes.NewReader(data), blockSize, int64(len(data)), nil, true)
@@ -715,6 +766,43 @@ func TestClusterConfig(t *testing.T) {
 	}
 }
 
+func TestClusterConfigDeviceGroup(t *testing.T) {
+	m, _, fcfg, w := setupModelWithConnection()
+	defer m.Stop()
+	defer os.Remove(w.ConfigPath())
+
+	// device2 isn't sharing the folder, until it joins a group the folder
+	// is shared with.
+	waiter, _ := w.SetDevice(config.NewDeviceConfiguration(device2, "device2"))
+	waiter.Wait()
+	fcfg.Groups = []string{"team"}
+	waiter, _ = w.SetFolder(fcfg)
+	waiter.Wait()
+	if cm := m.generateClusterConfig(device2); len(cm.Folders) != 0 {
+		t.Fatalf("Unexpected folders %v", cm.Folders)
+	}
+
+	waiter, _ = w.SetDeviceGroup(config.DeviceGroup{Name: "team", Devices: []protocol.DeviceID{device2}})
+	waiter.Wait()
+	cm := m.generateClusterConfig(device2)
+	if len(cm.Folders) != 1 || cm.Folders[0].ID != fcfg.ID {
+		t.Fatalf("Expected the folder in the cluster config, got %v", cm.Folders)
+	}
+	shared := false
+	for _, dev := range cm.Folders[0].Devices {
+		shared = shared || dev.ID == device2
+	}
+	if !shared {
+		t.Error("device2 not among the devices of the folder")
+	}
+
+	waiter, _ = w.RemoveDeviceGroup("team")
+	waiter.Wait()
+	if cm := m.generateClusterConfig(device2); len(cm.Folders) != 0 {
+		t.Errorf("Unexpected folders after removing the group %v", cm.Folders)
+	}
+}
+
 func TestIntroducer(t *testing.T) {
 	var introducedByAnyone protocol.DeviceID
 
@@ -1458,6 +1546,139 @@ func TestAutoAcceptAlreadyShared(t *testing.T) {
 	}
 }
 
+func TestAutoAcceptPathTemplate(t *testing.T) {
+	dir := createTmpDir()
+	defer os.RemoveAll(dir)
+	tcfg := defaultAutoAcceptCfg.Copy()
+	tcfg.Version = config.CurrentVersion
+	tcfg.Devices[1].Name = "laptop"
+	tcfg.Devices[1].AutoAcceptPath = filepath.Join(dir, "{devicename}", "{folderlabel}")
+	tcfg.Devices[1].AutoAcceptTemplate = "backup"
+	tmpl := config.NewFolderTemplate("backup")
+	tmpl.Type = config.FolderTypeReceiveOnly
+	tmpl.Versioning = config.VersioningConfiguration{Type: "simple", Params: map[string]string{"keep": "3"}}
+	tcfg.FolderTemplates = []config.FolderTemplate{tmpl}
+	wcfg, m := newState(tcfg)
+	defer os.Remove(wcfg.ConfigPath())
+	defer m.Stop()
+
+	// The label is taken, so the ID goes in its place.
+	must(t, os.MkdirAll(filepath.Join(dir, "laptop", "Photos"), 0777))
+	m.ClusterConfig(device1, protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{
+				ID:    "abcd-1234",
+				Label: "Photos",
+			},
+		},
+	})
+
+	fcfg, ok := wcfg.Folder("abcd-1234")
+	if !ok || !fcfg.SharedWith(device1) {
+		t.Fatal("expected shared")
+	}
+	if expected := filepath.Join(dir, "laptop", "abcd-1234"); fcfg.Path != expected {
+		t.Errorf("Path is %v, expected %v", fcfg.Path, expected)
+	}
+	if fcfg.Type != config.FolderTypeReceiveOnly || fcfg.Versioning.Type != "simple" || fcfg.Template != "backup" {
+		t.Errorf("Template not applied: %+v", fcfg)
+	}
+}
+
+func TestAutoAcceptIntroduced(t *testing.T) {
+	id := srand.String(8)
+	defer os.RemoveAll(id)
+	tcfg := defaultAutoAcceptCfg.Copy()
+	tcfg.Devices[1].Introducer = true
+	tcfg.Devices[2].AutoAcceptFolders = false
+	tcfg.Devices[2].IntroducedBy = device1
+	wcfg, m := newState(tcfg)
+	defer os.Remove(wcfg.ConfigPath())
+	defer m.Stop()
+
+	cc := protocol.ClusterConfig{
+		Folders: []protocol.Folder{
+			{
+				ID:    id,
+				Label: id,
+			},
+		},
+	}
+
+	// Not without the introducer saying so.
+	m.ClusterConfig(device2, cc)
+	if _, ok := wcfg.Folder(id); ok {
+		t.Fatal("unexpected folder", id)
+	}
+
+	dev, _ := wcfg.Device(device1)
+	dev.AutoAcceptIntroduced = true
+	w, _ := wcfg.SetDevice(dev)
+	w.Wait()
+	m.ClusterConfig(device2, cc)
+	if fcfg, ok := wcfg.Folder(id); !ok || !fcfg.SharedWith(device2) || fcfg.SharedWith(device1) {
+		t.Error("expected shared with the introduced device only", id)
+	}
+}
+
+func TestAutoAcceptPolicy(t *testing.T) {
+	ids := []string{"photos-" + srand.String(8), "photos-" + srand.String(8), "music-" + srand.String(8)}
+	for _, id := range ids {
+		defer os.RemoveAll(id)
+	}
+	tcfg := defaultAutoAcceptCfg.Copy()
+	tcfg.Devices[1].AutoAcceptDevices = []protocol.DeviceID{device1}
+	tcfg.Devices[1].AutoAcceptFolderPattern = "photos-*"
+	tcfg.Devices[1].AutoAcceptReceiveOnly = true
+	tcfg.Devices[1].AutoAcceptMaxSize = config.Size{Value: 100, Unit: "B"}
+	tcfg.Devices[2].AutoAcceptFolders = false
+	tcfg.Devices[2].IntroducedBy = device1
+	tcfg.Devices[1].Introducer = true
+	tcfg.Devices[1].AutoAcceptIntroduced = true
+	wcfg, m := newState(tcfg)
+	defer os.Remove(wcfg.ConfigPath())
+	defer m.Stop()
+
+	offer := func(device protocol.DeviceID, id string) {
+		m.ClusterConfig(device, protocol.ClusterConfig{
+			Folders: []protocol.Folder{{ID: id, Label: id}},
+		})
+	}
+
+	// Not from introduced devices missing in the list.
+	offer(device2, ids[0])
+	if _, ok := wcfg.Folder(ids[0]); ok {
+		t.Fatal("unexpected folder from device2", ids[0])
+	}
+
+	// Not when the ID doesn't match.
+	offer(device1, ids[2])
+	if _, ok := wcfg.Folder(ids[2]); ok {
+		t.Fatal("unexpected folder not matching the pattern", ids[2])
+	}
+
+	offer(device1, ids[0])
+	fcfg, ok := wcfg.Folder(ids[0])
+	if !ok || !fcfg.SharedWith(device1) {
+		t.Fatal("expected shared", ids[0])
+	}
+	if fcfg.Type != config.FolderTypeReceiveOnly {
+		t.Error("expected receive only, got", fcfg.Type)
+	}
+
+	// Not once the folders shared with the device hold more than the
+	// limit.
+	m.Index(device1, ids[0], []protocol.FileInfo{{
+		Name:    "big",
+		Size:    1000,
+		Version: protocol.Vector{}.Update(device1.Short()),
+	}})
+	offer(device1, ids[1])
+	if _, ok := wcfg.Folder(ids[1]); ok {
+		t.Fatal("unexpected folder beyond the size limit", ids[1])
+	}
+}
+
 func TestAutoAcceptNameConflict(t *testing.T) {
 	testOs := &fatalOs{t}
 
@@ -1768,6 +1989,255 @@ func TestIgnores(t *testing.T) {
 	changeIgnores(t, m, []string{})
 }
 
+func TestTestIgnores(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	if err := m.SetIgnores("default", []string{"!/keep.tmp", "(?d)*.tmp", "/build"}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := m.TestIgnores("default", []string{"keep.tmp", "sub/other.tmp", "/build/out", "src", ".stfolder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []IgnoreTestResult{
+		{File: "keep.tmp", Pattern: "!/keep.tmp"},
+		{File: "sub/other.tmp", Pattern: "(?d)**/*.tmp", Ignored: true, Deletable: true},
+		{File: "/build/out", Pattern: "/build/**", Ignored: true},
+		{File: "src"},
+		{File: ".stfolder", Ignored: true, Internal: true},
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("Got %+v, expected %+v", res, expected)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("Got %+v, expected %+v", res[i], expected[i])
+		}
+	}
+
+	if _, err := m.TestIgnores("default", []string{"../outside"}); err == nil {
+		t.Error("Expected error for path outside the folder")
+	}
+	if _, err := m.TestIgnores("doesnotexist", []string{"file"}); err != errFolderMissing {
+		t.Error("Expected errFolderMissing, got", err)
+	}
+}
+
+func TestProtocolTrace(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	if _, err := m.ProtocolTrace(device1); err != errNoProtocolTrace {
+		t.Error("Expected errNoProtocolTrace before starting, got", err)
+	}
+	unknown := protocol.DeviceID{1, 2, 3}
+	if err := m.StartProtocolTrace(unknown); err != errDeviceUnknown {
+		t.Error("Expected errDeviceUnknown, got", err)
+	}
+
+	must(t, m.StartProtocolTrace(device1))
+	if _, err := m.ProtocolTrace(device1); err != nil {
+		t.Error("Unexpected error getting trace:", err)
+	}
+	must(t, m.StopProtocolTrace(device1))
+	if _, err := m.ProtocolTrace(device1); err != nil {
+		t.Error("Trace should remain available after stopping, got", err)
+	}
+	if err := m.StopProtocolTrace(device2); err != errNoProtocolTrace {
+		t.Error("Expected errNoProtocolTrace, got", err)
+	}
+}
+
+func TestIndexFilter(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	for i := range fcfg.Devices {
+		if fcfg.Devices[i].DeviceID == device1 {
+			fcfg.Devices[i].IndexFilter = []string{"/secret", "*.key"}
+		}
+	}
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	ffs := fcfg.Filesystem()
+	must(t, ffs.MkdirAll("secret", 0755))
+	for _, name := range []string{"public", "secret/file", "id.key"} {
+		fd, err := ffs.Create(name)
+		must(t, err)
+		_, err = fd.Write([]byte("data"))
+		must(t, err)
+		fd.Close()
+	}
+	m.ScanFolders()
+
+	sent := make(map[string]struct{})
+	fc := &fakeConnection{id: device1, model: m}
+	fc.indexFn = func(folder string, fs []protocol.FileInfo) {
+		for _, f := range fs {
+			sent[f.Name] = struct{}{}
+		}
+	}
+	m.fmut.RLock()
+	fset := m.folderFiles["default"]
+	filter := m.folderIndexFilters["default"][device1]
+	m.fmut.RUnlock()
+	if _, err := sendIndexTo(0, fc, "default", fset, false, filter, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := sent["public"]; !ok {
+		t.Error("Unfiltered file wasn't announced")
+	}
+	for _, name := range []string{"secret", filepath.Join("secret", "file"), "id.key"} {
+		if _, ok := sent[name]; ok {
+			t.Errorf("Filtered file %v was announced", name)
+		}
+	}
+
+	if _, err := m.Request(device1, "default", "public", 4, 0, nil, 0, false); err != nil {
+		t.Error("Unexpected error requesting unfiltered file:", err)
+	}
+	if _, err := m.Request(device1, "default", "id.key", 4, 0, nil, 0, false); err != protocol.ErrNoSuchFile {
+		t.Error("Expected ErrNoSuchFile requesting filtered file, got", err)
+	}
+}
+
+func TestSharedIgnores(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.ShareIgnores = true
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	patterns := []string{"/build", "(?d)*.tmp"}
+	if err := m.SetIgnores("default", patterns); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := m.generateClusterConfig(device1)
+	if len(cm.Folders) != 1 || !cm.Folders[0].SharesIgnores {
+		t.Fatal("Expected the folder to share its ignore patterns")
+	}
+	if !equalLines(cm.Folders[0].IgnorePatterns, patterns) {
+		t.Fatalf("Announced patterns %v, expected %v", cm.Folders[0].IgnorePatterns, patterns)
+	}
+
+	// The other side opted in to use the patterns of the first device.
+	w2, fcfg2 := tmpDefaultWrapper()
+	fcfg2.IgnoresFrom = device1
+	w2.SetFolder(fcfg2)
+	m2 := setupModel(w2)
+	defer func() {
+		m2.Stop()
+		os.RemoveAll(fcfg2.Filesystem().URI())
+		os.Remove(w2.ConfigPath())
+	}()
+
+	m2.AddConnection(&fakeConnection{id: device1, model: m2}, protocol.HelloResult{})
+	m2.ClusterConfig(device1, cm)
+
+	timeout := time.Now().Add(2 * time.Second)
+	for {
+		lines, _, err := m2.GetIgnores("default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equalLines(lines, patterns) {
+			break
+		}
+		if time.Now().After(timeout) {
+			t.Fatalf("Got patterns %v, expected %v", lines, patterns)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSharedMetadata(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.Label = "Photos"
+	fcfg.FolderDescription = "All of them"
+	fcfg.Metadata = []config.FolderMetadata{{Key: "owner", Value: "alice"}, {Key: "host", Value: "nas", Local: true}}
+	fcfg.ShareMetadata = true
+	fcfg.MetadataChanged = time.Unix(2000, 0)
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	cm := m.generateClusterConfig(device1)
+	if len(cm.Folders) != 1 || !cm.Folders[0].SharesMetadata {
+		t.Fatal("Expected the folder to share its metadata")
+	}
+	if cm.Folders[0].MetadataChanged != fcfg.MetadataChanged.UnixNano() {
+		t.Errorf("Announced change time %d, expected %d", cm.Folders[0].MetadataChanged, fcfg.MetadataChanged.UnixNano())
+	}
+
+	// The other side keeps its own description and host.
+	w2, fcfg2 := tmpDefaultWrapper()
+	fcfg2.Label = "Pictures"
+	fcfg2.FolderDescription = "Mine"
+	fcfg2.LocalDescription = true
+	fcfg2.Metadata = []config.FolderMetadata{{Key: "host", Value: "laptop"}, {Key: "stale", Value: "x"}}
+	fcfg2.ShareMetadata = true
+	fcfg2.MetadataChanged = time.Unix(1000, 0)
+	w2.SetFolder(fcfg2)
+	m2 := setupModel(w2)
+	defer func() {
+		m2.Stop()
+		os.RemoveAll(fcfg2.Filesystem().URI())
+		os.Remove(w2.ConfigPath())
+	}()
+
+	m2.AddConnection(&fakeConnection{id: device1, model: m2}, protocol.HelloResult{})
+	m2.ClusterConfig(device1, cm)
+
+	got, _ := w2.Folder("default")
+	if got.Label != "Photos" {
+		t.Errorf("Got label %q, expected the shared one", got.Label)
+	}
+	if got.FolderDescription != "Mine" {
+		t.Errorf("Got description %q, expected the local one", got.FolderDescription)
+	}
+	expected := []config.FolderMetadata{{Key: "host", Value: "laptop"}, {Key: "owner", Value: "alice"}}
+	if !reflect.DeepEqual(got.Metadata, expected) {
+		t.Errorf("Got metadata %v, expected %v", got.Metadata, expected)
+	}
+	if !got.MetadataChanged.Equal(fcfg.MetadataChanged) {
+		t.Errorf("Got change time %v, expected %v", got.MetadataChanged, fcfg.MetadataChanged)
+	}
+
+	// Older metadata isn't taken on.
+	cm.Folders[0].Label = "Old"
+	cm.Folders[0].MetadataChanged = time.Unix(1500, 0).UnixNano()
+	m2.useSharedMetadata(device1, cm.Folders[0])
+	if got, _ := w2.Folder("default"); got.Label != "Photos" {
+		t.Errorf("Got label %q from older metadata", got.Label)
+	}
+}
+
 func waitForState(t *testing.T, m *model, folder, status string) {
 	t.Helper()
 	timeout := time.Now().Add(2 * time.Second)
@@ -3114,6 +3584,62 @@ func TestIssue4475(t *testing.T) {
 	}
 }
 
+func TestGetFolderVersionAtCurrent(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.Versioning = config.VersioningConfiguration{Type: "simple", Params: map[string]string{"keep": "5"}}
+	waiter, _ := w.SetFolder(fcfg)
+	waiter.Wait()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	ffs := fcfg.Filesystem()
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := ioutil.WriteFile(filepath.Join(ffs.URI(), "file"), []byte("current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ffs.Chtimes("file", modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	// An older version, archived long after it was replaced.
+	versions := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Join(ffs.URI(), ".stversions"))
+	if err := versions.MkdirAll(".", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if fd, err := versions.Create("file~" + modTime.Add(-time.Minute).Format(versioner.TimeFormat)); err != nil {
+		t.Fatal(err)
+	} else {
+		fd.Write([]byte("archived"))
+		fd.Close()
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	version, fd, err := m.GetFolderVersionAt(fcfg.ID, "file", modTime.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(fd)
+	fd.Close()
+	if string(data) != "current" || !version.VersionTime.Equal(modTime) {
+		t.Errorf("Got %q from %v, expected the current file", data, version.VersionTime)
+	}
+
+	version, fd, err = m.GetFolderVersionAt(fcfg.ID, "file", modTime.Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadAll(fd)
+	fd.Close()
+	if string(data) != "archived" {
+		t.Errorf("Got %q from %v, expected the archived version", data, version.VersionTime)
+	}
+}
+
 func TestVersionRestore(t *testing.T) {
 	// We create a bunch of files which we restore
 	// In each file, we write the filename as the content
@@ -3617,3 +4143,132 @@ func TestSanitizePath(t *testing.T) {
 		}
 	}
 }
+
+func TestNewFolderPathsStayInDefaultPath(t *testing.T) {
+	defaultPath := filepath.FromSlash("/home/user/Sync")
+	device := config.DeviceConfiguration{DeviceID: device1, Name: "remote"}
+
+	cases := []struct {
+		label, id string
+		expected  []string
+	}{
+		{"Photos", "abcd-1234", []string{"Photos", "abcd-1234"}},
+		{"../../etc", "abcd-1234", []string{".. .. etc", "abcd-1234"}},
+		{"..", "abcd-1234", []string{"abcd-1234"}},
+		{"", "..", nil},
+	}
+	for _, tc := range cases {
+		var expected []string
+		for _, name := range tc.expected {
+			expected = append(expected, filepath.Join(defaultPath, name))
+		}
+		paths := NewFolderPaths("", defaultPath, device, protocol.Folder{ID: tc.id, Label: tc.label})
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Paths for label %q, ID %q: %v, expected %v", tc.label, tc.id, paths, expected)
+		}
+	}
+
+	// Only the given names are restricted, not absolute templates.
+	paths := NewFolderPaths(filepath.FromSlash("/srv/{folderid}"), defaultPath, device, protocol.Folder{ID: "abcd-1234"})
+	if expected := []string{filepath.FromSlash("/srv/abcd-1234")}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Paths %v, expected %v", paths, expected)
+	}
+}
+
+func TestIgnorePatternSetChanged(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	cfg := w.RawCopy()
+	cfg.IgnorePatternSets = []config.IgnorePatternSet{{Name: "junk", Patterns: []string{"a"}}}
+	waiter, err := w.Replace(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+
+	ffs := fcfg.Filesystem()
+	if err := ignore.WriteIgnores(ffs, ".stignore", []string{"#include-config:junk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(ffs.URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	m.fmut.RLock()
+	ignores := m.folderIgnores[fcfg.ID]
+	m.fmut.RUnlock()
+	if !ignores.Match("a").IsIgnored() || ignores.Match("b").IsIgnored() {
+		t.Fatal("pattern set not applied")
+	}
+
+	cfg = w.RawCopy()
+	cfg.IgnorePatternSets[0].Patterns = []string{"b"}
+	waiter, err = w.Replace(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+
+	// The folder gets rescanned, reloading the ignores.
+	for start := time.Now(); !ignores.Match("b").IsIgnored(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("changed pattern set not applied")
+		}
+	}
+	if ignores.Match("a").IsIgnored() {
+		t.Error("old pattern set still applied")
+	}
+}
+
+func TestScanIgnorePermBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no executable bits on Windows")
+	}
+
+	w, fcfg := tmpDefaultWrapper()
+	fcfg.IgnorePermBits = "0111"
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	ffs := fcfg.Filesystem()
+	fd, err := ffs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if err := ffs.Chmod("file", 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := m.CurrentFolderFile(fcfg.ID, "file")
+
+	if err := ffs.Chmod("file", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _ := m.CurrentFolderFile(fcfg.ID, "file"); cur.Sequence != before.Sequence {
+		t.Error("change of ignored permission bits was announced")
+	}
+
+	if err := ffs.Chmod("file", 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _ := m.CurrentFolderFile(fcfg.ID, "file"); cur.Sequence == before.Sequence || cur.Permissions != 0600 {
+		t.Errorf("permission change not announced: %v", cur)
+	}
+}
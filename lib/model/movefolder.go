@@ -0,0 +1,287 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/sha256"
+)
+
+var (
+	errMoveSamePath   = errors.New("new path is the folder path, or inside or above it")
+	errMoveNotEmpty   = errors.New("new path exists and isn't an empty directory")
+	errMoveNotAbs     = errors.New("new path must be absolute")
+	errMoveCorruption = errors.New("copy differs from the original")
+)
+
+// A MoveReport describes the outcome of moving a folder to a new path.
+type MoveReport struct {
+	Folder string `json:"folder"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	// Renamed is true when the data was moved by renaming the folder
+	// directory, rather than by copying.
+	Renamed bool  `json:"renamed"`
+	Files   int   `json:"files"`
+	Bytes   int64 `json:"bytes"`
+	// RemoveError is set when the data was moved, but removing it from the
+	// old path failed.
+	RemoveError string        `json:"removeError,omitempty"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// MoveFolder moves the data of the folder to the new path and points the
+// folder at it. The folder is paused meanwhile, and the database kept as
+// it is, so the folder resumes in the state it was in. When the data can't
+// be renamed into place it's copied, each file being verified against the
+// original, and the original removed only once all of it was copied. On
+// failure the folder is left at its old path.
+func (m *model) MoveFolder(folder, path string) (MoveReport, error) {
+	started := time.Now()
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return MoveReport{}, errFolderMissing
+	}
+	report := MoveReport{Folder: cfg.ID, From: cfg.Path, To: path}
+
+	src := cfg.Filesystem()
+	dst, err := moveTarget(cfg, path)
+	if err != nil {
+		return report, err
+	}
+
+	ctx, done := m.folderJobs.start(context.Background(), folder, FolderJobMove)
+	defer done()
+
+	wasPaused := cfg.Paused
+	if !wasPaused {
+		cfg.Paused = true
+		if err := m.setFolderConfig(cfg); err != nil {
+			return report, err
+		}
+	}
+	resume := func(path string) error {
+		cfg, ok := m.cfg.Folder(folder)
+		if !ok {
+			return errFolderMissing
+		}
+		cfg.Path = path
+		cfg.Paused = wasPaused
+		return m.setFolderConfig(cfg)
+	}
+
+	l.Infof("Moving folder %v from %s to %s", cfg.Description(), src.URI(), dst.URI())
+	if err := moveFolderData(ctx, cfg.FilesystemType, src, dst, &report); err != nil {
+		l.Warnf("Moving folder %v: %v", cfg.Description(), err)
+		if rerr := resume(report.From); rerr != nil {
+			l.Warnf("Moving folder %v: resuming at old path: %v", cfg.Description(), rerr)
+		}
+		return report, err
+	}
+
+	if err := resume(path); err != nil {
+		return report, err
+	}
+	if err := m.cfg.Save(); err != nil {
+		l.Warnln("Failed to save config", err)
+	}
+	report.Duration = time.Since(started)
+	l.Infof("Moved folder %v to %s", cfg.Description(), dst.URI())
+	return report, nil
+}
+
+func (m *model) setFolderConfig(cfg config.FolderConfiguration) error {
+	w, err := m.cfg.SetFolder(cfg)
+	if err != nil {
+		return err
+	}
+	w.Wait()
+	return nil
+}
+
+// moveTarget returns the filesystem at the new path of the folder, which
+// must be absolute, outside the folder, and not exist or be empty.
+func moveTarget(cfg config.FolderConfiguration, path string) (fs.Filesystem, error) {
+	expanded, err := fs.ExpandTilde(path)
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(expanded) {
+		return nil, errMoveNotAbs
+	}
+	dst := fs.NewFilesystem(cfg.FilesystemType, expanded)
+
+	from, to := cfg.Filesystem().URI(), dst.URI()
+	sep := string(filepath.Separator)
+	if from == to || strings.HasPrefix(to, from+sep) || strings.HasPrefix(from, to+sep) {
+		return nil, errMoveSamePath
+	}
+
+	if info, err := dst.Lstat("."); err == nil {
+		if !info.IsDir() {
+			return nil, errMoveNotEmpty
+		}
+		if names, err := dst.DirNames("."); err != nil {
+			return nil, err
+		} else if len(names) > 0 {
+			return nil, errMoveNotEmpty
+		}
+	} else if !fs.IsNotExist(err) {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// moveFolderData renames the folder directory to the new path if it can,
+// and copies the data otherwise, removing the original afterwards.
+func moveFolderData(ctx context.Context, fsType fs.FilesystemType, src, dst fs.Filesystem, report *MoveReport) error {
+	if fsType == fs.FilesystemTypeBasic {
+		if err := os.MkdirAll(filepath.Dir(dst.URI()), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(src.URI(), dst.URI()); err == nil {
+			report.Renamed = true
+			return nil
+		}
+	}
+
+	_, err := dst.Lstat(".")
+	existed := err == nil
+	if err := copyFolderData(ctx, src, dst, report); err != nil {
+		// Remove what we copied, and nothing else.
+		if existed {
+			names, _ := dst.DirNames(".")
+			for _, name := range names {
+				dst.RemoveAll(name)
+			}
+		} else {
+			dst.RemoveAll(".")
+		}
+		return err
+	}
+
+	if err := src.RemoveAll("."); err != nil {
+		report.RemoveError = err.Error()
+	}
+	return nil
+}
+
+// copyFolderData copies the files, directories and symlinks to the other
+// filesystem, keeping permissions and modification times, so that the
+// copy scans the same as the original. Each file is read back and compared
+// to the original.
+func copyFolderData(ctx context.Context, src, dst fs.Filesystem, report *MoveReport) error {
+	type dirMode struct {
+		name string
+		mode fs.FileMode
+	}
+	var dirs []dirMode
+
+	err := src.Walk(".", func(name string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return errFolderJobCancelled
+		}
+
+		switch {
+		case info.IsDir():
+			// Directories are writeable until everything was copied.
+			if err := dst.MkdirAll(name, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirMode{name, info.Mode() & fs.ModePerm})
+		case info.IsSymlink():
+			target, err := src.ReadSymlink(name)
+			if err != nil {
+				return err
+			}
+			if err := dst.CreateSymlink(target, name); err != nil {
+				return err
+			}
+		case info.IsRegular():
+			if err := copyVerified(src, dst, name, info); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			report.Files++
+			report.Bytes += info.Size()
+		default:
+			l.Debugln("Not moving", name, "of type", info.Mode())
+			return nil
+		}
+
+		if runtime.GOOS != "windows" {
+			// Best effort, it's only possible with sufficient privileges.
+			dst.Lchown(name, info.Owner(), info.Group())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := dst.Chmod(dirs[i].name, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyVerified copies the file, then reads the copy back to compare it to
+// what was read from the original.
+func copyVerified(src, dst fs.Filesystem, name string, info fs.FileInfo) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := dst.OpenFile(name, fs.OptReadWrite|fs.OptCreate|fs.OptExclusive, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	srcHash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, srcHash), in); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dstHash := sha256.New()
+	if _, err := io.Copy(dstHash, out); err != nil {
+		return err
+	}
+	if !bytes.Equal(srcHash.Sum(nil), dstHash.Sum(nil)) {
+		return errMoveCorruption
+	}
+
+	if err := dst.Chmod(name, info.Mode()&fs.ModePerm); err != nil {
+		return err
+	}
+	return dst.Chtimes(name, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestMoveFolder(t *testing.T) {
+	m, _, fcfg, w := setupModelWithConnection()
+	dir := createTmpDir()
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.RemoveAll(dir)
+		os.Remove(w.ConfigPath())
+	}()
+
+	ffs := fcfg.Filesystem()
+	must(t, ffs.MkdirAll("dir", 0755))
+	must(t, ioutil.WriteFile(filepath.Join(ffs.URI(), "dir", "file"), []byte("data"), 0644))
+	must(t, m.ScanFolder("default"))
+	seq, _ := m.CurrentSequence("default")
+
+	// The target must be absolute, outside the folder and empty.
+	must(t, ioutil.WriteFile(filepath.Join(dir, "existing"), nil, 0644))
+	for _, path := range []string{"relative", ffs.URI(), filepath.Join(ffs.URI(), "dir"), dir} {
+		if _, err := m.MoveFolder("default", path); err == nil {
+			t.Errorf("Expected moving to %v to fail", path)
+		}
+	}
+
+	to := filepath.Join(dir, "moved")
+	report, err := m.MoveFolder("default", to)
+	must(t, err)
+	if !report.Renamed || report.From != fcfg.Path || report.To != to {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	cfg, _ := w.Folder("default")
+	if cfg.Path != to || cfg.Paused {
+		t.Errorf("Unexpected folder config after move, path %v, paused %v", cfg.Path, cfg.Paused)
+	}
+	if _, err := os.Lstat(fcfg.Path); !os.IsNotExist(err) {
+		t.Error("Old path still exists:", err)
+	}
+	if err := equalContents(filepath.Join(to, "dir", "file"), []byte("data")); err != nil {
+		t.Error("Moved file:", err)
+	}
+
+	// The moved folder scans without changes.
+	must(t, m.ScanFolder("default"))
+	if newSeq, _ := m.CurrentSequence("default"); newSeq != seq {
+		t.Errorf("Sequence changed from %v to %v after the move", seq, newSeq)
+	}
+
+	if _, err := m.MoveFolder("missing", to); err != errFolderMissing {
+		t.Error("Expected missing folder, got", err)
+	}
+}
+
+func TestCopyFolderData(t *testing.T) {
+	srcDir, dstDir := createTmpDir(), createTmpDir()
+	defer os.RemoveAll(srcDir)
+	defer os.RemoveAll(dstDir)
+	src := fs.NewFilesystem(fs.FilesystemTypeBasic, srcDir)
+	dst := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Join(dstDir, "copy"))
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	must(t, src.MkdirAll("dir", 0755))
+	must(t, ioutil.WriteFile(filepath.Join(srcDir, "dir", "file"), []byte("data"), 0640))
+	must(t, src.Chtimes(filepath.Join("dir", "file"), mtime, mtime))
+	must(t, src.Chmod("dir", 0500))
+	defer src.Chmod("dir", 0755)
+	if runtime.GOOS != "windows" {
+		must(t, src.CreateSymlink("dir", "link"))
+	}
+
+	var report MoveReport
+	must(t, copyFolderData(context.Background(), src, dst, &report))
+	if report.Files != 1 || report.Bytes != 4 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	defer dst.Chmod("dir", 0755)
+
+	info, err := dst.Lstat(filepath.Join("dir", "file"))
+	must(t, err)
+	if info.Mode()&fs.ModePerm != 0640 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Unexpected copied file mode %v, mtime %v", info.Mode(), info.ModTime())
+	}
+	if err := equalContents(filepath.Join(dst.URI(), "dir", "file"), []byte("data")); err != nil {
+		t.Error("Copied file:", err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err = dst.Lstat("dir")
+		must(t, err)
+		if info.Mode()&fs.ModePerm != 0500 {
+			t.Errorf("Unexpected copied directory mode %v", info.Mode())
+		}
+		if target, err := dst.ReadSymlink("link"); err != nil || target != "dir" {
+			t.Errorf("Unexpected symlink %v, %v", target, err)
+		}
+	}
+
+	// A cancelled copy stops.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := copyFolderData(ctx, src, fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Join(dstDir, "other")), &report); err != errFolderJobCancelled {
+		t.Error("Expected cancellation, got", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How often the complete blocks of a file being pulled are recorded in the
+// database. Blocks completed since are pulled again after a restart.
+const partialSaveInterval = 10 * time.Second
+
+// partialBlocks returns the indexes of the blocks of the temporary file
+// that were recorded as complete while pulling the same version of the file
+// before. A record that doesn't match the file or the temporary file is
+// dropped.
+func (f *sendReceiveFolder) partialBlocks(file protocol.FileInfo, tempName string) ([]int32, bool) {
+	partial, ok := f.fset.PartialFile(file.Name)
+	if !ok {
+		return nil, false
+	}
+
+	indexes, ok := f.validPartialBlocks(partial, file, tempName)
+	if !ok {
+		l.Debugf("%v dropping stale record of complete blocks of %s", f, file.Name)
+		f.fset.DropPartialFile(file.Name)
+		return nil, false
+	}
+	return indexes, true
+}
+
+func (f *sendReceiveFolder) validPartialBlocks(partial db.PartialFile, file protocol.FileInfo, tempName string) ([]int32, bool) {
+	if !partial.Version.Equal(file.Version) || partial.BlockSize != file.BlockSize() || len(partial.Blocks) == 0 {
+		return nil, false
+	}
+	info, err := f.tempFilesystem().Lstat(tempName)
+	if err != nil || !info.IsRegular() {
+		return nil, false
+	}
+
+	seen := make(map[int32]struct{}, len(partial.Blocks))
+	indexes := make([]int32, 0, len(partial.Blocks))
+	for _, index := range partial.Blocks {
+		if index < 0 || int(index) >= len(file.Blocks) {
+			return nil, false
+		}
+		if block := file.Blocks[index]; block.Offset+int64(block.Size) > info.Size() {
+			// The temporary file was truncated since.
+			return nil, false
+		}
+		if _, ok := seen[index]; ok {
+			continue
+		}
+		seen[index] = struct{}{}
+		indexes = append(indexes, index)
+	}
+	return indexes, true
+}
+
+// savePartialLocked records the blocks available in the temporary file in
+// the database, after syncing it to disk so that they are there after a
+// crash as well.
+func (s *sharedPullerState) savePartialLocked() {
+	s.partialSaved = time.Now()
+	if s.fset == nil || s.fd == nil || len(s.available) == 0 {
+		return
+	}
+	if err := s.fd.Sync(); err != nil {
+		l.Debugln("sharedPullerState", s.folder, s.file.Name, "not recording complete blocks:", err)
+		return
+	}
+	s.fset.SetPartialFile(s.file.Name, db.PartialFile{
+		Version:   s.file.Version,
+		BlockSize: s.file.BlockSize(),
+		Blocks:    append([]int32(nil), s.available...),
+	})
+	s.partialRecorded = true
+}
+
+// dropPartialLocked removes the record of the available blocks, if there
+// may be one.
+func (s *sharedPullerState) dropPartialLocked() {
+	if s.fset == nil || !s.partialRecorded {
+		return
+	}
+	s.fset.DropPartialFile(s.file.Name)
+	s.partialRecorded = false
+}
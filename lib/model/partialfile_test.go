@@ -0,0 +1,125 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+func TestHandleFileWithPartial(t *testing.T) {
+	existingFile := setupFile("file", []int{0, 2, 0, 0, 5, 0, 0, 8})
+	requiredFile := existingFile
+	requiredFile.Blocks = blocks[1:]
+	requiredFile.Version = protocol.Vector{}.Update(device1.Short())
+
+	m, f := setupSendReceiveFolder(existingFile)
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.Remove(f.Filesystem().URI())
+	}()
+
+	if _, err := prepareTmpFile(f.Filesystem()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The recorded blocks are taken as they are, rather than those found
+	// by hashing the temporary file.
+	f.fset.SetPartialFile("file", db.PartialFile{
+		Version:   requiredFile.Version,
+		BlockSize: requiredFile.BlockSize(),
+		Blocks:    []int32{0, 3, 3},
+	})
+
+	copyChan := make(chan copyBlocksState, 1)
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+
+	f.handleFile(requiredFile, copyChan, dbUpdateChan)
+	toCopy := <-copyChan
+	if len(toCopy.blocks) != 6 {
+		t.Errorf("Unexpected count of copy blocks: %d != 6", len(toCopy.blocks))
+	}
+	for _, block := range toCopy.blocks {
+		if block.Offset == requiredFile.Blocks[0].Offset || block.Offset == requiredFile.Blocks[3].Offset {
+			t.Errorf("Recorded block %v copied", block)
+		}
+	}
+	if !toCopy.sharedPullerState.partialRecorded {
+		t.Error("Record not noted on the puller state")
+	}
+
+	// A record of another version is dropped, and the temporary file
+	// hashed instead.
+	f.fset.SetPartialFile("file", db.PartialFile{
+		Version:   protocol.Vector{}.Update(device2.Short()),
+		BlockSize: requiredFile.BlockSize(),
+		Blocks:    []int32{0, 3},
+	})
+
+	f.handleFile(requiredFile, copyChan, dbUpdateChan)
+	toCopy = <-copyChan
+	if len(toCopy.blocks) != 4 {
+		t.Errorf("Unexpected count of copy blocks: %d != 4", len(toCopy.blocks))
+	}
+	if _, ok := f.fset.PartialFile("file"); ok {
+		t.Error("Stale record not dropped")
+	}
+}
+
+func TestSharedPullerStatePartial(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.Remove(f.Filesystem().URI())
+	}()
+
+	file := setupFile("file", []int{1, 2, 3})
+	file.Version = protocol.Vector{}.Update(device1.Short())
+	tempName := fs.TempName("file")
+	defer f.fs.Remove(tempName)
+
+	s := &sharedPullerState{
+		file:         file,
+		fs:           f.fs,
+		tempName:     tempName,
+		realName:     file.Name,
+		copyNeeded:   3,
+		mut:          sync.NewRWMutex(),
+		fset:         f.fset,
+		partialSaved: time.Now(),
+	}
+	if _, err := s.tempFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not recorded before the interval passed.
+	s.copyDone(file.Blocks[0])
+	if _, ok := f.fset.PartialFile("file"); ok {
+		t.Fatal("Blocks recorded too early")
+	}
+
+	s.partialSaved = time.Time{}
+	s.copyDone(file.Blocks[2])
+	partial, ok := f.fset.PartialFile("file")
+	if !ok || len(partial.Blocks) != 2 || partial.Blocks[0] != 0 || partial.Blocks[1] != 2 || !partial.Version.Equal(file.Version) {
+		t.Fatalf("Unexpected record %+v, %v", partial, ok)
+	}
+
+	s.copyDone(file.Blocks[1])
+	if closed, err := s.finalClose(); !closed || err != nil {
+		t.Fatal("Unexpected close result", closed, err)
+	}
+	if _, ok := f.fset.PartialFile("file"); ok {
+		t.Error("Record not dropped when done")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The number of most recent messages kept by a protocol trace.
+const protocolTraceEntries = 1000
+
+// StartProtocolTrace starts recording the messages exchanged with the
+// device, on the current connection and later ones. Any previous trace of
+// the device is discarded.
+func (m *model) StartProtocolTrace(device protocol.DeviceID) error {
+	if _, ok := m.cfg.Device(device); !ok {
+		return errDeviceUnknown
+	}
+
+	tracer := protocol.NewTracer(protocolTraceEntries)
+
+	m.pmut.Lock()
+	defer m.pmut.Unlock()
+	if old, ok := m.protocolTracers[device]; ok {
+		old.Stop()
+	}
+	m.protocolTracers[device] = tracer
+	if conn, ok := m.conn[device]; ok {
+		conn.SetTracer(tracer)
+	}
+	l.Infoln("Started protocol trace for device", device)
+	return nil
+}
+
+// StopProtocolTrace stops recording the messages exchanged with the device.
+// The trace remains available until the next one is started.
+func (m *model) StopProtocolTrace(device protocol.DeviceID) error {
+	m.pmut.Lock()
+	defer m.pmut.Unlock()
+
+	tracer, ok := m.protocolTracers[device]
+	if !ok {
+		return errNoProtocolTrace
+	}
+	tracer.Stop()
+	if conn, ok := m.conn[device]; ok {
+		conn.SetTracer(nil)
+	}
+	l.Infoln("Stopped protocol trace for device", device)
+	return nil
+}
+
+// ProtocolTrace returns the recorded messages exchanged with the device,
+// oldest first.
+func (m *model) ProtocolTrace(device protocol.DeviceID) ([]protocol.TraceEntry, error) {
+	m.pmut.RLock()
+	tracer, ok := m.protocolTracers[device]
+	m.pmut.RUnlock()
+	if !ok {
+		return nil, errNoProtocolTrace
+	}
+	return tracer.Entries(), nil
+}
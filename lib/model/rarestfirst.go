@@ -0,0 +1,48 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// tempBlockHolders returns, by block index, how many connected devices have
+// the blocks of the file in temporary files, i.e. are downloading it as
+// well. The devices which have the whole file have all blocks, and aren't
+// counted.
+func (m *model) tempBlockHolders(folder string, file protocol.FileInfo) map[int32]int {
+	m.pmut.RLock()
+	defer m.pmut.RUnlock()
+
+	var holders map[int32]int
+	for _, state := range m.deviceDownloads {
+		for _, index := range state.BlockIndexes(folder, file.Name, file.Version) {
+			if holders == nil {
+				holders = make(map[int32]int)
+			}
+			holders[index]++
+		}
+	}
+	return holders
+}
+
+// orderRarestFirst sorts the blocks so that those held by the fewest
+// devices come first, keeping the order of blocks held by as many. When
+// many devices download a new file at the same time, they then tend to
+// pull different blocks, which they can serve each other from their
+// temporary files, rather than all pulling the same blocks from the
+// devices that have the whole file.
+func orderRarestFirst(blocks []protocol.BlockInfo, blockSize int, holders map[int32]int) {
+	if len(holders) == 0 {
+		return
+	}
+	sort.SliceStable(blocks, func(a, b int) bool {
+		return holders[int32(blocks[a].Offset/int64(blockSize))] < holders[int32(blocks[b].Offset/int64(blockSize))]
+	})
+}
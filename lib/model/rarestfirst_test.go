@@ -0,0 +1,63 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestOrderRarestFirst(t *testing.T) {
+	const blockSize = 128 << 10
+	blocks := make([]protocol.BlockInfo, 5)
+	for i := range blocks {
+		blocks[i].Offset = int64(i) * blockSize
+	}
+
+	// Blocks 1 and 3 are on two devices, block 4 on one.
+	orderRarestFirst(blocks, blockSize, map[int32]int{1: 2, 3: 2, 4: 1})
+
+	expected := []int64{0, 2, 4, 1, 3}
+	for i, block := range blocks {
+		if block.Offset/blockSize != expected[i] {
+			t.Errorf("Block %d is %d, expected %d", i, block.Offset/blockSize, expected[i])
+		}
+	}
+}
+
+func TestTempBlockHolders(t *testing.T) {
+	m, _, fcfg, w := setupModelWithConnection()
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	file := protocol.FileInfo{
+		Name:    "file",
+		Version: protocol.Vector{}.Update(device1.Short()),
+	}
+	m.deviceDownloads[device1].Update(fcfg.ID, []protocol.FileDownloadProgressUpdate{{
+		UpdateType:   protocol.UpdateTypeAppend,
+		Name:         file.Name,
+		Version:      file.Version,
+		BlockIndexes: []int32{0, 2},
+	}})
+
+	holders := m.tempBlockHolders(fcfg.ID, file)
+	if len(holders) != 2 || holders[0] != 1 || holders[2] != 1 {
+		t.Errorf("Unexpected holders %v", holders)
+	}
+
+	// Another version is another file.
+	file.Version = protocol.Vector{}.Update(device2.Short())
+	if holders := m.tempBlockHolders(fcfg.ID, file); len(holders) != 0 {
+		t.Errorf("Unexpected holders of other version %v", holders)
+	}
+}
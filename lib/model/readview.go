@@ -0,0 +1,256 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A ReadView reads the indexes of one or more folders as they were at the
+// time it was created, so that sizes, needs and completion read through it
+// add up even while the indexes are being updated. Folders that weren't
+// running then read as empty, as through the model. The zero ReadView
+// contains no folders. A ReadView must be released when done.
+type ReadView struct {
+	model *model
+	snaps map[string]*db.ReadSnapshot
+	cfgs  map[string]config.FolderConfiguration
+}
+
+// ReadView returns a view of the current state of the given folders.
+func (m *model) ReadView(folders ...string) *ReadView {
+	m.fmut.RLock()
+	defer m.fmut.RUnlock()
+
+	v := &ReadView{
+		model: m,
+		snaps: make(map[string]*db.ReadSnapshot, len(folders)),
+		cfgs:  make(map[string]config.FolderConfiguration, len(folders)),
+	}
+	for _, folder := range folders {
+		if _, ok := v.snaps[folder]; ok {
+			continue
+		}
+		if fset, ok := m.folderFiles[folder]; ok {
+			v.snaps[folder] = fset.ReadSnapshot()
+			v.cfgs[folder] = m.folderCfgs[folder]
+		}
+	}
+	return v
+}
+
+// Release releases the view, which must not be used afterwards.
+func (v *ReadView) Release() {
+	for _, snap := range v.snaps {
+		snap.Release()
+	}
+}
+
+// Completion returns the completion status, in percent, for the given device
+// and folder.
+func (v *ReadView) Completion(device protocol.DeviceID, folder string) FolderCompletion {
+	snap, ok := v.snaps[folder]
+	if !ok {
+		return FolderCompletion{} // Folder doesn't exist, so we hardly have any of it
+	}
+
+	tot := snap.GlobalSize().Bytes
+	if tot == 0 {
+		// Folder is empty, so we have all of it
+		return FolderCompletion{
+			CompletionPct: 100,
+		}
+	}
+
+	v.model.pmut.RLock()
+	counts := v.model.deviceDownloads[device].GetBlockCounts(folder)
+	v.model.pmut.RUnlock()
+
+	var need, items, fileNeed, downloaded, deletes int64
+	snap.WithNeedTruncated(device, func(f db.FileIntf) bool {
+		ft := f.(db.FileInfoTruncated)
+
+		// If the file is deleted, we account it only in the deleted column.
+		if ft.Deleted {
+			deletes++
+			return true
+		}
+
+		// This might might be more than it really is, because some blocks can be of a smaller size.
+		downloaded = int64(counts[ft.Name] * int(ft.BlockSize()))
+
+		fileNeed = ft.FileSize() - downloaded
+		if fileNeed < 0 {
+			fileNeed = 0
+		}
+
+		need += fileNeed
+		items++
+
+		return true
+	})
+
+	needRatio := float64(need) / float64(tot)
+	completionPct := 100 * (1 - needRatio)
+
+	// If the completion is 100% but there are deletes we need to handle,
+	// drop it down a notch. Hack for consumers that look only at the
+	// percentage (our own GUI does the same calculation as here on its own
+	// and needs the same fixup).
+	if need == 0 && deletes > 0 {
+		completionPct = 95 // chosen by fair dice roll
+	}
+
+	l.Debugf("%v Completion(%s, %q): %f (%d / %d = %f)", v.model, device, folder, completionPct, need, tot, needRatio)
+
+	return FolderCompletion{
+		CompletionPct: completionPct,
+		NeedBytes:     need,
+		NeedItems:     items,
+		GlobalBytes:   tot,
+		NeedDeletes:   deletes,
+	}
+}
+
+// GlobalSize returns the number of files, deleted files and total bytes for all
+// files in the global model.
+func (v *ReadView) GlobalSize(folder string) db.Counts {
+	if snap, ok := v.snaps[folder]; ok {
+		return snap.GlobalSize()
+	}
+	return db.Counts{}
+}
+
+// LocalSize returns the number of files, deleted files and total bytes for all
+// files in the local folder.
+func (v *ReadView) LocalSize(folder string) db.Counts {
+	if snap, ok := v.snaps[folder]; ok {
+		return snap.LocalSize()
+	}
+	return db.Counts{}
+}
+
+// ReceiveOnlyChangedSize returns the number of files, deleted files and
+// total bytes for all files that have changed locally in a receieve only
+// folder.
+func (v *ReadView) ReceiveOnlyChangedSize(folder string) db.Counts {
+	if snap, ok := v.snaps[folder]; ok {
+		return snap.ReceiveOnlyChangedSize()
+	}
+	return db.Counts{}
+}
+
+// NeedSize returns the number and total size of currently needed files.
+func (v *ReadView) NeedSize(folder string) db.Counts {
+	var result db.Counts
+	if snap, ok := v.snaps[folder]; ok {
+		cfg := v.cfgs[folder]
+		snap.WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
+			if cfg.IgnoreDelete && f.IsDeleted() {
+				return true
+			}
+
+			addSizeOfFile(&result, f)
+			return true
+		})
+		result.Bytes -= v.model.progressEmitter.BytesCompleted(folder)
+	}
+	l.Debugf("%v NeedSize(%q): %v", v.model, folder, result)
+	return result
+}
+
+// NeedFolderFiles returns paginated list of currently needed files in
+// progress, queued, and to be queued on next puller iteration, as well as the
+// total number of files currently needed.
+func (v *ReadView) NeedFolderFiles(folder string, page, perpage int) ([]db.FileInfoTruncated, []db.FileInfoTruncated, []db.FileInfoTruncated) {
+	snap, ok := v.snaps[folder]
+	if !ok {
+		return nil, nil, nil
+	}
+
+	var progress, queued, rest []db.FileInfoTruncated
+	var seen map[string]struct{}
+
+	skip := (page - 1) * perpage
+	get := perpage
+
+	v.model.fmut.RLock()
+	runner, ok := v.model.folderRunners[folder]
+	v.model.fmut.RUnlock()
+	if ok {
+		allProgressNames, allQueuedNames := runner.Jobs()
+
+		var progressNames, queuedNames []string
+		progressNames, skip, get = getChunk(allProgressNames, skip, get)
+		queuedNames, skip, get = getChunk(allQueuedNames, skip, get)
+
+		progress = make([]db.FileInfoTruncated, len(progressNames))
+		queued = make([]db.FileInfoTruncated, len(queuedNames))
+		seen = make(map[string]struct{}, len(progressNames)+len(queuedNames))
+
+		for i, name := range progressNames {
+			if f, ok := snap.GetGlobalTruncated(name); ok {
+				progress[i] = f
+				seen[name] = struct{}{}
+			}
+		}
+
+		for i, name := range queuedNames {
+			if f, ok := snap.GetGlobalTruncated(name); ok {
+				queued[i] = f
+				seen[name] = struct{}{}
+			}
+		}
+	}
+
+	rest = make([]db.FileInfoTruncated, 0, perpage)
+	cfg := v.cfgs[folder]
+	snap.WithNeedTruncated(protocol.LocalDeviceID, func(f db.FileIntf) bool {
+		if cfg.IgnoreDelete && f.IsDeleted() {
+			return true
+		}
+
+		if skip > 0 {
+			skip--
+			return true
+		}
+		ft := f.(db.FileInfoTruncated)
+		if _, ok := seen[ft.Name]; !ok {
+			rest = append(rest, ft)
+			get--
+		}
+		return get > 0
+	})
+
+	return progress, queued, rest
+}
+
+// CurrentSequence returns the change version for the given folder.
+func (v *ReadView) CurrentSequence(folder string) (int64, bool) {
+	snap, ok := v.snaps[folder]
+	if !ok {
+		return 0, false
+	}
+	return snap.Sequence(protocol.LocalDeviceID), true
+}
+
+// RemoteSequence returns the change version for the given folder, as
+// sent by remote peers.
+func (v *ReadView) RemoteSequence(folder string) (int64, bool) {
+	snap, ok := v.snaps[folder]
+	if !ok {
+		return 0, false
+	}
+
+	var ver int64
+	for _, device := range v.cfgs[folder].Devices {
+		ver += snap.Sequence(device.DeviceID)
+	}
+	return ver, true
+}
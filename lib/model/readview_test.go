@@ -0,0 +1,64 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestReadView(t *testing.T) {
+	db := db.OpenMemory()
+	m := newModel(defaultCfgWrapper, myID, "syncthing", "dev", db, nil)
+	m.AddFolder(defaultFolderConfig)
+	m.ServeBackground()
+	defer m.Stop()
+
+	fset := m.folderFiles["default"]
+	version := protocol.Vector{}.Update(device1.Short())
+	fset.Update(device1, []protocol.FileInfo{
+		{Name: "a", Size: 10, Version: version, Sequence: 1},
+	})
+
+	view := m.ReadView("default", "missing")
+	defer view.Release()
+
+	fset.Update(device1, []protocol.FileInfo{
+		{Name: "b", Size: 20, Version: version, Sequence: 2},
+	})
+
+	// The view doesn't see the update, but its numbers agree with each
+	// other.
+	if global := view.GlobalSize("default"); global.Files != 1 || global.Bytes != 10 {
+		t.Errorf("Unexpected global size in view: %v", global)
+	}
+	if need := view.NeedSize("default"); need.Files != 1 || need.Bytes != 10 {
+		t.Errorf("Unexpected need size in view: %v", need)
+	}
+	if _, _, rest := view.NeedFolderFiles("default", 1, 10); len(rest) != 1 {
+		t.Errorf("Unexpected needed files in view: %v", rest)
+	}
+	if comp := view.Completion(protocol.LocalDeviceID, "default"); comp.NeedItems != 1 || comp.GlobalBytes != 10 {
+		t.Errorf("Unexpected completion in view: %+v", comp)
+	}
+	if seq, ok := view.RemoteSequence("default"); !ok || seq != 1 {
+		t.Errorf("Unexpected remote sequence in view: %v %v", seq, ok)
+	}
+
+	if need := m.NeedSize("default"); need.Files != 2 || need.Bytes != 30 {
+		t.Errorf("Unexpected need size: %v", need)
+	}
+
+	if comp := view.Completion(protocol.LocalDeviceID, "missing"); comp != (FolderCompletion{}) {
+		t.Errorf("Unexpected completion for missing folder: %+v", comp)
+	}
+	if _, ok := view.CurrentSequence("missing"); ok {
+		t.Error("Missing folder should have no sequence")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The most changed files listed in a RehashReport.
+const maxRehashReportChanged = 1000
+
+var errSilentChange = errors.New("contents changed without its size or modification time changing, possibly due to disk corruption")
+
+// A RehashReport is the result of rehashing a folder, i.e. scanning it
+// while hashing all regular files, even those which seem unchanged.
+type RehashReport struct {
+	Folder   string        `json:"folder"`
+	Subdirs  []string      `json:"subdirs"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Files which seemed unchanged and were found to be so.
+	Verified int `json:"verified"`
+	// Files which seemed unchanged, having the same size and modification
+	// time as in the database, but whose contents differ. This is what
+	// silent disk corruption looks like. They are not announced as
+	// changed, lest the corruption spread to other devices, but reported
+	// as folder errors.
+	Changed      []string `json:"changed"`
+	ChangedTotal int      `json:"changedTotal"`
+}
+
+// Rehash scans the subdirs, or the whole folder if there are none, hashing
+// all regular files rather than only those which seem changed.
+func (f *folder) Rehash(subdirs []string) (RehashReport, error) {
+	<-f.initialScanFinished
+	report := &RehashReport{
+		Folder:  f.ID,
+		Subdirs: subdirs,
+		Started: time.Now(),
+	}
+	req := rescanRequest{
+		subdirs: subdirs,
+		rehash:  report,
+		err:     make(chan error),
+	}
+
+	select {
+	case f.scanNow <- req:
+		err := <-req.err
+		report.Duration = time.Since(report.Started)
+		return *report, err
+	case <-f.ctx.Done():
+		return RehashReport{}, f.ctx.Err()
+	}
+}
+
+// rehashed returns true if the file was hashed only because of rehashing,
+// and is to be left as it is in the database: either it is unchanged, or
+// its contents differ while it seems unchanged. The latter are added to the
+// report and flagged as scan errors.
+func (f *folder) rehashed(file protocol.FileInfo, report *RehashReport) bool {
+	if file.Type != protocol.FileInfoTypeFile || file.IsDeleted() {
+		return false
+	}
+	cur, ok := f.fset.Get(protocol.LocalDeviceID, file.Name)
+	if !ok || !cur.IsEquivalentPermBits(file, f.IgnoredLocalPermBits(), true, f.localFlags) {
+		// Changed as far as a normal scan is concerned.
+		return false
+	}
+	if protocol.BlocksEqual(cur.Blocks, file.Blocks) {
+		report.Verified++
+		return true
+	}
+
+	l.Warnf("Rehash of folder %v: %s: %v", f.Description(), file.Name, errSilentChange)
+	f.newScanError(file.Name, errSilentChange)
+	report.ChangedTotal++
+	if len(report.Changed) < maxRehashReportChanged {
+		report.Changed = append(report.Changed, file.Name)
+	}
+	return true
+}
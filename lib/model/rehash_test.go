@@ -0,0 +1,92 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestRehash(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	name := filepath.Join(fcfg.Filesystem().URI(), "file")
+	if err := ioutil.WriteFile(name, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := m.CurrentFolderFile(fcfg.ID, "file")
+
+	// Same size and modification time, different contents.
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte("corrupts"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _ := m.CurrentFolderFile(fcfg.ID, "file"); cur.Sequence != before.Sequence {
+		t.Fatal("scan picked up a change without modification time change")
+	}
+
+	report, err := m.RehashFolderSubdirs(fcfg.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ChangedTotal != 1 || len(report.Changed) != 1 || report.Changed[0] != "file" || report.Verified != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	// The corruption must not be announced as a new version.
+	after, _ := m.CurrentFolderFile(fcfg.ID, "file")
+	if after.Sequence != before.Sequence || !after.Version.Equal(before.Version) || !protocol.BlocksEqual(after.Blocks, before.Blocks) {
+		t.Errorf("silently changed file updated: %v, was %v", after, before)
+	}
+	errs, err := m.FolderErrors(fcfg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0].Path != "file" {
+		t.Errorf("expected a folder error for the file, got %v", errs)
+	}
+
+	// Once restored, everything is as recorded.
+	if err := ioutil.WriteFile(name, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	report, err = m.RehashFolderSubdirs(fcfg.ID, []string{"file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ChangedTotal != 0 || report.Verified != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if cur, _ := m.CurrentFolderFile(fcfg.ID, "file"); cur.Sequence != before.Sequence {
+		t.Error("unchanged file updated by rehash")
+	}
+}
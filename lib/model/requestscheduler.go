@@ -0,0 +1,190 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"container/heap"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Total size of incoming requests handled concurrently, unless configured
+// otherwise.
+const defaultIncomingRequestKiB = 2 * defaultPullerPendingKiB
+
+// requestScheduler limits the total number of bytes of incoming requests
+// being handled at once. When the limit is reached, requests are queued and
+// served in start time fair queuing order, so that each device gets a share
+// of the capacity in proportion to its weight no matter how many requests
+// it has outstanding. A capacity of zero means no limit.
+type requestScheduler struct {
+	mut       sync.Mutex
+	max       int
+	available int
+	vtime     float64                       // start tag of the most recently granted request
+	finish    map[protocol.DeviceID]float64 // finish tag of the latest request per device
+	weights   map[protocol.DeviceID]int
+	waiting   requestWaiters
+	nextSeq   int
+}
+
+type requestWaiter struct {
+	bytes int
+	start float64
+	seq   int // keeps FIFO order among equal start tags
+	ready chan struct{}
+}
+
+func newRequestScheduler(max int) *requestScheduler {
+	return &requestScheduler{
+		mut:       sync.NewMutex(),
+		max:       max,
+		available: max,
+		finish:    make(map[protocol.DeviceID]float64),
+		weights:   make(map[protocol.DeviceID]int),
+	}
+}
+
+// take blocks until the given number of bytes can be handled for the
+// device.
+func (s *requestScheduler) take(device protocol.DeviceID, bytes int) {
+	s.mut.Lock()
+	if s.max <= 0 {
+		s.mut.Unlock()
+		return
+	}
+	if bytes > s.max {
+		bytes = s.max
+	}
+
+	weight := s.weights[device]
+	if weight <= 0 {
+		weight = 1
+	}
+	start := s.vtime
+	if f := s.finish[device]; f > start {
+		start = f
+	}
+	s.finish[device] = start + float64(bytes)/float64(weight)
+
+	if len(s.waiting) == 0 && bytes <= s.available {
+		s.available -= bytes
+		s.vtime = start
+		s.mut.Unlock()
+		return
+	}
+
+	w := &requestWaiter{
+		bytes: bytes,
+		start: start,
+		seq:   s.nextSeq,
+		ready: make(chan struct{}),
+	}
+	s.nextSeq++
+	heap.Push(&s.waiting, w)
+	s.mut.Unlock()
+
+	<-w.ready
+}
+
+// give returns bytes previously taken.
+func (s *requestScheduler) give(bytes int) {
+	s.mut.Lock()
+	if s.max > 0 {
+		if bytes > s.max {
+			bytes = s.max
+		}
+		s.available += bytes
+		if s.available > s.max {
+			s.available = s.max
+		}
+	}
+	s.dispatchLocked()
+	s.mut.Unlock()
+}
+
+func (s *requestScheduler) setCapacity(max int) {
+	s.mut.Lock()
+	s.available += max - s.max
+	s.max = max
+	if s.available < 0 {
+		s.available = 0
+	} else if s.available > s.max {
+		s.available = s.max
+	}
+	s.dispatchLocked()
+	s.mut.Unlock()
+}
+
+// setWeights updates the per device weights from the configuration.
+func (s *requestScheduler) setWeights(devices []config.DeviceConfiguration) {
+	s.mut.Lock()
+	s.weights = make(map[protocol.DeviceID]int, len(devices))
+	for _, dev := range devices {
+		s.weights[dev.DeviceID] = dev.RequestWeight
+	}
+	s.mut.Unlock()
+}
+
+func (s *requestScheduler) dispatchLocked() {
+	for len(s.waiting) > 0 {
+		w := s.waiting[0]
+		if s.max > 0 {
+			if w.bytes > s.max {
+				w.bytes = s.max
+			}
+			if w.bytes > s.available {
+				return
+			}
+			s.available -= w.bytes
+		}
+		heap.Pop(&s.waiting)
+		s.vtime = w.start
+		close(w.ready)
+	}
+}
+
+// requestWaiters is a heap of waiting requests, lowest start tag first.
+type requestWaiters []*requestWaiter
+
+func (h requestWaiters) Len() int { return len(h) }
+
+func (h requestWaiters) Less(i, j int) bool {
+	if h[i].start != h[j].start {
+		return h[i].start < h[j].start
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h requestWaiters) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *requestWaiters) Push(x interface{}) {
+	*h = append(*h, x.(*requestWaiter))
+}
+
+func (h *requestWaiters) Pop() interface{} {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return w
+}
+
+func incomingRequestCapacity(opts config.OptionsConfiguration) int {
+	// 0: default, <0: no limiting
+	switch {
+	case opts.MaxIncomingRequestKiB > 0:
+		return 1024 * opts.MaxIncomingRequestKiB
+	case opts.MaxIncomingRequestKiB == 0:
+		return 1024 * defaultIncomingRequestKiB
+	default:
+		return 0
+	}
+}
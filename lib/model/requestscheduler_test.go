@@ -0,0 +1,95 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestRequestSchedulerFairness(t *testing.T) {
+	s := newRequestScheduler(10)
+	s.setWeights([]config.DeviceConfiguration{
+		{DeviceID: device1},
+		{DeviceID: device2, RequestWeight: 2},
+	})
+
+	// device1 fills the capacity and queues up more requests
+	s.take(device1, 10)
+
+	granted := make(chan string, 10)
+	queue := func(device protocol.DeviceID, name string) {
+		s.mut.Lock()
+		waiting := len(s.waiting)
+		s.mut.Unlock()
+		go func() {
+			s.take(device, 5)
+			granted <- name
+		}()
+		// Wait for the request to be queued, to get a predictable order
+		for {
+			s.mut.Lock()
+			n := len(s.waiting)
+			s.mut.Unlock()
+			if n > waiting {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue(device1, "a1")
+	queue(device1, "a2")
+	queue(device1, "a3")
+	queue(device2, "b1")
+	queue(device2, "b2")
+	queue(device2, "b3")
+
+	// device1 has already had its share, and device2 is entitled to twice
+	// as much, so device2 goes first.
+	expected := []string{"b1", "b2", "b3", "a1", "a2", "a3"}
+	for _, exp := range expected {
+		s.give(5)
+		select {
+		case name := <-granted:
+			if name != exp {
+				t.Fatalf("Expected %s to be granted, got %s", exp, name)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for", exp)
+		}
+	}
+}
+
+func TestRequestSchedulerCapacity(t *testing.T) {
+	s := newRequestScheduler(10)
+
+	// Oversize requests are clamped to the capacity
+	s.take(device1, 100)
+
+	done := make(chan struct{})
+	go func() {
+		s.take(device2, 1)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Request should have been queued")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Removing the limit lets everything through
+	s.setCapacity(0)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Request should have been granted")
+	}
+	s.take(device1, 100)
+}
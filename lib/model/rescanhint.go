@@ -0,0 +1,176 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const (
+	// We don't hint the same file to the same device more often than this.
+	rescanHintInterval = time.Minute
+	// The most names we act on from a single RescanHint message.
+	maxRescanHintNames = 100
+)
+
+// rescanHintTracker remembers which rescan hints were sent recently, so that
+// a file failing to pull repeatedly doesn't cause a hint every time.
+type rescanHintTracker struct {
+	mut  sync.Mutex
+	sent map[rescanHintKey]time.Time
+}
+
+type rescanHintKey struct {
+	device       protocol.DeviceID
+	folder, name string
+}
+
+func newRescanHintTracker() *rescanHintTracker {
+	return &rescanHintTracker{
+		mut:  sync.NewMutex(),
+		sent: make(map[rescanHintKey]time.Time),
+	}
+}
+
+// shouldSend returns true and records the hint if it wasn't sent within the
+// last rescanHintInterval.
+func (t *rescanHintTracker) shouldSend(device protocol.DeviceID, folder, name string, now time.Time) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	key := rescanHintKey{device, folder, name}
+	if last, ok := t.sent[key]; ok && now.Sub(last) < rescanHintInterval {
+		return false
+	}
+	for k, last := range t.sent {
+		if now.Sub(last) >= rescanHintInterval {
+			delete(t.sent, k)
+		}
+	}
+	t.sent[key] = now
+	return true
+}
+
+// sendRescanHint asks the device to rescan the named file, as the data it
+// announced for it could not be retrieved. Nothing is sent to devices not
+// supporting it, or if the same hint was sent recently.
+func (m *model) sendRescanHint(device protocol.DeviceID, folder, name string) {
+	m.pmut.RLock()
+	conn, ok := m.conn[device]
+	_, supported := m.rescanHintPeers[device]
+	m.pmut.RUnlock()
+	if !ok || !supported {
+		return
+	}
+
+	if !m.rescanHints.shouldSend(device, folder, name, time.Now()) {
+		return
+	}
+	l.Debugf("%v sending rescan hint to %s: %q / %q", m, device, folder, name)
+	conn.RescanHint(folder, []string{name})
+}
+
+// RescanHint marks the files the remote device failed to pull from us for
+// rehashing and schedules a scan of them. Names that aren't regular files
+// in our index are ignored.
+func (m *model) RescanHint(deviceID protocol.DeviceID, hint protocol.RescanHint) {
+	if cfg, ok := m.cfg.Folder(hint.Folder); !ok || !cfg.SharedWith(deviceID) {
+		l.Debugf("Ignoring rescan hint for unshared folder %q from %v", hint.Folder, deviceID)
+		return
+	}
+
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[hint.Folder]
+	err := m.checkFolderRunningLocked(hint.Folder)
+	m.fmut.RUnlock()
+	if !ok || err != nil {
+		l.Debugf("Ignoring rescan hint for stopped folder %q from %v", hint.Folder, deviceID)
+		return
+	}
+
+	names := hint.Names
+	if len(names) > maxRescanHintNames {
+		names = names[:maxRescanHintNames]
+	}
+
+	var files []protocol.FileInfo
+	var subdirs []string
+	for _, raw := range names {
+		name, err := fs.Canonicalize(raw)
+		if err != nil || name == "." {
+			l.Debugf("Ignoring rescan hint for invalid name %q from %v", raw, deviceID)
+			continue
+		}
+		cf, ok := fset.Get(protocol.LocalDeviceID, name)
+		if !ok || cf.IsDeleted() || cf.IsInvalid() || cf.Type != protocol.FileInfoTypeFile {
+			continue
+		}
+		cf.SetMustRescan(m.shortID)
+		files = append(files, cf)
+		subdirs = append(subdirs, name)
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	l.Debugf("%v rescan hint from %s: %q: %d files", m, deviceID, hint.Folder, len(files))
+	fset.Update(protocol.LocalDeviceID, files)
+	// Scanning blocks until it's done, which mustn't hold up the connection.
+	go func() {
+		if err := m.ScanFolderSubdirs(hint.Folder, subdirs); err != nil {
+			l.Debugf("%v rescan hint: %q: %v", m, hint.Folder, err)
+		}
+	}()
+}
+
+// reportBlockMismatch tells the device that the block it sent us doesn't
+// match the hash it announced, so that it can rehash the file. Devices not
+// supporting BlockMismatch messages get a rescan hint instead.
+func (m *model) reportBlockMismatch(device protocol.DeviceID, folder, name string, block protocol.BlockInfo) {
+	m.pmut.RLock()
+	conn, ok := m.conn[device]
+	_, supported := m.blockMismatchPeers[device]
+	m.pmut.RUnlock()
+	if !ok {
+		return
+	}
+	if !supported {
+		m.sendRescanHint(device, folder, name)
+		return
+	}
+
+	if !m.rescanHints.shouldSend(device, folder, name, time.Now()) {
+		return
+	}
+	l.Debugf("%v sending block mismatch to %s: %q / %q o=%d s=%d", m, device, folder, name, block.Offset, block.Size)
+	conn.BlockMismatch(folder, name, block.Offset, block.Size, block.Hash)
+}
+
+// BlockMismatch rechecks the file we served a bad block of, which has
+// probably changed without us noticing. If our index still has the hash the
+// device asked for, the file is rehashed and the corrected index is sent
+// out as usual.
+func (m *model) BlockMismatch(deviceID protocol.DeviceID, mismatch protocol.BlockMismatch) {
+	cfg, ok := m.cfg.Folder(mismatch.Folder)
+	if !ok || !cfg.SharedWith(deviceID) {
+		l.Debugf("Ignoring block mismatch for unshared folder %q from %v", mismatch.Folder, deviceID)
+		return
+	}
+	name, err := fs.Canonicalize(mismatch.Name)
+	if err != nil || name == "." {
+		l.Debugf("Ignoring block mismatch for invalid name %q from %v", mismatch.Name, deviceID)
+		return
+	}
+
+	// Rescanning blocks until it's done, which mustn't hold up the
+	// connection.
+	go m.recheckFile(deviceID, cfg.Filesystem(), mismatch.Folder, name, mismatch.Size, mismatch.Offset, mismatch.Hash)
+}
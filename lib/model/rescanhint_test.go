@@ -0,0 +1,136 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSendRescanHint(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer m.Stop()
+
+	conn := &fakeConnection{id: device1, model: m}
+	m.AddConnection(conn, protocol.HelloResult{})
+
+	if cm := m.generateClusterConfig(device1); !cm.RescanHints {
+		t.Error("Expected cluster config to announce rescan hint support")
+	}
+
+	// Peers not announcing support don't get anything.
+	m.ClusterConfig(device1, protocol.ClusterConfig{})
+	m.sendRescanHint(device1, "default", "foo")
+	if len(conn.rescanHintMessages) != 0 {
+		t.Fatal("Unexpected rescan hint to unsupporting peer")
+	}
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{RescanHints: true})
+	m.sendRescanHint(device1, "default", "foo")
+	m.sendRescanHint(device1, "default", "foo")
+	m.sendRescanHint(device1, "default", "bar")
+	if len(conn.rescanHintMessages) != 2 {
+		t.Fatal("Expected two rescan hints, got", conn.rescanHintMessages)
+	}
+	if hint := conn.rescanHintMessages[0]; hint.Folder != "default" || len(hint.Names) != 1 || hint.Names[0] != "foo" {
+		t.Error("Unexpected rescan hint:", hint)
+	}
+}
+
+func TestRescanHintTracker(t *testing.T) {
+	tr := newRescanHintTracker()
+	now := time.Now()
+
+	if !tr.shouldSend(device1, "default", "foo", now) {
+		t.Error("First hint should be sent")
+	}
+	if tr.shouldSend(device1, "default", "foo", now.Add(time.Second)) {
+		t.Error("Repeated hint should not be sent")
+	}
+	if !tr.shouldSend(device2, "default", "foo", now.Add(time.Second)) {
+		t.Error("Hint to another device should be sent")
+	}
+	if !tr.shouldSend(device1, "default", "foo", now.Add(rescanHintInterval)) {
+		t.Error("Hint should be sent again after the interval")
+	}
+}
+
+func TestReceiveRescanHint(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer m.Stop()
+
+	before, ok := m.CurrentFolderFile("default", "foo")
+	if !ok {
+		t.Fatal("Expected file to exist")
+	}
+
+	// Unshared folders, invalid names and files we don't have are ignored.
+	m.RescanHint(device2, protocol.RescanHint{Folder: "default", Names: []string{"foo"}})
+	m.RescanHint(device1, protocol.RescanHint{Folder: "unshared", Names: []string{"foo"}})
+	m.RescanHint(device1, protocol.RescanHint{Folder: "default", Names: []string{"", "../foo", "nonexistent"}})
+	if cur, _ := m.CurrentFolderFile("default", "foo"); cur.Sequence != before.Sequence {
+		t.Fatal("File should not have been touched")
+	}
+
+	m.RescanHint(device1, protocol.RescanHint{Folder: "default", Names: []string{"foo"}})
+	if cur, _ := m.CurrentFolderFile("default", "foo"); cur.Sequence <= before.Sequence {
+		t.Error("Expected file to be marked for rescan")
+	}
+}
+
+func TestSendBlockMismatch(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer m.Stop()
+
+	conn := &fakeConnection{id: device1, model: m}
+	m.AddConnection(conn, protocol.HelloResult{})
+
+	if cm := m.generateClusterConfig(device1); !cm.BlockMismatch {
+		t.Error("Expected cluster config to announce block mismatch support")
+	}
+
+	block := protocol.BlockInfo{Offset: 0, Size: 6, Hash: []byte("hash")}
+
+	// Peers only supporting rescan hints get one of those.
+	m.ClusterConfig(device1, protocol.ClusterConfig{RescanHints: true})
+	m.reportBlockMismatch(device1, "default", "foo", block)
+	if len(conn.blockMismatchMessages) != 0 || len(conn.rescanHintMessages) != 1 {
+		t.Fatal("Expected a rescan hint, got", conn.blockMismatchMessages, conn.rescanHintMessages)
+	}
+
+	m.ClusterConfig(device1, protocol.ClusterConfig{BlockMismatch: true})
+	m.reportBlockMismatch(device1, "default", "bar", block)
+	m.reportBlockMismatch(device1, "default", "bar", block)
+	if len(conn.blockMismatchMessages) != 1 {
+		t.Fatal("Expected one block mismatch, got", conn.blockMismatchMessages)
+	}
+	if mm := conn.blockMismatchMessages[0]; mm.Folder != "default" || mm.Name != "bar" || mm.Size != 6 || string(mm.Hash) != "hash" {
+		t.Error("Unexpected block mismatch:", mm)
+	}
+}
+
+func TestReceiveBlockMismatch(t *testing.T) {
+	m := setupModel(defaultCfgWrapper)
+	defer m.Stop()
+
+	before, ok := m.CurrentFolderFile("default", "foo")
+	if !ok || len(before.Blocks) == 0 {
+		t.Fatal("Expected file to exist")
+	}
+	block := before.Blocks[0]
+
+	m.BlockMismatch(device1, protocol.BlockMismatch{Folder: "default", Name: "foo", Offset: block.Offset, Size: block.Size, Hash: block.Hash})
+	for i := 0; i < 100; i++ {
+		if cur, _ := m.CurrentFolderFile("default", "foo"); cur.Sequence > before.Sequence {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected file to be rechecked")
+}
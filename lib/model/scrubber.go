@@ -0,0 +1,249 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// How often the scrubber verifies the next ScrubBlocksPerMin blocks of each
+// folder.
+const scrubInterval = time.Minute
+
+// scrubber goes through the local files of the folders with scrubbing
+// enabled, a few blocks at a time, verifying that their contents on disk
+// are still as in the index. Corrupt blocks are fetched again from the
+// devices that have the same version of the file, and written in place.
+type scrubber struct {
+	model    *model
+	interval time.Duration
+	cursors  map[string]scrubCursor // Where to continue, by folder
+	stop     chan struct{}
+}
+
+// A scrubCursor is the sequence number of the next file to verify and the
+// index of the next block of it.
+type scrubCursor struct {
+	sequence int64
+	block    int
+}
+
+// A scrubItem is a range of blocks of a file to verify.
+type scrubItem struct {
+	file     protocol.FileInfo
+	from, to int
+}
+
+func newScrubber(m *model) *scrubber {
+	return &scrubber{
+		model:    m,
+		interval: scrubInterval,
+		cursors:  make(map[string]scrubCursor),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *scrubber) Serve() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.scrub()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *scrubber) Stop() {
+	close(s.stop)
+}
+
+func (s *scrubber) String() string {
+	return "scrubber"
+}
+
+func (s *scrubber) scrub() {
+	for id, cfg := range s.model.cfg.Folders() {
+		if cfg.ScrubBlocksPerMin <= 0 || cfg.Paused || cfg.Type == config.FolderTypeReceiveEncrypted {
+			// The hashes of encrypted files can't be verified.
+			delete(s.cursors, id)
+			continue
+		}
+		s.model.fmut.RLock()
+		fset, ok := s.model.folderFiles[id]
+		s.model.fmut.RUnlock()
+		if !ok {
+			continue
+		}
+		s.scrubFolder(cfg, fset)
+	}
+}
+
+// scrubFolder verifies the next ScrubBlocksPerMin blocks of the folder,
+// starting over once all files have been verified.
+func (s *scrubber) scrubFolder(cfg config.FolderConfiguration, fset *db.FileSet) {
+	items, next := scrubItems(fset, s.cursors[cfg.ID], cfg.ScrubBlocksPerMin)
+	s.cursors[cfg.ID] = next
+
+	ffs := fset.MtimeFS()
+	for _, item := range items {
+		corrupt := scrubFile(ffs, item)
+		if len(corrupt) == 0 {
+			continue
+		}
+		l.Warnf("Scrubbing folder %v: %d blocks of %s are corrupt on disk", cfg.Description(), len(corrupt), item.file.Name)
+		repaired := s.model.repairBlocks(cfg.ID, ffs, fset, item.file, corrupt)
+		if repaired < len(corrupt) {
+			l.Warnf("Scrubbing folder %v: %d corrupt blocks of %s could not be repaired, as no connected device has them in the same version", cfg.Description(), len(corrupt)-repaired, item.file.Name)
+		}
+		if repaired > 0 {
+			l.Infof("Scrubbing folder %v: repaired %d blocks of %s from other devices", cfg.Description(), repaired, item.file.Name)
+		}
+	}
+}
+
+// scrubItems returns the block ranges of the local files to verify next,
+// at most n blocks in total, starting at the cursor. The returned cursor is
+// where to continue afterwards, the start once we reached the end.
+func scrubItems(fset *db.FileSet, cur scrubCursor, n int) ([]scrubItem, scrubCursor) {
+	var items []scrubItem
+	next := scrubCursor{}
+	fset.WithHaveSequence(cur.sequence, func(fi db.FileIntf) bool {
+		file := fi.(protocol.FileInfo)
+		from := 0
+		if file.Sequence == cur.sequence {
+			from = cur.block
+		}
+		if n == 0 {
+			next = scrubCursor{sequence: file.Sequence, block: from}
+			return false
+		}
+		if file.Type != protocol.FileInfoTypeFile || file.IsDeleted() || file.IsInvalid() || from >= len(file.Blocks) {
+			return true
+		}
+		to := from + n
+		if to > len(file.Blocks) {
+			to = len(file.Blocks)
+		}
+		items = append(items, scrubItem{file: file, from: from, to: to})
+		n -= to - from
+		if to < len(file.Blocks) {
+			next = scrubCursor{sequence: file.Sequence, block: to}
+			return false
+		}
+		return true
+	})
+	return items, next
+}
+
+// scrubFile returns the blocks of the range whose contents on disk don't
+// match the index. Files that changed since they were last scanned are the
+// scanner's business, and skipped.
+func scrubFile(ffs fs.Filesystem, item scrubItem) []protocol.BlockInfo {
+	if !scrubUnchanged(ffs, item.file) {
+		return nil
+	}
+	fd, err := ffs.Open(item.file.Name)
+	if err != nil {
+		l.Debugln("scrubber: opening", item.file.Name, err)
+		return nil
+	}
+	defer fd.Close()
+
+	var corrupt []protocol.BlockInfo
+	buf := make([]byte, 0, item.file.BlockSize())
+	for _, block := range item.file.Blocks[item.from:item.to] {
+		buf = buf[:block.Size]
+		if _, err := fd.ReadAt(buf, block.Offset); err != nil {
+			l.Debugln("scrubber: reading", item.file.Name, err)
+			return nil
+		}
+		// The weak hash is no use here, as we are looking for any change.
+		if !scanner.Validate(buf, block.Hash, 0) {
+			corrupt = append(corrupt, block)
+		}
+	}
+	return corrupt
+}
+
+// scrubUnchanged returns true if the file on disk has the size and
+// modification time recorded in the index.
+func scrubUnchanged(ffs fs.Filesystem, file protocol.FileInfo) bool {
+	info, err := ffs.Lstat(file.Name)
+	if err != nil || !info.IsRegular() {
+		return false
+	}
+	return info.Size() == file.Size && info.ModTime().Equal(file.ModTime())
+}
+
+// repairBlocks fetches the blocks from the connected devices that have the
+// same version of the file, and writes them into the file, keeping its
+// modification time. It returns the number of blocks repaired.
+func (m *model) repairBlocks(folder string, ffs fs.Filesystem, fset *db.FileSet, file protocol.FileInfo, blocks []protocol.BlockInfo) int {
+	var devices []protocol.DeviceID
+	for _, device := range fset.Availability(file.Name) {
+		if theirs, ok := fset.Get(device, file.Name); ok && theirs.Version.Equal(file.Version) {
+			devices = append(devices, device)
+		}
+	}
+	if len(devices) == 0 {
+		return 0
+	}
+
+	// Fetch all blocks before writing, so that the file is changed as
+	// briefly as possible.
+	repairs := make(map[int64][]byte, len(blocks))
+	for _, block := range blocks {
+		for _, device := range devices {
+			buf, err := m.requestGlobal(device, folder, file.Name, block.Offset, int(block.Size), block.Hash, 0, false)
+			if err != nil {
+				l.Debugln("scrubber: requesting block of", file.Name, "from", device, err)
+				continue
+			}
+			if scanner.Validate(buf, block.Hash, 0) {
+				repairs[block.Offset] = buf
+				break
+			}
+		}
+	}
+	if len(repairs) == 0 || !scrubUnchanged(ffs, file) {
+		return 0
+	}
+
+	fd, err := ffs.OpenFile(file.Name, fs.OptReadWrite, 0)
+	if err != nil {
+		l.Infof("Scrubbing folder %s: repairing %s: %v", folder, file.Name, err)
+		return 0
+	}
+	repaired := 0
+	for offset, buf := range repairs {
+		if _, err := fd.WriteAt(buf, offset); err != nil {
+			l.Infof("Scrubbing folder %s: repairing %s: %v", folder, file.Name, err)
+			break
+		}
+		repaired++
+	}
+	if err := fd.Sync(); err != nil {
+		l.Infof("Scrubbing folder %s: repairing %s: %v", folder, file.Name, err)
+	}
+	fd.Close()
+
+	// Writing changed the modification time, but not the contents as far
+	// as the index is concerned.
+	if err := ffs.Chtimes(file.Name, file.ModTime(), file.ModTime()); err != nil {
+		l.Infof("Scrubbing folder %s: repairing %s: %v", folder, file.Name, err)
+	}
+	return repaired
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestScrubRepair(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	data := make([]byte, 3*protocol.MinBlockSize)
+	rand.Read(data)
+	name := filepath.Join(fcfg.Filesystem().URI(), "file")
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	local, _ := m.CurrentFolderFile(fcfg.ID, "file")
+
+	fc := addFakeConn(m, device1)
+	fc.requestFn = func(folder, name string, offset int64, size int, hash []byte, fromTemporary bool) ([]byte, error) {
+		return data[offset : offset+int64(size)], nil
+	}
+	m.Index(device1, fcfg.ID, []protocol.FileInfo{local})
+
+	// Corrupt the second block, keeping the size and modification time.
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(nil), data...)
+	copy(corrupt[protocol.MinBlockSize:], "corrupt")
+	if err := ioutil.WriteFile(name, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newScrubber(m)
+	fcfg.ScrubBlocksPerMin = 2
+	m.fmut.RLock()
+	fset := m.folderFiles[fcfg.ID]
+	m.fmut.RUnlock()
+
+	s.scrubFolder(fcfg, fset)
+	if cur := s.cursors[fcfg.ID]; cur.sequence != local.Sequence || cur.block != 2 {
+		t.Errorf("Unexpected cursor %+v", cur)
+	}
+	if bs, err := ioutil.ReadFile(name); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(bs, data) {
+		t.Error("Corrupt block not repaired")
+	}
+	if info2, err := os.Stat(name); err != nil {
+		t.Fatal(err)
+	} else if !info2.ModTime().Equal(info.ModTime()) {
+		t.Errorf("Modification time changed to %v from %v", info2.ModTime(), info.ModTime())
+	}
+
+	// The rest of the file, and starting over.
+	s.scrubFolder(fcfg, fset)
+	if cur := s.cursors[fcfg.ID]; cur != (scrubCursor{}) {
+		t.Errorf("Unexpected cursor %+v", cur)
+	}
+
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if cur, _ := m.CurrentFolderFile(fcfg.ID, "file"); cur.Sequence != local.Sequence {
+		t.Error("Repaired file seen as changed by the scanner")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sha256"
+)
+
+var (
+	errSeedMismatch = errors.New("content does not match the index")
+	errSeedExists   = errors.New("file already exists in folder")
+)
+
+// SeedResult is the outcome of seeding a folder. Skipped lists the files
+// that were present in the source but not imported, with the reason.
+type SeedResult struct {
+	Imported int               `json:"imported"`
+	Bytes    int64             `json:"bytes"`
+	Skipped  map[string]string `json:"skipped"`
+}
+
+// SeedFolder imports files the folder needs from a seed source: a
+// directory, or a zip or (gzipped) tar archive, laid out like the folder.
+// Each file's content is verified block by block against the global index
+// entry as it is copied, and only files that match exactly are put in
+// place and recorded in the local index with the global version, as if they
+// had been pulled. Files that already exist in the folder are left alone.
+func SeedFolder(fset *db.FileSet, cfg config.FolderConfiguration, source string) (SeedResult, error) {
+	need := make(map[string]protocol.FileInfo)
+	fset.WithNeed(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		f := fi.(protocol.FileInfo)
+		if f.IsDeleted() || f.IsInvalid() || f.IsDirectory() || f.IsSymlink() {
+			return true
+		}
+		need[osutil.NormalizedFilename(f.Name)] = f
+		return true
+	})
+
+	res := SeedResult{Skipped: make(map[string]string)}
+	if len(need) == 0 {
+		return res, nil
+	}
+
+	ffs := cfg.Filesystem()
+	batch := newFileInfoBatch(func(fs []protocol.FileInfo) error {
+		fset.Update(protocol.LocalDeviceID, fs)
+		return nil
+	})
+
+	err := walkSeedSource(source, func(name string, size int64, open func() (io.ReadCloser, error)) error {
+		name = osutil.NormalizedFilename(filepath.FromSlash(name))
+		f, ok := need[name]
+		if !ok {
+			return nil
+		}
+		delete(need, name)
+		if size != f.Size {
+			res.Skipped[name] = errSeedMismatch.Error()
+			return nil
+		}
+
+		r, err := open()
+		if err != nil {
+			res.Skipped[name] = err.Error()
+			return nil
+		}
+		err = seedFile(ffs, cfg, f, r)
+		r.Close()
+		if err != nil {
+			l.Debugln("seed:", name, err)
+			res.Skipped[name] = err.Error()
+			return nil
+		}
+
+		f.Sequence = 0
+		f.LocalFlags = 0
+		batch.append(f)
+		res.Imported++
+		res.Bytes += f.Size
+		return batch.flushIfFull()
+	})
+	if err != nil {
+		return res, err
+	}
+	return res, batch.flush()
+}
+
+// seedFile writes the content read from r to the file, via a temporary
+// file, if every block matches the file's block hashes.
+func seedFile(ffs fs.Filesystem, cfg config.FolderConfiguration, f protocol.FileInfo, r io.Reader) error {
+	if _, err := ffs.Lstat(f.Name); err == nil {
+		return errSeedExists
+	} else if !fs.IsNotExist(err) {
+		return err
+	}
+
+	if err := ffs.MkdirAll(filepath.Dir(f.Name), 0755); err != nil {
+		return err
+	}
+	tempName := fs.TempName(f.Name)
+	fd, err := ffs.Create(tempName)
+	if err != nil {
+		return err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			fd.Close()
+			ffs.Remove(tempName)
+		}
+	}()
+
+	buf := make([]byte, f.BlockSize())
+	for _, block := range f.Blocks {
+		buf = buf[:block.Size]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+		if hash := sha256.Sum256(buf); !bytes.Equal(hash[:], block.Hash) {
+			return errSeedMismatch
+		}
+		if _, err := fd.Write(buf); err != nil {
+			return err
+		}
+	}
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	if !cfg.IgnorePerms && !f.NoPermissions {
+		if err := ffs.Chmod(tempName, fs.FileMode(f.Permissions&0777)); err != nil {
+			return err
+		}
+	}
+	ffs.Chtimes(tempName, f.ModTime(), f.ModTime()) // never fails
+	if err := ffs.Rename(tempName, f.Name); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+// walkSeedSource calls fn for every regular file in the directory or
+// archive, with its slash separated path relative to the root. The opened
+// reader is only valid until fn returns.
+func walkSeedSource(source string, fn func(name string, size int64, open func() (io.ReadCloser, error)) error) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	lower := strings.ToLower(source)
+	switch {
+	case info.IsDir():
+		return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(source, path)
+			if err != nil {
+				return err
+			}
+			return fn(filepath.ToSlash(rel), info.Size(), func() (io.ReadCloser, error) {
+				return os.Open(path)
+			})
+		})
+
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(source)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() {
+				continue
+			}
+			if err := fn(strings.TrimPrefix(zf.Name, "./"), int64(zf.UncompressedSize64), zf.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fd, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		var r io.Reader = fd
+		if !strings.HasSuffix(lower, ".tar") {
+			gr, err := gzip.NewReader(fd)
+			if err != nil {
+				return err
+			}
+			defer gr.Close()
+			r = gr
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue
+			}
+			err = fn(strings.TrimPrefix(hdr.Name, "./"), hdr.Size, func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(tr), nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return errors.New("seed source must be a directory or a .zip, .tar, .tar.gz or .tgz archive")
+	}
+}
+
+// SeedFolder imports files from a seed source into the folder, see
+// SeedFolder. The folder must be paused.
+func (m *model) SeedFolder(folder, source string) (SeedResult, error) {
+	restartMut := m.folderRestartMuts.Get(folder)
+	restartMut.Lock()
+	defer restartMut.Unlock()
+
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return SeedResult{}, errFolderMissing
+	}
+	if !cfg.Paused {
+		return SeedResult{}, errFolderNotPaused
+	}
+
+	res, err := SeedFolder(db.NewFileSet(folder, cfg.Filesystem(), m.db), cfg, source)
+	if res.Imported > 0 {
+		l.Infof("Seeded %d files (%d bytes) into folder %v from %s", res.Imported, res.Bytes, cfg.Description(), source)
+	}
+	return res, err
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sha256"
+)
+
+func TestSeedFolder(t *testing.T) {
+	fcfg := testFolderConfigTmp()
+	defer os.RemoveAll(fcfg.Path)
+	ffs := fcfg.Filesystem()
+
+	contents := map[string]string{
+		"good":        "good data",
+		"dir/nested":  "nested data",
+		"corrupt":     "right data",
+		"present":     "present data",
+		"not-in-seed": "missing data",
+	}
+	version := protocol.Vector{}.Update(device1.Short())
+	var files []protocol.FileInfo
+	for name, data := range contents {
+		hash := sha256.Sum256([]byte(data))
+		files = append(files, protocol.FileInfo{
+			Name:        filepath.FromSlash(name),
+			Size:        int64(len(data)),
+			Permissions: 0644,
+			ModifiedS:   1234567890,
+			Version:     version,
+			Blocks:      []protocol.BlockInfo{{Size: int32(len(data)), Hash: hash[:]}},
+		})
+	}
+
+	// The seed has the wrong content for one file and an extra file
+	// the index doesn't know about.
+	seed := map[string]string{
+		"good":       contents["good"],
+		"dir/nested": contents["dir/nested"],
+		"corrupt":    "wrong data",
+		"present":    contents["present"],
+		"unknown":    "unknown data",
+	}
+
+	srcDir, err := ioutil.TempDir("", "syncthing-seed-")
+	must(t, err)
+	defer os.RemoveAll(srcDir)
+	for name, data := range seed {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		must(t, os.MkdirAll(filepath.Dir(path), 0755))
+		must(t, ioutil.WriteFile(path, []byte(data), 0644))
+	}
+
+	tarPath := filepath.Join(srcDir, "seed.tar.gz")
+	fd, err := os.Create(tarPath)
+	must(t, err)
+	gw := gzip.NewWriter(fd)
+	tw := tar.NewWriter(gw)
+	for name, data := range seed {
+		must(t, tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(data))
+		must(t, err)
+	}
+	must(t, tw.Close())
+	must(t, gw.Close())
+	must(t, fd.Close())
+
+	for _, source := range []string{srcDir, tarPath} {
+		os.RemoveAll(fcfg.Path)
+		must(t, os.MkdirAll(fcfg.Path, 0755))
+		must(t, ioutil.WriteFile(filepath.Join(fcfg.Path, "present"), []byte("local data!!"), 0644))
+
+		fset := db.NewFileSet(fcfg.ID, ffs, db.OpenMemory())
+		fset.Update(device1, files)
+
+		res, err := SeedFolder(fset, fcfg, source)
+		must(t, err)
+		if res.Imported != 2 {
+			t.Errorf("%s: imported %d files, expected 2", source, res.Imported)
+		}
+		if len(res.Skipped) != 2 || res.Skipped["corrupt"] != errSeedMismatch.Error() || res.Skipped["present"] != errSeedExists.Error() {
+			t.Errorf("%s: unexpected skipped files %v", source, res.Skipped)
+		}
+
+		for _, name := range []string{"good", "dir/nested"} {
+			name = filepath.FromSlash(name)
+			bs, err := ioutil.ReadFile(filepath.Join(fcfg.Path, name))
+			must(t, err)
+			if string(bs) != contents[filepath.ToSlash(name)] {
+				t.Errorf("%s: unexpected content %q for %s", source, bs, name)
+			}
+			f, ok := fset.Get(protocol.LocalDeviceID, name)
+			if !ok || !f.Version.Equal(version) {
+				t.Errorf("%s: %s not recorded as seeded: %v", source, name, f)
+			}
+			if info, err := ffs.Lstat(name); err != nil || info.ModTime().Unix() != 1234567890 {
+				t.Errorf("%s: %s has unexpected modification time", source, name)
+			}
+		}
+		for _, name := range []string{"corrupt", "not-in-seed", "unknown"} {
+			if _, err := ffs.Lstat(name); !os.IsNotExist(err) {
+				t.Errorf("%s: %s should not exist", source, name)
+			}
+			if _, ok := fset.Get(protocol.LocalDeviceID, name); ok {
+				t.Errorf("%s: %s should not be in the local index", source, name)
+			}
+		}
+		if bs, _ := ioutil.ReadFile(filepath.Join(fcfg.Path, "present")); string(bs) != "local data!!" {
+			t.Errorf("%s: existing file was overwritten", source)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// useSharedIgnores replaces the ignore patterns of the folder with those
+// announced by the given device, unless they are the same already.
+func (m *model) useSharedIgnores(folder string, deviceID protocol.DeviceID, patterns []string) {
+	current, _, err := m.GetIgnores(folder)
+	if err != nil {
+		l.Infof("Not using ignore patterns of device %v for folder %s: %v", deviceID, folder, err)
+		return
+	}
+	if equalLines(current, patterns) {
+		return
+	}
+
+	l.Infof("Using ignore patterns of device %v for folder %s", deviceID, folder)
+	if err := m.SetIgnores(folder, patterns); err != nil {
+		l.Warnf("Failed to use ignore patterns of device %v for folder %s: %v", deviceID, folder, err)
+	}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// setSharedMetadata announces the label, description and metadata of the
+// folder, along with which of them we keep local.
+func setSharedMetadata(folder *protocol.Folder, cfg config.FolderConfiguration) {
+	folder.SharesMetadata = true
+	if !cfg.MetadataChanged.IsZero() {
+		folder.MetadataChanged = cfg.MetadataChanged.UnixNano()
+	}
+	folder.LocalLabel = cfg.LocalLabel
+	folder.FolderDescription = cfg.FolderDescription
+	folder.LocalDescription = cfg.LocalDescription
+	folder.Metadata = make([]protocol.FolderMetadata, len(cfg.Metadata))
+	for i, md := range cfg.Metadata {
+		folder.Metadata[i] = protocol.FolderMetadata{
+			Key:   md.Key,
+			Value: md.Value,
+			Local: md.Local,
+		}
+	}
+}
+
+// useSharedMetadata takes on the label, description and metadata announced
+// by the given device, if they changed after ours. Those that either side
+// keeps local are left as they are.
+func (m *model) useSharedMetadata(deviceID protocol.DeviceID, folder protocol.Folder) {
+	cfg, ok := m.cfg.Folder(folder.ID)
+	if !ok || !cfg.ShareMetadata {
+		return
+	}
+	changed := time.Unix(0, folder.MetadataChanged)
+	if folder.MetadataChanged == 0 || !changed.After(cfg.MetadataChanged) {
+		return
+	}
+
+	if !cfg.LocalLabel && !folder.LocalLabel {
+		cfg.Label = folder.Label
+	}
+	if !cfg.LocalDescription && !folder.LocalDescription {
+		cfg.FolderDescription = folder.FolderDescription
+	}
+	cfg.Metadata = mergeMetadata(cfg.Metadata, folder.Metadata)
+	cfg.MetadataChanged = changed
+
+	l.Infof("Using label, description and metadata of device %v for folder %s", deviceID, cfg.Description())
+	if _, err := m.cfg.SetFolder(cfg); err != nil {
+		l.Warnf("Failed to use label, description and metadata of device %v for folder %s: %v", deviceID, cfg.Description(), err)
+		return
+	}
+	if err := m.cfg.Save(); err != nil {
+		l.Warnln("Failed to save config", err)
+	}
+}
+
+// mergeMetadata returns the entries announced by another device, except
+// that ours are kept for the keys we keep local or the other device does.
+// Other entries we have but the device doesn't are removed.
+func mergeMetadata(ours []config.FolderMetadata, theirs []protocol.FolderMetadata) []config.FolderMetadata {
+	keep := make(map[string]config.FolderMetadata, len(ours))
+	var merged []config.FolderMetadata
+	for _, md := range ours {
+		if md.Local {
+			merged = append(merged, md)
+		}
+		keep[md.Key] = md
+	}
+	seen := make(map[string]struct{}, len(theirs))
+	for _, md := range theirs {
+		if _, ok := seen[md.Key]; ok || md.Key == "" {
+			continue
+		}
+		seen[md.Key] = struct{}{}
+		own, ok := keep[md.Key]
+		switch {
+		case ok && own.Local:
+			// Already added above.
+		case md.Local:
+			if ok {
+				merged = append(merged, own)
+			}
+		default:
+			merged = append(merged, config.FolderMetadata{Key: md.Key, Value: md.Value})
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Key < merged[j].Key
+	})
+	return merged
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
@@ -33,6 +34,7 @@ type sharedPullerState struct {
 	curFile     protocol.FileInfo // The file as it exists now in our database
 	sparse      bool
 	created     time.Time
+	fset        *db.FileSet // For recording the complete blocks, if set
 
 	// Mutable, must be locked for access
 	err               error        // The first error we hit
@@ -47,6 +49,8 @@ type sharedPullerState struct {
 	closed            bool         // True if the file has been finalClosed.
 	available         []int32      // Indexes of the blocks that are available in the temporary file
 	availableUpdated  time.Time    // Time when list of available blocks was last updated
+	partialSaved      time.Time    // Time when the available blocks were last recorded in the database
+	partialRecorded   bool         // True if the database may have a record of the available blocks
 	mut               sync.RWMutex // Protects the above
 }
 
@@ -220,6 +224,9 @@ func (s *sharedPullerState) copyDone(block protocol.BlockInfo) {
 	s.updated = time.Now()
 	s.available = append(s.available, int32(block.Offset/int64(s.file.BlockSize())))
 	s.availableUpdated = time.Now()
+	if time.Since(s.partialSaved) >= partialSaveInterval {
+		s.savePartialLocked()
+	}
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "copyNeeded ->", s.copyNeeded)
 	s.mut.Unlock()
 }
@@ -256,6 +263,9 @@ func (s *sharedPullerState) pullDone(block protocol.BlockInfo) {
 	s.updated = time.Now()
 	s.available = append(s.available, int32(block.Offset/int64(s.file.BlockSize())))
 	s.availableUpdated = time.Now()
+	if time.Since(s.partialSaved) >= partialSaveInterval {
+		s.savePartialLocked()
+	}
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "pullNeeded done ->", s.pullNeeded)
 	s.mut.Unlock()
 }
@@ -285,6 +295,10 @@ func (s *sharedPullerState) finalClose() (bool, error) {
 		if fsyncErr := s.fd.Sync(); fsyncErr != nil && s.err == nil {
 			s.err = fsyncErr
 		}
+		if s.err != nil {
+			// Keep what we have for when we try again.
+			s.savePartialLocked()
+		}
 		if closeErr := s.fd.Close(); closeErr != nil && s.err == nil {
 			s.err = closeErr
 		}
@@ -293,6 +307,10 @@ func (s *sharedPullerState) finalClose() (bool, error) {
 
 	s.closed = true
 
+	if s.err == nil {
+		s.dropPartialLocked()
+	}
+
 	// Unhide the temporary file when we close it, as it's likely to
 	// immediately be renamed to the final name. If this is a failed temp
 	// file we will also unhide it, but I'm fine with that as we're now
@@ -0,0 +1,182 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// How often we look for folder shares that expired. Expired shares are
+// refused access right away, this is only about removing them from the
+// configuration.
+const shareExpiryInterval = time.Minute
+
+// shareExpirer periodically removes the devices whose share of a folder
+// expired from the folder.
+type shareExpirer struct {
+	model    *model
+	interval time.Duration
+	stop     chan struct{}
+}
+
+func newShareExpirer(m *model) *shareExpirer {
+	return &shareExpirer{
+		model:    m,
+		interval: shareExpiryInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *shareExpirer) Serve() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.model.retractExpiredShares(time.Now()); err != nil {
+				l.Warnln("Removing expired folder shares:", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *shareExpirer) Stop() {
+	close(s.stop)
+}
+
+func (s *shareExpirer) String() string {
+	return "shareExpirer"
+}
+
+// retractExpiredShares removes the devices whose share expired by now from
+// the folders, which closes their connections. Devices whose share is to be
+// purged on expiry get the folder added to their retracted folders, which
+// they are asked to remove in every cluster config from then on.
+func (m *model) retractExpiredShares(now time.Time) error {
+	raw := m.cfg.RawCopy()
+	changed := false
+	for i := range raw.Folders {
+		folder := &raw.Folders[i]
+		devices := folder.Devices[:0]
+		for _, dev := range folder.Devices {
+			if dev.DeviceID == m.id || !dev.Expired(now) {
+				devices = append(devices, dev)
+				continue
+			}
+			l.Infof("Share of folder %v with device %v expired", folder.Description(), dev.DeviceID)
+			changed = true
+			if !dev.PurgeOnExpiry {
+				continue
+			}
+			for j := range raw.Devices {
+				if raw.Devices[j].DeviceID == dev.DeviceID {
+					raw.Devices[j].RetractedFolders = append(raw.Devices[j].RetractedFolders, folder.ID)
+				}
+			}
+		}
+		folder.Devices = devices
+	}
+	if !changed {
+		return nil
+	}
+
+	waiter, err := m.cfg.Replace(raw)
+	if err != nil {
+		return err
+	}
+	waiter.Wait()
+	return m.cfg.Save()
+}
+
+// purgeRetractedFolders removes the folders, and their data, that the
+// device asks us to as their share with us expired. Only folders which we
+// share with the device, and which allow it, are removed.
+func (m *model) purgeRetractedFolders(deviceID protocol.DeviceID, folders []string) {
+	for _, folder := range folders {
+		cfg, ok := m.cfg.Folder(folder)
+		if !ok {
+			continue
+		}
+		if !cfg.AllowRemotePurge || !cfg.SharedWith(deviceID) {
+			l.Debugf("Not removing folder %v as requested by %v", cfg.Description(), deviceID)
+			continue
+		}
+
+		l.Infof("Removing folder %v and its data, as its share by device %v expired", cfg.Description(), deviceID)
+		// The index is gone with the folder, so get its files first.
+		names := m.localFileNames(folder)
+		if err := m.removeFolderConfig(folder); err != nil {
+			l.Warnf("Removing folder %v: %v", cfg.Description(), err)
+			continue
+		}
+		if err := removeIndexedFiles(cfg, names); err != nil {
+			l.Warnf("Deleting data of removed folder %v: %v", cfg.Description(), err)
+		}
+	}
+}
+
+// localFileNames returns the names of the files, directories and symlinks
+// we have in the folder.
+func (m *model) localFileNames(folder string) []string {
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil
+	}
+	var names []string
+	fset.WithHaveTruncated(protocol.LocalDeviceID, func(fi db.FileIntf) bool {
+		if !fi.IsDeleted() && !fi.IsInvalid() {
+			names = append(names, fi.FileName())
+		}
+		return true
+	})
+	return names
+}
+
+// removeIndexedFiles deletes the named files from the folder, and then the
+// directories left empty. Anything which isn't synced, such as ignored
+// files, is left alone, as are the directories containing it.
+func removeIndexedFiles(cfg config.FolderConfiguration, names []string) error {
+	ffs := cfg.Filesystem()
+	// Children sort after their parents, so in reverse order directories
+	// are emptied before we get to them.
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	failed := 0
+	for _, name := range names {
+		info, err := ffs.Lstat(name)
+		if fs.IsNotExist(err) {
+			continue
+		}
+		if err == nil && info.IsDir() {
+			if children, err := ffs.DirNames(name); err != nil || len(children) > 0 {
+				// Holds something we don't know about.
+				continue
+			}
+		}
+		if err == nil {
+			err = ffs.Remove(name)
+		}
+		if err != nil {
+			l.Debugf("Deleting %v in removed folder %v: %v", name, cfg.Description(), err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d items could not be deleted", failed)
+	}
+	return nil
+}
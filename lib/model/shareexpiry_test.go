@@ -0,0 +1,121 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+func TestShareExpiry(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	w.SetDevice(config.NewDeviceConfiguration(device2, "device2"))
+	fcfg.Devices = append(fcfg.Devices, config.FolderDeviceConfiguration{
+		DeviceID:      device2,
+		ExpiresAt:     time.Now().Add(-time.Second),
+		PurgeOnExpiry: true,
+	})
+	w.SetFolder(fcfg)
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	if cfg, _ := w.Folder(fcfg.ID); cfg.SharedWith(device2) {
+		t.Error("Expired share counts as shared")
+	}
+	if cm := m.generateClusterConfig(device2); len(cm.Folders) != 0 {
+		t.Errorf("Folder announced to device with expired share: %v", cm.Folders)
+	}
+	cm := m.generateClusterConfig(device1)
+	if len(cm.Folders) != 1 {
+		t.Fatalf("Expected one folder, got %v", cm.Folders)
+	}
+	for _, dev := range cm.Folders[0].Devices {
+		if dev.ID == device2 {
+			t.Error("Device with expired share announced to others")
+		}
+	}
+
+	if err := m.retractExpiredShares(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := w.Folder(fcfg.ID)
+	for _, dev := range cfg.Devices {
+		if dev.DeviceID == device2 {
+			t.Error("Device with expired share not removed from folder")
+		}
+	}
+	if !cfg.SharedWith(device1) {
+		t.Error("Device without expiry removed from folder")
+	}
+	if cm := m.generateClusterConfig(device2); len(cm.PurgeFolders) != 1 || cm.PurgeFolders[0] != fcfg.ID {
+		t.Errorf("Expected device to be asked to purge %v, got %v", fcfg.ID, cm.PurgeFolders)
+	}
+	if cm := m.generateClusterConfig(device1); len(cm.PurgeFolders) != 0 {
+		t.Errorf("Unexpected purge request %v", cm.PurgeFolders)
+	}
+}
+
+func TestPurgeRetractedFolders(t *testing.T) {
+	w, fcfg := tmpDefaultWrapper()
+	m := setupModel(w)
+	defer func() {
+		m.Stop()
+		os.RemoveAll(fcfg.Filesystem().URI())
+		os.Remove(w.ConfigPath())
+	}()
+
+	root := fcfg.Filesystem().URI()
+	name := filepath.Join(root, "dir", "file")
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ScanFolder(fcfg.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Not in the index, so not ours to delete.
+	untracked := filepath.Join(root, "untracked")
+	if err := ioutil.WriteFile(untracked, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m.purgeRetractedFolders(device1, []string{fcfg.ID})
+	if _, ok := w.Folder(fcfg.ID); !ok {
+		t.Fatal("Folder removed without allowing remote purge")
+	}
+
+	fcfg.AllowRemotePurge = true
+	waiter, _ := w.SetFolder(fcfg)
+	waiter.Wait()
+
+	m.purgeRetractedFolders(device2, []string{fcfg.ID})
+	if _, ok := w.Folder(fcfg.ID); !ok {
+		t.Fatal("Folder removed as requested by a device not sharing it")
+	}
+
+	m.purgeRetractedFolders(device1, []string{fcfg.ID})
+	if _, ok := w.Folder(fcfg.ID); ok {
+		t.Error("Folder not removed")
+	}
+	if _, err := os.Stat(filepath.Dir(name)); !os.IsNotExist(err) {
+		t.Errorf("Folder data not deleted: %v", err)
+	}
+	if _, err := os.Stat(untracked); err != nil {
+		t.Errorf("Untracked file deleted: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// spaceError is the folder error when pulling what's needed doesn't fit on
+// the disk, even once the replaced and deleted files are gone.
+type spaceError struct {
+	uri  string
+	need int64
+}
+
+func (e *spaceError) Error() string {
+	return fmt.Sprintf("insufficient space in %v, need %d bytes more", e.uri, e.need)
+}
+
+// spacePlan is the space pulling what's needed takes on the disk.
+type spacePlan struct {
+	peak int64 // the temporary files written, before anything is freed
+	net  int64 // the peak, less the files replaced and deleted
+}
+
+// planSpace returns the space pulling what's needed takes, going by the
+// same rules as the puller. The temporary files already there count as
+// written.
+func (f *sendReceiveFolder) planSpace() spacePlan {
+	var plan spacePlan
+	tempFs := f.tempFilesystem()
+	f.fset.WithNeed(protocol.LocalDeviceID, func(intf db.FileIntf) bool {
+		file := intf.(protocol.FileInfo)
+		if f.shouldIgnore(file) || (f.IgnoreDelete && file.IsDeleted()) {
+			return true
+		}
+		if !file.IsDeleted() && file.Type != protocol.FileInfoTypeFile {
+			return true
+		}
+
+		cur, hasCur := f.fset.Get(protocol.LocalDeviceID, file.Name)
+		hasCur = hasCur && !cur.IsDeleted() && !cur.IsInvalid() && cur.Type == protocol.FileInfoTypeFile
+		if hasCur {
+			plan.net -= cur.Size
+		}
+		if file.IsDeleted() {
+			return true
+		}
+		if _, need := blockDiff(cur.Blocks, file.Blocks); hasCur && len(need) == 0 {
+			// Only the metadata changes.
+			plan.net += cur.Size
+			return true
+		}
+
+		write := file.Size
+		if info, err := tempFs.Lstat(f.TempName(file.Name)); err == nil {
+			write -= info.Size()
+		}
+		if write > 0 {
+			plan.peak += write
+			plan.net += write
+		}
+		return true
+	})
+	return plan
+}
+
+// checkSpace returns whether the files to delete have to be deleted before
+// the others are pulled to make room, and an error if even then there isn't
+// enough.
+func (f *sendReceiveFolder) checkSpace() (bool, error) {
+	ffs := f.Filesystem()
+	usage, err := ffs.Usage(".")
+	if err != nil {
+		return false, nil
+	}
+	available := usage.Free
+	if reserve := f.MinDiskFree.BaseValue(); f.MinDiskFree.Percentage() {
+		available -= int64(reserve * float64(usage.Total) / 100)
+	} else {
+		available -= int64(reserve)
+	}
+
+	plan := f.planSpace()
+	l.Debugf("%v needs %d bytes at peak, %d net, of %d available", f, plan.peak, plan.net, available)
+	switch {
+	case plan.peak <= available:
+		return false, nil
+	case plan.net <= available:
+		return true, nil
+	default:
+		return false, &spaceError{uri: ffs.URI(), need: plan.net - available}
+	}
+}
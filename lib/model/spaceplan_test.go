@@ -0,0 +1,52 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestPlanSpace(t *testing.T) {
+	m, f := setupSendReceiveFolder(
+		protocol.FileInfo{Name: "replaced", Size: 100, Blocks: blocks[1:2]},
+		protocol.FileInfo{Name: "deleted", Size: 50, Blocks: blocks[2:3]},
+		protocol.FileInfo{Name: "touched", Size: 30, Blocks: blocks[3:4]},
+	)
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.RemoveAll(f.Filesystem().URI())
+	}()
+
+	f.ignores = ignore.New(f.fs)
+
+	remote := protocol.Vector{}.Update(device1.Short())
+	f.fset.Update(device1, []protocol.FileInfo{
+		{Name: "replaced", Size: 300, Blocks: blocks[4:5], Version: remote},
+		{Name: "deleted", Deleted: true, Version: remote},
+		{Name: "touched", Size: 30, Blocks: blocks[3:4], Version: remote, Permissions: 0600},
+		{Name: "new", Size: 200, Blocks: blocks[5:6], Version: remote},
+	})
+
+	// Part of the new file is already there.
+	fd, err := f.fs.Create(f.TempName("new"))
+	must(t, err)
+	_, err = fd.Write(make([]byte, 80))
+	must(t, err)
+	fd.Close()
+
+	plan := f.planSpace()
+	if plan.peak != 300+120 {
+		t.Errorf("Peak is %d, expected %d", plan.peak, 300+120)
+	}
+	if plan.net != 300+120-100-50 {
+		t.Errorf("Net is %d, expected %d", plan.net, 300+120-100-50)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// tempFilesystem returns the filesystem where the temporary files of pulled
+// files are, the TempDir if set.
+func (f *sendReceiveFolder) tempFilesystem() fs.Filesystem {
+	if f.tempFs == nil {
+		return f.fs
+	}
+	return f.tempFs
+}
+
+// prepareTempDir creates the TempDir of the folder, if set, and removes the
+// temporary files in it older than the configured lifetime. Those are left
+// behind by files that are no longer needed, and the scanner, which removes
+// them in the folder itself, never sees the TempDir.
+func (f *sendReceiveFolder) prepareTempDir() error {
+	if f.tempFs == nil {
+		return nil
+	}
+	if err := f.tempFs.MkdirAll(".", 0700); err != nil {
+		return errors.Wrap(err, "creating temp dir")
+	}
+
+	names, err := f.tempFs.DirNames(".")
+	if err != nil {
+		return errors.Wrap(err, "reading temp dir")
+	}
+	lifetime := time.Duration(f.model.cfg.Options().KeepTemporariesH) * time.Hour
+	for _, name := range names {
+		if !fs.IsTemporary(name) || fs.IsPartial(name) {
+			continue
+		}
+		if info, err := f.tempFs.Lstat(name); err == nil && info.IsRegular() && info.ModTime().Add(lifetime).Before(time.Now()) {
+			l.Debugln(f, "removing temporary:", name, info.ModTime())
+			f.tempFs.Remove(name)
+		}
+	}
+	return nil
+}
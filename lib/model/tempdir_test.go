@@ -0,0 +1,89 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestTempDir(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.Remove(f.Filesystem().URI())
+	}()
+
+	dir, err := ioutil.TempDir("", "syncthing-tempdir-")
+	must(t, err)
+	defer os.RemoveAll(dir)
+	f.TempDir = filepath.Join(dir, "staging")
+	f.tempFs = f.TempFilesystem()
+
+	// A stale temporary file is removed, others are left alone.
+	must(t, f.prepareTempDir())
+	stale := filepath.Join(f.TempDir, fs.TempName("stale"))
+	other := filepath.Join(f.TempDir, "other")
+	for _, name := range []string{stale, other} {
+		must(t, ioutil.WriteFile(name, nil, 0644))
+		old := time.Now().Add(-48 * time.Hour)
+		must(t, os.Chtimes(name, old, old))
+	}
+	must(t, f.prepareTempDir())
+	if _, err := os.Lstat(stale); !os.IsNotExist(err) {
+		t.Error("Stale temporary file not removed")
+	}
+	if _, err := os.Lstat(other); err != nil {
+		t.Error("Other file removed:", err)
+	}
+
+	file := setupFile("file", []int{1})
+	file.Version = protocol.Vector{}.Update(device1.Short())
+	file.Permissions = 0600
+
+	copyChan := make(chan copyBlocksState, 1)
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	f.handleFile(file, copyChan, dbUpdateChan)
+	state := (<-copyChan).sharedPullerState
+	if state.fs != f.tempFs || state.tempName != f.TempName("file") || filepath.Dir(state.tempName) != "." {
+		t.Fatalf("Unexpected temporary file %v in %v", state.tempName, state.fs.URI())
+	}
+
+	wr, err := state.tempFile()
+	must(t, err)
+	_, err = wr.WriteAt([]byte("contents"), 0)
+	must(t, err)
+	state.copyDone(file.Blocks[0])
+	if closed, err := state.finalClose(); !closed || err != nil {
+		t.Fatal("Unexpected close result", closed, err)
+	}
+	if _, err := f.fs.Lstat(state.tempName); !fs.IsNotExist(err) {
+		t.Error("Temporary file created in the folder")
+	}
+
+	scanChan := make(chan string, 1)
+	must(t, f.performFinish(state.file, state.curFile, state.hasCurFile, state.fs, state.tempName, dbUpdateChan, scanChan))
+	<-dbUpdateChan
+	info, err := f.fs.Lstat("file")
+	must(t, err)
+	if info.Mode()&0777 != 0600 {
+		t.Errorf("Unexpected permissions %v", info.Mode())
+	}
+	if _, err := f.tempFs.Lstat(state.tempName); !fs.IsNotExist(err) {
+		t.Error("Temporary file left in the temp dir")
+	}
+	// It was moved next to the file and renamed into place from there.
+	if _, err := f.fs.Lstat(fs.TempName("file")); !fs.IsNotExist(err) {
+		t.Error("Temporary file left in the folder")
+	}
+}
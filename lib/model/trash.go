@@ -0,0 +1,137 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+// A DeletedFile is a file that no longer exists in the folder, as known
+// from its tombstone in the global index, from the versions kept of it, or
+// both.
+type DeletedFile struct {
+	Name string `json:"name"`
+	// Deleted is when the newest version was archived, which is when the
+	// file was deleted or last replaced. It is zero without versions.
+	Deleted time.Time `json:"deleted"`
+	// ModTime is the last modification time from the tombstone, zero
+	// without one, as archiving resets that of the versions. Size is that
+	// of the newest version.
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+	// DeletedBy is the device that announced the deletion, if there is a
+	// tombstone and the device is known.
+	DeletedBy string                  `json:"deletedBy,omitempty"`
+	InIndex   bool                    `json:"inIndex"`
+	Versions  []versioner.FileVersion `json:"versions"`
+}
+
+// DeletedFiles lists the deleted files of the folder, newest deletion
+// first. The search string matches case insensitively anywhere in the
+// name. A non-zero since time only returns files with a version archived
+// at or after it.
+func (m *model) DeletedFiles(folder string, since time.Time, search string) ([]DeletedFile, error) {
+	cfg, ok := m.cfg.Folder(folder)
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	m.fmut.RLock()
+	fset, ok := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, ErrFolderPaused
+	}
+
+	var versions map[string][]versioner.FileVersion
+	if ver := cfg.Versioner(); ver != nil {
+		var err error
+		if versions, err = ver.GetVersions(); err != nil {
+			return nil, err
+		}
+	}
+
+	devices := make(map[protocol.ShortID]protocol.DeviceID)
+	for id := range m.cfg.Devices() {
+		devices[id.Short()] = id
+	}
+
+	return deletedFiles(fset, versions, devices, since, search), nil
+}
+
+func deletedFiles(fset *db.FileSet, versions map[string][]versioner.FileVersion, devices map[protocol.ShortID]protocol.DeviceID, since time.Time, search string) []DeletedFile {
+	search = strings.ToLower(search)
+	files := make(map[string]*DeletedFile)
+	present := make(map[string]struct{})
+
+	fset.WithGlobalTruncated(func(fi db.FileIntf) bool {
+		f := fi.(db.FileInfoTruncated)
+		name := osutil.NormalizedFilename(f.Name)
+		if !f.IsDeleted() {
+			present[name] = struct{}{}
+			return true
+		}
+		if f.IsDirectory() || f.IsInvalid() {
+			return true
+		}
+		df := &DeletedFile{
+			Name:    f.Name,
+			ModTime: f.ModTime(),
+			InIndex: true,
+		}
+		if id, ok := devices[f.ModifiedBy]; ok {
+			df.DeletedBy = id.String()
+		} else if f.ModifiedBy != 0 {
+			df.DeletedBy = f.ModifiedBy.String()
+		}
+		files[name] = df
+		return true
+	})
+
+	for name, vs := range versions {
+		if _, ok := present[name]; ok || len(vs) == 0 {
+			continue
+		}
+		df, ok := files[name]
+		if !ok {
+			df = &DeletedFile{Name: name}
+			files[name] = df
+		}
+		df.Versions = vs
+		for _, v := range vs {
+			if v.VersionTime.After(df.Deleted) {
+				df.Deleted = v.VersionTime
+				df.Size = v.Size
+			}
+		}
+	}
+
+	res := make([]DeletedFile, 0, len(files))
+	for _, df := range files {
+		if search != "" && !strings.Contains(strings.ToLower(df.Name), search) {
+			continue
+		}
+		if !since.IsZero() && df.Deleted.Before(since) {
+			continue
+		}
+		res = append(res, *df)
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if !res[a].Deleted.Equal(res[b].Deleted) {
+			return res[a].Deleted.After(res[b].Deleted)
+		}
+		return res[a].Name < res[b].Name
+	})
+	return res
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+func TestDeletedFiles(t *testing.T) {
+	fset := db.NewFileSet("default", fs.NewFilesystem(fs.FilesystemTypeFake, ""), db.OpenMemory())
+	version := protocol.Vector{}.Update(device1.Short())
+	fset.Update(device1, []protocol.FileInfo{
+		{Name: "present", Version: version, Size: 10},
+		{Name: "dir", Type: protocol.FileInfoTypeDirectory, Deleted: true, Version: version},
+		{Name: "Report.txt", Deleted: true, Version: version, ModifiedBy: device1.Short(), ModifiedS: 1000},
+		{Name: "old", Deleted: true, Version: version, ModifiedBy: device1.Short()},
+		{Name: "unversioned", Deleted: true, Version: version, ModifiedS: 2000},
+	})
+
+	now := time.Now().Truncate(time.Second)
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	versions := map[string][]versioner.FileVersion{
+		"present": {{VersionTime: now, Size: 5}},
+		"Report.txt": {
+			{VersionTime: now.Add(-2 * time.Hour), ModTime: now.Add(-3 * time.Hour), Size: 1},
+			{VersionTime: now.Add(-time.Hour), ModTime: now.Add(-2 * time.Hour), Size: 2},
+		},
+		"old":             {{VersionTime: lastWeek.Add(-time.Hour), Size: 3}},
+		"deleted-locally": {{VersionTime: now.Add(-30 * time.Minute), Size: 4}},
+	}
+	devices := map[protocol.ShortID]protocol.DeviceID{device1.Short(): device1}
+
+	files := deletedFiles(fset, versions, devices, time.Time{}, "")
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name
+	}
+	expected := []string{"deleted-locally", "Report.txt", "old", "unversioned"}
+	if len(names) != len(expected) {
+		t.Fatalf("Got %v, expected %v", names, expected)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Got %v, expected %v", names, expected)
+		}
+	}
+
+	report := files[1]
+	if !report.InIndex || report.DeletedBy != device1.String() || report.Size != 2 || len(report.Versions) != 2 {
+		t.Errorf("Unexpected entry %+v", report)
+	}
+	if !report.Deleted.Equal(now.Add(-time.Hour)) || report.ModTime.Unix() != 1000 {
+		t.Errorf("Entry deleted at %v, modified at %v; expected the newest version time and the tombstone modification time", report.Deleted, report.ModTime)
+	}
+	if deleted := files[0]; !deleted.Deleted.Equal(now.Add(-30*time.Minute)) || !deleted.ModTime.IsZero() {
+		t.Errorf("Entry without tombstone deleted at %v, modified at %v", deleted.Deleted, deleted.ModTime)
+	}
+	if files[0].InIndex || files[0].DeletedBy != "" {
+		t.Errorf("Unexpected entry %+v", files[0])
+	}
+	if unversioned := files[3]; !unversioned.Deleted.IsZero() || unversioned.ModTime.Unix() != 2000 {
+		t.Errorf("Unexpected entry %+v", unversioned)
+	}
+
+	files = deletedFiles(fset, versions, devices, lastWeek, "REPORT")
+	if len(files) != 1 || files[0].Name != "Report.txt" {
+		t.Errorf("Unexpected filtered result %+v", files)
+	}
+	if files = deletedFiles(fset, versions, devices, lastWeek, ""); len(files) != 2 {
+		t.Errorf("Expected two files deleted in the last week, got %+v", files)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+)
+
+var (
+	errNoKeptPartial     = errors.New("no kept partial file")
+	errPartialNotOffered = errors.New("the file is not offered by any device")
+)
+
+// A KeptPartial is the data pulled so far of a file that was deleted on all
+// other devices before it was complete. Pulling it resumes from there when
+// a device offers the file again.
+type KeptPartial struct {
+	Name     string    `json:"name"`
+	Blocks   int       `json:"blocks"` // The complete blocks
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Offered  bool      `json:"offered"` // A device offers the file again, so that it can be resumed
+}
+
+// handleVanished applies the vanished policy of the folder to the
+// temporary files of files that were deleted everywhere else since they
+// were being pulled, and returns the names of the promoted files.
+func (f *sendReceiveFolder) handleVanished() []string {
+	type partial struct {
+		name   string
+		record db.PartialFile
+	}
+	var partials []partial
+	f.fset.ListPartialFiles(func(name string, record db.PartialFile) bool {
+		partials = append(partials, partial{name, record})
+		return true
+	})
+
+	tempFs := f.tempFilesystem()
+	var promoted []string
+	for _, p := range partials {
+		if global, ok := f.fset.GetGlobal(p.name); ok && !global.IsDeleted() {
+			continue
+		}
+		tempName := f.TempName(p.name)
+		if _, err := tempFs.Lstat(tempName); err != nil {
+			if _, err := tempFs.Lstat(fs.PartialName(tempName)); err != nil {
+				// Nothing left of the file.
+				f.fset.DropPartialFile(p.name)
+			}
+			// Otherwise it was kept already.
+			continue
+		}
+
+		switch f.VanishedPolicy {
+		case config.VanishedKeep:
+			l.Infof("Keeping the data pulled so far of %s in folder %v, which was deleted on the other devices", p.name, f.Description())
+			if err := tempFs.Rename(tempName, fs.PartialName(tempName)); err != nil {
+				l.Infof("Keeping partial file %s: %v", p.name, err)
+			}
+		case config.VanishedPromote:
+			name := partialCopyName(p.name)
+			l.Infof("Keeping the data pulled so far of %s in folder %v, which was deleted on the other devices, as %s", p.name, f.Description(), name)
+			if err := f.promotePartial(tempFs, tempName, name); err != nil {
+				l.Infof("Promoting partial file %s: %v", p.name, err)
+				continue
+			}
+			f.fset.DropPartialFile(p.name)
+			promoted = append(promoted, name)
+		default:
+			l.Debugf("%v discarding temporary file of vanished %s", f, p.name)
+			tempFs.Remove(tempName)
+			f.fset.DropPartialFile(p.name)
+		}
+	}
+	return promoted
+}
+
+func (f *sendReceiveFolder) promotePartial(tempFs fs.Filesystem, tempName, name string) error {
+	if err := f.fs.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return osutil.RenameOrCopy(tempFs, f.fs, tempName, name)
+}
+
+// restorePartial makes a kept partial file the temporary file again, so
+// that its blocks are reused.
+func (f *sendReceiveFolder) restorePartial(tempFs fs.Filesystem, tempName string) {
+	if _, err := tempFs.Lstat(tempName); err == nil {
+		return
+	}
+	if err := tempFs.Rename(fs.PartialName(tempName), tempName); err == nil {
+		l.Debugf("%v resuming from kept partial file %s", f, tempName)
+	}
+}
+
+// partialCopyName is the name of a promoted partial file, next to the file
+// it was for.
+func partialCopyName(name string) string {
+	ext := filepath.Ext(name)
+	return name[:len(name)-len(ext)] + time.Now().Format(".sync-partial-20060102-150405") + ext
+}
+
+// KeptPartials returns the kept partial files of the folder, by name.
+func (m *model) KeptPartials(folder string) ([]KeptPartial, error) {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	fset := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return nil, errFolderMissing
+	}
+
+	tempFs := cfg.TempFilesystem()
+	var partials []KeptPartial
+	fset.ListPartialFiles(func(name string, record db.PartialFile) bool {
+		info, err := tempFs.Lstat(fs.PartialName(cfg.TempName(name)))
+		if err != nil {
+			return true
+		}
+		partials = append(partials, KeptPartial{
+			Name:     name,
+			Blocks:   len(record.Blocks),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return true
+	})
+	for i := range partials {
+		global, ok := fset.GetGlobal(partials[i].Name)
+		partials[i].Offered = ok && !global.IsDeleted()
+	}
+	sort.Slice(partials, func(a, b int) bool {
+		return partials[a].Name < partials[b].Name
+	})
+	return partials, nil
+}
+
+// ResumePartial pulls the file of a kept partial file first, which a device
+// must offer again.
+func (m *model) ResumePartial(folder, file string) error {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	fset := m.folderFiles[folder]
+	runner := m.folderRunners[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+	if _, err := cfg.TempFilesystem().Lstat(fs.PartialName(cfg.TempName(file))); err != nil {
+		return errNoKeptPartial
+	}
+	if global, ok := fset.GetGlobal(file); !ok || global.IsDeleted() {
+		return errPartialNotOffered
+	}
+	if runner == nil {
+		return errFolderNotRunning
+	}
+	runner.BringToFront(file)
+	runner.SchedulePull()
+	return nil
+}
+
+// DiscardPartial removes a kept partial file.
+func (m *model) DiscardPartial(folder, file string) error {
+	m.fmut.RLock()
+	cfg, ok := m.folderCfgs[folder]
+	fset := m.folderFiles[folder]
+	m.fmut.RUnlock()
+	if !ok {
+		return errFolderMissing
+	}
+	tempFs := cfg.TempFilesystem()
+	name := fs.PartialName(cfg.TempName(file))
+	if _, err := tempFs.Lstat(name); err != nil {
+		return errNoKeptPartial
+	}
+	if err := tempFs.Remove(name); err != nil {
+		return err
+	}
+	fset.DropPartialFile(file)
+	return nil
+}
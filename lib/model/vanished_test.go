@@ -0,0 +1,93 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestHandleVanished(t *testing.T) {
+	m, f := setupSendReceiveFolder()
+	defer func() {
+		os.Remove(m.cfg.ConfigPath())
+		os.RemoveAll(f.Filesystem().URI())
+	}()
+
+	dir := f.Filesystem().URI()
+	tempName := f.TempName("file")
+	vanish := func(policy config.VanishedPolicy) []string {
+		t.Helper()
+		must(t, ioutil.WriteFile(filepath.Join(dir, tempName), []byte("pulled"), 0644))
+		f.fset.SetPartialFile("file", db.PartialFile{
+			Version:   protocol.Vector{}.Update(device1.Short()),
+			BlockSize: protocol.MinBlockSize,
+			Blocks:    []int32{0},
+		})
+		f.VanishedPolicy = policy
+		return f.handleVanished()
+	}
+
+	vanish(config.VanishedDiscard)
+	if _, err := f.fs.Lstat(tempName); !fs.IsNotExist(err) {
+		t.Error("Temporary file not discarded:", err)
+	}
+	if _, ok := f.fset.PartialFile("file"); ok {
+		t.Error("Record of discarded file not dropped")
+	}
+
+	vanish(config.VanishedKeep)
+	f.handleVanished()
+	if _, err := f.fs.Lstat(fs.PartialName(tempName)); err != nil {
+		t.Fatal("Partial file not kept:", err)
+	}
+	partials, err := m.KeptPartials("default")
+	must(t, err)
+	if len(partials) != 1 || partials[0].Name != "file" || partials[0].Blocks != 1 || partials[0].Size != 6 || partials[0].Offered {
+		t.Errorf("Unexpected kept partials %+v", partials)
+	}
+	if err := m.ResumePartial("default", "file"); err != errPartialNotOffered {
+		t.Error("Expected the file not to be offered, got", err)
+	}
+
+	// Pulling the file again resumes from the kept data.
+	file := setupFile("file", []int{1})
+	file.Version = protocol.Vector{}.Update(device1.Short())
+	f.fset.Update(device1, []protocol.FileInfo{file})
+	if partials, _ := m.KeptPartials("default"); len(partials) != 1 || !partials[0].Offered {
+		t.Errorf("Unexpected kept partials %+v", partials)
+	}
+	copyChan := make(chan copyBlocksState, 1)
+	f.handleFile(file, copyChan, make(chan dbUpdateJob, 1))
+	<-copyChan
+	if _, err := f.fs.Lstat(fs.PartialName(tempName)); !fs.IsNotExist(err) {
+		t.Error("Kept partial file not resumed from:", err)
+	}
+	if err := m.DiscardPartial("default", "file"); err != errNoKeptPartial {
+		t.Error("Expected no kept partial, got", err)
+	}
+	f.fset.Update(device1, []protocol.FileInfo{{Name: "file", Deleted: true, Version: file.Version.Update(device1.Short())}})
+
+	promoted := vanish(config.VanishedPromote)
+	if len(promoted) != 1 || !strings.HasPrefix(promoted[0], "file.sync-partial-") {
+		t.Fatalf("Unexpected promoted files %v", promoted)
+	}
+	if err := equalContents(filepath.Join(dir, promoted[0]), []byte("pulled")); err != nil {
+		t.Error("Promoted file:", err)
+	}
+	if _, err := f.fs.Lstat(tempName); !fs.IsNotExist(err) {
+		t.Error("Temporary file of promoted file left:", err)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Just enough of MQTT 3.1.1 to publish messages at QoS 0.
+
+const (
+	packetConnect    = 1
+	packetConnAck    = 2
+	packetPublish    = 3
+	packetPingReq    = 12
+	packetPingResp   = 13
+	packetDisconnect = 14
+
+	protocolLevel = 4 // MQTT 3.1.1
+
+	flagCleanSession = 0x02
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+	flagRetain       = 0x01
+
+	maxRemainingLength = 268435455
+)
+
+var connAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+type dialOptions struct {
+	address            string
+	clientID           string
+	username           string
+	password           string
+	keepAlive          time.Duration
+	timeout            time.Duration
+	insecureSkipVerify bool
+}
+
+// A client is a connection to a broker. Publish and ping may be called
+// concurrently; received packets are consumed by readLoop.
+type client struct {
+	conn    net.Conn
+	timeout time.Duration
+	wmut    sync.Mutex
+}
+
+func dial(opts dialOptions) (*client, error) {
+	u, err := url.Parse(opts.address)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: opts.timeout}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", hostPort(u, "1883"))
+	case "tls", "ssl", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "8883"), &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: opts.insecureSkipVerify,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported broker address scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{
+		conn:    conn,
+		timeout: opts.timeout,
+		wmut:    sync.NewMutex(),
+	}
+	if err := c.connect(opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return u.Host
+}
+
+func (c *client) connect(opts dialOptions) error {
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, protocolLevel)
+	flags := byte(flagCleanSession)
+	if opts.username != "" {
+		flags |= flagUsername
+		if opts.password != "" {
+			flags |= flagPassword
+		}
+	}
+	body = append(body, flags)
+	body = appendUint16(body, uint16(opts.keepAlive/time.Second))
+	body = appendString(body, opts.clientID)
+	if flags&flagUsername != 0 {
+		body = appendString(body, opts.username)
+	}
+	if flags&flagPassword != 0 {
+		body = appendString(body, opts.password)
+	}
+	if err := c.write(packetConnect<<4, body); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	typ, resp, err := readPacket(bufio.NewReader(c.conn))
+	if err != nil {
+		return err
+	}
+	if typ != packetConnAck || len(resp) != 2 {
+		return errors.New("unexpected response to connect")
+	}
+	if code := resp[1]; code != 0 {
+		if msg, ok := connAckErrors[code]; ok {
+			return errors.New("connection refused: " + msg)
+		}
+		return fmt.Errorf("connection refused: code %d", code)
+	}
+	return nil
+}
+
+func (c *client) publish(topic string, payload []byte, retain bool) error {
+	header := byte(packetPublish << 4)
+	if retain {
+		header |= flagRetain
+	}
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+	return c.write(header, body)
+}
+
+func (c *client) ping() error {
+	return c.write(packetPingReq<<4, nil)
+}
+
+// close disconnects cleanly from the broker.
+func (c *client) close() {
+	c.write(packetDisconnect<<4, nil)
+	c.conn.Close()
+}
+
+// readLoop consumes packets from the broker until the connection fails,
+// returning the error. Pings must be answered within twice the keepalive
+// interval.
+func (c *client) readLoop(keepAlive time.Duration) error {
+	r := bufio.NewReader(c.conn)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(2 * keepAlive))
+		typ, _, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+		if typ != packetPingResp {
+			l.Debugln("Ignoring unexpected packet of type", typ)
+		}
+	}
+}
+
+func (c *client) write(header byte, body []byte) error {
+	if len(body) > maxRemainingLength {
+		return errors.New("packet too large")
+	}
+	pkt := make([]byte, 0, len(body)+5)
+	pkt = append(pkt, header)
+	pkt = appendLength(pkt, len(body))
+	pkt = append(pkt, body...)
+
+	c.wmut.Lock()
+	defer c.wmut.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	length, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			return length, nil
+		}
+	}
+	return 0, errors.New("malformed remaining length")
+}
+
+func appendLength(bs []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		bs = append(bs, b)
+		if n == 0 {
+			return bs
+		}
+	}
+}
+
+func appendUint16(bs []byte, v uint16) []byte {
+	var buf [2]byte
+	binary.BigEndian.PutUint16(buf[:], v)
+	return append(bs, buf[:]...)
+}
+
+func appendString(bs []byte, s string) []byte {
+	bs = appendUint16(bs, uint16(len(s)))
+	return append(bs, s...)
+}
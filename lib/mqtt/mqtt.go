@@ -0,0 +1,249 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package mqtt publishes events to an MQTT broker. Each event is published
+// as the JSON of an events.Event, at QoS 0, to a topic derived from the
+// configured template.
+package mqtt
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const (
+	queueSize      = 256 // events waiting before we start dropping
+	dialTimeout    = 10 * time.Second
+	keepAlive      = time.Minute
+	minRetryDelay  = 5 * time.Second
+	maxRetryDelay  = 5 * time.Minute
+	clientIDPrefix = "syncthing-"
+	topicDeviceVar = "{device}"
+	topicEventVar  = "{event}"
+)
+
+// Service publishes events to the configured MQTT broker.
+type Service struct {
+	cfg     config.Wrapper
+	myID    protocol.DeviceID
+	changed chan struct{}
+	stop    chan struct{}
+}
+
+func New(cfg config.Wrapper, myID protocol.DeviceID) *Service {
+	s := &Service{
+		cfg:     cfg,
+		myID:    myID,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	cfg.Subscribe(s)
+	return s
+}
+
+func (s *Service) Serve() {
+	p := s.startPublisher(s.cfg.RawCopy().MQTT)
+	sub := p.subscribe()
+	defer func() {
+		events.Default.Unsubscribe(sub)
+		p.Stop()
+	}()
+
+	for {
+		select {
+		case ev := <-sub.C():
+			p.offer(ev)
+		case <-s.changed:
+			events.Default.Unsubscribe(sub)
+			p.Stop()
+			p = s.startPublisher(s.cfg.RawCopy().MQTT)
+			sub = p.subscribe()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	close(s.stop)
+}
+
+func (s *Service) VerifyConfiguration(from, to config.Configuration) error {
+	return nil
+}
+
+func (s *Service) CommitConfiguration(from, to config.Configuration) bool {
+	if !reflect.DeepEqual(from.MQTT, to.MQTT) {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (*Service) String() string {
+	return "mqtt.Service"
+}
+
+// startPublisher returns a publisher for the given configuration, which is
+// nil if publishing is disabled or misconfigured.
+func (s *Service) startPublisher(cfg config.MQTTConfiguration) *publisher {
+	if cfg.Address == "" {
+		return nil
+	}
+	p, err := newPublisher(cfg, s.myID)
+	if err != nil {
+		l.Infoln("Not publishing events over MQTT:", err)
+		return nil
+	}
+	go p.run()
+	return p
+}
+
+type publisher struct {
+	opts   dialOptions
+	topic  string // template
+	device string
+	retain bool
+	mask   events.EventType
+	queue  chan events.Event
+	stop   chan struct{}
+}
+
+func newPublisher(cfg config.MQTTConfiguration, myID protocol.DeviceID) (*publisher, error) {
+	mask, err := events.UnmarshalEventMask(cfg.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = clientIDPrefix + myID.Short().String()
+	}
+
+	return &publisher{
+		opts: dialOptions{
+			address:            cfg.Address,
+			clientID:           clientID,
+			username:           cfg.Username,
+			password:           cfg.Password,
+			keepAlive:          keepAlive,
+			timeout:            dialTimeout,
+			insecureSkipVerify: cfg.InsecureSkipVerify,
+		},
+		topic:  cfg.Topic,
+		device: myID.Short().String(),
+		retain: cfg.Retain,
+		mask:   mask,
+		queue:  make(chan events.Event, queueSize),
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// subscribe returns a subscription to the events the publisher wants, none
+// for a nil publisher.
+func (p *publisher) subscribe() *events.Subscription {
+	if p == nil {
+		return events.Default.Subscribe(0)
+	}
+	return events.Default.Subscribe(p.mask)
+}
+
+// Stop stops the publisher; it's a no-op on a nil publisher.
+func (p *publisher) Stop() {
+	if p != nil {
+		close(p.stop)
+	}
+}
+
+// offer queues the event for publishing if it's one we want. Events are
+// dropped while the queue is full, for example while we can't reach the
+// broker.
+func (p *publisher) offer(ev events.Event) {
+	if p == nil || ev.Type&p.mask == 0 {
+		return
+	}
+	select {
+	case p.queue <- ev:
+	default:
+		l.Debugf("Queue full, dropping %v event", ev.Type)
+	}
+}
+
+func (p *publisher) topicFor(t events.EventType) string {
+	return strings.NewReplacer(topicDeviceVar, p.device, topicEventVar, t.String()).Replace(p.topic)
+}
+
+// run keeps a connection to the broker, reconnecting with increasing delays
+// when it fails.
+func (p *publisher) run() {
+	delay := minRetryDelay
+	for {
+		c, err := dial(p.opts)
+		if err != nil {
+			l.Infof("Connecting to MQTT broker %s: %v (retrying in %v)", p.opts.address, err, delay)
+			select {
+			case <-time.After(delay):
+			case <-p.stop:
+				return
+			}
+			if delay *= 2; delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			continue
+		}
+
+		l.Infoln("Connected to MQTT broker", p.opts.address)
+		delay = minRetryDelay
+		err = p.publishAll(c)
+		c.close()
+		if err == nil {
+			return
+		}
+		l.Infof("Connection to MQTT broker %s lost: %v", p.opts.address, err)
+	}
+}
+
+// publishAll publishes queued events over the given connection until it
+// fails or the publisher is stopped, in which case it returns nil.
+func (p *publisher) publishAll(c *client) error {
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- c.readLoop(keepAlive)
+	}()
+
+	ping := time.NewTicker(keepAlive)
+	defer ping.Stop()
+
+	for {
+		select {
+		case ev := <-p.queue:
+			payload, err := json.Marshal(ev)
+			if err != nil {
+				l.Debugf("Marshalling %v event: %v", ev.Type, err)
+				continue
+			}
+			if err := c.publish(p.topicFor(ev.Type), payload, p.retain); err != nil {
+				return err
+			}
+		case <-ping.C:
+			if err := c.ping(); err != nil {
+				return err
+			}
+		case err := <-readErr:
+			return err
+		case <-p.stop:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type published struct {
+	topic   string
+	payload []byte
+}
+
+// fakeBroker accepts a single connection, checks the credentials and
+// reports every published message.
+func fakeBroker(t *testing.T, user, pass string) (net.Listener, <-chan published) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := make(chan published, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		typ, body, err := readPacket(r)
+		if err != nil || typ != packetConnect {
+			t.Error("Expected connect packet, got", typ, err)
+			return
+		}
+		// Skip protocol name, level, flags and keepalive to get at the
+		// client ID, user name and password.
+		strs := readStrings(body[10:])
+		code := byte(0)
+		if len(strs) != 3 || strs[1] != user || strs[2] != pass {
+			code = 4
+		}
+		conn.Write([]byte{packetConnAck << 4, 2, 0, code})
+
+		for {
+			typ, body, err := readPacket(r)
+			if err != nil {
+				return
+			}
+			if typ == packetPublish {
+				n := binary.BigEndian.Uint16(body)
+				pubs <- published{string(body[2 : 2+n]), body[2+n:]}
+			}
+		}
+	}()
+	return ln, pubs
+}
+
+func readStrings(bs []byte) []string {
+	var res []string
+	for len(bs) >= 2 {
+		n := int(binary.BigEndian.Uint16(bs))
+		res = append(res, string(bs[2:2+n]))
+		bs = bs[2+n:]
+	}
+	return res
+}
+
+func TestPublish(t *testing.T) {
+	ln, pubs := fakeBroker(t, "user", "pass")
+	defer ln.Close()
+
+	cfg := config.New(protocol.LocalDeviceID).MQTT
+	cfg.Address = "tcp://" + ln.Addr().String()
+	cfg.Username = "user"
+	cfg.Password = "pass"
+	cfg.Events = []string{"DeviceDisconnected"}
+
+	p, err := newPublisher(cfg, protocol.LocalDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.run()
+	defer p.Stop()
+
+	p.offer(events.Event{Type: events.ItemFinished})
+	p.offer(events.Event{Type: events.DeviceDisconnected, Data: map[string]string{"id": "x"}})
+
+	select {
+	case pub := <-pubs:
+		expected := "syncthing/" + protocol.LocalDeviceID.Short().String() + "/DeviceDisconnected"
+		if pub.topic != expected {
+			t.Errorf("Topic %q, expected %q", pub.topic, expected)
+		}
+		var ev events.Event
+		if err := json.Unmarshal(pub.payload, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != events.DeviceDisconnected {
+			t.Error("Unexpected event", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for publish")
+	}
+
+	select {
+	case pub := <-pubs:
+		t.Error("Unexpected publish to", pub.topic)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	ln, _ := fakeBroker(t, "user", "pass")
+	defer ln.Close()
+
+	_, err := dial(dialOptions{
+		address:  "tcp://" + ln.Addr().String(),
+		clientID: "test",
+		username: "user",
+		password: "wrong",
+		timeout:  time.Second,
+	})
+	if err == nil {
+		t.Fatal("Expected connection to be refused")
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, maxRemainingLength} {
+		bs := appendLength(nil, n)
+		length, err := readLength(bytes.NewReader(bs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != n {
+			t.Errorf("Length %d encoded as %x decodes to %d", n, bs, length)
+		}
+	}
+}
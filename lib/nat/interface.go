@@ -24,3 +24,10 @@ type Device interface {
 	AddPortMapping(protocol Protocol, internalPort, externalPort int, description string, duration time.Duration) (int, error)
 	GetExternalIPAddress() (net.IP, error)
 }
+
+// A LeaseDevice is a Device that tells the lease its gateway granted for a
+// mapping, which may be shorter than the one asked for.
+type LeaseDevice interface {
+	Device
+	AddPortMappingLease(protocol Protocol, internalPort, externalPort int, description string, duration time.Duration) (int, time.Duration, error)
+}
@@ -125,6 +125,19 @@ func (s *Service) process() int {
 		s.updateMapping(mapping, nats, false)
 	}
 
+	// Short leases may need renewing before the timer fires.
+	s.mut.RLock()
+	for _, mapping := range s.mappings {
+		mapping.mut.RLock()
+		renewShortIn := time.Until(mapping.expires)
+		mapping.mut.RUnlock()
+		if renewShortIn < renewIn {
+			renewIn = renewShortIn
+			s.timer.Reset(renewIn)
+		}
+	}
+	s.mut.RUnlock()
+
 	return len(nats)
 }
 
@@ -154,6 +167,17 @@ func (s *Service) NewMapping(protocol Protocol, ip net.IP, port int) *Mapping {
 	return mapping
 }
 
+// Status returns the current state of all port mappings.
+func (s *Service) Status() []MappingStatus {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	res := make([]MappingStatus, 0, len(s.mappings))
+	for _, mapping := range s.mappings {
+		res = append(res, mapping.status())
+	}
+	return res
+}
+
 // RemoveMapping does not actually remove the mapping from the IGD, it just
 // internally removes it which stops renewing the mapping. Also, it clears any
 // existing mapped addresses from the mapping, which as a result should cause
@@ -182,7 +206,9 @@ func (s *Service) updateMapping(mapping *Mapping, nats map[string]Device, renew
 	var added, removed []Address
 
 	renewalTime := time.Duration(s.cfg.Options().NATRenewalM) * time.Minute
+	mapping.mut.Lock()
 	mapping.expires = time.Now().Add(renewalTime)
+	mapping.mut.Unlock()
 
 	newAdded, newRemoved := s.verifyExistingMappings(mapping, nats, renew)
 	added = append(added, newAdded...)
@@ -220,13 +246,14 @@ func (s *Service) verifyExistingMappings(mapping *Mapping, nats map[string]Devic
 
 			l.Debugf("Renewing %s -> %s mapping on %s", mapping, address, id)
 
-			addr, err := s.tryNATDevice(nat, mapping.address.Port, address.Port, leaseTime)
+			addr, lease, err := s.tryNATDevice(nat, mapping.address.Port, address.Port, leaseTime)
 			if err != nil {
 				l.Debugf("Failed to renew %s -> mapping on %s", mapping, address, id)
 				mapping.removeAddress(id)
 				removed = append(removed, address)
 				continue
 			}
+			mapping.leaseGranted(id, lease, time.Duration(s.cfg.Options().NATRenewalM)*time.Minute)
 
 			l.Debugf("Renewed %s -> %s mapping on %s", mapping, address, id)
 
@@ -263,11 +290,12 @@ func (s *Service) acquireNewMappings(mapping *Mapping, nats map[string]Device) (
 
 		l.Debugf("Acquiring %s mapping on %s", mapping, id)
 
-		addr, err := s.tryNATDevice(nat, mapping.address.Port, 0, leaseTime)
+		addr, lease, err := s.tryNATDevice(nat, mapping.address.Port, 0, leaseTime)
 		if err != nil {
 			l.Debugf("Failed to acquire %s mapping on %s", mapping, id)
 			continue
 		}
+		mapping.leaseGranted(id, lease, time.Duration(s.cfg.Options().NATRenewalM)*time.Minute)
 
 		l.Debugf("Acquired %s -> %s mapping on %s", mapping, addr, id)
 
@@ -280,9 +308,12 @@ func (s *Service) acquireNewMappings(mapping *Mapping, nats map[string]Device) (
 
 // tryNATDevice tries to acquire a port mapping for the given internal address to
 // the given external port. If external port is 0, picks a pseudo-random port.
-func (s *Service) tryNATDevice(natd Device, intPort, extPort int, leaseTime time.Duration) (Address, error) {
+// It returns the lease granted, which is the one asked for unless the device
+// tells otherwise.
+func (s *Service) tryNATDevice(natd Device, intPort, extPort int, leaseTime time.Duration) (Address, time.Duration, error) {
 	var err error
 	var port int
+	var lease time.Duration
 
 	// Generate a predictable random which is based on device ID + local port + hash of the device ID
 	// number so that the ports we'd try to acquire for the mapping would always be the same for the
@@ -292,7 +323,7 @@ func (s *Service) tryNATDevice(natd Device, intPort, extPort int, leaseTime time
 	if extPort != 0 {
 		// First try renewing our existing mapping, if we have one.
 		name := fmt.Sprintf("syncthing-%d", extPort)
-		port, err = natd.AddPortMapping(TCP, intPort, extPort, name, leaseTime)
+		port, lease, err = addPortMapping(natd, intPort, extPort, name, leaseTime)
 		if err == nil {
 			extPort = port
 			goto findIP
@@ -304,7 +335,7 @@ func (s *Service) tryNATDevice(natd Device, intPort, extPort int, leaseTime time
 		// Then try up to ten random ports.
 		extPort = 1024 + predictableRand.Intn(65535-1024)
 		name := fmt.Sprintf("syncthing-%d", extPort)
-		port, err = natd.AddPortMapping(TCP, intPort, extPort, name, leaseTime)
+		port, lease, err = addPortMapping(natd, intPort, extPort, name, leaseTime)
 		if err == nil {
 			extPort = port
 			goto findIP
@@ -312,7 +343,7 @@ func (s *Service) tryNATDevice(natd Device, intPort, extPort int, leaseTime time
 		l.Debugln("Error getting new lease on", natd.ID(), err)
 	}
 
-	return Address{}, err
+	return Address{}, 0, err
 
 findIP:
 	ip, err := natd.GetExternalIPAddress()
@@ -323,7 +354,15 @@ findIP:
 	return Address{
 		IP:   ip,
 		Port: extPort,
-	}, nil
+	}, lease, nil
+}
+
+func addPortMapping(natd Device, intPort, extPort int, name string, leaseTime time.Duration) (int, time.Duration, error) {
+	if ld, ok := natd.(LeaseDevice); ok {
+		return ld.AddPortMappingLease(TCP, intPort, extPort, name, leaseTime)
+	}
+	port, err := natd.AddPortMapping(TCP, intPort, extPort, name, leaseTime)
+	return port, leaseTime, err
 }
 
 func hash(input string) int64 {
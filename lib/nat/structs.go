@@ -22,6 +22,7 @@ type Mapping struct {
 
 	extAddresses map[string]Address // NAT ID -> Address
 	expires      time.Time
+	shortLeases  map[string]time.Duration // NAT ID -> lease shorter than the renewal interval
 	subscribers  []MappingChangeSubscriber
 	mut          sync.RWMutex
 }
@@ -45,6 +46,27 @@ func (m *Mapping) removeAddress(id string) {
 	m.mut.Unlock()
 }
 
+// leaseGranted makes sure the mapping is renewed in time when the NAT
+// granted a lease shorter than the renewal interval, at half of it.
+func (m *Mapping) leaseGranted(id string, lease, renewal time.Duration) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	if lease <= 0 || lease >= renewal {
+		delete(m.shortLeases, id)
+		return
+	}
+	if m.shortLeases[id] != lease {
+		l.Warnf("NAT %s granted a %v lease for %s, shorter than the renewal interval of %v; renewing it every %v", id, lease, m, renewal, lease/2)
+		if m.shortLeases == nil {
+			m.shortLeases = make(map[string]time.Duration)
+		}
+		m.shortLeases[id] = lease
+	}
+	if at := time.Now().Add(lease / 2); at.Before(m.expires) {
+		m.expires = at
+	}
+}
+
 func (m *Mapping) clearAddresses() {
 	m.mut.Lock()
 	var removed []Address
@@ -93,6 +115,30 @@ func (m *Mapping) ExternalAddresses() []Address {
 	return addrs
 }
 
+// MappingStatus describes a port mapping and the external addresses it has
+// been given by each NAT device.
+type MappingStatus struct {
+	Protocol          Protocol          `json:"protocol"`
+	LocalAddress      string            `json:"localAddress"`
+	ExternalAddresses map[string]string `json:"externalAddresses"` // NAT ID -> Address
+	NextRenewal       time.Time         `json:"nextRenewal"`
+}
+
+func (m *Mapping) status() MappingStatus {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+	st := MappingStatus{
+		Protocol:          m.protocol,
+		LocalAddress:      m.address.String(),
+		ExternalAddresses: make(map[string]string, len(m.extAddresses)),
+		NextRenewal:       m.expires,
+	}
+	for id, addr := range m.extAddresses {
+		st.ExternalAddresses[id] = addr.String()
+	}
+	return st
+}
+
 func (m *Mapping) OnChanged(subscribed MappingChangeSubscriber) {
 	m.mut.Lock()
 	m.subscribers = append(m.subscribers, subscribed)
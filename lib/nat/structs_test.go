@@ -9,6 +9,7 @@ package nat
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/protocol"
 )
@@ -67,3 +68,25 @@ func TestMappingClearAddresses(t *testing.T) {
 	// Now try and remove the mapped port; prior to #4829 this deadlocked
 	natSvc.RemoveMapping(m)
 }
+
+func TestMappingShortLease(t *testing.T) {
+	natSvc := NewService(protocol.EmptyDeviceID, nil)
+	m := natSvc.NewMapping(TCP, net.ParseIP("192.168.0.1"), 1024)
+	renewal := 30 * time.Minute
+	m.expires = time.Now().Add(renewal)
+
+	// A long enough lease leaves the renewal as it is
+	m.leaseGranted("test", time.Hour, renewal)
+	if time.Until(m.expires) < renewal-time.Minute {
+		t.Error("Renewal moved for a long lease", m.expires)
+	}
+
+	// A short one has it renewed at half the lease
+	m.leaseGranted("test", 10*time.Minute, renewal)
+	if d := time.Until(m.expires); d > 5*time.Minute || d < 4*time.Minute {
+		t.Error("Expected renewal in five minutes, got", d)
+	}
+	if m.shortLeases["test"] != 10*time.Minute {
+		t.Error("Short lease not recorded", m.shortLeases)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !android
+
+package osutil
+
+import "runtime"
+
+// SetIdleIOPriority puts the calling goroutine in the idle I/O scheduling
+// class, so that its disk accesses are only served when the disk isn't
+// otherwise busy. On Linux I/O priorities are per thread, so the goroutine
+// is locked to its thread until the returned function, which restores the
+// previous priority, is called from the same goroutine.
+func SetIdleIOPriority() (restore func()) {
+	runtime.LockOSThread()
+	prev, err := ioprioGet()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return func() {}
+	}
+	if err := ioprioSet(ioprioClassIdle, 0); err != nil {
+		runtime.UnlockOSThread()
+		return func() {}
+	}
+	return func() {
+		if err := ioprioSetRaw(prev); err != nil {
+			// Don't hand a thread with the wrong priority back to the
+			// runtime; it's dropped when this goroutine exits.
+			return
+		}
+		runtime.UnlockOSThread()
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// +build !android
+
+package osutil
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestSetIdleIOPriority(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	prev, err := ioprioGet()
+	if err != nil {
+		t.Skip("I/O priorities not available:", err)
+	}
+
+	restore := SetIdleIOPriority()
+	cur, err := ioprioGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ioprioClass(cur>>ioprioClassShift) != ioprioClassIdle {
+		t.Errorf("Expected idle I/O class, got priority %#x", cur)
+	}
+
+	restore()
+	if cur, _ := ioprioGet(); cur != prev {
+		t.Errorf("Expected priority %#x to be restored, got %#x", prev, cur)
+	}
+}
@@ -32,15 +32,27 @@ const (
 )
 
 func ioprioSet(class ioprioClass, value int) error {
+	return ioprioSetRaw(uintptr(class)<<ioprioClassShift | uintptr(value))
+}
+
+func ioprioSetRaw(prio uintptr) error {
 	res, _, err := syscall.Syscall(syscall.SYS_IOPRIO_SET,
-		uintptr(ioprioWhoProcess), 0,
-		uintptr(class)<<ioprioClassShift|uintptr(value))
+		uintptr(ioprioWhoProcess), 0, prio)
 	if res == 0 {
 		return nil
 	}
 	return err
 }
 
+func ioprioGet() (uintptr, error) {
+	res, _, err := syscall.Syscall(syscall.SYS_IOPRIO_GET,
+		uintptr(ioprioWhoProcess), 0, 0)
+	if err != 0 {
+		return 0, err
+	}
+	return res, nil
+}
+
 // SetLowPriority lowers the process CPU scheduling priority, and possibly
 // I/O priority depending on the platform and OS.
 func SetLowPriority() error {
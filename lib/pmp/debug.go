@@ -14,7 +14,7 @@ import (
 )
 
 var (
-	l = logger.DefaultLogger.NewFacility("pmp", "NAT-PMP discovery and port mapping")
+	l = logger.DefaultLogger.NewFacility("pmp", "NAT-PMP and PCP discovery and port mapping")
 )
 
 func init() {
@@ -0,0 +1,256 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pmp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/nat"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Port Control Protocol (RFC 6887), the successor of NAT-PMP. It uses the
+// same server port, and servers speaking both fall back to NAT-PMP when
+// they see a version 0 request.
+
+const (
+	pcpPort          = 5351
+	pcpVersion       = 2
+	pcpOpAnnounce    = 0
+	pcpOpMap         = 1
+	pcpResponseBit   = 0x80
+	pcpHeaderLen     = 24
+	pcpMapLen        = 36
+	pcpNonceLen      = 12
+	pcpMaxPacketLen  = 1100
+	pcpProtocolTCP   = 6
+	pcpProtocolUDP   = 17
+	pcpRetransmits   = 3
+	pcpMinRetransmit = 250 * time.Millisecond
+
+	pcpResultSuccess       = 0
+	pcpResultUnsuppVersion = 1
+)
+
+var errPCPUnsupported = errors.New("PCP not supported by gateway")
+
+var pcpResultNames = map[byte]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external address",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+type pcpResultError byte
+
+func (e pcpResultError) Error() string {
+	if name, ok := pcpResultNames[byte(e)]; ok {
+		return "PCP error: " + name
+	}
+	return fmt.Sprintf("PCP error: result code %d", byte(e))
+}
+
+type pcpMappingKey struct {
+	protocol     nat.Protocol
+	internalPort int
+}
+
+// pcpClient talks PCP to a single gateway.
+type pcpClient struct {
+	server  string
+	localIP net.IP
+	timeout time.Duration
+
+	mut        sync.Mutex
+	nonces     map[pcpMappingKey][pcpNonceLen]byte // reused when renewing
+	externalIP net.IP
+}
+
+func newPCPClient(gateway, localIP net.IP, port int, timeout time.Duration) *pcpClient {
+	return &pcpClient{
+		server:  net.JoinHostPort(gateway.String(), strconv.Itoa(port)),
+		localIP: localIP,
+		timeout: timeout,
+		mut:     sync.NewMutex(),
+		nonces:  make(map[pcpMappingKey][pcpNonceLen]byte),
+	}
+}
+
+// announce checks whether the gateway speaks PCP.
+func (c *pcpClient) announce() error {
+	_, _, err := c.request(pcpOpAnnounce, 0, nil)
+	return err
+}
+
+// addPortMapping requests a mapping, returning the assigned external port
+// and the granted lifetime.
+func (c *pcpClient) addPortMapping(protocol nat.Protocol, internalPort, externalPort int, lifetime time.Duration) (int, time.Duration, error) {
+	key := pcpMappingKey{protocol, internalPort}
+	c.mut.Lock()
+	nonce, ok := c.nonces[key]
+	if !ok {
+		rand.Read(nonce[:])
+		c.nonces[key] = nonce
+	}
+	c.mut.Unlock()
+
+	data := make([]byte, pcpMapLen)
+	copy(data, nonce[:])
+	data[pcpNonceLen] = pcpProtocolTCP
+	if protocol == nat.UDP {
+		data[pcpNonceLen] = pcpProtocolUDP
+	}
+	binary.BigEndian.PutUint16(data[16:], uint16(internalPort))
+	binary.BigEndian.PutUint16(data[18:], uint16(externalPort))
+	copy(data[20:], net.IPv4zero.To16())
+
+	granted, resp, err := c.request(pcpOpMap, uint32(lifetime/time.Second), data)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(resp) < pcpMapLen || string(resp[:pcpNonceLen]) != string(nonce[:]) {
+		return 0, 0, errors.New("PCP error: mismatched MAP response")
+	}
+
+	c.mut.Lock()
+	c.externalIP = net.IP(append([]byte(nil), resp[20:36]...))
+	if v4 := c.externalIP.To4(); v4 != nil {
+		c.externalIP = v4
+	}
+	c.mut.Unlock()
+
+	return int(binary.BigEndian.Uint16(resp[18:])), time.Duration(granted) * time.Second, nil
+}
+
+func (c *pcpClient) getExternalIP() (net.IP, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.externalIP == nil {
+		return nil, errors.New("PCP: external address not yet known")
+	}
+	return c.externalIP, nil
+}
+
+// request sends a request with the given opcode and data, retransmitting
+// as necessary, and returns the lifetime and opcode specific data of the
+// response.
+func (c *pcpClient) request(opcode byte, lifetime uint32, data []byte) (uint32, []byte, error) {
+	req := make([]byte, pcpHeaderLen+len(data))
+	req[0] = pcpVersion
+	req[1] = opcode
+	binary.BigEndian.PutUint32(req[4:], lifetime)
+	localIP := c.localIP
+	if localIP == nil {
+		localIP = net.IPv4zero
+	}
+	copy(req[8:], localIP.To16())
+	copy(req[pcpHeaderLen:], data)
+
+	conn, err := net.Dial("udp", c.server)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+
+	wait := c.timeout / pcpRetransmits
+	if wait < pcpMinRetransmit {
+		wait = pcpMinRetransmit
+	}
+	buf := make([]byte, pcpMaxPacketLen)
+	for i := 0; i < pcpRetransmits; i++ {
+		if _, err = conn.Write(req); err != nil {
+			return 0, nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		var n int
+		for {
+			n, err = conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if n >= 2 && buf[1] == opcode|pcpResponseBit {
+				break
+			}
+			// Something else, keep waiting
+		}
+		if err != nil {
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				continue
+			}
+			// ICMP port unreachable and similar
+			return 0, nil, err
+		}
+		return parsePCPResponse(buf[:n])
+	}
+	return 0, nil, err
+}
+
+func parsePCPResponse(resp []byte) (uint32, []byte, error) {
+	if len(resp) >= 4 && resp[0] != pcpVersion {
+		// A NAT-PMP only server answers with its own version and an
+		// unsupported version result.
+		return 0, nil, errPCPUnsupported
+	}
+	if len(resp) < pcpHeaderLen {
+		return 0, nil, errors.New("PCP error: short response")
+	}
+	if result := resp[3]; result != pcpResultSuccess {
+		if result == pcpResultUnsuppVersion {
+			return 0, nil, errPCPUnsupported
+		}
+		return 0, nil, pcpResultError(result)
+	}
+	return binary.BigEndian.Uint32(resp[4:]), resp[pcpHeaderLen:], nil
+}
+
+type pcpWrapper struct {
+	renewal   time.Duration
+	gatewayIP net.IP
+	client    *pcpClient
+}
+
+func (w *pcpWrapper) ID() string {
+	return fmt.Sprintf("PCP@%s", w.gatewayIP.String())
+}
+
+func (w *pcpWrapper) GetLocalIPAddress() net.IP {
+	return w.client.localIP
+}
+
+func (w *pcpWrapper) AddPortMapping(protocol nat.Protocol, internalPort, externalPort int, description string, duration time.Duration) (int, error) {
+	port, _, err := w.AddPortMappingLease(protocol, internalPort, externalPort, description, duration)
+	return port, err
+}
+
+// AddPortMappingLease adds the mapping and returns the lease granted, which
+// the gateway may make shorter than asked for.
+func (w *pcpWrapper) AddPortMappingLease(protocol nat.Protocol, internalPort, externalPort int, description string, duration time.Duration) (int, time.Duration, error) {
+	// As for NAT-PMP, a zero lifetime removes the mapping.
+	if duration == 0 {
+		duration = w.renewal
+	}
+	return w.client.addPortMapping(protocol, internalPort, externalPort, duration)
+}
+
+func (w *pcpWrapper) GetExternalIPAddress() (net.IP, error) {
+	return w.client.getExternalIP()
+}
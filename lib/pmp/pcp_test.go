@@ -0,0 +1,115 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package pmp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/nat"
+)
+
+// fakeGateway answers requests on a local UDP port using handler, until the
+// returned connection is closed.
+func fakeGateway(t *testing.T, handler func(req []byte) []byte) (*net.UDPConn, net.IP, int) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, pcpMaxPacketLen)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if resp := handler(buf[:n]); resp != nil {
+				conn.WriteToUDP(resp, addr)
+			}
+		}
+	}()
+	laddr := conn.LocalAddr().(*net.UDPAddr)
+	return conn, laddr.IP, laddr.Port
+}
+
+func TestPCPMap(t *testing.T) {
+	external := net.IPv4(192, 0, 2, 1)
+	conn, ip, port := fakeGateway(t, func(req []byte) []byte {
+		resp := make([]byte, len(req))
+		copy(resp, req)
+		resp[1] |= pcpResponseBit
+		if req[1] == pcpOpMap {
+			// Hand out the requested port plus one, with half the
+			// requested lifetime.
+			lifetime := binary.BigEndian.Uint32(req[4:])
+			binary.BigEndian.PutUint32(resp[4:], lifetime/2)
+			data := resp[pcpHeaderLen:]
+			binary.BigEndian.PutUint16(data[18:], binary.BigEndian.Uint16(data[18:])+1)
+			copy(data[20:], external.To16())
+		}
+		return resp
+	})
+	defer conn.Close()
+
+	c := newPCPClient(ip, ip, port, time.Second)
+	if err := c.announce(); err != nil {
+		t.Fatal(err)
+	}
+	w := &pcpWrapper{renewal: time.Minute, gatewayIP: ip, client: c}
+
+	if _, err := w.GetExternalIPAddress(); err == nil {
+		t.Error("Expected an error before any mapping was made")
+	}
+
+	extPort, err := w.AddPortMapping(nat.TCP, 22000, 22000, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extPort != 22001 {
+		t.Error("Unexpected external port", extPort)
+	}
+	extIP, err := w.GetExternalIPAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extIP.Equal(external) {
+		t.Error("Unexpected external address", extIP)
+	}
+}
+
+func TestPCPUnsupported(t *testing.T) {
+	// A NAT-PMP only gateway answers with version 0 and result code 1.
+	conn, ip, port := fakeGateway(t, func(req []byte) []byte {
+		return []byte{0, req[1] | pcpResponseBit, 0, 1, 0, 0, 0, 0}
+	})
+	defer conn.Close()
+
+	c := newPCPClient(ip, ip, port, time.Second)
+	if err := c.announce(); err != errPCPUnsupported {
+		t.Error("Expected PCP to be unsupported, got", err)
+	}
+}
+
+func TestPCPResultError(t *testing.T) {
+	conn, ip, port := fakeGateway(t, func(req []byte) []byte {
+		resp := make([]byte, len(req))
+		copy(resp, req)
+		resp[1] |= pcpResponseBit
+		resp[3] = 2 // not authorized
+		return resp
+	})
+	defer conn.Close()
+
+	c := newPCPClient(ip, ip, port, time.Second)
+	_, _, err := c.addPortMapping(nat.UDP, 22000, 22000, time.Hour)
+	if err != pcpResultError(2) {
+		t.Error("Expected not authorized error, got", err)
+	}
+}
@@ -33,6 +33,22 @@ func Discover(renewal, timeout time.Duration) []nat.Device {
 
 	l.Debugln("Discovered gateway at", ip)
 
+	localIP := lookupLocalIP(ip, timeout)
+
+	// Prefer PCP, falling back to NAT-PMP for gateways that only speak the
+	// older protocol.
+	pc := newPCPClient(ip, localIP, pcpPort, timeout)
+	if err := pc.announce(); err == nil {
+		l.Debugln("Gateway at", ip, "speaks PCP")
+		return []nat.Device{&pcpWrapper{
+			renewal:   renewal,
+			gatewayIP: ip,
+			client:    pc,
+		}}
+	} else {
+		l.Debugln("PCP announce to", ip, "failed:", err)
+	}
+
 	c := natpmp.NewClient(ip, timeout)
 	// Try contacting the gateway, if it does not respond, assume it does not
 	// speak NAT-PMP.
@@ -42,19 +58,6 @@ func Discover(renewal, timeout time.Duration) []nat.Device {
 		return nil
 	}
 
-	var localIP net.IP
-	// Port comes from the natpmp package
-	conn, err := net.DialTimeout("udp", net.JoinHostPort(ip.String(), "5351"), timeout)
-	if err == nil {
-		conn.Close()
-		localIPAddress, _, err := net.SplitHostPort(conn.LocalAddr().String())
-		if err == nil {
-			localIP = net.ParseIP(localIPAddress)
-		} else {
-			l.Debugln("Failed to lookup local IP", err)
-		}
-	}
-
 	return []nat.Device{&wrapper{
 		renewal:   renewal,
 		localIP:   localIP,
@@ -63,6 +66,22 @@ func Discover(renewal, timeout time.Duration) []nat.Device {
 	}}
 }
 
+// lookupLocalIP returns the local address used to talk to the gateway.
+func lookupLocalIP(gateway net.IP, timeout time.Duration) net.IP {
+	// Port comes from the natpmp package
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(gateway.String(), "5351"), timeout)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	localIPAddress, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		l.Debugln("Failed to lookup local IP", err)
+		return nil
+	}
+	return net.ParseIP(localIPAddress)
+}
+
 type wrapper struct {
 	renewal   time.Duration
 	localIP   net.IP
@@ -58,9 +58,9 @@ func benchmarkRequestsTLS(b *testing.B, conn0, conn1 net.Conn) {
 
 func benchmarkRequestsConnPair(b *testing.B, conn0, conn1 net.Conn) {
 	// Start up Connections on them
-	c0 := NewConnection(LocalDeviceID, conn0, conn0, new(fakeModel), "c0", CompressMetadata)
+	c0 := NewConnection(LocalDeviceID, conn0, conn0, new(fakeModel), "c0", CompressMetadata, nil)
 	c0.Start()
-	c1 := NewConnection(LocalDeviceID, conn1, conn1, new(fakeModel), "c1", CompressMetadata)
+	c1 := NewConnection(LocalDeviceID, conn1, conn1, new(fakeModel), "c1", CompressMetadata, nil)
 	c1.Start()
 
 	// Satisfy the assertions in the protocol by sending an initial cluster config
@@ -188,3 +188,18 @@ func (m *fakeModel) Closed(conn Connection, err error) {
 
 func (m *fakeModel) DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate) {
 }
+
+func (m *fakeModel) FolderActivity(deviceID DeviceID, activity FolderActivity) {
+}
+
+func (m *fakeModel) RescanHint(deviceID DeviceID, hint RescanHint) {
+}
+
+func (m *fakeModel) BlockMismatch(deviceID DeviceID, mismatch BlockMismatch) {
+}
+
+func (m *fakeModel) ConfigPush(deviceID DeviceID, push ConfigPush) {
+}
+
+func (m *fakeModel) ConfigPushResponse(deviceID DeviceID, resp ConfigPushResponse) {
+}
@@ -24,42 +24,57 @@ const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 type MessageType int32
 
 const (
-	messageTypeClusterConfig    MessageType = 0
-	messageTypeIndex            MessageType = 1
-	messageTypeIndexUpdate      MessageType = 2
-	messageTypeRequest          MessageType = 3
-	messageTypeResponse         MessageType = 4
-	messageTypeDownloadProgress MessageType = 5
-	messageTypePing             MessageType = 6
-	messageTypeClose            MessageType = 7
+	messageTypeClusterConfig      MessageType = 0
+	messageTypeIndex              MessageType = 1
+	messageTypeIndexUpdate        MessageType = 2
+	messageTypeRequest            MessageType = 3
+	messageTypeResponse           MessageType = 4
+	messageTypeDownloadProgress   MessageType = 5
+	messageTypePing               MessageType = 6
+	messageTypeClose              MessageType = 7
+	messageTypeFolderActivity     MessageType = 8
+	messageTypeRescanHint         MessageType = 9
+	messageTypeBlockMismatch      MessageType = 10
+	messageTypeConfigPush         MessageType = 11
+	messageTypeConfigPushResponse MessageType = 12
 )
 
 var MessageType_name = map[int32]string{
-	0: "CLUSTER_CONFIG",
-	1: "INDEX",
-	2: "INDEX_UPDATE",
-	3: "REQUEST",
-	4: "RESPONSE",
-	5: "DOWNLOAD_PROGRESS",
-	6: "PING",
-	7: "CLOSE",
+	0:  "CLUSTER_CONFIG",
+	1:  "INDEX",
+	2:  "INDEX_UPDATE",
+	3:  "REQUEST",
+	4:  "RESPONSE",
+	5:  "DOWNLOAD_PROGRESS",
+	6:  "PING",
+	7:  "CLOSE",
+	8:  "FOLDER_ACTIVITY",
+	9:  "RESCAN_HINT",
+	10: "BLOCK_MISMATCH",
+	11: "CONFIG_PUSH",
+	12: "CONFIG_PUSH_RESPONSE",
 }
 var MessageType_value = map[string]int32{
-	"CLUSTER_CONFIG":    0,
-	"INDEX":             1,
-	"INDEX_UPDATE":      2,
-	"REQUEST":           3,
-	"RESPONSE":          4,
-	"DOWNLOAD_PROGRESS": 5,
-	"PING":              6,
-	"CLOSE":             7,
+	"CLUSTER_CONFIG":       0,
+	"INDEX":                1,
+	"INDEX_UPDATE":         2,
+	"REQUEST":              3,
+	"RESPONSE":             4,
+	"DOWNLOAD_PROGRESS":    5,
+	"PING":                 6,
+	"CLOSE":                7,
+	"FOLDER_ACTIVITY":      8,
+	"RESCAN_HINT":          9,
+	"BLOCK_MISMATCH":       10,
+	"CONFIG_PUSH":          11,
+	"CONFIG_PUSH_RESPONSE": 12,
 }
 
 func (x MessageType) String() string {
 	return proto.EnumName(MessageType_name, int32(x))
 }
 func (MessageType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{0}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{0}
 }
 
 type MessageCompression int32
@@ -82,7 +97,7 @@ func (x MessageCompression) String() string {
 	return proto.EnumName(MessageCompression_name, int32(x))
 }
 func (MessageCompression) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{1}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{1}
 }
 
 type Compression int32
@@ -108,7 +123,7 @@ func (x Compression) String() string {
 	return proto.EnumName(Compression_name, int32(x))
 }
 func (Compression) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{2}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{2}
 }
 
 type FileInfoType int32
@@ -140,7 +155,7 @@ func (x FileInfoType) String() string {
 	return proto.EnumName(FileInfoType_name, int32(x))
 }
 func (FileInfoType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{3}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{3}
 }
 
 type ErrorCode int32
@@ -169,7 +184,7 @@ func (x ErrorCode) String() string {
 	return proto.EnumName(ErrorCode_name, int32(x))
 }
 func (ErrorCode) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{4}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{4}
 }
 
 type FileDownloadProgressUpdateType int32
@@ -192,7 +207,7 @@ func (x FileDownloadProgressUpdateType) String() string {
 	return proto.EnumName(FileDownloadProgressUpdateType_name, int32(x))
 }
 func (FileDownloadProgressUpdateType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{5}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{5}
 }
 
 type Hello struct {
@@ -205,7 +220,7 @@ func (m *Hello) Reset()         { *m = Hello{} }
 func (m *Hello) String() string { return proto.CompactTextString(m) }
 func (*Hello) ProtoMessage()    {}
 func (*Hello) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{0}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{0}
 }
 func (m *Hello) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -243,7 +258,7 @@ func (m *Header) Reset()         { *m = Header{} }
 func (m *Header) String() string { return proto.CompactTextString(m) }
 func (*Header) ProtoMessage()    {}
 func (*Header) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{1}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{1}
 }
 func (m *Header) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -273,14 +288,19 @@ func (m *Header) XXX_DiscardUnknown() {
 var xxx_messageInfo_Header proto.InternalMessageInfo
 
 type ClusterConfig struct {
-	Folders []Folder `protobuf:"bytes,1,rep,name=folders,proto3" json:"folders"`
+	Folders        []Folder `protobuf:"bytes,1,rep,name=folders,proto3" json:"folders"`
+	FolderActivity bool     `protobuf:"varint,2,opt,name=folder_activity,json=folderActivity,proto3" json:"folder_activity,omitempty"`
+	RescanHints    bool     `protobuf:"varint,3,opt,name=rescan_hints,json=rescanHints,proto3" json:"rescan_hints,omitempty"`
+	BlockMismatch  bool     `protobuf:"varint,4,opt,name=block_mismatch,json=blockMismatch,proto3" json:"block_mismatch,omitempty"`
+	PurgeFolders   []string `protobuf:"bytes,5,rep,name=purge_folders,json=purgeFolders,proto3" json:"purge_folders,omitempty"`
+	ConfigPush     bool     `protobuf:"varint,6,opt,name=config_push,json=configPush,proto3" json:"config_push,omitempty"`
 }
 
 func (m *ClusterConfig) Reset()         { *m = ClusterConfig{} }
 func (m *ClusterConfig) String() string { return proto.CompactTextString(m) }
 func (*ClusterConfig) ProtoMessage()    {}
 func (*ClusterConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{2}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{2}
 }
 func (m *ClusterConfig) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -310,21 +330,29 @@ func (m *ClusterConfig) XXX_DiscardUnknown() {
 var xxx_messageInfo_ClusterConfig proto.InternalMessageInfo
 
 type Folder struct {
-	ID                 string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Label              string   `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
-	ReadOnly           bool     `protobuf:"varint,3,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
-	IgnorePermissions  bool     `protobuf:"varint,4,opt,name=ignore_permissions,json=ignorePermissions,proto3" json:"ignore_permissions,omitempty"`
-	IgnoreDelete       bool     `protobuf:"varint,5,opt,name=ignore_delete,json=ignoreDelete,proto3" json:"ignore_delete,omitempty"`
-	DisableTempIndexes bool     `protobuf:"varint,6,opt,name=disable_temp_indexes,json=disableTempIndexes,proto3" json:"disable_temp_indexes,omitempty"`
-	Paused             bool     `protobuf:"varint,7,opt,name=paused,proto3" json:"paused,omitempty"`
-	Devices            []Device `protobuf:"bytes,16,rep,name=devices,proto3" json:"devices"`
+	ID                 string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Label              string           `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	ReadOnly           bool             `protobuf:"varint,3,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	IgnorePermissions  bool             `protobuf:"varint,4,opt,name=ignore_permissions,json=ignorePermissions,proto3" json:"ignore_permissions,omitempty"`
+	IgnoreDelete       bool             `protobuf:"varint,5,opt,name=ignore_delete,json=ignoreDelete,proto3" json:"ignore_delete,omitempty"`
+	DisableTempIndexes bool             `protobuf:"varint,6,opt,name=disable_temp_indexes,json=disableTempIndexes,proto3" json:"disable_temp_indexes,omitempty"`
+	Paused             bool             `protobuf:"varint,7,opt,name=paused,proto3" json:"paused,omitempty"`
+	SharesIgnores      bool             `protobuf:"varint,8,opt,name=shares_ignores,json=sharesIgnores,proto3" json:"shares_ignores,omitempty"`
+	IgnorePatterns     []string         `protobuf:"bytes,9,rep,name=ignore_patterns,json=ignorePatterns,proto3" json:"ignore_patterns,omitempty"`
+	SharesMetadata     bool             `protobuf:"varint,10,opt,name=shares_metadata,json=sharesMetadata,proto3" json:"shares_metadata,omitempty"`
+	MetadataChanged    int64            `protobuf:"varint,11,opt,name=metadata_changed,json=metadataChanged,proto3" json:"metadata_changed,omitempty"`
+	LocalLabel         bool             `protobuf:"varint,12,opt,name=local_label,json=localLabel,proto3" json:"local_label,omitempty"`
+	FolderDescription  string           `protobuf:"bytes,13,opt,name=description,proto3" json:"description,omitempty"`
+	LocalDescription   bool             `protobuf:"varint,14,opt,name=local_description,json=localDescription,proto3" json:"local_description,omitempty"`
+	Metadata           []FolderMetadata `protobuf:"bytes,15,rep,name=metadata,proto3" json:"metadata"`
+	Devices            []Device         `protobuf:"bytes,16,rep,name=devices,proto3" json:"devices"`
 }
 
 func (m *Folder) Reset()         { *m = Folder{} }
 func (m *Folder) String() string { return proto.CompactTextString(m) }
 func (*Folder) ProtoMessage()    {}
 func (*Folder) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{3}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{3}
 }
 func (m *Folder) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -353,6 +381,45 @@ func (m *Folder) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Folder proto.InternalMessageInfo
 
+type FolderMetadata struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Local bool   `protobuf:"varint,3,opt,name=local,proto3" json:"local,omitempty"`
+}
+
+func (m *FolderMetadata) Reset()         { *m = FolderMetadata{} }
+func (m *FolderMetadata) String() string { return proto.CompactTextString(m) }
+func (*FolderMetadata) ProtoMessage()    {}
+func (*FolderMetadata) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{4}
+}
+func (m *FolderMetadata) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FolderMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FolderMetadata.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *FolderMetadata) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FolderMetadata.Merge(dst, src)
+}
+func (m *FolderMetadata) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *FolderMetadata) XXX_DiscardUnknown() {
+	xxx_messageInfo_FolderMetadata.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FolderMetadata proto.InternalMessageInfo
+
 type Device struct {
 	ID                       DeviceID    `protobuf:"bytes,1,opt,name=id,proto3,customtype=DeviceID" json:"id"`
 	Name                     string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
@@ -369,7 +436,7 @@ func (m *Device) Reset()         { *m = Device{} }
 func (m *Device) String() string { return proto.CompactTextString(m) }
 func (*Device) ProtoMessage()    {}
 func (*Device) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{4}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{5}
 }
 func (m *Device) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -407,7 +474,7 @@ func (m *Index) Reset()         { *m = Index{} }
 func (m *Index) String() string { return proto.CompactTextString(m) }
 func (*Index) ProtoMessage()    {}
 func (*Index) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{5}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{6}
 }
 func (m *Index) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -445,7 +512,7 @@ func (m *IndexUpdate) Reset()         { *m = IndexUpdate{} }
 func (m *IndexUpdate) String() string { return proto.CompactTextString(m) }
 func (*IndexUpdate) ProtoMessage()    {}
 func (*IndexUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{6}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{7}
 }
 func (m *IndexUpdate) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -480,27 +547,24 @@ type FileInfo struct {
 	Size          int64        `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
 	Permissions   uint32       `protobuf:"varint,4,opt,name=permissions,proto3" json:"permissions,omitempty"`
 	ModifiedS     int64        `protobuf:"varint,5,opt,name=modified_s,json=modifiedS,proto3" json:"modified_s,omitempty"`
-	ModifiedNs    int32        `protobuf:"varint,11,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
-	ModifiedBy    ShortID      `protobuf:"varint,12,opt,name=modified_by,json=modifiedBy,proto3,customtype=ShortID" json:"modified_by"`
 	Deleted       bool         `protobuf:"varint,6,opt,name=deleted,proto3" json:"deleted,omitempty"`
 	RawInvalid    bool         `protobuf:"varint,7,opt,name=invalid,proto3" json:"invalid,omitempty"`
 	NoPermissions bool         `protobuf:"varint,8,opt,name=no_permissions,json=noPermissions,proto3" json:"no_permissions,omitempty"`
 	Version       Vector       `protobuf:"bytes,9,opt,name=version,proto3" json:"version"`
 	Sequence      int64        `protobuf:"varint,10,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	ModifiedNs    int32        `protobuf:"varint,11,opt,name=modified_ns,json=modifiedNs,proto3" json:"modified_ns,omitempty"`
+	ModifiedBy    ShortID      `protobuf:"varint,12,opt,name=modified_by,json=modifiedBy,proto3,customtype=ShortID" json:"modified_by"`
 	RawBlockSize  int32        `protobuf:"varint,13,opt,name=block_size,json=blockSize,proto3" json:"block_size,omitempty"`
 	Blocks        []BlockInfo  `protobuf:"bytes,16,rep,name=Blocks,proto3" json:"Blocks"`
 	SymlinkTarget string       `protobuf:"bytes,17,opt,name=symlink_target,json=symlinkTarget,proto3" json:"symlink_target,omitempty"`
-	// The local_flags fields stores flags that are relevant to the local
-	// host only. It is not part of the protocol, doesn't get sent or
-	// received (we make sure to zero it), nonetheless we need it on our
-	// struct and to be able to serialize it to/from the database.
-	LocalFlags uint32 `protobuf:"varint,1000,opt,name=local_flags,json=localFlags,proto3" json:"local_flags,omitempty"`
+	Encrypted     []byte       `protobuf:"bytes,19,opt,name=encrypted,proto3" json:"encrypted,omitempty"`
+	LocalFlags    uint32       `protobuf:"varint,1000,opt,name=local_flags,json=localFlags,proto3" json:"local_flags,omitempty"`
 }
 
 func (m *FileInfo) Reset()      { *m = FileInfo{} }
 func (*FileInfo) ProtoMessage() {}
 func (*FileInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{7}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{8}
 }
 func (m *FileInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -539,7 +603,7 @@ type BlockInfo struct {
 func (m *BlockInfo) Reset()      { *m = BlockInfo{} }
 func (*BlockInfo) ProtoMessage() {}
 func (*BlockInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{8}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{9}
 }
 func (m *BlockInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -576,7 +640,7 @@ func (m *Vector) Reset()         { *m = Vector{} }
 func (m *Vector) String() string { return proto.CompactTextString(m) }
 func (*Vector) ProtoMessage()    {}
 func (*Vector) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{9}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{10}
 }
 func (m *Vector) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -614,7 +678,7 @@ func (m *Counter) Reset()         { *m = Counter{} }
 func (m *Counter) String() string { return proto.CompactTextString(m) }
 func (*Counter) ProtoMessage()    {}
 func (*Counter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{10}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{11}
 }
 func (m *Counter) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -658,7 +722,7 @@ func (m *Request) Reset()         { *m = Request{} }
 func (m *Request) String() string { return proto.CompactTextString(m) }
 func (*Request) ProtoMessage()    {}
 func (*Request) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{11}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{12}
 }
 func (m *Request) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -697,7 +761,7 @@ func (m *Response) Reset()         { *m = Response{} }
 func (m *Response) String() string { return proto.CompactTextString(m) }
 func (*Response) ProtoMessage()    {}
 func (*Response) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{12}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{13}
 }
 func (m *Response) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -735,7 +799,7 @@ func (m *DownloadProgress) Reset()         { *m = DownloadProgress{} }
 func (m *DownloadProgress) String() string { return proto.CompactTextString(m) }
 func (*DownloadProgress) ProtoMessage()    {}
 func (*DownloadProgress) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{13}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{14}
 }
 func (m *DownloadProgress) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -775,7 +839,7 @@ func (m *FileDownloadProgressUpdate) Reset()         { *m = FileDownloadProgress
 func (m *FileDownloadProgressUpdate) String() string { return proto.CompactTextString(m) }
 func (*FileDownloadProgressUpdate) ProtoMessage()    {}
 func (*FileDownloadProgressUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{14}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{15}
 }
 func (m *FileDownloadProgressUpdate) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -811,7 +875,7 @@ func (m *Ping) Reset()         { *m = Ping{} }
 func (m *Ping) String() string { return proto.CompactTextString(m) }
 func (*Ping) ProtoMessage()    {}
 func (*Ping) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{15}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{16}
 }
 func (m *Ping) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -848,7 +912,7 @@ func (m *Close) Reset()         { *m = Close{} }
 func (m *Close) String() string { return proto.CompactTextString(m) }
 func (*Close) ProtoMessage()    {}
 func (*Close) Descriptor() ([]byte, []int) {
-	return fileDescriptor_bep_83d45b003aedf660, []int{16}
+	return fileDescriptor_bep_da6b78e804ae2436, []int{17}
 }
 func (m *Close) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -877,11 +941,248 @@ func (m *Close) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Close proto.InternalMessageInfo
 
+type FolderActivity struct {
+	Folders []FolderStatus `protobuf:"bytes,1,rep,name=folders,proto3" json:"folders"`
+}
+
+func (m *FolderActivity) Reset()         { *m = FolderActivity{} }
+func (m *FolderActivity) String() string { return proto.CompactTextString(m) }
+func (*FolderActivity) ProtoMessage()    {}
+func (*FolderActivity) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{18}
+}
+func (m *FolderActivity) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FolderActivity) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FolderActivity.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *FolderActivity) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FolderActivity.Merge(dst, src)
+}
+func (m *FolderActivity) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *FolderActivity) XXX_DiscardUnknown() {
+	xxx_messageInfo_FolderActivity.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FolderActivity proto.InternalMessageInfo
+
+type FolderStatus struct {
+	ID         string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Sequence   int64  `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	LocalFiles int64  `protobuf:"varint,3,opt,name=local_files,json=localFiles,proto3" json:"local_files,omitempty"`
+	LocalBytes int64  `protobuf:"varint,4,opt,name=local_bytes,json=localBytes,proto3" json:"local_bytes,omitempty"`
+	Errors     int32  `protobuf:"varint,5,opt,name=errors,proto3" json:"errors,omitempty"`
+	Paused     bool   `protobuf:"varint,6,opt,name=paused,proto3" json:"paused,omitempty"`
+	State      string `protobuf:"bytes,7,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (m *FolderStatus) Reset()         { *m = FolderStatus{} }
+func (m *FolderStatus) String() string { return proto.CompactTextString(m) }
+func (*FolderStatus) ProtoMessage()    {}
+func (*FolderStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{19}
+}
+func (m *FolderStatus) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *FolderStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_FolderStatus.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *FolderStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FolderStatus.Merge(dst, src)
+}
+func (m *FolderStatus) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *FolderStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_FolderStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FolderStatus proto.InternalMessageInfo
+
+type RescanHint struct {
+	Folder string   `protobuf:"bytes,1,opt,name=folder,proto3" json:"folder,omitempty"`
+	Names  []string `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty"`
+}
+
+func (m *RescanHint) Reset()         { *m = RescanHint{} }
+func (m *RescanHint) String() string { return proto.CompactTextString(m) }
+func (*RescanHint) ProtoMessage()    {}
+func (*RescanHint) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{20}
+}
+func (m *RescanHint) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RescanHint) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RescanHint.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *RescanHint) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RescanHint.Merge(dst, src)
+}
+func (m *RescanHint) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *RescanHint) XXX_DiscardUnknown() {
+	xxx_messageInfo_RescanHint.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RescanHint proto.InternalMessageInfo
+
+type BlockMismatch struct {
+	Folder string `protobuf:"bytes,1,opt,name=folder,proto3" json:"folder,omitempty"`
+	Name   string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Offset int64  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size   int32  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Hash   []byte `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *BlockMismatch) Reset()         { *m = BlockMismatch{} }
+func (m *BlockMismatch) String() string { return proto.CompactTextString(m) }
+func (*BlockMismatch) ProtoMessage()    {}
+func (*BlockMismatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{21}
+}
+func (m *BlockMismatch) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *BlockMismatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BlockMismatch.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *BlockMismatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockMismatch.Merge(dst, src)
+}
+func (m *BlockMismatch) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *BlockMismatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockMismatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockMismatch proto.InternalMessageInfo
+
+type ConfigPush struct {
+	ID        int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Config    []byte `protobuf:"bytes,2,opt,name=config,proto3" json:"config,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *ConfigPush) Reset()         { *m = ConfigPush{} }
+func (m *ConfigPush) String() string { return proto.CompactTextString(m) }
+func (*ConfigPush) ProtoMessage()    {}
+func (*ConfigPush) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{22}
+}
+func (m *ConfigPush) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ConfigPush) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ConfigPush.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *ConfigPush) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConfigPush.Merge(dst, src)
+}
+func (m *ConfigPush) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *ConfigPush) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConfigPush.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConfigPush proto.InternalMessageInfo
+
+type ConfigPushResponse struct {
+	ID    int64  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *ConfigPushResponse) Reset()         { *m = ConfigPushResponse{} }
+func (m *ConfigPushResponse) String() string { return proto.CompactTextString(m) }
+func (*ConfigPushResponse) ProtoMessage()    {}
+func (*ConfigPushResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bep_da6b78e804ae2436, []int{23}
+}
+func (m *ConfigPushResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ConfigPushResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ConfigPushResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalTo(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (dst *ConfigPushResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConfigPushResponse.Merge(dst, src)
+}
+func (m *ConfigPushResponse) XXX_Size() int {
+	return m.ProtoSize()
+}
+func (m *ConfigPushResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConfigPushResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConfigPushResponse proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*Hello)(nil), "protocol.Hello")
 	proto.RegisterType((*Header)(nil), "protocol.Header")
 	proto.RegisterType((*ClusterConfig)(nil), "protocol.ClusterConfig")
 	proto.RegisterType((*Folder)(nil), "protocol.Folder")
+	proto.RegisterType((*FolderMetadata)(nil), "protocol.FolderMetadata")
 	proto.RegisterType((*Device)(nil), "protocol.Device")
 	proto.RegisterType((*Index)(nil), "protocol.Index")
 	proto.RegisterType((*IndexUpdate)(nil), "protocol.IndexUpdate")
@@ -895,6 +1196,12 @@ func init() {
 	proto.RegisterType((*FileDownloadProgressUpdate)(nil), "protocol.FileDownloadProgressUpdate")
 	proto.RegisterType((*Ping)(nil), "protocol.Ping")
 	proto.RegisterType((*Close)(nil), "protocol.Close")
+	proto.RegisterType((*FolderActivity)(nil), "protocol.FolderActivity")
+	proto.RegisterType((*FolderStatus)(nil), "protocol.FolderStatus")
+	proto.RegisterType((*RescanHint)(nil), "protocol.RescanHint")
+	proto.RegisterType((*BlockMismatch)(nil), "protocol.BlockMismatch")
+	proto.RegisterType((*ConfigPush)(nil), "protocol.ConfigPush")
+	proto.RegisterType((*ConfigPushResponse)(nil), "protocol.ConfigPushResponse")
 	proto.RegisterEnum("protocol.MessageType", MessageType_name, MessageType_value)
 	proto.RegisterEnum("protocol.MessageCompression", MessageCompression_name, MessageCompression_value)
 	proto.RegisterEnum("protocol.Compression", Compression_name, Compression_value)
@@ -993,6 +1300,61 @@ func (m *ClusterConfig) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if m.FolderActivity {
+		dAtA[i] = 0x10
+		i++
+		if m.FolderActivity {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.RescanHints {
+		dAtA[i] = 0x18
+		i++
+		if m.RescanHints {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.BlockMismatch {
+		dAtA[i] = 0x20
+		i++
+		if m.BlockMismatch {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.PurgeFolders) > 0 {
+		for _, s := range m.PurgeFolders {
+			dAtA[i] = 0x2a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.ConfigPush {
+		dAtA[i] = 0x30
+		i++
+		if m.ConfigPush {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -1073,6 +1435,84 @@ func (m *Folder) MarshalTo(dAtA []byte) (int, error) {
 		}
 		i++
 	}
+	if m.SharesIgnores {
+		dAtA[i] = 0x40
+		i++
+		if m.SharesIgnores {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.IgnorePatterns) > 0 {
+		for _, s := range m.IgnorePatterns {
+			dAtA[i] = 0x4a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if m.SharesMetadata {
+		dAtA[i] = 0x50
+		i++
+		if m.SharesMetadata {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.MetadataChanged != 0 {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.MetadataChanged))
+	}
+	if m.LocalLabel {
+		dAtA[i] = 0x60
+		i++
+		if m.LocalLabel {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.FolderDescription) > 0 {
+		dAtA[i] = 0x6a
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.FolderDescription)))
+		i += copy(dAtA[i:], m.FolderDescription)
+	}
+	if m.LocalDescription {
+		dAtA[i] = 0x70
+		i++
+		if m.LocalDescription {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.Metadata) > 0 {
+		for _, msg := range m.Metadata {
+			dAtA[i] = 0x7a
+			i++
+			i = encodeVarintBep(dAtA, i, uint64(msg.ProtoSize()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
 	if len(m.Devices) > 0 {
 		for _, msg := range m.Devices {
 			dAtA[i] = 0x82
@@ -1090,7 +1530,7 @@ func (m *Folder) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
-func (m *Device) Marshal() (dAtA []byte, err error) {
+func (m *FolderMetadata) Marshal() (dAtA []byte, err error) {
 	size := m.ProtoSize()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalTo(dAtA)
@@ -1100,13 +1540,53 @@ func (m *Device) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Device) MarshalTo(dAtA []byte) (int, error) {
+func (m *FolderMetadata) MarshalTo(dAtA []byte) (int, error) {
 	var i int
 	_ = i
 	var l int
 	_ = l
-	dAtA[i] = 0xa
-	i++
+	if len(m.Key) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Key)))
+		i += copy(dAtA[i:], m.Key)
+	}
+	if len(m.Value) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Value)))
+		i += copy(dAtA[i:], m.Value)
+	}
+	if m.Local {
+		dAtA[i] = 0x18
+		i++
+		if m.Local {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	return i, nil
+}
+
+func (m *Device) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Device) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	dAtA[i] = 0xa
+	i++
 	i = encodeVarintBep(dAtA, i, uint64(m.ID.ProtoSize()))
 	n1, err := m.ID.MarshalTo(dAtA[i:])
 	if err != nil {
@@ -1371,6 +1851,14 @@ func (m *FileInfo) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintBep(dAtA, i, uint64(len(m.SymlinkTarget)))
 		i += copy(dAtA[i:], m.SymlinkTarget)
 	}
+	if len(m.Encrypted) > 0 {
+		dAtA[i] = 0x9a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Encrypted)))
+		i += copy(dAtA[i:], m.Encrypted)
+	}
 	if m.LocalFlags != 0 {
 		dAtA[i] = 0xc0
 		i++
@@ -1700,6 +2188,245 @@ func (m *Close) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *FolderActivity) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FolderActivity) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Folders) > 0 {
+		for _, msg := range m.Folders {
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintBep(dAtA, i, uint64(msg.ProtoSize()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	return i, nil
+}
+
+func (m *FolderStatus) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *FolderStatus) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ID) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.ID)))
+		i += copy(dAtA[i:], m.ID)
+	}
+	if m.Sequence != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.Sequence))
+	}
+	if m.LocalFiles != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.LocalFiles))
+	}
+	if m.LocalBytes != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.LocalBytes))
+	}
+	if m.Errors != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.Errors))
+	}
+	if m.Paused {
+		dAtA[i] = 0x30
+		i++
+		if m.Paused {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.State) > 0 {
+		dAtA[i] = 0x3a
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.State)))
+		i += copy(dAtA[i:], m.State)
+	}
+	return i, nil
+}
+
+func (m *RescanHint) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RescanHint) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Folder) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Folder)))
+		i += copy(dAtA[i:], m.Folder)
+	}
+	if len(m.Names) > 0 {
+		for _, s := range m.Names {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *BlockMismatch) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BlockMismatch) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Folder) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Folder)))
+		i += copy(dAtA[i:], m.Folder)
+	}
+	if len(m.Name) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Name)))
+		i += copy(dAtA[i:], m.Name)
+	}
+	if m.Offset != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.Offset))
+	}
+	if m.Size != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.Size))
+	}
+	if len(m.Hash) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Hash)))
+		i += copy(dAtA[i:], m.Hash)
+	}
+	return i, nil
+}
+
+func (m *ConfigPush) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ConfigPush) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ID != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.ID))
+	}
+	if len(m.Config) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Config)))
+		i += copy(dAtA[i:], m.Config)
+	}
+	if len(m.Signature) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Signature)))
+		i += copy(dAtA[i:], m.Signature)
+	}
+	return i, nil
+}
+
+func (m *ConfigPushResponse) Marshal() (dAtA []byte, err error) {
+	size := m.ProtoSize()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ConfigPushResponse) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ID != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(m.ID))
+	}
+	if len(m.Error) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintBep(dAtA, i, uint64(len(m.Error)))
+		i += copy(dAtA[i:], m.Error)
+	}
+	return i, nil
+}
+
 func encodeVarintBep(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -1757,6 +2484,24 @@ func (m *ClusterConfig) ProtoSize() (n int) {
 			n += 1 + l + sovBep(uint64(l))
 		}
 	}
+	if m.FolderActivity {
+		n += 2
+	}
+	if m.RescanHints {
+		n += 2
+	}
+	if m.BlockMismatch {
+		n += 2
+	}
+	if len(m.PurgeFolders) > 0 {
+		for _, s := range m.PurgeFolders {
+			l = len(s)
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
+	if m.ConfigPush {
+		n += 2
+	}
 	return n
 }
 
@@ -1789,6 +2534,37 @@ func (m *Folder) ProtoSize() (n int) {
 	if m.Paused {
 		n += 2
 	}
+	if m.SharesIgnores {
+		n += 2
+	}
+	if len(m.IgnorePatterns) > 0 {
+		for _, s := range m.IgnorePatterns {
+			l = len(s)
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
+	if m.SharesMetadata {
+		n += 2
+	}
+	if m.MetadataChanged != 0 {
+		n += 1 + sovBep(uint64(m.MetadataChanged))
+	}
+	if m.LocalLabel {
+		n += 2
+	}
+	l = len(m.FolderDescription)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	if m.LocalDescription {
+		n += 2
+	}
+	if len(m.Metadata) > 0 {
+		for _, e := range m.Metadata {
+			l = e.ProtoSize()
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
 	if len(m.Devices) > 0 {
 		for _, e := range m.Devices {
 			l = e.ProtoSize()
@@ -1798,6 +2574,26 @@ func (m *Folder) ProtoSize() (n int) {
 	return n
 }
 
+func (m *FolderMetadata) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Key)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	if m.Local {
+		n += 2
+	}
+	return n
+}
+
 func (m *Device) ProtoSize() (n int) {
 	if m == nil {
 		return 0
@@ -1931,6 +2727,10 @@ func (m *FileInfo) ProtoSize() (n int) {
 	if l > 0 {
 		n += 2 + l + sovBep(uint64(l))
 	}
+	l = len(m.Encrypted)
+	if l > 0 {
+		n += 2 + l + sovBep(uint64(l))
+	}
 	if m.LocalFlags != 0 {
 		n += 2 + sovBep(uint64(m.LocalFlags))
 	}
@@ -2108,6 +2908,135 @@ func (m *Close) ProtoSize() (n int) {
 	return n
 }
 
+func (m *FolderActivity) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Folders) > 0 {
+		for _, e := range m.Folders {
+			l = e.ProtoSize()
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *FolderStatus) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.ID)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	if m.Sequence != 0 {
+		n += 1 + sovBep(uint64(m.Sequence))
+	}
+	if m.LocalFiles != 0 {
+		n += 1 + sovBep(uint64(m.LocalFiles))
+	}
+	if m.LocalBytes != 0 {
+		n += 1 + sovBep(uint64(m.LocalBytes))
+	}
+	if m.Errors != 0 {
+		n += 1 + sovBep(uint64(m.Errors))
+	}
+	if m.Paused {
+		n += 2
+	}
+	l = len(m.State)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
+func (m *RescanHint) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Folder)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	if len(m.Names) > 0 {
+		for _, s := range m.Names {
+			l = len(s)
+			n += 1 + l + sovBep(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *BlockMismatch) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Folder)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	if m.Offset != 0 {
+		n += 1 + sovBep(uint64(m.Offset))
+	}
+	if m.Size != 0 {
+		n += 1 + sovBep(uint64(m.Size))
+	}
+	l = len(m.Hash)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
+func (m *ConfigPush) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.ID != 0 {
+		n += 1 + sovBep(uint64(m.ID))
+	}
+	l = len(m.Config)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
+func (m *ConfigPushResponse) ProtoSize() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.ID != 0 {
+		n += 1 + sovBep(uint64(m.ID))
+	}
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovBep(uint64(l))
+	}
+	return n
+}
+
 func sovBep(x uint64) (n int) {
 	for {
 		n++
@@ -2406,6 +3335,115 @@ func (m *ClusterConfig) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FolderActivity", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.FolderActivity = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RescanHints", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RescanHints = bool(v != 0)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockMismatch", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.BlockMismatch = bool(v != 0)
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PurgeFolders", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PurgeFolders = append(m.PurgeFolders, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConfigPush", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ConfigPush = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -2614,11 +3652,11 @@ func (m *Folder) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.Paused = bool(v != 0)
-		case 16:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Devices", wireType)
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SharesIgnores", wireType)
 			}
-			var msglen int
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2628,78 +3666,17 @@ func (m *Folder) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Devices = append(m.Devices, Device{})
-			if err := m.Devices[len(m.Devices)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipBep(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthBep
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *Device) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowBep
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Device: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Device: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			m.SharesIgnores = bool(v != 0)
+		case 9:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field IgnorePatterns", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2709,27 +3686,26 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.ID.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.IgnorePatterns = append(m.IgnorePatterns, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SharesMetadata", wireType)
 			}
-			var stringLen uint64
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2739,26 +3715,17 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Name = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Addresses", wireType)
+			m.SharesMetadata = bool(v != 0)
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MetadataChanged", wireType)
 			}
-			var stringLen uint64
+			m.MetadataChanged = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2768,26 +3735,16 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.MetadataChanged |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Addresses = append(m.Addresses, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
-		case 4:
+		case 12:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Compression", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LocalLabel", wireType)
 			}
-			m.Compression = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2797,14 +3754,15 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Compression |= (Compression(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 5:
+			m.LocalLabel = bool(v != 0)
+		case 13:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CertName", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field FolderDescription", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2829,30 +3787,11 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.CertName = string(dAtA[iNdEx:postIndex])
+			m.FolderDescription = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 6:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MaxSequence", wireType)
-			}
-			m.MaxSequence = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.MaxSequence |= (int64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 7:
+		case 14:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Introducer", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LocalDescription", wireType)
 			}
 			var v int
 			for shift := uint(0); ; shift += 7 {
@@ -2869,12 +3808,12 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
-			m.Introducer = bool(v != 0)
-		case 8:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field IndexID", wireType)
+			m.LocalDescription = bool(v != 0)
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metadata", wireType)
 			}
-			m.IndexID = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2884,16 +3823,28 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.IndexID |= (IndexID(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 9:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SkipIntroductionRemovals", wireType)
+			if msglen < 0 {
+				return ErrInvalidLengthBep
 			}
-			var v int
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Metadata = append(m.Metadata, FolderMetadata{})
+			if err := m.Metadata[len(m.Metadata)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Devices", wireType)
+			}
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -2903,12 +3854,23 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.SkipIntroductionRemovals = bool(v != 0)
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Devices = append(m.Devices, Device{})
+			if err := m.Devices[len(m.Devices)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -2930,7 +3892,7 @@ func (m *Device) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Index) Unmarshal(dAtA []byte) error {
+func (m *FolderMetadata) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2953,15 +3915,15 @@ func (m *Index) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Index: wiretype end group for non-group")
+			return fmt.Errorf("proto: FolderMetadata: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Index: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: FolderMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2986,13 +3948,13 @@ func (m *Index) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Folder = string(dAtA[iNdEx:postIndex])
+			m.Key = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3002,23 +3964,41 @@ func (m *Index) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Files = append(m.Files, FileInfo{})
-			if err := m.Files[len(m.Files)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Value = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Local", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Local = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -3040,7 +4020,7 @@ func (m *Index) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
+func (m *Device) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3063,17 +4043,17 @@ func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: IndexUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: Device: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: IndexUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Device: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
 			}
-			var stringLen uint64
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3083,26 +4063,27 @@ func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + byteLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Folder = string(dAtA[iNdEx:postIndex])
+			if err := m.ID.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3112,23 +4093,1134 @@ func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthBep
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Addresses", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Addresses = append(m.Addresses, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Compression", wireType)
+			}
+			m.Compression = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Compression |= (Compression(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CertName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CertName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxSequence", wireType)
+			}
+			m.MaxSequence = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxSequence |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Introducer", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Introducer = bool(v != 0)
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IndexID", wireType)
+			}
+			m.IndexID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IndexID |= (IndexID(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkipIntroductionRemovals", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.SkipIntroductionRemovals = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Index) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Index: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Index: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Folder = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Files = append(m.Files, FileInfo{})
+			if err := m.Files[len(m.Files)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: IndexUpdate: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: IndexUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Folder = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Files", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Files = append(m.Files, FileInfo{})
+			if err := m.Files[len(m.Files)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FileInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FileInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FileInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= (FileInfoType(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			}
+			m.Size = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Permissions", wireType)
+			}
+			m.Permissions = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Permissions |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedS", wireType)
+			}
+			m.ModifiedS = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedS |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Deleted", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Deleted = bool(v != 0)
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RawInvalid", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RawInvalid = bool(v != 0)
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NoPermissions", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.NoPermissions = bool(v != 0)
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sequence", wireType)
+			}
+			m.Sequence = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Sequence |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedNs", wireType)
+			}
+			m.ModifiedNs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedNs |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedBy", wireType)
+			}
+			m.ModifiedBy = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ModifiedBy |= (ShortID(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RawBlockSize", wireType)
+			}
+			m.RawBlockSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RawBlockSize |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Blocks", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Blocks = append(m.Blocks, BlockInfo{})
+			if err := m.Blocks[len(m.Blocks)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SymlinkTarget", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SymlinkTarget = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Encrypted", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Encrypted = append(m.Encrypted[:0], dAtA[iNdEx:postIndex]...)
+			if m.Encrypted == nil {
+				m.Encrypted = []byte{}
+			}
+			iNdEx = postIndex
+		case 1000:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LocalFlags", wireType)
+			}
+			m.LocalFlags = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LocalFlags |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BlockInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BlockInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BlockInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+			}
+			m.Offset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Offset |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			}
+			m.Size = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WeakHash", wireType)
+			}
+			m.WeakHash = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.WeakHash |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Vector) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Vector: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Vector: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Counters", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
 			}
 			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Files = append(m.Files, FileInfo{})
-			if err := m.Files[len(m.Files)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.Counters = append(m.Counters, Counter{})
+			if err := m.Counters[len(m.Counters)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
-			iNdEx = postIndex
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Counter) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Counter: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Counter: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+			}
+			m.ID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ID |= (ShortID(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			m.Value = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Value |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -3150,7 +5242,7 @@ func (m *IndexUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *FileInfo) Unmarshal(dAtA []byte) error {
+func (m *Request) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3173,17 +5265,17 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: FileInfo: wiretype end group for non-group")
+			return fmt.Errorf("proto: Request: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: FileInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Request: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
 			}
-			var stringLen uint64
+			m.ID = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3193,26 +5285,16 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.ID |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Name = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
 			}
-			m.Type = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3222,16 +5304,26 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Type |= (FileInfoType(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Folder = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			m.Size = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3241,16 +5333,26 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Size |= (int64(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 4:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Permissions", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
 			}
-			m.Permissions = 0
+			m.Offset = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3260,16 +5362,16 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Permissions |= (uint32(b) & 0x7F) << shift
+				m.Offset |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 5:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedS", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
 			}
-			m.ModifiedS = 0
+			m.Size = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3279,16 +5381,16 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ModifiedS |= (int64(b) & 0x7F) << shift
+				m.Size |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 6:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Deleted", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
 			}
-			var v int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3298,15 +5400,26 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.Deleted = bool(v != 0)
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
+			}
+			iNdEx = postIndex
 		case 7:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RawInvalid", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field FromTemporary", wireType)
 			}
 			var v int
 			for shift := uint(0); ; shift += 7 {
@@ -3323,12 +5436,12 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
-			m.RawInvalid = bool(v != 0)
+			m.FromTemporary = bool(v != 0)
 		case 8:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field NoPermissions", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field WeakHash", wireType)
 			}
-			var v int
+			m.WeakHash = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3338,66 +5451,66 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				m.WeakHash |= (uint32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.NoPermissions = bool(v != 0)
-		case 9:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			if msglen < 0 {
+			if skippy < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Response) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
 			}
-			iNdEx = postIndex
-		case 10:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Sequence", wireType)
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
 			}
-			m.Sequence = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Sequence |= (int64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-		case 11:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Response: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Response: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedNs", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
 			}
-			m.ModifiedNs = 0
+			m.ID = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3407,16 +5520,16 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ModifiedNs |= (int32(b) & 0x7F) << shift
+				m.ID |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 12:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ModifiedBy", wireType)
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
 			}
-			m.ModifiedBy = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3426,35 +5539,28 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ModifiedBy |= (ShortID(b) & 0x7F) << shift
+				byteLen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 13:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RawBlockSize", wireType)
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
 			}
-			m.RawBlockSize = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.RawBlockSize |= (int32(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
-		case 16:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Blocks", wireType)
+			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
+			if m.Data == nil {
+				m.Data = []byte{}
 			}
-			var msglen int
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Code", wireType)
+			}
+			m.Code = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3464,26 +5570,64 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.Code |= (ErrorCode(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Blocks = append(m.Blocks, BlockInfo{})
-			if err := m.Blocks[len(m.Blocks)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-			iNdEx = postIndex
-		case 17:
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: DownloadProgress: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: DownloadProgress: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SymlinkTarget", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3508,13 +5652,13 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.SymlinkTarget = string(dAtA[iNdEx:postIndex])
+			m.Folder = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 1000:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field LocalFlags", wireType)
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Updates", wireType)
 			}
-			m.LocalFlags = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3524,11 +5668,23 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.LocalFlags |= (uint32(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			if msglen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Updates = append(m.Updates, FileDownloadProgressUpdate{})
+			if err := m.Updates[len(m.Updates)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -3550,7 +5706,7 @@ func (m *FileInfo) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *BlockInfo) Unmarshal(dAtA []byte) error {
+func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3573,17 +5729,17 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: BlockInfo: wiretype end group for non-group")
+			return fmt.Errorf("proto: FileDownloadProgressUpdate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: BlockInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: FileDownloadProgressUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field UpdateType", wireType)
 			}
-			m.Offset = 0
+			m.UpdateType = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3593,16 +5749,16 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Offset |= (int64(b) & 0x7F) << shift
+				m.UpdateType |= (FileDownloadProgressUpdateType(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			m.Size = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3612,16 +5768,26 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Size |= (int32(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
 			}
-			var byteLen int
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3631,41 +5797,94 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + msglen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
-			if m.Hash == nil {
-				m.Hash = []byte{}
+			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
 			iNdEx = postIndex
 		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field WeakHash", wireType)
-			}
-			m.WeakHash = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
+			if wireType == 0 {
+				var v int32
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowBep
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					v |= (int32(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
 				}
-				if iNdEx >= l {
+				m.BlockIndexes = append(m.BlockIndexes, v)
+			} else if wireType == 2 {
+				var packedLen int
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowBep
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					packedLen |= (int(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				if packedLen < 0 {
+					return ErrInvalidLengthBep
+				}
+				postIndex := iNdEx + packedLen
+				if postIndex > l {
 					return io.ErrUnexpectedEOF
 				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.WeakHash |= (uint32(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
+				var elementCount int
+				var count int
+				for _, integer := range dAtA {
+					if integer < 128 {
+						count++
+					}
+				}
+				elementCount = count
+				if elementCount != 0 && len(m.BlockIndexes) == 0 {
+					m.BlockIndexes = make([]int32, 0, elementCount)
+				}
+				for iNdEx < postIndex {
+					var v int32
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowBep
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						v |= (int32(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					m.BlockIndexes = append(m.BlockIndexes, v)
 				}
+			} else {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockIndexes", wireType)
 			}
 		default:
 			iNdEx = preIndex
@@ -3688,7 +5907,7 @@ func (m *BlockInfo) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Vector) Unmarshal(dAtA []byte) error {
+func (m *Ping) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3711,17 +5930,67 @@ func (m *Vector) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Vector: wiretype end group for non-group")
+			return fmt.Errorf("proto: Ping: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Vector: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Ping: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipBep(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthBep
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Close) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowBep
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Close: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Close: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Counters", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3731,22 +6000,20 @@ func (m *Vector) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Counters = append(m.Counters, Counter{})
-			if err := m.Counters[len(m.Counters)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -3769,7 +6036,7 @@ func (m *Vector) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Counter) Unmarshal(dAtA []byte) error {
+func (m *FolderActivity) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3792,17 +6059,17 @@ func (m *Counter) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Counter: wiretype end group for non-group")
+			return fmt.Errorf("proto: FolderActivity: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Counter: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: FolderActivity: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Folders", wireType)
 			}
-			m.ID = 0
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3812,30 +6079,23 @@ func (m *Counter) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ID |= (ShortID(b) & 0x7F) << shift
+				msglen |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			if msglen < 0 {
+				return ErrInvalidLengthBep
 			}
-			m.Value = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Value |= (uint64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Folders = append(m.Folders, FolderStatus{})
+			if err := m.Folders[len(m.Folders)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -3857,7 +6117,7 @@ func (m *Counter) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Request) Unmarshal(dAtA []byte) error {
+func (m *FolderStatus) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3880,63 +6140,15 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Request: wiretype end group for non-group")
+			return fmt.Errorf("proto: FolderStatus: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Request: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: FolderStatus: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
-			}
-			m.ID = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.ID |= (int32(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Folder = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -3961,13 +6173,13 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Name = string(dAtA[iNdEx:postIndex])
+			m.ID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 4:
+		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Sequence", wireType)
 			}
-			m.Offset = 0
+			m.Sequence = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -3977,35 +6189,16 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Offset |= (int64(b) & 0x7F) << shift
+				m.Sequence |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 5:
+		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
-			}
-			m.Size = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Size |= (int32(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 6:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LocalFiles", wireType)
 			}
-			var byteLen int
+			m.LocalFiles = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4015,28 +6208,16 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				m.LocalFiles |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
-			if m.Hash == nil {
-				m.Hash = []byte{}
-			}
-			iNdEx = postIndex
-		case 7:
+		case 4:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FromTemporary", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LocalBytes", wireType)
 			}
-			var v int
+			m.LocalBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4046,17 +6227,16 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= (int(b) & 0x7F) << shift
+				m.LocalBytes |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.FromTemporary = bool(v != 0)
-		case 8:
+		case 5:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field WeakHash", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Errors", wireType)
 			}
-			m.WeakHash = 0
+			m.Errors = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4066,66 +6246,16 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.WeakHash |= (uint32(b) & 0x7F) << shift
+				m.Errors |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipBep(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthBep
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *Response) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowBep
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Response: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Response: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 6:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Paused", wireType)
 			}
-			m.ID = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4135,16 +6265,17 @@ func (m *Response) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ID |= (int32(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
+			m.Paused = bool(v != 0)
+		case 7:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Data", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field State", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4154,42 +6285,21 @@ func (m *Response) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Data = append(m.Data[:0], dAtA[iNdEx:postIndex]...)
-			if m.Data == nil {
-				m.Data = []byte{}
-			}
+			m.State = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Code", wireType)
-			}
-			m.Code = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowBep
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Code |= (ErrorCode(b) & 0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -4211,7 +6321,7 @@ func (m *Response) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
+func (m *RescanHint) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4234,10 +6344,10 @@ func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: DownloadProgress: wiretype end group for non-group")
+			return fmt.Errorf("proto: RescanHint: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: DownloadProgress: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: RescanHint: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -4271,9 +6381,9 @@ func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Updates", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Names", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4283,22 +6393,20 @@ func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthBep
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Updates = append(m.Updates, FileDownloadProgressUpdate{})
-			if err := m.Updates[len(m.Updates)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Names = append(m.Names, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -4321,7 +6429,7 @@ func (m *DownloadProgress) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
+func (m *BlockMismatch) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4344,17 +6452,17 @@ func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: FileDownloadProgressUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: BlockMismatch: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: FileDownloadProgressUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: BlockMismatch: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UpdateType", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Folder", wireType)
 			}
-			m.UpdateType = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4364,11 +6472,21 @@ func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.UpdateType |= (FileDownloadProgressUpdateType(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Folder = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
@@ -4399,10 +6517,10 @@ func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 			m.Name = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Version", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Offset", wireType)
 			}
-			var msglen int
+			m.Offset = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowBep
@@ -4412,95 +6530,61 @@ func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.Offset |= (int64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthBep
-			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			if err := m.Version.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			iNdEx = postIndex
 		case 4:
-			if wireType == 0 {
-				var v int32
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowBep
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					v |= (int32(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				m.BlockIndexes = append(m.BlockIndexes, v)
-			} else if wireType == 2 {
-				var packedLen int
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowBep
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					packedLen |= (int(b) & 0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				if packedLen < 0 {
-					return ErrInvalidLengthBep
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size", wireType)
+			}
+			m.Size = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
 				}
-				postIndex := iNdEx + packedLen
-				if postIndex > l {
+				if iNdEx >= l {
 					return io.ErrUnexpectedEOF
 				}
-				var elementCount int
-				var count int
-				for _, integer := range dAtA {
-					if integer < 128 {
-						count++
-					}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
 				}
-				elementCount = count
-				if elementCount != 0 && len(m.BlockIndexes) == 0 {
-					m.BlockIndexes = make([]int32, 0, elementCount)
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
 				}
-				for iNdEx < postIndex {
-					var v int32
-					for shift := uint(0); ; shift += 7 {
-						if shift >= 64 {
-							return ErrIntOverflowBep
-						}
-						if iNdEx >= l {
-							return io.ErrUnexpectedEOF
-						}
-						b := dAtA[iNdEx]
-						iNdEx++
-						v |= (int32(b) & 0x7F) << shift
-						if b < 0x80 {
-							break
-						}
-					}
-					m.BlockIndexes = append(m.BlockIndexes, v)
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
 				}
-			} else {
-				return fmt.Errorf("proto: wrong wireType = %d for field BlockIndexes", wireType)
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
 			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -4522,7 +6606,7 @@ func (m *FileDownloadProgressUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Ping) Unmarshal(dAtA []byte) error {
+func (m *ConfigPush) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4545,12 +6629,93 @@ func (m *Ping) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Ping: wiretype end group for non-group")
+			return fmt.Errorf("proto: ConfigPush: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Ping: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ConfigPush: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+			}
+			m.ID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ID |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Config", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Config = append(m.Config[:0], dAtA[iNdEx:postIndex]...)
+			if m.Config == nil {
+				m.Config = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthBep
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipBep(dAtA[iNdEx:])
@@ -4572,7 +6737,7 @@ func (m *Ping) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Close) Unmarshal(dAtA []byte) error {
+func (m *ConfigPushResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -4595,15 +6760,34 @@ func (m *Close) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Close: wiretype end group for non-group")
+			return fmt.Errorf("proto: ConfigPushResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Close: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ConfigPushResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ID", wireType)
+			}
+			m.ID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowBep
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ID |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -4628,7 +6812,7 @@ func (m *Close) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Reason = string(dAtA[iNdEx:postIndex])
+			m.Error = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -4756,121 +6940,156 @@ var (
 	ErrIntOverflowBep   = fmt.Errorf("proto: integer overflow")
 )
 
-func init() { proto.RegisterFile("bep.proto", fileDescriptor_bep_83d45b003aedf660) }
-
-var fileDescriptor_bep_83d45b003aedf660 = []byte{
-	// 1800 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0x4f, 0x6f, 0xdb, 0xc8,
-	0x15, 0x17, 0x25, 0xea, 0xdf, 0x93, 0xec, 0xa5, 0x27, 0x89, 0xcb, 0x72, 0xb3, 0x12, 0xa3, 0x24,
-	0x1b, 0xad, 0xb1, 0x4d, 0xd2, 0xdd, 0x6d, 0x8b, 0x16, 0x6d, 0x01, 0xfd, 0xa1, 0x1d, 0xa1, 0x8e,
-	0xe4, 0x8e, 0xe4, 0x6c, 0xb3, 0x87, 0x12, 0xb4, 0x38, 0x92, 0x89, 0x50, 0x1c, 0x95, 0xa4, 0xec,
-	0x68, 0x3f, 0x82, 0x4e, 0x3d, 0xf6, 0x22, 0x60, 0x81, 0x9e, 0xfa, 0x4d, 0x72, 0x4c, 0x7b, 0x28,
-	0x8a, 0x1e, 0x8c, 0xae, 0x73, 0xd9, 0x63, 0x3f, 0x41, 0x51, 0xcc, 0x0c, 0x29, 0x51, 0x76, 0xb2,
-	0xc8, 0x61, 0x4f, 0x9c, 0x79, 0xef, 0x37, 0x33, 0x7c, 0xbf, 0xf7, 0x7b, 0x6f, 0x06, 0x8a, 0x27,
-	0x64, 0xfa, 0x70, 0xea, 0xd3, 0x90, 0xa2, 0x02, 0xff, 0x0c, 0xa9, 0xab, 0xdd, 0xf5, 0xc9, 0x94,
-	0x06, 0x8f, 0xf8, 0xfc, 0x64, 0x36, 0x7a, 0x34, 0xa6, 0x63, 0xca, 0x27, 0x7c, 0x24, 0xe0, 0xb5,
-	0x29, 0x64, 0x9f, 0x10, 0xd7, 0xa5, 0xa8, 0x0a, 0x25, 0x9b, 0x9c, 0x39, 0x43, 0x62, 0x7a, 0xd6,
-	0x84, 0xa8, 0x92, 0x2e, 0xd5, 0x8b, 0x18, 0x84, 0xa9, 0x6b, 0x4d, 0x08, 0x03, 0x0c, 0x5d, 0x87,
-	0x78, 0xa1, 0x00, 0xa4, 0x05, 0x40, 0x98, 0x38, 0xe0, 0x3e, 0x6c, 0x47, 0x80, 0x33, 0xe2, 0x07,
-	0x0e, 0xf5, 0xd4, 0x0c, 0xc7, 0x6c, 0x09, 0xeb, 0x33, 0x61, 0xac, 0x05, 0x90, 0x7b, 0x42, 0x2c,
-	0x9b, 0xf8, 0xe8, 0x13, 0x90, 0xc3, 0xf9, 0x54, 0x9c, 0xb5, 0xfd, 0xd9, 0xad, 0x87, 0xf1, 0x9f,
-	0x3f, 0x7c, 0x4a, 0x82, 0xc0, 0x1a, 0x93, 0xc1, 0x7c, 0x4a, 0x30, 0x87, 0xa0, 0xdf, 0x42, 0x69,
-	0x48, 0x27, 0x53, 0x9f, 0x04, 0x7c, 0xe3, 0x34, 0x5f, 0x71, 0xfb, 0xda, 0x8a, 0xd6, 0x1a, 0x83,
-	0x93, 0x0b, 0x6a, 0x0d, 0xd8, 0x6a, 0xb9, 0xb3, 0x20, 0x24, 0x7e, 0x8b, 0x7a, 0x23, 0x67, 0x8c,
-	0x1e, 0x43, 0x7e, 0x44, 0x5d, 0x9b, 0xf8, 0x81, 0x2a, 0xe9, 0x99, 0x7a, 0xe9, 0x33, 0x65, 0xbd,
-	0xd9, 0x3e, 0x77, 0x34, 0xe5, 0x57, 0x17, 0xd5, 0x14, 0x8e, 0x61, 0xb5, 0xbf, 0xa6, 0x21, 0x27,
-	0x3c, 0x68, 0x17, 0xd2, 0x8e, 0x2d, 0x28, 0x6a, 0xe6, 0x2e, 0x2f, 0xaa, 0xe9, 0x4e, 0x1b, 0xa7,
-	0x1d, 0x1b, 0xdd, 0x84, 0xac, 0x6b, 0x9d, 0x10, 0x37, 0x22, 0x47, 0x4c, 0xd0, 0x87, 0x50, 0xf4,
-	0x89, 0x65, 0x9b, 0xd4, 0x73, 0xe7, 0x9c, 0x92, 0x02, 0x2e, 0x30, 0x43, 0xcf, 0x73, 0xe7, 0xe8,
-	0x27, 0x80, 0x9c, 0xb1, 0x47, 0x7d, 0x62, 0x4e, 0x89, 0x3f, 0x71, 0xf8, 0xdf, 0x06, 0xaa, 0xcc,
-	0x51, 0x3b, 0xc2, 0x73, 0xb4, 0x76, 0xa0, 0xbb, 0xb0, 0x15, 0xc1, 0x6d, 0xe2, 0x92, 0x90, 0xa8,
-	0x59, 0x8e, 0x2c, 0x0b, 0x63, 0x9b, 0xdb, 0xd0, 0x63, 0xb8, 0x69, 0x3b, 0x81, 0x75, 0xe2, 0x12,
-	0x33, 0x24, 0x93, 0xa9, 0xe9, 0x78, 0x36, 0x79, 0x49, 0x02, 0x35, 0xc7, 0xb1, 0x28, 0xf2, 0x0d,
-	0xc8, 0x64, 0xda, 0x11, 0x1e, 0xb4, 0x0b, 0xb9, 0xa9, 0x35, 0x0b, 0x88, 0xad, 0xe6, 0x39, 0x26,
-	0x9a, 0x31, 0x96, 0x84, 0x02, 0x02, 0x55, 0xb9, 0xca, 0x52, 0x9b, 0x3b, 0x62, 0x96, 0x22, 0x58,
-	0xed, 0xbf, 0x69, 0xc8, 0x09, 0x0f, 0xfa, 0x78, 0xc5, 0x52, 0xb9, 0xb9, 0xcb, 0x50, 0xff, 0xbe,
-	0xa8, 0x16, 0x84, 0xaf, 0xd3, 0x4e, 0xb0, 0x86, 0x40, 0x4e, 0x28, 0x8a, 0x8f, 0xd1, 0x6d, 0x28,
-	0x5a, 0xb6, 0xcd, 0xb2, 0x47, 0x02, 0x35, 0xa3, 0x67, 0xea, 0x45, 0xbc, 0x36, 0xa0, 0x5f, 0x6c,
-	0xaa, 0x41, 0xbe, 0xaa, 0x9f, 0x77, 0xc9, 0x80, 0xa5, 0x62, 0x48, 0xfc, 0x48, 0xc1, 0x59, 0x7e,
-	0x5e, 0x81, 0x19, 0xb8, 0x7e, 0xef, 0x40, 0x79, 0x62, 0xbd, 0x34, 0x03, 0xf2, 0xa7, 0x19, 0xf1,
-	0x86, 0x84, 0xd3, 0x95, 0xc1, 0xa5, 0x89, 0xf5, 0xb2, 0x1f, 0x99, 0x50, 0x05, 0xc0, 0xf1, 0x42,
-	0x9f, 0xda, 0xb3, 0x21, 0xf1, 0x23, 0xae, 0x12, 0x16, 0xf4, 0x33, 0x28, 0x70, 0xb2, 0x4d, 0xc7,
-	0x56, 0x0b, 0xba, 0x54, 0x97, 0x9b, 0x5a, 0x14, 0x78, 0x9e, 0x53, 0xcd, 0xe3, 0x8e, 0x87, 0x38,
-	0xcf, 0xb1, 0x1d, 0x1b, 0xfd, 0x1a, 0xb4, 0xe0, 0x85, 0xc3, 0x12, 0x25, 0x76, 0x0a, 0x1d, 0xea,
-	0x99, 0x3e, 0x99, 0xd0, 0x33, 0xcb, 0x0d, 0xd4, 0x22, 0x3f, 0x46, 0x65, 0x88, 0x4e, 0x02, 0x80,
-	0x23, 0x7f, 0xad, 0x07, 0x59, 0xbe, 0x23, 0xcb, 0xa2, 0x10, 0x6b, 0x54, 0xbd, 0xd1, 0x0c, 0x3d,
-	0x84, 0xec, 0xc8, 0x71, 0x49, 0xa0, 0xa6, 0x79, 0x0e, 0x51, 0x42, 0xe9, 0x8e, 0x4b, 0x3a, 0xde,
-	0x88, 0x46, 0x59, 0x14, 0xb0, 0xda, 0x31, 0x94, 0xf8, 0x86, 0xc7, 0x53, 0xdb, 0x0a, 0xc9, 0x0f,
-	0xb6, 0xed, 0x85, 0x0c, 0x85, 0xd8, 0xb3, 0x4a, 0xba, 0x94, 0x48, 0xfa, 0x5e, 0xd4, 0x0f, 0x44,
-	0x75, 0xef, 0x5e, 0xdf, 0x2f, 0xd1, 0x10, 0x10, 0xc8, 0x81, 0xf3, 0x35, 0xe1, 0xf5, 0x94, 0xc1,
-	0x7c, 0x8c, 0x74, 0x28, 0x5d, 0x2d, 0xa2, 0x2d, 0x9c, 0x34, 0xa1, 0x8f, 0x00, 0x26, 0xd4, 0x76,
-	0x46, 0x0e, 0xb1, 0xcd, 0x80, 0x0b, 0x20, 0x83, 0x8b, 0xb1, 0xa5, 0x8f, 0x54, 0x26, 0x77, 0x56,
-	0x42, 0x76, 0x54, 0x2b, 0xf1, 0x14, 0xd5, 0x21, 0xef, 0x78, 0x67, 0x96, 0xeb, 0x44, 0x15, 0xd2,
-	0xdc, 0xbe, 0xbc, 0xa8, 0x02, 0xb6, 0xce, 0x3b, 0xc2, 0x8a, 0x63, 0x37, 0xeb, 0x82, 0x1e, 0xdd,
-	0x28, 0xe6, 0x02, 0xdf, 0x6a, 0xcb, 0xa3, 0xc9, 0x42, 0x7e, 0x0c, 0xf9, 0xb8, 0x4b, 0xb2, 0xfc,
-	0x6e, 0x54, 0xd6, 0x33, 0x32, 0x0c, 0xe9, 0xaa, 0xff, 0x44, 0x30, 0xa4, 0x41, 0x61, 0x25, 0x4d,
-	0xe0, 0x7f, 0xbe, 0x9a, 0xb3, 0xde, 0xbc, 0x8a, 0xcb, 0x0b, 0xd4, 0x92, 0x2e, 0xd5, 0xb3, 0x78,
-	0x15, 0x6a, 0x97, 0x1d, 0xb7, 0x06, 0x9c, 0xcc, 0xd5, 0x32, 0xd7, 0xe6, 0x07, 0xb1, 0x36, 0xfb,
-	0xa7, 0xd4, 0x0f, 0x3b, 0xed, 0xf5, 0x8a, 0xe6, 0x1c, 0x3d, 0x02, 0x38, 0x71, 0xe9, 0xf0, 0x85,
-	0xc9, 0x69, 0xde, 0x62, 0x3b, 0x36, 0x95, 0xcb, 0x8b, 0x6a, 0x19, 0x5b, 0xe7, 0x4d, 0xe6, 0xe8,
-	0x3b, 0x5f, 0x13, 0x5c, 0x3c, 0x89, 0x87, 0xe8, 0xa7, 0x90, 0xe3, 0xf6, 0xb8, 0x55, 0xdc, 0x58,
-	0x07, 0xc4, 0xed, 0x09, 0x41, 0x44, 0x40, 0xc6, 0x55, 0x30, 0x9f, 0xb8, 0x8e, 0xf7, 0xc2, 0x0c,
-	0x2d, 0x7f, 0x4c, 0x42, 0x75, 0x47, 0xdc, 0x18, 0x91, 0x75, 0xc0, 0x8d, 0x2c, 0xaf, 0x2e, 0x1d,
-	0x5a, 0xae, 0x39, 0x72, 0xad, 0x71, 0xa0, 0x7e, 0x97, 0xe7, 0x89, 0x05, 0x6e, 0xdb, 0x67, 0xa6,
-	0x5f, 0xc9, 0x7f, 0xf9, 0xa6, 0x9a, 0xaa, 0x79, 0x50, 0x5c, 0x9d, 0xc4, 0x54, 0x4b, 0x47, 0xa3,
-	0x80, 0x84, 0x5c, 0x62, 0x19, 0x1c, 0xcd, 0x56, 0xc2, 0x49, 0x73, 0x8e, 0x84, 0x70, 0x10, 0xc8,
-	0xa7, 0x56, 0x70, 0xca, 0xc5, 0x54, 0xc6, 0x7c, 0xcc, 0x5a, 0xc5, 0x39, 0xb1, 0x5e, 0x98, 0xdc,
-	0x21, 0xa4, 0x54, 0x60, 0x86, 0x27, 0x56, 0x70, 0x1a, 0x9d, 0xf7, 0x1b, 0xc8, 0x89, 0x54, 0xa1,
-	0xcf, 0xa1, 0x30, 0xa4, 0x33, 0x2f, 0x5c, 0x5f, 0x27, 0x3b, 0xc9, 0x6e, 0xc4, 0x3d, 0x51, 0xec,
-	0x2b, 0x60, 0x6d, 0x1f, 0xf2, 0x91, 0x0b, 0xdd, 0x5f, 0xb5, 0x4a, 0xb9, 0x79, 0xeb, 0x4a, 0x56,
-	0x36, 0xef, 0x97, 0x33, 0xcb, 0x9d, 0x89, 0x9f, 0x97, 0xb1, 0x98, 0xd4, 0xfe, 0x2e, 0x41, 0x1e,
-	0x33, 0x25, 0x04, 0x61, 0xe2, 0x66, 0xca, 0x6e, 0xdc, 0x4c, 0xeb, 0x1a, 0x4e, 0x6f, 0xd4, 0x70,
-	0x5c, 0x86, 0x99, 0x44, 0x19, 0xae, 0x99, 0x93, 0xdf, 0xca, 0x5c, 0xf6, 0x2d, 0xcc, 0xe5, 0x12,
-	0xcc, 0xdd, 0x87, 0xed, 0x91, 0x4f, 0x27, 0xfc, 0xee, 0xa1, 0xbe, 0xe5, 0xcf, 0xa3, 0x46, 0xb9,
-	0xc5, 0xac, 0x83, 0xd8, 0xb8, 0x49, 0x70, 0x61, 0x93, 0xe0, 0x9a, 0x09, 0x05, 0x4c, 0x82, 0x29,
-	0xf5, 0x02, 0xf2, 0xce, 0x98, 0x10, 0xc8, 0xb6, 0x15, 0x5a, 0x3c, 0xa2, 0x32, 0xe6, 0x63, 0xf4,
-	0x00, 0xe4, 0x21, 0xb5, 0x45, 0x3c, 0xdb, 0x49, 0x09, 0x1a, 0xbe, 0x4f, 0xfd, 0x16, 0xb5, 0x09,
-	0xe6, 0x80, 0xda, 0x14, 0x94, 0x36, 0x3d, 0xf7, 0x5c, 0x6a, 0xd9, 0x47, 0x3e, 0x1d, 0xb3, 0x0b,
-	0xe2, 0x9d, 0x8d, 0xae, 0x0d, 0xf9, 0x19, 0x6f, 0x85, 0x71, 0xab, 0xbb, 0xb7, 0xd9, 0x9a, 0xae,
-	0x6e, 0x24, 0xfa, 0x66, 0x5c, 0xbf, 0xd1, 0xd2, 0xda, 0x3f, 0x25, 0xd0, 0xde, 0x8d, 0x46, 0x1d,
-	0x28, 0x09, 0xa4, 0x99, 0x78, 0x13, 0xd5, 0xdf, 0xe7, 0x20, 0xde, 0x15, 0x61, 0xb6, 0x1a, 0xbf,
-	0xf5, 0x42, 0x4d, 0xf4, 0x9b, 0xcc, 0xfb, 0xf5, 0x9b, 0x07, 0xb0, 0x25, 0x1a, 0x40, 0xfc, 0x7c,
-	0x90, 0xf5, 0x4c, 0x3d, 0xdb, 0x4c, 0x2b, 0x29, 0x5c, 0x3e, 0x11, 0x65, 0xc6, 0xed, 0xb5, 0x1c,
-	0xc8, 0x47, 0x8e, 0x37, 0xae, 0x55, 0x21, 0xdb, 0x72, 0x29, 0x4f, 0x58, 0xce, 0x27, 0x56, 0x40,
-	0xbd, 0x98, 0x47, 0x31, 0xdb, 0xfb, 0x47, 0x1a, 0x4a, 0x89, 0xa7, 0x1d, 0x7a, 0x0c, 0xdb, 0xad,
-	0xc3, 0xe3, 0xfe, 0xc0, 0xc0, 0x66, 0xab, 0xd7, 0xdd, 0xef, 0x1c, 0x28, 0x29, 0xed, 0xf6, 0x62,
-	0xa9, 0xab, 0x93, 0x35, 0x68, 0xf3, 0xd5, 0x56, 0x85, 0x6c, 0xa7, 0xdb, 0x36, 0xfe, 0xa0, 0x48,
-	0xda, 0xcd, 0xc5, 0x52, 0x57, 0x12, 0x40, 0x71, 0x05, 0x7e, 0x0a, 0x65, 0x0e, 0x30, 0x8f, 0x8f,
-	0xda, 0x8d, 0x81, 0xa1, 0xa4, 0x35, 0x6d, 0xb1, 0xd4, 0x77, 0xaf, 0xe2, 0x22, 0xce, 0xef, 0x42,
-	0x1e, 0x1b, 0xbf, 0x3f, 0x36, 0xfa, 0x03, 0x25, 0xa3, 0xed, 0x2e, 0x96, 0x3a, 0x4a, 0x00, 0xe3,
-	0x92, 0xba, 0x0f, 0x05, 0x6c, 0xf4, 0x8f, 0x7a, 0xdd, 0xbe, 0xa1, 0xc8, 0xda, 0x8f, 0x16, 0x4b,
-	0xfd, 0xc6, 0x06, 0x2a, 0x52, 0xe9, 0xcf, 0x61, 0xa7, 0xdd, 0xfb, 0xb2, 0x7b, 0xd8, 0x6b, 0xb4,
-	0xcd, 0x23, 0xdc, 0x3b, 0xc0, 0x46, 0xbf, 0xaf, 0x64, 0xb5, 0xea, 0x62, 0xa9, 0x7f, 0x98, 0xc0,
-	0x5f, 0x13, 0xdd, 0x47, 0x20, 0x1f, 0x75, 0xba, 0x07, 0x4a, 0x4e, 0xbb, 0xb1, 0x58, 0xea, 0x1f,
-	0x24, 0xa0, 0x8c, 0x54, 0x16, 0x71, 0xeb, 0xb0, 0xd7, 0x37, 0x94, 0xfc, 0xb5, 0x88, 0x39, 0xd9,
-	0x7b, 0x7f, 0x04, 0x74, 0xfd, 0xf1, 0x8b, 0xee, 0x81, 0xdc, 0xed, 0x75, 0x0d, 0x25, 0x25, 0xe2,
-	0xbf, 0x8e, 0xe8, 0x52, 0x8f, 0xa0, 0x1a, 0x64, 0x0e, 0xbf, 0xfa, 0x42, 0x91, 0xb4, 0x1f, 0x2f,
-	0x96, 0xfa, 0xad, 0xeb, 0xa0, 0xc3, 0xaf, 0xbe, 0xd8, 0xa3, 0x50, 0x4a, 0x6e, 0x5c, 0x83, 0xc2,
-	0x53, 0x63, 0xd0, 0x68, 0x37, 0x06, 0x0d, 0x25, 0x25, 0x7e, 0x29, 0x76, 0x3f, 0x25, 0xa1, 0xc5,
-	0x8b, 0xf0, 0x36, 0x64, 0xbb, 0xc6, 0x33, 0x03, 0x2b, 0x92, 0xb6, 0xb3, 0x58, 0xea, 0x5b, 0x31,
-	0xa0, 0x4b, 0xce, 0x88, 0x8f, 0x2a, 0x90, 0x6b, 0x1c, 0x7e, 0xd9, 0x78, 0xde, 0x57, 0xd2, 0x1a,
-	0x5a, 0x2c, 0xf5, 0xed, 0xd8, 0xdd, 0x70, 0xcf, 0xad, 0x79, 0xb0, 0xf7, 0x3f, 0x09, 0xca, 0xc9,
-	0x0b, 0x1f, 0x55, 0x40, 0xde, 0xef, 0x1c, 0x1a, 0xf1, 0x71, 0x49, 0x1f, 0x1b, 0xa3, 0x3a, 0x14,
-	0xdb, 0x1d, 0x6c, 0xb4, 0x06, 0x3d, 0xfc, 0x3c, 0x8e, 0x25, 0x09, 0x6a, 0x3b, 0x3e, 0x17, 0xf8,
-	0x1c, 0xfd, 0x12, 0xca, 0xfd, 0xe7, 0x4f, 0x0f, 0x3b, 0xdd, 0xdf, 0x99, 0x7c, 0xc7, 0xb4, 0xf6,
-	0x60, 0xb1, 0xd4, 0xef, 0x6c, 0x80, 0xc9, 0xd4, 0x27, 0x43, 0x2b, 0x24, 0x76, 0x5f, 0xdc, 0x41,
-	0xcc, 0x59, 0x90, 0x50, 0x0b, 0x76, 0xe2, 0xa5, 0xeb, 0xc3, 0x32, 0xda, 0xa7, 0x8b, 0xa5, 0xfe,
-	0xf1, 0xf7, 0xae, 0x5f, 0x9d, 0x5e, 0x90, 0xd0, 0x3d, 0xc8, 0x47, 0x9b, 0xc4, 0x4a, 0x4a, 0x2e,
-	0x8d, 0x16, 0xec, 0xfd, 0x4d, 0x82, 0xe2, 0xaa, 0x5d, 0x31, 0xc2, 0xbb, 0x3d, 0xd3, 0xc0, 0xb8,
-	0x87, 0x63, 0x06, 0x56, 0xce, 0x2e, 0xe5, 0x43, 0x74, 0x07, 0xf2, 0x07, 0x46, 0xd7, 0xc0, 0x9d,
-	0x56, 0x5c, 0x18, 0x2b, 0xc8, 0x01, 0xf1, 0x88, 0xef, 0x0c, 0xd1, 0x27, 0x50, 0xee, 0xf6, 0xcc,
-	0xfe, 0x71, 0xeb, 0x49, 0x1c, 0x3a, 0x3f, 0x3f, 0xb1, 0x55, 0x7f, 0x36, 0x3c, 0xe5, 0x7c, 0xee,
-	0xb1, 0x1a, 0x7a, 0xd6, 0x38, 0xec, 0xb4, 0x05, 0x34, 0xa3, 0xa9, 0x8b, 0xa5, 0x7e, 0x73, 0x05,
-	0x8d, 0x9e, 0x3c, 0x0c, 0xbb, 0x67, 0x43, 0xe5, 0xfb, 0x1b, 0x13, 0xd2, 0x21, 0xd7, 0x38, 0x3a,
-	0x32, 0xba, 0xed, 0xf8, 0xef, 0xd7, 0xbe, 0xc6, 0x74, 0x4a, 0x3c, 0x9b, 0x21, 0xf6, 0x7b, 0xf8,
-	0xc0, 0x18, 0xc4, 0x3f, 0xbf, 0x46, 0xec, 0x53, 0xf6, 0x00, 0x68, 0xd6, 0x5f, 0x7d, 0x5b, 0x49,
-	0xbd, 0xfe, 0xb6, 0x92, 0x7a, 0x75, 0x59, 0x91, 0x5e, 0x5f, 0x56, 0xa4, 0xff, 0x5c, 0x56, 0x52,
-	0xdf, 0x5d, 0x56, 0xa4, 0x3f, 0xbf, 0xa9, 0xa4, 0xbe, 0x79, 0x53, 0x91, 0x5e, 0xbf, 0xa9, 0xa4,
-	0xfe, 0xf5, 0xa6, 0x92, 0x3a, 0xc9, 0xf1, 0xa6, 0xf6, 0xf9, 0xff, 0x03, 0x00, 0x00, 0xff, 0xff,
-	0x02, 0x4d, 0x9a, 0x1c, 0x11, 0x0f, 0x00, 0x00,
+func init() { proto.RegisterFile("bep.proto", fileDescriptor_bep_da6b78e804ae2436) }
+
+var fileDescriptor_bep_da6b78e804ae2436 = []byte{
+	// 2357 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0xcb, 0x6f, 0x1b, 0xc7,
+	0x19, 0xe7, 0x63, 0xf9, 0xfa, 0x48, 0x51, 0xab, 0xb1, 0xac, 0x32, 0x8c, 0x4d, 0xd1, 0x9b, 0x38,
+	0x56, 0xd4, 0xd4, 0x76, 0x9d, 0x34, 0x45, 0xdd, 0x07, 0xc0, 0xc7, 0x4a, 0x22, 0x42, 0x91, 0xea,
+	0x90, 0x72, 0x6a, 0x1f, 0xba, 0x58, 0x71, 0x47, 0xd4, 0xc2, 0xcb, 0x5d, 0x76, 0x77, 0x29, 0x9b,
+	0xe9, 0x7f, 0xc0, 0x53, 0x8f, 0xbd, 0x10, 0xc8, 0xb5, 0xff, 0x89, 0x51, 0xa0, 0x80, 0x7b, 0x29,
+	0x8a, 0x1e, 0x84, 0x46, 0xbe, 0xe4, 0xd8, 0x73, 0x51, 0x14, 0xc5, 0x3c, 0x76, 0xb9, 0xa4, 0xa4,
+	0x20, 0x87, 0x9e, 0x34, 0xf3, 0xfb, 0x7e, 0x33, 0xc3, 0xf9, 0x1e, 0xbf, 0xf9, 0x56, 0x90, 0x3b,
+	0x21, 0xe3, 0x87, 0x63, 0xd7, 0xf1, 0x1d, 0x94, 0x65, 0x7f, 0x06, 0x8e, 0x55, 0xfe, 0xc0, 0x25,
+	0x63, 0xc7, 0x7b, 0xc4, 0xe6, 0x27, 0x93, 0xd3, 0x47, 0x43, 0x67, 0xe8, 0xb0, 0x09, 0x1b, 0x71,
+	0xba, 0x32, 0x86, 0xd4, 0x01, 0xb1, 0x2c, 0x07, 0x6d, 0x43, 0xde, 0x20, 0xe7, 0xe6, 0x80, 0x68,
+	0xb6, 0x3e, 0x22, 0xa5, 0x78, 0x35, 0xbe, 0x93, 0xc3, 0xc0, 0xa1, 0x8e, 0x3e, 0x22, 0x94, 0x30,
+	0xb0, 0x4c, 0x62, 0xfb, 0x9c, 0x90, 0xe0, 0x04, 0x0e, 0x31, 0xc2, 0x7d, 0x28, 0x0a, 0xc2, 0x39,
+	0x71, 0x3d, 0xd3, 0xb1, 0x4b, 0x49, 0xc6, 0x59, 0xe3, 0xe8, 0x33, 0x0e, 0x2a, 0x1e, 0xa4, 0x0f,
+	0x88, 0x6e, 0x10, 0x17, 0x7d, 0x0c, 0x92, 0x3f, 0x1d, 0xf3, 0xb3, 0x8a, 0x4f, 0x6e, 0x3f, 0x0c,
+	0x7e, 0xf9, 0xc3, 0x43, 0xe2, 0x79, 0xfa, 0x90, 0xf4, 0xa7, 0x63, 0x82, 0x19, 0x05, 0xfd, 0x0a,
+	0xf2, 0x03, 0x67, 0x34, 0x76, 0x89, 0xc7, 0x36, 0x4e, 0xb0, 0x15, 0x77, 0xae, 0xac, 0x68, 0x2c,
+	0x38, 0x38, 0xba, 0x40, 0xf9, 0x77, 0x1c, 0xd6, 0x1a, 0xd6, 0xc4, 0xf3, 0x89, 0xdb, 0x70, 0xec,
+	0x53, 0x73, 0x88, 0x1e, 0x43, 0xe6, 0xd4, 0xb1, 0x0c, 0xe2, 0x7a, 0xa5, 0x78, 0x35, 0xb9, 0x93,
+	0x7f, 0x22, 0x2f, 0x76, 0xdb, 0x63, 0x86, 0xba, 0xf4, 0xe6, 0x62, 0x3b, 0x86, 0x03, 0x1a, 0x7a,
+	0x00, 0xeb, 0x7c, 0xa8, 0xe9, 0x03, 0xdf, 0x3c, 0x37, 0xfd, 0x29, 0xfb, 0x1d, 0x59, 0x5c, 0xe4,
+	0x70, 0x4d, 0xa0, 0xe8, 0x1e, 0x14, 0x5c, 0xe2, 0x0d, 0x74, 0x5b, 0x3b, 0x33, 0x6d, 0xdf, 0x63,
+	0x6e, 0xc8, 0xe2, 0x3c, 0xc7, 0x0e, 0x28, 0x44, 0x7d, 0x75, 0x62, 0x39, 0x83, 0x97, 0xda, 0xc8,
+	0xf4, 0x46, 0xba, 0x3f, 0x38, 0x2b, 0x49, 0x8c, 0xb4, 0xc6, 0xd0, 0x43, 0x01, 0xa2, 0x0f, 0x60,
+	0x6d, 0x3c, 0x71, 0x87, 0x44, 0x0b, 0x7e, 0x6a, 0xaa, 0x9a, 0xdc, 0xc9, 0xe1, 0x02, 0x03, 0xf7,
+	0xc4, 0xef, 0xa2, 0x81, 0x61, 0x77, 0xd2, 0xc6, 0x13, 0xef, 0xac, 0x94, 0x66, 0x1b, 0x01, 0x87,
+	0x8e, 0x26, 0xde, 0x99, 0x32, 0x4b, 0x41, 0x9a, 0x93, 0xd1, 0x16, 0x24, 0x4c, 0x83, 0x07, 0xb7,
+	0x9e, 0xbe, 0xbc, 0xd8, 0x4e, 0xb4, 0x9a, 0x38, 0x61, 0x1a, 0x68, 0x13, 0x52, 0x96, 0x7e, 0x42,
+	0x2c, 0x11, 0x56, 0x3e, 0x41, 0xef, 0x43, 0xce, 0x25, 0xba, 0xa1, 0x39, 0xb6, 0x35, 0x15, 0xb7,
+	0xc8, 0x52, 0xa0, 0x6b, 0x5b, 0x53, 0xf4, 0x23, 0x40, 0xe6, 0xd0, 0x76, 0x5c, 0xa2, 0x8d, 0x89,
+	0x3b, 0x32, 0x99, 0x9f, 0x3d, 0x71, 0x8d, 0x0d, 0x6e, 0x39, 0x5a, 0x18, 0xe8, 0x55, 0x04, 0xdd,
+	0x20, 0x16, 0xf1, 0x49, 0x29, 0xc5, 0x98, 0x05, 0x0e, 0x36, 0x19, 0x86, 0x1e, 0xc3, 0xa6, 0x61,
+	0x7a, 0xfa, 0x89, 0x45, 0x34, 0x9f, 0x8c, 0xc6, 0x9a, 0x69, 0x1b, 0xe4, 0x35, 0xf1, 0xc4, 0x9d,
+	0x90, 0xb0, 0xf5, 0xc9, 0x68, 0xdc, 0xe2, 0x16, 0xb4, 0x05, 0xe9, 0xb1, 0x3e, 0xf1, 0x88, 0x51,
+	0xca, 0x30, 0x8e, 0x98, 0x51, 0x07, 0x7b, 0x67, 0xba, 0x4b, 0x3c, 0x8d, 0x1f, 0xe0, 0x95, 0xb2,
+	0xdc, 0xc1, 0x1c, 0x6d, 0x71, 0x90, 0xc6, 0x34, 0xb8, 0x84, 0xee, 0xfb, 0xc4, 0xb5, 0xbd, 0x52,
+	0x8e, 0xb9, 0xb8, 0x28, 0x6e, 0x20, 0x50, 0x4a, 0x14, 0xfb, 0x8d, 0x88, 0xaf, 0x1b, 0xba, 0xaf,
+	0x97, 0x80, 0x07, 0x9f, 0xc3, 0x87, 0x02, 0x45, 0x1f, 0x83, 0x1c, 0x30, 0xb4, 0xc1, 0x99, 0x6e,
+	0x0f, 0x89, 0x51, 0xca, 0x57, 0xe3, 0x3b, 0x49, 0xbc, 0x1e, 0xe0, 0x0d, 0x0e, 0xd3, 0xc0, 0x59,
+	0xce, 0x40, 0xb7, 0x34, 0xee, 0xfa, 0x02, 0x0f, 0x1c, 0x83, 0xda, 0xcc, 0xff, 0x3f, 0xa5, 0x35,
+	0xe9, 0x0d, 0x5c, 0x73, 0xec, 0xd3, 0xac, 0x5f, 0x63, 0x61, 0xbb, 0x7d, 0x79, 0xb1, 0xbd, 0xc1,
+	0xc3, 0xd9, 0x5c, 0x18, 0x71, 0x94, 0x89, 0x7e, 0x08, 0x1b, 0x7c, 0xe7, 0xe8, 0xf2, 0x22, 0xdb,
+	0x5f, 0x66, 0x86, 0xc8, 0x4a, 0xf4, 0x14, 0xb2, 0xe1, 0x9d, 0xd6, 0x59, 0x29, 0x94, 0x56, 0x4b,
+	0x21, 0xb8, 0x9d, 0x28, 0x89, 0x90, 0x4f, 0xab, 0x88, 0x4b, 0x84, 0x57, 0x92, 0x57, 0xab, 0xa8,
+	0xc9, 0x0c, 0x41, 0x15, 0x09, 0x9a, 0xd2, 0x81, 0xe2, 0xf2, 0x9e, 0x48, 0x86, 0xe4, 0x4b, 0x32,
+	0x15, 0x8a, 0x43, 0x87, 0x34, 0x1b, 0xcf, 0x75, 0x6b, 0x12, 0x88, 0x0c, 0x9f, 0x50, 0x94, 0xfd,
+	0x76, 0x91, 0x89, 0x7c, 0xa2, 0xfc, 0x2b, 0x01, 0x69, 0x7e, 0x12, 0xfa, 0x28, 0x4c, 0xee, 0x42,
+	0x7d, 0x8b, 0x9e, 0xfa, 0x8f, 0x8b, 0xed, 0x2c, 0xb7, 0xb5, 0x9a, 0x91, 0x64, 0x47, 0x20, 0x45,
+	0x24, 0x8c, 0x8d, 0xd1, 0x1d, 0xc8, 0xe9, 0x86, 0x41, 0xe5, 0x82, 0xd0, 0x82, 0xa5, 0x29, 0xb0,
+	0x00, 0x68, 0x20, 0xa2, 0xf2, 0x23, 0xad, 0x0a, 0xd6, 0x4d, 0xba, 0x43, 0x2b, 0x68, 0x40, 0x5c,
+	0x21, 0x99, 0x29, 0x76, 0x5e, 0x96, 0x02, 0x4c, 0x30, 0xef, 0x41, 0x61, 0xa4, 0xbf, 0xd6, 0x3c,
+	0xf2, 0xbb, 0x09, 0xb1, 0x07, 0x84, 0x65, 0x79, 0x12, 0xe7, 0x47, 0xfa, 0xeb, 0x9e, 0x80, 0x50,
+	0x05, 0xc0, 0xb4, 0x7d, 0xd7, 0x31, 0x26, 0x03, 0xe2, 0x8a, 0x14, 0x8f, 0x20, 0xe8, 0x27, 0x90,
+	0x65, 0x35, 0xa2, 0x99, 0x06, 0x4b, 0x70, 0xa9, 0x5e, 0x16, 0x17, 0xcf, 0xb0, 0x0a, 0x61, 0xf7,
+	0x0e, 0x86, 0x38, 0xc3, 0xb8, 0x2d, 0x03, 0xfd, 0x02, 0xca, 0xde, 0x4b, 0x73, 0xac, 0x05, 0x3b,
+	0xd1, 0x3c, 0xd0, 0x5c, 0x32, 0x72, 0xce, 0x75, 0x8b, 0x56, 0x00, 0x3d, 0xa6, 0x44, 0x19, 0xad,
+	0x08, 0x01, 0x0b, 0xbb, 0xd2, 0x85, 0x14, 0xdb, 0x91, 0x16, 0x1f, 0x17, 0x26, 0x11, 0x3c, 0x31,
+	0x43, 0x0f, 0x21, 0x75, 0x6a, 0x5a, 0xc4, 0x2b, 0x25, 0x58, 0x4e, 0xa0, 0x48, 0x3a, 0x99, 0x16,
+	0x69, 0xd9, 0xa7, 0x8e, 0xc8, 0x0a, 0x4e, 0x53, 0x8e, 0x21, 0xcf, 0x36, 0x3c, 0x1e, 0x1b, 0xba,
+	0x4f, 0xfe, 0x6f, 0xdb, 0xfe, 0x47, 0x82, 0x6c, 0x60, 0x09, 0x83, 0x1e, 0x8f, 0x04, 0x7d, 0x57,
+	0x3c, 0x40, 0xfc, 0x39, 0xd9, 0xba, 0xba, 0x5f, 0xe4, 0x05, 0x42, 0x20, 0x79, 0xe6, 0x57, 0x84,
+	0x25, 0x5f, 0x12, 0xb3, 0x31, 0xaa, 0x42, 0x7e, 0x55, 0xfb, 0xd6, 0x70, 0x14, 0x42, 0x77, 0x01,
+	0x46, 0x8e, 0x61, 0x9e, 0x9a, 0xc4, 0xd0, 0x3c, 0x96, 0x00, 0x49, 0x9c, 0x0b, 0x90, 0x1e, 0x2a,
+	0xd1, 0xf2, 0xa1, 0xca, 0x67, 0x08, 0x89, 0x0b, 0xa6, 0x68, 0x07, 0x32, 0xa6, 0x7d, 0xae, 0x5b,
+	0xa6, 0x10, 0xb6, 0x7a, 0xf1, 0xf2, 0x62, 0x1b, 0xb0, 0xfe, 0xaa, 0xc5, 0x51, 0x1c, 0x98, 0xa9,
+	0xd2, 0xd9, 0xce, 0x92, 0x06, 0x0b, 0xa5, 0xb3, 0x9d, 0xa8, 0xfe, 0x3e, 0x86, 0x4c, 0xf0, 0x2c,
+	0xd3, 0xf8, 0x2e, 0x55, 0xea, 0x33, 0x32, 0xf0, 0x9d, 0xf0, 0xbd, 0x13, 0x34, 0x54, 0x86, 0x6c,
+	0x98, 0x9a, 0xc0, 0x7e, 0x79, 0x38, 0xa7, 0xd2, 0x15, 0xde, 0xcb, 0xf6, 0x98, 0xc0, 0xa5, 0x70,
+	0x78, 0xd5, 0x0e, 0x3d, 0x6e, 0x41, 0x38, 0x99, 0x32, 0x6d, 0x93, 0xea, 0xeb, 0x41, 0x6e, 0xf6,
+	0xce, 0x1c, 0xd7, 0x6f, 0x35, 0x17, 0x2b, 0xea, 0x53, 0xf4, 0x08, 0x80, 0x3f, 0x89, 0xcc, 0xcd,
+	0x54, 0xeb, 0x52, 0x75, 0xf9, 0xf2, 0x62, 0xbb, 0x80, 0xf5, 0x57, 0x75, 0x6a, 0xe8, 0x99, 0x5f,
+	0x11, 0x9c, 0x3b, 0x09, 0x86, 0xe8, 0xc7, 0x90, 0x66, 0x78, 0x20, 0x3d, 0xb7, 0x16, 0x17, 0x62,
+	0x78, 0x24, 0x21, 0x04, 0x91, 0xbd, 0x0a, 0xd3, 0x91, 0x65, 0xda, 0x2f, 0x35, 0x5f, 0x77, 0x87,
+	0xc4, 0x2f, 0x6d, 0xf0, 0x16, 0x45, 0xa0, 0x7d, 0x06, 0x52, 0x31, 0x20, 0xf6, 0xc0, 0x9d, 0x8e,
+	0x69, 0x60, 0x6e, 0x51, 0x3d, 0xc1, 0x0b, 0x80, 0x46, 0x9d, 0x8b, 0xeb, 0xa9, 0xa5, 0x0f, 0xbd,
+	0xd2, 0xb7, 0x19, 0x16, 0x76, 0xae, 0xdb, 0x7b, 0x14, 0x7a, 0x2a, 0xfd, 0xf1, 0xeb, 0xed, 0x98,
+	0x62, 0x43, 0x2e, 0xfc, 0x1d, 0x34, 0xa7, 0x9d, 0xd3, 0x53, 0x8f, 0xf8, 0x2c, 0x01, 0x93, 0x58,
+	0xcc, 0xc2, 0xb4, 0x4a, 0x30, 0x0f, 0xb2, 0x31, 0xc5, 0xce, 0x74, 0xef, 0x8c, 0xa5, 0x5a, 0x01,
+	0xb3, 0x31, 0x15, 0x92, 0x57, 0x44, 0x7f, 0xa9, 0x31, 0x03, 0x4f, 0xb4, 0x2c, 0x05, 0x0e, 0x74,
+	0xef, 0x4c, 0x9c, 0xf7, 0x4b, 0x48, 0xf3, 0x40, 0xa2, 0x4f, 0x21, 0x3b, 0x70, 0x26, 0xb6, 0xbf,
+	0x68, 0x6e, 0x36, 0xa2, 0x5a, 0xc5, 0x2c, 0x81, 0x94, 0x07, 0x44, 0x65, 0x0f, 0x32, 0xc2, 0x84,
+	0xee, 0x87, 0x42, 0x2a, 0xd5, 0x6f, 0xaf, 0xc4, 0x6c, 0xb9, 0x69, 0x58, 0xc8, 0xb4, 0x24, 0x64,
+	0x5a, 0xf9, 0x6b, 0x1c, 0x32, 0x98, 0xe6, 0x89, 0xe7, 0x47, 0xda, 0x8d, 0xd4, 0x52, 0xbb, 0xb1,
+	0xa8, 0xf0, 0xc4, 0x52, 0x85, 0x07, 0x45, 0x9a, 0x8c, 0x14, 0xe9, 0xc2, 0x73, 0xd2, 0xb5, 0x9e,
+	0x4b, 0x5d, 0xe3, 0xb9, 0x74, 0xc4, 0x73, 0xf7, 0xa1, 0x78, 0xea, 0x3a, 0x23, 0xd6, 0x50, 0x38,
+	0xae, 0xee, 0x4e, 0x85, 0x8c, 0xae, 0x51, 0xb4, 0x1f, 0x80, 0xcb, 0x0e, 0xce, 0x2e, 0x3b, 0x58,
+	0xd1, 0x20, 0x8b, 0x89, 0x37, 0x76, 0x6c, 0x8f, 0xdc, 0x78, 0x27, 0x04, 0x12, 0x7b, 0x42, 0x13,
+	0xfc, 0x6c, 0x3a, 0x46, 0x0f, 0x40, 0x1a, 0x38, 0x06, 0xbf, 0x4f, 0x31, 0x9a, 0xa0, 0xaa, 0xeb,
+	0x3a, 0x6e, 0xc3, 0x31, 0x08, 0x66, 0x04, 0x65, 0x0c, 0x72, 0xd3, 0x79, 0x65, 0x5b, 0x8e, 0x6e,
+	0x1c, 0xb9, 0xce, 0x90, 0x3e, 0x1f, 0x37, 0xca, 0x60, 0x13, 0x32, 0x13, 0x26, 0x94, 0x81, 0x10,
+	0x7e, 0xb8, 0x2c, 0x5c, 0xab, 0x1b, 0x71, 0x55, 0x0d, 0xaa, 0x5b, 0x2c, 0x55, 0xfe, 0x16, 0x87,
+	0xf2, 0xcd, 0x6c, 0xd4, 0x82, 0x3c, 0x67, 0x6a, 0x91, 0x16, 0x7d, 0xe7, 0xfb, 0x1c, 0xc4, 0x34,
+	0x13, 0x26, 0xe1, 0xf8, 0xda, 0xe7, 0x36, 0xa2, 0x46, 0xc9, 0xef, 0xa7, 0x46, 0x0f, 0x80, 0xf7,
+	0xc6, 0x61, 0x4f, 0x28, 0x55, 0x93, 0x3b, 0xa9, 0x7a, 0x42, 0x8e, 0xe1, 0xc2, 0x09, 0x2f, 0x33,
+	0x86, 0x2b, 0x69, 0x90, 0x8e, 0x4c, 0x7b, 0xa8, 0x6c, 0x43, 0xaa, 0x61, 0x39, 0x2c, 0x60, 0x69,
+	0x97, 0xe8, 0x9e, 0x63, 0x07, 0x7e, 0xe4, 0x33, 0xe5, 0x20, 0xe8, 0x44, 0xc2, 0xc6, 0xfd, 0xf3,
+	0xd5, 0x6f, 0x82, 0xad, 0xd5, 0x46, 0xa8, 0xe7, 0xeb, 0xfe, 0xc4, 0x5b, 0xf9, 0x32, 0x50, 0xfe,
+	0x1c, 0x87, 0x42, 0xd4, 0x7e, 0x63, 0x9b, 0x1d, 0x95, 0xd4, 0xc4, 0x55, 0x49, 0x15, 0xb2, 0xc2,
+	0xde, 0x38, 0xfe, 0xce, 0x08, 0x55, 0xa1, 0xc8, 0x82, 0x70, 0x32, 0xf5, 0x89, 0x57, 0x92, 0x22,
+	0x84, 0x3a, 0x45, 0xe8, 0x45, 0x09, 0xcd, 0x2b, 0x4f, 0xd4, 0x84, 0x98, 0x45, 0x7a, 0xe4, 0xf4,
+	0x52, 0x8f, 0xbc, 0x09, 0x29, 0xcf, 0xd7, 0x7d, 0xc2, 0x0a, 0x22, 0x87, 0xf9, 0x44, 0x79, 0x0a,
+	0x80, 0xc3, 0x2f, 0x95, 0x1b, 0x93, 0x70, 0x13, 0x52, 0x34, 0x90, 0x3c, 0x05, 0x73, 0x98, 0x4f,
+	0x94, 0xdf, 0xc3, 0x5a, 0x7d, 0xe9, 0x03, 0xe6, 0xa6, 0xe5, 0xd7, 0xe5, 0xc4, 0xa2, 0xd0, 0x93,
+	0xd7, 0x16, 0xba, 0x74, 0x4d, 0xa1, 0xa7, 0x16, 0x85, 0xae, 0xbc, 0x00, 0x68, 0x84, 0x1f, 0x3d,
+	0x91, 0x10, 0x24, 0x57, 0xa5, 0x87, 0x7f, 0x1a, 0x89, 0x42, 0x15, 0x33, 0xaa, 0xf9, 0x9e, 0x39,
+	0xb4, 0x75, 0x7f, 0xe2, 0x12, 0xa1, 0xbc, 0x0b, 0x40, 0xa9, 0x03, 0x5a, 0xec, 0x7d, 0x8d, 0x14,
+	0x24, 0x57, 0xbf, 0xa6, 0x98, 0xeb, 0x83, 0xfe, 0x95, 0x4d, 0x76, 0xff, 0x22, 0x41, 0x3e, 0xf2,
+	0x65, 0x8b, 0x1e, 0x43, 0xb1, 0xd1, 0x3e, 0xee, 0xf5, 0x55, 0xac, 0x35, 0xba, 0x9d, 0xbd, 0xd6,
+	0xbe, 0x1c, 0x2b, 0xdf, 0x99, 0xcd, 0xab, 0xa5, 0xd1, 0x82, 0xb4, 0xfc, 0xcd, 0xba, 0x0d, 0xa9,
+	0x56, 0xa7, 0xa9, 0xfe, 0x46, 0x8e, 0x97, 0x37, 0x67, 0xf3, 0xaa, 0x1c, 0x21, 0xf2, 0x86, 0xec,
+	0x13, 0x28, 0x30, 0x82, 0x76, 0x7c, 0xd4, 0xac, 0xf5, 0x55, 0x39, 0x51, 0x2e, 0xcf, 0xe6, 0xd5,
+	0xad, 0x55, 0x9e, 0xa8, 0xf1, 0x0f, 0x20, 0x83, 0xd5, 0x5f, 0x1f, 0xab, 0xbd, 0xbe, 0x9c, 0x2c,
+	0x6f, 0xcd, 0xe6, 0x55, 0x14, 0x21, 0x06, 0x12, 0x7e, 0x1f, 0xb2, 0x58, 0xed, 0x1d, 0x75, 0x3b,
+	0x3d, 0x55, 0x96, 0xca, 0x3f, 0x98, 0xcd, 0xab, 0xb7, 0x96, 0x58, 0xc2, 0x15, 0x9f, 0xc3, 0x46,
+	0xb3, 0xfb, 0x65, 0xa7, 0xdd, 0xad, 0x35, 0xb5, 0x23, 0xdc, 0xdd, 0xc7, 0x6a, 0xaf, 0x27, 0xa7,
+	0xca, 0xdb, 0xb3, 0x79, 0xf5, 0xfd, 0x08, 0xff, 0x8a, 0xc8, 0xdd, 0x05, 0xe9, 0xa8, 0xd5, 0xd9,
+	0x97, 0xd3, 0xe5, 0x5b, 0xb3, 0x79, 0x75, 0x3d, 0x42, 0xa5, 0x45, 0x4c, 0x6f, 0xdc, 0x68, 0x77,
+	0x7b, 0xaa, 0x9c, 0xb9, 0x72, 0x63, 0x5e, 0xdc, 0x4f, 0x60, 0x7d, 0xaf, 0xdb, 0x6e, 0xaa, 0x58,
+	0xab, 0x35, 0xfa, 0xad, 0x67, 0xad, 0xfe, 0x73, 0x39, 0x5b, 0xbe, 0x3b, 0x9b, 0x57, 0xdf, 0x8b,
+	0x50, 0x57, 0xca, 0x7c, 0x17, 0xf2, 0x58, 0xed, 0x35, 0x6a, 0x1d, 0xed, 0xa0, 0xd5, 0xe9, 0xcb,
+	0xb9, 0xf2, 0x7b, 0xb3, 0x79, 0xf5, 0xf6, 0xf2, 0xad, 0x82, 0xfc, 0x7f, 0x0c, 0xc5, 0x7a, 0xbb,
+	0xdb, 0xf8, 0x42, 0x3b, 0x6c, 0xf5, 0x0e, 0x6b, 0xfd, 0xc6, 0x81, 0x0c, 0x57, 0x82, 0xb4, 0x9c,
+	0xf2, 0xbb, 0x90, 0xe7, 0xe1, 0xd4, 0x8e, 0x8e, 0x7b, 0x07, 0x72, 0xfe, 0xca, 0xee, 0x91, 0x24,
+	0xfd, 0x39, 0x6c, 0x46, 0xb8, 0x5a, 0xe8, 0xe8, 0x42, 0xf9, 0xde, 0x6c, 0x5e, 0xbd, 0x7b, 0xed,
+	0xa2, 0xc0, 0xe5, 0xbb, 0xbf, 0x05, 0x74, 0xf5, 0xdf, 0x1e, 0xe8, 0x43, 0x90, 0x3a, 0xdd, 0x8e,
+	0x2a, 0xc7, 0x78, 0xe8, 0xaf, 0x32, 0x3a, 0x8e, 0x4d, 0x90, 0x02, 0xc9, 0xf6, 0x8b, 0xcf, 0xe4,
+	0x38, 0xff, 0x71, 0x57, 0x49, 0xed, 0x17, 0x9f, 0xed, 0x3a, 0x90, 0x8f, 0x6e, 0xac, 0x40, 0xf6,
+	0x50, 0xed, 0xd7, 0x9a, 0xb5, 0x7e, 0x4d, 0x8e, 0xf1, 0x68, 0x04, 0xe6, 0xf0, 0x53, 0xee, 0x0e,
+	0xa4, 0x3a, 0xea, 0x33, 0x15, 0xcb, 0xf1, 0xf2, 0xc6, 0x6c, 0x5e, 0x5d, 0x0b, 0x08, 0x1d, 0x72,
+	0x4e, 0x5c, 0x54, 0x81, 0x74, 0xad, 0xfd, 0x65, 0xed, 0x79, 0x4f, 0x4e, 0x94, 0xd1, 0x6c, 0x5e,
+	0x2d, 0x06, 0xe6, 0x9a, 0xf5, 0x4a, 0x9f, 0x7a, 0xbb, 0xff, 0xa5, 0x32, 0x1a, 0xe9, 0xbc, 0x51,
+	0x05, 0xa4, 0xbd, 0x56, 0x5b, 0x0d, 0x8e, 0x8b, 0xda, 0xe8, 0x18, 0xed, 0x40, 0xae, 0xd9, 0xc2,
+	0x6a, 0xa3, 0xdf, 0xc5, 0xcf, 0x83, 0xbb, 0x44, 0x49, 0x4d, 0xd3, 0x65, 0x6f, 0xc9, 0x14, 0xfd,
+	0x0c, 0x0a, 0xbd, 0xe7, 0x87, 0xed, 0x56, 0xe7, 0x0b, 0x8d, 0xed, 0x98, 0x28, 0x3f, 0x98, 0xcd,
+	0xab, 0xf7, 0x96, 0xc8, 0x64, 0xec, 0x92, 0x81, 0xee, 0x13, 0xa3, 0xc7, 0x9b, 0x41, 0x6a, 0xcc,
+	0xc6, 0x51, 0x03, 0x36, 0x82, 0xa5, 0x8b, 0xc3, 0x92, 0xe5, 0x4f, 0x66, 0xf3, 0xea, 0x47, 0xdf,
+	0xb9, 0x3e, 0x3c, 0x3d, 0x1b, 0x47, 0x1f, 0x42, 0x46, 0x6c, 0x12, 0x14, 0x51, 0x74, 0xa9, 0x58,
+	0xb0, 0xfb, 0xa7, 0x38, 0xe4, 0xc2, 0xce, 0x80, 0x3a, 0xbc, 0xd3, 0xd5, 0x54, 0x8c, 0xbb, 0x38,
+	0xf0, 0x40, 0x68, 0xec, 0x38, 0x6c, 0x88, 0xee, 0x41, 0x66, 0x5f, 0xed, 0xa8, 0xb8, 0xd5, 0x08,
+	0x34, 0x21, 0xa4, 0xec, 0x13, 0x9b, 0xb8, 0xe6, 0x00, 0x7d, 0x0c, 0x85, 0x4e, 0x57, 0xeb, 0x1d,
+	0x37, 0x0e, 0x82, 0xab, 0xb3, 0xf3, 0x23, 0x5b, 0xf5, 0x26, 0x83, 0x33, 0xe6, 0xcf, 0x5d, 0x2a,
+	0x1f, 0xcf, 0x6a, 0xed, 0x56, 0x93, 0x53, 0x93, 0xe5, 0xd2, 0x6c, 0x5e, 0xdd, 0x0c, 0xa9, 0xe2,
+	0xdb, 0x83, 0x72, 0x77, 0x0d, 0xa8, 0x7c, 0x77, 0x0f, 0x80, 0xaa, 0x90, 0xae, 0x1d, 0x1d, 0xa9,
+	0x9d, 0x66, 0xf0, 0xeb, 0x17, 0xb6, 0xda, 0x78, 0x4c, 0x6c, 0xda, 0x49, 0xa7, 0xf7, 0xba, 0x78,
+	0x5f, 0xed, 0xcb, 0xf1, 0x55, 0xc6, 0x9e, 0x43, 0x3b, 0xf1, 0xfa, 0xce, 0x9b, 0x6f, 0x2a, 0xb1,
+	0xb7, 0xdf, 0x54, 0x62, 0x6f, 0x2e, 0x2b, 0xf1, 0xb7, 0x97, 0x95, 0xf8, 0x3f, 0x2f, 0x2b, 0xb1,
+	0x6f, 0x2f, 0x2b, 0xf1, 0x3f, 0xbc, 0xab, 0xc4, 0xbe, 0x7e, 0x57, 0x89, 0xbf, 0x7d, 0x57, 0x89,
+	0xfd, 0xfd, 0x5d, 0x25, 0x76, 0x92, 0x66, 0x2f, 0xf5, 0xa7, 0xff, 0x1b, 0x00, 0x32, 0x6e, 0x01,
+	0xcc, 0x0b, 0x15, 0x00, 0x00,
 }
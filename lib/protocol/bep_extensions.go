@@ -159,16 +159,26 @@ func (f FileInfo) IsEmpty() bool {
 }
 
 func (f FileInfo) IsEquivalent(other FileInfo) bool {
-	return f.isEquivalent(other, false, false, 0)
+	return f.isEquivalent(other, 0, false, 0)
 }
 
 func (f FileInfo) IsEquivalentOptional(other FileInfo, ignorePerms bool, ignoreBlocks bool, ignoreFlags uint32) bool {
-	return f.isEquivalent(other, ignorePerms, ignoreBlocks, ignoreFlags)
+	var ignorePermBits uint32
+	if ignorePerms {
+		ignorePermBits = 0777
+	}
+	return f.isEquivalent(other, ignorePermBits, ignoreBlocks, ignoreFlags)
+}
+
+// IsEquivalentPermBits is like IsEquivalentOptional, except that only the
+// given permission bits are ignored.
+func (f FileInfo) IsEquivalentPermBits(other FileInfo, ignorePermBits uint32, ignoreBlocks bool, ignoreFlags uint32) bool {
+	return f.isEquivalent(other, ignorePermBits, ignoreBlocks, ignoreFlags)
 }
 
 // isEquivalent checks that the two file infos represent the same actual file content,
 // i.e. it does purposely not check only selected (see below) struct members.
-// Permissions (config) and blocks (scanning) can be excluded from the comparison.
+// Permission bits (config) and blocks (scanning) can be excluded from the comparison.
 // Any file info is not "equivalent", if it has different
 //  - type
 //  - deleted flag
@@ -181,7 +191,7 @@ func (f FileInfo) IsEquivalentOptional(other FileInfo, ignorePerms bool, ignoreB
 // A symlink is not "equivalent", if it has different
 //  - target
 // A directory does not have anything specific to check.
-func (f FileInfo) isEquivalent(other FileInfo, ignorePerms bool, ignoreBlocks bool, ignoreFlags uint32) bool {
+func (f FileInfo) isEquivalent(other FileInfo, ignorePermBits uint32, ignoreBlocks bool, ignoreFlags uint32) bool {
 	if f.MustRescan() || other.MustRescan() {
 		// These are per definition not equivalent because they don't
 		// represent a valid state, even if both happen to have the
@@ -197,7 +207,7 @@ func (f FileInfo) isEquivalent(other FileInfo, ignorePerms bool, ignoreBlocks bo
 		return false
 	}
 
-	if !ignorePerms && !f.NoPermissions && !other.NoPermissions && !PermsEqual(f.Permissions, other.Permissions) {
+	if ignorePermBits&0777 != 0777 && !f.NoPermissions && !other.NoPermissions && !PermsEqual(f.Permissions&^ignorePermBits, other.Permissions&^ignorePermBits) {
 		return false
 	}
 
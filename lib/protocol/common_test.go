@@ -53,6 +53,21 @@ func (t *TestModel) ClusterConfig(deviceID DeviceID, config ClusterConfig) {
 func (t *TestModel) DownloadProgress(DeviceID, string, []FileDownloadProgressUpdate) {
 }
 
+func (t *TestModel) FolderActivity(DeviceID, FolderActivity) {
+}
+
+func (t *TestModel) RescanHint(DeviceID, RescanHint) {
+}
+
+func (t *TestModel) BlockMismatch(DeviceID, BlockMismatch) {
+}
+
+func (t *TestModel) ConfigPush(DeviceID, ConfigPush) {
+}
+
+func (t *TestModel) ConfigPushResponse(DeviceID, ConfigPushResponse) {
+}
+
 func (t *TestModel) closedError() error {
 	select {
 	case <-t.closedCh:
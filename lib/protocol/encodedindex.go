@@ -0,0 +1,119 @@
+// Copyright (C) 2019 The Protocol Authors.
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var errEncodedIndexUnmarshal = errors.New("an encoded index can't be unmarshalled")
+
+// An EncodedIndex is an Index or IndexUpdate message marshalled once, and
+// compressed once when first needed, for all the connections it is sent
+// on. That saves doing so for every device when the same batch of files is
+// sent to many. It must not be modified after it's created.
+type EncodedIndex struct {
+	folder string
+	files  int
+	typ    MessageType
+	data   []byte
+
+	compressOnce sync.Once
+	compressed   []byte
+	compressErr  error
+}
+
+// EncodeIndex returns the encoded Index message of the files, or the
+// IndexUpdate message if update is true. File names are converted to the
+// wire format.
+func EncodeIndex(folder string, files []FileInfo, update bool) (*EncodedIndex, error) {
+	wireFiles := make([]FileInfo, len(files))
+	copy(wireFiles, files)
+	for i := range wireFiles {
+		wireFiles[i].Name = norm.NFC.String(filepath.ToSlash(wireFiles[i].Name))
+	}
+
+	var msg message
+	typ := messageTypeIndex
+	if update {
+		msg = &IndexUpdate{Folder: folder, Files: wireFiles}
+		typ = messageTypeIndexUpdate
+	} else {
+		msg = &Index{Folder: folder, Files: wireFiles}
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("marshalling index: %v", err)
+	}
+
+	return &EncodedIndex{
+		folder: folder,
+		files:  len(files),
+		typ:    typ,
+		data:   data,
+	}, nil
+}
+
+// Folder returns the folder of the index.
+func (e *EncodedIndex) Folder() string {
+	return e.folder
+}
+
+// NumFiles returns the number of files in the index.
+func (e *EncodedIndex) NumFiles() int {
+	return e.files
+}
+
+// Files decodes the files in the index, with the names in wire format.
+func (e *EncodedIndex) Files() ([]FileInfo, error) {
+	var idx Index // same layout as IndexUpdate
+	if err := idx.Unmarshal(e.data); err != nil {
+		return nil, err
+	}
+	return idx.Files, nil
+}
+
+// IsUpdate returns true for an IndexUpdate message.
+func (e *EncodedIndex) IsUpdate() bool {
+	return e.typ == messageTypeIndexUpdate
+}
+
+// ProtoSize returns the size of the marshalled message.
+func (e *EncodedIndex) ProtoSize() int {
+	return len(e.data)
+}
+
+// Marshal returns a copy of the marshalled message.
+func (e *EncodedIndex) Marshal() ([]byte, error) {
+	return append([]byte(nil), e.data...), nil
+}
+
+// MarshalTo copies the marshalled message to buf.
+func (e *EncodedIndex) MarshalTo(buf []byte) (int, error) {
+	return copy(buf, e.data), nil
+}
+
+// Unmarshal fails, as an EncodedIndex is only ever sent.
+func (e *EncodedIndex) Unmarshal([]byte) error {
+	return errEncodedIndexUnmarshal
+}
+
+// lz4Compressed returns the compressed message, which the caller must not
+// modify or return to the buffer pool.
+func (e *EncodedIndex) lz4Compressed(compress func([]byte) ([]byte, error)) ([]byte, error) {
+	e.compressOnce.Do(func() {
+		var compressed []byte
+		compressed, e.compressErr = compress(e.data)
+		if e.compressErr == nil {
+			// Out of the pool, as it's kept.
+			e.compressed = append([]byte(nil), compressed...)
+			BufferPool.Put(compressed)
+		}
+	})
+	return e.compressed, e.compressErr
+}
@@ -0,0 +1,492 @@
+// Copyright (C) 2019 The Protocol Authors.
+
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+Folders shared with an untrusted device, with an encryption password for it,
+are encrypted on the way to it, and decrypted on the way back, by wrapping
+the connection and the model. The device only ever sees ciphertext:
+
+- File names are encrypted deterministically with the folder key, derived
+  from the password and the folder ID, so that a file has the same name
+  whichever device it came from.
+
+- The file info is replaced by one of a regular file with the encrypted
+  name, the same version, and blocks of the encrypted data. The original,
+  encrypted with the folder key, goes along with it, and is what we get
+  back from the device in its index.
+
+- Each block is encrypted with a key of its file, with a random nonce, and
+  so becomes blockOverhead longer. Its hash is replaced by the hash
+  encrypted deterministically, which the device can't verify data against,
+  but which is the same every time and from which we get the original back
+  when the device requests the block.
+
+- Every MinBlockSize of a file takes blockOverhead more in the encrypted
+  file, so that the offset of a block in one can be told from its offset in
+  the other, whatever the block size.
+*/
+
+const (
+	nonceSize             = chacha20poly1305.NonceSizeX
+	tagSize               = 16
+	keySize               = chacha20poly1305.KeySize
+	blockOverhead         = nonceSize + tagSize
+	encryptedDirExtension = ".syncthing-enc"
+	maxPathComponent      = 200        // characters of an encrypted name per directory level
+	encryptedModTime      = 1234567890 // of all encrypted files, the real one being encrypted
+	keySalt               = "syncthing"
+)
+
+var (
+	errEncryptedTooShort = errors.New("encrypted data too short")
+	errNotEncryptedName  = errors.New("not an encrypted name")
+	errNotEncryptedInfo  = errors.New("file info not encrypted")
+	errEncryptedMismatch = errors.New("encrypted file info doesn't match its name")
+)
+
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// folderKeys returns the keys of the folders, by ID, with the passwords.
+func folderKeys(passwords map[string]string) map[string]*[keySize]byte {
+	keys := make(map[string]*[keySize]byte, len(passwords))
+	for folder, password := range passwords {
+		keys[folder] = keyFromPassword(folder, password)
+	}
+	return keys
+}
+
+func keyFromPassword(folderID, password string) *[keySize]byte {
+	bs, err := scrypt.Key([]byte(password), []byte(keySalt+folderID), 32768, 8, 1, keySize)
+	if err != nil {
+		panic("key derivation failure: " + err.Error())
+	}
+	var key [keySize]byte
+	copy(key[:], bs)
+	return &key
+}
+
+// fileKey returns the key the blocks of the file are encrypted with.
+func fileKey(name string, folderKey *[keySize]byte) *[keySize]byte {
+	kdf := hkdf.New(sha256.New, folderKey[:], []byte(keySalt), []byte(name))
+	var key [keySize]byte
+	if _, err := io.ReadFull(kdf, key[:]); err != nil {
+		panic("key derivation failure: " + err.Error())
+	}
+	return &key
+}
+
+// encryptBytes encrypts the data with a random nonce, which is prepended to
+// the result.
+func encryptBytes(data []byte, key *[keySize]byte) []byte {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		panic("random failure: " + err.Error())
+	}
+	return seal(nonce, data, key, nil)
+}
+
+// encryptDeterministic encrypts the data with a nonce derived from it and
+// the additional data, so that the same data always encrypts the same.
+func encryptDeterministic(data []byte, key *[keySize]byte, additional []byte) []byte {
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(additional)
+	mac.Write(data)
+	return seal(mac.Sum(nil)[:nonceSize], data, key, additional)
+}
+
+func seal(nonce, data []byte, key *[keySize]byte, additional []byte) []byte {
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		panic("cipher failure: " + err.Error())
+	}
+	out := make([]byte, nonceSize, nonceSize+len(data)+tagSize)
+	copy(out, nonce)
+	return aead.Seal(out, nonce, data, additional)
+}
+
+// decryptBytes decrypts data encrypted by encryptBytes or
+// encryptDeterministic.
+func decryptBytes(data []byte, key *[keySize]byte, additional []byte) ([]byte, error) {
+	if len(data) < blockOverhead {
+		return nil, errEncryptedTooShort
+	}
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], additional)
+}
+
+// encryptName returns the encrypted file name, in directories as short as
+// filesystems need them to be.
+func encryptName(name string, folderKey *[keySize]byte) string {
+	return slashify(base32Hex.EncodeToString(encryptDeterministic([]byte(name), folderKey, nil)))
+}
+
+func decryptName(name string, folderKey *[keySize]byte) (string, error) {
+	encoded, err := deslashify(name)
+	if err != nil {
+		return "", err
+	}
+	bs, err := base32Hex.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	dec, err := decryptBytes(bs, folderKey, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(dec), nil
+}
+
+// slashify splits the encoded name into a directory of its first
+// character, marked as encrypted, one of the next two, and the rest in
+// components of at most maxPathComponent. The name is at least as long as
+// the overhead of encryption, so there always is a rest.
+func slashify(s string) string {
+	comps := []string{s[:1] + encryptedDirExtension, s[1:3]}
+	s = s[3:]
+	for len(s) > maxPathComponent {
+		comps = append(comps, s[:maxPathComponent])
+		s = s[maxPathComponent:]
+	}
+	comps = append(comps, s)
+	return strings.Join(comps, "/")
+}
+
+func deslashify(s string) (string, error) {
+	if len(s) < 1+len(encryptedDirExtension) || s[1:1+len(encryptedDirExtension)] != encryptedDirExtension {
+		return "", errNotEncryptedName
+	}
+	return s[:1] + strings.Replace(s[1+len(encryptedDirExtension):], "/", "", -1), nil
+}
+
+// encryptedOffset returns the offset in the encrypted file of the block at
+// the offset in the original file, which is a multiple of the block size
+// and so of MinBlockSize. Blocks larger than the minimum leave some unused
+// room after them.
+func encryptedOffset(offset int64) int64 {
+	return offset + offset/MinBlockSize*blockOverhead
+}
+
+// plainOffset is the inverse of encryptedOffset.
+func plainOffset(offset int64) int64 {
+	return offset - offset/(MinBlockSize+blockOverhead)*blockOverhead
+}
+
+func encryptBlockHash(hash []byte, offset int64, fileKey *[keySize]byte) []byte {
+	return encryptDeterministic(hash, fileKey, offsetBytes(offset))
+}
+
+func decryptBlockHash(hash []byte, offset int64, fileKey *[keySize]byte) ([]byte, error) {
+	return decryptBytes(hash, fileKey, offsetBytes(offset))
+}
+
+func offsetBytes(offset int64) []byte {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, uint64(offset))
+	return bs
+}
+
+// encryptFileInfo returns the file info that stands in for the original
+// toward an untrusted device. Directories and symlinks, which don't need
+// to be stored anywhere but in the encrypted original, become empty
+// directories.
+func encryptFileInfo(fi FileInfo, folderKey *[keySize]byte) FileInfo {
+	bs, err := fi.Marshal()
+	if err != nil {
+		panic("impossible serialization mishap: " + err.Error())
+	}
+
+	typ := FileInfoTypeFile
+	perms := uint32(0644)
+	var size int64
+	var blocks []BlockInfo
+	if fi.Type == FileInfoTypeFile {
+		key := fileKey(fi.Name, folderKey)
+		blocks = make([]BlockInfo, len(fi.Blocks))
+		for i, b := range fi.Blocks {
+			blocks[i] = BlockInfo{
+				Offset: encryptedOffset(b.Offset),
+				Size:   b.Size + blockOverhead,
+				Hash:   encryptBlockHash(b.Hash, b.Offset, key),
+			}
+		}
+		if n := len(blocks); n > 0 {
+			size = blocks[n-1].Offset + int64(blocks[n-1].Size)
+		}
+	} else {
+		typ = FileInfoTypeDirectory
+		perms = 0755
+	}
+
+	return FileInfo{
+		Name:         encryptName(fi.Name, folderKey),
+		Type:         typ,
+		Size:         size,
+		Permissions:  perms,
+		ModifiedS:    encryptedModTime,
+		Deleted:      fi.Deleted,
+		RawInvalid:   fi.IsInvalid(),
+		Version:      fi.Version,
+		Sequence:     fi.Sequence,
+		RawBlockSize: int32(fi.BlockSize() + blockOverhead),
+		Blocks:       blocks,
+		Encrypted:    encryptBytes(bs, folderKey),
+	}
+}
+
+// decryptFileInfo returns the original of the file info an untrusted
+// device announces, at the sequence it has in the device's index.
+func decryptFileInfo(fi FileInfo, folderKey *[keySize]byte) (FileInfo, error) {
+	if len(fi.Encrypted) == 0 {
+		return FileInfo{}, errNotEncryptedInfo
+	}
+	bs, err := decryptBytes(fi.Encrypted, folderKey, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	var dec FileInfo
+	if err := dec.Unmarshal(bs); err != nil {
+		return FileInfo{}, err
+	}
+	// The device can't have changed the file without us, but could swap
+	// the originals of files.
+	if encryptName(dec.Name, folderKey) != fi.Name {
+		return FileInfo{}, errEncryptedMismatch
+	}
+	dec.Sequence = fi.Sequence
+	return dec, nil
+}
+
+// encryptedModel decrypts what an untrusted device sends for the folders
+// encrypted toward it, before passing it on to the model.
+type encryptedModel struct {
+	Model
+	folderKeys map[string]*[keySize]byte
+}
+
+func (e encryptedModel) Index(deviceID DeviceID, folder string, files []FileInfo) {
+	if key, ok := e.folderKeys[folder]; ok {
+		files = decryptFileInfos(folder, files, key)
+	}
+	e.Model.Index(deviceID, folder, files)
+}
+
+func (e encryptedModel) IndexUpdate(deviceID DeviceID, folder string, files []FileInfo) {
+	if key, ok := e.folderKeys[folder]; ok {
+		files = decryptFileInfos(folder, files, key)
+	}
+	e.Model.IndexUpdate(deviceID, folder, files)
+}
+
+// decryptFileInfos returns the originals of the files, leaving out those
+// without any, like the files changed locally on the device.
+func decryptFileInfos(folder string, files []FileInfo, key *[keySize]byte) []FileInfo {
+	res := make([]FileInfo, 0, len(files))
+	for _, fi := range files {
+		dec, err := decryptFileInfo(fi, key)
+		if err != nil {
+			l.Debugf("Dropping file %q in folder %q: %v", fi.Name, folder, err)
+			continue
+		}
+		res = append(res, dec)
+	}
+	return res
+}
+
+func (e encryptedModel) Request(deviceID DeviceID, folder, name string, size int32, offset int64, hash []byte, weakHash uint32, fromTemporary bool) (RequestResponse, error) {
+	key, ok := e.folderKeys[folder]
+	if !ok {
+		return e.Model.Request(deviceID, folder, name, size, offset, hash, weakHash, fromTemporary)
+	}
+
+	realName, err := decryptName(name, key)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	realSize := size - blockOverhead
+	realOffset := plainOffset(offset)
+	if realSize < 0 {
+		return nil, ErrInvalid
+	}
+	fkey := fileKey(realName, key)
+	realHash, err := decryptBlockHash(hash, realOffset, fkey)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	res, err := e.Model.Request(deviceID, folder, realName, realSize, realOffset, realHash, 0, fromTemporary)
+	if err != nil {
+		return nil, err
+	}
+	enc := encryptBytes(res.Data(), fkey)
+	res.Close()
+	return rawResponse{enc}, nil
+}
+
+func (e encryptedModel) DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate) {
+	if _, ok := e.folderKeys[folder]; ok {
+		// Encrypted files are never pulled from a device that has them
+		// partially.
+		return
+	}
+	e.Model.DownloadProgress(deviceID, folder, updates)
+}
+
+func (e encryptedModel) RescanHint(deviceID DeviceID, hint RescanHint) {
+	if key, ok := e.folderKeys[hint.Folder]; ok {
+		names := make([]string, 0, len(hint.Names))
+		for _, name := range hint.Names {
+			if realName, err := decryptName(name, key); err == nil {
+				names = append(names, realName)
+			}
+		}
+		hint.Names = names
+	}
+	e.Model.RescanHint(deviceID, hint)
+}
+
+func (e encryptedModel) BlockMismatch(deviceID DeviceID, mismatch BlockMismatch) {
+	if _, ok := e.folderKeys[mismatch.Folder]; ok {
+		// The device can't verify what we send it.
+		return
+	}
+	e.Model.BlockMismatch(deviceID, mismatch)
+}
+
+// encryptedConnection encrypts what is sent to an untrusted device for the
+// folders encrypted toward it, and decrypts the data requested from it.
+type encryptedConnection struct {
+	Connection
+	folderKeys map[string]*[keySize]byte
+}
+
+func (c encryptedConnection) Index(folder string, files []FileInfo) error {
+	if key, ok := c.folderKeys[folder]; ok {
+		files = encryptFileInfos(files, key)
+	}
+	return c.Connection.Index(folder, files)
+}
+
+func (c encryptedConnection) IndexUpdate(folder string, files []FileInfo) error {
+	if key, ok := c.folderKeys[folder]; ok {
+		files = encryptFileInfos(files, key)
+	}
+	return c.Connection.IndexUpdate(folder, files)
+}
+
+func encryptFileInfos(files []FileInfo, key *[keySize]byte) []FileInfo {
+	res := make([]FileInfo, len(files))
+	for i, fi := range files {
+		res[i] = encryptFileInfo(fi, key)
+	}
+	return res
+}
+
+// SendEncodedIndex sends the index in plain, unless the folder is
+// encrypted, in which case it's encrypted for this device alone.
+func (c encryptedConnection) SendEncodedIndex(idx *EncodedIndex) error {
+	if _, ok := c.folderKeys[idx.Folder()]; !ok {
+		return c.Connection.SendEncodedIndex(idx)
+	}
+	files, err := idx.Files()
+	if err != nil {
+		return err
+	}
+	if idx.IsUpdate() {
+		return c.IndexUpdate(idx.Folder(), files)
+	}
+	return c.Index(idx.Folder(), files)
+}
+
+func (c encryptedConnection) Request(folder string, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+	key, ok := c.folderKeys[folder]
+	if !ok {
+		return c.Connection.Request(folder, name, offset, size, hash, weakHash, fromTemporary)
+	}
+
+	fkey := fileKey(name, key)
+	bs, err := c.Connection.Request(folder, encryptName(name, key), encryptedOffset(offset), size+blockOverhead, encryptBlockHash(hash, offset, fkey), 0, fromTemporary)
+	if err != nil {
+		return nil, err
+	}
+	return decryptBytes(bs, fkey, nil)
+}
+
+// ClusterConfig leaves out what the device shouldn't know about the
+// encrypted folders, like their labels and ignore patterns.
+func (c encryptedConnection) ClusterConfig(config ClusterConfig) {
+	folders := make([]Folder, len(config.Folders))
+	for i, folder := range config.Folders {
+		if _, ok := c.folderKeys[folder.ID]; ok {
+			folder.Label = folder.ID
+			folder.SharesIgnores = false
+			folder.IgnorePatterns = nil
+			folder.SharesMetadata = false
+			folder.FolderDescription = ""
+			folder.Metadata = nil
+		}
+		folders[i] = folder
+	}
+	config.Folders = folders
+	c.Connection.ClusterConfig(config)
+}
+
+func (c encryptedConnection) DownloadProgress(folder string, updates []FileDownloadProgressUpdate) {
+	if _, ok := c.folderKeys[folder]; ok {
+		return
+	}
+	c.Connection.DownloadProgress(folder, updates)
+}
+
+func (c encryptedConnection) RescanHint(folder string, names []string) {
+	if key, ok := c.folderKeys[folder]; ok {
+		encNames := make([]string, len(names))
+		for i, name := range names {
+			encNames[i] = encryptName(name, key)
+		}
+		names = encNames
+	}
+	c.Connection.RescanHint(folder, names)
+}
+
+func (c encryptedConnection) BlockMismatch(folder, name string, offset int64, size int32, hash []byte) {
+	if key, ok := c.folderKeys[folder]; ok {
+		hash = encryptBlockHash(hash, offset, fileKey(name, key))
+		name = encryptName(name, key)
+		offset = encryptedOffset(offset)
+		size += blockOverhead
+	}
+	c.Connection.BlockMismatch(folder, name, offset, size, hash)
+}
+
+// rawResponse is the response to a request that isn't read into a buffer
+// from the pool.
+type rawResponse struct {
+	data []byte
+}
+
+func (r rawResponse) Data() []byte {
+	return r.data
+}
+
+func (r rawResponse) Close() {}
+
+func (r rawResponse) Wait() {}
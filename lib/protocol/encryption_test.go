@@ -0,0 +1,175 @@
+// Copyright (C) 2019 The Protocol Authors.
+
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncryptName(t *testing.T) {
+	key := keyFromPassword("folder", "secret")
+	names := []string{
+		"a",
+		"dir/file.txt",
+		strings.Repeat("long/", 100) + "name",
+	}
+	for _, name := range names {
+		enc := encryptName(name, key)
+		if enc != encryptName(name, key) {
+			t.Error("encrypted name not deterministic for", name)
+		}
+		if strings.Contains(enc, name) {
+			t.Error("encrypted name contains the name", enc)
+		}
+		if !strings.HasPrefix(enc[1:], encryptedDirExtension+"/") {
+			t.Error("encrypted name not in encrypted directory", enc)
+		}
+		for _, comp := range strings.Split(enc, "/") {
+			if len(comp) > maxPathComponent {
+				t.Error("path component too long", len(comp))
+			}
+		}
+		dec, err := decryptName(enc, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec != name {
+			t.Errorf("decrypted %q, expected %q", dec, name)
+		}
+	}
+
+	if _, err := decryptName(encryptName("a", key), keyFromPassword("folder", "other")); err == nil {
+		t.Error("decrypted name with the wrong password")
+	}
+	if _, err := decryptName("plain/name", key); err == nil {
+		t.Error("decrypted name that isn't encrypted")
+	}
+}
+
+func TestEncryptedOffset(t *testing.T) {
+	for _, blockSize := range BlockSizes {
+		for i := int64(0); i < 5; i++ {
+			offset := i * int64(blockSize)
+			enc := encryptedOffset(offset)
+			if plainOffset(enc) != offset {
+				t.Errorf("offset %d encrypted to %d, decrypted to %d", offset, enc, plainOffset(enc))
+			}
+			// The block must fit before the next one.
+			if next := encryptedOffset(offset + int64(blockSize)); enc+int64(blockSize+blockOverhead) > next {
+				t.Errorf("block at %d of size %d overlaps the next at %d", enc, blockSize, next)
+			}
+		}
+	}
+}
+
+func TestEncryptFileInfo(t *testing.T) {
+	key := keyFromPassword("folder", "secret")
+	fi := FileInfo{
+		Name:        "dir/file",
+		Type:        FileInfoTypeFile,
+		Size:        MinBlockSize + 10,
+		Permissions: 0600,
+		ModifiedS:   1500000000,
+		Version:     Vector{}.Update(42),
+		Sequence:    7,
+		Blocks: []BlockInfo{
+			{Offset: 0, Size: MinBlockSize, Hash: bytes.Repeat([]byte{1}, 32)},
+			{Offset: MinBlockSize, Size: 10, Hash: bytes.Repeat([]byte{2}, 32)},
+		},
+	}
+
+	enc := encryptFileInfo(fi, key)
+	if enc.Name == fi.Name || enc.ModifiedS == fi.ModifiedS || enc.Permissions != 0644 {
+		t.Error("file info not encrypted:", enc)
+	}
+	if !enc.Version.Equal(fi.Version) {
+		t.Error("version not kept:", enc.Version)
+	}
+	for i, b := range enc.Blocks {
+		if bytes.Equal(b.Hash, fi.Blocks[i].Hash) {
+			t.Error("block hash not encrypted")
+		}
+		if b.Size != fi.Blocks[i].Size+blockOverhead || b.Offset != encryptedOffset(fi.Blocks[i].Offset) {
+			t.Error("unexpected encrypted block", b)
+		}
+	}
+	if enc.Size != MinBlockSize+10+2*blockOverhead {
+		t.Error("unexpected encrypted size", enc.Size)
+	}
+
+	enc.Sequence = 12
+	dec, err := decryptFileInfo(enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Name != fi.Name || dec.ModifiedS != fi.ModifiedS || dec.Sequence != 12 || len(dec.Blocks) != 2 {
+		t.Error("unexpected decrypted file info:", dec)
+	}
+
+	// The original of a file can't be passed off as another's.
+	other := encryptFileInfo(FileInfo{Name: "other", Type: FileInfoTypeFile}, key)
+	other.Encrypted = enc.Encrypted
+	if _, err := decryptFileInfo(other, key); err != errEncryptedMismatch {
+		t.Error("expected mismatch, got", err)
+	}
+}
+
+func TestEncryptedRequests(t *testing.T) {
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	passwords := map[string]string{"folder": "secret"}
+	key := keyFromPassword("folder", "secret")
+	plain := []byte("some data")
+
+	// c0 trusts c1 with the folder only encrypted.
+	m0 := newTestModel()
+	m0.data = plain
+	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways, passwords)
+	c0.Start()
+	m1 := newTestModel()
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, nil)
+	c1.Start()
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	hash := bytes.Repeat([]byte{1}, 32)
+
+	// What c1 requests from c0 is decrypted for, and encrypted by, c0.
+	fkey := fileKey("file", key)
+	enc, err := c1.Request("folder", encryptName("file", key), encryptedOffset(MinBlockSize), len(plain)+blockOverhead, encryptBlockHash(hash, MinBlockSize, fkey), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m0.name != "file" || m0.offset != MinBlockSize || int(m0.size) != len(plain) || !bytes.Equal(m0.hash, hash) {
+		t.Errorf("unexpected request %q %d %d %x", m0.name, m0.offset, m0.size, m0.hash)
+	}
+	if bytes.Contains(enc, plain) {
+		t.Error("data sent unencrypted")
+	}
+	if dec, err := decryptBytes(enc, fkey, nil); err != nil || !bytes.Equal(dec, plain) {
+		t.Error("unexpected decrypted data", dec, err)
+	}
+
+	// What c0 requests from c1 is encrypted toward, and decrypted by, c0.
+	m1.data = enc
+	dec, err := c0.Request("folder", "file", MinBlockSize, len(plain), hash, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.name != encryptName("file", key) || m1.offset != encryptedOffset(MinBlockSize) || int(m1.size) != len(plain)+blockOverhead {
+		t.Errorf("unexpected request %q %d %d", m1.name, m1.offset, m1.size)
+	}
+	if !bytes.Equal(dec, plain) {
+		t.Errorf("unexpected data %q", dec)
+	}
+
+	// Folders without a password are as they were.
+	m1.data = plain
+	if data, err := c0.Request("other", "file", 0, len(plain), hash, 0, false); err != nil || !bytes.Equal(data, plain) || m1.name != "file" {
+		t.Error("unexpected plain request", m1.name, data, err)
+	}
+}
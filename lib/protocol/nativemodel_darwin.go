@@ -30,3 +30,15 @@ func (m nativeModel) Request(deviceID DeviceID, folder, name string, size int32,
 	name = norm.NFD.String(name)
 	return m.Model.Request(deviceID, folder, name, size, offset, hash, weakHash, fromTemporary)
 }
+
+func (m nativeModel) RescanHint(deviceID DeviceID, hint RescanHint) {
+	for i := range hint.Names {
+		hint.Names[i] = norm.NFD.String(hint.Names[i])
+	}
+	m.Model.RescanHint(deviceID, hint)
+}
+
+func (m nativeModel) BlockMismatch(deviceID DeviceID, mismatch BlockMismatch) {
+	mismatch.Name = norm.NFD.String(mismatch.Name)
+	m.Model.BlockMismatch(deviceID, mismatch)
+}
@@ -35,6 +35,28 @@ func (m nativeModel) Request(deviceID DeviceID, folder, name string, size int32,
 	return m.Model.Request(deviceID, folder, name, size, offset, hash, weakHash, fromTemporary)
 }
 
+func (m nativeModel) RescanHint(deviceID DeviceID, hint RescanHint) {
+	names := make([]string, 0, len(hint.Names))
+	for _, name := range hint.Names {
+		if strings.Contains(name, `\`) {
+			l.Warnf("Dropping rescan hint for %s, contains invalid path separator", name)
+			continue
+		}
+		names = append(names, filepath.FromSlash(name))
+	}
+	hint.Names = names
+	m.Model.RescanHint(deviceID, hint)
+}
+
+func (m nativeModel) BlockMismatch(deviceID DeviceID, mismatch BlockMismatch) {
+	if strings.Contains(mismatch.Name, `\`) {
+		l.Warnf("Dropping block mismatch for %s, contains invalid path separator", mismatch.Name)
+		return
+	}
+	mismatch.Name = filepath.FromSlash(mismatch.Name)
+	m.Model.BlockMismatch(deviceID, mismatch)
+}
+
 func fixupFiles(files []FileInfo) []FileInfo {
 	var out []FileInfo
 	for i := range files {
@@ -144,6 +144,16 @@ type Model interface {
 	Closed(conn Connection, err error)
 	// The peer device sent progress updates for the files it is currently downloading
 	DownloadProgress(deviceID DeviceID, folder string, updates []FileDownloadProgressUpdate)
+	// The peer device sent a summary of the state of its folders
+	FolderActivity(deviceID DeviceID, activity FolderActivity)
+	// The peer device asks us to rescan files it suspects our index is wrong about
+	RescanHint(deviceID DeviceID, hint RescanHint)
+	// The peer device got data from us not matching the hash we announced
+	BlockMismatch(deviceID DeviceID, mismatch BlockMismatch)
+	// The peer device pushes configuration for us to apply
+	ConfigPush(deviceID DeviceID, push ConfigPush)
+	// The peer device acknowledges configuration we pushed to it
+	ConfigPushResponse(deviceID DeviceID, resp ConfigPushResponse)
 }
 
 type RequestResponse interface {
@@ -159,9 +169,16 @@ type Connection interface {
 	Name() string
 	Index(folder string, files []FileInfo) error
 	IndexUpdate(folder string, files []FileInfo) error
+	SendEncodedIndex(idx *EncodedIndex) error
 	Request(folder string, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error)
 	ClusterConfig(config ClusterConfig)
 	DownloadProgress(folder string, updates []FileDownloadProgressUpdate)
+	FolderActivity(folders []FolderStatus)
+	RescanHint(folder string, names []string)
+	BlockMismatch(folder, name string, offset int64, size int32, hash []byte)
+	ConfigPush(id int64, config, signature []byte)
+	ConfigPushResponse(id int64, err string)
+	SetTracer(t *Tracer)
 	Statistics() Statistics
 	Closed() bool
 }
@@ -189,6 +206,9 @@ type rawConnection struct {
 	sendCloseOnce     sync.Once
 	wg                sync.WaitGroup
 	compression       Compression
+
+	tracer    *Tracer // nil unless tracing
+	tracerMut sync.Mutex
 }
 
 type asyncResult struct {
@@ -217,14 +237,23 @@ const (
 	ReceiveTimeout = 300 * time.Second
 )
 
-func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression) Connection {
+// NewConnection returns a connection to the device. The folders with a
+// password are encrypted with it toward the device, as it's untrusted.
+func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiver Model, name string, compress Compression, passwords map[string]string) Connection {
 	cr := &countingReader{Reader: reader}
 	cw := &countingWriter{Writer: writer}
 
+	receiver = nativeModel{receiver}
+	var keys map[string]*[keySize]byte
+	if len(passwords) > 0 {
+		keys = folderKeys(passwords)
+		receiver = encryptedModel{receiver, keys}
+	}
+
 	c := rawConnection{
 		id:                deviceID,
 		name:              name,
-		receiver:          nativeModel{receiver},
+		receiver:          receiver,
 		cr:                cr,
 		cw:                cw,
 		awaiting:          make(map[int32]chan asyncResult),
@@ -234,6 +263,9 @@ func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, receiv
 		compression:       compress,
 	}
 
+	if keys != nil {
+		return wireFormatConnection{encryptedConnection{&c, keys}}
+	}
 	return wireFormatConnection{&c}
 }
 
@@ -290,6 +322,20 @@ func (c *rawConnection) IndexUpdate(folder string, idx []FileInfo) error {
 	return nil
 }
 
+// SendEncodedIndex writes the already encoded Index or IndexUpdate message
+// to the connected peer device
+func (c *rawConnection) SendEncodedIndex(idx *EncodedIndex) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+	c.idxMut.Lock()
+	c.send(idx, nil)
+	c.idxMut.Unlock()
+	return nil
+}
+
 // Request returns the bytes for the specified block after fetching them from the connected peer.
 func (c *rawConnection) Request(folder string, name string, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
 	c.nextIDMut.Lock()
@@ -359,6 +405,71 @@ func (c *rawConnection) DownloadProgress(folder string, updates []FileDownloadPr
 	}, nil)
 }
 
+// FolderActivity sends a summary of the state of our folders. It should only
+// be sent to peers that announced support for it in their cluster config.
+func (c *rawConnection) FolderActivity(folders []FolderStatus) {
+	c.send(&FolderActivity{
+		Folders: folders,
+	}, nil)
+}
+
+// RescanHint asks the peer to rescan the given files, as the data it
+// announced for them could not be retrieved. It should only be sent to peers
+// that announced support for it in their cluster config.
+func (c *rawConnection) RescanHint(folder string, names []string) {
+	c.send(&RescanHint{
+		Folder: folder,
+		Names:  names,
+	}, nil)
+}
+
+// BlockMismatch tells the peer that the block it sent us did not match the
+// hash it announced for it. It should only be sent to peers that announced
+// support for it in their cluster config.
+func (c *rawConnection) BlockMismatch(folder, name string, offset int64, size int32, hash []byte) {
+	c.send(&BlockMismatch{
+		Folder: folder,
+		Name:   name,
+		Offset: offset,
+		Size:   size,
+		Hash:   hash,
+	}, nil)
+}
+
+// ConfigPush sends the peer configuration to apply, which it acknowledges
+// with a ConfigPushResponse of the same ID. It should only be sent to peers
+// that announced accepting it from us in their cluster config.
+func (c *rawConnection) ConfigPush(id int64, config, signature []byte) {
+	c.send(&ConfigPush{
+		ID:        id,
+		Config:    config,
+		Signature: signature,
+	}, nil)
+}
+
+// ConfigPushResponse acknowledges the config push of the given ID, with an
+// empty error if it was applied.
+func (c *rawConnection) ConfigPushResponse(id int64, err string) {
+	c.send(&ConfigPushResponse{
+		ID:    id,
+		Error: err,
+	}, nil)
+}
+
+// SetTracer makes the connection record the messages it sends and receives
+// to the tracer, or stop doing so when it's nil.
+func (c *rawConnection) SetTracer(t *Tracer) {
+	c.tracerMut.Lock()
+	c.tracer = t
+	c.tracerMut.Unlock()
+}
+
+func (c *rawConnection) getTracer() *Tracer {
+	c.tracerMut.Lock()
+	defer c.tracerMut.Unlock()
+	return c.tracer
+}
+
 func (c *rawConnection) ping() bool {
 	return c.send(&Ping{}, nil)
 }
@@ -436,6 +547,41 @@ func (c *rawConnection) readerLoop() (err error) {
 			}
 			c.receiver.DownloadProgress(c.id, msg.Folder, msg.Updates)
 
+		case *FolderActivity:
+			l.Debugln("read FolderActivity message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: folder activity message in state %d", state)
+			}
+			c.receiver.FolderActivity(c.id, *msg)
+
+		case *RescanHint:
+			l.Debugln("read RescanHint message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: rescan hint message in state %d", state)
+			}
+			c.receiver.RescanHint(c.id, *msg)
+
+		case *BlockMismatch:
+			l.Debugln("read BlockMismatch message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: block mismatch message in state %d", state)
+			}
+			c.receiver.BlockMismatch(c.id, *msg)
+
+		case *ConfigPush:
+			l.Debugln("read ConfigPush message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: config push message in state %d", state)
+			}
+			c.receiver.ConfigPush(c.id, *msg)
+
+		case *ConfigPushResponse:
+			l.Debugln("read ConfigPushResponse message")
+			if state != stateReady {
+				return fmt.Errorf("protocol error: config push response message in state %d", state)
+			}
+			c.receiver.ConfigPushResponse(c.id, *msg)
+
 		case *Ping:
 			l.Debugln("read Ping message")
 			if state != stateReady {
@@ -455,12 +601,23 @@ func (c *rawConnection) readerLoop() (err error) {
 }
 
 func (c *rawConnection) readMessage(fourByteBuf []byte) (message, error) {
+	before := c.cr.Tot()
 	hdr, err := c.readHeader(fourByteBuf)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.readMessageAfterHeader(hdr, fourByteBuf)
+	tracer := c.getTracer()
+	if tracer == nil {
+		return c.readMessageAfterHeader(hdr, fourByteBuf)
+	}
+	// The time until the header arrived is spent waiting, not reading.
+	start := time.Now()
+	msg, err := c.readMessageAfterHeader(hdr, fourByteBuf)
+	if err == nil {
+		tracer.record(newTraceEntry(msg, hdr.Type, false, start, c.cr.Tot()-before))
+	}
+	return msg, err
 }
 
 func (c *rawConnection) readMessageAfterHeader(hdr Header, fourByteBuf []byte) (message, error) {
@@ -681,20 +838,38 @@ func (c *rawConnection) writerLoop() {
 }
 
 func (c *rawConnection) writeMessage(hm asyncMessage) error {
+	tracer := c.getTracer()
+	start := time.Now()
+	before := c.cw.Tot()
+
+	var err error
 	if c.shouldCompressMessage(hm.msg) {
-		return c.writeCompressedMessage(hm)
+		err = c.writeCompressedMessage(hm)
+	} else {
+		err = c.writeUncompressedMessage(hm)
 	}
-	return c.writeUncompressedMessage(hm)
+
+	if tracer != nil && err == nil {
+		tracer.record(newTraceEntry(hm.msg, c.typeOf(hm.msg), true, start, c.cw.Tot()-before))
+	}
+	return err
 }
 
 func (c *rawConnection) writeCompressedMessage(hm asyncMessage) error {
 	size := hm.msg.ProtoSize()
 	buf := BufferPool.Get(size)
-	if _, err := hm.msg.MarshalTo(buf); err != nil {
-		return fmt.Errorf("marshalling message: %v", err)
+	var compressed []byte
+	var err error
+	enc, encoded := hm.msg.(*EncodedIndex)
+	if encoded {
+		// Compressed once for all connections it's sent on, and kept.
+		compressed, err = enc.lz4Compressed(c.lz4Compress)
+	} else {
+		if _, err := hm.msg.MarshalTo(buf); err != nil {
+			return fmt.Errorf("marshalling message: %v", err)
+		}
+		compressed, err = c.lz4Compress(buf)
 	}
-
-	compressed, err := c.lz4Compress(buf)
 	if err != nil {
 		return fmt.Errorf("compressing message: %v", err)
 	}
@@ -721,7 +896,9 @@ func (c *rawConnection) writeCompressedMessage(hm asyncMessage) error {
 	binary.BigEndian.PutUint32(buf[2+hdrSize:], uint32(len(compressed)))
 	// Message
 	copy(buf[2+hdrSize+4:], compressed)
-	BufferPool.Put(compressed)
+	if !encoded {
+		BufferPool.Put(compressed)
+	}
 
 	n, err := c.cw.Write(buf)
 	BufferPool.Put(buf)
@@ -771,7 +948,7 @@ func (c *rawConnection) writeUncompressedMessage(hm asyncMessage) error {
 }
 
 func (c *rawConnection) typeOf(msg message) MessageType {
-	switch msg.(type) {
+	switch msg := msg.(type) {
 	case *ClusterConfig:
 		return messageTypeClusterConfig
 	case *Index:
@@ -788,6 +965,18 @@ func (c *rawConnection) typeOf(msg message) MessageType {
 		return messageTypePing
 	case *Close:
 		return messageTypeClose
+	case *FolderActivity:
+		return messageTypeFolderActivity
+	case *RescanHint:
+		return messageTypeRescanHint
+	case *BlockMismatch:
+		return messageTypeBlockMismatch
+	case *ConfigPush:
+		return messageTypeConfigPush
+	case *ConfigPushResponse:
+		return messageTypeConfigPushResponse
+	case *EncodedIndex:
+		return msg.typ
 	default:
 		panic("bug: unknown message type")
 	}
@@ -811,6 +1000,16 @@ func (c *rawConnection) newMessage(t MessageType) (message, error) {
 		return new(Ping), nil
 	case messageTypeClose:
 		return new(Close), nil
+	case messageTypeFolderActivity:
+		return new(FolderActivity), nil
+	case messageTypeRescanHint:
+		return new(RescanHint), nil
+	case messageTypeBlockMismatch:
+		return new(BlockMismatch), nil
+	case messageTypeConfigPush:
+		return new(ConfigPush), nil
+	case messageTypeConfigPushResponse:
+		return new(ConfigPushResponse), nil
 	default:
 		return nil, errUnknownMessage
 	}
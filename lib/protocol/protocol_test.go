@@ -8,12 +8,15 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/syncthing/syncthing/lib/rand"
 )
@@ -28,9 +31,9 @@ func TestPing(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -43,6 +46,73 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestTracer(t *testing.T) {
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+
+	tracer := NewTracer(10)
+	c0 := NewConnection(c0ID, ar, bw, newTestModel(), "name", CompressAlways, nil).(wireFormatConnection).Connection.(*rawConnection)
+	c0.SetTracer(tracer)
+	c0.Start()
+	c1 := NewConnection(c1ID, br, aw, newTestModel(), "name", CompressAlways, nil).(wireFormatConnection).Connection.(*rawConnection)
+	c1.Start()
+	c0.ClusterConfig(ClusterConfig{})
+	c1.ClusterConfig(ClusterConfig{})
+
+	if ok := c0.ping(); !ok {
+		t.Fatal("c0 ping failed")
+	}
+	if ok := c1.ping(); !ok {
+		t.Fatal("c1 ping failed")
+	}
+
+	// The ping from c1 is recorded once c0 has read it.
+	var entries []TraceEntry
+	for i := 0; i < 100; i++ {
+		if entries = tracer.Entries(); len(entries) == 4 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.WireSize == 0 {
+			t.Errorf("No wire size for %+v", e)
+		}
+		seen[fmt.Sprintf("%v %v", e.Sent, e.Type)] = true
+	}
+	for _, exp := range []string{"true CLUSTER_CONFIG", "false CLUSTER_CONFIG", "true PING", "false PING"} {
+		if !seen[exp] {
+			t.Errorf("Missing %v in %+v", exp, entries)
+		}
+	}
+
+	tracer.Stop()
+	if ok := c0.ping(); !ok {
+		t.Fatal("c0 ping failed")
+	}
+	if l := len(tracer.Entries()); l != 4 {
+		t.Errorf("Stopped tracer recorded %d entries, expected 4", l)
+	}
+}
+
+func TestTracerRing(t *testing.T) {
+	tracer := NewTracer(3)
+	for i := 0; i < 5; i++ {
+		tracer.record(TraceEntry{Size: i})
+	}
+	entries := tracer.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Got %d entries, expected 3", len(entries))
+	}
+	for i, e := range entries {
+		if e.Size != i+2 {
+			t.Errorf("Entry %d has size %d, expected %d", i, e.Size, i+2)
+		}
+	}
+}
+
 func TestClose(t *testing.T) {
 	m0 := newTestModel()
 	m1 := newTestModel()
@@ -50,9 +120,9 @@ func TestClose(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways).(wireFormatConnection).Connection.(*rawConnection)
+	c0 := NewConnection(c0ID, ar, bw, m0, "name", CompressAlways, nil).(wireFormatConnection).Connection.(*rawConnection)
 	c0.Start()
-	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways)
+	c1 := NewConnection(c1ID, br, aw, m1, "name", CompressAlways, nil)
 	c1.Start()
 	c0.ClusterConfig(ClusterConfig{})
 	c1.ClusterConfig(ClusterConfig{})
@@ -158,6 +228,9 @@ func TestMarshalClusterConfigMessage(t *testing.T) {
 			if len(m1.Folders[i].Devices) == 0 {
 				m1.Folders[i].Devices = nil
 			}
+			if len(m1.Folders[i].Metadata) == 0 {
+				m1.Folders[i].Metadata = nil
+			}
 		}
 		return testMarshal(t, "clusterconfig", &m1, &ClusterConfig{})
 	}
@@ -265,6 +338,60 @@ func TestLZ4Compression(t *testing.T) {
 	}
 }
 
+func TestEncodedIndex(t *testing.T) {
+	files := []FileInfo{
+		{Name: "a", Sequence: 1},
+		// Native separator and decomposed form.
+		{Name: filepath.Join("dir", "a\u030a"), Sequence: 2},
+	}
+
+	enc, err := EncodeIndex("default", files, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !enc.IsUpdate() || enc.Folder() != "default" || enc.NumFiles() != 2 {
+		t.Errorf("unexpected encoded index: update %v, folder %q, %d files", enc.IsUpdate(), enc.Folder(), enc.NumFiles())
+	}
+	if files[1].Name != filepath.Join("dir", "a\u030a") {
+		t.Error("EncodeIndex modified the given files")
+	}
+
+	decoded, err := enc.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Name != "a" || decoded[1].Name != "dir/\u00e5" {
+		t.Errorf("unexpected decoded files %v", decoded)
+	}
+
+	calls := 0
+	compress := func(data []byte) ([]byte, error) {
+		calls++
+		return new(rawConnection).lz4Compress(data)
+	}
+	first, err := enc.lz4Compressed(compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := enc.lz4Compressed(compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("compressed %d times, expected once", calls)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("compressed data differs between calls")
+	}
+	res, err := new(rawConnection).lz4Decompress(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := enc.Marshal(); !bytes.Equal(res, data) {
+		t.Error("incorrect decompressed data")
+	}
+}
+
 func TestCheckFilename(t *testing.T) {
 	cases := []struct {
 		name string
@@ -601,10 +728,10 @@ func TestIsEquivalent(t *testing.T) {
 					continue
 				}
 
-				if res := tc.a.isEquivalent(tc.b, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
+				if res := tc.a.IsEquivalentOptional(tc.b, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
 					t.Errorf("Case %d:\na: %v\nb: %v\na.IsEquivalent(b, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, res, tc.eq)
 				}
-				if res := tc.b.isEquivalent(tc.a, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
+				if res := tc.b.IsEquivalentOptional(tc.a, ignPerms, ignBlocks, tc.ignFlags); res != tc.eq {
 					t.Errorf("Case %d:\na: %v\nb: %v\nb.IsEquivalent(a, %v, %v) => %v, expected %v", i, tc.a, tc.b, ignPerms, ignBlocks, res, tc.eq)
 				}
 			}
@@ -612,6 +739,27 @@ func TestIsEquivalent(t *testing.T) {
 	}
 }
 
+func TestIsEquivalentPermBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("only the user write bit counts on Windows")
+	}
+
+	a := FileInfo{Permissions: 0644}
+	b := FileInfo{Permissions: 0755}
+	if a.IsEquivalentPermBits(b, 0, false, 0) {
+		t.Error("different permissions are equivalent")
+	}
+	if a.IsEquivalentPermBits(b, 0100, false, 0) {
+		t.Error("different permissions are equivalent with some bits ignored")
+	}
+	if !a.IsEquivalentPermBits(b, 0111, false, 0) {
+		t.Error("permissions differing only in the ignored bits aren't equivalent")
+	}
+	if !a.IsEquivalentPermBits(FileInfo{Permissions: 0600}, 0777, false, 0) {
+		t.Error("permissions aren't equivalent with all bits ignored")
+	}
+}
+
 func TestSha256OfEmptyBlock(t *testing.T) {
 	// every block size should have a correct entry in sha256OfEmptyBlock
 	for blockSize := MinBlockSize; blockSize <= MaxBlockSize; blockSize *= 2 {
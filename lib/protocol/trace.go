@@ -0,0 +1,117 @@
+// Copyright (C) 2019 The Protocol Authors.
+
+package protocol
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A TraceEntry describes one message sent or received on a connection.
+type TraceEntry struct {
+	Time     time.Time     `json:"time"`
+	Sent     bool          `json:"sent"`
+	Type     string        `json:"type"`
+	Size     int           `json:"size"`     // of the message, uncompressed
+	WireSize int           `json:"wireSize"` // including headers, as compressed on the wire
+	Duration time.Duration `json:"duration"` // spent writing or reading the message
+	Detail   string        `json:"detail,omitempty"`
+}
+
+// A Tracer records the most recent messages of a connection into a ring
+// buffer, for debugging interoperability without debug logging. It is safe
+// for use from multiple goroutines.
+type Tracer struct {
+	mut     sync.Mutex
+	entries []TraceEntry
+	next    int
+	full    bool
+	stopped bool
+}
+
+// NewTracer returns a Tracer that keeps the given number of most recent
+// messages.
+func NewTracer(size int) *Tracer {
+	return &Tracer{
+		entries: make([]TraceEntry, size),
+	}
+}
+
+// Stop makes the tracer stop recording. The entries recorded so far are
+// kept.
+func (t *Tracer) Stop() {
+	t.mut.Lock()
+	t.stopped = true
+	t.mut.Unlock()
+}
+
+// Stopped returns true if the tracer no longer records.
+func (t *Tracer) Stopped() bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.stopped
+}
+
+// Entries returns the recorded entries, oldest first.
+func (t *Tracer) Entries() []TraceEntry {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if !t.full {
+		return append([]TraceEntry(nil), t.entries[:t.next]...)
+	}
+	res := make([]TraceEntry, 0, len(t.entries))
+	res = append(res, t.entries[t.next:]...)
+	return append(res, t.entries[:t.next]...)
+}
+
+func (t *Tracer) record(e TraceEntry) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.stopped || len(t.entries) == 0 {
+		return
+	}
+	t.entries[t.next] = e
+	t.next++
+	if t.next == len(t.entries) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+func newTraceEntry(msg message, typ MessageType, sent bool, start time.Time, wireSize int64) TraceEntry {
+	return TraceEntry{
+		Time:     start,
+		Sent:     sent,
+		Type:     typ.String(),
+		Size:     msg.ProtoSize(),
+		WireSize: int(wireSize),
+		Duration: time.Since(start),
+		Detail:   traceDetail(msg),
+	}
+}
+
+// traceDetail returns what identifies the message, apart from its type.
+func traceDetail(msg message) string {
+	switch msg := msg.(type) {
+	case *Index:
+		return fmt.Sprintf("folder=%q files=%d", msg.Folder, len(msg.Files))
+	case *IndexUpdate:
+		return fmt.Sprintf("folder=%q files=%d", msg.Folder, len(msg.Files))
+	case *EncodedIndex:
+		return fmt.Sprintf("folder=%q files=%d", msg.Folder(), msg.NumFiles())
+	case *Request:
+		return fmt.Sprintf("id=%d folder=%q name=%q offset=%d size=%d temp=%v", msg.ID, msg.Folder, msg.Name, msg.Offset, msg.Size, msg.FromTemporary)
+	case *Response:
+		return fmt.Sprintf("id=%d code=%v data=%d", msg.ID, msg.Code, len(msg.Data))
+	case *DownloadProgress:
+		return fmt.Sprintf("folder=%q updates=%d", msg.Folder, len(msg.Updates))
+	case *ClusterConfig:
+		return fmt.Sprintf("folders=%d", len(msg.Folders))
+	case *Close:
+		return fmt.Sprintf("reason=%q", msg.Reason)
+	}
+	return ""
+}
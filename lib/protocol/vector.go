@@ -206,3 +206,20 @@ func (v Vector) Compare(b Vector) Ordering {
 
 	return result
 }
+
+// String returns the ordering as "equal", "greater", "lesser" or
+// "concurrent", for either of the concurrent orderings.
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Greater:
+		return "greater"
+	case Lesser:
+		return "lesser"
+	case ConcurrentLesser, ConcurrentGreater:
+		return "concurrent"
+	default:
+		return "unknown"
+	}
+}
@@ -38,3 +38,16 @@ func (c wireFormatConnection) Request(folder, name string, offset int64, size in
 	name = norm.NFC.String(filepath.ToSlash(name))
 	return c.Connection.Request(folder, name, offset, size, hash, weakHash, fromTemporary)
 }
+
+func (c wireFormatConnection) RescanHint(folder string, names []string) {
+	myNames := make([]string, len(names))
+	for i := range names {
+		myNames[i] = norm.NFC.String(filepath.ToSlash(names[i]))
+	}
+	c.Connection.RescanHint(folder, myNames)
+}
+
+func (c wireFormatConnection) BlockMismatch(folder, name string, offset int64, size int32, hash []byte) {
+	name = norm.NFC.String(filepath.ToSlash(name))
+	c.Connection.BlockMismatch(folder, name, offset, size, hash)
+}
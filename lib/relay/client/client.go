@@ -29,6 +29,7 @@ type RelayClient interface {
 	String() string
 	Invitations() chan protocol.SessionInvitation
 	URI() *url.URL
+	URIs() []*url.URL // of all the relays connected to, the closest first
 }
 
 func NewClient(uri *url.URL, certs []tls.Certificate, invitations chan protocol.SessionInvitation, timeout time.Duration) (RelayClient, error) {
@@ -39,3 +40,14 @@ func NewClient(uri *url.URL, certs []tls.Certificate, invitations chan protocol.
 
 	return factory(uri, certs, invitations, timeout), nil
 }
+
+// NewMultiClient returns a client like NewClient, except that for a pool of
+// relays it stays connected to the given number of them at once. Each
+// failed relay is replaced by the next one, by latency.
+func NewMultiClient(uri *url.URL, certs []tls.Certificate, invitations chan protocol.SessionInvitation, timeout time.Duration, relays int) (RelayClient, error) {
+	c, err := NewClient(uri, certs, invitations, timeout)
+	if dc, ok := c.(*dynamicClient); ok && relays > 1 {
+		dc.relays = relays
+	}
+	return c, err
+}
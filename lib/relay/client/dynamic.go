@@ -24,10 +24,15 @@ type dynamicClient struct {
 	closeInvitationsOnFinish bool
 	timeout                  time.Duration
 
-	mut    sync.RWMutex
-	err    error
-	client RelayClient
-	stop   chan struct{}
+	relays int // to stay connected to at once
+
+	// Creates the client of each relay; NewClient, unless testing.
+	newClient func(uri *url.URL, certs []tls.Certificate, invitations chan protocol.SessionInvitation, timeout time.Duration) (RelayClient, error)
+
+	mut     sync.RWMutex
+	err     error
+	clients []RelayClient
+	stop    chan struct{}
 }
 
 func newDynamicClient(uri *url.URL, certs []tls.Certificate, invitations chan protocol.SessionInvitation, timeout time.Duration) RelayClient {
@@ -42,6 +47,7 @@ func newDynamicClient(uri *url.URL, certs []tls.Certificate, invitations chan pr
 		invitations:              invitations,
 		closeInvitationsOnFinish: closeInvitationsOnFinish,
 		timeout:                  timeout,
+		relays:                   1,
 
 		mut: sync.NewRWMutex(),
 	}
@@ -87,63 +93,111 @@ func (c *dynamicClient) Serve() {
 		addrs = append(addrs, ruri.String())
 	}
 
-	for _, addr := range relayAddressesOrder(addrs) {
-		select {
-		case <-c.stop:
-			l.Debugln(c, "stopping")
-			c.setError(nil)
-			return
-		default:
+	// Connect to the relays with the lowest latency, and to the next one
+	// each time one of them fails.
+	candidates := relayAddressesOrder(addrs)
+	newClient := c.newClient
+	if newClient == nil {
+		newClient = NewClient
+	}
+	finished := make(chan RelayClient)
+	startNext := func() bool {
+		for len(candidates) > 0 {
+			addr := candidates[0]
+			candidates = candidates[1:]
 			ruri, err := url.Parse(addr)
 			if err != nil {
 				l.Debugln(c, "skipping relay", addr, err)
 				continue
 			}
-			client, err := NewClient(ruri, c.certs, c.invitations, c.timeout)
+			client, err := newClient(ruri, c.certs, c.invitations, c.timeout)
 			if err != nil {
 				continue
 			}
 			c.mut.Lock()
-			c.client = client
+			c.clients = append(c.clients, client)
 			c.mut.Unlock()
+			go func() {
+				client.Serve()
+				finished <- client
+			}()
+			return true
+		}
+		return false
+	}
 
-			c.client.Serve()
-
-			c.mut.Lock()
-			c.client = nil
-			c.mut.Unlock()
+	running := 0
+	for running < c.relays && startNext() {
+		running++
+	}
+	for running > 0 {
+		select {
+		case <-c.stop:
+			l.Debugln(c, "stopping")
+			c.mut.RLock()
+			for _, client := range c.clients {
+				go client.Stop()
+			}
+			c.mut.RUnlock()
+			for ; running > 0; running-- {
+				c.removeClient(<-finished)
+			}
+			c.setError(nil)
+			return
+		case client := <-finished:
+			l.Debugln(c, client, "finished")
+			c.removeClient(client)
+			running--
+			for running < c.relays && startNext() {
+				running++
+			}
 		}
 	}
 	l.Debugln(c, "could not find a connectable relay")
 	c.setError(fmt.Errorf("could not find a connectable relay"))
 }
 
+func (c *dynamicClient) removeClient(client RelayClient) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for i, cl := range c.clients {
+		if cl == client {
+			c.clients = append(c.clients[:i], c.clients[i+1:]...)
+			return
+		}
+	}
+}
+
 func (c *dynamicClient) Stop() {
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 	close(c.stop)
-	if c.client == nil {
-		return
-	}
-	c.client.Stop()
 }
 
+// Error returns nil if the client is connected to any relay, or else the
+// error of the relay it last tried.
 func (c *dynamicClient) Error() error {
 	c.mut.RLock()
 	defer c.mut.RUnlock()
-	if c.client == nil {
+	if len(c.clients) == 0 {
 		return c.err
 	}
-	return c.client.Error()
+	var err error
+	for _, client := range c.clients {
+		if err = client.Error(); err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
+// Latency returns the latency of the closest relay.
 func (c *dynamicClient) Latency() time.Duration {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-	if c.client == nil {
+	clients := c.sortedClients()
+	if len(clients) == 0 {
 		return time.Hour
 	}
-	return c.client.Latency()
+	return clients[0].Latency()
 }
 
 func (c *dynamicClient) String() string {
@@ -151,12 +205,50 @@ func (c *dynamicClient) String() string {
 }
 
 func (c *dynamicClient) URI() *url.URL {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-	if c.client == nil {
+	clients := c.sortedClients()
+	if len(clients) == 0 {
 		return nil
 	}
-	return c.client.URI()
+	return clients[0].URI()
+}
+
+// URIs returns only the relays we are connected to, as those still
+// connecting or failing are of no use to the devices we announce them to.
+func (c *dynamicClient) URIs() []*url.URL {
+	var uris []*url.URL
+	for _, client := range c.sortedClients() {
+		if !isConnected(client) {
+			continue
+		}
+		uris = append(uris, client.URI())
+	}
+	return uris
+}
+
+// sortedClients returns the clients of the relays connected to first,
+// those with the lowest latency first.
+func (c *dynamicClient) sortedClients() []RelayClient {
+	c.mut.RLock()
+	clients := append([]RelayClient(nil), c.clients...)
+	c.mut.RUnlock()
+	connected := make(map[RelayClient]bool, len(clients))
+	for _, client := range clients {
+		connected[client] = isConnected(client)
+	}
+	sort.SliceStable(clients, func(a, b int) bool {
+		if connected[clients[a]] != connected[clients[b]] {
+			return connected[clients[a]]
+		}
+		return clients[a].Latency() < clients[b].Latency()
+	})
+	return clients
+}
+
+// isConnected returns whether the client, if it is one that knows, is
+// connected to its relay.
+func isConnected(client RelayClient) bool {
+	status, ok := client.(interface{ StatusOK() bool })
+	return !ok || status.StatusOK()
 }
 
 func (c *dynamicClient) Invitations() chan protocol.SessionInvitation {
@@ -215,7 +307,7 @@ func relayAddressesOrder(input []string) []string {
 
 	sort.Ints(ids)
 
-	addresses := make([]string, len(input))
+	addresses := make([]string, 0, len(input))
 
 	for _, id := range ids {
 		addresses = append(addresses, buckets[id]...)
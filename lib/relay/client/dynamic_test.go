@@ -0,0 +1,227 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/relay/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// A fakeStaticClient stands in for the client of a single relay, connected
+// or failing as the test says.
+type fakeStaticClient struct {
+	uri     *url.URL
+	latency time.Duration
+
+	mut       sync.Mutex
+	connected bool
+	err       error
+	stop      chan struct{}
+	stopped   bool
+}
+
+func newFakeStaticClient(uri *url.URL, latency time.Duration) *fakeStaticClient {
+	return &fakeStaticClient{
+		uri:     uri,
+		latency: latency,
+		mut:     sync.NewMutex(),
+		stop:    make(chan struct{}),
+	}
+}
+
+func (c *fakeStaticClient) Serve() {
+	<-c.stop
+}
+
+func (c *fakeStaticClient) Stop() {
+	c.fail(nil)
+}
+
+func (c *fakeStaticClient) connect() {
+	c.mut.Lock()
+	c.connected = true
+	c.mut.Unlock()
+}
+
+func (c *fakeStaticClient) fail(err error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.connected = false
+	c.err = err
+	if !c.stopped {
+		c.stopped = true
+		close(c.stop)
+	}
+}
+
+func (c *fakeStaticClient) StatusOK() bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.connected
+}
+
+func (c *fakeStaticClient) Error() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.err
+}
+
+func (c *fakeStaticClient) Latency() time.Duration                       { return c.latency }
+func (c *fakeStaticClient) String() string                               { return "fake@" + c.uri.String() }
+func (c *fakeStaticClient) Invitations() chan protocol.SessionInvitation { return nil }
+func (c *fakeStaticClient) URI() *url.URL                                { return c.uri }
+func (c *fakeStaticClient) URIs() []*url.URL                             { return []*url.URL{c.uri} }
+
+// newFakePool returns a dynamic client for a pool of the given relays, each
+// with a latency of its port in milliseconds, and a channel getting the
+// fake client of each relay it starts using.
+func newFakePool(t *testing.T, relays int, addrs ...string) (*dynamicClient, <-chan *fakeStaticClient, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"relays": [`)
+		for i, addr := range addrs {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"url": %q}`, addr)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+
+	uri, err := url.Parse("dynamic+" + srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newDynamicClient(uri, nil, nil, time.Second).(*dynamicClient)
+	c.relays = relays
+	created := make(chan *fakeStaticClient, len(addrs))
+	c.newClient = func(uri *url.URL, _ []tls.Certificate, _ chan protocol.SessionInvitation, _ time.Duration) (RelayClient, error) {
+		var port int
+		fmt.Sscan(uri.Port(), &port)
+		fake := newFakeStaticClient(uri, time.Duration(port)*time.Millisecond)
+		created <- fake
+		return fake, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Serve()
+		close(done)
+	}()
+	return c, created, func() {
+		c.Stop()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("Dynamic client did not stop")
+		}
+		srv.Close()
+	}
+}
+
+func nextFake(t *testing.T, created <-chan *fakeStaticClient) *fakeStaticClient {
+	t.Helper()
+	select {
+	case fake := <-created:
+		return fake
+	case <-time.After(10 * time.Second):
+		t.Fatal("No relay client started")
+		return nil
+	}
+}
+
+func waitURIs(t *testing.T, c *dynamicClient, expected ...*url.URL) {
+	t.Helper()
+	t0 := time.Now()
+	for {
+		uris := c.URIs()
+		if len(uris) == 0 && len(expected) == 0 || reflect.DeepEqual(uris, expected) {
+			return
+		}
+		if time.Since(t0) > 10*time.Second {
+			t.Fatalf("Got URIs %v, expected %v", uris, expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDynamicClientMultiRelay(t *testing.T) {
+	c, created, stop := newFakePool(t, 2, "relay://127.0.0.1:1", "relay://127.0.0.1:2", "relay://127.0.0.1:3")
+	defer stop()
+
+	a := nextFake(t, created)
+	b := nextFake(t, created)
+	select {
+	case fake := <-created:
+		t.Fatal("Started a third relay client:", fake)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Nothing is announced before being connected.
+	waitURIs(t, c)
+	if err := c.Error(); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+
+	a.connect()
+	waitURIs(t, c, a.uri)
+
+	// Both connected, the closest first.
+	b.connect()
+	if a.latency < b.latency {
+		waitURIs(t, c, a.uri, b.uri)
+	} else {
+		waitURIs(t, c, b.uri, a.uri)
+	}
+	if uri := c.URI(); uri != a.uri && uri != b.uri {
+		t.Error("Unexpected URI", uri)
+	}
+}
+
+func TestDynamicClientFailover(t *testing.T) {
+	c, created, stop := newFakePool(t, 2, "relay://127.0.0.1:1", "relay://127.0.0.1:2", "relay://127.0.0.1:3")
+	defer stop()
+
+	a := nextFake(t, created)
+	b := nextFake(t, created)
+	a.connect()
+	b.connect()
+
+	// A relay that fails is no longer announced, and replaced by the one
+	// left, which is announced once connected.
+	a.fail(fmt.Errorf("failed"))
+	next := nextFake(t, created)
+	waitURIs(t, c, b.uri)
+	next.connect()
+	if b.latency < next.latency {
+		waitURIs(t, c, b.uri, next.uri)
+	} else {
+		waitURIs(t, c, next.uri, b.uri)
+	}
+
+	// With no relays left to replace the failed ones there's nothing to
+	// announce and the last error is returned.
+	b.fail(fmt.Errorf("failed"))
+	waitURIs(t, c, next.uri)
+	next.fail(fmt.Errorf("failed"))
+	waitURIs(t, c)
+	t0 := time.Now()
+	for c.Error() == nil {
+		if time.Since(t0) > 10*time.Second {
+			t.Fatal("No error without relays")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
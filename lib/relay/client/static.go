@@ -205,6 +205,10 @@ func (c *staticClient) URI() *url.URL {
 	return c.uri
 }
 
+func (c *staticClient) URIs() []*url.URL {
+	return []*url.URL{c.uri}
+}
+
 func (c *staticClient) Invitations() chan protocol.SessionInvitation {
 	c.mut.RLock()
 	inv := c.invitations
@@ -16,6 +16,7 @@ var (
 	ResponseSuccess           = Response{0, "success"}
 	ResponseNotFound          = Response{1, "not found"}
 	ResponseAlreadyConnected  = Response{2, "already connected"}
+	ResponseNotAllowed        = Response{3, "not allowed"}
 	ResponseInternalError     = Response{99, "internal error"}
 	ResponseUnexpectedMessage = Response{100, "unexpected message"}
 )
@@ -11,12 +11,18 @@ import (
 	"errors"
 
 	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/tracing"
 )
 
 // HashFile hashes the files and returns a list of blocks representing the file.
 func HashFile(ctx context.Context, fs fs.Filesystem, path string, blockSize int, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
+	return hashFile(ctx, fs, path, blockSize, counter, useWeakHashes, nil)
+}
+
+func hashFile(ctx context.Context, fs fs.Filesystem, path string, blockSize int, counter Counter, useWeakHashes bool, throttle *ioThrottle) ([]protocol.BlockInfo, error) {
 	fd, err := fs.Open(path)
 	if err != nil {
 		l.Debugln("open:", err)
@@ -36,7 +42,7 @@ func HashFile(ctx context.Context, fs fs.Filesystem, path string, blockSize int,
 
 	// Hash the file. This may take a while for large files.
 
-	blocks, err := Blocks(ctx, fd, blockSize, size, counter, useWeakHashes)
+	blocks, err := Blocks(ctx, throttle.reader(ctx, fd), blockSize, size, counter, useWeakHashes)
 	if err != nil {
 		l.Debugln("blocks:", err)
 		return nil, err
@@ -62,24 +68,30 @@ func HashFile(ctx context.Context, fs fs.Filesystem, path string, blockSize int,
 // workers are used in parallel. The outbox will become closed when the inbox
 // is closed and all items handled.
 type parallelHasher struct {
-	fs      fs.Filesystem
-	workers int
-	outbox  chan<- ScanResult
-	inbox   <-chan protocol.FileInfo
-	counter Counter
-	done    chan<- struct{}
-	wg      sync.WaitGroup
+	fs       fs.Filesystem
+	workers  int
+	outbox   chan<- ScanResult
+	inbox    <-chan protocol.FileInfo
+	counter  Counter
+	tracker  *progressTracker
+	done     chan<- struct{}
+	idleIO   bool
+	throttle *ioThrottle
+	wg       sync.WaitGroup
 }
 
-func newParallelHasher(ctx context.Context, fs fs.Filesystem, workers int, outbox chan<- ScanResult, inbox <-chan protocol.FileInfo, counter Counter, done chan<- struct{}) {
+func newParallelHasher(ctx context.Context, fs fs.Filesystem, workers int, outbox chan<- ScanResult, inbox <-chan protocol.FileInfo, counter Counter, tracker *progressTracker, done chan<- struct{}, idleIO bool, throttle *ioThrottle) {
 	ph := &parallelHasher{
-		fs:      fs,
-		workers: workers,
-		outbox:  outbox,
-		inbox:   inbox,
-		counter: counter,
-		done:    done,
-		wg:      sync.NewWaitGroup(),
+		fs:       fs,
+		workers:  workers,
+		outbox:   outbox,
+		inbox:    inbox,
+		counter:  counter,
+		tracker:  tracker,
+		done:     done,
+		idleIO:   idleIO,
+		throttle: throttle,
+		wg:       sync.NewWaitGroup(),
 	}
 
 	for i := 0; i < workers; i++ {
@@ -93,6 +105,11 @@ func newParallelHasher(ctx context.Context, fs fs.Filesystem, workers int, outbo
 func (ph *parallelHasher) hashFiles(ctx context.Context) {
 	defer ph.wg.Done()
 
+	if ph.idleIO {
+		restore := osutil.SetIdleIOPriority()
+		defer restore()
+	}
+
 	for {
 		select {
 		case f, ok := <-ph.inbox:
@@ -104,7 +121,15 @@ func (ph *parallelHasher) hashFiles(ctx context.Context) {
 				panic("Bug. Asked to hash a directory or a deleted file.")
 			}
 
-			blocks, err := HashFile(ctx, ph.fs, f.Name, f.BlockSize(), ph.counter, true)
+			ph.tracker.hashingFile(f.Name)
+			_, span := tracing.Start(ctx, "scanner.hash")
+			span.SetAttribute("file", f.Name)
+			span.SetAttribute("size", f.Size)
+			blocks, err := hashFile(ctx, ph.fs, f.Name, f.BlockSize(), ph.counter, true, ph.throttle)
+			span.SetError(err)
+			span.End()
+			ph.tracker.hashedFile()
+			ph.throttle.sleep(ctx)
 			if err != nil {
 				l.Debugln("hash error:", f.Name, err)
 				continue
@@ -0,0 +1,128 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"time"
+
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// The phases of a scan.
+const (
+	// The folder is being walked, to find what changed. The totals are
+	// what was found so far.
+	ScanPhaseWalking = "walking"
+	// The changed files are being hashed.
+	ScanPhaseHashing = "hashing"
+)
+
+// Progress is the state of a scan.
+type Progress struct {
+	Folder  string    `json:"folder"`
+	Phase   string    `json:"phase"`
+	Started time.Time `json:"started"`
+	// The path most recently walked or started hashing.
+	CurrentPath string  `json:"currentPath"`
+	FilesWalked int     `json:"filesWalked"`
+	FilesHashed int     `json:"filesHashed"`
+	FilesTotal  int     `json:"filesTotal"` // to hash
+	BytesHashed int64   `json:"bytesHashed"`
+	BytesTotal  int64   `json:"bytesTotal"` // to hash
+	Rate        float64 `json:"rate"`       // bytes hashed per second
+	// The estimated time until hashing is done, zero while walking or if
+	// nothing is hashed yet.
+	ETA time.Duration `json:"eta"`
+}
+
+// A progressTracker records what the walker and hashers are up to. A nil
+// progressTracker records nothing.
+type progressTracker struct {
+	mut     sync.Mutex
+	p       Progress
+	counter *byteCounter
+}
+
+func newProgressTracker(folder string) *progressTracker {
+	return &progressTracker{
+		mut: sync.NewMutex(),
+		p: Progress{
+			Folder:  folder,
+			Phase:   ScanPhaseWalking,
+			Started: time.Now(),
+		},
+	}
+}
+
+// walked records a path the walker looked at.
+func (t *progressTracker) walked(path string) {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	t.p.CurrentPath = path
+	t.p.FilesWalked++
+	t.mut.Unlock()
+}
+
+// queued records a file of the given size to be hashed.
+func (t *progressTracker) queued(size int64) {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	t.p.FilesTotal++
+	t.p.BytesTotal += size
+	t.mut.Unlock()
+}
+
+// hashing records the start of the hashing phase, counting the hashed
+// bytes with the counter.
+func (t *progressTracker) hashing(counter *byteCounter) {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	t.p.Phase = ScanPhaseHashing
+	t.counter = counter
+	t.mut.Unlock()
+}
+
+// hashingFile records that hashing of the named file started.
+func (t *progressTracker) hashingFile(name string) {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	t.p.CurrentPath = name
+	t.mut.Unlock()
+}
+
+// hashedFile records that a file was hashed, or failed to.
+func (t *progressTracker) hashedFile() {
+	if t == nil {
+		return
+	}
+	t.mut.Lock()
+	t.p.FilesHashed++
+	t.mut.Unlock()
+}
+
+func (t *progressTracker) progress() Progress {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	p := t.p
+	if t.counter != nil {
+		p.BytesHashed = t.counter.Total()
+		p.Rate = t.counter.Rate()
+		if left := p.BytesTotal - p.BytesHashed; left > 0 && p.Rate > 0 {
+			p.ETA = time.Duration(float64(left) / p.Rate * float64(time.Second))
+		}
+	}
+	return p
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// The most bytes read at once without waiting, which must fit an int.
+const maxIOThrottleBurst = 1 << 30
+
+// An ioThrottle limits the rate at which the hashers of a scan read files
+// in total, and pauses each hasher after every file. A nil ioThrottle
+// doesn't throttle.
+type ioThrottle struct {
+	limiter   *rate.Limiter // nil if the rate is unlimited
+	fileSleep time.Duration
+}
+
+// newIOThrottle returns the throttle for a rate in bytes per second and a
+// pause per file, either of which can be zero or less to not limit, or nil
+// if neither limits.
+func newIOThrottle(maxRate int64, fileSleep time.Duration) *ioThrottle {
+	if maxRate <= 0 && fileSleep <= 0 {
+		return nil
+	}
+	t := &ioThrottle{fileSleep: fileSleep}
+	if maxRate > 0 {
+		// Up to a second's worth of reading at once.
+		burst := maxRate
+		if burst > maxIOThrottleBurst {
+			burst = maxIOThrottleBurst
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(maxRate), int(burst))
+	}
+	return t
+}
+
+// reader returns r, limited to the rate of the throttle.
+func (t *ioThrottle) reader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil || t.limiter == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, reader: r, limiter: t.limiter}
+}
+
+// sleep pauses after a file, returning early when the context is done.
+func (t *ioThrottle) sleep(ctx context.Context) {
+	if t == nil || t.fileSleep <= 0 {
+		return
+	}
+	timer := time.NewTimer(t.fileSleep)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *throttledReader) Read(buf []byte) (int, error) {
+	n, err := r.reader.Read(buf)
+	// No call to WaitN can be larger than the burst size, so wait for
+	// larger reads in several steps.
+	for tokens := n; tokens > 0; {
+		wait := tokens
+		if burst := r.limiter.Burst(); wait > burst {
+			wait = burst
+		}
+		if werr := r.limiter.WaitN(r.ctx, wait); werr != nil {
+			return n, werr
+		}
+		tokens -= wait
+	}
+	return n, err
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestIOThrottleRate(t *testing.T) {
+	if newIOThrottle(0, 0) != nil {
+		t.Error("throttle without limits")
+	}
+
+	// The first second's worth is read right away, the rest at the rate.
+	const rate = 100000
+	throttle := newIOThrottle(rate, 0)
+	r := throttle.reader(context.Background(), bytes.NewReader(make([]byte, rate*3/2)))
+
+	t0 := time.Now()
+	n, err := io.Copy(ioutil.Discard, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != rate*3/2 {
+		t.Errorf("read %d bytes, expected %d", n, rate*3/2)
+	}
+	if d := time.Since(t0); d < 400*time.Millisecond {
+		t.Errorf("reading took %v, expected at least half a second", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = throttle.reader(ctx, bytes.NewReader(make([]byte, rate)))
+	if _, err := io.Copy(ioutil.Discard, r); err == nil {
+		t.Error("no error reading with a cancelled context")
+	}
+}
+
+func TestIOThrottleSleep(t *testing.T) {
+	throttle := newIOThrottle(0, time.Hour)
+	if r := bytes.NewReader(nil); throttle.reader(context.Background(), r) != r {
+		t.Error("reader throttled without a rate")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	t0 := time.Now()
+	throttle.sleep(ctx)
+	if d := time.Since(t0); d < 50*time.Millisecond || d > 10*time.Second {
+		t.Errorf("sleep took %v, expected until the context is done", d)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/osutil"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"golang.org/x/text/unicode/norm"
 )
@@ -42,6 +43,8 @@ type Config struct {
 	// detected. Scanned files will get zero permission bits and the
 	// NoPermissionBits flag set.
 	IgnorePerms bool
+	// Changes to only these permission bits are not detected.
+	IgnorePermBits uint32
 	// When AutoNormalize is set, file names that are in UTF8 but incorrect
 	// normalization form will be corrected.
 	AutoNormalize bool
@@ -52,10 +55,26 @@ type Config struct {
 	// Optional progress tick interval which defines how often FolderScanProgress
 	// events are emitted. Negative number means disabled.
 	ProgressTickIntervalS int
+	// If ProgressFn is not nil, it is called with the progress of the scan
+	// at every progress tick.
+	ProgressFn func(Progress)
 	// Whether to use large blocks for large files or the old standard of 128KiB for everything.
 	UseLargeBlocks bool
 	// Local flags to set on scanned files
 	LocalFlags uint32
+	// If IdleIOPriority is true, the disk is accessed with idle I/O
+	// priority on platforms that support it.
+	IdleIOPriority bool
+	// If MaxReadRate is positive, the hashers read at most this many bytes
+	// per second in total.
+	MaxReadRate int64
+	// If FileSleep is positive, each hasher pauses this long after every
+	// file.
+	FileSleep time.Duration
+	// If Rehash is true, regular files are hashed even if they seem
+	// unchanged compared with the current file, so that their contents can
+	// be verified.
+	Rehash bool
 }
 
 type CurrentFiler interface {
@@ -70,7 +89,7 @@ type ScanResult struct {
 }
 
 func Walk(ctx context.Context, cfg Config) chan ScanResult {
-	w := walker{cfg}
+	w := walker{Config: cfg}
 
 	if w.CurrentFiler == nil {
 		w.CurrentFiler = noCurrentFiler{}
@@ -93,6 +112,7 @@ var (
 
 type walker struct {
 	Config
+	progress *progressTracker // nil unless progress is reported
 }
 
 // Walk returns the list of files found in the local folder by scanning the
@@ -103,9 +123,18 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 	toHashChan := make(chan protocol.FileInfo)
 	finishedChan := make(chan ScanResult)
 
+	if w.ProgressTickIntervalS >= 0 {
+		w.progress = newProgressTracker(w.Folder)
+	}
+	throttle := newIOThrottle(w.MaxReadRate, w.FileSleep)
+
 	// A routine which walks the filesystem tree, and sends files which have
 	// been modified to the counter routine.
 	go func() {
+		if w.IdleIOPriority {
+			restore := osutil.SetIdleIOPriority()
+			defer restore()
+		}
 		hashFiles := w.walkAndHashFiles(ctx, toHashChan, finishedChan)
 		if len(w.Subs) == 0 {
 			w.Filesystem.Walk(".", hashFiles)
@@ -120,7 +149,7 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 	// We're not required to emit scan progress events, just kick off hashers,
 	// and feed inputs directly from the walker.
 	if w.ProgressTickIntervalS < 0 {
-		newParallelHasher(ctx, w.Filesystem, w.Hashers, finishedChan, toHashChan, nil, nil)
+		newParallelHasher(ctx, w.Filesystem, w.Hashers, finishedChan, toHashChan, nil, nil, nil, w.IdleIOPriority, throttle)
 		return finishedChan
 	}
 
@@ -137,21 +166,31 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 	// start a routine which periodically emits FolderScanProgress events,
 	// until a stop signal is sent by the parallel hasher.
 	// Parallel hasher is stopped by this routine when we close the channel over
-	// which it receives the files we ask it to hash.
+	// which it receives the files we ask it to hash. While walking, the
+	// events tell how far the walk got.
 	go func() {
 		var filesToHash []protocol.FileInfo
-		var total int64 = 1
 
-		for file := range toHashChan {
-			filesToHash = append(filesToHash, file)
-			total += file.Size
+	walking:
+		for {
+			select {
+			case file, ok := <-toHashChan:
+				if !ok {
+					break walking
+				}
+				filesToHash = append(filesToHash, file)
+				w.progress.queued(file.Size)
+			case <-ticker.C:
+				w.reportProgress()
+			}
 		}
 
 		realToHashChan := make(chan protocol.FileInfo)
 		done := make(chan struct{})
 		progress := newByteCounter()
+		w.progress.hashing(progress)
 
-		newParallelHasher(ctx, w.Filesystem, w.Hashers, finishedChan, realToHashChan, progress, done)
+		newParallelHasher(ctx, w.Filesystem, w.Hashers, finishedChan, realToHashChan, progress, w.progress, done, w.IdleIOPriority, throttle)
 
 		// A routine which actually emits the FolderScanProgress events
 		// every w.ProgressTicker ticks, until the hasher routines terminate.
@@ -165,15 +204,7 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 					ticker.Stop()
 					return
 				case <-ticker.C:
-					current := progress.Total()
-					rate := progress.Rate()
-					l.Debugf("Walk %s %s current progress %d/%d at %.01f MiB/s (%d%%)", w.Folder, w.Subs, current, total, rate/1024/1024, current*100/total)
-					events.Default.Log(events.FolderScanProgress, map[string]interface{}{
-						"folder":  w.Folder,
-						"current": current,
-						"total":   total,
-						"rate":    rate, // bytes per second
-					})
+					w.reportProgress()
 				case <-ctx.Done():
 					ticker.Stop()
 					return
@@ -222,7 +253,7 @@ func (w *walker) walkAndHashFiles(ctx context.Context, toHashChan chan<- protoco
 
 		if fs.IsTemporary(path) {
 			l.Debugln("temporary:", path, "err:", err)
-			if err == nil && info.IsRegular() && info.ModTime().Add(w.TempLifetime).Before(now) {
+			if err == nil && info.IsRegular() && !fs.IsPartial(path) && info.ModTime().Add(w.TempLifetime).Before(now) {
 				w.Filesystem.Remove(path)
 				l.Debugln("removing temporary:", path, info.ModTime())
 			}
@@ -234,7 +265,7 @@ func (w *walker) walkAndHashFiles(ctx context.Context, toHashChan chan<- protoco
 			return skip
 		}
 
-		if w.Matcher.Match(path).IsIgnored() {
+		if w.ignored(path, info, err) {
 			l.Debugln("ignored (patterns):", path)
 			// Only descend if matcher says so and the current file is not a symlink.
 			if err != nil || w.Matcher.SkipIgnoredDirs() || info.IsSymlink() {
@@ -287,6 +318,38 @@ func (w *walker) walkAndHashFiles(ctx context.Context, toHashChan chan<- protoco
 	}
 }
 
+// reportProgress emits a FolderScanProgress event with the current progress
+// and passes it to the ProgressFn.
+func (w *walker) reportProgress() {
+	p := w.progress.progress()
+	total := p.BytesTotal + 1 // never zero, as consumers divide by it
+	l.Debugf("Walk %s %s %s %d files, current progress %d/%d at %.01f MiB/s (%d%%)", w.Folder, w.Subs, p.Phase, p.FilesWalked, p.BytesHashed, total, p.Rate/1024/1024, p.BytesHashed*100/total)
+	events.Default.Log(events.FolderScanProgress, map[string]interface{}{
+		"folder":      w.Folder,
+		"current":     p.BytesHashed,
+		"total":       total,
+		"rate":        p.Rate, // bytes per second
+		"phase":       p.Phase,
+		"currentPath": p.CurrentPath,
+		"filesWalked": p.FilesWalked,
+		"filesHashed": p.FilesHashed,
+		"filesTotal":  p.FilesTotal,
+		"etaS":        p.ETA.Seconds(),
+	})
+	if w.ProgressFn != nil {
+		w.ProgressFn(p)
+	}
+}
+
+// ignored returns whether the item is ignored by the patterns, taking the
+// size and age of files into account.
+func (w *walker) ignored(path string, info fs.FileInfo, err error) bool {
+	if err == nil && info.IsRegular() {
+		return w.Matcher.MatchStat(path, info.Size(), info.ModTime()).IsIgnored()
+	}
+	return w.Matcher.Match(path).IsIgnored()
+}
+
 func (w *walker) handleItem(ctx context.Context, path string, toHashChan chan<- protocol.FileInfo, finishedChan chan<- ScanResult, skip error) error {
 	info, err := w.Filesystem.Lstat(path)
 	// An error here would be weird as we've already gotten to this point, but act on it nonetheless
@@ -294,6 +357,7 @@ func (w *walker) handleItem(ctx context.Context, path string, toHashChan chan<-
 		w.handleError(ctx, "scan", path, err, finishedChan)
 		return skip
 	}
+	w.progress.walked(path)
 
 	oldPath := path
 	path, err = w.normalizePath(path, info)
@@ -352,18 +416,25 @@ func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileIn
 	f.RawBlockSize = int32(blockSize)
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, w.IgnorePerms, true, w.LocalFlags) {
+		unchanged := curFile.IsEquivalentPermBits(f, w.ignoredPermBits(), true, w.LocalFlags)
+		switch {
+		case unchanged && !w.Rehash:
 			return nil
+		case unchanged:
+			// Hashed with the same block size, to compare the blocks.
+			f.RawBlockSize = int32(curFile.BlockSize())
+			l.Debugln("rehash:", curFile)
+		default:
+			if curFile.ShouldConflict() {
+				// The old file was invalid for whatever reason and probably not
+				// up to date with what was out there in the cluster. Drop all
+				// others from the version vector to indicate that we haven't
+				// taken their version into account, and possibly cause a
+				// conflict.
+				f.Version = f.Version.DropOthers(w.ShortID)
+			}
+			l.Debugln("rescan:", curFile, info.ModTime().Unix(), info.Mode()&fs.ModePerm)
 		}
-		if curFile.ShouldConflict() {
-			// The old file was invalid for whatever reason and probably not
-			// up to date with what was out there in the cluster. Drop all
-			// others from the version vector to indicate that we haven't
-			// taken their version into account, and possibly cause a
-			// conflict.
-			f.Version = f.Version.DropOthers(w.ShortID)
-		}
-		l.Debugln("rescan:", curFile, info.ModTime().Unix(), info.Mode()&fs.ModePerm)
 	}
 
 	l.Debugln("to hash:", relPath, f)
@@ -385,7 +456,7 @@ func (w *walker) walkDir(ctx context.Context, relPath string, info fs.FileInfo,
 	f.NoPermissions = w.IgnorePerms
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, w.IgnorePerms, true, w.LocalFlags) {
+		if curFile.IsEquivalentPermBits(f, w.ignoredPermBits(), true, w.LocalFlags) {
 			return nil
 		}
 		if curFile.ShouldConflict() {
@@ -409,6 +480,13 @@ func (w *walker) walkDir(ctx context.Context, relPath string, info fs.FileInfo,
 	return nil
 }
 
+func (w *walker) ignoredPermBits() uint32 {
+	if w.IgnorePerms {
+		return 0777
+	}
+	return w.IgnorePermBits
+}
+
 // walkSymlink returns nil or an error, if the error is of the nature that
 // it should stop the entire walk.
 func (w *walker) walkSymlink(ctx context.Context, relPath string, info fs.FileInfo, finishedChan chan<- ScanResult) error {
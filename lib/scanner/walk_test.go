@@ -20,6 +20,7 @@ import (
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/d4l3k/messagediff"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -465,6 +466,41 @@ func TestWalkReceiveOnly(t *testing.T) {
 	}
 }
 
+func TestWalkRehash(t *testing.T) {
+	sf := fs.NewWalkFilesystem(&singleFileFS{
+		name:     "testfile.dat",
+		filesize: 1024,
+	})
+
+	current := make(fakeCurrentFiler)
+	files := walkDir(sf, ".", current, nil, 0)
+	if len(files) != 1 {
+		t.Fatal("Should have scanned one file")
+	}
+	cur := files[0]
+	current[cur.Name] = cur
+
+	fchan := Walk(context.TODO(), Config{
+		Filesystem:   sf,
+		Hashers:      2,
+		CurrentFiler: current,
+		Rehash:       true,
+	})
+	files = nil
+	for f := range fchan {
+		if f.Err != nil {
+			t.Fatal(f.Err)
+		}
+		files = append(files, f.File)
+	}
+	if len(files) != 1 {
+		t.Fatal("Should have rehashed the unchanged file")
+	}
+	if !protocol.BlocksEqual(files[0].Blocks, cur.Blocks) {
+		t.Error("Rehashed blocks differ")
+	}
+}
+
 func walkDir(fs fs.Filesystem, dir string, cfiler CurrentFiler, matcher *ignore.Matcher, localFlags uint32) []protocol.FileInfo {
 	fchan := Walk(context.TODO(), Config{
 		Filesystem:     fs,
@@ -794,3 +830,68 @@ func (fcf fakeCurrentFiler) CurrentFile(name string) (protocol.FileInfo, bool) {
 	f, ok := fcf[name]
 	return f, ok
 }
+
+func TestWalkProgress(t *testing.T) {
+	ignores := ignore.New(testFs)
+	if err := ignores.Load(".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &walker{Config: Config{
+		Folder:       "default",
+		Filesystem:   testFs,
+		Matcher:      ignores,
+		CurrentFiler: noCurrentFiler{},
+		Hashers:      2,
+	}}
+	var hashed, bytes int64
+	for res := range w.walk(context.TODO()) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if !res.File.IsDirectory() && !res.File.IsSymlink() {
+			hashed++
+			bytes += res.File.Size
+		}
+	}
+
+	p := w.progress.progress()
+	if p.Folder != "default" || p.Phase != ScanPhaseHashing {
+		t.Errorf("unexpected folder %q or phase %q", p.Folder, p.Phase)
+	}
+	if p.FilesWalked < p.FilesTotal || p.FilesTotal == 0 {
+		t.Errorf("walked %d files, for %d to hash", p.FilesWalked, p.FilesTotal)
+	}
+	if int64(p.FilesHashed) != hashed || int64(p.FilesTotal) != hashed {
+		t.Errorf("hashed %d of %d files, expected %d", p.FilesHashed, p.FilesTotal, hashed)
+	}
+	if p.BytesHashed != bytes || p.BytesTotal != bytes {
+		t.Errorf("hashed %d of %d bytes, expected %d", p.BytesHashed, p.BytesTotal, bytes)
+	}
+	if p.CurrentPath == "" {
+		t.Error("no current path")
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	tr := newProgressTracker("default")
+	tr.queued(1000)
+	tr.queued(1500)
+	if p := tr.progress(); p.Phase != ScanPhaseWalking || p.ETA != 0 {
+		t.Errorf("unexpected phase %q or ETA %v while walking", p.Phase, p.ETA)
+	}
+
+	counter := newByteCounter()
+	defer counter.Close()
+	tr.hashing(counter)
+	counter.Update(500)
+	counter.Tick() // 100 bytes per second, over the five second interval
+
+	p := tr.progress()
+	if p.Rate != 100 || p.BytesHashed != 500 || p.BytesTotal != 2500 {
+		t.Fatalf("unexpected rate %v or %d of %d bytes", p.Rate, p.BytesHashed, p.BytesTotal)
+	}
+	if p.ETA != 20*time.Second {
+		t.Errorf("ETA %v, expected 20s", p.ETA)
+	}
+}
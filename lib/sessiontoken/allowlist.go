@@ -0,0 +1,124 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package sessiontoken
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+const allowListReloadInterval = time.Minute
+
+// An AllowList is the set of devices permitted to use a private server, read
+// from a file with one device ID per line. Empty lines and lines starting
+// with "#" are ignored. The file is reloaded periodically, and the removed
+// callback is called for devices no longer in it so that their tokens and
+// connections can be dropped.
+type AllowList struct {
+	path    string
+	removed func(protocol.DeviceID)
+
+	mut     sync.RWMutex
+	devices map[protocol.DeviceID]struct{}
+	stop    chan struct{}
+}
+
+func NewAllowList(path string, removed func(protocol.DeviceID)) (*AllowList, error) {
+	a := &AllowList{
+		path:    path,
+		removed: removed,
+		mut:     sync.NewRWMutex(),
+		devices: make(map[protocol.DeviceID]struct{}),
+		stop:    make(chan struct{}),
+	}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Allowed returns whether the device is in the list.
+func (a *AllowList) Allowed(device protocol.DeviceID) bool {
+	a.mut.RLock()
+	_, ok := a.devices[device]
+	a.mut.RUnlock()
+	return ok
+}
+
+// Reload reads the file again. On error the current list stays in effect.
+func (a *AllowList) Reload() error {
+	devices, err := readAllowList(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.mut.Lock()
+	var removed []protocol.DeviceID
+	for dev := range a.devices {
+		if _, ok := devices[dev]; !ok {
+			removed = append(removed, dev)
+		}
+	}
+	a.devices = devices
+	a.mut.Unlock()
+
+	if a.removed != nil {
+		for _, dev := range removed {
+			a.removed(dev)
+		}
+	}
+	return nil
+}
+
+// Serve reloads the file periodically until stopped.
+func (a *AllowList) Serve() {
+	ticker := time.NewTicker(allowListReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.Reload(); err != nil {
+				l.Warnln("Reloading allowed devices:", err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *AllowList) Stop() {
+	close(a.stop)
+}
+
+func readAllowList(path string) (map[protocol.DeviceID]struct{}, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	devices := make(map[protocol.DeviceID]struct{})
+	scanner := bufio.NewScanner(fd)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dev, err := protocol.DeviceIDFromString(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		devices[dev] = struct{}{}
+	}
+	return devices, scanner.Err()
+}
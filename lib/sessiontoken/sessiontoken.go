@@ -0,0 +1,177 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package sessiontoken implements short-lived tokens for servers that
+// authenticate a device by its certificate once and then accept the token
+// in place of the certificate, such as private discovery servers.
+package sessiontoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+var (
+	ErrMalformed = errors.New("malformed token")
+	ErrInvalid   = errors.New("invalid token")
+	ErrExpired   = errors.New("token expired")
+	ErrRevoked   = errors.New("token revoked")
+)
+
+const (
+	keyLen     = 32
+	payloadLen = 4 + protocol.DeviceIDLength + 8 + 8 // key ID, device ID, issued, expires
+)
+
+type signingKey struct {
+	id  uint32
+	key []byte
+}
+
+// An Issuer issues and verifies tokens. The signing key is rotated every
+// token lifetime; the previous key is kept so that tokens remain valid
+// until they expire.
+type Issuer struct {
+	lifetime time.Duration
+
+	mut      sync.Mutex
+	current  signingKey
+	previous signingKey
+	revoked  map[protocol.DeviceID]time.Time
+	stop     chan struct{}
+}
+
+func NewIssuer(lifetime time.Duration) *Issuer {
+	i := &Issuer{
+		lifetime: lifetime,
+		mut:      sync.NewMutex(),
+		revoked:  make(map[protocol.DeviceID]time.Time),
+		stop:     make(chan struct{}),
+	}
+	i.Rotate()
+	return i
+}
+
+// Lifetime returns how long issued tokens are valid.
+func (i *Issuer) Lifetime() time.Duration {
+	return i.lifetime
+}
+
+// Issue returns a new token for the device and when it expires.
+func (i *Issuer) Issue(device protocol.DeviceID) (string, time.Time) {
+	now := time.Now()
+	expires := now.Add(i.lifetime)
+
+	i.mut.Lock()
+	key := i.current
+	i.mut.Unlock()
+
+	payload := make([]byte, payloadLen)
+	binary.BigEndian.PutUint32(payload, key.id)
+	copy(payload[4:], device[:])
+	binary.BigEndian.PutUint64(payload[4+protocol.DeviceIDLength:], uint64(now.UnixNano()))
+	binary.BigEndian.PutUint64(payload[4+protocol.DeviceIDLength+8:], uint64(expires.UnixNano()))
+
+	token := append(payload, sign(key.key, payload)...)
+	return base64.RawURLEncoding.EncodeToString(token), expires
+}
+
+// Verify returns the device the token was issued to, if it's valid.
+func (i *Issuer) Verify(token string) (protocol.DeviceID, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(bs) != payloadLen+sha256.Size {
+		return protocol.EmptyDeviceID, ErrMalformed
+	}
+	payload, mac := bs[:payloadLen], bs[payloadLen:]
+
+	keyID := binary.BigEndian.Uint32(payload)
+	device := protocol.DeviceIDFromBytes(payload[4 : 4+protocol.DeviceIDLength])
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(payload[4+protocol.DeviceIDLength:])))
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(payload[4+protocol.DeviceIDLength+8:])))
+
+	i.mut.Lock()
+	var key []byte
+	switch keyID {
+	case i.current.id:
+		key = i.current.key
+	case i.previous.id:
+		key = i.previous.key
+	}
+	revoked, isRevoked := i.revoked[device]
+	i.mut.Unlock()
+
+	if key == nil || !hmac.Equal(mac, sign(key, payload)) {
+		return protocol.EmptyDeviceID, ErrInvalid
+	}
+	if time.Now().After(expires) {
+		return protocol.EmptyDeviceID, ErrExpired
+	}
+	if isRevoked && !issued.After(revoked) {
+		return protocol.EmptyDeviceID, ErrRevoked
+	}
+	return device, nil
+}
+
+// Revoke invalidates all tokens issued to the device so far.
+func (i *Issuer) Revoke(device protocol.DeviceID) {
+	i.mut.Lock()
+	i.revoked[device] = time.Now()
+	i.mut.Unlock()
+}
+
+// Rotate replaces the signing key. Tokens signed with the key before the
+// current one are no longer accepted.
+func (i *Issuer) Rotate() {
+	key := make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		panic("sessiontoken: reading random key: " + err.Error())
+	}
+
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	i.previous = i.current
+	i.current = signingKey{id: i.previous.id + 1, key: key}
+
+	// Revocations older than a lifetime cover only expired tokens.
+	for dev, t := range i.revoked {
+		if time.Since(t) > i.lifetime {
+			delete(i.revoked, dev)
+		}
+	}
+}
+
+// Serve rotates the signing key every token lifetime until stopped.
+func (i *Issuer) Serve() {
+	ticker := time.NewTicker(i.lifetime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			i.Rotate()
+		case <-i.stop:
+			return
+		}
+	}
+}
+
+func (i *Issuer) Stop() {
+	close(i.stop)
+}
+
+func sign(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
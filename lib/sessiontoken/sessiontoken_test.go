@@ -0,0 +1,122 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package sessiontoken
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var (
+	device1, _ = protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+	device2, _ = protocol.DeviceIDFromString("GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY")
+)
+
+func TestIssueVerify(t *testing.T) {
+	iss := NewIssuer(time.Hour)
+
+	token, expires := iss.Issue(device1)
+	if time.Until(expires) > time.Hour || time.Until(expires) < time.Hour-time.Minute {
+		t.Error("Unexpected expiry", expires)
+	}
+	if dev, err := iss.Verify(token); err != nil || dev != device1 {
+		t.Fatal("Expected valid token for device1, got", dev, err)
+	}
+
+	if _, err := iss.Verify("garbage"); err != ErrMalformed {
+		t.Error("Expected malformed token, got", err)
+	}
+	other := NewIssuer(time.Hour)
+	if _, err := other.Verify(token); err != ErrInvalid {
+		t.Error("Expected token from another issuer to be invalid, got", err)
+	}
+
+	short := NewIssuer(-time.Second)
+	expired, _ := short.Issue(device1)
+	if _, err := short.Verify(expired); err != ErrExpired {
+		t.Error("Expected expired token, got", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	iss := NewIssuer(time.Hour)
+	token, _ := iss.Issue(device1)
+
+	iss.Rotate()
+	if _, err := iss.Verify(token); err != nil {
+		t.Error("Expected token to survive one rotation, got", err)
+	}
+	iss.Rotate()
+	if _, err := iss.Verify(token); err != ErrInvalid {
+		t.Error("Expected token to be invalid after two rotations, got", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	iss := NewIssuer(time.Hour)
+	token1, _ := iss.Issue(device1)
+	token2, _ := iss.Issue(device2)
+
+	iss.Revoke(device1)
+	if _, err := iss.Verify(token1); err != ErrRevoked {
+		t.Error("Expected revoked token, got", err)
+	}
+	if _, err := iss.Verify(token2); err != nil {
+		t.Error("Expected other device's token to remain valid, got", err)
+	}
+
+	time.Sleep(time.Millisecond)
+	token1, _ = iss.Issue(device1)
+	if _, err := iss.Verify(token1); err != nil {
+		t.Error("Expected token issued after revocation to be valid, got", err)
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	fd, err := ioutil.TempFile("", "allowlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fd.Name())
+	fd.WriteString("# allowed devices\n" + device1.String() + "\n\n" + device2.String() + "\n")
+	fd.Close()
+
+	var removed []protocol.DeviceID
+	a, err := NewAllowList(fd.Name(), func(dev protocol.DeviceID) {
+		removed = append(removed, dev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Allowed(device1) || !a.Allowed(device2) || a.Allowed(protocol.LocalDeviceID) {
+		t.Fatal("Unexpected allow list contents")
+	}
+
+	ioutil.WriteFile(fd.Name(), []byte(device2.String()+"\n"), 0644)
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Allowed(device1) || !a.Allowed(device2) {
+		t.Error("Expected device1 to be removed")
+	}
+	if len(removed) != 1 || removed[0] != device1 {
+		t.Error("Expected removal callback for device1, got", removed)
+	}
+
+	// A broken file leaves the list as it was
+	ioutil.WriteFile(fd.Name(), []byte("not a device id\n"), 0644)
+	if err := a.Reload(); err == nil {
+		t.Error("Expected error for invalid device ID")
+	}
+	if !a.Allowed(device2) {
+		t.Error("Expected device2 to remain allowed")
+	}
+}
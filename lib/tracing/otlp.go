@@ -0,0 +1,249 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	otlpQueueSize     = 2048 // spans waiting to be exported before we start dropping
+	otlpBatchSize     = 512
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+)
+
+// An otlpExporter posts spans in batches to an OpenTelemetry collector, or
+// anything else that accepts OTLP over HTTP with JSON encoding.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+	queue    chan SpanData
+	stop     chan struct{}
+	done     chan struct{}
+	failing  bool
+}
+
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return &otlpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: otlpTimeout},
+		queue:    make(chan SpanData, otlpQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Export queues the span. Spans are dropped rather than holding up the
+// work being traced if the collector can't keep up.
+func (e *otlpExporter) Export(s SpanData) {
+	select {
+	case e.queue <- s:
+	default:
+		l.Debugln("OTLP queue full, dropping span", s.Name)
+	}
+}
+
+// run posts the queued spans until stopped, then the ones left.
+func (e *otlpExporter) run() {
+	defer close(e.done)
+
+	t := time.NewTicker(otlpFlushInterval)
+	defer t.Stop()
+
+	batch := make([]SpanData, 0, otlpBatchSize)
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) < otlpBatchSize {
+				continue
+			}
+		case <-t.C:
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+				default:
+					e.post(batch)
+					return
+				}
+			}
+		}
+		e.post(batch)
+		batch = batch[:0]
+	}
+}
+
+// close stops the exporter, after posting the spans it has.
+func (e *otlpExporter) close() {
+	close(e.stop)
+	<-e.done
+}
+
+func (e *otlpExporter) post(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest(batch))
+	if err != nil {
+		l.Debugln("Marshalling spans:", err)
+		return
+	}
+
+	err = func() error {
+		resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected response status %s", resp.Status)
+		}
+		return nil
+	}()
+
+	// Only log changes between working and failing, not every failure.
+	if err != nil && !e.failing {
+		l.Infof("Exporting spans to %s is failing: %v", e.endpoint, err)
+	} else if err == nil && e.failing {
+		l.Infof("Exporting spans to %s is working again", e.endpoint)
+	}
+	e.failing = err != nil
+}
+
+// The OTLP JSON encoding of an ExportTraceServiceRequest, as far as we use
+// it. IDs are hex encoded, 64 bit integers are strings.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    string   `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+func otlpRequest(batch []SpanData) otlpTraces {
+	service := "syncthing"
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		keys := make([]string, 0, len(s.Attributes))
+		for key := range s.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			spans[i].Attributes = append(spans[i].Attributes, otlpKeyValue{key, otlpValue(s.Attributes[key])})
+		}
+		if s.Error != "" {
+			spans[i].Status = &otlpStatus{Code: otlpStatusCodeError, Message: s.Error}
+		}
+	}
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{{"service.name", otlpAnyValue{StringValue: &service}}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/syncthing/syncthing/lib/tracing"},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+func otlpValue(val interface{}) otlpAnyValue {
+	switch v := val.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		return otlpAnyValue{IntValue: strconv.FormatInt(int64(v), 10)}
+	case int32:
+		return otlpAnyValue{IntValue: strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return otlpAnyValue{IntValue: strconv.FormatInt(v, 10)}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	default:
+		str := fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &str}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tracing
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+// Service exports spans to the OTLP endpoint in the options, if there is
+// one, following changes to it. Without an endpoint it leaves the exporter
+// alone, which may then be one set up for debugging.
+type Service struct {
+	cfg     config.Wrapper
+	changed chan struct{}
+	stop    chan struct{}
+}
+
+func New(cfg config.Wrapper) *Service {
+	s := &Service{
+		cfg:     cfg,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	cfg.Subscribe(s)
+	return s
+}
+
+func (s *Service) Serve() {
+	exp := startOTLP(s.cfg.Options().TracingEndpoint)
+	defer func() {
+		stopOTLP(exp)
+	}()
+
+	for {
+		select {
+		case <-s.changed:
+			stopOTLP(exp)
+			exp = startOTLP(s.cfg.Options().TracingEndpoint)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	close(s.stop)
+}
+
+func (s *Service) VerifyConfiguration(from, to config.Configuration) error {
+	return nil
+}
+
+func (s *Service) CommitConfiguration(from, to config.Configuration) bool {
+	if from.Options.TracingEndpoint != to.Options.TracingEndpoint {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (*Service) String() string {
+	return "tracing.Service"
+}
+
+func startOTLP(endpoint string) *otlpExporter {
+	if endpoint == "" {
+		return nil
+	}
+	exp, err := newOTLPExporter(endpoint)
+	if err != nil {
+		l.Infof("Not exporting spans to %s: %v", endpoint, err)
+		return nil
+	}
+	l.Debugln("Exporting spans to", endpoint)
+	go exp.run()
+	SetExporter(exp)
+	return exp
+}
+
+func stopOTLP(exp *otlpExporter) {
+	if exp == nil {
+		return
+	}
+	SetExporter(nil)
+	exp.close()
+}
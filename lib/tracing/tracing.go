@@ -0,0 +1,145 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package tracing records timed spans of work (scanning, hashing, pulling,
+// network requests, database writes) for performance analysis. Spans follow
+// the OpenTelemetry data model - trace and span IDs, parent relationships,
+// start and end times and attributes - and are handed to an Exporter, such
+// as the OTLP exporter started by the Service for the configured endpoint.
+// Tracing has negligible overhead while no exporter is set.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+// SpanData is a finished span as given to an Exporter.
+type SpanData struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string // empty for a root span
+	Name         string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]interface{}
+	Error        string
+}
+
+// An Exporter receives finished spans. It must be safe for concurrent use.
+type Exporter interface {
+	Export(SpanData)
+}
+
+type exporterHolder struct {
+	Exporter
+}
+
+var exporter atomic.Value // exporterHolder
+
+func init() {
+	exporter.Store(exporterHolder{})
+}
+
+// SetExporter sets the exporter that will receive all spans ended from now
+// on. Passing nil disables tracing.
+func SetExporter(e Exporter) {
+	exporter.Store(exporterHolder{e})
+}
+
+func currentExporter() Exporter {
+	return exporter.Load().(exporterHolder).Exporter
+}
+
+// A Span is a timed unit of work. A nil *Span is valid and does nothing, so
+// callers do not need to check whether tracing is enabled.
+type Span struct {
+	exp  Exporter
+	data SpanData
+}
+
+type spanKey struct{}
+
+// Start begins a new span, as a child of the span in ctx if there is one.
+// The returned context carries the new span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	exp := currentExporter()
+	if exp == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		exp: exp,
+		data: SpanData{
+			SpanID: randomID(8),
+			Name:   name,
+			Start:  time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentSpanID = parent.data.SpanID
+	} else {
+		s.data.TraceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttribute records a key/value pair on the span.
+func (s *Span) SetAttribute(key string, val interface{}) {
+	if s == nil {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = val
+}
+
+// SetError records that the operation failed, if err is non-nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.data.Error = err.Error()
+}
+
+// End finishes the span and hands it to the exporter.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.data.End = time.Now()
+	s.exp.Export(s.data)
+}
+
+func randomID(size int) string {
+	bs := make([]byte, size)
+	if _, err := rand.Read(bs); err != nil {
+		// Uniqueness is nice to have, but not critical
+		binary.BigEndian.PutUint64(bs, uint64(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(bs)
+}
+
+// NewLogExporter returns an Exporter that writes a line per span to the log.
+func NewLogExporter() Exporter {
+	return logExporter{}
+}
+
+type logExporter struct{}
+
+func (logExporter) Export(s SpanData) {
+	if s.Error != "" {
+		l.Infof("Span %s (trace %s) took %v: %v %s", s.Name, s.TraceID, s.End.Sub(s.Start), s.Attributes, s.Error)
+		return
+	}
+	l.Infof("Span %s (trace %s) took %v: %v", s.Name, s.TraceID, s.End.Sub(s.Start), s.Attributes)
+}
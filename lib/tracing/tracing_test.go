@@ -0,0 +1,112 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestDisabled(t *testing.T) {
+	SetExporter(nil)
+	ctx, span := Start(context.Background(), "test")
+	if span != nil {
+		t.Error("Expected nil span while tracing is disabled")
+	}
+	if ctx != context.Background() {
+		t.Error("Context should be untouched while tracing is disabled")
+	}
+	// Must not panic
+	span.SetAttribute("foo", "bar")
+	span.SetError(errors.New("fail"))
+	span.End()
+}
+
+func TestOTLPExporter(t *testing.T) {
+	reqs := make(chan otlpTraces, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpTraces
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Error("Unexpected content type", ct)
+		}
+		reqs <- req
+	}))
+	defer srv.Close()
+
+	exp, err := newOTLPExporter(srv.URL + "/v1/traces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go exp.run()
+	SetExporter(exp)
+	defer SetExporter(nil)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	child.SetAttribute("size", 42)
+	child.SetAttribute("folder", "default")
+	child.SetError(errors.New("fail"))
+	child.End()
+	parent.End()
+
+	// The spans left are posted when stopping
+	exp.close()
+	var req otlpTraces
+	select {
+	case req = <-reqs:
+	default:
+		t.Fatal("No spans posted")
+	}
+
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || *attrs[0].Value.StringValue != "syncthing" {
+		t.Error("Unexpected resource", attrs)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name != "child" || p.Name != "parent" {
+		t.Fatal("Unexpected span order", c.Name, p.Name)
+	}
+	if len(c.TraceID) != 32 || len(c.SpanID) != 16 || c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" {
+		t.Error("Child not linked to parent", c, p)
+	}
+	if len(c.Attributes) != 2 || c.Attributes[0].Key != "folder" || *c.Attributes[0].Value.StringValue != "default" || c.Attributes[1].Key != "size" || c.Attributes[1].Value.IntValue != "42" {
+		t.Error("Unexpected child attributes", c.Attributes)
+	}
+	if c.Status == nil || c.Status.Code != otlpStatusCodeError || c.Status.Message != "fail" || p.Status != nil {
+		t.Error("Unexpected status", c.Status, p.Status)
+	}
+	nanos := func(s string) int64 {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if nanos(c.EndTimeUnixNano) < nanos(c.StartTimeUnixNano) || nanos(p.EndTimeUnixNano) < nanos(c.EndTimeUnixNano) {
+		t.Error("Unexpected span times", c, p)
+	}
+}
+
+func TestOTLPEndpoint(t *testing.T) {
+	if _, err := newOTLPExporter("grpc://localhost:4317"); err == nil {
+		t.Error("Unsupported scheme accepted")
+	}
+}
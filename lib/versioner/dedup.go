@@ -0,0 +1,252 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/sha256"
+)
+
+func init() {
+	// Register the constructor for this type of versioner with the name "dedup"
+	Factories["dedup"] = NewDedup
+}
+
+// dedupObjectsDir is where the content of versions is kept, named by its
+// hash, in the versions directory.
+const dedupObjectsDir = ".stobjects"
+
+// Dedup is a versioner that keeps versions like the simple versioner, but
+// stores each distinct content only once: versions with the same content
+// are hard links to the same object. As a consequence such versions share
+// their modification time and permissions. On filesystems without hard
+// links versions are kept as plain copies.
+type Dedup struct {
+	*cleaner
+	folderFs   fs.Filesystem
+	versionsFs fs.Filesystem
+}
+
+func NewDedup(folderID string, folderFs fs.Filesystem, params map[string]string) Versioner {
+	retention := retentionFromParams(params)
+	if keep, err := strconv.Atoi(params["keep"]); err == nil {
+		retention.KeepLast = keep
+	} else if !retention.hasKeepRules() {
+		retention.KeepLast = 5 // A reasonable default
+	}
+
+	versionsFs := fsFromParams(folderFs, params)
+	s := &Dedup{
+		cleaner:    newCleaner(versionsFs, retention, true, params, time.Minute, time.Hour),
+		folderFs:   folderFs,
+		versionsFs: versionsFs,
+	}
+	s.skipDir = dedupObjectsDir
+	s.afterClean = s.collectObjects
+
+	l.Debugf("instantiated %#v", s)
+	return s
+}
+
+// Archive moves the named file away to a version archive. If this function
+// returns nil, the named file does not exist any more (has been archived).
+func (v *Dedup) Archive(filePath string) error {
+	archived := ""
+	err := archiveFile(v.folderFs, v.versionsFs, filePath, func(name, tag string) string {
+		archived = TagFilename(name, tag)
+		return archived
+	})
+	if err != nil {
+		return err
+	}
+
+	if archived != "" {
+		v.store(filepath.Join(filepath.Dir(osutil.NativeFilename(filePath)), archived))
+	}
+	v.cleanFile(filePath)
+	return nil
+}
+
+func (v *Dedup) GetVersions() (map[string][]FileVersion, error) {
+	return retrieveVersionsExcept(v.versionsFs, dedupObjectsDir)
+}
+
+func (v *Dedup) Restore(filePath string, versionTime time.Time) error {
+	// The restored file must not share its content with the archive, as it
+	// may be modified in place.
+	if name, err := findVersion(v.versionsFs, osutil.NativeFilename(filePath), versionTime); err == nil {
+		if err := v.unshare(name); err != nil {
+			return err
+		}
+	}
+
+	archived := ""
+	tagger := func(name, tag string) string {
+		archived = TagFilename(name, tag)
+		return archived
+	}
+	if err := restoreFile(v.versionsFs, v.folderFs, filePath, versionTime, tagger); err != nil {
+		return err
+	}
+
+	if archived != "" {
+		v.store(filepath.Join(filepath.Dir(osutil.NativeFilename(filePath)), archived))
+	}
+	return nil
+}
+
+func (v *Dedup) Open(filePath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(v.versionsFs, filePath, versionTime)
+}
+
+// store replaces the archived version with a link to the object with the
+// same content, or makes it the object if there is none yet. On failure
+// the version is left as it is.
+func (v *Dedup) store(path string) {
+	hash, err := v.hashFile(path)
+	if err != nil {
+		l.Infof("Versioner: deduplicating %q: %v", path, err)
+		return
+	}
+	object := filepath.Join(dedupObjectsDir, hash[:2], hash)
+
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	if _, err := v.versionsFs.Lstat(object); err == nil {
+		// Seen this content before
+		tempName := fs.TempName(path)
+		if err := v.versionsFs.Link(object, tempName); err != nil {
+			l.Debugf("Versioner: linking %q: %v", path, err)
+			return
+		}
+		if err := v.versionsFs.Rename(tempName, path); err != nil {
+			l.Debugf("Versioner: linking %q: %v", path, err)
+			v.versionsFs.Remove(tempName)
+		}
+		return
+	} else if !fs.IsNotExist(err) {
+		l.Infof("Versioner: deduplicating %q: %v", path, err)
+		return
+	}
+
+	if err := v.versionsFs.MkdirAll(filepath.Dir(object), 0755); err != nil {
+		l.Infof("Versioner: deduplicating %q: %v", path, err)
+		return
+	}
+	if err := v.versionsFs.Link(path, object); err != nil {
+		l.Debugf("Versioner: linking %q: %v", path, err)
+	}
+}
+
+// unshare replaces the version with a copy of its own.
+func (v *Dedup) unshare(path string) error {
+	info, err := v.versionsFs.Lstat(path)
+	if err != nil {
+		return err
+	}
+	tempName := fs.TempName(path)
+	if err := osutil.Copy(v.versionsFs, v.versionsFs, path, tempName); err != nil {
+		v.versionsFs.Remove(tempName)
+		return err
+	}
+	_ = v.versionsFs.Chmod(tempName, info.Mode()&fs.ModePerm)
+	_ = v.versionsFs.Chtimes(tempName, info.ModTime(), info.ModTime())
+	if err := v.versionsFs.Rename(tempName, path); err != nil {
+		v.versionsFs.Remove(tempName)
+		return err
+	}
+	return nil
+}
+
+func (v *Dedup) hashFile(path string) (string, error) {
+	fd, err := v.versionsFs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fd); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// collectObjects removes the objects no version links to any more. It is
+// called with the lock held, after the versions have been cleaned.
+func (v *Dedup) collectObjects() {
+	if _, err := v.versionsFs.Lstat(dedupObjectsDir); err != nil {
+		return
+	}
+
+	type object struct {
+		path string
+		info fs.FileInfo
+	}
+	objectsBySize := make(map[int64][]object)
+	dirTracker := make(emptyDirTracker)
+	err := v.versionsFs.Walk(dedupObjectsDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirTracker.addDir(path)
+			return nil
+		}
+		objectsBySize[info.Size()] = append(objectsBySize[info.Size()], object{path, info})
+		return nil
+	})
+	if err != nil {
+		l.Infoln("Versioner: walking objects:", err)
+		return
+	}
+
+	unreferenced := make(map[string]struct{})
+	for _, objects := range objectsBySize {
+		for _, o := range objects {
+			unreferenced[o.path] = struct{}{}
+		}
+	}
+	err = v.versionsFs.Walk(".", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dedupObjectsDir {
+			return fs.SkipDir
+		}
+		if !info.IsRegular() {
+			return nil
+		}
+		for _, o := range objectsBySize[info.Size()] {
+			if v.versionsFs.SameFile(o.info, info) {
+				delete(unreferenced, o.path)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		l.Infoln("Versioner: walking versions:", err)
+		return
+	}
+
+	for _, objects := range objectsBySize {
+		for _, o := range objects {
+			if _, ok := unreferenced[o.path]; !ok || !v.remove(o.path) {
+				dirTracker.addFile(o.path)
+			}
+		}
+	}
+	dirTracker.deleteEmptyDirs(v.versionsFs)
+}
@@ -0,0 +1,106 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestDedupVersioning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	folderFs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+	versionsFs := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Join(dir, ".stversions"))
+	v := NewDedup("", folderFs, map[string]string{"keep": "1"}).(*Dedup)
+
+	// Two files with the same content, and an older version of one of
+	// them with different content.
+	times := []time.Time{
+		time.Now().Add(-3 * time.Hour).Truncate(time.Second),
+		time.Now().Add(-2 * time.Hour).Truncate(time.Second),
+		time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+	for i, f := range []struct{ name, content string }{{"a", "old"}, {"a", "same"}, {"b", "same"}} {
+		writeFile(t, folderFs, f.name, f.content)
+		if err := folderFs.Chtimes(f.name, times[i], times[i]); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Archive(f.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || len(versions["a"]) != 1 || len(versions["b"]) != 1 {
+		t.Fatal("Unexpected versions", versions)
+	}
+
+	aInfo, err := versionsFs.Lstat(TagFilename("a", times[1].Format(TimeFormat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bInfo, err := versionsFs.Lstat(TagFilename("b", times[2].Format(TimeFormat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !versionsFs.SameFile(aInfo, bInfo) {
+		t.Error("Versions with the same content should be the same file")
+	}
+
+	// The object of the old version is collected, the shared one is not.
+	if err := v.clean(); err != nil {
+		t.Fatal(err)
+	}
+	names, err := versionsFs.DirNames(dedupObjectsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Error("Expected one object to remain, got", names)
+	}
+
+	// A restored file doesn't share its content with the archive.
+	if err := v.Restore("b", times[2]); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := folderFs.Lstat("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aInfo, err = versionsFs.Lstat(TagFilename("a", times[1].Format(TimeFormat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folderFs.SameFile(restored, aInfo) {
+		t.Error("Restored file should not be linked to the archive")
+	}
+	fd, err := folderFs.Open("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "same" {
+		t.Errorf("expected same got %s", buf)
+	}
+}
@@ -112,3 +112,7 @@ func (v External) GetVersions() (map[string][]FileVersion, error) {
 func (v External) Restore(filePath string, versionTime time.Time) error {
 	return ErrRestorationNotSupported
 }
+
+func (v External) Open(filePath string, versionTime time.Time) (fs.File, error) {
+	return nil, ErrRestorationNotSupported
+}
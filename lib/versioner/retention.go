@@ -0,0 +1,420 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/sync"
+	"github.com/syncthing/syncthing/lib/util"
+)
+
+// Retention is a policy deciding which versions in an archive are kept.
+// Versions matched by any of the keep rules are kept, and the others are
+// removed; without keep rules all versions are kept. Versions older than
+// MaxAge are removed regardless. Finally the oldest versions in the archive
+// are removed until it is no larger than MaxTotalSize.
+type Retention struct {
+	KeepLast     int        // the newest versions of each file
+	KeepDaily    int        // the newest version of each file per day, for this many days
+	KeepWeekly   int        // the newest version of each file per week, for this many weeks
+	KeepMonthly  int        // the newest version of each file per month, for this many months
+	Intervals    []Interval // one version per step within each age interval
+	MaxAge       time.Duration
+	MaxTotalSize int64
+}
+
+// retentionFromParams returns the retention rules common to all versioners:
+// "keepLast", "keepDaily", "keepWeekly", "keepMonthly", "maxAge" (in
+// seconds) and "maxTotalSizeMiB".
+func retentionFromParams(params map[string]string) Retention {
+	atoi := func(key string) int {
+		n, _ := strconv.Atoi(params[key])
+		if n < 0 {
+			return 0
+		}
+		return n
+	}
+	return Retention{
+		KeepLast:     atoi("keepLast"),
+		KeepDaily:    atoi("keepDaily"),
+		KeepWeekly:   atoi("keepWeekly"),
+		KeepMonthly:  atoi("keepMonthly"),
+		MaxAge:       time.Duration(atoi("maxAge")) * time.Second,
+		MaxTotalSize: int64(atoi("maxTotalSizeMiB")) << 20,
+	}
+}
+
+func (r Retention) hasKeepRules() bool {
+	return r.KeepLast > 0 || r.KeepDaily > 0 || r.KeepWeekly > 0 || r.KeepMonthly > 0 || len(r.Intervals) > 0
+}
+
+// isTimeBased returns true if versions can expire without new ones being
+// archived, so that the archive needs cleaning periodically.
+func (r Retention) isTimeBased() bool {
+	return r.KeepDaily > 0 || r.KeepWeekly > 0 || r.KeepMonthly > 0 || len(r.Intervals) > 0 || r.MaxAge > 0 || r.MaxTotalSize > 0
+}
+
+type archivedVersion struct {
+	path string
+	time time.Time
+	size int64
+}
+
+// expired returns the paths of the versions of a single file which are not
+// kept by the keep rules or are too old.
+func (r Retention) expired(versions []archivedVersion, now time.Time) []string {
+	sort.SliceStable(versions, func(a, b int) bool {
+		return versions[a].time.Before(versions[b].time)
+	})
+	keep := r.keep(versions, now)
+
+	var remove []string
+	for i, v := range versions {
+		if !keep[i] || (r.MaxAge > 0 && now.Sub(v.time) > r.MaxAge) {
+			remove = append(remove, v.path)
+		}
+	}
+	return remove
+}
+
+// oversize returns the paths of the oldest of the given versions which need
+// to be removed to stay within MaxTotalSize.
+func (r Retention) oversize(versions []archivedVersion) []string {
+	if r.MaxTotalSize <= 0 {
+		return nil
+	}
+	var total int64
+	for _, v := range versions {
+		total += v.size
+	}
+	sort.SliceStable(versions, func(a, b int) bool {
+		return versions[a].time.Before(versions[b].time)
+	})
+
+	var remove []string
+	for _, v := range versions {
+		if total <= r.MaxTotalSize {
+			break
+		}
+		remove = append(remove, v.path)
+		total -= v.size
+	}
+	return remove
+}
+
+// keep returns which of the versions, sorted oldest first, are matched by a
+// keep rule.
+func (r Retention) keep(versions []archivedVersion, now time.Time) []bool {
+	keep := make([]bool, len(versions))
+	if !r.hasKeepRules() {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+
+	for i := len(versions) - 1; i >= 0 && i >= len(versions)-r.KeepLast; i-- {
+		keep[i] = true
+	}
+
+	keepPer := func(n int, bucket func(time.Time) string) {
+		prev := ""
+		for i := len(versions) - 1; i >= 0 && n > 0; i-- {
+			if b := bucket(versions[i].time.In(locationLocal)); b != prev {
+				keep[i] = true
+				prev = b
+				n--
+			}
+		}
+	}
+	keepPer(r.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPer(r.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepPer(r.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	if len(r.Intervals) > 0 {
+		var prevAge int64
+		firstFile := true
+		lastIntv := r.Intervals[len(r.Intervals)-1]
+		for i, v := range versions {
+			age := int64(now.Sub(v.time).Seconds())
+
+			// Files older than the max age of the last interval are not kept
+			if lastIntv.end > 0 && age > lastIntv.end {
+				continue
+			}
+
+			// If it's the first (oldest) file in the list we can skip the interval checks
+			if firstFile {
+				keep[i] = true
+				prevAge = age
+				firstFile = false
+				continue
+			}
+
+			// Find the interval the file fits in
+			var usedInterval Interval
+			for _, usedInterval = range r.Intervals {
+				if age < usedInterval.end {
+					break
+				}
+			}
+
+			if prevAge-age < usedInterval.step {
+				continue
+			}
+
+			keep[i] = true
+			prevAge = age
+		}
+	}
+
+	return keep
+}
+
+// cleaner applies a retention policy to a version archive: to the versions
+// of a file whenever a new one is archived, and periodically to the whole
+// archive when the policy is time or size based.
+type cleaner struct {
+	versionsFs fs.Filesystem
+	retention  Retention
+	// Whether versions are tagged with their time. Untagged versions, as
+	// kept by the trash can, are timed by their modification time instead.
+	tagged bool
+	// A directory in the archive not holding versions, if any.
+	skipDir string
+	// Called with the lock held after cleaning the whole archive, if set.
+	afterClean func()
+	firstClean time.Duration
+	interval   time.Duration
+	mut        sync.Mutex
+	stop       chan struct{}
+
+	testCleanDone chan struct{}
+}
+
+// newCleaner returns a cleaner which first cleans the archive after
+// firstClean and then every "cleanInterval" seconds as given in the params,
+// or the default interval.
+func newCleaner(versionsFs fs.Filesystem, retention Retention, tagged bool, params map[string]string, firstClean, defaultInterval time.Duration) *cleaner {
+	interval := defaultInterval
+	if secs, err := strconv.Atoi(params["cleanInterval"]); err == nil && secs > 0 {
+		interval = time.Duration(secs) * time.Second
+	}
+	return &cleaner{
+		versionsFs: versionsFs,
+		retention:  retention,
+		tagged:     tagged,
+		firstClean: firstClean,
+		interval:   interval,
+		mut:        sync.NewMutex(),
+		stop:       make(chan struct{}),
+	}
+}
+
+func (c *cleaner) Serve() {
+	l.Debugln(c, "starting")
+	defer l.Debugln(c, "stopping")
+
+	testCleanDone := c.testCleanDone
+	timer := time.NewTimer(c.firstClean)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+
+		case <-timer.C:
+			if c.retention.isTimeBased() || c.afterClean != nil {
+				if err := c.clean(); err != nil {
+					l.Infoln("Cleaning versions:", err)
+				}
+			}
+			if testCleanDone != nil {
+				close(testCleanDone)
+				testCleanDone = nil
+			}
+			timer.Reset(c.interval)
+		}
+	}
+}
+
+func (c *cleaner) Stop() {
+	close(c.stop)
+}
+
+func (c *cleaner) String() string {
+	return fmt.Sprintf("versioncleaner@%p", c)
+}
+
+// clean applies the retention policy to the whole archive and removes
+// directories left empty.
+func (c *cleaner) clean() error {
+	l.Debugln("Versioner clean: Waiting for lock on", c.versionsFs)
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	l.Debugln("Versioner clean: Cleaning", c.versionsFs)
+
+	if _, err := c.versionsFs.Stat("."); fs.IsNotExist(err) {
+		// There is no need to clean a nonexistent dir.
+		return nil
+	}
+
+	versionsPerFile := make(map[string][]archivedVersion)
+	dirTracker := make(emptyDirTracker)
+	var files []string
+
+	walkFn := func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if c.skipDir != "" && path == c.skipDir {
+			return fs.SkipDir
+		}
+
+		if info.IsDir() && !info.IsSymlink() {
+			dirTracker.addDir(path)
+			return nil
+		}
+
+		// Regular file, or possibly a symlink.
+		files = append(files, path)
+		if name, v, ok := c.version(path, info); ok {
+			versionsPerFile[name] = append(versionsPerFile[name], v)
+		}
+		return nil
+	}
+
+	if err := c.versionsFs.Walk(".", walkFn); err != nil {
+		return err
+	}
+
+	removed := make(map[string]struct{})
+	var kept []archivedVersion
+	now := time.Now()
+	for _, versions := range versionsPerFile {
+		for _, path := range c.retention.expired(versions, now) {
+			removed[path] = struct{}{}
+		}
+		for _, v := range versions {
+			if _, ok := removed[v.path]; !ok {
+				kept = append(kept, v)
+			}
+		}
+	}
+	for _, path := range c.retention.oversize(kept) {
+		removed[path] = struct{}{}
+	}
+
+	for _, path := range files {
+		if _, ok := removed[path]; ok && c.remove(path) {
+			continue
+		}
+		// Keep this file, and remember it so we don't unnecessarily try
+		// to remove this directory.
+		dirTracker.addFile(path)
+	}
+
+	dirTracker.deleteEmptyDirs(c.versionsFs)
+
+	if c.afterClean != nil {
+		c.afterClean()
+	}
+
+	l.Debugln("Cleaner: Finished cleaning", c.versionsFs)
+	return nil
+}
+
+// cleanFile applies the keep rules and maximum age to the versions of a
+// single file, as after archiving a new version of it.
+func (c *cleaner) cleanFile(filePath string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	file := filepath.Base(filePath)
+	dir := filepath.Dir(filePath)
+
+	// Glob according to the new file~timestamp.ext pattern.
+	pattern := filepath.Join(dir, TagFilename(file, TimeGlob))
+	newVersions, err := c.versionsFs.Glob(pattern)
+	if err != nil {
+		l.Warnln("globbing:", err, "for", pattern)
+		return
+	}
+
+	// Also according to the old file.ext~timestamp pattern.
+	pattern = filepath.Join(dir, file+"~"+TimeGlob)
+	oldVersions, err := c.versionsFs.Glob(pattern)
+	if err != nil {
+		l.Warnln("globbing:", err, "for", pattern)
+		return
+	}
+
+	var versions []archivedVersion
+	for _, path := range util.UniqueStrings(append(oldVersions, newVersions...)) {
+		info, err := c.versionsFs.Lstat(path)
+		if err != nil {
+			l.Warnln("versioner:", err)
+			continue
+		} else if info.IsDir() {
+			l.Infof("non-file %q is named like a file version", path)
+			continue
+		}
+		if _, v, ok := c.version(path, info); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	l.Debugln("Versioner: Expiring versions of", filePath)
+	for _, path := range c.retention.expired(versions, time.Now()) {
+		c.remove(path)
+	}
+}
+
+// version returns the name of the file the archived path is a version of,
+// and the version.
+func (c *cleaner) version(path string, info fs.FileInfo) (string, archivedVersion, bool) {
+	v := archivedVersion{path: path, time: info.ModTime(), size: info.Size()}
+	if !c.tagged {
+		return path, v, true
+	}
+
+	name, tag := UntagFilename(path)
+	if name == "" {
+		return "", v, false
+	}
+	versionTime, err := time.ParseInLocation(TimeFormat, tag, locationLocal)
+	if err != nil {
+		l.Debugf("Versioner: file name %q is invalid: %v", path, err)
+		return "", v, false
+	}
+	v.time = versionTime
+	return name, v, true
+}
+
+func (c *cleaner) remove(path string) bool {
+	l.Debugln("cleaning out", path)
+	if err := c.versionsFs.Remove(path); err != nil {
+		l.Warnf("Versioner: can't remove %q: %v", path, err)
+		return false
+	}
+	return true
+}
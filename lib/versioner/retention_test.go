@@ -0,0 +1,109 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/d4l3k/messagediff"
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestRetentionKeepRules(t *testing.T) {
+	now, _ := time.ParseInLocation(TimeFormat, "20190415-140000", locationLocal)
+	names := []string{
+		"test~20190415-135000", // today, a Monday
+		"test~20190415-120000", // today
+		"test~20190414-180000", // yesterday
+		"test~20190414-100000", // yesterday
+		"test~20190410-100000", // earlier this week
+		"test~20190403-100000", // last week
+		"test~20190402-100000", // last week
+		"test~20190315-100000", // last month
+		"test~20190101-100000", // long ago
+	}
+	var versions []archivedVersion
+	for _, name := range names {
+		versionTime, _ := time.ParseInLocation(TimeFormat, ExtractTag(name), locationLocal)
+		versions = append(versions, archivedVersion{path: name, time: versionTime})
+	}
+
+	cases := []struct {
+		retention Retention
+		remove    []string
+	}{
+		// No rules keep everything
+		{Retention{}, nil},
+		{Retention{KeepLast: 3}, names[3:]},
+		{Retention{KeepDaily: 2}, []string{names[1], names[3], names[4], names[5], names[6], names[7], names[8]}},
+		{Retention{KeepWeekly: 3}, []string{names[1], names[3], names[4], names[6], names[7], names[8]}},
+		{Retention{KeepMonthly: 2}, []string{names[1], names[2], names[3], names[4], names[5], names[6], names[8]}},
+		// Rules combine, and the age limit applies regardless
+		{Retention{KeepLast: 1, KeepWeekly: 2}, []string{names[1], names[3], names[4], names[5], names[6], names[7], names[8]}},
+		{Retention{KeepLast: 5, MaxAge: 48 * time.Hour}, names[4:]},
+		{Retention{MaxAge: 48 * time.Hour}, names[4:]},
+	}
+
+	for i, tc := range cases {
+		rem := tc.retention.expired(append([]archivedVersion(nil), versions...), now)
+		remove := append([]string(nil), tc.remove...)
+		sort.Strings(rem)
+		sort.Strings(remove)
+		if diff, equal := messagediff.PrettyDiff(remove, rem); !equal {
+			t.Errorf("%d: incorrect removed versions; got %v, expected %v\n%v", i, rem, remove, diff)
+		}
+	}
+}
+
+func TestRetentionMaxTotalSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	versionsDir := filepath.Join(dir, ".stversions")
+	now := time.Now()
+	files := []struct {
+		name   string
+		remove bool
+	}{
+		{"a~" + now.Add(-3*time.Hour).Format(TimeFormat), true},
+		{"sub/b~" + now.Add(-2*time.Hour).Format(TimeFormat), true},
+		{"a~" + now.Add(-time.Hour).Format(TimeFormat), false},
+		{"sub/b~" + now.Format(TimeFormat), false},
+	}
+	for _, f := range files {
+		path := filepath.Join(versionsDir, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		// One MiB each
+		if err := ioutil.WriteFile(path, make([]byte, 1<<20), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := NewSimple("", fs.NewFilesystem(fs.FilesystemTypeBasic, dir), map[string]string{"maxTotalSizeMiB": "2"}).(Simple)
+	if err := v.clean(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		_, err := os.Lstat(filepath.Join(versionsDir, filepath.FromSlash(f.name)))
+		if f.remove && !os.IsNotExist(err) {
+			t.Error(f.name, "should have been removed")
+		} else if !f.remove && err != nil {
+			t.Error(f.name, "should not have been removed")
+		}
+	}
+}
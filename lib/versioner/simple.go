@@ -7,12 +7,10 @@
 package versioner
 
 import (
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/fs"
-	"github.com/syncthing/syncthing/lib/util"
 )
 
 func init() {
@@ -21,21 +19,24 @@ func init() {
 }
 
 type Simple struct {
-	keep       int
+	*cleaner
 	folderFs   fs.Filesystem
 	versionsFs fs.Filesystem
 }
 
 func NewSimple(folderID string, folderFs fs.Filesystem, params map[string]string) Versioner {
-	keep, err := strconv.Atoi(params["keep"])
-	if err != nil {
-		keep = 5 // A reasonable default
+	retention := retentionFromParams(params)
+	if keep, err := strconv.Atoi(params["keep"]); err == nil {
+		retention.KeepLast = keep
+	} else if !retention.hasKeepRules() {
+		retention.KeepLast = 5 // A reasonable default
 	}
 
+	versionsFs := fsFromParams(folderFs, params)
 	s := Simple{
-		keep:       keep,
+		cleaner:    newCleaner(versionsFs, retention, true, params, time.Minute, time.Hour),
 		folderFs:   folderFs,
-		versionsFs: fsFromParams(folderFs, params),
+		versionsFs: versionsFs,
 	}
 
 	l.Debugf("instantiated %#v", s)
@@ -50,39 +51,7 @@ func (v Simple) Archive(filePath string) error {
 		return err
 	}
 
-	file := filepath.Base(filePath)
-	dir := filepath.Dir(filePath)
-
-	// Glob according to the new file~timestamp.ext pattern.
-	pattern := filepath.Join(dir, TagFilename(file, TimeGlob))
-	newVersions, err := v.versionsFs.Glob(pattern)
-	if err != nil {
-		l.Warnln("globbing:", err, "for", pattern)
-		return nil
-	}
-
-	// Also according to the old file.ext~timestamp pattern.
-	pattern = filepath.Join(dir, file+"~"+TimeGlob)
-	oldVersions, err := v.versionsFs.Glob(pattern)
-	if err != nil {
-		l.Warnln("globbing:", err, "for", pattern)
-		return nil
-	}
-
-	// Use all the found filenames. "~" sorts after "." so all old pattern
-	// files will be deleted before any new, which is as it should be.
-	versions := util.UniqueStrings(append(oldVersions, newVersions...))
-
-	if len(versions) > v.keep {
-		for _, toRemove := range versions[:len(versions)-v.keep] {
-			l.Debugln("cleaning out", toRemove)
-			err = v.versionsFs.Remove(toRemove)
-			if err != nil {
-				l.Warnln("removing old version:", err)
-			}
-		}
-	}
-
+	v.cleanFile(filePath)
 	return nil
 }
 
@@ -93,3 +62,7 @@ func (v Simple) GetVersions() (map[string][]FileVersion, error) {
 func (v Simple) Restore(filepath string, versionTime time.Time) error {
 	return restoreFile(v.versionsFs, v.folderFs, filepath, versionTime, TagFilename)
 }
+
+func (v Simple) Open(filepath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(v.versionsFs, filepath, versionTime)
+}
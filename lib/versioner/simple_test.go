@@ -9,6 +9,7 @@ package versioner
 import (
 	"io/ioutil"
 	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -87,3 +88,149 @@ func TestSimpleVersioningVersionCount(t *testing.T) {
 		time.Sleep(time.Second)
 	}
 }
+
+func TestSimpleVersioningOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	folderFs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+	v := NewSimple("", folderFs, map[string]string{"keep": "5"})
+
+	writeFile(t, folderFs, "file", "A")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := folderFs.Chtimes("file", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Archive("file"); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := v.Open("file", old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "A" {
+		t.Errorf("expected A got %s", buf)
+	}
+
+	if _, err := v.Open("file", old.Add(time.Second)); err != errNotFound {
+		t.Error("Expected not found error, got", err)
+	}
+}
+
+func TestSimpleVersioningRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	folderFs := fs.NewFilesystem(fs.FilesystemTypeBasic, dir)
+	v := NewSimple("", folderFs, map[string]string{"keep": "5"})
+
+	if err := folderFs.MkdirAll("sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, folderFs, "sub/file", "A")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := folderFs.Chtimes("sub/file", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Archive("sub/file"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, folderFs, "sub/file", "B")
+
+	if err := v.Restore("sub/file", old.Add(time.Second)); err == nil {
+		t.Fatal("Expected restoring a nonexistent version to fail")
+	}
+	if err := v.Restore("sub/file", old); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := folderFs.Open("sub/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(fd)
+	fd.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "A" {
+		t.Errorf("expected A got %s", buf)
+	}
+
+	// The replaced file is archived, and no temporary file remains
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(versions["sub/file"]); n != 1 {
+		t.Error("Expected the replaced file to be archived, got", n, "versions")
+	}
+	if _, err := folderFs.Lstat(fs.TempName(filepath.Join("sub", "file"))); !fs.IsNotExist(err) {
+		t.Error("Expected temporary file to be gone, got", err)
+	}
+
+	// Directories are not replaced
+	if err := folderFs.MkdirAll("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Restore("dir", old); err != errDirectory {
+		t.Error("Expected directory error, got", err)
+	}
+}
+
+func TestSimpleVersioningOtherLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	folderFs := fs.NewFilesystem(fs.FilesystemTypeBasic, filepath.Join(dir, "folder"))
+	if err := folderFs.MkdirAll(".", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions go to a directory outside the folder, which doesn't exist
+	// yet, and to one relative to the folder.
+	for _, fsPath := range []string{filepath.Join(dir, "elsewhere", "versions"), "versions"} {
+		v := NewSimple("", folderFs, map[string]string{"keep": "5", "fsType": "basic", "fsPath": fsPath})
+		versionsDir := fsPath
+		if !filepath.IsAbs(versionsDir) {
+			versionsDir = filepath.Join(folderFs.URI(), versionsDir)
+		}
+
+		writeFile(t, folderFs, "file", "A")
+		old := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if err := folderFs.Chtimes("file", old, old); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Archive("file"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(filepath.Join(versionsDir, TagFilename("file", old.Format(TimeFormat)))); err != nil {
+			t.Fatal("Version not archived to", versionsDir, err)
+		}
+
+		if err := v.Restore("file", old); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := folderFs.Lstat("file"); err != nil {
+			t.Fatal("File not restored:", err)
+		}
+		if err := folderFs.Remove("file"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -20,21 +20,25 @@ func init() {
 }
 
 type Trashcan struct {
-	folderFs     fs.Filesystem
-	versionsFs   fs.Filesystem
-	cleanoutDays int
-	stop         chan struct{}
+	*cleaner
+	folderFs   fs.Filesystem
+	versionsFs fs.Filesystem
 }
 
 func NewTrashcan(folderID string, folderFs fs.Filesystem, params map[string]string) Versioner {
-	cleanoutDays, _ := strconv.Atoi(params["cleanoutDays"])
-	// On error we default to 0, "do not clean out the trash can"
+	retention := retentionFromParams(params)
+	if cleanoutDays, _ := strconv.Atoi(params["cleanoutDays"]); cleanoutDays > 0 {
+		retention.MaxAge = time.Duration(cleanoutDays) * 24 * time.Hour
+	}
+	// Otherwise the default is "do not clean out the trash can"
 
+	versionsFs := fsFromParams(folderFs, params)
 	s := &Trashcan{
-		folderFs:     folderFs,
-		versionsFs:   fsFromParams(folderFs, params),
-		cleanoutDays: cleanoutDays,
-		stop:         make(chan struct{}),
+		// Do the first cleanup one minute after startup. Cleanups once a
+		// day should be enough.
+		cleaner:    newCleaner(versionsFs, retention, false, params, time.Minute, 24*time.Hour),
+		folderFs:   folderFs,
+		versionsFs: versionsFs,
 	}
 
 	l.Debugf("instantiated %#v", s)
@@ -49,78 +53,10 @@ func (t *Trashcan) Archive(filePath string) error {
 	})
 }
 
-func (t *Trashcan) Serve() {
-	l.Debugln(t, "starting")
-	defer l.Debugln(t, "stopping")
-
-	// Do the first cleanup one minute after startup.
-	timer := time.NewTimer(time.Minute)
-	defer timer.Stop()
-
-	for {
-		select {
-		case <-t.stop:
-			return
-
-		case <-timer.C:
-			if t.cleanoutDays > 0 {
-				if err := t.cleanoutArchive(); err != nil {
-					l.Infoln("Cleaning trashcan:", err)
-				}
-			}
-
-			// Cleanups once a day should be enough.
-			timer.Reset(24 * time.Hour)
-		}
-	}
-}
-
-func (t *Trashcan) Stop() {
-	close(t.stop)
-}
-
 func (t *Trashcan) String() string {
 	return fmt.Sprintf("trashcan@%p", t)
 }
 
-func (t *Trashcan) cleanoutArchive() error {
-	if _, err := t.versionsFs.Lstat("."); fs.IsNotExist(err) {
-		return nil
-	}
-
-	cutoff := time.Now().Add(time.Duration(-24*t.cleanoutDays) * time.Hour)
-	dirTracker := make(emptyDirTracker)
-
-	walkFn := func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if info.IsDir() && !info.IsSymlink() {
-			dirTracker.addDir(path)
-			return nil
-		}
-
-		if info.ModTime().Before(cutoff) {
-			// The file is too old; remove it.
-			err = t.versionsFs.Remove(path)
-		} else {
-			// Keep this file, and remember it so we don't unnecessarily try
-			// to remove this directory.
-			dirTracker.addFile(path)
-		}
-		return err
-	}
-
-	if err := t.versionsFs.Walk(".", walkFn); err != nil {
-		return err
-	}
-
-	dirTracker.deleteEmptyDirs(t.versionsFs)
-
-	return nil
-}
-
 func (t *Trashcan) GetVersions() (map[string][]FileVersion, error) {
 	return retrieveVersions(t.versionsFs)
 }
@@ -145,3 +81,7 @@ func (t *Trashcan) Restore(filepath string, versionTime time.Time) error {
 
 	return t.versionsFs.Rename(taggedName, filepath)
 }
+
+func (t *Trashcan) Open(filepath string, versionTime time.Time) (fs.File, error) {
+	return openVersion(t.versionsFs, filepath, versionTime)
+}
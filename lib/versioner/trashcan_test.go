@@ -54,7 +54,7 @@ func TestTrashcanCleanout(t *testing.T) {
 	}
 
 	versioner := NewTrashcan("default", fs.NewFilesystem(fs.FilesystemTypeBasic, "testdata"), map[string]string{"cleanoutDays": "7"}).(*Trashcan)
-	if err := versioner.cleanoutArchive(); err != nil {
+	if err := versioner.clean(); err != nil {
 		t.Fatal(err)
 	}
 
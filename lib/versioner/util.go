@@ -72,6 +72,12 @@ func UntagFilename(path string) (string, string) {
 }
 
 func retrieveVersions(fileSystem fs.Filesystem) (map[string][]FileVersion, error) {
+	return retrieveVersionsExcept(fileSystem, "")
+}
+
+// retrieveVersionsExcept is like retrieveVersions, but does not look into
+// the given directory of the archive.
+func retrieveVersionsExcept(fileSystem fs.Filesystem, skipDir string) (map[string][]FileVersion, error) {
 	files := make(map[string][]FileVersion)
 
 	err := fileSystem.Walk(".", func(path string, f fs.FileInfo, err error) error {
@@ -85,6 +91,10 @@ func retrieveVersions(fileSystem fs.Filesystem) (map[string][]FileVersion, error
 			return err
 		}
 
+		if skipDir != "" && path == skipDir {
+			return fs.SkipDir
+		}
+
 		// Ignore symlinks
 		if f.IsSymlink() {
 			return fs.SkipDir
@@ -152,7 +162,7 @@ func archiveFile(srcFs, dstFs fs.Filesystem, filePath string, tagger fileTagger)
 	if err != nil {
 		if fs.IsNotExist(err) {
 			l.Debugln("creating versions dir")
-			err := dstFs.Mkdir(".", 0755)
+			err := dstFs.MkdirAll(".", 0755)
 			if err != nil {
 				return err
 			}
@@ -186,86 +196,132 @@ func archiveFile(srcFs, dstFs fs.Filesystem, filePath string, tagger fileTagger)
 }
 
 func restoreFile(src, dst fs.Filesystem, filePath string, versionTime time.Time, tagger fileTagger) error {
+	// We can't restore on top of a directory
+	info, err := dst.Lstat(filePath)
+	if err == nil && info.IsDir() {
+		return errDirectory
+	} else if err != nil && !fs.IsNotExist(err) {
+		return err
+	}
+
+	filePath = osutil.NativeFilename(filePath)
+	sourceFile, err := findVersion(src, filePath, versionTime)
+	if err != nil {
+		return err
+	}
+
+	// Move the version next to the target under a temporary name first, so
+	// that the file is never seen partially restored, even if the version
+	// has to be copied across filesystems.
+	tempPath := fs.TempName(filePath)
+	_ = dst.MkdirAll(filepath.Dir(filePath), 0755)
+	if err := osutil.RenameOrCopy(src, dst, sourceFile, tempPath); err != nil {
+		return errors.Wrap(err, "moving version into place")
+	}
+	unstage := func() {
+		_ = osutil.RenameOrCopy(dst, src, tempPath, sourceFile)
+	}
+
 	// If the something already exists where we are restoring to, archive existing file for versioning
-	// remove if it's a symlink, or fail if it's a directory
+	// remove if it's a symlink
 	if info, err := dst.Lstat(filePath); err == nil {
 		switch {
-		case info.IsDir():
-			return errDirectory
 		case info.IsSymlink():
 			// Remove existing symlinks (as we don't want to archive them)
 			if err := dst.Remove(filePath); err != nil {
+				unstage()
 				return errors.Wrap(err, "removing existing symlink")
 			}
 		case info.IsRegular():
 			if err := archiveFile(dst, src, filePath, tagger); err != nil {
+				unstage()
 				return errors.Wrap(err, "archiving existing file")
 			}
 		default:
-			panic("bug: unknown item type")
+			unstage()
+			return errDirectory
 		}
 	} else if !fs.IsNotExist(err) {
+		unstage()
 		return err
 	}
 
-	filePath = osutil.NativeFilename(filePath)
+	// Check that the target location of where we are supposed to restore does not exist.
+	// This should have been taken care of by the archiving above.
+	if _, err := dst.Lstat(filePath); err == nil {
+		unstage()
+		return errFileAlreadyExists
+	} else if !fs.IsNotExist(err) {
+		unstage()
+		return err
+	}
+
+	if err := dst.Rename(tempPath, filePath); err != nil {
+		unstage()
+		return err
+	}
+	return nil
+}
+
+// findVersion returns the name of the given version of the file in the
+// versions filesystem.
+func findVersion(src fs.Filesystem, filePath string, versionTime time.Time) (string, error) {
 	tag := versionTime.In(locationLocal).Truncate(time.Second).Format(TimeFormat)
 
 	taggedFilename := TagFilename(filePath, tag)
 	oldTaggedFilename := filePath + tag
 	untaggedFileName := filePath
 
-	// Check that the thing we've been asked to restore is actually a file
-	// and that it exists.
-	sourceFile := ""
+	// Check that the thing we've been asked for is actually a file and that
+	// it exists.
 	for _, candidate := range []string{taggedFilename, oldTaggedFilename, untaggedFileName} {
 		if info, err := src.Lstat(candidate); fs.IsNotExist(err) || !info.IsRegular() {
 			continue
 		} else if err != nil {
 			// All other errors are fatal
-			return err
+			return "", err
 		} else if candidate == untaggedFileName && !info.ModTime().Truncate(time.Second).Equal(versionTime) {
 			// No error, and untagged file, but mtime does not match, skip
 			continue
 		}
 
-		sourceFile = candidate
-		break
+		return candidate, nil
 	}
 
-	if sourceFile == "" {
-		return errNotFound
-	}
+	return "", errNotFound
+}
 
-	// Check that the target location of where we are supposed to restore does not exist.
-	// This should have been taken care of by the first few lines of this function.
-	if _, err := dst.Lstat(filePath); err == nil {
-		return errFileAlreadyExists
-	} else if !fs.IsNotExist(err) {
-		return err
+// openVersion opens the given version of the file for reading.
+func openVersion(src fs.Filesystem, filePath string, versionTime time.Time) (fs.File, error) {
+	name, err := findVersion(src, osutil.NativeFilename(filePath), versionTime)
+	if err != nil {
+		return nil, err
 	}
-
-	_ = dst.MkdirAll(filepath.Dir(filePath), 0755)
-	return osutil.RenameOrCopy(src, dst, sourceFile, filePath)
+	return src.Open(name)
 }
 
-func fsFromParams(folderFs fs.Filesystem, params map[string]string) (versionsFs fs.Filesystem) {
-	if params["fsType"] == "" && params["fsPath"] == "" {
-		versionsFs = fs.NewFilesystem(folderFs.Type(), filepath.Join(folderFs.URI(), ".stversions"))
-
-	} else if params["fsType"] == "" {
-		uri := params["fsPath"]
-		// We only know how to deal with relative folders for basic filesystems, as that's the only one we know
-		// how to check if it's absolute or relative.
-		if folderFs.Type() == fs.FilesystemTypeBasic && !filepath.IsAbs(params["fsPath"]) {
-			uri = filepath.Join(folderFs.URI(), params["fsPath"])
-		}
-		versionsFs = fs.NewFilesystem(folderFs.Type(), uri)
-	} else {
-		var fsType fs.FilesystemType
+// fsFromParams returns the filesystem versions are kept in: the
+// .stversions directory of the folder, or the one given by the "fsType" and
+// "fsPath" params. The filesystem type defaults to that of the folder, and
+// relative paths are relative to the folder if both are basic filesystems.
+func fsFromParams(folderFs fs.Filesystem, params map[string]string) fs.Filesystem {
+	fsType := folderFs.Type()
+	if params["fsType"] != "" {
 		_ = fsType.UnmarshalText([]byte(params["fsType"]))
-		versionsFs = fs.NewFilesystem(fsType, params["fsPath"])
 	}
-	l.Debugln("%s (%s) folder using %s (%s) versioner dir", folderFs.URI(), folderFs.Type(), versionsFs.URI(), versionsFs.Type())
-	return
+
+	uri := params["fsPath"]
+	switch {
+	case uri == "" && fsType == folderFs.Type():
+		uri = filepath.Join(folderFs.URI(), ".stversions")
+	case uri != "" && fsType == fs.FilesystemTypeBasic && folderFs.Type() == fs.FilesystemTypeBasic && !filepath.IsAbs(uri):
+		// We only know how to deal with relative folders for basic
+		// filesystems, as that's the only one we know how to check if it's
+		// absolute or relative.
+		uri = filepath.Join(folderFs.URI(), uri)
+	}
+
+	versionsFs := fs.NewFilesystem(fsType, uri)
+	l.Debugf("%s (%s) folder using %s (%s) versioner dir", folderFs.URI(), folderFs.Type(), versionsFs.URI(), versionsFs.Type())
+	return versionsFs
 }
@@ -19,6 +19,7 @@ type Versioner interface {
 	Archive(filePath string) error
 	GetVersions() (map[string][]FileVersion, error)
 	Restore(filePath string, versionTime time.Time) error
+	Open(filePath string, versionTime time.Time) (fs.File, error)
 }
 
 type FileVersion struct {
@@ -0,0 +1,22 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhook
+
+import (
+	"os"
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/logger"
+)
+
+var (
+	l = logger.DefaultLogger.NewFacility("webhook", "Event webhooks")
+)
+
+func init() {
+	l.SetDebug("webhook", strings.Contains(os.Getenv("STTRACE"), "webhook") || os.Getenv("STTRACE") == "all")
+}
@@ -0,0 +1,261 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package webhook posts events to external URLs as they happen.
+//
+// Each event is sent as the JSON of an events.Event in the body of a POST
+// request. The X-Syncthing-Event header carries the event type and, if the
+// webhook has a secret, X-Syncthing-Signature carries "sha256=" followed by
+// the hex encoded HMAC-SHA256 of the body.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+const (
+	queueSize      = 64 // events waiting per webhook before we start dropping
+	requestTimeout = 10 * time.Second
+	maxAttempts    = 3
+	retryDelay     = 2 * time.Second // doubled for every attempt
+)
+
+// Service passes events on to the configured webhooks.
+type Service struct {
+	cfg     config.Wrapper
+	client  *http.Client
+	changed chan struct{}
+	stop    chan struct{}
+}
+
+func New(cfg config.Wrapper) *Service {
+	s := &Service{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	cfg.Subscribe(s)
+	return s
+}
+
+func (s *Service) Serve() {
+	hooks := s.startHooks(s.cfg.RawCopy().Webhooks)
+	sub := subscribe(hooks)
+	defer func() {
+		events.Default.Unsubscribe(sub)
+		stopHooks(hooks)
+	}()
+
+	for {
+		select {
+		case ev := <-sub.C():
+			for _, h := range hooks {
+				h.offer(ev)
+			}
+		case <-s.changed:
+			events.Default.Unsubscribe(sub)
+			stopHooks(hooks)
+			hooks = s.startHooks(s.cfg.RawCopy().Webhooks)
+			sub = subscribe(hooks)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	close(s.stop)
+}
+
+func (s *Service) VerifyConfiguration(from, to config.Configuration) error {
+	return nil
+}
+
+func (s *Service) CommitConfiguration(from, to config.Configuration) bool {
+	if !reflect.DeepEqual(from.Webhooks, to.Webhooks) {
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (*Service) String() string {
+	return "webhook.Service"
+}
+
+func (s *Service) startHooks(cfgs []config.WebhookConfiguration) []*hook {
+	hooks := make([]*hook, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		h, err := newHook(cfg, s.client)
+		if err != nil {
+			l.Infof("Ignoring webhook %s: %v", cfg.URL, err)
+			continue
+		}
+		l.Debugln("Starting webhook", cfg.URL)
+		go h.run()
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+// subscribe returns a subscription to the events any of the hooks wants.
+func subscribe(hooks []*hook) *events.Subscription {
+	var mask events.EventType
+	for _, h := range hooks {
+		mask |= h.mask
+	}
+	return events.Default.Subscribe(mask)
+}
+
+func stopHooks(hooks []*hook) {
+	for _, h := range hooks {
+		close(h.stop)
+	}
+}
+
+// A hook delivers events to a single URL, in order.
+type hook struct {
+	url     string
+	secret  []byte
+	mask    events.EventType
+	client  *http.Client
+	queue   chan events.Event
+	stop    chan struct{}
+	failing bool
+}
+
+func newHook(cfg config.WebhookConfiguration, client *http.Client) (*hook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	mask, err := events.UnmarshalEventMask(cfg.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hook{
+		url:    cfg.URL,
+		mask:   mask,
+		client: client,
+		queue:  make(chan events.Event, queueSize),
+		stop:   make(chan struct{}),
+	}
+	if cfg.Secret != "" {
+		h.secret = []byte(cfg.Secret)
+	}
+	return h, nil
+}
+
+// offer queues the event for delivery if the hook wants it. Events are
+// dropped rather than holding everything else up if the receiver can't keep
+// up.
+func (h *hook) offer(ev events.Event) {
+	if ev.Type&h.mask == 0 {
+		return
+	}
+	select {
+	case h.queue <- ev:
+	default:
+		l.Debugf("Webhook %s: queue full, dropping %v event", h.url, ev.Type)
+	}
+}
+
+func (h *hook) run() {
+	for {
+		select {
+		case ev := <-h.queue:
+			h.deliver(ev)
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+func (h *hook) deliver(ev events.Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		l.Debugf("Webhook %s: marshalling %v event: %v", h.url, ev.Type, err)
+		return
+	}
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err = h.post(ev.Type, body)
+		if err == nil {
+			break
+		}
+		l.Debugf("Webhook %s: attempt %d posting %v event: %v", h.url, attempt, ev.Type, err)
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-h.stop:
+			return
+		}
+		delay *= 2
+	}
+
+	// Only log changes between working and failing, not every failure.
+	if err != nil && !h.failing {
+		l.Infof("Webhook %s is failing: %v", h.url, err)
+	} else if err == nil && h.failing {
+		l.Infof("Webhook %s is working again", h.url)
+	}
+	h.failing = err != nil
+}
+
+func (h *hook) post(t events.EventType, body []byte) error {
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Syncthing-Event", t.String())
+	if h.secret != nil {
+		req.Header.Set("X-Syncthing-Signature", "sha256="+Sign(h.secret, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using the given secret,
+// as sent in the X-Syncthing-Signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
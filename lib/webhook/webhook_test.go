@@ -0,0 +1,87 @@
+// Copyright (C) 2019 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	recv := make(chan received, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		recv <- received{r.Header, body}
+	}))
+	defer srv.Close()
+
+	cfg := config.Wrap("", config.Configuration{
+		Webhooks: []config.WebhookConfiguration{
+			{URL: srv.URL, Events: []string{"FolderCompletion"}, Secret: "s3cr3t"},
+		},
+	})
+	svc := New(cfg)
+	go svc.Serve()
+	defer svc.Stop()
+
+	// Give the service a moment to subscribe.
+	time.Sleep(100 * time.Millisecond)
+
+	events.Default.Log(events.ItemFinished, map[string]string{"item": "ignored"})
+	events.Default.Log(events.FolderCompletion, map[string]string{"folder": "default"})
+
+	var r received
+	select {
+	case r = <-recv:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for webhook")
+	}
+
+	if et := r.header.Get("X-Syncthing-Event"); et != "FolderCompletion" {
+		t.Error("Unexpected event type", et)
+	}
+	if sig := r.header.Get("X-Syncthing-Signature"); sig != "sha256="+Sign([]byte("s3cr3t"), r.body) {
+		t.Error("Bad signature", sig)
+	}
+	var ev events.Event
+	if err := json.Unmarshal(r.body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != events.FolderCompletion {
+		t.Error("Unexpected event", ev)
+	}
+
+	select {
+	case r = <-recv:
+		t.Error("Unexpected second delivery", string(r.body))
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWebhookInvalidConfig(t *testing.T) {
+	cases := []config.WebhookConfiguration{
+		{URL: "ftp://example.com/"},
+		{URL: "https://example.com/", Events: []string{"NoSuchEvent"}},
+	}
+	for _, tc := range cases {
+		if _, err := newHook(tc, http.DefaultClient); err == nil {
+			t.Errorf("Expected an error for %+v", tc)
+		}
+	}
+}